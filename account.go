@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+)
+
+const accountAddUsage = `Usage: pgbudget account add -name <name> [flags]
+
+Adds a bank account (asset) or credit card (liability) to a ledger. Budget
+categories are added with "pgbudget category add".
+
+Flags:
+`
+
+const accountListUsage = `Usage: pgbudget account list [flags]
+
+Lists the asset and liability accounts of a ledger.
+
+Flags:
+`
+
+const accountBalanceUsage = `Usage: pgbudget account balance [flags]
+
+Shows the balance of one account with -account, or of every account and
+category of the ledger without it. balance counts pending transactions,
+cleared only the ones posted.
+
+Flags:
+`
+
+const accountReconcileUsage = `Usage: pgbudget account reconcile -account <uuid> -balance <amount> [flags]
+
+Reconciles a bank account or credit card with a statement. The cleared
+balance, counting the posted transactions up to -date, is compared with the
+ending -balance of the statement; for a credit card that is what is owed.
+A difference is recorded as an adjustment to -category, then the posted
+transactions up to -date are locked: tx correct and tx delete refuse to
+change them without -override.
+
+With -dry-run the difference is shown and nothing is changed.
+
+Flags:
+`
+
+const accountReconciliationsUsage = `Usage: pgbudget account reconciliations -account <uuid> [flags]
+
+Lists the reconciliations of an account, latest first.
+
+Flags:
+`
+
+func runAccount(ctx context.Context, args []string) error {
+	return runSubcommand(
+		ctx, "account", []subcommand{
+			{"add", "add an account", runAccountAdd},
+			{"list", "list accounts", runAccountList},
+			{"balance", "show account balances", runAccountBalance},
+			{"reconcile", "reconcile an account with a statement", runAccountReconcile},
+			{"reconciliations", "list the reconciliations of an account", runAccountReconciliations},
+		}, args,
+	)
+}
+
+func runAccountAdd(ctx context.Context, args []string) error {
+	fs := newFlagSet("account add", accountAddUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	name := fs.String("name", "", "account name")
+	accountType := fs.String("type", string(client.AccountTypeAsset), "account type: asset or liability")
+	description := fs.String("description", "", "account description")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	switch {
+	case *name == "":
+		return errors.New("missing -name")
+	case *accountType != string(client.AccountTypeAsset) && *accountType != string(client.AccountTypeLiability):
+		return fmt.Errorf("invalid -type %q: use asset or liability", *accountType)
+	}
+
+	var account *client.Account
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		account, err = c.CreateAccount(
+			ctx, client.CreateAccountParams{
+				LedgerUUID:  s.ledger,
+				Name:        *name,
+				Type:        client.AccountType(*accountType),
+				Description: *description,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(account, accountTable([]client.Account{*account}))
+}
+
+func runAccountList(ctx context.Context, args []string) error {
+	fs := newFlagSet("account list", accountListUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+
+	var accounts []client.Account
+	err := s.with(ctx, func(c *client.Client) error {
+		all, err := c.ListAccounts(ctx, s.ledger)
+		if err != nil {
+			return err
+		}
+		accounts = []client.Account{}
+		for _, a := range all {
+			if a.Type == client.AccountTypeAsset || a.Type == client.AccountTypeLiability {
+				accounts = append(accounts, a)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(accounts, accountTable(accounts))
+}
+
+func runAccountBalance(ctx context.Context, args []string) error {
+	fs := newFlagSet("account balance", accountBalanceUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	account := fs.String("account", "", "account UUID; all accounts of the ledger when empty")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if *account == "" {
+		if err := s.requireLedger(); err != nil {
+			return err
+		}
+	}
+
+	var balances []client.LedgerBalance
+	err := s.with(ctx, func(c *client.Client) error {
+		if *account == "" {
+			var err error
+			balances, err = c.GetLedgerBalances(ctx, s.ledger)
+			return err
+		}
+
+		acct, err := c.GetAccount(ctx, *account)
+		if err != nil {
+			return err
+		}
+		balance, err := c.GetAccountBalance(ctx, *account)
+		if err != nil {
+			return err
+		}
+		cleared, err := c.GetAccountClearedBalance(ctx, *account)
+		if err != nil {
+			return err
+		}
+		balances = []client.LedgerBalance{
+			{
+				AccountUUID: acct.UUID, AccountName: acct.Name, AccountType: acct.Type,
+				CurrentBalance: balance, ClearedBalance: cleared,
+			},
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	t := table{header: []string{"uuid", "name", "type", "balance", "cleared"}}
+	for _, b := range balances {
+		t.rows = append(
+			t.rows, []string{
+				b.AccountUUID, b.AccountName, string(b.AccountType),
+				client.FormatAmount(b.CurrentBalance), client.FormatAmount(b.ClearedBalance),
+			},
+		)
+	}
+	return s.print(balances, t)
+}
+
+func runAccountReconcile(ctx context.Context, args []string) error {
+	fs := newFlagSet("account reconcile", accountReconcileUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	account := fs.String("account", "", "account UUID")
+	balance := fs.String("balance", "", "ending balance of the statement, e.g. 1234.56 or -20.00")
+	date := fs.String("date", "", "last day of the statement as YYYY-MM-DD (default today)")
+	category := fs.String("category", "", "category name or UUID of an adjustment (default Income)")
+	dryRun := fs.Bool("dry-run", false, "show the difference without reconciling")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	switch {
+	case *account == "":
+		return errors.New("missing -account")
+	case *balance == "":
+		return errors.New("missing -balance")
+	}
+	if *category != "" {
+		// category names are looked up in the ledger
+		if err := s.requireLedger(); err != nil {
+			return err
+		}
+	}
+	statementBalance, err := client.ParseBalance(*balance)
+	if err != nil {
+		return err
+	}
+	statementDate, err := parseDate(*date)
+	if err != nil {
+		return err
+	}
+
+	params := client.ReconcileParams{
+		AccountUUID:      *account,
+		StatementBalance: statementBalance,
+		StatementDate:    statementDate,
+	}
+	var preview *client.ReconciliationPreview
+	var reconciliationUUID string
+	err = s.with(ctx, func(c *client.Client) (err error) {
+		if preview, err = c.PreviewReconciliation(ctx, params); err != nil || *dryRun {
+			return err
+		}
+		if params.AdjustmentCategoryUUID, err = resolveCategory(ctx, c, s.ledger, *category); err != nil {
+			return err
+		}
+		reconciliationUUID, err = c.ReconcileAccount(ctx, params)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	t := table{
+		header: []string{"statement", "cleared", "difference", "transactions"},
+		rows: [][]string{
+			{
+				client.FormatAmount(preview.StatementBalance), client.FormatAmount(preview.ClearedBalance),
+				client.FormatAmount(preview.Difference), strconv.Itoa(preview.Transactions),
+			},
+		},
+	}
+	switch {
+	case *dryRun:
+		t.footer = []string{"dry run, nothing was reconciled"}
+		return s.print(preview, t)
+	case preview.Difference != 0:
+		t.footer = []string{"reconciled as " + reconciliationUUID + " with an adjustment of " + client.FormatAmount(preview.Difference)}
+	default:
+		t.footer = []string{"reconciled as " + reconciliationUUID}
+	}
+	return s.print(reconcileResult{UUID: reconciliationUUID, ReconciliationPreview: *preview}, t)
+}
+
+// reconcileResult is printed by account reconcile.
+type reconcileResult struct {
+	UUID string `json:"uuid"`
+	client.ReconciliationPreview
+}
+
+func runAccountReconciliations(ctx context.Context, args []string) error {
+	fs := newFlagSet("account reconciliations", accountReconciliationsUsage)
+	s := registerSessionFlags(fs)
+	s.registerFormatFlag(fs)
+	account := fs.String("account", "", "account UUID")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if *account == "" {
+		return errors.New("missing -account")
+	}
+
+	var reconciliations []client.Reconciliation
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		reconciliations, err = c.GetReconciliations(ctx, *account)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	t := table{header: []string{"uuid", "date", "statement", "cleared", "adjustment", "transactions"}}
+	for _, r := range reconciliations {
+		t.rows = append(
+			t.rows, []string{
+				r.UUID, r.StatementDate.Format(time.DateOnly), client.FormatAmount(r.StatementBalance),
+				client.FormatAmount(r.ClearedBalance), deref(r.AdjustmentUUID), strconv.Itoa(r.Transactions),
+			},
+		)
+	}
+	return s.print(reconciliations, t)
+}
+
+func accountTable(accounts []client.Account) table {
+	t := table{header: []string{"uuid", "name", "type", "description"}}
+	for _, a := range accounts {
+		t.rows = append(t.rows, []string{a.UUID, a.Name, string(a.Type), deref(a.Description)})
+	}
+	return t
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader is the request header carrying a static API key.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys authenticates requests with static API keys, each mapped to a user.
+// Keys are kept as SHA-256 digests and compared in constant time.
+type APIKeys struct {
+	keys []apiKey
+}
+
+type apiKey struct {
+	digest [sha256.Size]byte
+	userID string
+}
+
+// NewAPIKeys returns an authenticator for the given key to user ID mapping.
+func NewAPIKeys(keys map[string]string) (*APIKeys, error) {
+	a := &APIKeys{}
+	for key, userID := range keys {
+		if err := a.add(key, userID); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// LoadAPIKeys reads one "<key> <user id>" pair per line. Blank lines and
+// lines starting with # are ignored.
+func LoadAPIKeys(r io.Reader) (*APIKeys, error) {
+	a := &APIKeys{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("api keys: line %d: want \"<key> <user id>\"", line)
+		}
+		if err := a.add(fields[0], fields[1]); err != nil {
+			return nil, fmt.Errorf("api keys: line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("api keys: %w", err)
+	}
+
+	return a, nil
+}
+
+func (a *APIKeys) add(key, userID string) error {
+	if key == "" || strings.TrimSpace(userID) == "" {
+		return errors.New("key and user id must not be empty")
+	}
+	a.keys = append(a.keys, apiKey{digest: sha256.Sum256([]byte(key)), userID: strings.TrimSpace(userID)})
+	return nil
+}
+
+// Len returns the number of configured keys.
+func (a *APIKeys) Len() int {
+	return len(a.keys)
+}
+
+// Authenticate implements Authenticator.
+func (a *APIKeys) Authenticate(r *http.Request) (string, error) {
+	key := strings.TrimSpace(r.Header.Get(APIKeyHeader))
+	if key == "" {
+		return "", ErrNoCredentials
+	}
+
+	digest := sha256.Sum256([]byte(key))
+
+	// compare against every key so timing does not reveal which one matched
+	userID := ""
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare(digest[:], k.digest[:]) == 1 {
+			userID = k.userID
+		}
+	}
+
+	if userID == "" {
+		return "", invalid("unknown api key")
+	}
+	return userID, nil
+}
@@ -0,0 +1,67 @@
+// Package auth resolves the pgbudget user of an HTTP request from a JWT or a
+// static API key.
+//
+// The resolved user ID is what the server stores in app.current_user_id for
+// the duration of the request transaction, so an Authenticator must never
+// return an empty ID without an error.
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNoCredentials is returned when a request carries no credentials
+	// that the authenticator understands.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned when credentials are present but
+	// cannot be verified.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator resolves the user ID of a request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (userID string, err error)
+}
+
+// Chain tries each authenticator in order and returns the first identity
+// found. Authenticators reporting ErrNoCredentials are skipped; any other
+// error stops the chain, so a bad token is never masked by a later method.
+type Chain []Authenticator
+
+// Authenticate implements Authenticator.
+func (c Chain) Authenticate(r *http.Request) (string, error) {
+	for _, a := range c {
+		userID, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return userID, err
+	}
+	return "", ErrNoCredentials
+}
+
+// Header trusts the user ID sent in a request header. Use it only behind a
+// proxy that authenticates users and overwrites the header.
+type Header string
+
+// Authenticate implements Authenticator.
+func (h Header) Authenticate(r *http.Request) (string, error) {
+	userID := strings.TrimSpace(r.Header.Get(string(h)))
+	if userID == "" {
+		return "", ErrNoCredentials
+	}
+	return userID, nil
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
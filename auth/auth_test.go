@@ -0,0 +1,226 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/pgbudget/auth"
+	is_ "github.com/matryer/is"
+)
+
+// sign builds a compact JWT; key is a []byte for HS256 or an *rsa.PrivateKey
+// for RS256.
+func sign(t *testing.T, alg string, key any, claims map[string]any) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(input))
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWT(t *testing.T) {
+	is := is_.New(t)
+
+	secret := []byte("test-secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	is.NoErr(err)
+
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Hour).Unix()
+
+	t.Run(
+		"HS256", func(t *testing.T) {
+			is := is_.New(t)
+
+			jwt, err := auth.NewJWT(auth.JWTConfig{Secret: secret})
+			is.NoErr(err)
+
+			userID, err := jwt.Verify(sign(t, "HS256", secret, map[string]any{"user_data": "alice", "exp": future}))
+			is.NoErr(err)
+			is.Equal(userID, "alice")
+
+			_, err = jwt.Verify(sign(t, "HS256", []byte("other"), map[string]any{"user_data": "alice", "exp": future}))
+			is.True(errors.Is(err, auth.ErrInvalidCredentials)) // wrong secret
+
+			_, err = jwt.Verify(sign(t, "HS256", secret, map[string]any{"user_data": "alice", "exp": past}))
+			is.True(errors.Is(err, auth.ErrInvalidCredentials)) // expired
+
+			_, err = jwt.Verify(sign(t, "HS256", secret, map[string]any{"sub": "alice", "exp": future}))
+			is.True(errors.Is(err, auth.ErrInvalidCredentials)) // missing user claim
+		},
+	)
+
+	t.Run(
+		"RS256", func(t *testing.T) {
+			is := is_.New(t)
+
+			der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+			is.NoErr(err)
+			publicKey, err := auth.ParseRSAPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+			is.NoErr(err)
+
+			jwt, err := auth.NewJWT(auth.JWTConfig{PublicKey: publicKey, Claim: "sub"})
+			is.NoErr(err)
+
+			userID, err := jwt.Verify(sign(t, "RS256", rsaKey, map[string]any{"sub": "bob", "exp": future}))
+			is.NoErr(err)
+			is.Equal(userID, "bob")
+
+			// an HS256 token must not be accepted when only an RSA key is configured
+			_, err = jwt.Verify(sign(t, "HS256", der, map[string]any{"sub": "bob", "exp": future}))
+			is.True(errors.Is(err, auth.ErrInvalidCredentials))
+		},
+	)
+
+	t.Run(
+		"RejectsNone", func(t *testing.T) {
+			is := is_.New(t)
+
+			jwt, err := auth.NewJWT(auth.JWTConfig{Secret: secret})
+			is.NoErr(err)
+
+			token := sign(t, "HS256", secret, map[string]any{"user_data": "alice", "exp": future})
+			parts := strings.Split(token, ".")
+			header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+
+			_, err = jwt.Verify(header + "." + parts[1] + ".")
+			is.True(errors.Is(err, auth.ErrInvalidCredentials))
+		},
+	)
+
+	t.Run(
+		"IssuerAndAudience", func(t *testing.T) {
+			is := is_.New(t)
+
+			jwt, err := auth.NewJWT(auth.JWTConfig{Secret: secret, Issuer: "pgbudget", Audience: "api"})
+			is.NoErr(err)
+
+			_, err = jwt.Verify(sign(t, "HS256", secret, map[string]any{"user_data": "a", "exp": future, "iss": "pgbudget", "aud": []string{"web", "api"}}))
+			is.NoErr(err)
+
+			_, err = jwt.Verify(sign(t, "HS256", secret, map[string]any{"user_data": "a", "exp": future, "iss": "other", "aud": "api"}))
+			is.True(errors.Is(err, auth.ErrInvalidCredentials))
+
+			_, err = jwt.Verify(sign(t, "HS256", secret, map[string]any{"user_data": "a", "exp": future, "iss": "pgbudget", "aud": "web"}))
+			is.True(errors.Is(err, auth.ErrInvalidCredentials))
+		},
+	)
+
+	t.Run(
+		"MissingExpiry", func(t *testing.T) {
+			is := is_.New(t)
+
+			token := sign(t, "HS256", secret, map[string]any{"user_data": "alice"})
+
+			jwt, err := auth.NewJWT(auth.JWTConfig{Secret: secret})
+			is.NoErr(err)
+			_, err = jwt.Verify(token)
+			is.True(errors.Is(err, auth.ErrInvalidCredentials)) // exp is required by default
+
+			jwt, err = auth.NewJWT(auth.JWTConfig{Secret: secret, AllowMissingExpiry: true})
+			is.NoErr(err)
+			userID, err := jwt.Verify(token)
+			is.NoErr(err)
+			is.Equal(userID, "alice")
+		},
+	)
+
+	t.Run(
+		"Request", func(t *testing.T) {
+			is := is_.New(t)
+
+			jwt, err := auth.NewJWT(auth.JWTConfig{Secret: secret})
+			is.NoErr(err)
+
+			r := httptest.NewRequest("GET", "/ledgers", nil)
+			_, err = jwt.Authenticate(r)
+			is.True(errors.Is(err, auth.ErrNoCredentials))
+
+			r.Header.Set("Authorization", "Bearer "+sign(t, "HS256", secret, map[string]any{"user_data": "alice", "exp": future}))
+			userID, err := jwt.Authenticate(r)
+			is.NoErr(err)
+			is.Equal(userID, "alice")
+		},
+	)
+
+	_, err = auth.NewJWT(auth.JWTConfig{})
+	is.True(err != nil) // a key is required
+}
+
+func TestAPIKeys(t *testing.T) {
+	is := is_.New(t)
+
+	keys, err := auth.LoadAPIKeys(strings.NewReader("# comment\n\nkey-alice alice\nkey-bob   bob\n"))
+	is.NoErr(err)
+	is.Equal(keys.Len(), 2)
+
+	r := httptest.NewRequest("GET", "/ledgers", nil)
+	_, err = keys.Authenticate(r)
+	is.True(errors.Is(err, auth.ErrNoCredentials))
+
+	r.Header.Set(auth.APIKeyHeader, "key-bob")
+	userID, err := keys.Authenticate(r)
+	is.NoErr(err)
+	is.Equal(userID, "bob")
+
+	r.Header.Set(auth.APIKeyHeader, "key-eve")
+	_, err = keys.Authenticate(r)
+	is.True(errors.Is(err, auth.ErrInvalidCredentials))
+
+	_, err = auth.LoadAPIKeys(strings.NewReader("only-a-key\n"))
+	is.True(err != nil) // malformed line
+}
+
+func TestChain(t *testing.T) {
+	is := is_.New(t)
+
+	secret := []byte("test-secret")
+	jwt, err := auth.NewJWT(auth.JWTConfig{Secret: secret})
+	is.NoErr(err)
+	keys, err := auth.NewAPIKeys(map[string]string{"key-alice": "alice"})
+	is.NoErr(err)
+
+	chain := auth.Chain{jwt, keys}
+
+	r := httptest.NewRequest("GET", "/ledgers", nil)
+	_, err = chain.Authenticate(r)
+	is.True(errors.Is(err, auth.ErrNoCredentials)) // nothing to authenticate with
+
+	r.Header.Set(auth.APIKeyHeader, "key-alice")
+	userID, err := chain.Authenticate(r)
+	is.NoErr(err)
+	is.Equal(userID, "alice") // falls through to the api key
+
+	r.Header.Set("Authorization", "Bearer not.a.token")
+	_, err = chain.Authenticate(r)
+	is.True(errors.Is(err, auth.ErrInvalidCredentials)) // a bad token is not masked by the key
+}
@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultClaim is the JWT claim holding the user ID when none is configured.
+// It matches the user_data column used by the row level security policies.
+const DefaultClaim = "user_data"
+
+// JWTConfig configures JWT verification. At least one of Secret (HS256) and
+// PublicKey (RS256) must be set; a token is only accepted with the algorithm
+// whose key is configured.
+type JWTConfig struct {
+	// Secret is the HMAC key for HS256 tokens.
+	Secret []byte
+	// PublicKey verifies RS256 tokens.
+	PublicKey *rsa.PublicKey
+	// Claim names the claim holding the user ID. Defaults to DefaultClaim.
+	Claim string
+	// Issuer, when set, must match the iss claim.
+	Issuer string
+	// Audience, when set, must be one of the aud claim values.
+	Audience string
+	// Leeway tolerates clock skew when checking exp and nbf.
+	Leeway time.Duration
+	// AllowMissingExpiry accepts tokens without an exp claim. By default
+	// such tokens are rejected, since they would never expire.
+	AllowMissingExpiry bool
+}
+
+// JWT authenticates requests carrying "Authorization: Bearer <token>".
+type JWT struct {
+	cfg JWTConfig
+	now func() time.Time
+}
+
+// NewJWT validates cfg and returns a JWT authenticator.
+func NewJWT(cfg JWTConfig) (*JWT, error) {
+	if len(cfg.Secret) == 0 && cfg.PublicKey == nil {
+		return nil, errors.New("jwt: a secret or a public key is required")
+	}
+	if cfg.Claim == "" {
+		cfg.Claim = DefaultClaim
+	}
+
+	return &JWT{cfg: cfg, now: time.Now}, nil
+}
+
+// Authenticate implements Authenticator.
+func (j *JWT) Authenticate(r *http.Request) (string, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		return "", ErrNoCredentials
+	}
+	return j.Verify(token)
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+// Verify checks the signature and registered claims of token and returns
+// the user ID held in the configured claim.
+func (j *JWT) Verify(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", invalid("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", invalid("malformed header")
+	}
+
+	signingInput := parts[0] + "." + parts[1]
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", invalid("malformed signature")
+	}
+
+	if err := j.verifySignature(header.Alg, signingInput, signature); err != nil {
+		return "", err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", invalid("malformed claims")
+	}
+
+	if err := j.verifyClaims(claims); err != nil {
+		return "", err
+	}
+
+	userID, _ := claims[j.cfg.Claim].(string)
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return "", invalid(fmt.Sprintf("missing %s claim", j.cfg.Claim))
+	}
+
+	return userID, nil
+}
+
+func (j *JWT) verifySignature(alg, signingInput string, signature []byte) error {
+	switch {
+	case alg == "HS256" && len(j.cfg.Secret) > 0:
+		mac := hmac.New(sha256.New, j.cfg.Secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return invalid("bad signature")
+		}
+	case alg == "RS256" && j.cfg.PublicKey != nil:
+		digest := sha256.Sum256([]byte(signingInput))
+		if err := rsa.VerifyPKCS1v15(j.cfg.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			return invalid("bad signature")
+		}
+	default:
+		return invalid(fmt.Sprintf("unsupported algorithm %q", alg))
+	}
+	return nil
+}
+
+func (j *JWT) verifyClaims(claims map[string]any) error {
+	now := j.now()
+
+	if exp, ok, err := numericDate(claims, "exp"); err != nil {
+		return err
+	} else if !ok && !j.cfg.AllowMissingExpiry {
+		return invalid("missing exp claim")
+	} else if ok && !now.Before(exp.Add(j.cfg.Leeway)) {
+		return invalid("token expired")
+	}
+
+	if nbf, ok, err := numericDate(claims, "nbf"); err != nil {
+		return err
+	} else if ok && now.Add(j.cfg.Leeway).Before(nbf) {
+		return invalid("token not valid yet")
+	}
+
+	if j.cfg.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != j.cfg.Issuer {
+			return invalid("unexpected issuer")
+		}
+	}
+
+	if j.cfg.Audience != "" && !hasAudience(claims["aud"], j.cfg.Audience) {
+		return invalid("unexpected audience")
+	}
+
+	return nil
+}
+
+// numericDate reads a registered time claim expressed in seconds since the
+// epoch.
+func numericDate(claims map[string]any, name string) (time.Time, bool, error) {
+	value, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	seconds, ok := value.(float64)
+	if !ok {
+		return time.Time{}, false, invalid(fmt.Sprintf("malformed %s claim", name))
+	}
+	return time.Unix(int64(seconds), 0), true, nil
+}
+
+// hasAudience reports whether aud, a string or a list of strings, contains want.
+func hasAudience(aud any, want string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == want
+	case []any:
+		for _, a := range v {
+			if s, _ := a.(string); s == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func invalid(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidCredentials, reason)
+}
+
+// ParseRSAPublicKey decodes a PEM encoded RSA public key, either PKIX
+// ("PUBLIC KEY") or PKCS #1 ("RSA PUBLIC KEY").
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwt: no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: failed to parse public key: %w", err)
+		}
+		return key, nil
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: failed to parse public key: %w", err)
+		}
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("jwt: public key is not an RSA key")
+		}
+		return rsaKey, nil
+	default:
+		return nil, fmt.Errorf("jwt: unexpected PEM block %q", block.Type)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+	"github.com/j0lvera/pgbudget/tui"
+	"github.com/jackc/pgx/v5"
+)
+
+const assignUsage = `Usage: pgbudget assign -category <name|uuid> -amount <amount> [flags]
+
+Assigns money from Income to a budget category.
+
+Flags:
+`
+
+const moveUsage = `Usage: pgbudget move -from <name|uuid> -to <name|uuid> -amount <amount> [flags]
+
+Moves money from one budget category to another. The move is refused when
+the source holds less than the amount on the date of the move, unless
+-force is given.
+
+Flags:
+`
+
+const budgetUsage = `Usage: pgbudget budget [flags]
+
+Opens the budget of a ledger in a full-screen terminal view. Arrow keys (or
+h/j/k/l) move between categories and months; "a" assigns money to the
+selected category, "n" enters a transaction, enter lists the transactions
+of a category and "c" corrects the selected one. "q" quits.
+
+Flags:
+`
+
+const statusUsage = `Usage: pgbudget status [flags]
+
+Shows budgeted, activity and balance per category for a month, followed by
+the ledger totals.
+
+Flags:
+`
+
+func runAssign(ctx context.Context, args []string) error {
+	fs := newFlagSet("assign", assignUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	category := fs.String("category", "", "category name or UUID")
+	amount := fs.String("amount", "", "positive amount, e.g. 250.00")
+	date := fs.String("date", "", "date as YYYY-MM-DD (default today)")
+	description := fs.String("description", "", "description (default \"Budget assignment\")")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	switch {
+	case *category == "":
+		return errors.New("missing -category")
+	case *amount == "":
+		return errors.New("missing -amount")
+	}
+	cents, err := client.ParseAmount(*amount)
+	if err != nil {
+		return err
+	}
+	day, err := parseDate(*date)
+	if err != nil {
+		return err
+	}
+	if *description == "" {
+		*description = "Budget assignment"
+	}
+
+	var assignment *client.Transaction
+	err = s.with(ctx, func(c *client.Client) error {
+		categoryUUID, err := resolveCategory(ctx, c, s.ledger, *category)
+		if err != nil {
+			return err
+		}
+		assignment, err = c.AssignToCategory(
+			ctx, client.AssignToCategoryParams{
+				LedgerUUID:   s.ledger,
+				Date:         day,
+				Description:  *description,
+				Amount:       cents,
+				CategoryUUID: categoryUUID,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(
+		assignment, table{
+			header: []string{"uuid", "date", "description", "amount"},
+			rows: [][]string{{
+				assignment.UUID, assignment.Date.Format(time.DateOnly),
+				assignment.Description, client.FormatAmount(assignment.Amount),
+			}},
+		},
+	)
+}
+
+func runMove(ctx context.Context, args []string) error {
+	fs := newFlagSet("move", moveUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	from := fs.String("from", "", "source category name or UUID")
+	to := fs.String("to", "", "destination category name or UUID")
+	amount := fs.String("amount", "", "positive amount, e.g. 50.00")
+	date := fs.String("date", "", "date as YYYY-MM-DD (default today)")
+	memo := fs.String("memo", "", "description (default \"Move from <from> to <to>\")")
+	force := fs.Bool("force", false, "allow overdrawing the source category")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	switch {
+	case *from == "":
+		return errors.New("missing -from")
+	case *to == "":
+		return errors.New("missing -to")
+	case *amount == "":
+		return errors.New("missing -amount")
+	}
+	cents, err := client.ParseAmount(*amount)
+	if err != nil {
+		return err
+	}
+	day, err := parseDate(*date)
+	if err != nil {
+		return err
+	}
+
+	var move *client.Transaction
+	err = s.with(ctx, func(c *client.Client) error {
+		fromUUID, err := resolveCategory(ctx, c, s.ledger, *from)
+		if err != nil {
+			return err
+		}
+		toUUID, err := resolveCategory(ctx, c, s.ledger, *to)
+		if err != nil {
+			return err
+		}
+		move, err = c.MoveBetweenCategories(
+			ctx, client.MoveBetweenCategoriesParams{
+				LedgerUUID:       s.ledger,
+				FromCategoryUUID: fromUUID,
+				ToCategoryUUID:   toUUID,
+				Amount:           cents,
+				Date:             day,
+				Memo:             *memo,
+				Force:            *force,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(
+		move, table{
+			header: []string{"uuid", "date", "description", "amount"},
+			rows: [][]string{{
+				move.UUID, move.Date.Format(time.DateOnly), move.Description, client.FormatAmount(move.Amount),
+			}},
+		},
+	)
+}
+
+// statusResult is the JSON output of pgbudget status.
+type statusResult struct {
+	Period     string                `json:"period"`
+	Totals     *client.BudgetTotals  `json:"totals"`
+	Categories []client.BudgetStatus `json:"categories"`
+}
+
+func runStatus(ctx context.Context, args []string) error {
+	fs := newFlagSet("status", statusUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	period := fs.String("period", time.Now().Format("200601"), `month as YYYYMM, or "all" for all-time figures`)
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+
+	result := statusResult{Period: *period}
+	if *period == "all" {
+		result.Period = ""
+	} else if _, err := time.Parse("200601", *period); err != nil {
+		return fmt.Errorf("invalid -period %q: use YYYYMM", *period)
+	}
+
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		result.Categories, err = c.GetBudgetStatus(ctx, s.ledger, result.Period)
+		if err != nil {
+			return err
+		}
+		result.Totals, err = c.GetBudgetTotals(ctx, s.ledger, result.Period)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	t := table{
+		header: []string{"category", "budgeted", "activity", "balance"},
+		footer: []string{
+			"Income:          " + client.FormatAmount(result.Totals.Income),
+			"Budgeted:        " + client.FormatAmount(result.Totals.Budgeted),
+			"Left to budget:  " + client.FormatAmount(result.Totals.LeftToBudget),
+		},
+	}
+	for _, row := range result.Categories {
+		t.rows = append(
+			t.rows, []string{
+				row.CategoryName, client.FormatAmount(row.Budgeted), client.FormatAmount(row.Activity), client.FormatAmount(row.Balance),
+			},
+		)
+	}
+	return s.print(result, t)
+}
+
+func runBudget(ctx context.Context, args []string) error {
+	fs := newFlagSet("budget", budgetUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	period := fs.String("period", time.Now().Format("200601"), "month to show first, as YYYYMM")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	month, err := time.Parse("200601", *period)
+	if err != nil {
+		return fmt.Errorf("invalid -period %q: use YYYYMM", *period)
+	}
+
+	conn, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	return tui.Run(
+		ctx, tui.Options{
+			LedgerUUID: s.ledger,
+			Period:     month,
+			Do: func(ctx context.Context, fn func(c *client.Client) error) error {
+				return client.WithUser(ctx, conn, s.user, func(tx pgx.Tx) error {
+					return fn(client.New(tx))
+				})
+			},
+		},
+	)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+
+	"github.com/j0lvera/pgbudget/client"
+)
+
+const categoryAddUsage = `Usage: pgbudget category add [flags] <name>...
+
+Adds one or more budget categories to a ledger, all or none.
+
+Flags:
+`
+
+const categoryListUsage = `Usage: pgbudget category list [flags]
+
+Lists the budget categories of a ledger, including Income, Off-budget and
+Unassigned.
+
+Flags:
+`
+
+func runCategory(ctx context.Context, args []string) error {
+	return runSubcommand(
+		ctx, "category", []subcommand{
+			{"add", "add categories", runCategoryAdd},
+			{"list", "list categories", runCategoryList},
+		}, args,
+	)
+}
+
+func runCategoryAdd(ctx context.Context, args []string) error {
+	fs := newFlagSet("category add", categoryAddUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if err := s.resolve(); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	for _, name := range fs.Args() {
+		if name == "" {
+			return errors.New("category names must not be empty")
+		}
+	}
+
+	var categories []client.Account
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		categories, err = c.AddCategories(ctx, s.ledger, fs.Args())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(categories, categoryTable(categories))
+}
+
+func runCategoryList(ctx context.Context, args []string) error {
+	fs := newFlagSet("category list", categoryListUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+
+	var categories []client.Account
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		categories, err = c.ListCategories(ctx, s.ledger)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(categories, categoryTable(categories))
+}
+
+// resolveCategory returns the UUID of the category named nameOrUUID in the
+// ledger; a value that names no category is taken as a UUID. Empty stays
+// empty, which the database reads as Unassigned.
+func resolveCategory(ctx context.Context, c *client.Client, ledgerUUID, nameOrUUID string) (string, error) {
+	if nameOrUUID == "" {
+		return "", nil
+	}
+
+	uuid, err := c.FindCategoryUUID(ctx, ledgerUUID, nameOrUUID)
+	if errors.Is(err, client.ErrCategoryNotFound) {
+		return nameOrUUID, nil
+	}
+	return uuid, err
+}
+
+func categoryTable(categories []client.Account) table {
+	t := table{header: []string{"uuid", "name", "description"}}
+	for _, a := range categories {
+		t.rows = append(t.rows, []string{a.UUID, a.Name, deref(a.Description)})
+	}
+	return t
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// accountColumns is the column list of the api.accounts view.
+const accountColumns = "uuid, name, type, description, metadata, user_data, ledger_uuid"
+
+// CreateAccountParams holds the fields accepted when creating an account.
+type CreateAccountParams struct {
+	LedgerUUID  string
+	Name        string
+	Type        AccountType
+	Description string
+	Metadata    json.RawMessage
+}
+
+// CreateAccount inserts an account through the api.accounts view.
+func (c *Client) CreateAccount(ctx context.Context, params CreateAccountParams) (*Account, error) {
+	rows, err := c.db.Query(
+		ctx,
+		`insert into api.accounts (ledger_uuid, name, type, description, metadata)
+		 values ($1, $2, $3, $4, $5) returning `+accountColumns,
+		params.LedgerUUID, params.Name, string(params.Type),
+		nullString(params.Description), params.Metadata,
+	)
+	if err != nil {
+		return nil, wrapErr("create account", err)
+	}
+
+	account, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Account])
+	if err != nil {
+		return nil, wrapErr("create account", err)
+	}
+
+	return &account, nil
+}
+
+// GetAccount returns the account with the given UUID.
+func (c *Client) GetAccount(ctx context.Context, accountUUID string) (*Account, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select "+accountColumns+" from api.accounts where uuid = $1",
+		accountUUID,
+	)
+	if err != nil {
+		return nil, wrapErr("get account", err)
+	}
+
+	account, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Account])
+	if err != nil {
+		return nil, wrapNotFound("get account", err, ErrAccountNotFound)
+	}
+
+	return &account, nil
+}
+
+// ListAccounts returns every account in a ledger, categories included,
+// ordered by type and name.
+func (c *Client) ListAccounts(ctx context.Context, ledgerUUID string) ([]Account, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select "+accountColumns+" from api.accounts where ledger_uuid = $1 order by type, name",
+		ledgerUUID,
+	)
+	if err != nil {
+		return nil, wrapErr("list accounts", err)
+	}
+
+	accounts, err := pgx.CollectRows(rows, pgx.RowToStructByName[Account])
+	if err != nil {
+		return nil, wrapErr("list accounts", err)
+	}
+
+	return accounts, nil
+}
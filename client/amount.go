@@ -0,0 +1,57 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FormatAmount renders cents as a decimal number, e.g. -1234 as "-12.34".
+func FormatAmount(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// ParseAmount reads a positive decimal amount such as "12", "12.3" or
+// "1234.56" as cents. The direction of money is given by a transaction
+// type, so signs are refused.
+func ParseAmount(value string) (int64, error) {
+	cents, ok := parseCents(value)
+	if !ok || cents == 0 {
+		return 0, fmt.Errorf("invalid amount %q: use a positive number with up to two decimals", value)
+	}
+	return cents, nil
+}
+
+// ParseBalance reads a balance such as "-12.34" or "0" as cents. Unlike an
+// amount, a balance may be zero or negative, e.g. an overdrawn account.
+func ParseBalance(value string) (int64, error) {
+	unsigned, negative := strings.CutPrefix(strings.TrimSpace(value), "-")
+	cents, ok := parseCents(unsigned)
+	if !ok {
+		return 0, fmt.Errorf("invalid balance %q: use a number with up to two decimals", value)
+	}
+	if negative {
+		cents = -cents
+	}
+	return cents, nil
+}
+
+// parseCents reads an unsigned decimal with up to two decimals as cents.
+func parseCents(value string) (int64, bool) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(value), ".")
+	if whole == "" && frac == "" || len(frac) > 2 || strings.ContainsAny(whole+frac, "+-") {
+		return 0, false
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+
+	cents, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return cents, true
+}
@@ -0,0 +1,38 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/j0lvera/pgbudget/client"
+	is_ "github.com/matryer/is"
+)
+
+func TestAmounts(t *testing.T) {
+	is := is_.New(t)
+
+	is.Equal(client.FormatAmount(0), "0.00")
+	is.Equal(client.FormatAmount(5), "0.05")
+	is.Equal(client.FormatAmount(-123456), "-1234.56")
+
+	for value, want := range map[string]int64{"12": 1200, "12.3": 1230, "0.05": 5, ".5": 50, " 1234.56 ": 123456} {
+		got, err := client.ParseAmount(value)
+		is.NoErr(err)
+		is.Equal(got, want)
+	}
+
+	for _, value := range []string{"", "0", "-5", "+5", "1.234", "1,50", "abc"} {
+		_, err := client.ParseAmount(value)
+		is.True(err != nil) // value must be refused
+	}
+
+	for value, want := range map[string]int64{"0": 0, "-12.34": -1234, "12.3": 1230} {
+		got, err := client.ParseBalance(value)
+		is.NoErr(err)
+		is.Equal(got, want)
+	}
+
+	for _, value := range []string{"", "-", "--5", "+5", "1.234"} {
+		_, err := client.ParseBalance(value)
+		is.True(err != nil) // value must be refused
+	}
+}
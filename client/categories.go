@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// AddCategory creates a budget category through api.add_category.
+func (c *Client) AddCategory(ctx context.Context, ledgerUUID, name string) (*Account, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select "+accountColumns+" from api.add_category($1, $2)",
+		ledgerUUID, name,
+	)
+	if err != nil {
+		return nil, wrapErr("add category", err)
+	}
+
+	category, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Account])
+	if err != nil {
+		return nil, wrapErr("add category", err)
+	}
+
+	return &category, nil
+}
+
+// AddCategories creates several categories at once through api.add_categories.
+// Blank names are skipped by the database; a duplicate name fails the whole batch.
+func (c *Client) AddCategories(ctx context.Context, ledgerUUID string, names []string) ([]Account, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select "+accountColumns+" from api.add_categories($1, $2)",
+		ledgerUUID, names,
+	)
+	if err != nil {
+		return nil, wrapErr("add categories", err)
+	}
+
+	categories, err := pgx.CollectRows(rows, pgx.RowToStructByName[Account])
+	if err != nil {
+		return nil, wrapErr("add categories", err)
+	}
+
+	return categories, nil
+}
+
+// ListCategories returns the categories of a ledger, including the special
+// Income, Off-budget and Unassigned accounts, ordered by name.
+func (c *Client) ListCategories(ctx context.Context, ledgerUUID string) ([]Account, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select "+accountColumns+" from api.accounts where ledger_uuid = $1 and type = 'equity' order by name",
+		ledgerUUID,
+	)
+	if err != nil {
+		return nil, wrapErr("list categories", err)
+	}
+
+	categories, err := pgx.CollectRows(rows, pgx.RowToStructByName[Account])
+	if err != nil {
+		return nil, wrapErr("list categories", err)
+	}
+
+	return categories, nil
+}
+
+// FindCategory returns the category with the given name in a ledger.
+func (c *Client) FindCategory(ctx context.Context, ledgerUUID, name string) (*Account, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select "+accountColumns+" from api.accounts where ledger_uuid = $1 and type = 'equity' and name = $2",
+		ledgerUUID, name,
+	)
+	if err != nil {
+		return nil, wrapErr("find category", err)
+	}
+
+	category, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Account])
+	if err != nil {
+		return nil, wrapNotFound("find category "+strconv.Quote(name), err, ErrCategoryNotFound)
+	}
+
+	return &category, nil
+}
+
+// FindCategoryUUID returns the UUID of the category with the given name
+// through utils.find_category, without loading the whole account row.
+// It fails with ErrCategoryNotFound when the ledger has no such category.
+func (c *Client) FindCategoryUUID(ctx context.Context, ledgerUUID, name string) (string, error) {
+	var categoryUUID *string
+	err := c.db.QueryRow(ctx, "select utils.find_category($1, $2)", ledgerUUID, name).Scan(&categoryUUID)
+	if err != nil {
+		return "", wrapErr("find category "+strconv.Quote(name), err)
+	}
+	if categoryUUID == nil {
+		return "", &Error{Op: "find category " + strconv.Quote(name), Kind: ErrCategoryNotFound, Err: ErrCategoryNotFound}
+	}
+
+	return *categoryUUID, nil
+}
@@ -0,0 +1,48 @@
+// Package client provides a typed Go interface to the pgbudget api schema.
+//
+// Every method is a thin wrapper around an existing api view or function, so
+// the database remains the single source of truth for validation and
+// double-entry rules. The client does not set any user context on its own;
+// row level security is driven by whatever `app.current_user_id` (or
+// current_user) is active on the connection it is given.
+package client
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Querier is the subset of pgx used by the client.
+// *pgxpool.Pool, *pgx.Conn and pgx.Tx all satisfy it, which lets callers run
+// client methods inside their own transactions.
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Client exposes typed methods over the pgbudget api schema.
+type Client struct {
+	db Querier
+}
+
+// New creates a Client that issues all queries through db.
+func New(db Querier) *Client {
+	return &Client{db: db}
+}
+
+// WithQuerier returns a copy of the client bound to a different Querier,
+// typically a pgx.Tx obtained from the pool the client was created with.
+func (c *Client) WithQuerier(db Querier) *Client {
+	return &Client{db: db}
+}
+
+// nullString converts an empty string into a SQL NULL argument.
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
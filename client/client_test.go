@@ -0,0 +1,1098 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+	"github.com/j0lvera/pgbudget/testutils/pgcontainer"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	is_ "github.com/matryer/is"
+	"github.com/rs/zerolog"
+)
+
+var (
+	testDSN string
+	log     zerolog.Logger
+)
+
+func TestMain(m *testing.M) {
+	// Setup logging
+	log = zerolog.New(os.Stdout).With().Timestamp().Logger()
+
+	// Create a context with timeout for setup
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	// Configure and start the PostgreSQL container
+	cfg := pgcontainer.NewConfig()
+	cfg.WithLogger(&log).WithMigrationsPath("migrations") // Path relative to project root
+
+	pgContainer := pgcontainer.NewPgContainer(cfg)
+	output, err := pgContainer.Start(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to start PostgreSQL container")
+	}
+
+	testDSN = output.DSN()
+
+	os.Exit(m.Run())
+}
+
+// TestClient exercises the typed client against a migrated database,
+// following a ledger from creation to reporting.
+func TestClient(t *testing.T) {
+	is := is_.New(t)
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, testDSN)
+	is.NoErr(err) // should connect to database without error
+	t.Cleanup(pool.Close)
+
+	c := client.New(pool)
+
+	ledger, err := c.CreateLedger(ctx, client.CreateLedgerParams{Name: "Client Test Ledger"})
+	is.NoErr(err)              // should create ledger
+	is.True(ledger.UUID != "") // should return the generated uuid
+	is.Equal(ledger.Name, "Client Test Ledger")
+
+	var (
+		checking  *client.Account
+		groceries *client.Account
+		income    *client.Account
+		spendUUID string
+	)
+
+	t.Run(
+		"Ledgers", func(t *testing.T) {
+			is := is_.New(t)
+
+			got, err := c.GetLedger(ctx, ledger.UUID)
+			is.NoErr(err)
+			is.Equal(got.UUID, ledger.UUID)
+
+			ledgers, err := c.ListLedgers(ctx)
+			is.NoErr(err)
+			found := false
+			for _, l := range ledgers {
+				if l.UUID == ledger.UUID {
+					found = true
+				}
+			}
+			is.True(found) // created ledger should be listed
+		},
+	)
+
+	t.Run(
+		"AccountsAndCategories", func(t *testing.T) {
+			is := is_.New(t)
+
+			checking, err = c.CreateAccount(
+				ctx, client.CreateAccountParams{
+					LedgerUUID: ledger.UUID,
+					Name:       "Checking",
+					Type:       client.AccountTypeAsset,
+				},
+			)
+			is.NoErr(err)
+			is.Equal(checking.LedgerUUID, ledger.UUID)
+			is.Equal(checking.Type, client.AccountTypeAsset)
+
+			groceries, err = c.AddCategory(ctx, ledger.UUID, "Groceries")
+			is.NoErr(err)
+			is.Equal(groceries.Type, client.AccountTypeEquity)
+
+			batch, err := c.AddCategories(ctx, ledger.UUID, []string{"Rent", "Utilities"})
+			is.NoErr(err)
+			is.Equal(len(batch), 2)
+
+			income, err = c.FindCategory(ctx, ledger.UUID, client.IncomeCategory)
+			is.NoErr(err)
+
+			categories, err := c.ListCategories(ctx, ledger.UUID)
+			is.NoErr(err)
+			is.Equal(len(categories), 6) // 3 special accounts + 3 categories
+
+			accounts, err := c.ListAccounts(ctx, ledger.UUID)
+			is.NoErr(err)
+			is.Equal(len(accounts), 7) // categories + checking
+		},
+	)
+
+	if checking == nil || groceries == nil || income == nil {
+		t.Fatal("account setup failed")
+	}
+
+	t.Run(
+		"Transactions", func(t *testing.T) {
+			is := is_.New(t)
+			today := time.Now()
+
+			_, err := c.AddTransaction(
+				ctx, client.AddTransactionParams{
+					LedgerUUID:   ledger.UUID,
+					Date:         today,
+					Description:  "Paycheck",
+					Type:         client.Inflow,
+					Amount:       100000,
+					AccountUUID:  checking.UUID,
+					CategoryUUID: income.UUID,
+				},
+			)
+			is.NoErr(err)
+
+			assignment, err := c.AssignToCategory(
+				ctx, client.AssignToCategoryParams{
+					LedgerUUID:   ledger.UUID,
+					Date:         today,
+					Description:  "Budget groceries",
+					Amount:       30000,
+					CategoryUUID: groceries.UUID,
+				},
+			)
+			is.NoErr(err)
+			is.Equal(assignment.Amount, int64(30000))
+			is.Equal(*assignment.CategoryUUID, groceries.UUID)
+
+			spendUUID, err = c.AddTransaction(
+				ctx, client.AddTransactionParams{
+					LedgerUUID:   ledger.UUID,
+					Date:         today,
+					Description:  "Grocery run",
+					Type:         client.Outflow,
+					Amount:       7500,
+					AccountUUID:  checking.UUID,
+					CategoryUUID: groceries.UUID,
+				},
+			)
+			is.NoErr(err)
+			is.True(spendUUID != "")
+
+			balance, err := c.GetAccountBalance(ctx, checking.UUID)
+			is.NoErr(err)
+			is.Equal(balance, int64(92500)) // 1000.00 - 75.00
+
+			history, err := c.GetAccountTransactions(ctx, checking.UUID)
+			is.NoErr(err)
+			is.Equal(len(history), 2)
+			is.Equal(history[0].Description, "Grocery run") // newest first
+			is.Equal(history[0].RunningBalance, int64(92500))
+		},
+	)
+
+	t.Run(
+		"CorrectAndDelete", func(t *testing.T) {
+			is := is_.New(t)
+
+			correctionUUID, err := c.CorrectTransaction(
+				ctx, client.CorrectTransactionParams{
+					TransactionUUID: spendUUID,
+					Type:            client.Outflow,
+					AccountUUID:     checking.UUID,
+					CategoryUUID:    groceries.UUID,
+					Amount:          8000,
+					Description:     "Grocery run (corrected)",
+					Date:            time.Now(),
+					Reason:          "Receipt total was wrong",
+				},
+			)
+			is.NoErr(err)
+			is.True(correctionUUID != spendUUID)
+
+			balance, err := c.GetAccountBalance(ctx, checking.UUID)
+			is.NoErr(err)
+			is.Equal(balance, int64(92000))
+
+			reversalUUID, err := c.DeleteTransaction(ctx, correctionUUID, "")
+			is.NoErr(err)
+			is.True(reversalUUID != "")
+
+			balance, err = c.GetAccountBalance(ctx, checking.UUID)
+			is.NoErr(err)
+			is.Equal(balance, int64(100000))
+		},
+	)
+
+	t.Run(
+		"Reports", func(t *testing.T) {
+			is := is_.New(t)
+
+			status, err := c.GetBudgetStatus(ctx, ledger.UUID, "")
+			is.NoErr(err)
+			is.Equal(len(status), 3) // special accounts are excluded
+			for _, s := range status {
+				if s.CategoryUUID == groceries.UUID {
+					is.Equal(s.Budgeted, int64(30000))
+					is.Equal(s.Balance, int64(30000)) // spending was deleted
+				}
+			}
+
+			period := time.Now().Format("200601")
+			monthly, err := c.GetBudgetStatus(ctx, ledger.UUID, period)
+			is.NoErr(err)
+			is.Equal(len(monthly), 3)
+
+			totals, err := c.GetBudgetTotals(ctx, ledger.UUID, period)
+			is.NoErr(err)
+			is.Equal(totals.Income, int64(100000))
+			is.Equal(totals.LeftToBudget, int64(70000))
+
+			balances, err := c.GetLedgerBalances(ctx, ledger.UUID)
+			is.NoErr(err)
+			is.Equal(len(balances), 7)
+
+			entries, err := c.GetAccountBalanceHistory(ctx, checking.UUID, 10)
+			is.NoErr(err)
+			is.True(len(entries) > 0)
+		},
+	)
+
+	t.Run(
+		"SplitTransactions", func(t *testing.T) {
+			is := is_.New(t)
+
+			utilities, err := c.FindCategory(ctx, ledger.UUID, "Utilities")
+			is.NoErr(err)
+
+			splitUUID, err := c.AddSplitTransaction(
+				ctx, client.AddSplitTransactionParams{
+					LedgerUUID:  ledger.UUID,
+					Date:        time.Now(),
+					Description: "Hardware store",
+					Type:        client.Outflow,
+					AccountUUID: checking.UUID,
+					Splits: []client.Split{
+						{CategoryUUID: groceries.UUID, Amount: 8000},
+						{CategoryUUID: utilities.UUID, Amount: 4000, Memo: "Light bulbs"},
+					},
+				},
+			)
+			is.NoErr(err)
+
+			history, err := c.GetAccountTransactions(ctx, checking.UUID)
+			is.NoErr(err)
+			is.Equal(history[0].UUID, splitUUID) // the split is one row
+			is.True(history[0].Split)
+			is.Equal(history[0].Amount, int64(12000))
+			is.Equal(history[0].CategoryUUID, "") // spread over two categories
+
+			split, err := c.GetSplitTransaction(ctx, splitUUID)
+			is.NoErr(err)
+			is.Equal(split.Type, client.Outflow)
+			is.Equal(split.AccountUUID, checking.UUID)
+			is.Equal(split.Amount, int64(12000))
+			is.Equal(len(split.Splits), 2)
+			is.Equal(split.Splits[0].Category, "Groceries")
+			is.Equal(split.Splits[1].Memo, "Light bulbs")
+
+			_, err = c.CorrectTransaction(
+				ctx, client.CorrectTransactionParams{
+					TransactionUUID: split.Splits[0].UUID,
+					Type:            client.Outflow,
+					AccountUUID:     checking.UUID,
+					Amount:          100,
+					Date:            time.Now(),
+				},
+			)
+			is.True(errors.Is(err, client.ErrInvalidInput)) // legs change only with their split
+
+			correctionUUID, err := c.CorrectSplitTransaction(
+				ctx, client.CorrectSplitTransactionParams{
+					SplitUUID:   splitUUID,
+					Type:        client.Outflow,
+					AccountUUID: checking.UUID,
+					Date:        time.Now(),
+					Description: "Hardware store",
+					Splits: []client.Split{
+						{CategoryUUID: groceries.UUID, Amount: 9000},
+						{CategoryUUID: utilities.UUID, Amount: 1000},
+					},
+					Reason: "Receipt misread",
+				},
+			)
+			is.NoErr(err)
+
+			balance, err := c.GetAccountBalance(ctx, checking.UUID)
+			is.NoErr(err)
+			is.Equal(balance, int64(90000))
+
+			_, err = c.DeleteTransaction(ctx, correctionUUID, "")
+			is.NoErr(err) // deletes every split
+
+			balance, err = c.GetAccountBalance(ctx, checking.UUID)
+			is.NoErr(err)
+			is.Equal(balance, int64(100000))
+
+			_, err = c.GetSplitTransaction(ctx, "missing")
+			is.True(errors.Is(err, client.ErrTransactionNotFound))
+		},
+	)
+
+	t.Run(
+		"Schedules", func(t *testing.T) {
+			is := is_.New(t)
+
+			now := time.Now()
+			today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+			lastMonth := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.UTC)
+
+			scheduleUUID, err := c.CreateSchedule(
+				ctx, client.CreateScheduleParams{
+					LedgerUUID:   ledger.UUID,
+					Name:         "Rent",
+					Type:         client.Outflow,
+					Amount:       50000,
+					AccountUUID:  checking.UUID,
+					CategoryUUID: groceries.UUID,
+					Frequency:    client.FrequencyMonthly,
+					StartDate:    lastMonth,
+				},
+			)
+			is.NoErr(err)
+
+			schedules, err := c.GetSchedules(ctx, ledger.UUID)
+			is.NoErr(err)
+			is.Equal(len(schedules), 1)
+			is.Equal(schedules[0].Every, 1)
+			is.Equal(*schedules[0].DayOfMonth, 1) // the day of the start date
+			is.True(schedules[0].NextDate.Equal(lastMonth))
+
+			recorded, err := c.RunSchedules(ctx, "", today)
+			is.NoErr(err)
+			is.Equal(len(recorded), 2) // the 1st of last month and of this month
+			is.Equal(recorded[0].ScheduleUUID, scheduleUUID)
+
+			recorded, err = c.RunSchedules(ctx, ledger.UUID, today)
+			is.NoErr(err)
+			is.Equal(len(recorded), 0) // nothing new is due
+
+			upcoming, err := c.GetUpcomingTransactions(ctx, ledger.UUID, today.AddDate(0, 2, 0))
+			is.NoErr(err)
+			is.Equal(len(upcoming), 2)
+			is.Equal(upcoming[0].Description, "Rent") // the name stands in for the description
+
+			err = c.DeleteSchedule(ctx, scheduleUUID)
+			is.NoErr(err)
+
+			err = c.DeleteSchedule(ctx, scheduleUUID)
+			is.True(errors.Is(err, client.ErrScheduleNotFound))
+
+			balance, err := c.GetAccountBalance(ctx, checking.UUID)
+			is.NoErr(err)
+			is.Equal(balance, int64(0)) // recorded rent is kept
+		},
+	)
+
+	t.Run(
+		"Posting", func(t *testing.T) {
+			is := is_.New(t)
+
+			// the rent recorded by the schedule is pending
+			pending, err := c.ListAccountTransactions(
+				ctx, client.AccountTransactionsParams{AccountUUID: checking.UUID, Status: client.StatusPending},
+			)
+			is.NoErr(err)
+			is.Equal(len(pending), 2)
+			is.Equal(pending[0].Status, client.StatusPending)
+
+			cleared, err := c.GetAccountClearedBalance(ctx, checking.UUID)
+			is.NoErr(err)
+			is.Equal(cleared, int64(100000)) // the bank has not seen the rent yet
+
+			err = c.PostTransaction(ctx, pending[0].UUID)
+			is.NoErr(err)
+
+			posted, err := c.PostTransactions(ctx, []string{pending[0].UUID, pending[1].UUID})
+			is.NoErr(err)
+			is.Equal(posted, 1) // the first one is posted already
+
+			balances, err := c.GetLedgerBalances(ctx, ledger.UUID)
+			is.NoErr(err)
+			for _, b := range balances {
+				is.Equal(b.ClearedBalance, b.CurrentBalance) // nothing is pending anymore
+			}
+
+			err = c.PostTransaction(ctx, "missing")
+			is.True(errors.Is(err, client.ErrTransactionNotFound))
+
+			_, err = c.ListAccountTransactions(
+				ctx, client.AccountTransactionsParams{AccountUUID: checking.UUID, Status: "cleared"},
+			)
+			is.True(errors.Is(err, client.ErrInvalidInput))
+		},
+	)
+
+	t.Run(
+		"Reconciliation", func(t *testing.T) {
+			is := is_.New(t)
+
+			params := client.ReconcileParams{AccountUUID: checking.UUID, StatementBalance: 1000}
+			preview, err := c.PreviewReconciliation(ctx, params)
+			is.NoErr(err)
+			is.Equal(preview.Difference, preview.StatementBalance-preview.ClearedBalance)
+
+			reconciliationUUID, err := c.ReconcileAccount(ctx, params)
+			is.NoErr(err)
+
+			reconciliations, err := c.GetReconciliations(ctx, checking.UUID)
+			is.NoErr(err)
+			is.Equal(len(reconciliations), 1)
+			is.Equal(reconciliations[0].UUID, reconciliationUUID)
+			is.Equal(reconciliations[0].AdjustmentUUID != nil, preview.Difference != 0)
+
+			cleared, err := c.GetAccountClearedBalance(ctx, checking.UUID)
+			is.NoErr(err)
+			is.Equal(cleared, int64(1000)) // the adjustment makes up the difference
+
+			history, err := c.GetAccountTransactions(ctx, checking.UUID)
+			is.NoErr(err)
+			is.True(history[0].Reconciled)
+
+			_, err = c.DeleteTransaction(ctx, history[0].UUID, "")
+			is.True(errors.Is(err, client.ErrTransactionReconciled))
+
+			_, err = c.DeleteReconciledTransaction(ctx, history[0].UUID, "Statement misread")
+			is.NoErr(err)
+		},
+	)
+
+	t.Run(
+		"Goals", func(t *testing.T) {
+			is := is_.New(t)
+
+			rent, err := c.FindCategory(ctx, ledger.UUID, "Rent")
+			is.NoErr(err)
+			utilities, err := c.FindCategory(ctx, ledger.UUID, "Utilities")
+			is.NoErr(err)
+
+			_, err = c.AddTransaction(
+				ctx, client.AddTransactionParams{
+					LedgerUUID:   ledger.UUID,
+					Date:         time.Now(),
+					Description:  "Bonus",
+					Type:         client.Inflow,
+					Amount:       100000,
+					AccountUUID:  checking.UUID,
+					CategoryUUID: income.UUID,
+				},
+			)
+			is.NoErr(err)
+
+			err = c.SetCategoryGoal(
+				ctx, client.SetCategoryGoalParams{CategoryUUID: rent.UUID, Type: client.GoalMonthlyFunding, Amount: 20000},
+			)
+			is.NoErr(err)
+			// due this month and more than Income holds
+			err = c.SetCategoryGoal(
+				ctx, client.SetCategoryGoalParams{
+					CategoryUUID: utilities.UUID,
+					Type:         client.GoalTargetBalance,
+					Amount:       10000000,
+					TargetDate:   time.Now(),
+				},
+			)
+			is.NoErr(err)
+
+			totals, err := c.GetBudgetTotals(ctx, ledger.UUID, "")
+			is.NoErr(err)
+
+			assignments, err := c.AutoAssign(ctx, client.AutoAssignParams{LedgerUUID: ledger.UUID})
+			is.NoErr(err)
+			is.Equal(len(assignments), 2)
+			is.Equal(*assignments[0].CategoryUUID, rent.UUID) // in category order
+			is.Equal(assignments[0].Amount, int64(20000))
+			is.Equal(assignments[1].Amount, totals.LeftToBudget-20000) // the rest of Income
+
+			progress, err := c.GetGoalProgress(ctx, ledger.UUID, "")
+			is.NoErr(err)
+			is.Equal(len(progress), 2)
+			is.Equal(progress[0].Underfunded, int64(0))
+			is.True(progress[1].Underfunded > 0)
+
+			assignments, err = c.AutoAssign(ctx, client.AutoAssignParams{LedgerUUID: ledger.UUID})
+			is.NoErr(err)
+			is.Equal(len(assignments), 0) // Income is empty
+
+			is.NoErr(c.DeleteCategoryGoal(ctx, utilities.UUID))
+			err = c.DeleteCategoryGoal(ctx, utilities.UUID)
+			is.True(errors.Is(err, client.ErrGoalNotFound))
+		},
+	)
+
+	t.Run(
+		"MoveBetweenCategories", func(t *testing.T) {
+			is := is_.New(t)
+
+			rent, err := c.FindCategory(ctx, ledger.UUID, "Rent")
+			is.NoErr(err)
+			available, err := c.GetAccountBalance(ctx, rent.UUID)
+			is.NoErr(err)
+
+			params := client.MoveBetweenCategoriesParams{
+				LedgerUUID:       ledger.UUID,
+				FromCategoryUUID: rent.UUID,
+				ToCategoryUUID:   groceries.UUID,
+				Amount:           available + 1,
+				Date:             time.Now(),
+				Memo:             "Cover groceries",
+			}
+			_, err = c.MoveBetweenCategories(ctx, params)
+			is.True(errors.Is(err, client.ErrInsufficientFunds)) // Rent cannot be overdrawn
+
+			params.Amount = 5000
+			move, err := c.MoveBetweenCategories(ctx, params)
+			is.NoErr(err)
+			is.Equal(move.Description, "Cover groceries")
+			is.Equal(*move.AccountUUID, rent.UUID)
+			is.Equal(*move.CategoryUUID, groceries.UUID)
+
+			history, err := c.GetAccountTransactions(ctx, rent.UUID)
+			is.NoErr(err)
+			is.Equal(history[0].UUID, move.UUID)
+			is.Equal(history[0].Category, "Groceries")
+			is.Equal(history[0].RunningBalance, available-5000)
+		},
+	)
+
+	t.Run(
+		"Transfers", func(t *testing.T) {
+			is := is_.New(t)
+
+			savings, err := c.CreateAccount(
+				ctx, client.CreateAccountParams{LedgerUUID: ledger.UUID, Name: "Savings", Type: client.AccountTypeAsset},
+			)
+			is.NoErr(err)
+			before, err := c.GetAccountBalance(ctx, checking.UUID)
+			is.NoErr(err)
+
+			params := client.AddTransferParams{
+				LedgerUUID:      ledger.UUID,
+				FromAccountUUID: checking.UUID,
+				ToAccountUUID:   savings.UUID,
+				Amount:          10000,
+				Date:            time.Now(),
+			}
+			transferUUID, err := c.AddTransfer(ctx, params)
+			is.NoErr(err)
+			is.True(transferUUID != "")
+
+			balance, err := c.GetAccountBalance(ctx, checking.UUID)
+			is.NoErr(err)
+			is.Equal(balance, before-10000)
+			balance, err = c.GetAccountBalance(ctx, savings.UUID)
+			is.NoErr(err)
+			is.Equal(balance, int64(10000))
+
+			history, err := c.GetAccountTransactions(ctx, savings.UUID)
+			is.NoErr(err)
+			is.Equal(history[0].Category, "Checking") // the other side is an account
+			is.Equal(history[0].Description, "Transfer from Checking to Savings")
+
+			params.ToAccountUUID = groceries.UUID
+			_, err = c.AddTransfer(ctx, params)
+			is.True(errors.Is(err, client.ErrInvalidInput)) // categories are moved, not transferred
+		},
+	)
+
+	t.Run(
+		"CategoryRules", func(t *testing.T) {
+			is := is_.New(t)
+
+			maxAmount := int64(20000)
+			ruleUUID, err := c.AddCategoryRule(
+				ctx, client.AddCategoryRuleParams{
+					LedgerUUID:         ledger.UUID,
+					Name:               "Market",
+					DescriptionPattern: "farmers? market",
+					MaxAmount:          &maxAmount,
+					CategoryUUID:       groceries.UUID,
+					Tags:               []string{"local"},
+				},
+			)
+			is.NoErr(err)
+
+			rules, err := c.GetCategoryRules(ctx, ledger.UUID)
+			is.NoErr(err)
+			is.Equal(len(rules), 1)
+			is.Equal(*rules[0].CategoryName, "Groceries")
+			is.Equal(rules[0].Tags, []string{"local"})
+			is.Equal(rules[0].MinAmount, (*int64)(nil))
+
+			_, err = c.AddTransaction(
+				ctx, client.AddTransactionParams{
+					LedgerUUID:  ledger.UUID,
+					Date:        time.Now(),
+					Description: "Farmers Market",
+					Type:        client.Outflow,
+					Amount:      1500,
+					AccountUUID: checking.UUID,
+				},
+			)
+			is.NoErr(err)
+
+			history, err := c.GetAccountTransactions(ctx, groceries.UUID)
+			is.NoErr(err)
+			found := false
+			for _, tx := range history {
+				found = found || tx.Description == "Farmers Market"
+			}
+			is.True(found) // the rule chose Groceries
+
+			applied, err := c.ApplyCategoryRules(ctx, ledger.UUID)
+			is.NoErr(err)
+			is.Equal(len(applied), 0) // nothing Unassigned matches
+
+			is.NoErr(c.DeleteCategoryRule(ctx, ruleUUID))
+			err = c.DeleteCategoryRule(ctx, ruleUUID)
+			is.True(errors.Is(err, client.ErrRuleNotFound))
+
+			_, err = c.AddCategoryRule(ctx, client.AddCategoryRuleParams{LedgerUUID: ledger.UUID, Name: "Idle"})
+			is.True(errors.Is(err, client.ErrInvalidInput)) // a rule needs an action
+		},
+	)
+
+	t.Run(
+		"Payees", func(t *testing.T) {
+			is := is_.New(t)
+
+			params := client.AddTransactionParams{
+				LedgerUUID:   ledger.UUID,
+				Date:         time.Now(),
+				Description:  "Produce",
+				Type:         client.Outflow,
+				Amount:       1200,
+				AccountUUID:  checking.UUID,
+				CategoryUUID: groceries.UUID,
+				Payee:        "Corner Market",
+			}
+			_, err := c.AddTransaction(ctx, params)
+			is.NoErr(err)
+
+			params.CategoryUUID = ""
+			params.Payee = "corner market"
+			produceUUID, err := c.AddTransaction(ctx, params)
+			is.NoErr(err)
+
+			history, err := c.GetAccountTransactions(ctx, checking.UUID)
+			is.NoErr(err)
+			for _, tx := range history {
+				if tx.UUID == produceUUID {
+					is.Equal(tx.Category, "Groceries") // the payee remembered it
+					is.Equal(*tx.Payee, "Corner Market")
+				}
+			}
+
+			linked, err := c.BackfillPayees(ctx, ledger.UUID)
+			is.NoErr(err)
+			is.True(linked > 0) // the transactions recorded without a payee
+
+			payees, err := c.GetPayees(ctx, ledger.UUID)
+			is.NoErr(err)
+			var market client.Payee
+			for _, p := range payees {
+				if p.Name == "Corner Market" {
+					market = p
+				}
+			}
+			is.Equal(market.Transactions, 2)
+			is.Equal(market.Outflow, int64(2400))
+			is.Equal(*market.DefaultCategoryName, "Groceries")
+
+			storeUUID, err := c.CreatePayee(ctx, client.CreatePayeeParams{LedgerUUID: ledger.UUID, Name: "Corner Store"})
+			is.NoErr(err)
+			err = c.RenamePayee(ctx, storeUUID, "CORNER MARKET")
+			is.True(errors.Is(err, client.ErrDuplicateName)) // merge instead
+			moved, err := c.MergePayees(ctx, storeUUID, market.UUID)
+			is.NoErr(err)
+			is.Equal(moved, 0)
+
+			is.NoErr(c.SetPayeeCategory(ctx, market.UUID, ""))
+			_, err = c.MergePayees(ctx, storeUUID, market.UUID)
+			is.True(errors.Is(err, client.ErrPayeeNotFound)) // merged payees are deleted
+		},
+	)
+
+	t.Run(
+		"Tags", func(t *testing.T) {
+			is := is_.New(t)
+
+			transactionUUID, err := c.AddTransaction(
+				ctx, client.AddTransactionParams{
+					LedgerUUID:   ledger.UUID,
+					Date:         time.Now(),
+					Description:  "Camping food",
+					Type:         client.Outflow,
+					Amount:       4200,
+					AccountUUID:  checking.UUID,
+					CategoryUUID: groceries.UUID,
+				},
+			)
+			is.NoErr(err)
+
+			tags, err := c.TagTransaction(ctx, transactionUUID, "Trip-2026", "reimbursable")
+			is.NoErr(err)
+			is.Equal(tags, []string{"reimbursable", "trip-2026"}) // stored in lowercase, sorted
+
+			history, err := c.ListAccountTransactions(
+				ctx, client.AccountTransactionsParams{AccountUUID: checking.UUID, Tags: []string{"trip-2026"}},
+			)
+			is.NoErr(err)
+			is.Equal(len(history), 1)
+			is.Equal(history[0].UUID, transactionUUID)
+			is.Equal(history[0].Tags, []string{"reimbursable", "trip-2026"})
+
+			tags, err = c.UntagTransaction(ctx, transactionUUID, "reimbursable")
+			is.NoErr(err)
+			is.Equal(tags, []string{"trip-2026"})
+
+			period := time.Now().Format("200601")
+			spending, err := c.GetTagSpending(
+				ctx, client.TagSpendingParams{
+					LedgerUUID: ledger.UUID, Tags: []string{"trip-2026"}, StartPeriod: period, EndPeriod: period,
+				},
+			)
+			is.NoErr(err)
+			is.Equal(len(spending), 1)
+			is.Equal(spending[0].CategoryName, "Groceries")
+			is.Equal(spending[0].Spent, int64(4200))
+			is.Equal(spending[0].Transactions, 1)
+
+			_, err = c.TagTransaction(ctx, transactionUUID, "road trip")
+			is.True(errors.Is(err, client.ErrInvalidInput))
+			_, err = c.TagTransaction(ctx, "missing", "trip-2026")
+			is.True(errors.Is(err, client.ErrTransactionNotFound))
+			_, err = c.GetTagSpending(ctx, client.TagSpendingParams{LedgerUUID: ledger.UUID, StartPeriod: "2026-01"})
+			is.True(errors.Is(err, client.ErrInvalidPeriod))
+		},
+	)
+
+	t.Run(
+		"Search", func(t *testing.T) {
+			is := is_.New(t)
+
+			var uuids []string
+			for _, amount := range []int64{1500, 2500} {
+				transactionUUID, err := c.AddTransaction(
+					ctx, client.AddTransactionParams{
+						LedgerUUID:   ledger.UUID,
+						Date:         time.Now(),
+						Description:  "Zephyr Hardware #42",
+						Type:         client.Outflow,
+						Amount:       amount,
+						AccountUUID:  checking.UUID,
+						CategoryUUID: groceries.UUID,
+					},
+				)
+				is.NoErr(err)
+				uuids = append(uuids, transactionUUID)
+			}
+
+			page, err := c.Search(ctx, client.SearchParams{LedgerUUID: ledger.UUID, Query: "zeph hard"})
+			is.NoErr(err)
+			is.Equal(len(page.Transactions), 2)
+			is.Equal(page.Transactions[0].UUID, uuids[1]) // newest first
+			is.Equal(page.Transactions[0].CategoryName, "Groceries")
+			is.Equal(page.NextCursor, "") // the last page
+
+			minAmount := int64(2000)
+			page, err = c.Search(ctx, client.SearchParams{LedgerUUID: ledger.UUID, Query: "#42", MinAmount: &minAmount})
+			is.NoErr(err)
+			is.Equal(len(page.Transactions), 1)
+			is.Equal(page.Transactions[0].Amount, int64(2500))
+
+			page, err = c.Search(ctx, client.SearchParams{LedgerUUID: ledger.UUID, Query: "zephyr", Limit: 1})
+			is.NoErr(err)
+			is.Equal(page.NextCursor, uuids[1])
+			page, err = c.Search(
+				ctx, client.SearchParams{LedgerUUID: ledger.UUID, Query: "zephyr", Limit: 1, After: page.NextCursor},
+			)
+			is.NoErr(err)
+			is.Equal(len(page.Transactions), 1)
+			is.Equal(page.Transactions[0].UUID, uuids[0])
+
+			_, err = c.Search(ctx, client.SearchParams{LedgerUUID: "missing"})
+			is.True(errors.Is(err, client.ErrLedgerNotFound))
+			_, err = c.Search(ctx, client.SearchParams{LedgerUUID: ledger.UUID, After: "missing"})
+			is.True(errors.Is(err, client.ErrTransactionNotFound))
+			_, err = c.Search(ctx, client.SearchParams{LedgerUUID: ledger.UUID, Status: "cleared"})
+			is.True(errors.Is(err, client.ErrInvalidInput))
+		},
+	)
+
+	t.Run(
+		"AccountTransactionsPage", func(t *testing.T) {
+			is := is_.New(t)
+
+			whole, err := c.GetAccountTransactions(ctx, checking.UUID)
+			is.NoErr(err)
+			is.True(len(whole) > 2)
+
+			page, err := c.GetAccountTransactionsPage(
+				ctx, client.AccountTransactionsParams{AccountUUID: checking.UUID, Limit: 2, WithTotal: true},
+			)
+			is.NoErr(err)
+			is.Equal(page.Transactions, whole[:2])
+			is.Equal(*page.Total, int64(len(whole)))
+
+			page, err = c.GetAccountTransactionsPage(
+				ctx, client.AccountTransactionsParams{AccountUUID: checking.UUID, After: whole[1].UUID, Limit: 1},
+			)
+			is.NoErr(err)
+			is.Equal(page.Transactions, whole[2:3])
+			is.Equal(page.Total, (*int64)(nil)) // counted only on request
+
+			page, err = c.GetAccountTransactionsPage(
+				ctx, client.AccountTransactionsParams{AccountUUID: checking.UUID, Before: whole[2].UUID, WithTotal: true},
+			)
+			is.NoErr(err)
+			is.Equal(page.Transactions, whole[:2])
+
+			page, err = c.GetAccountTransactionsPage(
+				ctx, client.AccountTransactionsParams{AccountUUID: checking.UUID, Before: whole[0].UUID, WithTotal: true},
+			)
+			is.NoErr(err)
+			is.Equal(len(page.Transactions), 0)
+			is.Equal(*page.Total, int64(len(whole))) // an empty page still has the total
+
+			_, err = c.GetAccountTransactionsPage(ctx, client.AccountTransactionsParams{AccountUUID: checking.UUID, After: "missing"})
+			is.True(errors.Is(err, client.ErrTransactionNotFound))
+		},
+	)
+
+	t.Run(
+		"MonthClose", func(t *testing.T) {
+			is := is_.New(t)
+
+			rules, err := c.GetRolloverRules(ctx, ledger.UUID)
+			is.NoErr(err)
+			is.Equal(
+				*rules, client.RolloverRules{
+					Leftover:           client.RolloverKeep,
+					CashOverspending:   client.RolloverIncome,
+					CreditOverspending: client.RolloverUnassigned,
+				},
+			)
+
+			rules.Leftover = client.RolloverIncome
+			is.NoErr(c.SetRolloverRules(ctx, ledger.UUID, *rules))
+
+			now := time.Now()
+			lastMonth := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.UTC).Format("200601")
+			recorded, err := c.CloseMonth(ctx, ledger.UUID, lastMonth)
+			is.NoErr(err)
+
+			months, err := c.GetClosedMonths(ctx, ledger.UUID)
+			is.NoErr(err)
+			is.Equal(len(months), 1)
+			is.Equal(months[0].Period, lastMonth)
+			is.Equal(months[0].Transactions, len(recorded))
+
+			_, err = c.CloseMonth(ctx, ledger.UUID, lastMonth)
+			is.True(errors.Is(err, client.ErrDateOutOfRange)) // months close once
+
+			err = c.SetRolloverRules(ctx, ledger.UUID, client.RolloverRules{Leftover: client.RolloverUnassigned})
+			is.True(errors.Is(err, client.ErrInvalidInput))
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			is := is_.New(t)
+
+			_, err := c.GetLedger(ctx, "missing")
+			is.True(errors.Is(err, client.ErrLedgerNotFound)) // unknown ledger should fail
+
+			_, err = c.AddTransaction(
+				ctx, client.AddTransactionParams{
+					LedgerUUID:  ledger.UUID,
+					Date:        time.Now(),
+					Description: "Bad amount",
+					Type:        client.Outflow,
+					Amount:      0,
+					AccountUUID: checking.UUID,
+				},
+			)
+			is.True(errors.Is(err, client.ErrAmountOutOfRange)) // zero amounts are rejected by the database
+
+			var clientErr *client.Error
+			is.True(errors.As(err, &clientErr))
+			is.Equal(clientErr.Code, client.CodeAmountOutOfRange)
+
+			var pgErr *pgconn.PgError
+			is.True(errors.As(err, &pgErr)) // the driver error stays reachable
+
+			_, err = c.AddTransaction(
+				ctx, client.AddTransactionParams{
+					LedgerUUID:  ledger.UUID,
+					Date:        time.Now().AddDate(2, 0, 0),
+					Description: "Far future",
+					Type:        client.Outflow,
+					Amount:      100,
+					AccountUUID: checking.UUID,
+				},
+			)
+			is.True(errors.Is(err, client.ErrDateOutOfRange))
+
+			_, err = c.AddTransaction(
+				ctx, client.AddTransactionParams{
+					LedgerUUID:  ledger.UUID,
+					Date:        time.Now(),
+					Description: "Unknown account",
+					Type:        client.Outflow,
+					Amount:      100,
+					AccountUUID: "missing",
+				},
+			)
+			is.True(errors.Is(err, client.ErrAccountNotFound))
+
+			_, err = c.AddCategory(ctx, ledger.UUID, "Groceries")
+			is.True(errors.Is(err, client.ErrDuplicateName))
+
+			_, err = c.FindCategory(ctx, ledger.UUID, "Missing")
+			is.True(errors.Is(err, client.ErrCategoryNotFound))
+
+			_, err = c.DeleteTransaction(ctx, "missing", "")
+			is.True(errors.Is(err, client.ErrTransactionNotFound))
+
+			_, err = c.GetBudgetStatus(ctx, ledger.UUID, "2025-08")
+			is.True(errors.Is(err, client.ErrInvalidPeriod))
+		},
+	)
+}
+
+// TestWithUser checks that user contexts set through WithUser never bleed
+// between tenants sharing a pool, and that the pool hooks clean up after code
+// that sets app.current_user_id at session level.
+func TestWithUser(t *testing.T) {
+	is := is_.New(t)
+	ctx := context.Background()
+
+	cfg, err := pgxpool.ParseConfig(testDSN)
+	is.NoErr(err)
+	cfg.MaxConns = 4 // far fewer connections than workers, to force reuse
+	client.ConfigurePool(cfg)
+
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	is.NoErr(err) // should connect to database without error
+	t.Cleanup(pool.Close)
+
+	t.Run(
+		"RequiresUser", func(t *testing.T) {
+			is := is_.New(t)
+
+			err := client.WithUser(ctx, pool, " ", func(tx pgx.Tx) error { return nil })
+			is.True(errors.Is(err, client.ErrNoUser))
+		},
+	)
+
+	t.Run(
+		"Concurrency", func(t *testing.T) {
+			is := is_.New(t)
+
+			const (
+				tenants    = 16
+				iterations = 25
+			)
+
+			var (
+				wg   sync.WaitGroup
+				errc = make(chan error, tenants*iterations)
+			)
+
+			for i := range tenants {
+				userID := fmt.Sprintf("tenant-%02d", i)
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					for j := range iterations {
+						err := client.WithUser(ctx, pool, userID, func(tx pgx.Tx) error {
+							c := client.New(tx)
+
+							ledger, err := c.CreateLedger(ctx, client.CreateLedgerParams{Name: fmt.Sprintf("%s ledger %d", userID, j)})
+							if err != nil {
+								return err
+							}
+							if ledger.UserData != userID {
+								return fmt.Errorf("ledger created for %q, want %q", ledger.UserData, userID)
+							}
+
+							// yield so other tenants interleave on the shared connections
+							if _, err := tx.Exec(ctx, "select pg_sleep(0.001)"); err != nil {
+								return err
+							}
+
+							var current string
+							if err := tx.QueryRow(ctx, "select utils.get_user()").Scan(&current); err != nil {
+								return err
+							}
+							if current != userID {
+								return fmt.Errorf("utils.get_user() = %q, want %q", current, userID)
+							}
+							return nil
+						})
+						if err != nil {
+							errc <- err
+						}
+					}
+				}()
+			}
+
+			wg.Wait()
+			close(errc)
+
+			for err := range errc {
+				t.Error(err)
+			}
+
+			// every ledger must belong to the tenant named in it
+			var mismatched int
+			err := pool.QueryRow(
+				ctx,
+				"select count(*) from data.ledgers where name like 'tenant-%' and name not like user_data || ' %'",
+			).Scan(&mismatched)
+			is.NoErr(err)
+			is.Equal(mismatched, 0)
+		},
+	)
+
+	t.Run(
+		"SessionLevelLeak", func(t *testing.T) {
+			is := is_.New(t)
+
+			cfg, err := pgxpool.ParseConfig(testDSN)
+			is.NoErr(err)
+			cfg.MaxConns = 1 // the second acquire must reuse the first connection
+			client.ConfigurePool(cfg)
+
+			single, err := pgxpool.NewWithConfig(ctx, cfg)
+			is.NoErr(err)
+			t.Cleanup(single.Close)
+
+			conn, err := single.Acquire(ctx)
+			is.NoErr(err)
+			pid := conn.Conn().PgConn().PID()
+			_, err = conn.Exec(ctx, "select set_config('app.current_user_id', 'mallory', false)")
+			is.NoErr(err)
+			conn.Release()
+
+			conn, err = single.Acquire(ctx)
+			is.NoErr(err)
+			defer conn.Release()
+
+			var current *string
+			err = conn.QueryRow(ctx, "select nullif(current_setting('app.current_user_id', true), '')").Scan(&current)
+			is.NoErr(err)
+			is.True(current == nil)                   // the session setting must not survive release
+			is.Equal(conn.Conn().PgConn().PID(), pid) // and the connection itself was reused
+		},
+	)
+}
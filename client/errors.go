@@ -0,0 +1,177 @@
+package client
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Sentinel errors returned by the client. Match them with errors.Is; the
+// underlying *pgconn.PgError stays reachable through errors.As.
+var (
+	ErrLedgerNotFound          = errors.New("ledger not found")
+	ErrAccountNotFound         = errors.New("account not found")
+	ErrCategoryNotFound        = errors.New("category not found")
+	ErrTransactionNotFound     = errors.New("transaction not found")
+	ErrScheduleNotFound        = errors.New("schedule not found")
+	ErrGoalNotFound            = errors.New("goal not found")
+	ErrRuleNotFound            = errors.New("rule not found")
+	ErrPayeeNotFound           = errors.New("payee not found")
+	ErrTransactionReconciled   = errors.New("transaction is reconciled")
+	ErrInsufficientFunds       = errors.New("insufficient funds")
+	ErrDuplicateName           = errors.New("name already exists")
+	ErrAmountOutOfRange        = errors.New("amount out of range")
+	ErrDateOutOfRange          = errors.New("date out of range")
+	ErrInvalidTransactionType  = errors.New("invalid transaction type")
+	ErrInvalidInput            = errors.New("invalid input")
+	ErrInvalidPeriod           = errors.New("invalid period")
+	ErrSpecialAccountProtected = errors.New("special account cannot be modified")
+)
+
+// SQLSTATE codes raised by the database functions. The PB class is specific
+// to pgbudget; see the add_error_codes migration for the full list.
+const (
+	CodeLedgerNotFound          = "PB001"
+	CodeAccountNotFound         = "PB002"
+	CodeCategoryNotFound        = "PB003"
+	CodeTransactionNotFound     = "PB004"
+	CodeScheduleNotFound        = "PB005"
+	CodeGoalNotFound            = "PB006"
+	CodeRuleNotFound            = "PB007"
+	CodePayeeNotFound           = "PB008"
+	CodeAmountOutOfRange        = "PB010"
+	CodeDateOutOfRange          = "PB011"
+	CodeInvalidTransactionType  = "PB012"
+	CodeInvalidInput            = "PB013"
+	CodeSpecialAccountProtected = "PB020"
+	CodeTransactionReconciled   = "PB021"
+	CodeInsufficientFunds       = "PB022"
+	CodeUniqueViolation         = "23505"
+)
+
+var kindsByCode = map[string]error{
+	CodeLedgerNotFound:          ErrLedgerNotFound,
+	CodeAccountNotFound:         ErrAccountNotFound,
+	CodeCategoryNotFound:        ErrCategoryNotFound,
+	CodeTransactionNotFound:     ErrTransactionNotFound,
+	CodeScheduleNotFound:        ErrScheduleNotFound,
+	CodeGoalNotFound:            ErrGoalNotFound,
+	CodeRuleNotFound:            ErrRuleNotFound,
+	CodePayeeNotFound:           ErrPayeeNotFound,
+	CodeAmountOutOfRange:        ErrAmountOutOfRange,
+	CodeDateOutOfRange:          ErrDateOutOfRange,
+	CodeInvalidTransactionType:  ErrInvalidTransactionType,
+	CodeInvalidInput:            ErrInvalidInput,
+	CodeSpecialAccountProtected: ErrSpecialAccountProtected,
+	CodeTransactionReconciled:   ErrTransactionReconciled,
+	CodeInsufficientFunds:       ErrInsufficientFunds,
+}
+
+// nameConstraints are the unique constraints on names. Breaking one is
+// ErrDuplicateName; other unique violations, on uuids, fingerprints or
+// one-per-period keys, are left unclassified.
+var nameConstraints = map[string]bool{
+	"ledgers_name_user_unique":           true,
+	"accounts_name_ledger_unique":        true,
+	"unique_special_accounts_per_ledger": true,
+	"schedules_name_unique":              true,
+	"category_rules_name_ledger_unique":  true,
+	"payees_name_ledger_unique":          true,
+}
+
+// kindsByMessage classifies errors from functions that still raise the
+// generic P0001 code, matching on the start of the message.
+var kindsByMessage = []struct {
+	prefix string
+	kind   error
+}{
+	{"ledger with uuid", ErrLedgerNotFound},
+	{"ledger not found", ErrLedgerNotFound},
+	{"account with uuid", ErrAccountNotFound},
+	{"account not found", ErrAccountNotFound},
+	{"category with uuid", ErrCategoryNotFound},
+	{"category not found", ErrCategoryNotFound},
+	{"transaction not found", ErrTransactionNotFound},
+	{"invalid period format", ErrInvalidPeriod},
+}
+
+// Error is returned by every client method that fails in the database.
+// Kind holds one of the sentinel errors above when the failure could be
+// classified, and Err the original error.
+type Error struct {
+	// Op names the client operation, e.g. "add transaction".
+	Op string
+	// Kind is the classified sentinel error, or nil.
+	Kind error
+	// Code is the SQLSTATE reported by Postgres, if any.
+	Code string
+	// Message is the message reported by Postgres, if any.
+	Message string
+	// Hint is the hint reported by Postgres, if any.
+	Hint string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return "failed to " + e.Op + ": " + e.Err.Error()
+}
+
+// Unwrap exposes both the kind and the original error so that errors.Is
+// and errors.As work on either.
+func (e *Error) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// wrapErr turns err into an *Error for the operation op. It returns nil
+// when err is nil.
+func wrapErr(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	e := &Error{Op: op, Err: err}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		e.Code = pgErr.Code
+		e.Message = pgErr.Message
+		e.Hint = pgErr.Hint
+		e.Kind = classify(pgErr)
+	}
+
+	return e
+}
+
+// wrapNotFound is like wrapErr but reports pgx.ErrNoRows as kind.
+func wrapNotFound(op string, err error, kind error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &Error{Op: op, Kind: kind, Err: err}
+	}
+	return wrapErr(op, err)
+}
+
+func classify(pgErr *pgconn.PgError) error {
+	if pgErr.Code == CodeUniqueViolation {
+		if nameConstraints[pgErr.ConstraintName] {
+			return ErrDuplicateName
+		}
+		return nil
+	}
+	if kind, ok := kindsByCode[pgErr.Code]; ok {
+		return kind
+	}
+
+	message := strings.ToLower(pgErr.Message)
+	for _, m := range kindsByMessage {
+		if strings.HasPrefix(message, m.prefix) {
+			return m.kind
+		}
+	}
+
+	return nil
+}
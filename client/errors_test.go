@@ -0,0 +1,29 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	is_ "github.com/matryer/is"
+)
+
+func TestClassifyUniqueViolations(t *testing.T) {
+	is := is_.New(t)
+
+	for constraint, want := range map[string]error{
+		"accounts_name_ledger_unique":          ErrDuplicateName,
+		"payees_name_ledger_unique":            ErrDuplicateName,
+		"transactions_uuid_unique":             nil,
+		"month_closes_month_unique":            nil,
+		"idx_transactions_schedule_occurrence": nil,
+		"":                                     nil,
+	} {
+		err := wrapErr("test", &pgconn.PgError{Code: CodeUniqueViolation, ConstraintName: constraint})
+
+		var clientErr *Error
+		is.True(errors.As(err, &clientErr))
+		is.Equal(clientErr.Kind, want) // only name constraints are duplicate names
+		is.Equal(clientErr.Code, CodeUniqueViolation)
+	}
+}
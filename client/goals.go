@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SetCategoryGoalParams holds the arguments of api.set_category_goal.
+type SetCategoryGoalParams struct {
+	CategoryUUID string
+	Type         GoalType
+	// Amount is expressed in cents and must be positive: the monthly
+	// funding, the target balance or the spending cap.
+	Amount int64
+	// TargetDate is the date a target balance is due by; it must be zero for
+	// the other goal types.
+	TargetDate time.Time
+}
+
+// SetCategoryGoal sets the goal of a category through api.set_category_goal,
+// replacing the goal it had.
+func (c *Client) SetCategoryGoal(ctx context.Context, params SetCategoryGoalParams) error {
+	var targetDate *time.Time
+	if !params.TargetDate.IsZero() {
+		targetDate = &params.TargetDate
+	}
+
+	_, err := c.db.Exec(
+		ctx,
+		"select api.set_category_goal($1, $2, $3, $4)",
+		params.CategoryUUID, string(params.Type), params.Amount, targetDate,
+	)
+	if err != nil {
+		return wrapErr("set category goal", err)
+	}
+
+	return nil
+}
+
+// DeleteCategoryGoal removes the goal of a category through
+// api.delete_category_goal. It fails with ErrGoalNotFound when the category
+// has none.
+func (c *Client) DeleteCategoryGoal(ctx context.Context, categoryUUID string) error {
+	if _, err := c.db.Exec(ctx, "select api.delete_category_goal($1)", categoryUUID); err != nil {
+		return wrapErr("delete category goal", err)
+	}
+
+	return nil
+}
+
+// GetGoalProgress returns how every category with a goal stands in a month
+// through api.get_goal_progress, ordered by category name. Period uses the
+// YYYYMM format; an empty period is the current month.
+func (c *Client) GetGoalProgress(ctx context.Context, ledgerUUID, period string) ([]GoalProgress, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select * from api.get_goal_progress($1, coalesce($2, to_char(current_date, 'YYYYMM')))",
+		ledgerUUID, nullString(period),
+	)
+	if err != nil {
+		return nil, wrapErr("get goal progress", err)
+	}
+
+	progress, err := pgx.CollectRows(rows, pgx.RowToStructByName[GoalProgress])
+	if err != nil {
+		return nil, wrapErr("get goal progress", err)
+	}
+
+	return progress, nil
+}
+
+// AutoAssignParams holds the arguments of AutoAssign.
+type AutoAssignParams struct {
+	LedgerUUID string
+	// Period is the month to fund as YYYYMM; the current month when empty.
+	Period string
+	// Date is the date of the assignments. When zero it is today, or the
+	// first day of Period when Period is another month, so that the
+	// assignments count towards it.
+	Date time.Time
+}
+
+// AutoAssign assigns money from Income to the categories whose goals are
+// underfunded in a month, with one api.assign_to_category call per category
+// in the order of GetGoalProgress. It never assigns more than Income holds,
+// so the last category funded may stay partly underfunded. It returns the
+// assignments made; run it through WithUser to make them all or nothing.
+func (c *Client) AutoAssign(ctx context.Context, params AutoAssignParams) ([]Transaction, error) {
+	date := params.Date
+	if date.IsZero() {
+		date = time.Now()
+		if params.Period != "" && params.Period != date.Format("200601") {
+			month, err := time.Parse("200601", params.Period)
+			if err != nil {
+				return nil, &Error{Op: "auto assign", Kind: ErrInvalidPeriod, Err: err}
+			}
+			date = month
+		}
+	}
+
+	progress, err := c.GetGoalProgress(ctx, params.LedgerUUID, params.Period)
+	if err != nil {
+		return nil, err
+	}
+	totals, err := c.GetBudgetTotals(ctx, params.LedgerUUID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	available := totals.LeftToBudget
+	assignments := make([]Transaction, 0, len(progress))
+	for _, p := range progress {
+		amount := min(p.Underfunded, available)
+		if amount <= 0 {
+			continue
+		}
+		assignment, err := c.AssignToCategory(
+			ctx, AssignToCategoryParams{
+				LedgerUUID:   params.LedgerUUID,
+				Date:         date,
+				Description:  "Goal funding",
+				Amount:       amount,
+				CategoryUUID: p.CategoryUUID,
+			},
+		)
+		if err != nil {
+			return assignments, err
+		}
+		assignments = append(assignments, *assignment)
+		available -= amount
+	}
+
+	return assignments, nil
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ImportBatchItem is one line of an import batch.
+type ImportBatchItem struct {
+	Line        int          `json:"line"`
+	Fingerprint string       `json:"fingerprint"`
+	Action      ImportAction `json:"action"`
+	// TransactionUUID is the transaction the line was recorded as, merged
+	// into or skipped in favour of. It is empty when there is none.
+	TransactionUUID string `json:"transaction_uuid,omitempty"`
+}
+
+// AddImportBatchParams holds the arguments of api.add_import_batch.
+type AddImportBatchParams struct {
+	AccountUUID string
+	// Source names where the lines came from, such as a file name.
+	Source string
+	// Policy is how likely duplicates were handled: "skip", "merge" or "force".
+	Policy string
+	Items  []ImportBatchItem
+}
+
+// AddImportBatch stores a finished import and its lines through
+// api.add_import_batch and returns the UUID of the batch.
+func (c *Client) AddImportBatch(ctx context.Context, params AddImportBatchParams) (string, error) {
+	items := params.Items
+	if items == nil {
+		items = []ImportBatchItem{}
+	}
+	payload, err := json.Marshal(items)
+	if err != nil {
+		return "", wrapErr("add import batch", err)
+	}
+
+	var batchUUID string
+	err = c.db.QueryRow(
+		ctx,
+		"select api.add_import_batch($1, $2, $3, $4)",
+		params.AccountUUID, nullString(params.Source), params.Policy, payload,
+	).Scan(&batchUUID)
+	if err != nil {
+		return "", wrapErr("add import batch", err)
+	}
+
+	return batchUUID, nil
+}
+
+// FindImportFingerprints returns the fingerprints among the given ones that
+// earlier import batches of the account have recorded, as imported, merged
+// or forced lines. Lines they skipped, and lines whose transaction was
+// deleted or corrected since, are not returned.
+func (c *Client) FindImportFingerprints(ctx context.Context, accountUUID string, fingerprints []string) ([]ImportFingerprint, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select fingerprint, action, transaction_uuid, batch_uuid from api.find_import_fingerprints($1, $2)",
+		accountUUID, fingerprints,
+	)
+	if err != nil {
+		return nil, wrapErr("find import fingerprints", err)
+	}
+
+	found, err := pgx.CollectRows(rows, pgx.RowToStructByName[ImportFingerprint])
+	if err != nil {
+		return nil, wrapErr("find import fingerprints", err)
+	}
+
+	return found, nil
+}
+
+// FindImportCandidates returns the transactions of an account dated
+// between start and end, inclusive. Deleted and corrected transactions and
+// their reversals are left out; corrections are returned.
+func (c *Client) FindImportCandidates(ctx context.Context, accountUUID string, start, end time.Time) ([]ImportCandidate, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select uuid, date, type, amount, description, metadata from api.find_import_candidates($1, $2, $3)",
+		accountUUID, start, end,
+	)
+	if err != nil {
+		return nil, wrapErr("find import candidates", err)
+	}
+
+	candidates, err := pgx.CollectRows(rows, pgx.RowToStructByName[ImportCandidate])
+	if err != nil {
+		return nil, wrapErr("find import candidates", err)
+	}
+
+	return candidates, nil
+}
+
+// MergeTransactionMetadata merges a JSON object into the metadata of a
+// transaction through api.merge_transaction_metadata.
+func (c *Client) MergeTransactionMetadata(ctx context.Context, transactionUUID string, metadata json.RawMessage) error {
+	_, err := c.db.Exec(ctx, "select api.merge_transaction_metadata($1, $2)", transactionUUID, metadata)
+	return wrapErr("merge transaction metadata", err)
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ledgerColumns is the column list of the api.ledgers view.
+const ledgerColumns = "uuid, name, description, metadata, user_data"
+
+// CreateLedgerParams holds the fields accepted when creating a ledger.
+type CreateLedgerParams struct {
+	Name        string
+	Description string
+	Metadata    json.RawMessage
+}
+
+// CreateLedger inserts a ledger through the api.ledgers view.
+// The database creates the Income, Off-budget and Unassigned accounts for it.
+func (c *Client) CreateLedger(ctx context.Context, params CreateLedgerParams) (*Ledger, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"insert into api.ledgers (name, description, metadata) values ($1, $2, $3) returning "+ledgerColumns,
+		params.Name, nullString(params.Description), params.Metadata,
+	)
+	if err != nil {
+		return nil, wrapErr("create ledger", err)
+	}
+
+	ledger, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Ledger])
+	if err != nil {
+		return nil, wrapErr("create ledger", err)
+	}
+
+	return &ledger, nil
+}
+
+// GetLedger returns the ledger with the given UUID.
+func (c *Client) GetLedger(ctx context.Context, ledgerUUID string) (*Ledger, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select "+ledgerColumns+" from api.ledgers where uuid = $1",
+		ledgerUUID,
+	)
+	if err != nil {
+		return nil, wrapErr("get ledger", err)
+	}
+
+	ledger, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Ledger])
+	if err != nil {
+		return nil, wrapNotFound("get ledger", err, ErrLedgerNotFound)
+	}
+
+	return &ledger, nil
+}
+
+// ListLedgers returns every ledger visible to the current user, ordered by name.
+func (c *Client) ListLedgers(ctx context.Context) ([]Ledger, error) {
+	rows, err := c.db.Query(ctx, "select "+ledgerColumns+" from api.ledgers order by name")
+	if err != nil {
+		return nil, wrapErr("list ledgers", err)
+	}
+
+	ledgers, err := pgx.CollectRows(rows, pgx.RowToStructByName[Ledger])
+	if err != nil {
+		return nil, wrapErr("list ledgers", err)
+	}
+
+	return ledgers, nil
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GetRolloverRules returns the rollover rules of a ledger through
+// api.get_rollover_rules; a ledger that never set them has the defaults:
+// leftovers roll over, cash overspending is covered from Income and credit
+// overspending from Unassigned.
+func (c *Client) GetRolloverRules(ctx context.Context, ledgerUUID string) (*RolloverRules, error) {
+	rows, err := c.db.Query(ctx, "select * from api.get_rollover_rules($1)", ledgerUUID)
+	if err != nil {
+		return nil, wrapErr("get rollover rules", err)
+	}
+
+	rules, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[RolloverRules])
+	if err != nil {
+		return nil, wrapErr("get rollover rules", err)
+	}
+
+	return &rules, nil
+}
+
+// SetRolloverRules sets the rollover rules of a ledger through
+// api.set_rollover_rules. Every rule must be set.
+func (c *Client) SetRolloverRules(ctx context.Context, ledgerUUID string, rules RolloverRules) error {
+	_, err := c.db.Exec(
+		ctx,
+		"select api.set_rollover_rules($1, $2, $3, $4)",
+		ledgerUUID, string(rules.Leftover), string(rules.CashOverspending), string(rules.CreditOverspending),
+	)
+	if err != nil {
+		return wrapErr("set rollover rules", err)
+	}
+
+	return nil
+}
+
+// CloseMonth applies the rollover rules of a ledger to the balances of its
+// categories at the end of a month through api.close_month, and returns the
+// transactions it recorded. Period uses the YYYYMM format. Months close
+// once, in order, and only once they are over; ErrDateOutOfRange is
+// returned otherwise.
+func (c *Client) CloseMonth(ctx context.Context, ledgerUUID, period string) ([]MonthCloseTransaction, error) {
+	rows, err := c.db.Query(ctx, "select * from api.close_month($1, $2)", ledgerUUID, period)
+	if err != nil {
+		return nil, wrapErr("close month", err)
+	}
+
+	recorded, err := pgx.CollectRows(rows, pgx.RowToStructByName[MonthCloseTransaction])
+	if err != nil {
+		return nil, wrapErr("close month", err)
+	}
+
+	return recorded, nil
+}
+
+// GetClosedMonths returns the closed months of a ledger through
+// api.get_closed_months, newest first.
+func (c *Client) GetClosedMonths(ctx context.Context, ledgerUUID string) ([]ClosedMonth, error) {
+	rows, err := c.db.Query(ctx, "select * from api.get_closed_months($1)", ledgerUUID)
+	if err != nil {
+		return nil, wrapErr("get closed months", err)
+	}
+
+	months, err := pgx.CollectRows(rows, pgx.RowToStructByName[ClosedMonth])
+	if err != nil {
+		return nil, wrapErr("get closed months", err)
+	}
+
+	return months, nil
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CreatePayeeParams holds the arguments of api.create_payee.
+type CreatePayeeParams struct {
+	LedgerUUID string
+	Name       string
+	// DefaultCategoryUUID is optional; it is used when a transaction names
+	// the payee but no category.
+	DefaultCategoryUUID string
+}
+
+// CreatePayee adds a payee to a ledger through api.create_payee and returns
+// its UUID. Names are unique per ledger regardless of case.
+func (c *Client) CreatePayee(ctx context.Context, params CreatePayeeParams) (string, error) {
+	var payeeUUID string
+	err := c.db.QueryRow(
+		ctx,
+		"select api.create_payee($1, $2, $3)",
+		params.LedgerUUID, params.Name, nullString(params.DefaultCategoryUUID),
+	).Scan(&payeeUUID)
+	if err != nil {
+		return "", wrapErr("create payee", err)
+	}
+
+	return payeeUUID, nil
+}
+
+// RenamePayee renames a payee through api.rename_payee. It fails with
+// ErrDuplicateName when the ledger has a payee by that name; see MergePayees.
+func (c *Client) RenamePayee(ctx context.Context, payeeUUID, name string) error {
+	if _, err := c.db.Exec(ctx, "select api.rename_payee($1, $2)", payeeUUID, name); err != nil {
+		return wrapErr("rename payee", err)
+	}
+
+	return nil
+}
+
+// SetPayeeCategory sets the default category of a payee through
+// api.set_payee_category. An empty categoryUUID clears it.
+func (c *Client) SetPayeeCategory(ctx context.Context, payeeUUID, categoryUUID string) error {
+	_, err := c.db.Exec(ctx, "select api.set_payee_category($1, $2)", payeeUUID, nullString(categoryUUID))
+	if err != nil {
+		return wrapErr("set payee category", err)
+	}
+
+	return nil
+}
+
+// MergePayees moves the transactions of the source payee to the target
+// through api.merge_payees and deletes the source. It returns the number of
+// transactions moved.
+func (c *Client) MergePayees(ctx context.Context, sourceUUID, targetUUID string) (int, error) {
+	var moved int
+	if err := c.db.QueryRow(ctx, "select api.merge_payees($1, $2)", sourceUUID, targetUUID).Scan(&moved); err != nil {
+		return 0, wrapErr("merge payees", err)
+	}
+
+	return moved, nil
+}
+
+// GetPayees returns the payees of a ledger by name with the totals of their
+// transactions through api.get_payees.
+func (c *Client) GetPayees(ctx context.Context, ledgerUUID string) ([]Payee, error) {
+	rows, err := c.db.Query(ctx, "select * from api.get_payees($1)", ledgerUUID)
+	if err != nil {
+		return nil, wrapErr("get payees", err)
+	}
+
+	payees, err := pgx.CollectRows(rows, pgx.RowToStructByName[Payee])
+	if err != nil {
+		return nil, wrapErr("get payees", err)
+	}
+
+	return payees, nil
+}
+
+// BackfillPayees gives the transactions of a ledger recorded without a payee
+// the payee their description names once normalized, so "SQ *BLUE BOTTLE
+// #0123" is linked to Blue Bottle, through api.backfill_payees. Missing
+// payees are created. It returns the number of transactions linked.
+func (c *Client) BackfillPayees(ctx context.Context, ledgerUUID string) (int, error) {
+	var linked int
+	if err := c.db.QueryRow(ctx, "select api.backfill_payees($1)", ledgerUUID).Scan(&linked); err != nil {
+		return 0, wrapErr("backfill payees", err)
+	}
+
+	return linked, nil
+}
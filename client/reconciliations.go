@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ReconcileParams holds the arguments of api.reconcile_account and
+// api.get_reconciliation_preview.
+type ReconcileParams struct {
+	// AccountUUID is the bank account or credit card to reconcile.
+	AccountUUID string
+	// StatementBalance is the ending balance of the statement in cents; what
+	// is owed for a credit card.
+	StatementBalance int64
+	// StatementDate is the last day of the statement; today when zero.
+	StatementDate time.Time
+	// AdjustmentCategoryUUID receives the adjustment for a difference; Income
+	// is used when empty. It is ignored by PreviewReconciliation.
+	AdjustmentCategoryUUID string
+}
+
+// statementDate returns the statement date argument, leaving a zero date to
+// the database.
+func (p ReconcileParams) statementDate() *time.Time {
+	if p.StatementDate.IsZero() {
+		return nil
+	}
+	return &p.StatementDate
+}
+
+// PreviewReconciliation compares the cleared balance of an account with a
+// statement through api.get_reconciliation_preview, without changing
+// anything.
+func (c *Client) PreviewReconciliation(ctx context.Context, params ReconcileParams) (*ReconciliationPreview, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select * from api.get_reconciliation_preview($1, $2, coalesce($3::date, current_date))",
+		params.AccountUUID, params.StatementBalance, params.statementDate(),
+	)
+	if err != nil {
+		return nil, wrapErr("preview reconciliation", err)
+	}
+
+	preview, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[ReconciliationPreview])
+	if err != nil {
+		return nil, wrapErr("preview reconciliation", err)
+	}
+
+	return &preview, nil
+}
+
+// ReconcileAccount reconciles an account with a statement through
+// api.reconcile_account and returns the UUID of the reconciliation. A
+// difference between the statement and the cleared balance is recorded as
+// an adjustment, and every posted transaction up to the statement date is
+// locked against CorrectTransaction and DeleteTransaction.
+func (c *Client) ReconcileAccount(ctx context.Context, params ReconcileParams) (string, error) {
+	var reconciliationUUID string
+	err := c.db.QueryRow(
+		ctx,
+		"select api.reconcile_account($1, $2, coalesce($3::date, current_date), $4)",
+		params.AccountUUID, params.StatementBalance, params.statementDate(),
+		nullString(params.AdjustmentCategoryUUID),
+	).Scan(&reconciliationUUID)
+	if err != nil {
+		return "", wrapErr("reconcile account", err)
+	}
+
+	return reconciliationUUID, nil
+}
+
+// GetReconciliations returns the reconciliations of an account through
+// api.get_reconciliations, latest first.
+func (c *Client) GetReconciliations(ctx context.Context, accountUUID string) ([]Reconciliation, error) {
+	rows, err := c.db.Query(ctx, "select * from api.get_reconciliations($1)", accountUUID)
+	if err != nil {
+		return nil, wrapErr("get reconciliations", err)
+	}
+
+	reconciliations, err := pgx.CollectRows(rows, pgx.RowToStructByName[Reconciliation])
+	if err != nil {
+		return nil, wrapErr("get reconciliations", err)
+	}
+
+	return reconciliations, nil
+}
@@ -0,0 +1,226 @@
+package client
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GetBudgetStatus returns budgeted, activity and balance per category through
+// api.get_budget_status. Period is optional and uses the YYYYMM format
+// (e.g. "202508"); an empty period reports all-time figures.
+func (c *Client) GetBudgetStatus(ctx context.Context, ledgerUUID, period string) ([]BudgetStatus, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select * from api.get_budget_status($1, $2)",
+		ledgerUUID, nullString(period),
+	)
+	if err != nil {
+		return nil, wrapErr("get budget status", err)
+	}
+
+	status, err := pgx.CollectRows(rows, pgx.RowToStructByName[BudgetStatus])
+	if err != nil {
+		return nil, wrapErr("get budget status", err)
+	}
+
+	return status, nil
+}
+
+// GetBudgetTotals returns the ledger-wide totals of api.get_budget_totals.
+// Period follows the same rules as GetBudgetStatus.
+func (c *Client) GetBudgetTotals(ctx context.Context, ledgerUUID, period string) (*BudgetTotals, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select * from api.get_budget_totals($1, $2)",
+		ledgerUUID, nullString(period),
+	)
+	if err != nil {
+		return nil, wrapErr("get budget totals", err)
+	}
+
+	totals, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[BudgetTotals])
+	if err != nil {
+		return nil, wrapErr("get budget totals", err)
+	}
+
+	return &totals, nil
+}
+
+// AccountTransactionsParams holds the arguments of
+// api.get_account_transactions_page.
+type AccountTransactionsParams struct {
+	AccountUUID string
+	// Status keeps only pending or only posted transactions; all are
+	// returned when it is empty.
+	Status TransactionStatus
+	// Tags keeps only the transactions carrying every tag given.
+	Tags []string
+	// After keeps the transactions older than the one with this UUID, e.g.
+	// the last of the previous page, and Before the newer ones. A split is
+	// named by the UUID of the split.
+	After  string
+	Before string
+	// Limit is the size of a page, next to the cursor; all transactions are
+	// returned when it is zero.
+	Limit int
+	// WithTotal sets AccountTransactionsPage.Total.
+	WithTotal bool
+}
+
+// AccountTransactionsPage is a page of the history of an account.
+type AccountTransactionsPage struct {
+	Transactions []AccountTransaction `json:"transactions"`
+	// Total counts the transactions matching Status and Tags on every page.
+	// It is nil unless AccountTransactionsParams.WithTotal is set.
+	Total *int64 `json:"total,omitempty"`
+}
+
+// GetAccountTransactions returns the history of an account, newest first,
+// with the running balance after each transaction. The legs of a split
+// transaction are returned as one row.
+func (c *Client) GetAccountTransactions(ctx context.Context, accountUUID string) ([]AccountTransaction, error) {
+	return c.ListAccountTransactions(ctx, AccountTransactionsParams{AccountUUID: accountUUID})
+}
+
+// ListAccountTransactions is GetAccountTransactions with filters.
+func (c *Client) ListAccountTransactions(ctx context.Context, params AccountTransactionsParams) ([]AccountTransaction, error) {
+	page, err := c.GetAccountTransactionsPage(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Transactions, nil
+}
+
+// GetAccountTransactionsPage returns a page of the history of an account,
+// newest first, paged by keyset through params.After and params.Before.
+// Running balances are those of the whole history on every page.
+func (c *Client) GetAccountTransactionsPage(ctx context.Context, params AccountTransactionsParams) (*AccountTransactionsPage, error) {
+	var limit *int
+	if params.Limit != 0 {
+		limit = &params.Limit
+	}
+
+	rows, err := c.db.Query(
+		ctx,
+		"select date, category, description, type, amount, running_balance, uuid, "+
+			"coalesce(category_uuid, '') as category_uuid, split, status, reconciled, payee, tags, total_count "+
+			"from api.get_account_transactions_page($1, $2, $3, $4, $5, $6, $7)",
+		params.AccountUUID, nullString(string(params.Status)), params.Tags,
+		nullString(params.After), nullString(params.Before), limit, params.WithTotal,
+	)
+	if err != nil {
+		return nil, wrapErr("get account transactions", err)
+	}
+
+	// total_count repeats the same total on every row
+	type accountTransactionRow struct {
+		AccountTransaction
+		TotalCount *int64 `db:"total_count"`
+	}
+	results, err := pgx.CollectRows(rows, pgx.RowToStructByName[accountTransactionRow])
+	if err != nil {
+		return nil, wrapErr("get account transactions", err)
+	}
+
+	page := &AccountTransactionsPage{Transactions: make([]AccountTransaction, len(results))}
+	for i, r := range results {
+		page.Transactions[i] = r.AccountTransaction
+	}
+	if len(results) > 0 {
+		page.Total = results[0].TotalCount
+	} else if params.WithTotal {
+		// an empty page has no row to carry the total, but past a cursor
+		// the first page of the history still does
+		page.Total = new(int64)
+		if params.After != "" || params.Before != "" {
+			first, err := c.GetAccountTransactionsPage(
+				ctx, AccountTransactionsParams{
+					AccountUUID: params.AccountUUID,
+					Status:      params.Status,
+					Tags:        params.Tags,
+					Limit:       1,
+					WithTotal:   true,
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+			page.Total = first.Total
+		}
+	}
+
+	return page, nil
+}
+
+// GetAccountBalance returns the working balance of an account from its
+// latest balance snapshot, counting pending transactions.
+func (c *Client) GetAccountBalance(ctx context.Context, accountUUID string) (int64, error) {
+	return c.getAccountBalance(ctx, accountUUID, false)
+}
+
+// GetAccountClearedBalance returns the balance of an account counting posted
+// transactions only, which is what the bank shows.
+func (c *Client) GetAccountClearedBalance(ctx context.Context, accountUUID string) (int64, error) {
+	return c.getAccountBalance(ctx, accountUUID, true)
+}
+
+func (c *Client) getAccountBalance(ctx context.Context, accountUUID string, cleared bool) (int64, error) {
+	var balance int64
+	err := c.db.QueryRow(ctx, "select api.get_account_balance($1, $2)", accountUUID, cleared).Scan(&balance)
+	if err != nil {
+		return 0, wrapErr("get account balance", err)
+	}
+
+	return balance, nil
+}
+
+// GetAccountBalanceHistory returns up to limit balance snapshots of an
+// account, newest first.
+func (c *Client) GetAccountBalanceHistory(ctx context.Context, accountUUID string, limit int) ([]BalanceHistoryEntry, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select * from api.get_account_balance_history($1, $2)",
+		accountUUID, limit,
+	)
+	if err != nil {
+		return nil, wrapErr("get account balance history", err)
+	}
+
+	history, err := pgx.CollectRows(rows, pgx.RowToStructByName[BalanceHistoryEntry])
+	if err != nil {
+		return nil, wrapErr("get account balance history", err)
+	}
+
+	return history, nil
+}
+
+// GetLedgerBalances returns the working and cleared balance of every account
+// in a ledger.
+func (c *Client) GetLedgerBalances(ctx context.Context, ledgerUUID string) ([]LedgerBalance, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select * from api.get_ledger_balances($1)",
+		ledgerUUID,
+	)
+	if err != nil {
+		return nil, wrapErr("get ledger balances", err)
+	}
+
+	balances, err := pgx.CollectRows(rows, pgx.RowToStructByName[LedgerBalance])
+	if err != nil {
+		return nil, wrapErr("get ledger balances", err)
+	}
+
+	return balances, nil
+}
+
+// RebuildLedgerBalanceSnapshots recomputes every balance snapshot of a ledger.
+func (c *Client) RebuildLedgerBalanceSnapshots(ctx context.Context, ledgerUUID string) error {
+	if _, err := c.db.Exec(ctx, "select api.rebuild_ledger_balance_snapshots($1)", ledgerUUID); err != nil {
+		return wrapErr("rebuild balance snapshots", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// AddCategoryRuleParams holds the arguments of api.add_category_rule. The
+// conditions are optional; a rule needs at least one of CategoryUUID,
+// SetDescription and Tags.
+type AddCategoryRuleParams struct {
+	LedgerUUID string
+	Name       string
+	// Priority orders the rules; lower priorities are tried first.
+	Priority int
+
+	// DescriptionPattern is a case-insensitive regular expression.
+	DescriptionPattern string
+	// MinAmount and MaxAmount bound the amount in cents, inclusive.
+	MinAmount *int64
+	MaxAmount *int64
+	// AccountUUID is the bank account or credit card of the transaction.
+	AccountUUID string
+	// Weekdays are ISO weekdays, 1 (Monday) to 7 (Sunday).
+	Weekdays []int
+
+	CategoryUUID   string
+	SetDescription string
+	Tags           []string
+}
+
+// AddCategoryRule adds a rule to a ledger through api.add_category_rule and
+// returns its UUID. Transactions recorded without a category from then on
+// take the category of the first rule they match.
+func (c *Client) AddCategoryRule(ctx context.Context, params AddCategoryRuleParams) (string, error) {
+	var ruleUUID string
+	err := c.db.QueryRow(
+		ctx,
+		"select api.add_category_rule($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
+		params.LedgerUUID, params.Name, nullString(params.CategoryUUID), nullString(params.DescriptionPattern),
+		params.MinAmount, params.MaxAmount, nullString(params.AccountUUID), params.Weekdays,
+		nullString(params.SetDescription), params.Tags, params.Priority,
+	).Scan(&ruleUUID)
+	if err != nil {
+		return "", wrapErr("add category rule", err)
+	}
+
+	return ruleUUID, nil
+}
+
+// DeleteCategoryRule removes a rule through api.delete_category_rule. It
+// fails with ErrRuleNotFound when there is no such rule.
+func (c *Client) DeleteCategoryRule(ctx context.Context, ruleUUID string) error {
+	if _, err := c.db.Exec(ctx, "select api.delete_category_rule($1)", ruleUUID); err != nil {
+		return wrapErr("delete category rule", err)
+	}
+
+	return nil
+}
+
+// GetCategoryRules returns the rules of a ledger in the order they are tried
+// through api.get_category_rules.
+func (c *Client) GetCategoryRules(ctx context.Context, ledgerUUID string) ([]CategoryRule, error) {
+	rows, err := c.db.Query(ctx, "select * from api.get_category_rules($1)", ledgerUUID)
+	if err != nil {
+		return nil, wrapErr("get category rules", err)
+	}
+
+	rules, err := pgx.CollectRows(rows, pgx.RowToStructByName[CategoryRule])
+	if err != nil {
+		return nil, wrapErr("get category rules", err)
+	}
+
+	return rules, nil
+}
+
+// ApplyCategoryRules runs the rules of a ledger over its Unassigned
+// transactions through api.apply_category_rules. Every match is corrected
+// with api.correct_transaction, so the transaction log keeps the original.
+func (c *Client) ApplyCategoryRules(ctx context.Context, ledgerUUID string) ([]RuleApplication, error) {
+	rows, err := c.db.Query(ctx, "select * from api.apply_category_rules($1)", ledgerUUID)
+	if err != nil {
+		return nil, wrapErr("apply category rules", err)
+	}
+
+	applied, err := pgx.CollectRows(rows, pgx.RowToStructByName[RuleApplication])
+	if err != nil {
+		return nil, wrapErr("apply category rules", err)
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateScheduleParams holds the arguments of api.create_schedule.
+type CreateScheduleParams struct {
+	LedgerUUID string
+	// Name identifies the schedule within the ledger, e.g. "Rent".
+	Name string
+	// Description is given to the recorded transactions; Name is used when empty.
+	Description string
+	Type        TransactionType
+	// Amount is expressed in cents and must be positive.
+	Amount int64
+	// AccountUUID is the bank account or credit card the money moves through.
+	AccountUUID string
+	// CategoryUUID is optional; the Unassigned category is used when empty.
+	CategoryUUID string
+	Frequency    Frequency
+	// Every is the number of months or weeks between occurrences; 0 means 1.
+	Every int
+	// DayOfMonth applies to monthly schedules; 0 means the day of StartDate.
+	DayOfMonth int
+	// StartDate is the first day an occurrence may fall on.
+	StartDate time.Time
+	// EndDate is optional; the schedule repeats forever when it is zero.
+	EndDate time.Time
+}
+
+// CreateSchedule creates a recurring transaction through api.create_schedule
+// and returns the UUID of the schedule. Nothing is recorded until
+// RunSchedules reaches its first occurrence.
+func (c *Client) CreateSchedule(ctx context.Context, params CreateScheduleParams) (string, error) {
+	every := params.Every
+	if every == 0 {
+		every = 1
+	}
+	var dayOfMonth *int
+	if params.DayOfMonth != 0 {
+		dayOfMonth = &params.DayOfMonth
+	}
+	var endDate *time.Time
+	if !params.EndDate.IsZero() {
+		endDate = &params.EndDate
+	}
+
+	var scheduleUUID string
+	err := c.db.QueryRow(
+		ctx,
+		"select api.create_schedule($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+		params.LedgerUUID, params.Name, string(params.Type), params.Amount,
+		params.AccountUUID, string(params.Frequency), params.StartDate,
+		nullString(params.CategoryUUID), every, dayOfMonth, endDate,
+		nullString(params.Description),
+	).Scan(&scheduleUUID)
+	if err != nil {
+		return "", wrapErr("create schedule", err)
+	}
+
+	return scheduleUUID, nil
+}
+
+// GetSchedules returns the schedules of a ledger through api.get_schedules,
+// ordered by name.
+func (c *Client) GetSchedules(ctx context.Context, ledgerUUID string) ([]Schedule, error) {
+	rows, err := c.db.Query(ctx, "select * from api.get_schedules($1)", ledgerUUID)
+	if err != nil {
+		return nil, wrapErr("get schedules", err)
+	}
+
+	schedules, err := pgx.CollectRows(rows, pgx.RowToStructByName[Schedule])
+	if err != nil {
+		return nil, wrapErr("get schedules", err)
+	}
+
+	return schedules, nil
+}
+
+// DeleteSchedule deletes a schedule through api.delete_schedule. The
+// transactions it already recorded are kept.
+func (c *Client) DeleteSchedule(ctx context.Context, scheduleUUID string) error {
+	if _, err := c.db.Exec(ctx, "select api.delete_schedule($1)", scheduleUUID); err != nil {
+		return wrapErr("delete schedule", err)
+	}
+
+	return nil
+}
+
+// GetUpcomingTransactions returns the occurrences of the schedules of a
+// ledger that are not recorded yet, up to and including through, in date
+// order.
+func (c *Client) GetUpcomingTransactions(ctx context.Context, ledgerUUID string, through time.Time) ([]UpcomingTransaction, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select * from api.get_upcoming_transactions($1, $2)",
+		ledgerUUID, through,
+	)
+	if err != nil {
+		return nil, wrapErr("get upcoming transactions", err)
+	}
+
+	upcoming, err := pgx.CollectRows(rows, pgx.RowToStructByName[UpcomingTransaction])
+	if err != nil {
+		return nil, wrapErr("get upcoming transactions", err)
+	}
+
+	return upcoming, nil
+}
+
+// RunSchedules records every occurrence due up to and including through as
+// a pending transaction through api.run_schedules, for one ledger or, when
+// ledgerUUID is empty, for every ledger of the user. Occurrences are
+// recorded once: running again returns only what became due since.
+func (c *Client) RunSchedules(ctx context.Context, ledgerUUID string, through time.Time) ([]ScheduledTransaction, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select * from api.run_schedules($1, $2)",
+		nullString(ledgerUUID), through,
+	)
+	if err != nil {
+		return nil, wrapErr("run schedules", err)
+	}
+
+	recorded, err := pgx.CollectRows(rows, pgx.RowToStructByName[ScheduledTransaction])
+	if err != nil {
+		return nil, wrapErr("run schedules", err)
+	}
+
+	return recorded, nil
+}
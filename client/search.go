@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SearchParams holds the arguments of api.search_transactions. Every field
+// but LedgerUUID is optional.
+type SearchParams struct {
+	LedgerUUID string
+	// Query matches descriptions by words, word prefixes or any fragment,
+	// and payee names by words.
+	Query string
+	// MinAmount and MaxAmount bound the amount in cents, inclusive.
+	MinAmount *int64
+	MaxAmount *int64
+	// StartDate and EndDate bound the date, inclusive.
+	StartDate time.Time
+	EndDate   time.Time
+	// AccountUUID keeps the transactions of a bank account or credit card,
+	// on either side of transfers.
+	AccountUUID  string
+	CategoryUUID string
+	Status       TransactionStatus
+	// MetadataKeys keeps the transactions whose metadata has every key,
+	// e.g. "fitid" for imported transactions.
+	MetadataKeys []string
+	// After is the cursor of the previous page, see SearchPage.NextCursor.
+	After string
+	// Limit is the size of a page; 50 when zero.
+	Limit int
+}
+
+// SearchPage is a page of search results.
+type SearchPage struct {
+	Transactions []SearchResult `json:"transactions"`
+	// NextCursor is passed as SearchParams.After to get the next page. It is
+	// empty on the last page; a full page may still be followed by an empty
+	// one.
+	NextCursor string `json:"next_cursor"`
+}
+
+// Search finds the transactions of a ledger matching a query and filters
+// through api.search_transactions, newest first. Corrected and deleted
+// transactions and their reversals are left out.
+func (c *Client) Search(ctx context.Context, params SearchParams) (*SearchPage, error) {
+	limit := params.Limit
+	if limit == 0 {
+		limit = 50
+	}
+	var startDate, endDate *time.Time
+	if !params.StartDate.IsZero() {
+		startDate = &params.StartDate
+	}
+	if !params.EndDate.IsZero() {
+		endDate = &params.EndDate
+	}
+	keys := params.MetadataKeys
+	if len(keys) == 0 {
+		keys = nil
+	}
+
+	rows, err := c.db.Query(
+		ctx,
+		"select * from api.search_transactions($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+		params.LedgerUUID, nullString(params.Query), params.MinAmount, params.MaxAmount, startDate, endDate,
+		nullString(params.AccountUUID), nullString(params.CategoryUUID), nullString(string(params.Status)),
+		keys, nullString(params.After), limit,
+	)
+	if err != nil {
+		return nil, wrapErr("search transactions", err)
+	}
+
+	results, err := pgx.CollectRows(rows, pgx.RowToStructByName[SearchResult])
+	if err != nil {
+		return nil, wrapErr("search transactions", err)
+	}
+
+	page := &SearchPage{Transactions: results}
+	if len(results) == limit {
+		page.NextCursor = results[len(results)-1].UUID
+	}
+
+	return page, nil
+}
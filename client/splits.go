@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// AddSplitTransactionParams holds the arguments of api.add_split_transaction.
+type AddSplitTransactionParams struct {
+	LedgerUUID  string
+	Date        time.Time
+	Description string
+	Type        TransactionType
+	// AccountUUID is the bank account or credit card the money moves through.
+	AccountUUID string
+	// Splits share the transaction between categories; at least two are
+	// needed. Their amounts are in cents and add up to the posting.
+	Splits []Split
+}
+
+// AddSplitTransaction records one posting shared by several categories
+// through api.add_split_transaction and returns the UUID of the split. Each
+// split is stored as its own transaction, so budget reports see every
+// category, while GetAccountTransactions shows the posting as one row.
+func (c *Client) AddSplitTransaction(ctx context.Context, params AddSplitTransactionParams) (string, error) {
+	splits, err := splitsJSON(params.Splits)
+	if err != nil {
+		return "", wrapErr("add split transaction", err)
+	}
+
+	var splitUUID string
+	err = c.db.QueryRow(
+		ctx,
+		"select api.add_split_transaction($1, $2, $3, $4, $5, $6)",
+		params.LedgerUUID, params.Date, params.Description, string(params.Type),
+		params.AccountUUID, splits,
+	).Scan(&splitUUID)
+	if err != nil {
+		return "", wrapErr("add split transaction", err)
+	}
+
+	return splitUUID, nil
+}
+
+// CorrectSplitTransactionParams holds the arguments of
+// api.correct_split_transaction.
+type CorrectSplitTransactionParams struct {
+	SplitUUID   string
+	Type        TransactionType
+	AccountUUID string
+	Date        time.Time
+	Description string
+	Splits      []Split
+	// Reason is stored in the transaction log; the database default is used when empty.
+	Reason string
+	// Override allows correcting a split transaction locked by a reconciliation.
+	Override bool
+}
+
+// CorrectSplitTransaction reverses every split of a split transaction and
+// records the corrected splits under a new split through
+// api.correct_split_transaction. It returns the UUID of the new split.
+func (c *Client) CorrectSplitTransaction(ctx context.Context, params CorrectSplitTransactionParams) (string, error) {
+	splits, err := splitsJSON(params.Splits)
+	if err != nil {
+		return "", wrapErr("correct split transaction", err)
+	}
+
+	args := []any{
+		params.SplitUUID, string(params.Type), params.AccountUUID, params.Date,
+		params.Description, splits,
+	}
+	query := "select api.correct_split_transaction($1, $2, $3, $4, $5, $6"
+	if params.Reason != "" {
+		query += ", $7"
+		args = append(args, params.Reason)
+	}
+	if params.Override {
+		query += ", p_override => true"
+	}
+	query += ")"
+
+	var splitUUID string
+	if err := c.db.QueryRow(ctx, query, args...).Scan(&splitUUID); err != nil {
+		return "", wrapErr("correct split transaction", err)
+	}
+
+	return splitUUID, nil
+}
+
+// GetSplitTransaction returns a split transaction with its splits through
+// api.get_split_transaction. Deleting it goes through DeleteTransaction,
+// which reverses all of its splits.
+func (c *Client) GetSplitTransaction(ctx context.Context, splitUUID string) (*SplitTransaction, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select uuid, date, description, type, account_uuid, amount, leg_uuid, category_uuid, category, memo, leg_amount "+
+			"from api.get_split_transaction($1)",
+		splitUUID,
+	)
+	if err != nil {
+		return nil, wrapErr("get split transaction", err)
+	}
+	defer rows.Close()
+
+	var split SplitTransaction
+	for rows.Next() {
+		var s Split
+		err := rows.Scan(
+			&split.UUID, &split.Date, &split.Description, &split.Type, &split.AccountUUID, &split.Amount,
+			&s.UUID, &s.CategoryUUID, &s.Category, &s.Memo, &s.Amount,
+		)
+		if err != nil {
+			return nil, wrapErr("get split transaction", err)
+		}
+		split.Splits = append(split.Splits, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapErr("get split transaction", err)
+	}
+
+	return &split, nil
+}
+
+// splitsJSON encodes splits as the json array taken by the split functions.
+func splitsJSON(splits []Split) ([]byte, error) {
+	if splits == nil {
+		splits = []Split{}
+	}
+	return json.Marshal(splits)
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TagTransaction adds tags to a transaction through api.tag_transaction and
+// returns the tags it carries now. Tags are stored in lowercase and cannot
+// hold spaces or commas. The UUID of a split tags every leg.
+func (c *Client) TagTransaction(ctx context.Context, transactionUUID string, tags ...string) ([]string, error) {
+	var current []string
+	err := c.db.QueryRow(ctx, "select api.tag_transaction($1, $2)", transactionUUID, tags).Scan(&current)
+	if err != nil {
+		return nil, wrapErr("tag transaction", err)
+	}
+
+	return current, nil
+}
+
+// UntagTransaction removes tags from a transaction through
+// api.untag_transaction and returns the tags left. Without tags it removes
+// every tag.
+func (c *Client) UntagTransaction(ctx context.Context, transactionUUID string, tags ...string) ([]string, error) {
+	if len(tags) == 0 {
+		tags = nil
+	}
+
+	var current []string
+	err := c.db.QueryRow(ctx, "select api.untag_transaction($1, $2)", transactionUUID, tags).Scan(&current)
+	if err != nil {
+		return nil, wrapErr("untag transaction", err)
+	}
+
+	return current, nil
+}
+
+// TagSpendingParams holds the arguments of api.get_tag_spending.
+type TagSpendingParams struct {
+	LedgerUUID string
+	// Tags keeps only these tags; every tag is reported when it is empty.
+	Tags []string
+	// StartPeriod and EndPeriod bound the months reported as YYYYMM; either
+	// may be empty.
+	StartPeriod string
+	EndPeriod   string
+}
+
+// GetTagSpending returns what the transactions of a ledger spent per tag,
+// month and category through api.get_tag_spending, ordered by tag, month
+// and category name.
+func (c *Client) GetTagSpending(ctx context.Context, params TagSpendingParams) ([]TagSpending, error) {
+	tags := params.Tags
+	if len(tags) == 0 {
+		tags = nil
+	}
+
+	rows, err := c.db.Query(
+		ctx,
+		"select * from api.get_tag_spending($1, $2, $3, $4)",
+		params.LedgerUUID, tags, nullString(params.StartPeriod), nullString(params.EndPeriod),
+	)
+	if err != nil {
+		return nil, wrapErr("get tag spending", err)
+	}
+
+	spending, err := pgx.CollectRows(rows, pgx.RowToStructByName[TagSpending])
+	if err != nil {
+		return nil, wrapErr("get tag spending", err)
+	}
+
+	return spending, nil
+}
@@ -0,0 +1,289 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// AddTransactionParams holds the arguments of api.add_transaction.
+type AddTransactionParams struct {
+	LedgerUUID  string
+	Date        time.Time
+	Description string
+	Type        TransactionType
+	// Amount is expressed in cents and must be positive.
+	Amount int64
+	// AccountUUID is the bank account or credit card the money moves through.
+	AccountUUID string
+	// CategoryUUID is optional; when empty, the default category of Payee,
+	// then the first category rule the transaction matches chooses it, or the
+	// Unassigned category is used.
+	CategoryUUID string
+	// Payee is the name of the payee, created when the ledger has none by
+	// that name. It remembers CategoryUUID as its default category.
+	Payee string
+}
+
+// AddTransaction records a transaction through api.add_transaction and
+// returns the UUID of the new transaction.
+func (c *Client) AddTransaction(ctx context.Context, params AddTransactionParams) (string, error) {
+	var transactionUUID string
+	err := c.db.QueryRow(
+		ctx,
+		"select api.add_transaction($1, $2, $3, $4, $5, $6, $7, $8)",
+		params.LedgerUUID, params.Date, params.Description, string(params.Type),
+		params.Amount, params.AccountUUID, nullString(params.CategoryUUID), nullString(params.Payee),
+	).Scan(&transactionUUID)
+	if err != nil {
+		return "", wrapErr("add transaction", err)
+	}
+
+	return transactionUUID, nil
+}
+
+// ImportTransactionParams holds the arguments of api.import_transaction.
+// Payee is not used: imported transactions get their payees from
+// BackfillPayees.
+type ImportTransactionParams struct {
+	AddTransactionParams
+	// ExternalID is the bank's identifier for the transaction (OFX FITID). It
+	// is stored as metadata.fitid; a second import with the same ExternalID
+	// into the same account is skipped.
+	ExternalID string
+	// Metadata must be a JSON object; it is merged into the transaction metadata.
+	Metadata json.RawMessage
+}
+
+// ImportTransaction records a statement line through api.import_transaction.
+// It returns the UUID of the new transaction and true, or an empty UUID and
+// false when the account already holds a transaction with the same
+// ExternalID.
+func (c *Client) ImportTransaction(ctx context.Context, params ImportTransactionParams) (string, bool, error) {
+	var transactionUUID *string
+	err := c.db.QueryRow(
+		ctx,
+		"select api.import_transaction($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		params.LedgerUUID, params.Date, params.Description, string(params.Type),
+		params.Amount, params.AccountUUID, nullString(params.CategoryUUID),
+		nullString(params.ExternalID), params.Metadata,
+	).Scan(&transactionUUID)
+	if err != nil {
+		return "", false, wrapErr("import transaction", err)
+	}
+	if transactionUUID == nil {
+		return "", false, nil
+	}
+
+	return *transactionUUID, true, nil
+}
+
+// AddTransferParams holds the arguments of api.add_transfer.
+type AddTransferParams struct {
+	LedgerUUID string
+	// FromAccountUUID and ToAccountUUID are bank accounts or credit cards;
+	// the money leaves the first and enters the second.
+	FromAccountUUID string
+	ToAccountUUID   string
+	Amount          int64
+	Date            time.Time
+	// Memo is the description of the transfer; the database names both
+	// accounts when it is empty.
+	Memo string
+}
+
+// AddTransfer moves money between two bank or credit card accounts through
+// api.add_transfer and returns the UUID of the transfer. Budget categories
+// are left alone, except that paying a credit card from a bank account
+// spends what the payment category of the card holds.
+func (c *Client) AddTransfer(ctx context.Context, params AddTransferParams) (string, error) {
+	var transferUUID string
+	err := c.db.QueryRow(
+		ctx,
+		"select api.add_transfer($1, $2, $3, $4, $5, $6)",
+		params.LedgerUUID, params.FromAccountUUID, params.ToAccountUUID, params.Amount, params.Date,
+		nullString(params.Memo),
+	).Scan(&transferUUID)
+	if err != nil {
+		return "", wrapErr("add transfer", err)
+	}
+
+	return transferUUID, nil
+}
+
+// AssignToCategoryParams holds the arguments of api.assign_to_category.
+type AssignToCategoryParams struct {
+	LedgerUUID   string
+	Date         time.Time
+	Description  string
+	Amount       int64
+	CategoryUUID string
+}
+
+// AssignToCategory moves money from Income into a category through
+// api.assign_to_category.
+func (c *Client) AssignToCategory(ctx context.Context, params AssignToCategoryParams) (*Transaction, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select * from api.assign_to_category($1, $2, $3, $4, $5)",
+		params.LedgerUUID, params.Date, params.Description, params.Amount, params.CategoryUUID,
+	)
+	if err != nil {
+		return nil, wrapErr("assign to category", err)
+	}
+
+	transaction, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Transaction])
+	if err != nil {
+		return nil, wrapErr("assign to category", err)
+	}
+
+	return &transaction, nil
+}
+
+// MoveBetweenCategoriesParams holds the arguments of
+// api.move_between_categories.
+type MoveBetweenCategoriesParams struct {
+	LedgerUUID       string
+	FromCategoryUUID string
+	ToCategoryUUID   string
+	Amount           int64
+	Date             time.Time
+	// Memo is the description of the move; the database names both
+	// categories when it is empty.
+	Memo string
+	// Force allows the move to overdraw the source category.
+	Force bool
+}
+
+// MoveBetweenCategories moves money from one category to another through
+// api.move_between_categories. It fails with ErrInsufficientFunds when the
+// source holds less than Amount on Date, unless Force is set. The source is
+// returned as AccountUUID and the destination as CategoryUUID.
+func (c *Client) MoveBetweenCategories(ctx context.Context, params MoveBetweenCategoriesParams) (*Transaction, error) {
+	rows, err := c.db.Query(
+		ctx,
+		"select * from api.move_between_categories($1, $2, $3, $4, $5, $6, $7)",
+		params.LedgerUUID, params.FromCategoryUUID, params.ToCategoryUUID, params.Amount, params.Date,
+		nullString(params.Memo), params.Force,
+	)
+	if err != nil {
+		return nil, wrapErr("move between categories", err)
+	}
+
+	transaction, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Transaction])
+	if err != nil {
+		return nil, wrapErr("move between categories", err)
+	}
+
+	return &transaction, nil
+}
+
+// CorrectTransactionParams holds the arguments of api.correct_transaction.
+type CorrectTransactionParams struct {
+	TransactionUUID string
+	Type            TransactionType
+	AccountUUID     string
+	// CategoryUUID is optional; the default category of Payee, or else the
+	// Unassigned category, is used when empty.
+	CategoryUUID string
+	Amount       int64
+	Description  string
+	Date         time.Time
+	// Reason is stored in the transaction log; the database default is used when empty.
+	Reason string
+	// Override allows correcting a transaction locked by a reconciliation.
+	Override bool
+	// Payee replaces the payee of the transaction, which is kept when empty.
+	// Its default category is used when CategoryUUID is empty.
+	Payee string
+}
+
+// CorrectTransaction reverses a transaction and records a corrected copy of
+// it through api.correct_transaction. It returns the UUID of the correction.
+func (c *Client) CorrectTransaction(ctx context.Context, params CorrectTransactionParams) (string, error) {
+	args := []any{
+		params.TransactionUUID, string(params.Type), params.AccountUUID,
+		nullString(params.CategoryUUID), params.Amount, params.Description, params.Date,
+	}
+	query := "select api.correct_transaction($1, $2, $3, $4, $5, $6, $7"
+	if params.Reason != "" {
+		query += ", $8"
+		args = append(args, params.Reason)
+	}
+	if params.Override {
+		query += ", p_override => true"
+	}
+	if params.Payee != "" {
+		args = append(args, params.Payee)
+		query += fmt.Sprintf(", p_payee => $%d", len(args))
+	}
+	query += ")"
+
+	var correctionUUID string
+	if err := c.db.QueryRow(ctx, query, args...).Scan(&correctionUUID); err != nil {
+		return "", wrapErr("correct transaction", err)
+	}
+
+	return correctionUUID, nil
+}
+
+// DeleteTransaction cancels a transaction with a reversing entry through
+// api.delete_transaction. It returns the UUID of the reversal.
+// An empty reason falls back to the database default.
+func (c *Client) DeleteTransaction(ctx context.Context, transactionUUID, reason string) (string, error) {
+	return c.deleteTransaction(ctx, transactionUUID, reason, false)
+}
+
+// DeleteReconciledTransaction is DeleteTransaction for a transaction locked
+// by a reconciliation, which DeleteTransaction refuses with
+// ErrTransactionReconciled.
+func (c *Client) DeleteReconciledTransaction(ctx context.Context, transactionUUID, reason string) (string, error) {
+	return c.deleteTransaction(ctx, transactionUUID, reason, true)
+}
+
+func (c *Client) deleteTransaction(ctx context.Context, transactionUUID, reason string, override bool) (string, error) {
+	args := []any{transactionUUID}
+	query := "select api.delete_transaction($1"
+	if reason != "" {
+		query += ", $2"
+		args = append(args, reason)
+	}
+	if override {
+		query += ", p_override => true"
+	}
+	query += ")"
+
+	var reversalUUID string
+	if err := c.db.QueryRow(ctx, query, args...).Scan(&reversalUUID); err != nil {
+		return "", wrapErr("delete transaction", err)
+	}
+
+	return reversalUUID, nil
+}
+
+// PostTransaction marks a pending transaction as cleared by the bank through
+// api.post_transaction. A split transaction is posted as a whole. Posting a
+// transaction that is posted already does nothing.
+func (c *Client) PostTransaction(ctx context.Context, transactionUUID string) error {
+	if _, err := c.db.Exec(ctx, "select api.post_transaction($1)", transactionUUID); err != nil {
+		return wrapErr("post transaction", err)
+	}
+
+	return nil
+}
+
+// PostTransactions posts several transactions at once through
+// api.post_transactions; either all of them are posted or, on error, none.
+// It returns how many of them were pending.
+func (c *Client) PostTransactions(ctx context.Context, transactionUUIDs []string) (int, error) {
+	var posted int
+	err := c.db.QueryRow(ctx, "select api.post_transactions($1)", transactionUUIDs).Scan(&posted)
+	if err != nil {
+		return 0, wrapErr("post transactions", err)
+	}
+
+	return posted, nil
+}
@@ -0,0 +1,453 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AccountType is the accounting type of an account, see SPEC.md.
+type AccountType string
+
+const (
+	AccountTypeAsset     AccountType = "asset"
+	AccountTypeLiability AccountType = "liability"
+	AccountTypeEquity    AccountType = "equity"
+	AccountTypeRevenue   AccountType = "revenue"
+	AccountTypeExpense   AccountType = "expense"
+)
+
+// TransactionType is the direction of a transaction relative to the bank
+// account or credit card it is recorded against.
+type TransactionType string
+
+const (
+	Inflow  TransactionType = "inflow"
+	Outflow TransactionType = "outflow"
+)
+
+// TransactionStatus tells whether the bank has cleared a transaction.
+type TransactionStatus string
+
+const (
+	// StatusPending marks a transaction the bank has not cleared yet, such as
+	// one recorded by a schedule. It counts towards the working balance only.
+	StatusPending TransactionStatus = "pending"
+	// StatusPosted marks a cleared transaction. It counts towards both the
+	// working and the cleared balance.
+	StatusPosted TransactionStatus = "posted"
+)
+
+// Names of the special accounts created with every ledger.
+const (
+	IncomeCategory     = "Income"
+	OffBudgetCategory  = "Off-budget"
+	UnassignedCategory = "Unassigned"
+)
+
+// Ledger mirrors a row of the api.ledgers view.
+type Ledger struct {
+	UUID        string          `db:"uuid" json:"uuid"`
+	Name        string          `db:"name" json:"name"`
+	Description *string         `db:"description" json:"description"`
+	Metadata    json.RawMessage `db:"metadata" json:"metadata"`
+	UserData    string          `db:"user_data" json:"user_data"`
+}
+
+// Account mirrors a row of the api.accounts view.
+// Budget categories are accounts of type equity.
+type Account struct {
+	UUID        string          `db:"uuid" json:"uuid"`
+	Name        string          `db:"name" json:"name"`
+	Type        AccountType     `db:"type" json:"type"`
+	Description *string         `db:"description" json:"description"`
+	Metadata    json.RawMessage `db:"metadata" json:"metadata"`
+	UserData    string          `db:"user_data" json:"user_data"`
+	LedgerUUID  string          `db:"ledger_uuid" json:"ledger_uuid"`
+}
+
+// Transaction mirrors a row of the api.transactions view, as returned by
+// api.assign_to_category.
+type Transaction struct {
+	UUID         string          `db:"uuid" json:"uuid"`
+	Description  string          `db:"description" json:"description"`
+	Amount       int64           `db:"amount" json:"amount"`
+	Date         time.Time       `db:"date" json:"date"`
+	Metadata     json.RawMessage `db:"metadata" json:"metadata"`
+	LedgerUUID   string          `db:"ledger_uuid" json:"ledger_uuid"`
+	Type         *string         `db:"type" json:"type"`
+	AccountUUID  *string         `db:"account_uuid" json:"account_uuid"`
+	CategoryUUID *string         `db:"category_uuid" json:"category_uuid"`
+}
+
+// BudgetStatus is a row of api.get_budget_status.
+type BudgetStatus struct {
+	CategoryUUID string `db:"category_uuid" json:"category_uuid"`
+	CategoryName string `db:"category_name" json:"category_name"`
+	Budgeted     int64  `db:"budgeted" json:"budgeted"`
+	Activity     int64  `db:"activity" json:"activity"`
+	Balance      int64  `db:"balance" json:"balance"`
+}
+
+// BudgetTotals is the row returned by api.get_budget_totals.
+type BudgetTotals struct {
+	Income                       int64 `db:"income" json:"income"`
+	IncomeRemainingFromLastMonth int64 `db:"income_remaining_from_last_month" json:"income_remaining_from_last_month"`
+	Budgeted                     int64 `db:"budgeted" json:"budgeted"`
+	LeftToBudget                 int64 `db:"left_to_budget" json:"left_to_budget"`
+}
+
+// AccountTransaction is a row of api.get_account_transactions_page.
+type AccountTransaction struct {
+	Date           time.Time `db:"date" json:"date"`
+	Category       string    `db:"category" json:"category"`
+	Description    string    `db:"description" json:"description"`
+	Type           string    `db:"type" json:"type"`
+	Amount         int64     `db:"amount" json:"amount"`
+	RunningBalance int64     `db:"running_balance" json:"running_balance"`
+	// UUID identifies the transaction, e.g. for CorrectTransaction.
+	UUID string `db:"uuid" json:"uuid"`
+	// CategoryUUID is the other side of the transaction, named by Category.
+	// It is empty for a split spread over several categories, whose Category
+	// lists them all.
+	CategoryUUID string `db:"category_uuid" json:"category_uuid"`
+	// Split marks the legs of a split transaction shown as one row. UUID is
+	// then the UUID of the split, see GetSplitTransaction.
+	Split bool `db:"split" json:"split"`
+	// Status is pending while any leg of the transaction is.
+	Status TransactionStatus `db:"status" json:"status"`
+	// Reconciled marks a transaction locked by a reconciliation, see
+	// ReconcileAccount.
+	Reconciled bool `db:"reconciled" json:"reconciled"`
+	// Payee is the name of the payee of the transaction, if any.
+	Payee *string `db:"payee" json:"payee"`
+	// Tags are the tags of the transaction, or of every leg of a split, in
+	// lowercase and sorted; see TagTransaction.
+	Tags []string `db:"tags" json:"tags"`
+}
+
+// SplitTransaction is one posting on a bank account or credit card shared by
+// several categories, as returned by api.get_split_transaction.
+type SplitTransaction struct {
+	UUID        string          `json:"uuid"`
+	Date        time.Time       `json:"date"`
+	Description string          `json:"description"`
+	Type        TransactionType `json:"type"`
+	AccountUUID string          `json:"account_uuid"`
+	// Amount is the total of the splits.
+	Amount int64   `json:"amount"`
+	Splits []Split `json:"splits"`
+}
+
+// Split is the share of one category in a split transaction.
+type Split struct {
+	// UUID identifies the leg transaction; it is set on splits read back.
+	UUID string `json:"uuid,omitempty"`
+	// CategoryUUID is optional; the Unassigned category is used when empty.
+	CategoryUUID string `json:"category_uuid"`
+	// Category is the name of the category; it is set on splits read back.
+	Category string `json:"category,omitempty"`
+	Amount   int64  `json:"amount"`
+	// Memo describes the split; the description of the transaction is used
+	// when empty.
+	Memo string `json:"memo,omitempty"`
+}
+
+// Frequency is how a schedule repeats.
+type Frequency string
+
+const (
+	// FrequencyMonthly repeats on a day of the month; days past the end of a
+	// short month fall on its last day.
+	FrequencyMonthly Frequency = "monthly"
+	// FrequencyWeekly repeats on the weekday of the start date.
+	FrequencyWeekly Frequency = "weekly"
+	// FrequencyLastBusinessDay repeats on the last weekday of the month.
+	FrequencyLastBusinessDay Frequency = "last_business_day"
+)
+
+// Schedule is a row of api.get_schedules: a transaction recorded again and
+// again, such as rent or a paycheck.
+type Schedule struct {
+	UUID         string          `db:"uuid" json:"uuid"`
+	Name         string          `db:"name" json:"name"`
+	Description  *string         `db:"description" json:"description"`
+	Type         TransactionType `db:"type" json:"type"`
+	Amount       int64           `db:"amount" json:"amount"`
+	AccountUUID  string          `db:"account_uuid" json:"account_uuid"`
+	CategoryUUID *string         `db:"category_uuid" json:"category_uuid"`
+	Frequency    Frequency       `db:"frequency" json:"frequency"`
+	// Every is the number of months or weeks between occurrences.
+	Every      int        `db:"every" json:"every"`
+	DayOfMonth *int       `db:"day_of_month" json:"day_of_month"`
+	StartDate  time.Time  `db:"start_date" json:"start_date"`
+	EndDate    *time.Time `db:"end_date" json:"end_date"`
+	// NextDate is the first occurrence not recorded yet; it is nil once the
+	// schedule has ended.
+	NextDate *time.Time `db:"next_date" json:"next_date"`
+}
+
+// UpcomingTransaction is a row of api.get_upcoming_transactions: an
+// occurrence of a schedule that is not recorded yet.
+type UpcomingTransaction struct {
+	ScheduleUUID string          `db:"schedule_uuid" json:"schedule_uuid"`
+	Name         string          `db:"name" json:"name"`
+	Date         time.Time       `db:"date" json:"date"`
+	Description  string          `db:"description" json:"description"`
+	Type         TransactionType `db:"type" json:"type"`
+	Amount       int64           `db:"amount" json:"amount"`
+	AccountUUID  string          `db:"account_uuid" json:"account_uuid"`
+	CategoryUUID *string         `db:"category_uuid" json:"category_uuid"`
+}
+
+// ScheduledTransaction is a row of api.run_schedules: a pending transaction
+// recorded for an occurrence of a schedule.
+type ScheduledTransaction struct {
+	ScheduleUUID    string    `db:"schedule_uuid" json:"schedule_uuid"`
+	TransactionUUID string    `db:"transaction_uuid" json:"transaction_uuid"`
+	Date            time.Time `db:"date" json:"date"`
+}
+
+// BalanceHistoryEntry is a row of api.get_account_balance_history.
+type BalanceHistoryEntry struct {
+	TransactionID int64     `db:"transaction_id" json:"transaction_id"`
+	Balance       int64     `db:"balance" json:"balance"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+// LedgerBalance is a row of api.get_ledger_balances.
+type LedgerBalance struct {
+	AccountUUID string      `db:"account_uuid" json:"account_uuid"`
+	AccountName string      `db:"account_name" json:"account_name"`
+	AccountType AccountType `db:"account_type" json:"account_type"`
+	// CurrentBalance is the working balance, counting pending transactions.
+	CurrentBalance int64 `db:"current_balance" json:"current_balance"`
+	// ClearedBalance counts posted transactions only.
+	ClearedBalance int64 `db:"cleared_balance" json:"cleared_balance"`
+}
+
+// ImportAction records what happened to one line of an import batch.
+type ImportAction string
+
+const (
+	// ImportActionImported marks a line recorded as a new transaction.
+	ImportActionImported ImportAction = "imported"
+	// ImportActionSkipped marks a duplicate line that was left out.
+	ImportActionSkipped ImportAction = "skipped"
+	// ImportActionMerged marks a duplicate line whose details were merged
+	// into the existing transaction.
+	ImportActionMerged ImportAction = "merged"
+	// ImportActionForced marks a likely duplicate recorded anyway.
+	ImportActionForced ImportAction = "forced"
+)
+
+// ImportFingerprint is a row of api.find_import_fingerprints: the latest
+// recorded batch line of an account with a given fingerprint whose
+// transaction still stands.
+type ImportFingerprint struct {
+	Fingerprint     string       `db:"fingerprint" json:"fingerprint"`
+	Action          ImportAction `db:"action" json:"action"`
+	TransactionUUID *string      `db:"transaction_uuid" json:"transaction_uuid"`
+	BatchUUID       string       `db:"batch_uuid" json:"batch_uuid"`
+}
+
+// ImportCandidate is a row of api.find_import_candidates: an existing
+// transaction of the account an imported line may duplicate.
+type ImportCandidate struct {
+	UUID        string          `db:"uuid" json:"uuid"`
+	Date        time.Time       `db:"date" json:"date"`
+	Type        TransactionType `db:"type" json:"type"`
+	Amount      int64           `db:"amount" json:"amount"`
+	Description string          `db:"description" json:"description"`
+	Metadata    json.RawMessage `db:"metadata" json:"metadata"`
+}
+
+// ReconciliationPreview is the row of api.get_reconciliation_preview: how an
+// account compares with a statement before it is reconciled.
+type ReconciliationPreview struct {
+	StatementDate    time.Time `db:"statement_date" json:"statement_date"`
+	StatementBalance int64     `db:"statement_balance" json:"statement_balance"`
+	// ClearedBalance counts the posted transactions dated up to the statement.
+	ClearedBalance int64 `db:"cleared_balance" json:"cleared_balance"`
+	// Difference is StatementBalance minus ClearedBalance, the amount of the
+	// adjustment reconciling would record.
+	Difference int64 `db:"difference" json:"difference"`
+	// Transactions is the number of transactions reconciling would lock.
+	Transactions int `db:"transactions" json:"transactions"`
+}
+
+// Reconciliation is a row of api.get_reconciliations.
+type Reconciliation struct {
+	UUID             string    `db:"uuid" json:"uuid"`
+	StatementDate    time.Time `db:"statement_date" json:"statement_date"`
+	StatementBalance int64     `db:"statement_balance" json:"statement_balance"`
+	// ClearedBalance is the balance found before the adjustment.
+	ClearedBalance int64 `db:"cleared_balance" json:"cleared_balance"`
+	// AdjustmentUUID is the transaction recording the difference; nil when
+	// the account matched the statement.
+	AdjustmentUUID *string `db:"adjustment_uuid" json:"adjustment_uuid"`
+	// Transactions is the number of transactions the reconciliation locked.
+	Transactions int       `db:"transactions" json:"transactions"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
+
+// GoalType is the kind of goal a budget category has.
+type GoalType string
+
+const (
+	// GoalMonthlyFunding asks for the goal amount to be assigned every month.
+	GoalMonthlyFunding GoalType = "monthly_funding"
+	// GoalTargetBalance asks for a balance of the goal amount by a target
+	// date, spreading what is missing evenly over the months left.
+	GoalTargetBalance GoalType = "target_balance"
+	// GoalSpendingCap limits the spending of a month to the goal amount. It
+	// needs no funding.
+	GoalSpendingCap GoalType = "spending_cap"
+)
+
+// GoalProgress is a row of api.get_goal_progress: how a category with a goal
+// stands in a month.
+type GoalProgress struct {
+	CategoryUUID string     `db:"category_uuid" json:"category_uuid"`
+	CategoryName string     `db:"category_name" json:"category_name"`
+	GoalType     GoalType   `db:"goal_type" json:"goal_type"`
+	GoalAmount   int64      `db:"goal_amount" json:"goal_amount"`
+	TargetDate   *time.Time `db:"target_date" json:"target_date"`
+	Budgeted     int64      `db:"budgeted" json:"budgeted"`
+	Activity     int64      `db:"activity" json:"activity"`
+	// Balance is the balance of the category at the end of the month.
+	Balance int64 `db:"balance" json:"balance"`
+	// Needed is what the goal asks to assign in the month.
+	Needed int64 `db:"needed" json:"needed"`
+	// Underfunded is the part of Needed that is not assigned yet.
+	Underfunded int64 `db:"underfunded" json:"underfunded"`
+	// OverCap is how much a spending cap is exceeded.
+	OverCap int64 `db:"over_cap" json:"over_cap"`
+}
+
+// RolloverRule is what closing a month does with a category balance.
+type RolloverRule string
+
+const (
+	// RolloverKeep lets the balance roll over into the next month.
+	RolloverKeep RolloverRule = "keep"
+	// RolloverIncome moves leftovers back to Income, or covers overspending
+	// from Income, leaving less to budget next month.
+	RolloverIncome RolloverRule = "income"
+	// RolloverUnassigned covers overspending from Unassigned, tracking it as
+	// unfunded debt.
+	RolloverUnassigned RolloverRule = "unassigned"
+)
+
+// RolloverRules is the row of api.get_rollover_rules: how closing a month
+// treats the balances of the budget categories of a ledger.
+type RolloverRules struct {
+	// Leftover applies to positive balances: RolloverKeep or RolloverIncome.
+	Leftover RolloverRule `db:"leftover" json:"leftover"`
+	// CashOverspending applies to money overspent from bank accounts.
+	CashOverspending RolloverRule `db:"cash_overspending" json:"cash_overspending"`
+	// CreditOverspending applies to money overspent on credit cards.
+	CreditOverspending RolloverRule `db:"credit_overspending" json:"credit_overspending"`
+}
+
+// MonthCloseTransaction is a row of api.close_month: a transaction recorded
+// by applying a rollover rule to a category.
+type MonthCloseTransaction struct {
+	CategoryUUID string `db:"category_uuid" json:"category_uuid"`
+	CategoryName string `db:"category_name" json:"category_name"`
+	// Kind is "leftover", "cash_overspending" or "credit_overspending".
+	Kind            string `db:"kind" json:"kind"`
+	Amount          int64  `db:"amount" json:"amount"`
+	TransactionUUID string `db:"transaction_uuid" json:"transaction_uuid"`
+}
+
+// ClosedMonth is a row of api.get_closed_months.
+type ClosedMonth struct {
+	// Period is the month as YYYYMM.
+	Period   string    `db:"period" json:"period"`
+	ClosedAt time.Time `db:"closed_at" json:"closed_at"`
+	// Transactions is the number of transactions closing the month recorded.
+	Transactions int `db:"transactions" json:"transactions"`
+}
+
+// CategoryRule is a row of api.get_category_rules. Its conditions are
+// optional and all of them must hold for the rule to match a transaction.
+type CategoryRule struct {
+	UUID     string `db:"uuid" json:"uuid"`
+	Name     string `db:"name" json:"name"`
+	Priority int    `db:"priority" json:"priority"`
+	// DescriptionPattern is a case-insensitive regular expression.
+	DescriptionPattern *string `db:"description_pattern" json:"description_pattern"`
+	MinAmount          *int64  `db:"min_amount" json:"min_amount"`
+	MaxAmount          *int64  `db:"max_amount" json:"max_amount"`
+	AccountUUID        *string `db:"account_uuid" json:"account_uuid"`
+	// Weekdays are ISO weekdays, 1 (Monday) to 7 (Sunday).
+	Weekdays     []int   `db:"weekdays" json:"weekdays"`
+	CategoryUUID *string `db:"category_uuid" json:"category_uuid"`
+	CategoryName *string `db:"category_name" json:"category_name"`
+	// SetDescription replaces the description of the transactions matched.
+	SetDescription *string `db:"set_description" json:"set_description"`
+	// Tags are added to the metadata of the transactions matched.
+	Tags []string `db:"tags" json:"tags"`
+}
+
+// RuleApplication is a row of api.apply_category_rules: a transaction a rule
+// corrected.
+type RuleApplication struct {
+	TransactionUUID string `db:"transaction_uuid" json:"transaction_uuid"`
+	CorrectionUUID  string `db:"correction_uuid" json:"correction_uuid"`
+	RuleUUID        string `db:"rule_uuid" json:"rule_uuid"`
+	CategoryUUID    string `db:"category_uuid" json:"category_uuid"`
+}
+
+// Payee is a row of api.get_payees: who transactions are paid to or received
+// from, with the totals of its transactions.
+type Payee struct {
+	UUID string `db:"uuid" json:"uuid"`
+	Name string `db:"name" json:"name"`
+	// DefaultCategoryUUID is the category used when a transaction names the
+	// payee but no category.
+	DefaultCategoryUUID *string `db:"default_category_uuid" json:"default_category_uuid"`
+	DefaultCategoryName *string `db:"default_category_name" json:"default_category_name"`
+	Transactions        int     `db:"transactions" json:"transactions"`
+	// Outflow and Inflow are seen from the bank account or credit card.
+	Outflow  int64      `db:"outflow" json:"outflow"`
+	Inflow   int64      `db:"inflow" json:"inflow"`
+	LastDate *time.Time `db:"last_date" json:"last_date"`
+}
+
+// TagSpending is a row of api.get_tag_spending: what the transactions
+// carrying a tag spent from a category in a month.
+type TagSpending struct {
+	Tag string `db:"tag" json:"tag"`
+	// Period is the month as YYYYMM.
+	Period       string `db:"period" json:"period"`
+	CategoryUUID string `db:"category_uuid" json:"category_uuid"`
+	CategoryName string `db:"category_name" json:"category_name"`
+	// Spent is what was paid out of the category less what came back into
+	// it, in cents; inflows such as refunds make it negative.
+	Spent        int64 `db:"spent" json:"spent"`
+	Transactions int   `db:"transactions" json:"transactions"`
+}
+
+// SearchResult is a row of api.search_transactions.
+type SearchResult struct {
+	UUID        string    `db:"uuid" json:"uuid"`
+	Date        time.Time `db:"date" json:"date"`
+	Description string    `db:"description" json:"description"`
+	// Type and Amount are seen from the account, as in AccountTransaction.
+	Type   string `db:"type" json:"type"`
+	Amount int64  `db:"amount" json:"amount"`
+	// AccountUUID is the bank account or credit card of the transaction:
+	// the source of a transfer, or the category credited by an assignment.
+	AccountUUID string `db:"account_uuid" json:"account_uuid"`
+	AccountName string `db:"account_name" json:"account_name"`
+	// CategoryUUID is the other side of the transaction.
+	CategoryUUID string            `db:"category_uuid" json:"category_uuid"`
+	CategoryName string            `db:"category_name" json:"category_name"`
+	Payee        *string           `db:"payee" json:"payee"`
+	Status       TransactionStatus `db:"status" json:"status"`
+	// SplitUUID is the split the transaction is a leg of, if any.
+	SplitUUID *string         `db:"split_uuid" json:"split_uuid"`
+	Tags      []string        `db:"tags" json:"tags"`
+	Metadata  json.RawMessage `db:"metadata" json:"metadata"`
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// ErrNoUser is returned by WithUser when no user ID is given.
+	ErrNoUser = errors.New("user id is required")
+	// ErrUserMismatch is returned by WithUser when utils.get_user() does not
+	// report the requested user after setting it.
+	ErrUserMismatch = errors.New("user context mismatch")
+)
+
+// poolHookTimeout bounds the queries run by the pool hooks.
+const poolHookTimeout = 5 * time.Second
+
+// Beginner starts transactions. *pgxpool.Pool and *pgx.Conn satisfy it.
+type Beginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// WithUser runs fn in a transaction where app.current_user_id is userID.
+//
+// The setting is made with set_config(..., true), so it ends with the
+// transaction and can never be seen by the next borrower of a pooled
+// connection. Before fn runs, utils.get_user() is checked to return userID.
+// The transaction is committed when fn returns nil and rolled back otherwise.
+func WithUser(ctx context.Context, db Beginner, userID string, fn func(tx pgx.Tx) error) error {
+	if strings.TrimSpace(userID) == "" {
+		return ErrNoUser
+	}
+
+	return pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		// is_local = true limits the setting to this transaction
+		if _, err := tx.Exec(ctx, "select set_config('app.current_user_id', $1, true)", userID); err != nil {
+			return wrapErr("set user context", err)
+		}
+
+		var current string
+		if err := tx.QueryRow(ctx, "select utils.get_user()").Scan(&current); err != nil {
+			return wrapErr("verify user context", err)
+		}
+		if current != userID {
+			return fmt.Errorf("%w: expected %q, database reports %q", ErrUserMismatch, userID, current)
+		}
+
+		return fn(tx)
+	})
+}
+
+// ConfigurePool installs pgxpool hooks that keep a user context from leaking
+// between borrowers of a connection, even when some code sets
+// app.current_user_id at session level:
+//
+//   - AfterRelease resets the setting, and discards connections returned in
+//     the middle of a transaction.
+//   - BeforeAcquire refuses any connection that still carries a user.
+//
+// Hooks already present on cfg run first.
+func ConfigurePool(cfg *pgxpool.Config) {
+	afterRelease := cfg.AfterRelease
+	cfg.AfterRelease = func(conn *pgx.Conn) bool {
+		if afterRelease != nil && !afterRelease(conn) {
+			return false
+		}
+		// a connection released inside a transaction cannot be reset safely
+		if conn.PgConn().TxStatus() != 'I' {
+			return false
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), poolHookTimeout)
+		defer cancel()
+
+		_, err := conn.Exec(ctx, "reset app.current_user_id")
+		return err == nil
+	}
+
+	beforeAcquire := cfg.BeforeAcquire
+	cfg.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+		if beforeAcquire != nil && !beforeAcquire(ctx, conn) {
+			return false
+		}
+
+		var current *string
+		err := conn.QueryRow(ctx, "select nullif(current_setting('app.current_user_id', true), '')").Scan(&current)
+		return err == nil && current == nil
+	}
+}
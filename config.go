@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/j0lvera/pgbudget/client"
+	"github.com/jackc/pgx/v5"
+)
+
+// config is the optional configuration file of the budgeting commands.
+// Flags and environment variables take precedence over it.
+type config struct {
+	DSN    string `json:"dsn"`
+	User   string `json:"user"`
+	Ledger string `json:"ledger"`
+	Format string `json:"format"`
+}
+
+// defaultConfigPath returns config.json in the pgbudget directory of the
+// user configuration directory, e.g. ~/.config/pgbudget/config.json.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pgbudget", "config.json")
+}
+
+// loadConfig reads the configuration file at path. A missing file is only
+// an error when required is set, that is when the path was given
+// explicitly.
+func loadConfig(path string, required bool) (config, error) {
+	var cfg config
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// session holds the connection settings shared by the commands that work
+// on a user's budget. Each setting comes from its flag, then its
+// environment variable, then the configuration file.
+type session struct {
+	config string
+	dsn    string
+	user   string
+	ledger string
+	format string
+}
+
+// registerSessionFlags adds -config, -dsn and -user to fs.
+func registerSessionFlags(fs *flag.FlagSet) *session {
+	s := &session{}
+	fs.StringVar(&s.config, "config", "", "configuration file (default $PGBUDGET_CONFIG or "+defaultConfigPath()+")")
+	fs.StringVar(&s.dsn, "dsn", "", "PostgreSQL connection string (default $DATABASE_URL)")
+	fs.StringVar(&s.user, "user", "", "user to act as (default $PGBUDGET_USER)")
+	return s
+}
+
+// registerLedgerFlag adds -ledger to fs.
+func (s *session) registerLedgerFlag(fs *flag.FlagSet) {
+	fs.StringVar(&s.ledger, "ledger", "", "ledger UUID (default $PGBUDGET_LEDGER)")
+}
+
+// registerFormatFlag adds -format to fs.
+func (s *session) registerFormatFlag(fs *flag.FlagSet) {
+	fs.StringVar(&s.format, "format", "", "output format: table, json or csv (default $PGBUDGET_FORMAT or table)")
+}
+
+// resolve fills the settings left empty on the command line from the
+// environment and the configuration file, and checks that the connection
+// string and user are known.
+func (s *session) resolve() error {
+	path, required := s.config, s.config != ""
+	if path == "" {
+		path = os.Getenv("PGBUDGET_CONFIG")
+		required = path != ""
+	}
+	if path == "" {
+		path = defaultConfigPath()
+	}
+
+	cfg, err := loadConfig(path, required)
+	if err != nil {
+		return err
+	}
+
+	s.dsn = firstNonEmpty(s.dsn, dsnFromEnv(), cfg.DSN)
+	s.user = firstNonEmpty(s.user, os.Getenv("PGBUDGET_USER"), cfg.User)
+	s.ledger = firstNonEmpty(s.ledger, os.Getenv("PGBUDGET_LEDGER"), cfg.Ledger)
+	s.format = firstNonEmpty(s.format, os.Getenv("PGBUDGET_FORMAT"), cfg.Format, string(formatTable))
+
+	switch {
+	case s.dsn == "":
+		return errors.New("missing connection string: set -dsn, DATABASE_URL or dsn in the config file")
+	case s.user == "":
+		return errors.New("missing user: set -user, PGBUDGET_USER or user in the config file")
+	}
+	if _, err := parseFormat(s.format); err != nil {
+		return err
+	}
+	return nil
+}
+
+// requireLedger reports a missing ledger; call it after resolve.
+func (s *session) requireLedger() error {
+	if s.ledger == "" {
+		return errors.New("missing ledger: set -ledger, PGBUDGET_LEDGER or ledger in the config file")
+	}
+	return nil
+}
+
+// connect opens a connection with the session's connection string.
+func (s *session) connect(ctx context.Context) (*pgx.Conn, error) {
+	conn, err := pgx.Connect(ctx, s.dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	return conn, nil
+}
+
+// with connects to the database and runs fn as the session user in a
+// single transaction.
+func (s *session) with(ctx context.Context, fn func(c *client.Client) error) error {
+	conn, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	return client.WithUser(ctx, conn, s.user, func(tx pgx.Tx) error {
+		return fn(client.New(tx))
+	})
+}
+
+// print writes v in the session's output format; t is its tabular form.
+func (s *session) print(v any, t table) error {
+	f, err := parseFormat(s.format)
+	if err != nil {
+		return err
+	}
+	return write(os.Stdout, f, v, t)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
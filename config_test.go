@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	is_ "github.com/matryer/is"
+)
+
+func TestSessionResolve(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	err := os.WriteFile(path, []byte(`{"dsn": "postgres://file", "user": "file-user", "ledger": "file-ledger", "format": "csv"}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PGBUDGET_CONFIG", "")
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("PGBUDGET_USER", "env-user")
+	t.Setenv("PGBUDGET_LEDGER", "")
+	t.Setenv("PGBUDGET_FORMAT", "")
+
+	t.Run(
+		"Precedence", func(t *testing.T) {
+			is := is_.New(t)
+
+			s := &session{config: path, ledger: "flag-ledger"}
+			is.NoErr(s.resolve())
+			is.Equal(s.dsn, "postgres://file") // only the file sets it
+			is.Equal(s.user, "env-user")       // the environment beats the file
+			is.Equal(s.ledger, "flag-ledger")  // flags beat both
+			is.Equal(s.format, "csv")
+		},
+	)
+
+	t.Run(
+		"MissingFile", func(t *testing.T) {
+			is := is_.New(t)
+
+			// an explicit path must exist
+			s := &session{config: filepath.Join(dir, "missing.json")}
+			is.True(s.resolve() != nil)
+
+			// the default path may be missing
+			t.Setenv("XDG_CONFIG_HOME", dir)
+			t.Setenv("HOME", dir)
+			s = &session{dsn: "postgres://flag"}
+			is.NoErr(s.resolve())
+			is.Equal(s.format, "table")
+			is.True(s.requireLedger() != nil)
+		},
+	)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/j0lvera/pgbudget/client"
+	"github.com/j0lvera/pgbudget/importer/qif"
+)
+
+const exportUsage = `Usage: pgbudget export <format> [flags]
+
+Formats:
+  qif   Quicken Interchange Format
+
+Run "pgbudget export <format> -h" for the flags of a format.
+`
+
+const exportQIFUsage = `Usage: pgbudget export qif [flags]
+
+Writes the history of an account, as returned by
+api.get_account_transactions_page, as a QIF file. Asset accounts are written as
+!Type:Bank and liabilities as !Type:CCard.
+
+Flags:
+`
+
+func runExport(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, exportUsage)
+		return flag.ErrHelp
+	}
+
+	switch args[0] {
+	case "qif":
+		return runExportQIF(ctx, args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, exportUsage)
+		return nil
+	}
+
+	fmt.Fprint(os.Stderr, exportUsage)
+	return fmt.Errorf("unknown export format %q", args[0])
+}
+
+func runExportQIF(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export qif", flag.ContinueOnError)
+	s := registerSessionFlags(fs)
+	account := fs.String("account", "", "UUID of the account to export")
+	output := fs.String("o", "-", `output file, "-" for standard output`)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), exportQIFUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if err := s.resolve(); err != nil {
+		return err
+	}
+	if *account == "" {
+		return errors.New("missing -account")
+	}
+
+	var (
+		accountType client.AccountType
+		rows        []client.AccountTransaction
+	)
+	err := s.with(ctx, func(c *client.Client) error {
+		acct, err := c.GetAccount(ctx, *account)
+		if err != nil {
+			return err
+		}
+		accountType = acct.Type
+		rows, err = c.GetAccountTransactions(ctx, *account)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := qif.Write(w, accountType, rows); err != nil {
+		return fmt.Errorf("failed to write qif: %w", err)
+	}
+	return nil
+}
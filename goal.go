@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+)
+
+const goalSetUsage = `Usage: pgbudget goal set -category <name|uuid> -type <type> -amount <amount> [flags]
+
+Sets the goal of a budget category, replacing the goal it had. -type is one
+of:
+
+  monthly_funding  assign -amount every month
+  target_balance   reach a balance of -amount by -by, spreading what is
+                   missing evenly over the months left
+  spending_cap     spend at most -amount a month
+
+Flags:
+`
+
+const goalDeleteUsage = `Usage: pgbudget goal delete -category <name|uuid> [flags]
+
+Removes the goal of a budget category.
+
+Flags:
+`
+
+const goalProgressUsage = `Usage: pgbudget goal progress [flags]
+
+Shows every category with a goal for a month: what the goal needs, what is
+assigned and what is still underfunded. Spending caps show how much they
+are exceeded instead.
+
+Flags:
+`
+
+const goalAssignUsage = `Usage: pgbudget goal assign [flags]
+
+Assigns money from Income to every category whose goal is underfunded in a
+month, in category order, as long as Income holds money. The assignments
+are made all or none.
+
+Flags:
+`
+
+func runGoal(ctx context.Context, args []string) error {
+	return runSubcommand(
+		ctx, "goal", []subcommand{
+			{"set", "set the goal of a category", runGoalSet},
+			{"delete", "remove the goal of a category", runGoalDelete},
+			{"progress", "show the progress of the goals of a month", runGoalProgress},
+			{"assign", "fund underfunded goals from Income", runGoalAssign},
+		}, args,
+	)
+}
+
+func runGoalSet(ctx context.Context, args []string) error {
+	fs := newFlagSet("goal set", goalSetUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	category := fs.String("category", "", "category name or UUID")
+	goalType := fs.String("type", string(client.GoalMonthlyFunding), "monthly_funding, target_balance or spending_cap")
+	amount := fs.String("amount", "", "positive amount, e.g. 250.00")
+	by := fs.String("by", "", "target date of a target_balance goal as YYYY-MM-DD")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	switch {
+	case *category == "":
+		return errors.New("missing -category")
+	case *amount == "":
+		return errors.New("missing -amount")
+	}
+	cents, err := client.ParseAmount(*amount)
+	if err != nil {
+		return err
+	}
+	var targetDate time.Time
+	if *by != "" {
+		if targetDate, err = parseDate(*by); err != nil {
+			return err
+		}
+	}
+
+	return s.with(ctx, func(c *client.Client) error {
+		categoryUUID, err := resolveCategory(ctx, c, s.ledger, *category)
+		if err != nil {
+			return err
+		}
+		return c.SetCategoryGoal(
+			ctx, client.SetCategoryGoalParams{
+				CategoryUUID: categoryUUID,
+				Type:         client.GoalType(*goalType),
+				Amount:       cents,
+				TargetDate:   targetDate,
+			},
+		)
+	})
+}
+
+func runGoalDelete(ctx context.Context, args []string) error {
+	fs := newFlagSet("goal delete", goalDeleteUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	category := fs.String("category", "", "category name or UUID")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	if *category == "" {
+		return errors.New("missing -category")
+	}
+
+	return s.with(ctx, func(c *client.Client) error {
+		categoryUUID, err := resolveCategory(ctx, c, s.ledger, *category)
+		if err != nil {
+			return err
+		}
+		return c.DeleteCategoryGoal(ctx, categoryUUID)
+	})
+}
+
+func runGoalProgress(ctx context.Context, args []string) error {
+	fs := newFlagSet("goal progress", goalProgressUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	period := fs.String("period", time.Now().Format("200601"), "month as YYYYMM")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	if _, err := time.Parse("200601", *period); err != nil {
+		return fmt.Errorf("invalid -period %q: use YYYYMM", *period)
+	}
+
+	var progress []client.GoalProgress
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		progress, err = c.GetGoalProgress(ctx, s.ledger, *period)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	t := table{header: []string{"category", "goal", "amount", "by", "budgeted", "needed", "underfunded", "over cap"}}
+	for _, p := range progress {
+		by := ""
+		if p.TargetDate != nil {
+			by = p.TargetDate.Format(time.DateOnly)
+		}
+		t.rows = append(
+			t.rows, []string{
+				p.CategoryName, string(p.GoalType), client.FormatAmount(p.GoalAmount), by,
+				client.FormatAmount(p.Budgeted), client.FormatAmount(p.Needed),
+				client.FormatAmount(p.Underfunded), client.FormatAmount(p.OverCap),
+			},
+		)
+	}
+	return s.print(progress, t)
+}
+
+func runGoalAssign(ctx context.Context, args []string) error {
+	fs := newFlagSet("goal assign", goalAssignUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	period := fs.String("period", time.Now().Format("200601"), "month as YYYYMM")
+	date := fs.String("date", "", "date of the assignments as YYYY-MM-DD (default today, or the first day of -period)")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	if _, err := time.Parse("200601", *period); err != nil {
+		return fmt.Errorf("invalid -period %q: use YYYYMM", *period)
+	}
+	var day time.Time
+	if *date != "" {
+		var err error
+		if day, err = parseDate(*date); err != nil {
+			return err
+		}
+	}
+
+	var assignments []client.Transaction
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		assignments, err = c.AutoAssign(
+			ctx, client.AutoAssignParams{LedgerUUID: s.ledger, Period: *period, Date: day},
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	t := table{header: []string{"uuid", "date", "category", "amount"}}
+	for _, a := range assignments {
+		total += a.Amount
+		t.rows = append(
+			t.rows, []string{
+				a.UUID, a.Date.Format(time.DateOnly), deref(a.CategoryUUID), client.FormatAmount(a.Amount),
+			},
+		)
+	}
+	t.footer = []string{"Assigned:  " + client.FormatAmount(total)}
+	return s.print(assignments, t)
+}
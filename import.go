@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/j0lvera/pgbudget/client"
+	"github.com/j0lvera/pgbudget/importer"
+	"github.com/j0lvera/pgbudget/importer/csv"
+	"github.com/j0lvera/pgbudget/importer/ofx"
+	"github.com/j0lvera/pgbudget/importer/qif"
+)
+
+const importUsage = `Usage: pgbudget import <format> [flags] <file>
+
+Formats:
+  csv   bank CSV export, mapped with a profile
+  ofx   OFX 1.x/2.x or QFX statement download
+  qif   Quicken Interchange Format (Bank, CCard, Cash, Oth A, Oth L)
+
+Run "pgbudget import <format> -h" for the flags of a format.
+`
+
+const importCSVUsage = `Usage: pgbudget import csv [flags] <file>
+
+Imports a CSV bank statement into an asset or liability account. The file
+is mapped with -profile, either a built-in profile name (%s)
+or the path of a JSON profile. Use "-" to read from standard input.
+
+The whole file is imported in one transaction: if any line fails nothing
+is recorded. Lines imported before, and lines with the same amount as an
+existing transaction within -date-window days, are handled by -duplicates.
+
+Flags:
+`
+
+const importOFXUsage = `Usage: pgbudget import ofx [flags] <file>
+
+Imports an OFX or QFX statement into an asset or liability account. Use
+"-" to read from standard input. The FITID of every transaction is stored
+in its metadata, and lines already imported into the account are skipped,
+so downloading overlapping statements is safe.
+
+Flags:
+`
+
+const importQIFUsage = `Usage: pgbudget import qif [flags] <file>
+
+Imports a QIF account into an asset or liability account. Use "-" to read
+from standard input. Split transactions are recorded as one transaction
+per split. Transfers ("[Account]" categories) use the -category fallback.
+
+Flags:
+`
+
+func runImport(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, importUsage)
+		return flag.ErrHelp
+	}
+
+	switch args[0] {
+	case "csv":
+		return runImportCSV(ctx, args[1:])
+	case "ofx", "qfx":
+		return runImportOFX(ctx, args[1:])
+	case "qif":
+		return runImportQIF(ctx, args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, importUsage)
+		return nil
+	}
+
+	fmt.Fprint(os.Stderr, importUsage)
+	return fmt.Errorf("unknown import format %q", args[0])
+}
+
+func runImportCSV(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import csv", flag.ContinueOnError)
+	target := registerImportFlags(fs)
+	profileName := fs.String("profile", "default", "built-in profile name or path to a JSON profile")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), importCSVUsage, strings.Join(csv.BuiltinNames(), ", "))
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if err := target.validate(); err != nil {
+		return err
+	}
+
+	profile, err := loadCSVProfile(*profileName)
+	if err != nil {
+		return err
+	}
+
+	txs, err := parseFile(fs.Arg(0), func(r io.Reader) ([]importer.Transaction, error) {
+		return csv.Parse(r, profile)
+	})
+	if err != nil {
+		return err
+	}
+
+	return target.run(ctx, fs.Arg(0), txs)
+}
+
+func runImportOFX(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import ofx", flag.ContinueOnError)
+	target := registerImportFlags(fs)
+	acctID := fs.String("acctid", "", "ACCTID of the statement to import when the file holds several")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), importOFXUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if err := target.validate(); err != nil {
+		return err
+	}
+
+	txs, err := parseFile(fs.Arg(0), func(r io.Reader) ([]importer.Transaction, error) {
+		statements, err := ofx.Parse(r)
+		if err != nil {
+			return nil, err
+		}
+		stmt, err := selectStatement(statements, *acctID)
+		if err != nil {
+			return nil, err
+		}
+		return stmt.Transactions, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return target.run(ctx, fs.Arg(0), txs)
+}
+
+func runImportQIF(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import qif", flag.ContinueOnError)
+	target := registerImportFlags(fs)
+	name := fs.String("qif-account", "", "name of the !Account section to import when the file holds several")
+	dayFirst := fs.Bool("day-first", false, "read dates as day/month/year")
+	decimalComma := fs.Bool("decimal-comma", false, `read amounts written as "1.234,56"`)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), importQIFUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if err := target.validate(); err != nil {
+		return err
+	}
+
+	txs, err := parseFile(fs.Arg(0), func(r io.Reader) ([]importer.Transaction, error) {
+		accounts, err := qif.Parse(r, qif.Options{DayFirst: *dayFirst, DecimalComma: *decimalComma})
+		if err != nil {
+			return nil, err
+		}
+		account, err := selectQIFAccount(accounts, *name)
+		if err != nil {
+			return nil, err
+		}
+		return account.Transactions, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return target.run(ctx, fs.Arg(0), txs)
+}
+
+// selectQIFAccount picks the account section with the given name, or the
+// only section when name is empty.
+func selectQIFAccount(accounts []qif.Account, name string) (qif.Account, error) {
+	switch {
+	case len(accounts) == 0:
+		return qif.Account{}, errors.New("no Bank, CCard, Cash, Oth A or Oth L section found")
+	case name == "" && len(accounts) > 1:
+		names := make([]string, len(accounts))
+		for i, account := range accounts {
+			names[i] = account.Name
+		}
+		return qif.Account{}, fmt.Errorf("file holds %d accounts (%s): choose one with -qif-account", len(accounts), strings.Join(names, ", "))
+	case name == "":
+		return accounts[0], nil
+	}
+
+	for _, account := range accounts {
+		if account.Name == name {
+			return account, nil
+		}
+	}
+	return qif.Account{}, fmt.Errorf("no account %q in file", name)
+}
+
+// selectStatement picks the statement of the account with the given ACCTID,
+// or the only statement when acctID is empty.
+func selectStatement(statements []ofx.Statement, acctID string) (ofx.Statement, error) {
+	if acctID == "" {
+		if len(statements) > 1 {
+			ids := make([]string, len(statements))
+			for i, stmt := range statements {
+				ids[i] = stmt.AccountID
+			}
+			return ofx.Statement{}, fmt.Errorf("file holds %d statements (%s): choose one with -acctid", len(statements), strings.Join(ids, ", "))
+		}
+		return statements[0], nil
+	}
+
+	for _, stmt := range statements {
+		if stmt.AccountID == acctID {
+			return stmt, nil
+		}
+	}
+	return ofx.Statement{}, fmt.Errorf("no statement for account %q", acctID)
+}
+
+// loadCSVProfile resolves a built-in profile name or reads a JSON profile.
+func loadCSVProfile(nameOrPath string) (csv.Profile, error) {
+	if p, ok := csv.Builtin(nameOrPath); ok {
+		return p, nil
+	}
+
+	f, err := os.Open(nameOrPath)
+	if err != nil {
+		return csv.Profile{}, fmt.Errorf("unknown profile %q: not built in and %w", nameOrPath, err)
+	}
+	defer f.Close()
+
+	p, err := csv.LoadProfile(f)
+	if err != nil {
+		return csv.Profile{}, fmt.Errorf("failed to load profile %s: %w", nameOrPath, err)
+	}
+	return p, nil
+}
+
+// parseFile runs parse over the named file, or standard input for "-".
+func parseFile(name string, parse func(io.Reader) ([]importer.Transaction, error)) ([]importer.Transaction, error) {
+	if name == "-" {
+		return parse(os.Stdin)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open statement: %w", err)
+	}
+	defer f.Close()
+
+	txs, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return txs, nil
+}
+
+// importTarget holds the flags shared by every import format.
+type importTarget struct {
+	*session
+	account    *string
+	category   *string
+	create     *bool
+	dryRun     *bool
+	duplicates *string
+	dateWindow *int
+}
+
+func registerImportFlags(fs *flag.FlagSet) importTarget {
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	return importTarget{
+		session:  s,
+		account:  fs.String("account", "", "UUID of the asset or liability account the statement belongs to"),
+		category: fs.String("category", "", "category UUID for lines without a known category (default Unassigned)"),
+		create:   fs.Bool("create-categories", false, "create the categories named in the file that do not exist yet"),
+		dryRun:   fs.Bool("dry-run", false, "parse and validate without recording anything"),
+		duplicates: fs.String(
+			"duplicates", string(importer.SkipDuplicates),
+			"what to do with duplicate lines: skip, merge (copy bank details into the existing transaction) or force",
+		),
+		dateWindow: fs.Int(
+			"date-window", importer.DefaultDateWindow,
+			"days apart a line and an existing transaction with the same amount may be to count as duplicates",
+		),
+	}
+}
+
+func (t importTarget) validate() error {
+	if err := t.resolve(); err != nil {
+		return err
+	}
+	if err := t.requireLedger(); err != nil {
+		return err
+	}
+	switch {
+	case *t.account == "":
+		return errors.New("missing -account")
+	case *t.dateWindow < 0:
+		return errors.New("-date-window must not be negative")
+	}
+	_, err := importer.ParseDuplicatePolicy(*t.duplicates)
+	return err
+}
+
+// run records txs, read from the named file, as the configured user in a
+// single transaction.
+func (t importTarget) run(ctx context.Context, name string, txs []importer.Transaction) error {
+	source := filepath.Base(name)
+	if name == "-" {
+		source = "stdin"
+	}
+
+	// the flag counts 0 as the same day, Options as the default
+	window := *t.dateWindow
+	if window == 0 {
+		window = -1
+	}
+
+	opts := importer.Options{
+		LedgerUUID:          t.ledger,
+		AccountUUID:         *t.account,
+		DefaultCategoryUUID: *t.category,
+		CreateCategories:    *t.create,
+		DryRun:              *t.dryRun,
+		Duplicates:          importer.DuplicatePolicy(*t.duplicates),
+		DateWindow:          window,
+		Source:              source,
+	}
+
+	var result *importer.Result
+	err := t.with(ctx, func(c *client.Client) (err error) {
+		result, err = importer.Import(ctx, c, opts, txs)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(result.CreatedCategories) > 0 {
+		verb := "created"
+		if opts.DryRun {
+			verb = "would create"
+		}
+		fmt.Printf("%s categories: %s\n", verb, strings.Join(result.CreatedCategories, ", "))
+	}
+	for _, m := range result.Matches {
+		kind := "likely duplicate of"
+		if m.Exact {
+			kind = "duplicate of"
+		}
+		target := m.TransactionUUID
+		if target == "" {
+			target = "a line imported before"
+		}
+		fmt.Printf("line %d: %s %s (%s)\n", m.Line, kind, target, m.Reason)
+	}
+
+	if opts.DryRun {
+		recorded := len(txs) - result.Skipped - result.Duplicates - result.Merged
+		fmt.Printf(
+			"would import %d transactions (%d skipped, %d duplicates, %d merged, %d forced)\n",
+			recorded, result.Skipped, result.Duplicates, result.Merged, result.Forced,
+		)
+		return nil
+	}
+	fmt.Printf(
+		"imported %d transactions (%d skipped, %d duplicates, %d merged, %d forced) in batch %s\n",
+		len(result.Imported), result.Skipped, result.Duplicates, result.Merged, result.Forced, result.BatchUUID,
+	)
+	return nil
+}
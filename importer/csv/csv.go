@@ -0,0 +1,287 @@
+// Package csv parses bank CSV exports into importer transactions, driven by
+// a column-mapping Profile.
+package csv
+
+import (
+	stdcsv "encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/j0lvera/pgbudget/importer"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// ParseError reports the line of the file a parse failure happened on.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("csv: line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Parse reads every data row of r according to p.
+func Parse(r io.Reader, p Profile) ([]importer.Transaction, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	decoded, err := decode(r, p.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := stdcsv.NewReader(decoded)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if p.Delimiter != "" {
+		reader.Comma, _ = utf8.DecodeRuneInString(p.Delimiter)
+	}
+
+	for i := 0; i < p.SkipRows; i++ {
+		if _, err := reader.Read(); err != nil {
+			return nil, &ParseError{Line: i + 1, Err: fmt.Errorf("skipping rows: %w", err)}
+		}
+	}
+
+	var header []string
+	if !p.NoHeader {
+		header, err = reader.Read()
+		if err != nil {
+			return nil, &ParseError{Line: p.SkipRows + 1, Err: fmt.Errorf("reading header: %w", err)}
+		}
+	}
+
+	cols, err := resolveColumns(p.Columns, header)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := goLayout(p.DateFormat)
+	var txs []importer.Transaction
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			return nil, &ParseError{Line: line, Err: err}
+		}
+		if blank(record) {
+			continue
+		}
+
+		tx, err := parseRecord(record, cols, layout, p)
+		if err != nil {
+			return nil, &ParseError{Line: line, Err: err}
+		}
+		txs = append(txs, tx)
+	}
+
+	return txs, nil
+}
+
+// columnIndexes holds the 0-based position of each mapped column, or -1.
+type columnIndexes struct {
+	date, description, amount, debit, credit, category, memo, id int
+}
+
+func resolveColumns(c Columns, header []string) (columnIndexes, error) {
+	lookup := func(name string, required bool) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")), name) {
+				return i, nil
+			}
+		}
+		if n, err := strconv.Atoi(name); err == nil && n > 0 {
+			return n - 1, nil
+		}
+		if !required && header != nil {
+			// optional columns from a shared profile may be absent from this file
+			return -1, nil
+		}
+		return -1, fmt.Errorf("csv: column %q not found", name)
+	}
+
+	var idx columnIndexes
+	var err error
+	for _, f := range []struct {
+		dst      *int
+		name     string
+		required bool
+	}{
+		{&idx.date, c.Date, true},
+		{&idx.description, c.Description, true},
+		{&idx.amount, c.Amount, true},
+		{&idx.debit, c.Debit, true},
+		{&idx.credit, c.Credit, true},
+		{&idx.category, c.Category, false},
+		{&idx.memo, c.Memo, false},
+		{&idx.id, c.ID, false},
+	} {
+		if *f.dst, err = lookup(f.name, f.required); err != nil {
+			return columnIndexes{}, err
+		}
+	}
+
+	return idx, nil
+}
+
+func parseRecord(record []string, cols columnIndexes, layout string, p Profile) (importer.Transaction, error) {
+	field := func(i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	date, err := time.Parse(layout, field(cols.date))
+	if err != nil {
+		return importer.Transaction{}, fmt.Errorf("invalid date %q: expected %s", field(cols.date), p.DateFormat)
+	}
+
+	var amount int64
+	if cols.amount >= 0 {
+		amount, err = ParseAmount(field(cols.amount), p.DecimalSeparator)
+		if err != nil {
+			return importer.Transaction{}, err
+		}
+		if p.AmountSign == SignOutflowPositive {
+			amount = -amount
+		}
+	} else {
+		debit, err := parseOptionalAmount(field(cols.debit), p.DecimalSeparator)
+		if err != nil {
+			return importer.Transaction{}, err
+		}
+		credit, err := parseOptionalAmount(field(cols.credit), p.DecimalSeparator)
+		if err != nil {
+			return importer.Transaction{}, err
+		}
+		// some banks print debits as negative numbers, others as positive ones
+		amount = abs(credit) - abs(debit)
+	}
+
+	return importer.Transaction{
+		Date:        date,
+		Amount:      amount,
+		Description: field(cols.description),
+		Category:    field(cols.category),
+		Memo:        field(cols.memo),
+		ExternalID:  field(cols.id),
+	}, nil
+}
+
+// ParseAmount converts a decimal amount such as "-1,234.56" (or
+// "-1.234,56" with a "," decimal separator) into cents. Currency symbols,
+// spaces, apostrophes and a trailing minus or parentheses are accepted.
+func ParseAmount(value, decimalSeparator string) (int64, error) {
+	if decimalSeparator == "" {
+		decimalSeparator = "."
+	}
+	thousands := ","
+	if decimalSeparator == "," {
+		thousands = "."
+	}
+
+	s := strings.TrimSpace(value)
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative, s = true, s[1:len(s)-1]
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case string(r) == decimalSeparator:
+			b.WriteByte('.')
+		case r == '-':
+			negative = !negative
+		case r == '+', string(r) == thousands, r == ' ', r == '\'', r == '\u00a0':
+		case strings.ContainsRune("$€£¥", r) || (r >= 'A' && r <= 'Z'):
+			// currency symbols and codes
+		default:
+			return 0, fmt.Errorf("invalid amount %q", value)
+		}
+	}
+
+	whole, frac, _ := strings.Cut(b.String(), ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if len(frac) > 2 {
+		return 0, fmt.Errorf("invalid amount %q: more than two decimals", value)
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+
+	cents, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if negative {
+		cents = -cents
+	}
+	return cents, nil
+}
+
+func parseOptionalAmount(value, decimalSeparator string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return ParseAmount(value, decimalSeparator)
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func blank(record []string) bool {
+	for _, f := range record {
+		if strings.TrimSpace(f) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// decode wraps r so that it yields UTF-8.
+func decode(r io.Reader, name string) (io.Reader, error) {
+	if name == "" || strings.EqualFold(name, "utf-8") || strings.EqualFold(name, "utf8") {
+		return r, nil
+	}
+
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("csv: unsupported encoding %q", name)
+	}
+	if enc == encoding.Nop {
+		return r, nil
+	}
+
+	return transform.NewReader(r, enc.NewDecoder()), nil
+}
@@ -0,0 +1,199 @@
+package csv_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/pgbudget/importer/csv"
+	is_ "github.com/matryer/is"
+)
+
+func TestParse(t *testing.T) {
+	t.Run(
+		"Default", func(t *testing.T) {
+			is := is_.New(t)
+
+			p, ok := csv.Builtin("default")
+			is.True(ok)
+
+			input := "Date,Description,Amount,Category,ID\n" +
+				"2025-01-03,Paycheck,\"2,500.00\",Income,T1\n" +
+				"\n" +
+				"2025-01-04,Groceries,-45.1,Food,T2\n"
+
+			txs, err := csv.Parse(strings.NewReader(input), p)
+			is.NoErr(err)
+			is.Equal(len(txs), 2)
+
+			is.Equal(txs[0].Date, time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC))
+			is.Equal(txs[0].Amount, int64(250000))
+			is.Equal(txs[0].Description, "Paycheck")
+			is.Equal(txs[0].Category, "Income")
+			is.Equal(txs[0].ExternalID, "T1")
+			is.Equal(txs[0].Memo, "") // the memo column is optional
+
+			is.Equal(txs[1].Amount, int64(-4510))
+		},
+	)
+
+	t.Run(
+		"DebitCredit", func(t *testing.T) {
+			is := is_.New(t)
+
+			p, _ := csv.Builtin("debit-credit")
+			input := "date,description,debit,credit\n" +
+				"2025-02-01,Rent,1200.00,\n" +
+				"2025-02-02,Refund,,19.99\n" +
+				"2025-02-03,Fee,-2.50,\n"
+
+			txs, err := csv.Parse(strings.NewReader(input), p)
+			is.NoErr(err)
+			is.Equal(len(txs), 3)
+			is.Equal(txs[0].Amount, int64(-120000))
+			is.Equal(txs[1].Amount, int64(1999))
+			is.Equal(txs[2].Amount, int64(-250)) // negative debits are still debits
+		},
+	)
+
+	t.Run(
+		"European", func(t *testing.T) {
+			is := is_.New(t)
+
+			p, _ := csv.Builtin("european")
+			// "Café" encoded as windows-1252
+			input := "date;description;amount\n" +
+				"31.01.2025;Caf\xe9;-1.234,56\n"
+
+			txs, err := csv.Parse(strings.NewReader(input), p)
+			is.NoErr(err)
+			is.Equal(len(txs), 1)
+			is.Equal(txs[0].Date, time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC))
+			is.Equal(txs[0].Description, "Café")
+			is.Equal(txs[0].Amount, int64(-123456))
+		},
+	)
+
+	t.Run(
+		"OutflowPositive", func(t *testing.T) {
+			is := is_.New(t)
+
+			p, _ := csv.Builtin("credit-card")
+			input := "date,description,amount\n" +
+				"03/15/2025,Coffee,4.75\n" +
+				"03/20/2025,Payment,-500.00\n"
+
+			txs, err := csv.Parse(strings.NewReader(input), p)
+			is.NoErr(err)
+			is.Equal(txs[0].Amount, int64(-475)) // a charge takes money from the card
+			is.Equal(txs[1].Amount, int64(50000))
+		},
+	)
+
+	t.Run(
+		"ColumnIndexes", func(t *testing.T) {
+			is := is_.New(t)
+
+			p := csv.Profile{
+				SkipRows:   2,
+				NoHeader:   true,
+				DateFormat: "02/01/2006",
+				Columns:    csv.Columns{Date: "1", Description: "3", Amount: "2"},
+			}
+			input := "Account statement\nGenerated 2025-04-30\n" +
+				"01/04/2025,-10.00,Lunch\n"
+
+			txs, err := csv.Parse(strings.NewReader(input), p)
+			is.NoErr(err)
+			is.Equal(len(txs), 1)
+			is.Equal(txs[0].Date, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC))
+			is.Equal(txs[0].Description, "Lunch")
+			is.Equal(txs[0].Amount, int64(-1000))
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			is := is_.New(t)
+
+			p, _ := csv.Builtin("default")
+
+			_, err := csv.Parse(strings.NewReader("date,description,amount\n2025-01-01,Ok,1\n2025-13-01,Bad,1\n"), p)
+			var parseErr *csv.ParseError
+			is.True(errors.As(err, &parseErr))
+			is.Equal(parseErr.Line, 3)
+
+			_, err = csv.Parse(strings.NewReader("when,what,how much\n"), p)
+			is.True(err != nil) // required columns are missing
+
+			_, err = csv.Parse(strings.NewReader(""), csv.Profile{Columns: csv.Columns{Date: "date"}})
+			is.True(err != nil) // no amount column configured
+		},
+	)
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value     string
+		separator string
+		want      int64
+		wantErr   bool
+	}{
+		{"12", "", 1200, false},
+		{"12.3", ".", 1230, false},
+		{"-0.05", ".", -5, false},
+		{"$1,234.56", ".", 123456, false},
+		{"(15.00)", ".", -1500, false},
+		{"15.00-", ".", -1500, false},
+		{"1.234,56 EUR", ",", 123456, false},
+		{"1'000.00", ".", 100000, false},
+		{"1.005", ".", 0, true},
+		{"abc", ".", 0, true},
+		{"", ".", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.value, func(t *testing.T) {
+				is := is_.New(t)
+
+				got, err := csv.ParseAmount(tt.value, tt.separator)
+				if tt.wantErr {
+					is.True(err != nil)
+					return
+				}
+				is.NoErr(err)
+				is.Equal(got, tt.want)
+			},
+		)
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	is := is_.New(t)
+
+	p, err := csv.LoadProfile(
+		strings.NewReader(
+			`{
+				"name": "mybank",
+				"delimiter": "\t",
+				"date_format": "YYYY/MM/DD",
+				"columns": {"date": "Posted", "description": "Payee", "amount": "Amount"}
+			}`,
+		),
+	)
+	is.NoErr(err)
+	is.Equal(p.Name, "mybank")
+
+	txs, err := csv.Parse(strings.NewReader("Posted\tPayee\tAmount\n2025/05/06\tShop\t-3.00\n"), p)
+	is.NoErr(err)
+	is.Equal(len(txs), 1)
+	is.Equal(txs[0].Amount, int64(-300))
+
+	_, err = csv.LoadProfile(strings.NewReader(`{"columns": {"date": "d", "amount": "a"}, "typo": 1}`))
+	is.True(err != nil) // unknown fields are rejected
+
+	_, err = csv.LoadProfile(strings.NewReader(`{"columns": {"date": "d", "amount": "a", "debit": "b", "credit": "c"}}`))
+	is.True(err != nil) // amount and debit/credit are exclusive
+}
@@ -0,0 +1,161 @@
+package csv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Sign conventions for a single amount column.
+const (
+	// SignInflowPositive treats positive amounts as money entering the
+	// account. Most bank account exports use it.
+	SignInflowPositive = "inflow-positive"
+	// SignOutflowPositive treats positive amounts as money leaving the
+	// account, as many credit card exports list charges.
+	SignOutflowPositive = "outflow-positive"
+)
+
+// Columns names the CSV columns holding each field. A column is referenced
+// by its header name, or by its 1-based position when the file has no
+// header row.
+type Columns struct {
+	Date        string `json:"date"`
+	Description string `json:"description"`
+	// Amount is a single signed amount column. Use either Amount or the
+	// Debit and Credit pair.
+	Amount string `json:"amount,omitempty"`
+	// Debit holds money leaving the account.
+	Debit string `json:"debit,omitempty"`
+	// Credit holds money entering the account.
+	Credit   string `json:"credit,omitempty"`
+	Category string `json:"category,omitempty"`
+	Memo     string `json:"memo,omitempty"`
+	// ID holds the bank's transaction identifier.
+	ID string `json:"id,omitempty"`
+}
+
+// Profile describes the layout of one bank's CSV export.
+type Profile struct {
+	Name string `json:"name"`
+	// Delimiter separates fields. Defaults to a comma.
+	Delimiter string `json:"delimiter,omitempty"`
+	// Encoding is an IANA or WHATWG name such as "windows-1252" or
+	// "iso-8859-1". Defaults to UTF-8.
+	Encoding string `json:"encoding,omitempty"`
+	// SkipRows is the number of lines to discard before the header.
+	SkipRows int `json:"skip_rows,omitempty"`
+	// NoHeader is set when the first row already holds data.
+	NoHeader bool `json:"no_header,omitempty"`
+	// DateFormat is a Go layout ("02/01/2006") or a pattern using YYYY, YY,
+	// MM, M, DD and D ("DD/MM/YYYY"). Defaults to YYYY-MM-DD.
+	DateFormat string `json:"date_format,omitempty"`
+	// DecimalSeparator is "." (default) or ",". The other character is
+	// treated as a thousands separator.
+	DecimalSeparator string `json:"decimal_separator,omitempty"`
+	// AmountSign is SignInflowPositive (default) or SignOutflowPositive.
+	AmountSign string  `json:"amount_sign,omitempty"`
+	Columns    Columns `json:"columns"`
+}
+
+// Validate reports configuration mistakes before any row is read.
+func (p Profile) Validate() error {
+	if p.Columns.Date == "" {
+		return errors.New("profile: date column is required")
+	}
+	hasAmount := p.Columns.Amount != ""
+	hasSplit := p.Columns.Debit != "" || p.Columns.Credit != ""
+	if hasAmount == hasSplit {
+		return errors.New("profile: set either the amount column or the debit and credit columns")
+	}
+	if hasSplit && (p.Columns.Debit == "" || p.Columns.Credit == "") {
+		return errors.New("profile: debit and credit columns must be set together")
+	}
+	if d := p.DecimalSeparator; d != "" && d != "." && d != "," {
+		return fmt.Errorf("profile: unsupported decimal separator %q", d)
+	}
+	if s := p.AmountSign; s != "" && s != SignInflowPositive && s != SignOutflowPositive {
+		return fmt.Errorf("profile: unsupported amount sign %q", s)
+	}
+	if len([]rune(p.Delimiter)) > 1 {
+		return fmt.Errorf("profile: delimiter must be a single character, got %q", p.Delimiter)
+	}
+	return nil
+}
+
+// LoadProfile decodes a JSON profile.
+func LoadProfile(r io.Reader) (Profile, error) {
+	var p Profile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return Profile{}, fmt.Errorf("profile: %w", err)
+	}
+	return p, p.Validate()
+}
+
+// builtin holds ready-made profiles, selectable by name.
+var builtin = map[string]Profile{
+	"default": {
+		Name:    "default",
+		Columns: Columns{Date: "date", Description: "description", Amount: "amount", Category: "category", Memo: "memo", ID: "id"},
+	},
+	"debit-credit": {
+		Name:    "debit-credit",
+		Columns: Columns{Date: "date", Description: "description", Debit: "debit", Credit: "credit", Category: "category", Memo: "memo", ID: "id"},
+	},
+	"european": {
+		Name:             "european",
+		Delimiter:        ";",
+		Encoding:         "windows-1252",
+		DateFormat:       "DD.MM.YYYY",
+		DecimalSeparator: ",",
+		Columns:          Columns{Date: "date", Description: "description", Amount: "amount", Category: "category", Memo: "memo", ID: "id"},
+	},
+	"credit-card": {
+		Name:       "credit-card",
+		DateFormat: "MM/DD/YYYY",
+		AmountSign: SignOutflowPositive,
+		Columns:    Columns{Date: "date", Description: "description", Amount: "amount", Category: "category", Memo: "memo", ID: "id"},
+	},
+}
+
+// Builtin returns the ready-made profile with the given name.
+func Builtin(name string) (Profile, bool) {
+	p, ok := builtin[name]
+	return p, ok
+}
+
+// BuiltinNames lists the ready-made profiles.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtin))
+	for name := range builtin {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// goLayout converts a YYYY/MM/DD style pattern into a Go time layout.
+// Layouts already written the Go way are returned unchanged.
+func goLayout(format string) string {
+	if format == "" {
+		return "2006-01-02"
+	}
+	if !strings.ContainsAny(format, "YMD") {
+		return format
+	}
+
+	replacer := strings.NewReplacer(
+		"YYYY", "2006",
+		"YY", "06",
+		"MM", "01",
+		"M", "1",
+		"DD", "02",
+		"D", "2",
+	)
+	return replacer.Replace(format)
+}
@@ -0,0 +1,262 @@
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/j0lvera/pgbudget/client"
+)
+
+// DuplicatePolicy decides what Import does with a line that duplicates a
+// transaction already in the account.
+type DuplicatePolicy string
+
+const (
+	// SkipDuplicates leaves duplicate lines out. It is the default.
+	SkipDuplicates DuplicatePolicy = "skip"
+	// MergeDuplicates leaves duplicate lines out but merges their bank
+	// details (external ID, memo, ...) into the existing transaction, so
+	// that a transaction entered by hand is recognized exactly next time.
+	MergeDuplicates DuplicatePolicy = "merge"
+	// ForceDuplicates records likely duplicates anyway. Exact duplicates,
+	// with a known external ID or fingerprint, are still left out.
+	ForceDuplicates DuplicatePolicy = "force"
+)
+
+// ParseDuplicatePolicy validates a policy name; empty means SkipDuplicates.
+func ParseDuplicatePolicy(name string) (DuplicatePolicy, error) {
+	switch p := DuplicatePolicy(name); p {
+	case "":
+		return SkipDuplicates, nil
+	case SkipDuplicates, MergeDuplicates, ForceDuplicates:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown duplicate policy %q: use skip, merge or force", name)
+}
+
+// DefaultDateWindow is how many days apart a line and an existing
+// transaction with the same amount may be dated and still be reported as
+// likely duplicates. Banks often post a few days after the purchase.
+const DefaultDateWindow = 3
+
+// Match reports a line that duplicates an existing transaction.
+type Match struct {
+	// Line is the 1-based position of the line in the imported slice.
+	Line int
+	// TransactionUUID is the existing transaction.
+	TransactionUUID string
+	// Exact is set for matches on the external ID or on a fingerprint an
+	// earlier import recorded; the others are likely duplicates.
+	Exact bool
+	// Days is how many days the existing transaction is dated after
+	// (positive) or before (negative) the line.
+	Days int
+	// Reason explains the match in a few words.
+	Reason string
+}
+
+// NormalizeDescription lowercases a description and reduces everything but
+// letters and digits to single spaces, so that "ACME  Corp." and
+// "acme corp" compare equal.
+func NormalizeDescription(description string) string {
+	fields := strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+// Fingerprint identifies a statement line of an account by its date,
+// amount, normalized description and external ID. occurrence tells apart
+// identical lines of one statement, such as two coffees bought the same
+// day; it starts at 1.
+func Fingerprint(accountUUID string, tx Transaction, occurrence int) string {
+	h := sha256.New()
+	for _, part := range []string{
+		accountUUID,
+		tx.Date.Format(time.DateOnly),
+		strconv.FormatInt(tx.Amount, 10),
+		NormalizeDescription(tx.Description),
+		tx.ExternalID,
+		strconv.Itoa(occurrence),
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0x1f})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fingerprints returns the fingerprint of every line of txs.
+func fingerprints(accountUUID string, txs []Transaction) []string {
+	out := make([]string, len(txs))
+	occurrences := map[string]int{}
+	for i, tx := range txs {
+		key := Fingerprint(accountUUID, tx, 0)
+		occurrences[key]++
+		out[i] = Fingerprint(accountUUID, tx, occurrences[key])
+	}
+	return out
+}
+
+// candidate is an existing transaction of the account, with its amount
+// signed like Transaction.Amount.
+type candidate struct {
+	uuid        string
+	date        time.Time
+	amount      int64
+	description string
+	externalID  string
+}
+
+func newCandidate(accountType client.AccountType, c client.ImportCandidate) candidate {
+	var metadata struct {
+		FITID string `json:"fitid"`
+	}
+	// metadata written by hand may not be an object; it then has no fitid
+	_ = json.Unmarshal(c.Metadata, &metadata)
+
+	return candidate{
+		uuid:        c.UUID,
+		date:        c.Date,
+		amount:      SignedAmount(accountType, c.Type, c.Amount),
+		description: NormalizeDescription(c.Description),
+		externalID:  metadata.FITID,
+	}
+}
+
+// findDuplicates matches the lines of txs against the existing transactions
+// of the account and the fingerprints earlier imports have recorded. Every
+// existing transaction matches one line at most. The result is keyed by the
+// 0-based index of the line.
+func findDuplicates(
+	txs []Transaction, prints []string, seen map[string]client.ImportFingerprint,
+	candidates []candidate, window int,
+) map[int]Match {
+	matches := map[int]Match{}
+	claimed := make([]bool, len(candidates))
+
+	// exact matches first, so likely ones cannot take their transactions
+	for i, tx := range txs {
+		if tx.Amount == 0 {
+			continue
+		}
+
+		if tx.ExternalID != "" {
+			for j, c := range candidates {
+				if !claimed[j] && c.externalID == tx.ExternalID {
+					claimed[j] = true
+					matches[i] = Match{Line: i + 1, TransactionUUID: c.uuid, Exact: true, Days: days(tx.Date, c.date), Reason: "same external id"}
+					break
+				}
+			}
+			if _, ok := matches[i]; ok {
+				continue
+			}
+		}
+
+		if f, ok := seen[prints[i]]; ok {
+			m := Match{Line: i + 1, Exact: true, Reason: "imported before"}
+			if f.TransactionUUID != nil {
+				m.TransactionUUID = *f.TransactionUUID
+				for j, c := range candidates {
+					if c.uuid == m.TransactionUUID {
+						claimed[j] = true
+					}
+				}
+			}
+			matches[i] = m
+		}
+	}
+
+	for i, tx := range txs {
+		if _, ok := matches[i]; ok || tx.Amount == 0 {
+			continue
+		}
+
+		description := NormalizeDescription(tx.Description)
+		best, bestScore := -1, 0
+		for j, c := range candidates {
+			if claimed[j] || c.amount != tx.Amount {
+				continue
+			}
+			// two different bank ids are two different transactions
+			if tx.ExternalID != "" && c.externalID != "" {
+				continue
+			}
+			d := days(tx.Date, c.date)
+			if abs(d) > window {
+				continue
+			}
+
+			// prefer the same description, then the closest date
+			score := abs(d)
+			if c.description != description {
+				score += window + 1
+			}
+			if best < 0 || score < bestScore {
+				best, bestScore = j, score
+			}
+		}
+		if best < 0 {
+			continue
+		}
+
+		claimed[best] = true
+		c := candidates[best]
+		reason := "same amount within the date window"
+		if c.description == description {
+			reason = "same amount and description within the date window"
+		}
+		matches[i] = Match{Line: i + 1, TransactionUUID: c.uuid, Days: days(tx.Date, c.date), Reason: reason}
+	}
+
+	return matches
+}
+
+// sortedMatches returns the matches in line order.
+func sortedMatches(matches map[int]Match) []Match {
+	out := make([]Match, 0, len(matches))
+	for _, m := range matches {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Line < out[j].Line })
+	return out
+}
+
+// dateRange returns the earliest and latest date of the lines that carry
+// money, widened by window days on both sides.
+func dateRange(txs []Transaction, window int) (start, end time.Time, ok bool) {
+	for _, tx := range txs {
+		if tx.Amount == 0 {
+			continue
+		}
+		if !ok || tx.Date.Before(start) {
+			start = tx.Date
+		}
+		if !ok || tx.Date.After(end) {
+			end = tx.Date
+		}
+		ok = true
+	}
+	return start.AddDate(0, 0, -window), end.AddDate(0, 0, window), ok
+}
+
+// days returns the number of calendar days from a to b.
+func days(a, b time.Time) int {
+	a = time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	b = time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a).Hours() / 24)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
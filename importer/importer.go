@@ -0,0 +1,594 @@
+// Package importer records bank statement lines as pgbudget transactions.
+//
+// Format specific packages (importer/csv, ...) parse a statement into
+// Transaction values; Import then turns each of them into an
+// api.add_transaction call against one asset or liability account, or an
+// api.add_split_transaction call for lines with splits.
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+)
+
+// Transaction is a statement line normalised from any import format.
+type Transaction struct {
+	Date time.Time
+	// Amount is in cents. It is positive when money enters the account
+	// (deposits, refunds, credit card payments) and negative when it leaves
+	// (withdrawals, purchases, card charges).
+	Amount      int64
+	Description string
+	// Category optionally names the budget category of the transaction.
+	Category string
+	// ExternalID is the identifier the bank gave the transaction, if any.
+	// It is stored as metadata.fitid and prevents the same line from being
+	// imported twice into the account.
+	ExternalID string
+	// Memo holds additional free text from the statement.
+	Memo string
+	// Metadata holds further format specific fields worth keeping, such as
+	// the OFX transaction type or check number.
+	Metadata map[string]string
+	// Splits share the line between categories, as QIF split transactions
+	// do. When set, Category is not used; the splits have the sign of
+	// Amount and add up to it.
+	Splits []Split
+}
+
+// Split is the share of one category in a Transaction with splits.
+type Split struct {
+	// Amount is in cents, signed like Transaction.Amount.
+	Amount   int64
+	Category string
+	// Memo holds free text about the split; it replaces the memo of the line.
+	Memo string
+	// Metadata holds format specific fields of the split, such as a class.
+	Metadata map[string]string
+}
+
+// TransactionType returns the api transaction type of an amount recorded
+// against an account of the given type.
+//
+// For asset accounts money entering the account is an inflow. Liability
+// accounts are the other way around: a card charge increases the debt and
+// is an inflow, a payment decreases it and is an outflow (see SPEC.md).
+func TransactionType(accountType client.AccountType, amount int64) client.TransactionType {
+	moneyIn := amount > 0
+	if accountType == client.AccountTypeLiability {
+		moneyIn = !moneyIn
+	}
+	if moneyIn {
+		return client.Inflow
+	}
+	return client.Outflow
+}
+
+// SignedAmount is the inverse of TransactionType: it turns the positive
+// amount and type of a recorded transaction back into a signed amount that
+// is positive when money entered the account.
+func SignedAmount(accountType client.AccountType, txType client.TransactionType, amount int64) int64 {
+	moneyIn := txType == client.Inflow
+	if accountType == client.AccountTypeLiability {
+		moneyIn = !moneyIn
+	}
+	if moneyIn {
+		return amount
+	}
+	return -amount
+}
+
+// Options selects where imported transactions are recorded.
+type Options struct {
+	LedgerUUID string
+	// AccountUUID is the asset or liability account the statement belongs to.
+	AccountUUID string
+	// DefaultCategoryUUID is used for transactions without a category, or
+	// whose category does not exist. Empty means the Unassigned category.
+	DefaultCategoryUUID string
+	// CreateCategories creates the categories named by txs that the ledger
+	// does not have yet, instead of using the default category for them.
+	CreateCategories bool
+	// Duplicates is what to do with lines that duplicate existing
+	// transactions. Empty means SkipDuplicates.
+	Duplicates DuplicatePolicy
+	// DateWindow is how many days apart a line and an existing transaction
+	// may be to count as likely duplicates. Zero means DefaultDateWindow; a
+	// negative window only matches transactions on the same day.
+	DateWindow int
+	// Source is stored with the import batch, typically the file name.
+	Source string
+	// DryRun validates, resolves and reports duplicates without recording
+	// anything.
+	DryRun bool
+}
+
+// Result summarises an import.
+type Result struct {
+	// BatchUUID identifies the stored import batch; it is empty for dry runs.
+	BatchUUID string
+	// Imported holds the UUID of every recorded transaction, in input order.
+	// A line with splits is recorded as a split transaction; its first leg
+	// stands for it.
+	Imported []string
+	// Skipped counts transactions with a zero amount, which carry no money.
+	Skipped int
+	// Duplicates counts duplicate lines that were left out.
+	Duplicates int
+	// Merged counts duplicate lines merged into existing transactions.
+	Merged int
+	// Forced counts likely duplicates recorded because of ForceDuplicates.
+	Forced int
+	// Matches lists every line found to duplicate an existing transaction,
+	// whatever the policy did with it.
+	Matches []Match
+	// CreatedCategories names the categories added because of
+	// Options.CreateCategories (or that would be, in a dry run).
+	CreatedCategories []string
+}
+
+// ErrUnsupportedAccount is returned when the target account is not an asset
+// or a liability.
+var ErrUnsupportedAccount = errors.New("transactions can only be imported into asset or liability accounts")
+
+// ErrInvalidSplits is returned for a line whose splits do not add up to its
+// amount, or do not all move money the same way as the line: a split
+// transaction records one posting shared by categories.
+var ErrInvalidSplits = errors.New("splits must add up to the line and share its sign")
+
+// Import records txs against the account named in opts through c.
+//
+// Every line is fingerprinted and compared with the transactions already
+// in the account: lines with a known external ID or a fingerprint recorded
+// by an earlier import are exact duplicates, lines with the same amount dated
+// within Options.DateWindow days of an existing transaction are likely
+// duplicates. Options.Duplicates decides what happens to them, and the
+// outcome of every line is stored as an import batch, so re-running an
+// import is harmless.
+//
+// Import does not open a transaction of its own; run it inside
+// client.WithUser (or any pgx.Tx) so that a failing line rolls back the
+// whole statement.
+func Import(ctx context.Context, c *client.Client, opts Options, txs []Transaction) (*Result, error) {
+	account, err := c.GetAccount(ctx, opts.AccountUUID)
+	if err != nil {
+		return nil, err
+	}
+	if account.LedgerUUID != opts.LedgerUUID {
+		return nil, fmt.Errorf("account %s does not belong to ledger %s: %w", opts.AccountUUID, opts.LedgerUUID, client.ErrAccountNotFound)
+	}
+	if account.Type != client.AccountTypeAsset && account.Type != client.AccountTypeLiability {
+		return nil, fmt.Errorf("account %s is %s: %w", account.Name, account.Type, ErrUnsupportedAccount)
+	}
+
+	policy, err := ParseDuplicatePolicy(string(opts.Duplicates))
+	if err != nil {
+		return nil, err
+	}
+
+	prints := fingerprints(opts.AccountUUID, txs)
+	matches, err := duplicates(ctx, c, account.Type, opts, txs, prints)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Matches: sortedMatches(matches)}
+
+	// lines left out as duplicates need no category
+	var recorded []Transaction
+	for i, tx := range txs {
+		if m, ok := matches[i]; !ok || (policy == ForceDuplicates && !m.Exact) {
+			recorded = append(recorded, tx)
+		}
+	}
+
+	categories := newCategoryResolver(c, opts.LedgerUUID, opts.DefaultCategoryUUID)
+	if opts.CreateCategories {
+		result.CreatedCategories, err = categories.missing(ctx, recorded)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.CreatedCategories) > 0 && !opts.DryRun {
+			if err := categories.create(ctx, result.CreatedCategories); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var items []client.ImportBatchItem
+
+	for i, tx := range txs {
+		if tx.Amount == 0 {
+			result.Skipped++
+			continue
+		}
+
+		item := client.ImportBatchItem{Line: i + 1, Fingerprint: prints[i]}
+		match, duplicate := matches[i]
+
+		switch {
+		case duplicate && policy == MergeDuplicates && match.TransactionUUID != "":
+			result.Merged++
+			item.Action, item.TransactionUUID = client.ImportActionMerged, match.TransactionUUID
+			if !opts.DryRun {
+				if err := merge(ctx, c, match.TransactionUUID, tx); err != nil {
+					return nil, fmt.Errorf("line %d: %w", i+1, err)
+				}
+			}
+			items = append(items, item)
+			continue
+
+		case duplicate && (policy != ForceDuplicates || match.Exact):
+			result.Duplicates++
+			item.Action, item.TransactionUUID = client.ImportActionSkipped, match.TransactionUUID
+			items = append(items, item)
+			continue
+		}
+
+		var (
+			categoryUUID string
+			splits       []client.Split
+		)
+		if len(tx.Splits) > 0 {
+			splits, err = categories.splits(ctx, tx)
+		} else {
+			categoryUUID, err = categories.resolve(ctx, tx.Category)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		if opts.DryRun {
+			if duplicate {
+				result.Forced++
+			}
+			continue
+		}
+
+		var (
+			transactionUUID string
+			created         = true
+		)
+		if len(splits) > 0 {
+			transactionUUID, err = addSplit(ctx, c, opts, account.Type, tx, splits)
+		} else {
+			amount := tx.Amount
+			if amount < 0 {
+				amount = -amount
+			}
+
+			var metadata json.RawMessage
+			metadata, err = tx.metadata()
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+
+			transactionUUID, created, err = c.ImportTransaction(
+				ctx, client.ImportTransactionParams{
+					AddTransactionParams: client.AddTransactionParams{
+						LedgerUUID:   opts.LedgerUUID,
+						Date:         tx.Date,
+						Description:  tx.Description,
+						Type:         TransactionType(account.Type, tx.Amount),
+						Amount:       amount,
+						AccountUUID:  opts.AccountUUID,
+						CategoryUUID: categoryUUID,
+					},
+					ExternalID: tx.ExternalID,
+					Metadata:   metadata,
+				},
+			)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d (%s %q): %w", i+1, tx.Date.Format(time.DateOnly), tx.Description, err)
+		}
+
+		switch {
+		case !created:
+			// the external id is known from outside the date range looked at
+			result.Duplicates++
+			item.Action = client.ImportActionSkipped
+		case duplicate:
+			result.Forced++
+			item.Action, item.TransactionUUID = client.ImportActionForced, transactionUUID
+			result.Imported = append(result.Imported, transactionUUID)
+		default:
+			item.Action, item.TransactionUUID = client.ImportActionImported, transactionUUID
+			result.Imported = append(result.Imported, transactionUUID)
+		}
+		items = append(items, item)
+	}
+
+	if opts.DryRun {
+		return result, nil
+	}
+
+	result.BatchUUID, err = c.AddImportBatch(
+		ctx, client.AddImportBatchParams{
+			AccountUUID: opts.AccountUUID,
+			Source:      opts.Source,
+			Policy:      string(policy),
+			Items:       items,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// duplicates loads what the database knows about the account around the
+// dates of txs and matches the lines against it.
+func duplicates(
+	ctx context.Context, c *client.Client, accountType client.AccountType,
+	opts Options, txs []Transaction, prints []string,
+) (map[int]Match, error) {
+	window := opts.DateWindow
+	switch {
+	case window == 0:
+		window = DefaultDateWindow
+	case window < 0:
+		window = 0
+	}
+
+	start, end, ok := dateRange(txs, window)
+	if !ok {
+		return map[int]Match{}, nil
+	}
+
+	rows, err := c.FindImportCandidates(ctx, opts.AccountUUID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]candidate, len(rows))
+	for i, row := range rows {
+		candidates[i] = newCandidate(accountType, row)
+	}
+
+	found, err := c.FindImportFingerprints(ctx, opts.AccountUUID, prints)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]client.ImportFingerprint, len(found))
+	for _, f := range found {
+		seen[f.Fingerprint] = f
+	}
+
+	return findDuplicates(txs, prints, seen, candidates, window), nil
+}
+
+// addSplit records a line with splits through api.add_split_transaction and
+// stores the details of the line and of each split on its legs. It returns
+// the UUID of the first leg, which stands for the line in the import batch.
+func addSplit(
+	ctx context.Context, c *client.Client, opts Options, accountType client.AccountType,
+	tx Transaction, splits []client.Split,
+) (string, error) {
+	splitUUID, err := c.AddSplitTransaction(
+		ctx, client.AddSplitTransactionParams{
+			LedgerUUID:  opts.LedgerUUID,
+			Date:        tx.Date,
+			Description: tx.Description,
+			Type:        TransactionType(accountType, tx.Amount),
+			AccountUUID: opts.AccountUUID,
+			Splits:      splits,
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	split, err := c.GetSplitTransaction(ctx, splitUUID)
+	if err != nil {
+		return "", err
+	}
+
+	// the legs come back in the order of the splits
+	for i, leg := range split.Splits {
+		fields := tx.fields()
+		for k, v := range tx.Splits[i].fields() {
+			fields[k] = v
+		}
+		if i == 0 && tx.ExternalID != "" {
+			fields["fitid"] = tx.ExternalID
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		metadata, err := json.Marshal(fields)
+		if err != nil {
+			return "", err
+		}
+		if err := c.MergeTransactionMetadata(ctx, leg.UUID, metadata); err != nil {
+			return "", err
+		}
+	}
+
+	return split.Splits[0].UUID, nil
+}
+
+// merge copies the bank details of tx into an existing transaction.
+func merge(ctx context.Context, c *client.Client, transactionUUID string, tx Transaction) error {
+	fields := tx.fields()
+	if tx.ExternalID != "" {
+		fields["fitid"] = tx.ExternalID
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	metadata, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return c.MergeTransactionMetadata(ctx, transactionUUID, metadata)
+}
+
+// fields returns the memo and the non-empty format specific fields.
+func (tx Transaction) fields() map[string]string {
+	fields := make(map[string]string, len(tx.Metadata)+2)
+	for k, v := range tx.Metadata {
+		if v != "" {
+			fields[k] = v
+		}
+	}
+	if tx.Memo != "" {
+		fields["memo"] = tx.Memo
+	}
+	return fields
+}
+
+// fields returns the memo and the non-empty format specific fields of the
+// split.
+func (s Split) fields() map[string]string {
+	fields := make(map[string]string, len(s.Metadata)+1)
+	for k, v := range s.Metadata {
+		if v != "" {
+			fields[k] = v
+		}
+	}
+	if s.Memo != "" {
+		fields["memo"] = s.Memo
+	}
+	return fields
+}
+
+// metadata returns fields as a JSON object, or nil when there is nothing
+// to store.
+func (tx Transaction) metadata() (json.RawMessage, error) {
+	fields := tx.fields()
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(fields)
+}
+
+// categoryResolver maps category names to UUIDs, caching lookups.
+type categoryResolver struct {
+	c          *client.Client
+	ledgerUUID string
+	fallback   string
+	// cache holds the UUID of every name looked up, empty when not found.
+	cache map[string]string
+}
+
+func newCategoryResolver(c *client.Client, ledgerUUID, fallback string) *categoryResolver {
+	return &categoryResolver{c: c, ledgerUUID: ledgerUUID, fallback: fallback, cache: map[string]string{}}
+}
+
+// lookup returns the UUID of the named category, or "" when the ledger has
+// no such category.
+func (r *categoryResolver) lookup(ctx context.Context, name string) (string, error) {
+	if uuid, ok := r.cache[name]; ok {
+		return uuid, nil
+	}
+
+	uuid, err := r.c.FindCategoryUUID(ctx, r.ledgerUUID, name)
+	if err != nil && !errors.Is(err, client.ErrCategoryNotFound) {
+		return "", err
+	}
+
+	r.cache[name] = uuid
+	return uuid, nil
+}
+
+// resolve returns the UUID of the named category, falling back to the
+// default category for empty or unknown names.
+func (r *categoryResolver) resolve(ctx context.Context, name string) (string, error) {
+	if name == "" {
+		return r.fallback, nil
+	}
+
+	uuid, err := r.lookup(ctx, name)
+	if err != nil || uuid != "" {
+		return uuid, err
+	}
+	return r.fallback, nil
+}
+
+// splits resolves the categories of the splits of tx, checking that they
+// can be recorded as one split transaction.
+func (r *categoryResolver) splits(ctx context.Context, tx Transaction) ([]client.Split, error) {
+	var total int64
+	splits := make([]client.Split, len(tx.Splits))
+	for i, s := range tx.Splits {
+		if s.Amount == 0 || (s.Amount < 0) != (tx.Amount < 0) {
+			return nil, ErrInvalidSplits
+		}
+		total += s.Amount
+
+		categoryUUID, err := r.resolve(ctx, s.Category)
+		if err != nil {
+			return nil, err
+		}
+		amount := s.Amount
+		if amount < 0 {
+			amount = -amount
+		}
+		splits[i] = client.Split{CategoryUUID: categoryUUID, Amount: amount}
+	}
+	if total != tx.Amount {
+		return nil, ErrInvalidSplits
+	}
+
+	return splits, nil
+}
+
+// missing returns the distinct category names of txs the ledger lacks, in
+// order of first use.
+func (r *categoryResolver) missing(ctx context.Context, txs []Transaction) ([]string, error) {
+	var names []string
+	seen := map[string]bool{}
+
+	for _, tx := range txs {
+		if tx.Amount == 0 {
+			continue
+		}
+
+		used := []string{tx.Category}
+		if len(tx.Splits) > 0 {
+			used = used[:0]
+			for _, s := range tx.Splits {
+				used = append(used, s.Category)
+			}
+		}
+
+		for _, name := range used {
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			uuid, err := r.lookup(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			if uuid == "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// create adds the named categories in one api.add_categories call.
+func (r *categoryResolver) create(ctx context.Context, names []string) error {
+	categories, err := r.c.AddCategories(ctx, r.ledgerUUID, names)
+	if err != nil {
+		return err
+	}
+
+	for _, category := range categories {
+		r.cache[category.Name] = category.UUID
+	}
+	return nil
+}
@@ -0,0 +1,617 @@
+package importer_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+	"github.com/j0lvera/pgbudget/importer"
+	"github.com/j0lvera/pgbudget/testutils/pgcontainer"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	is_ "github.com/matryer/is"
+	"github.com/rs/zerolog"
+)
+
+var (
+	testDSN string
+	log     zerolog.Logger
+)
+
+// errRollback makes withClient roll back what a subtest recorded.
+var errRollback = errors.New("rollback")
+
+func TestMain(m *testing.M) {
+	// Setup logging
+	log = zerolog.New(os.Stdout).With().Timestamp().Logger()
+
+	// Create a context with timeout for setup
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	// Configure and start the PostgreSQL container
+	cfg := pgcontainer.NewConfig()
+	cfg.WithLogger(&log).WithMigrationsPath("migrations") // Path relative to project root
+
+	pgContainer := pgcontainer.NewPgContainer(cfg)
+	output, err := pgContainer.Start(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to start PostgreSQL container")
+	}
+
+	testDSN = output.DSN()
+
+	os.Exit(m.Run())
+}
+
+// fixture is a ledger with a checking account, a credit card and one
+// spending category.
+type fixture struct {
+	ledger    *client.Ledger
+	checking  *client.Account
+	card      *client.Account
+	groceries *client.Account
+}
+
+func newFixture(ctx context.Context, t *testing.T, c *client.Client, name string) fixture {
+	t.Helper()
+	is := is_.New(t)
+
+	ledger, err := c.CreateLedger(ctx, client.CreateLedgerParams{Name: name})
+	is.NoErr(err)
+
+	checking, err := c.CreateAccount(ctx, client.CreateAccountParams{LedgerUUID: ledger.UUID, Name: "Checking", Type: client.AccountTypeAsset})
+	is.NoErr(err)
+	card, err := c.CreateAccount(ctx, client.CreateAccountParams{LedgerUUID: ledger.UUID, Name: "Visa", Type: client.AccountTypeLiability})
+	is.NoErr(err)
+	groceries, err := c.AddCategory(ctx, ledger.UUID, "Groceries")
+	is.NoErr(err)
+
+	return fixture{ledger: ledger, checking: checking, card: card, groceries: groceries}
+}
+
+func TestImport(t *testing.T) {
+	is := is_.New(t)
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, testDSN)
+	is.NoErr(err)
+	defer pool.Close()
+
+	const userID = "importer_test_user"
+
+	// withClient runs fn in a transaction scoped to the test user.
+	withClient := func(fn func(c *client.Client) error) error {
+		return client.WithUser(ctx, pool, userID, func(tx pgx.Tx) error {
+			return fn(client.New(tx))
+		})
+	}
+
+	var f fixture
+	is.NoErr(withClient(func(c *client.Client) error {
+		f = newFixture(ctx, t, c, "Import Test Ledger")
+		return nil
+	}))
+
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	t.Run(
+		"AssetAccount", func(t *testing.T) {
+			is := is_.New(t)
+
+			txs := []importer.Transaction{
+				{Date: day(1), Amount: 200000, Description: "Paycheck", Category: "Income"},
+				{Date: day(2), Amount: -4550, Description: "Market", Category: "Groceries"},
+				{Date: day(3), Amount: 0, Description: "Balance inquiry"},
+				{Date: day(4), Amount: -1000, Description: "Unknown shop", Category: "No Such Category"},
+			}
+
+			err := withClient(func(c *client.Client) error {
+				result, err := importer.Import(ctx, c, importer.Options{LedgerUUID: f.ledger.UUID, AccountUUID: f.checking.UUID}, txs)
+				if err != nil {
+					return err
+				}
+				is.Equal(len(result.Imported), 3)
+				is.Equal(result.Skipped, 1)
+
+				balance, err := c.GetAccountBalance(ctx, f.checking.UUID)
+				is.NoErr(err)
+				is.Equal(balance, int64(200000-4550-1000))
+
+				// unknown categories fall back to Unassigned
+				rows, err := c.GetAccountTransactions(ctx, f.checking.UUID)
+				is.NoErr(err)
+				categories := map[string]string{}
+				for _, row := range rows {
+					categories[row.Description] = row.Category
+				}
+				is.Equal(categories["Paycheck"], "Income")
+				is.Equal(categories["Market"], "Groceries")
+				is.Equal(categories["Unknown shop"], "Unassigned")
+				return nil
+			})
+			is.NoErr(err)
+		},
+	)
+
+	t.Run(
+		"LiabilityAccount", func(t *testing.T) {
+			is := is_.New(t)
+
+			txs := []importer.Transaction{
+				{Date: day(5), Amount: -3000, Description: "Card purchase", Category: "Groceries"},
+				{Date: day(6), Amount: 1000, Description: "Card refund", Category: "Groceries"},
+			}
+
+			err := withClient(func(c *client.Client) error {
+				_, err := importer.Import(ctx, c, importer.Options{LedgerUUID: f.ledger.UUID, AccountUUID: f.card.UUID}, txs)
+				if err != nil {
+					return err
+				}
+
+				// a charge increases what is owed on the card
+				balance, err := c.GetAccountBalance(ctx, f.card.UUID)
+				is.NoErr(err)
+				is.Equal(balance, int64(2000))
+				return nil
+			})
+			is.NoErr(err)
+		},
+	)
+
+	t.Run(
+		"DryRun", func(t *testing.T) {
+			is := is_.New(t)
+
+			err := withClient(func(c *client.Client) error {
+				before, err := c.GetAccountBalance(ctx, f.checking.UUID)
+				is.NoErr(err)
+
+				result, err := importer.Import(
+					ctx, c, importer.Options{LedgerUUID: f.ledger.UUID, AccountUUID: f.checking.UUID, DryRun: true},
+					[]importer.Transaction{{Date: day(7), Amount: -500, Description: "Not recorded"}},
+				)
+				is.NoErr(err)
+				is.Equal(len(result.Imported), 0)
+
+				after, err := c.GetAccountBalance(ctx, f.checking.UUID)
+				is.NoErr(err)
+				is.Equal(after, before)
+				return nil
+			})
+			is.NoErr(err)
+		},
+	)
+
+	t.Run(
+		"RollsBackOnError", func(t *testing.T) {
+			is := is_.New(t)
+
+			var before int64
+			is.NoErr(withClient(func(c *client.Client) (err error) {
+				before, err = c.GetAccountBalance(ctx, f.checking.UUID)
+				return err
+			}))
+
+			txs := []importer.Transaction{
+				{Date: day(8), Amount: -700, Description: "Recorded then rolled back", Category: "Groceries"},
+				{Date: day(9), Amount: -800, Description: "Falls back to a missing category"},
+			}
+			opts := importer.Options{LedgerUUID: f.ledger.UUID, AccountUUID: f.checking.UUID, DefaultCategoryUUID: "missing"}
+			err := withClient(func(c *client.Client) error {
+				_, err := importer.Import(ctx, c, opts, txs)
+				return err
+			})
+			is.True(err != nil)
+
+			is.NoErr(withClient(func(c *client.Client) error {
+				after, err := c.GetAccountBalance(ctx, f.checking.UUID)
+				is.NoErr(err)
+				is.Equal(after, before) // the first line was rolled back with the second
+				return nil
+			}))
+		},
+	)
+
+	t.Run(
+		"Reimport", func(t *testing.T) {
+			is := is_.New(t)
+
+			txs := []importer.Transaction{
+				{Date: day(10), Amount: -1500, Description: "Pharmacy", ExternalID: "FIT-1", Memo: "card 1234", Metadata: map[string]string{"trntype": "POS"}},
+				{Date: day(11), Amount: -2500, Description: "Hardware store", ExternalID: "FIT-2"},
+			}
+			opts := importer.Options{LedgerUUID: f.ledger.UUID, AccountUUID: f.checking.UUID}
+
+			var first *importer.Result
+			is.NoErr(withClient(func(c *client.Client) (err error) {
+				first, err = importer.Import(ctx, c, opts, txs)
+				return err
+			}))
+			is.Equal(len(first.Imported), 2)
+
+			// the same statement plus one new line, as overlapping downloads look
+			again := append(txs, importer.Transaction{Date: day(12), Amount: -500, Description: "Parking", ExternalID: "FIT-3"})
+			is.NoErr(withClient(func(c *client.Client) error {
+				result, err := importer.Import(ctx, c, opts, again)
+				if err != nil {
+					return err
+				}
+				is.Equal(len(result.Imported), 1)
+				is.Equal(result.Duplicates, 2)
+				return nil
+			}))
+
+			// the same FITID in another account is a different transaction
+			is.NoErr(withClient(func(c *client.Client) error {
+				result, err := importer.Import(ctx, c, importer.Options{LedgerUUID: f.ledger.UUID, AccountUUID: f.card.UUID}, txs[:1])
+				if err != nil {
+					return err
+				}
+				is.Equal(len(result.Imported), 1)
+				return nil
+			}))
+
+			is.NoErr(client.WithUser(ctx, pool, userID, func(tx pgx.Tx) error {
+				var fitID, memo, trnType string
+				err := tx.QueryRow(
+					ctx,
+					`select metadata->>'fitid', metadata->>'memo', metadata->>'trntype'
+					   from api.transactions where uuid = $1`,
+					first.Imported[0],
+				).Scan(&fitID, &memo, &trnType)
+				is.NoErr(err)
+				is.Equal(fitID, "FIT-1")
+				is.Equal(memo, "card 1234")
+				is.Equal(trnType, "POS")
+				return nil
+			}))
+		},
+	)
+
+	t.Run(
+		"CreateCategories", func(t *testing.T) {
+			is := is_.New(t)
+
+			txs := []importer.Transaction{
+				{Date: day(13), Amount: -1200, Description: "Novel", Category: "Books"},
+				{Date: day(14), Amount: -900, Description: "Magazine", Category: "Books"},
+				{Date: day(15), Amount: -30000, Description: "Train", Category: "Travel"},
+				{Date: day(16), Amount: -800, Description: "Bread", Category: "Groceries"},
+			}
+			opts := importer.Options{LedgerUUID: f.ledger.UUID, AccountUUID: f.checking.UUID, CreateCategories: true}
+
+			is.NoErr(withClient(func(c *client.Client) error {
+				dryRun := opts
+				dryRun.DryRun = true
+				result, err := importer.Import(ctx, c, dryRun, txs)
+				if err != nil {
+					return err
+				}
+				is.Equal(result.CreatedCategories, []string{"Books", "Travel"})
+
+				_, err = c.FindCategoryUUID(ctx, f.ledger.UUID, "Books")
+				is.True(errors.Is(err, client.ErrCategoryNotFound)) // a dry run creates nothing
+				return nil
+			}))
+
+			is.NoErr(withClient(func(c *client.Client) error {
+				result, err := importer.Import(ctx, c, opts, txs)
+				if err != nil {
+					return err
+				}
+				is.Equal(result.CreatedCategories, []string{"Books", "Travel"})
+				is.Equal(len(result.Imported), 4)
+
+				rows, err := c.GetAccountTransactions(ctx, f.checking.UUID)
+				is.NoErr(err)
+				categories := map[string]string{}
+				for _, row := range rows {
+					categories[row.Description] = row.Category
+				}
+				is.Equal(categories["Magazine"], "Books")
+				is.Equal(categories["Train"], "Travel")
+				return nil
+			}))
+		},
+	)
+
+	t.Run(
+		"Fingerprints", func(t *testing.T) {
+			is := is_.New(t)
+
+			var g fixture
+			is.NoErr(withClient(func(c *client.Client) error {
+				g = newFixture(ctx, t, c, "Fingerprint Ledger")
+				return nil
+			}))
+
+			// CSV lines carry no external id; two identical coffees are two lines
+			txs := []importer.Transaction{
+				{Date: day(20), Amount: -350, Description: "Coffee"},
+				{Date: day(20), Amount: -350, Description: "Coffee"},
+				{Date: day(21), Amount: -4200, Description: "Books"},
+			}
+			opts := importer.Options{LedgerUUID: g.ledger.UUID, AccountUUID: g.checking.UUID, Source: "march.csv"}
+
+			is.NoErr(withClient(func(c *client.Client) error {
+				result, err := importer.Import(ctx, c, opts, txs)
+				if err != nil {
+					return err
+				}
+				is.Equal(len(result.Imported), 3)
+				is.True(result.BatchUUID != "")
+				return nil
+			}))
+
+			// the same file again, with a third coffee added
+			again := append(txs, importer.Transaction{Date: day(20), Amount: -350, Description: "COFFEE"})
+			is.NoErr(withClient(func(c *client.Client) error {
+				result, err := importer.Import(ctx, c, opts, again)
+				if err != nil {
+					return err
+				}
+				is.Equal(result.Duplicates, 3)
+				is.Equal(len(result.Imported), 1)
+				is.True(result.Matches[0].Exact)
+				return nil
+			}))
+
+			is.NoErr(client.WithUser(ctx, pool, userID, func(tx pgx.Tx) error {
+				var batches, imported, skipped int
+				err := tx.QueryRow(
+					ctx,
+					`select count(*), sum(imported), sum(skipped)
+					   from api.import_batches where account_uuid = $1 and source = 'march.csv'`,
+					g.checking.UUID,
+				).Scan(&batches, &imported, &skipped)
+				is.NoErr(err)
+				is.Equal(batches, 2)
+				is.Equal(imported, 4)
+				is.Equal(skipped, 3)
+				return nil
+			}))
+		},
+	)
+
+	t.Run(
+		"LikelyDuplicates", func(t *testing.T) {
+			is := is_.New(t)
+
+			var g fixture
+			var entered string
+			is.NoErr(withClient(func(c *client.Client) (err error) {
+				g = newFixture(ctx, t, c, "Likely Duplicate Ledger")
+				// entered by hand on the day of purchase
+				entered, err = c.AddTransaction(
+					ctx, client.AddTransactionParams{
+						LedgerUUID:   g.ledger.UUID,
+						Date:         day(3),
+						Description:  "Corner market",
+						Type:         client.Outflow,
+						Amount:       6400,
+						AccountUUID:  g.checking.UUID,
+						CategoryUUID: g.groceries.UUID,
+					},
+				)
+				return err
+			}))
+
+			// the bank posts it two days later
+			line := importer.Transaction{Date: day(5), Amount: -6400, Description: "CORNER MARKET #12", ExternalID: "FIT-CM"}
+			opts := importer.Options{LedgerUUID: g.ledger.UUID, AccountUUID: g.checking.UUID}
+
+			is.NoErr(withClient(func(c *client.Client) error {
+				result, err := importer.Import(ctx, c, opts, []importer.Transaction{line})
+				if err != nil {
+					return err
+				}
+				is.Equal(len(result.Imported), 0)
+				is.Equal(result.Duplicates, 1)
+				is.Equal(result.Matches[0].TransactionUUID, entered)
+				is.Equal(result.Matches[0].Days, -2)
+				is.True(!result.Matches[0].Exact)
+				return nil
+			}))
+
+			// outside a one day window it is a new transaction; rolled back
+			err := withClient(func(c *client.Client) error {
+				result, err := importer.Import(ctx, c, importer.Options{LedgerUUID: g.ledger.UUID, AccountUUID: g.checking.UUID, DateWindow: 1}, []importer.Transaction{line})
+				if err != nil {
+					return err
+				}
+				is.Equal(len(result.Imported), 1)
+				return errRollback
+			})
+			is.True(errors.Is(err, errRollback))
+
+			// force records it anyway; rolled back as well
+			err = withClient(func(c *client.Client) error {
+				result, err := importer.Import(ctx, c, importer.Options{LedgerUUID: g.ledger.UUID, AccountUUID: g.checking.UUID, Duplicates: importer.ForceDuplicates}, []importer.Transaction{line})
+				if err != nil {
+					return err
+				}
+				is.Equal(result.Forced, 1)
+				is.Equal(len(result.Imported), 1)
+				is.True(!result.Matches[0].Exact) // skipping the line before did not make it exact
+				return errRollback
+			})
+			is.True(errors.Is(err, errRollback))
+
+			// merge stores the FITID on the hand-entered transaction
+			opts.Duplicates = importer.MergeDuplicates
+			is.NoErr(withClient(func(c *client.Client) error {
+				result, err := importer.Import(ctx, c, opts, []importer.Transaction{line})
+				if err != nil {
+					return err
+				}
+				is.Equal(result.Merged, 1)
+				is.Equal(len(result.Imported), 0)
+				return nil
+			}))
+
+			// so the next download, even with another description, is an exact match
+			line.Description = "Corner Market"
+			is.NoErr(withClient(func(c *client.Client) error {
+				result, err := importer.Import(ctx, c, importer.Options{LedgerUUID: g.ledger.UUID, AccountUUID: g.checking.UUID, Duplicates: importer.ForceDuplicates}, []importer.Transaction{line})
+				if err != nil {
+					return err
+				}
+				is.Equal(result.Duplicates, 1)
+				is.True(result.Matches[0].Exact)
+				return nil
+			}))
+
+			is.NoErr(client.WithUser(ctx, pool, userID, func(tx pgx.Tx) error {
+				var fitID string
+				err := tx.QueryRow(ctx, `select metadata->>'fitid' from api.transactions where uuid = $1`, entered).Scan(&fitID)
+				is.NoErr(err)
+				is.Equal(fitID, "FIT-CM")
+				return nil
+			}))
+		},
+	)
+
+	t.Run(
+		"ReimportAfterDelete", func(t *testing.T) {
+			is := is_.New(t)
+
+			var g fixture
+			is.NoErr(withClient(func(c *client.Client) error {
+				g = newFixture(ctx, t, c, "Reimport Ledger")
+				return nil
+			}))
+
+			// one line with a bank id, one without
+			txs := []importer.Transaction{
+				{Date: day(8), Amount: -2500, Description: "Pharmacy", ExternalID: "FIT-PH"},
+				{Date: day(9), Amount: -1800, Description: "Bakery"},
+			}
+			opts := importer.Options{LedgerUUID: g.ledger.UUID, AccountUUID: g.checking.UUID}
+
+			is.NoErr(withClient(func(c *client.Client) error {
+				result, err := importer.Import(ctx, c, opts, txs)
+				if err != nil {
+					return err
+				}
+				is.Equal(len(result.Imported), 2)
+
+				for _, uuid := range result.Imported {
+					if _, err := c.DeleteTransaction(ctx, uuid, "Imported by mistake"); err != nil {
+						return err
+					}
+				}
+				return nil
+			}))
+
+			// deleted transactions and their reversals match nothing
+			is.NoErr(withClient(func(c *client.Client) error {
+				result, err := importer.Import(ctx, c, opts, txs)
+				if err != nil {
+					return err
+				}
+				is.Equal(result.Duplicates, 0)
+				is.Equal(len(result.Imported), 2)
+
+				balance, err := c.GetAccountBalance(ctx, g.checking.UUID)
+				is.NoErr(err)
+				is.Equal(balance, int64(-2500-1800))
+				return nil
+			}))
+		},
+	)
+
+	t.Run(
+		"Splits", func(t *testing.T) {
+			is := is_.New(t)
+
+			line := importer.Transaction{
+				Date: day(12), Amount: -12050, Description: "Corner Market", Memo: "Weekly shop",
+				Metadata: map[string]string{"checknum": "1042"},
+				Splits: []importer.Split{
+					{Amount: -10000, Category: "Groceries", Memo: "Food"},
+					{Amount: -2050, Category: "Household", Metadata: map[string]string{"class": "Home"}},
+				},
+			}
+			opts := importer.Options{LedgerUUID: f.ledger.UUID, AccountUUID: f.checking.UUID}
+
+			err := client.WithUser(ctx, pool, userID, func(tx pgx.Tx) error {
+				c := client.New(tx)
+				before, err := c.GetAccountBalance(ctx, f.checking.UUID)
+				is.NoErr(err)
+
+				result, err := importer.Import(ctx, c, opts, []importer.Transaction{line})
+				if err != nil {
+					return err
+				}
+				is.Equal(len(result.Imported), 1)
+
+				after, err := c.GetAccountBalance(ctx, f.checking.UUID)
+				is.NoErr(err)
+				is.Equal(after-before, int64(-12050))
+
+				// one split transaction, the unknown category falling back to Unassigned
+				rows, err := c.GetAccountTransactions(ctx, f.checking.UUID)
+				is.NoErr(err)
+				var splitUUID string
+				for _, row := range rows {
+					if row.Split {
+						splitUUID = row.UUID
+					}
+				}
+				split, err := c.GetSplitTransaction(ctx, splitUUID)
+				is.NoErr(err)
+				is.Equal(split.Splits[0].UUID, result.Imported[0])
+				is.Equal(split.Amount, int64(12050))
+				is.Equal(split.Description, "Corner Market")
+				is.Equal([]string{split.Splits[0].Category, split.Splits[1].Category}, []string{"Groceries", "Unassigned"})
+
+				// the legs keep the details of the line and of their split
+				var memo, checknum, class string
+				err = tx.QueryRow(ctx, `select metadata->>'memo', metadata->>'checknum', coalesce(metadata->>'class', '') from api.transactions where uuid = $1`, split.Splits[0].UUID).Scan(&memo, &checknum, &class)
+				is.NoErr(err)
+				is.Equal([]string{memo, checknum, class}, []string{"Food", "1042", ""})
+				err = tx.QueryRow(ctx, `select metadata->>'memo', metadata->>'checknum', coalesce(metadata->>'class', '') from api.transactions where uuid = $1`, split.Splits[1].UUID).Scan(&memo, &checknum, &class)
+				is.NoErr(err)
+				is.Equal([]string{memo, checknum, class}, []string{"Weekly shop", "1042", "Home"})
+				return errRollback
+			})
+			is.True(errors.Is(err, errRollback))
+
+			// a paycheck with deductions is not one posting shared by categories
+			paycheck := importer.Transaction{
+				Date: day(12), Amount: 150000, Description: "ACME Payroll",
+				Splits: []importer.Split{
+					{Amount: 200000, Category: "Income"},
+					{Amount: -50000, Category: "Taxes"},
+				},
+			}
+			err = withClient(func(c *client.Client) error {
+				_, err := importer.Import(ctx, c, opts, []importer.Transaction{paycheck})
+				return err
+			})
+			is.True(errors.Is(err, importer.ErrInvalidSplits))
+		},
+	)
+
+	t.Run(
+		"UnsupportedAccount", func(t *testing.T) {
+			is := is_.New(t)
+
+			err := withClient(func(c *client.Client) error {
+				_, err := importer.Import(ctx, c, importer.Options{LedgerUUID: f.ledger.UUID, AccountUUID: f.groceries.UUID}, nil)
+				return err
+			})
+			is.True(errors.Is(err, importer.ErrUnsupportedAccount))
+
+			err = withClient(func(c *client.Client) error {
+				_, err := importer.Import(ctx, c, importer.Options{LedgerUUID: "missing", AccountUUID: f.checking.UUID}, nil)
+				return err
+			})
+			is.True(errors.Is(err, client.ErrAccountNotFound))
+		},
+	)
+}
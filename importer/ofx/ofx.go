@@ -0,0 +1,307 @@
+// Package ofx parses OFX 1.x (SGML) and 2.x (XML) statements, including
+// Quicken's QFX flavour, into importer transactions.
+//
+// Each STMTTRN becomes one importer.Transaction whose ExternalID is the
+// FITID, so re-importing a statement skips the lines already recorded.
+// TRNAMT is signed from the account holder's point of view for bank and
+// credit card statements alike, which matches importer.Transaction.Amount.
+package ofx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/j0lvera/pgbudget/importer"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Statement is one account statement (STMTRS or CCSTMTRS) of an OFX file.
+type Statement struct {
+	// AccountID is the ACCTID of the statement's account.
+	AccountID string
+	// CreditCard is set for credit card statements.
+	CreditCard bool
+	// Currency is the CURDEF of the statement, such as "USD".
+	Currency     string
+	Transactions []importer.Transaction
+}
+
+// ErrNoStatement is returned when a file holds no bank or credit card
+// statement.
+var ErrNoStatement = errors.New("ofx: no statement found")
+
+// Parse reads every statement of an OFX or QFX file.
+func Parse(r io.Reader) ([]Statement, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("ofx: %w", err)
+	}
+
+	start := bytes.Index(bytes.ToUpper(raw), []byte("<OFX>"))
+	if start < 0 {
+		return nil, errors.New("ofx: missing <OFX> element")
+	}
+	body, err := decodeBody(raw[:start], raw[start:])
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		statements []Statement
+		stmt       *Statement
+		tx         *statementLine
+		stack      []string
+	)
+
+	for _, tok := range tokenize(body) {
+		switch {
+		case tok.open:
+			stack = append(stack, tok.name)
+			switch tok.name {
+			case "STMTRS", "CCSTMTRS":
+				stmt = &Statement{CreditCard: tok.name == "CCSTMTRS"}
+			case "STMTTRN":
+				if stmt != nil {
+					tx = &statementLine{}
+				}
+			}
+
+		case tok.close:
+			// elements left open in SGML are closed by the enclosing end tag
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i] == tok.name {
+					stack = stack[:i]
+					break
+				}
+			}
+			switch tok.name {
+			case "STMTTRN":
+				if stmt != nil && tx != nil {
+					line, err := tx.transaction()
+					if err != nil {
+						return nil, fmt.Errorf("ofx: transaction %d: %w", len(stmt.Transactions)+1, err)
+					}
+					stmt.Transactions = append(stmt.Transactions, line)
+				}
+				tx = nil
+			case "STMTRS", "CCSTMTRS":
+				if stmt != nil {
+					statements = append(statements, *stmt)
+				}
+				stmt = nil
+			}
+
+		default:
+			if len(stack) == 0 || stmt == nil {
+				continue
+			}
+			name := stack[len(stack)-1]
+			// a value ends an SGML leaf element even without its end tag
+			stack = stack[:len(stack)-1]
+
+			switch {
+			case tx != nil:
+				tx.set(name, tok.text)
+			case name == "ACCTID":
+				stmt.AccountID = tok.text
+			case name == "CURDEF":
+				stmt.Currency = tok.text
+			}
+		}
+	}
+
+	if len(statements) == 0 {
+		return nil, ErrNoStatement
+	}
+	return statements, nil
+}
+
+// statementLine collects the fields of one STMTTRN.
+type statementLine struct {
+	trnType, posted, amount, fitID, name, memo, checkNum, refNum string
+}
+
+func (l *statementLine) set(name, value string) {
+	switch name {
+	case "TRNTYPE":
+		l.trnType = value
+	case "DTPOSTED":
+		l.posted = value
+	case "TRNAMT":
+		l.amount = value
+	case "FITID":
+		l.fitID = value
+	case "NAME":
+		l.name = value
+	case "MEMO":
+		l.memo = value
+	case "CHECKNUM":
+		l.checkNum = value
+	case "REFNUM":
+		l.refNum = value
+	}
+}
+
+func (l *statementLine) transaction() (importer.Transaction, error) {
+	date, err := ParseDate(l.posted)
+	if err != nil {
+		return importer.Transaction{}, err
+	}
+	amount, err := ParseAmount(l.amount)
+	if err != nil {
+		return importer.Transaction{}, err
+	}
+
+	description, memo := l.name, l.memo
+	if description == "" {
+		description, memo = memo, ""
+	}
+
+	return importer.Transaction{
+		Date:        date,
+		Amount:      amount,
+		Description: description,
+		Memo:        memo,
+		ExternalID:  l.fitID,
+		Metadata: map[string]string{
+			"trntype":  l.trnType,
+			"checknum": l.checkNum,
+			"refnum":   l.refNum,
+		},
+	}, nil
+}
+
+// ParseDate reads the date part of an OFX datetime such as
+// "20250103", "20250103120000" or "20250103120000.000[-5:EST]".
+func ParseDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	date, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return date, nil
+}
+
+// ParseAmount converts an OFX amount such as "-12.5" or "+1200,00" into
+// cents. Extra decimal places are accepted as long as they are zero.
+func ParseAmount(value string) (int64, error) {
+	s := strings.TrimSpace(value)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+
+	whole, frac, _ := strings.Cut(strings.Replace(s, ",", ".", 1), ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if len(frac) > 2 {
+		if strings.Trim(frac[2:], "0") != "" {
+			return 0, fmt.Errorf("invalid amount %q: more than two decimals", value)
+		}
+		frac = frac[:2]
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("invalid amount %q", value)
+		}
+	}
+	cents, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if negative {
+		cents = -cents
+	}
+	return cents, nil
+}
+
+// token is an element start, an element end or a text value.
+type token struct {
+	name        string
+	text        string
+	open, close bool
+}
+
+// tokenize splits an OFX body into tokens. Processing instructions and
+// comments are dropped, and text is trimmed and unescaped.
+func tokenize(body string) []token {
+	var tokens []token
+	for len(body) > 0 {
+		lt := strings.IndexByte(body, '<')
+		if lt < 0 {
+			lt = len(body)
+		}
+		if text := strings.TrimSpace(body[:lt]); text != "" {
+			tokens = append(tokens, token{text: html.UnescapeString(text)})
+		}
+		body = body[lt:]
+		if body == "" {
+			break
+		}
+
+		gt := strings.IndexByte(body, '>')
+		if gt < 0 {
+			break
+		}
+		tag := body[1:gt]
+		body = body[gt+1:]
+
+		switch {
+		case tag == "" || tag[0] == '?' || tag[0] == '!':
+		case tag[0] == '/':
+			tokens = append(tokens, token{name: strings.ToUpper(strings.TrimSpace(tag[1:])), close: true})
+		case strings.HasSuffix(tag, "/"):
+			// an empty XML element carries no value
+		default:
+			name, _, _ := strings.Cut(strings.TrimSpace(tag), " ")
+			tokens = append(tokens, token{name: strings.ToUpper(name), open: true})
+		}
+	}
+	return tokens
+}
+
+var (
+	sgmlCharset  = regexp.MustCompile(`(?im)^\s*CHARSET:\s*(\S+)`)
+	sgmlEncoding = regexp.MustCompile(`(?im)^\s*ENCODING:\s*(\S+)`)
+	xmlEncoding  = regexp.MustCompile(`(?i)<\?xml[^>]*encoding=["']([^"']+)["']`)
+)
+
+// decodeBody converts the body to UTF-8 according to the file header.
+// OFX 1.x declares ENCODING:USASCII or UTF-8 and a Windows code page in
+// CHARSET; OFX 2.x uses the XML declaration.
+func decodeBody(header, body []byte) (string, error) {
+	var charset string
+	if m := xmlEncoding.FindSubmatch(header); m != nil {
+		charset = string(m[1])
+	} else if m := sgmlEncoding.FindSubmatch(header); m != nil && strings.EqualFold(string(m[1]), "UTF-8") {
+		charset = "utf-8"
+	} else if m := sgmlCharset.FindSubmatch(header); m != nil {
+		charset = string(m[1])
+	}
+
+	switch strings.ToUpper(charset) {
+	case "", "NONE", "UTF-8", "USASCII", "US-ASCII":
+		return string(body), nil
+	case "1252", "WINDOWS-1252", "CP1252":
+		out, err := charmap.Windows1252.NewDecoder().Bytes(body)
+		return string(out), err
+	case "ISO-8859-1", "8859-1", "LATIN1":
+		out, err := charmap.ISO8859_1.NewDecoder().Bytes(body)
+		return string(out), err
+	}
+
+	return "", fmt.Errorf("ofx: unsupported charset %q", charset)
+}
@@ -0,0 +1,198 @@
+package ofx_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/pgbudget/importer/ofx"
+	is_ "github.com/matryer/is"
+)
+
+const sgmlStatement = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+SECURITY:NONE
+ENCODING:USASCII
+CHARSET:1252
+COMPRESSION:NONE
+OLDFILEUID:NONE
+NEWFILEUID:NONE
+
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0<SEVERITY>INFO</STATUS><DTSERVER>20250131120000<LANGUAGE>ENG</SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1>
+<STMTTRNRS>
+<TRNUID>1
+<STMTRS>
+<CURDEF>USD
+<BANKACCTFROM>
+<BANKID>121000248
+<ACCTID>000123456
+<ACCTTYPE>CHECKING
+</BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>20250101
+<DTEND>20250131
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20250103120000.000[-5:EST]
+<TRNAMT>2500.00
+<FITID>202501030001
+<NAME>ACME PAYROLL
+<MEMO>January salary
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CHECK
+<DTPOSTED>20250110
+<TRNAMT>-120.5
+<FITID>202501100002
+<CHECKNUM>1042
+<NAME>Caf` + "\xe9" + ` &amp; Bakery
+</STMTTRN>
+</BANKTRANLIST>
+<LEDGERBAL><BALAMT>2379.50<DTASOF>20250131</LEDGERBAL>
+</STMTRS>
+</STMTTRNRS>
+</BANKMSGSRSV1>
+</OFX>
+`
+
+const xmlStatement = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+  <CREDITCARDMSGSRSV1>
+    <CCSTMTTRNRS>
+      <TRNUID>1</TRNUID>
+      <CCSTMTRS>
+        <CURDEF>EUR</CURDEF>
+        <CCACCTFROM><ACCTID>4111XXXXXXXX1111</ACCTID></CCACCTFROM>
+        <BANKTRANLIST>
+          <STMTTRN>
+            <TRNTYPE>DEBIT</TRNTYPE>
+            <DTPOSTED>20250205</DTPOSTED>
+            <TRNAMT>-45.1000</TRNAMT>
+            <FITID>CC-1</FITID>
+            <PAYEE><NAME>Grocer</NAME></PAYEE>
+            <MEMO/>
+          </STMTTRN>
+          <STMTTRN>
+            <TRNTYPE>PAYMENT</TRNTYPE>
+            <DTPOSTED>20250220</DTPOSTED>
+            <TRNAMT>300.00</TRNAMT>
+            <FITID>CC-2</FITID>
+            <MEMO>Thank you</MEMO>
+          </STMTTRN>
+        </BANKTRANLIST>
+      </CCSTMTRS>
+    </CCSTMTTRNRS>
+  </CREDITCARDMSGSRSV1>
+</OFX>
+`
+
+func TestParse(t *testing.T) {
+	t.Run(
+		"SGML", func(t *testing.T) {
+			is := is_.New(t)
+
+			statements, err := ofx.Parse(strings.NewReader(sgmlStatement))
+			is.NoErr(err)
+			is.Equal(len(statements), 1)
+
+			stmt := statements[0]
+			is.Equal(stmt.AccountID, "000123456")
+			is.Equal(stmt.Currency, "USD")
+			is.True(!stmt.CreditCard)
+			is.Equal(len(stmt.Transactions), 2)
+
+			salary := stmt.Transactions[0]
+			is.Equal(salary.Date, time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC))
+			is.Equal(salary.Amount, int64(250000))
+			is.Equal(salary.ExternalID, "202501030001")
+			is.Equal(salary.Description, "ACME PAYROLL")
+			is.Equal(salary.Memo, "January salary")
+			is.Equal(salary.Metadata["trntype"], "CREDIT")
+
+			check := stmt.Transactions[1]
+			is.Equal(check.Amount, int64(-12050))
+			is.Equal(check.Description, "Café & Bakery") // windows-1252 and entities are decoded
+			is.Equal(check.Metadata["checknum"], "1042")
+		},
+	)
+
+	t.Run(
+		"XML", func(t *testing.T) {
+			is := is_.New(t)
+
+			statements, err := ofx.Parse(strings.NewReader(xmlStatement))
+			is.NoErr(err)
+			is.Equal(len(statements), 1)
+
+			stmt := statements[0]
+			is.True(stmt.CreditCard)
+			is.Equal(stmt.AccountID, "4111XXXXXXXX1111")
+			is.Equal(stmt.Currency, "EUR")
+			is.Equal(len(stmt.Transactions), 2)
+
+			charge := stmt.Transactions[0]
+			is.Equal(charge.Amount, int64(-4510))
+			is.Equal(charge.Description, "Grocer") // NAME nested in PAYEE
+			is.Equal(charge.Memo, "")
+
+			payment := stmt.Transactions[1]
+			is.Equal(payment.Amount, int64(30000))
+			is.Equal(payment.Description, "Thank you") // MEMO stands in for a missing NAME
+			is.Equal(payment.ExternalID, "CC-2")
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			is := is_.New(t)
+
+			_, err := ofx.Parse(strings.NewReader("not an ofx file"))
+			is.True(err != nil)
+
+			_, err = ofx.Parse(strings.NewReader("<OFX><SIGNONMSGSRSV1></SIGNONMSGSRSV1></OFX>"))
+			is.True(errors.Is(err, ofx.ErrNoStatement))
+
+			_, err = ofx.Parse(strings.NewReader("<OFX><STMTRS><STMTTRN><DTPOSTED>2025<TRNAMT>1</STMTTRN></STMTRS></OFX>"))
+			is.True(err != nil) // truncated date
+		},
+	)
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"12", 1200, false},
+		{"+12.5", 1250, false},
+		{"-0.01", -1, false},
+		{"-1200,00", -120000, false},
+		{"3.1400", 314, false},
+		{".5", 50, false},
+		{"3.145", 0, true},
+		{"1,000.00", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.value, func(t *testing.T) {
+				is := is_.New(t)
+
+				got, err := ofx.ParseAmount(tt.value)
+				if tt.wantErr {
+					is.True(err != nil)
+					return
+				}
+				is.NoErr(err)
+				is.Equal(got, tt.want)
+			},
+		)
+	}
+}
@@ -0,0 +1,356 @@
+// Package qif reads and writes the Quicken Interchange Format used by
+// Quicken, older GnuCash releases and Microsoft Money.
+//
+// Only the cash-like account types are supported: !Type:Bank, !Type:CCard,
+// !Type:Cash, !Type:Oth A and !Type:Oth L. Lists (categories, classes,
+// memorized transactions) and investment accounts are skipped.
+package qif
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/j0lvera/pgbudget/importer"
+)
+
+// Account holds the transactions of one account section of a QIF file.
+type Account struct {
+	// Name comes from the preceding !Account block; it is empty for files
+	// that hold a single account.
+	Name string
+	// Type is the section type without the "!Type:" prefix, such as "Bank".
+	Type         string
+	Transactions []importer.Transaction
+}
+
+// Options controls how ambiguous fields are read.
+type Options struct {
+	// DayFirst reads dates as day/month/year instead of the US month/day/year.
+	DayFirst bool
+	// DecimalComma reads "1.234,56" style amounts.
+	DecimalComma bool
+}
+
+// supported lists the section types holding cash-like transactions.
+var supported = map[string]bool{
+	"bank":  true,
+	"ccard": true,
+	"cash":  true,
+	"oth a": true,
+	"oth l": true,
+}
+
+// Parse reads every cash-like account section of a QIF file.
+//
+// A transaction with splits (S, E and $ lines) keeps them in
+// importer.Transaction.Splits, each with its own category, memo and amount,
+// so that it is recorded as one split transaction. The part of the amount
+// the splits leave uncovered is a split in the main category. Transfers
+// ("[Account]" categories) keep no category; the other account's name is
+// kept in Metadata["transfer"]. A class ("Category/Class") goes to
+// Metadata["class"].
+func Parse(r io.Reader, opts Options) ([]Account, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var (
+		accounts    []Account
+		current     *Account
+		accountName string
+		inAccount   bool // inside an !Account block
+		entry       record
+		lineNo      int
+	)
+
+	flush := func() error {
+		defer func() { entry = record{} }()
+		if entry.empty() || current == nil {
+			return nil
+		}
+		tx, err := entry.transaction(opts)
+		if err != nil {
+			return fmt.Errorf("qif: line %d: %w", entry.line, err)
+		}
+		current.Transactions = append(current.Transactions, tx)
+		return nil
+	}
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if line[0] == '!' {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			header := strings.TrimSpace(line[1:])
+			switch {
+			case strings.EqualFold(header, "Account"):
+				inAccount = true
+				current = nil
+			case strings.HasPrefix(strings.ToLower(header), "type:"):
+				inAccount = false
+				sectionType := strings.TrimSpace(header[len("type:"):])
+				current = nil
+				if supported[strings.ToLower(sectionType)] {
+					accounts = append(accounts, Account{Name: accountName, Type: sectionType})
+					current = &accounts[len(accounts)-1]
+				}
+			default:
+				// !Option and !Clear directives do not change the section
+			}
+			continue
+		}
+
+		if inAccount {
+			if line[0] == 'N' {
+				accountName = strings.TrimSpace(line[1:])
+			}
+			continue
+		}
+
+		if line[0] == '^' {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+		if entry.empty() {
+			entry.line = lineNo
+		}
+		entry.set(line[0], strings.TrimSpace(line[1:]))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("qif: %w", err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+// record collects the fields of one transaction, up to its "^" line.
+type record struct {
+	line                                int
+	date, amount, payee, memo, category string
+	number, cleared                     string
+	splits                              []split
+	fields                              int
+}
+
+type split struct {
+	category, memo, amount string
+}
+
+func (r *record) empty() bool {
+	return r.fields == 0
+}
+
+func (r *record) set(code byte, value string) {
+	r.fields++
+	switch code {
+	case 'D':
+		r.date = value
+	case 'T', 'U':
+		if r.amount == "" {
+			r.amount = value
+		}
+	case 'P':
+		r.payee = value
+	case 'M':
+		r.memo = value
+	case 'L':
+		r.category = value
+	case 'N':
+		r.number = value
+	case 'C':
+		r.cleared = value
+	case 'S':
+		r.splits = append(r.splits, split{category: value})
+	case 'E':
+		if n := len(r.splits); n > 0 {
+			r.splits[n-1].memo = value
+		}
+	case '$':
+		if n := len(r.splits); n > 0 {
+			r.splits[n-1].amount = value
+		}
+	}
+}
+
+func (r *record) transaction(opts Options) (importer.Transaction, error) {
+	date, err := ParseDate(r.date, opts.DayFirst)
+	if err != nil {
+		return importer.Transaction{}, err
+	}
+	total, err := ParseAmount(r.amount, opts.DecimalComma)
+	if err != nil {
+		return importer.Transaction{}, err
+	}
+
+	tx := importer.Transaction{
+		Date:        date,
+		Amount:      total,
+		Description: r.payee,
+		Memo:        r.memo,
+	}
+	if tx.Description == "" {
+		tx.Description, tx.Memo = r.memo, ""
+	}
+	metadata := map[string]string{"checknum": r.number, "cleared": r.cleared}
+
+	var splits []importer.Split
+	remaining := total
+	for i, s := range r.splits {
+		amount, err := ParseAmount(s.amount, opts.DecimalComma)
+		if err != nil {
+			return importer.Transaction{}, fmt.Errorf("split %d: %w", i+1, err)
+		}
+		if amount == 0 {
+			continue
+		}
+		remaining -= amount
+
+		split := importer.Split{Amount: amount, Memo: s.memo}
+		split.Category, split.Metadata = category(s.category, map[string]string{})
+		splits = append(splits, split)
+	}
+
+	// splits that do not add up to the total leave the rest in the main category
+	if len(splits) > 0 && remaining != 0 {
+		split := importer.Split{Amount: remaining}
+		split.Category, split.Metadata = category(r.category, map[string]string{})
+		splits = append(splits, split)
+	}
+
+	if len(splits) == 1 {
+		// a single split is the whole transaction
+		tx.Category = splits[0].Category
+		if splits[0].Memo != "" {
+			tx.Memo = splits[0].Memo
+		}
+		for k, v := range splits[0].Metadata {
+			metadata[k] = v
+		}
+		tx.Metadata = metadata
+		return tx, nil
+	}
+
+	tx.Category, tx.Metadata = category(r.category, metadata)
+	if len(splits) > 1 {
+		tx.Splits = splits
+	}
+
+	return tx, nil
+}
+
+// category splits a QIF category field into the pgbudget category name and
+// metadata for its class or transfer account.
+func category(field string, metadata map[string]string) (string, map[string]string) {
+	name, class, _ := strings.Cut(field, "/")
+	if class != "" {
+		metadata["class"] = class
+	}
+
+	name = strings.TrimSpace(name)
+	if strings.HasPrefix(name, "[") && strings.HasSuffix(name, "]") {
+		metadata["transfer"] = strings.TrimSpace(name[1 : len(name)-1])
+		return "", metadata
+	}
+	return name, metadata
+}
+
+// ParseDate reads the date forms written by Quicken and friends:
+// "1/ 3/25", "1/3'25", "01/03/2025", "01.03.2025" and "2025-01-03". The
+// apostrophe marks a year in the 2000s; other two-digit years below 70 are
+// read as 20xx as well.
+func ParseDate(value string, dayFirst bool) (time.Time, error) {
+	s := strings.ReplaceAll(strings.TrimSpace(value), " ", "")
+	apostrophe := strings.Contains(s, "'")
+	s = strings.NewReplacer("'", "/", "-", "/", ".", "/").Replace(s)
+
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+
+	nums := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q", value)
+		}
+		nums[i] = n
+	}
+
+	var year, month, day int
+	switch {
+	case len(parts[0]) == 4:
+		year, month, day = nums[0], nums[1], nums[2]
+	case dayFirst:
+		day, month, year = nums[0], nums[1], nums[2]
+	default:
+		month, day, year = nums[0], nums[1], nums[2]
+	}
+
+	if len(parts[2]) <= 2 && len(parts[0]) != 4 {
+		if apostrophe || year < 70 {
+			year += 2000
+		} else {
+			year += 1900
+		}
+	}
+
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Year() != year || int(date.Month()) != month || date.Day() != day {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return date, nil
+}
+
+// ParseAmount converts a QIF amount such as "-1,234.56" into cents.
+func ParseAmount(value string, decimalComma bool) (int64, error) {
+	s := strings.TrimSpace(value)
+	thousands, decimal := ",", "."
+	if decimalComma {
+		thousands, decimal = ".", ","
+	}
+	s = strings.ReplaceAll(s, thousands, "")
+	s = strings.Replace(s, decimal, ".", 1)
+
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if (whole == "" && frac == "") || len(frac) > 2 {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+
+	cents, err := strconv.ParseUint(whole+frac, 10, 63)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if negative {
+		return -int64(cents), nil
+	}
+	return int64(cents), nil
+}
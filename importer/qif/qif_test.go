@@ -0,0 +1,182 @@
+package qif_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+	"github.com/j0lvera/pgbudget/importer/qif"
+	is_ "github.com/matryer/is"
+)
+
+const bankFile = `!Type:Bank
+D1/ 3'25
+T2,500.00
+PACME Payroll
+LSalary
+^
+D01/10/2025
+T-120.50
+N1042
+PCorner Market
+MWeekly shop
+LGroceries/Household
+SGroceries
+EFood
+$-100.00
+SHousehold
+$-15.50
+^
+D01/12/2025
+T-500.00
+PTo savings
+L[Savings]
+C*
+^
+`
+
+func TestParse(t *testing.T) {
+	t.Run(
+		"Bank", func(t *testing.T) {
+			is := is_.New(t)
+
+			accounts, err := qif.Parse(strings.NewReader(bankFile), qif.Options{})
+			is.NoErr(err)
+			is.Equal(len(accounts), 1)
+			is.Equal(accounts[0].Type, "Bank")
+
+			txs := accounts[0].Transactions
+			is.Equal(len(txs), 3)
+
+			is.Equal(txs[0].Date, time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC))
+			is.Equal(txs[0].Amount, int64(250000))
+			is.Equal(txs[0].Description, "ACME Payroll")
+			is.Equal(txs[0].Category, "Salary")
+			is.Equal(len(txs[0].Splits), 0)
+
+			// splits stay on their transaction with their own category, memo and amount
+			is.Equal(txs[1].Description, "Corner Market")
+			is.Equal(txs[1].Amount, int64(-12050))
+			is.Equal(txs[1].Memo, "Weekly shop")
+			is.Equal(txs[1].Metadata["checknum"], "1042")
+			is.Equal(len(txs[1].Splits), 3)
+			is.Equal(txs[1].Splits[0].Category, "Groceries")
+			is.Equal(txs[1].Splits[0].Amount, int64(-10000))
+			is.Equal(txs[1].Splits[0].Memo, "Food")
+			is.Equal(txs[1].Splits[1].Category, "Household")
+			is.Equal(txs[1].Splits[1].Amount, int64(-1550))
+			is.Equal(txs[1].Splits[1].Memo, "")
+
+			// the part not covered by the splits stays in the main category
+			is.Equal(txs[1].Splits[2].Amount, int64(-500))
+			is.Equal(txs[1].Splits[2].Category, "Groceries")
+			is.Equal(txs[1].Splits[2].Metadata["class"], "Household")
+
+			// transfers carry no category
+			is.Equal(txs[2].Category, "")
+			is.Equal(txs[2].Metadata["transfer"], "Savings")
+			is.Equal(txs[2].Metadata["cleared"], "*")
+		},
+	)
+
+	t.Run(
+		"SingleSplit", func(t *testing.T) {
+			is := is_.New(t)
+
+			input := "!Type:Bank\nD01/10/2025\nT-20.00\nPPharmacy\nLMisc\nSHealth\nEPrescription\n$-20.00\n^\n"
+
+			accounts, err := qif.Parse(strings.NewReader(input), qif.Options{})
+			is.NoErr(err)
+			tx := accounts[0].Transactions[0]
+			is.Equal(len(tx.Splits), 0) // one split is the whole transaction
+			is.Equal(tx.Category, "Health")
+			is.Equal(tx.Memo, "Prescription")
+			is.Equal(tx.Amount, int64(-2000))
+		},
+	)
+
+	t.Run(
+		"Accounts", func(t *testing.T) {
+			is := is_.New(t)
+
+			input := "!Option:AutoSwitch\n" +
+				"!Account\nNVisa\nTCCard\n^\n" +
+				"!Type:CCard\nD03/02/2025\nT-42.00\nPBookshop\nLBooks\n^\n" +
+				"!Account\nNBrokerage\nTInvst\n^\n" +
+				"!Type:Invst\nD03/02/2025\nNBuy\nYACME\n^\n" +
+				"!Type:Cat\nNBooks\nE\n^\n"
+
+			accounts, err := qif.Parse(strings.NewReader(input), qif.Options{DayFirst: true})
+			is.NoErr(err)
+			is.Equal(len(accounts), 1) // investment and list sections are skipped
+			is.Equal(accounts[0].Name, "Visa")
+			is.Equal(accounts[0].Type, "CCard")
+			is.Equal(accounts[0].Transactions[0].Date, time.Date(2025, 2, 3, 0, 0, 0, 0, time.UTC))
+			is.Equal(accounts[0].Transactions[0].Amount, int64(-4200))
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			is := is_.New(t)
+
+			_, err := qif.Parse(strings.NewReader("!Type:Bank\nD02/30/2025\nT1.00\n^\n"), qif.Options{})
+			is.True(err != nil) // no such day
+
+			_, err = qif.Parse(strings.NewReader("!Type:Bank\nD02/03/2025\nTabc\n^\n"), qif.Options{})
+			is.True(err != nil)
+		},
+	)
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value    string
+		dayFirst bool
+		want     time.Time
+	}{
+		{"1/ 3/25", false, time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"12/31'99", false, time.Date(2099, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{"12/31/99", false, time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{"31.12.2024", true, time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{"2025-06-07", false, time.Date(2025, 6, 7, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.value, func(t *testing.T) {
+				is := is_.New(t)
+
+				got, err := qif.ParseDate(tt.value, tt.dayFirst)
+				is.NoErr(err)
+				is.Equal(got, tt.want)
+			},
+		)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	is := is_.New(t)
+
+	// newest first, as api.get_account_transactions_page returns them
+	rows := []client.AccountTransaction{
+		{Date: time.Date(2025, 3, 5, 0, 0, 0, 0, time.UTC), Category: "Groceries", Description: "Market", Type: "inflow", Amount: 4210},
+		{Date: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), Category: "Unassigned", Description: "Payment\nthanks", Type: "outflow", Amount: 10000},
+	}
+
+	var out strings.Builder
+	is.NoErr(qif.Write(&out, client.AccountTypeLiability, rows))
+	is.Equal(
+		out.String(),
+		"!Type:CCard\n"+
+			"D03/01/2025\nT100.00\nPPayment thanks\nLUnassigned\n^\n"+
+			"D03/05/2025\nT-42.10\nPMarket\nLGroceries\n^\n",
+	)
+
+	// a written file reads back to the same amounts
+	accounts, err := qif.Parse(strings.NewReader(out.String()), qif.Options{})
+	is.NoErr(err)
+	is.Equal(accounts[0].Transactions[1].Amount, int64(-4210))
+	is.Equal(accounts[0].Transactions[1].Category, "Groceries")
+}
@@ -0,0 +1,60 @@
+package qif
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/j0lvera/pgbudget/client"
+	"github.com/j0lvera/pgbudget/importer"
+)
+
+// SectionType returns the QIF section type for a pgbudget account type:
+// "CCard" for liabilities and "Bank" for everything else.
+func SectionType(accountType client.AccountType) string {
+	if accountType == client.AccountTypeLiability {
+		return "CCard"
+	}
+	return "Bank"
+}
+
+// Write emits an account history, as returned by
+// Client.GetAccountTransactions, as a single QIF account section. Rows are
+// written oldest first; categories go to the L field.
+func Write(w io.Writer, accountType client.AccountType, rows []client.AccountTransaction) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "!Type:%s\n", SectionType(accountType))
+
+	// api.get_account_transactions_page lists the newest transaction first
+	for i := len(rows) - 1; i >= 0; i-- {
+		row := rows[i]
+		amount := importer.SignedAmount(accountType, client.TransactionType(row.Type), row.Amount)
+
+		fmt.Fprintf(bw, "D%s\n", row.Date.Format("01/02/2006"))
+		fmt.Fprintf(bw, "T%s\n", FormatAmount(amount))
+		if row.Description != "" {
+			fmt.Fprintf(bw, "P%s\n", field(row.Description))
+		}
+		if row.Category != "" {
+			fmt.Fprintf(bw, "L%s\n", field(row.Category))
+		}
+		fmt.Fprintln(bw, "^")
+	}
+
+	return bw.Flush()
+}
+
+// FormatAmount writes cents as a QIF amount such as "-1234.56".
+func FormatAmount(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// field keeps a value on a single line, as QIF has no escaping.
+func field(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/j0lvera/pgbudget/client"
+)
+
+const ledgerCreateUsage = `Usage: pgbudget ledger create -name <name> [flags]
+
+Creates a ledger. The Income, Off-budget and Unassigned accounts are
+created with it.
+
+Flags:
+`
+
+const ledgerListUsage = `Usage: pgbudget ledger list [flags]
+
+Lists the ledgers of the user.
+
+Flags:
+`
+
+func runLedger(ctx context.Context, args []string) error {
+	return runSubcommand(
+		ctx, "ledger", []subcommand{
+			{"create", "create a ledger", runLedgerCreate},
+			{"list", "list ledgers", runLedgerList},
+		}, args,
+	)
+}
+
+func runLedgerCreate(ctx context.Context, args []string) error {
+	fs := newFlagSet("ledger create", ledgerCreateUsage)
+	s := registerSessionFlags(fs)
+	s.registerFormatFlag(fs)
+	name := fs.String("name", "", "ledger name")
+	description := fs.String("description", "", "ledger description")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("missing -name")
+	}
+
+	var ledger *client.Ledger
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		ledger, err = c.CreateLedger(ctx, client.CreateLedgerParams{Name: *name, Description: *description})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(ledger, ledgerTable([]client.Ledger{*ledger}))
+}
+
+func runLedgerList(ctx context.Context, args []string) error {
+	fs := newFlagSet("ledger list", ledgerListUsage)
+	s := registerSessionFlags(fs)
+	s.registerFormatFlag(fs)
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+
+	var ledgers []client.Ledger
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		ledgers, err = c.ListLedgers(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(ledgers, ledgerTable(ledgers))
+}
+
+func ledgerTable(ledgers []client.Ledger) table {
+	t := table{header: []string{"uuid", "name", "description"}}
+	for _, l := range ledgers {
+		t.rows = append(t.rows, []string{l.UUID, l.Name, deref(l.Description)})
+	}
+	return t
+}
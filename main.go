@@ -1,7 +1,163 @@
+// Command pgbudget manages a pgbudget database from the command line.
 package main
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// command is a pgbudget subcommand. run receives the arguments that follow
+// the command name.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+// commands lists the subcommands in the order they are shown in the usage.
+var commands = []command{
+	{"migrate", "apply or inspect database migrations", runMigrate},
+	{"serve", "serve the REST API", runServe},
+	{"import", "import bank statements into an account", runImport},
+	{"export", "export an account history", runExport},
+	{"ledger", "create and list ledgers", runLedger},
+	{"account", "add accounts and show balances", runAccount},
+	{"category", "add and list budget categories", runCategory},
+	{"tx", "add, list, correct and delete transactions", runTx},
+	{"assign", "assign money from Income to a category", runAssign},
+	{"move", "move money between categories", runMove},
+	{"status", "show the budget of a month", runStatus},
+	{"goal", "set category goals and fund them from Income", runGoal},
+	{"month", "close months and set rollover rules", runMonth},
+	{"rule", "categorize transactions recorded without a category", runRule},
+	{"payee", "manage payees and their default categories", runPayee},
+	{"tag", "tag transactions and report spending per tag", runTag},
+	{"schedule", "add, list and delete recurring transactions", runSchedule},
+	{"scheduler", "record due recurring transactions as pending", runScheduler},
+	{"budget", "budget a month in a full-screen terminal view", runBudget},
+}
 
 func main() {
-	fmt.Println("Hello, World!")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := run(ctx, os.Args[1:])
+	stop()
+
+	switch {
+	case err == nil:
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(2)
+	default:
+		fmt.Fprintln(os.Stderr, "pgbudget:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return flag.ErrHelp
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		return nil
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(ctx, args[1:])
+		}
+	}
+
+	usage(os.Stderr)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: pgbudget <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "pgbudget <command> -h" for the arguments of a command.`)
+}
+
+// subcommand is an action of a command, such as "create" in
+// "pgbudget ledger create".
+type subcommand struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+// runSubcommand runs the action of the named command that args[0] selects.
+func runSubcommand(ctx context.Context, name string, subs []subcommand, args []string) error {
+	usage := func(w io.Writer) {
+		fmt.Fprintf(w, "Usage: pgbudget %s <action> [flags]\n\nActions:\n", name)
+		for _, sub := range subs {
+			fmt.Fprintf(w, "  %-10s %s\n", sub.name, sub.summary)
+		}
+		fmt.Fprintf(w, "\nRun \"pgbudget %s <action> -h\" for the flags of an action.\n", name)
+	}
+
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return flag.ErrHelp
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		return nil
+	}
+
+	for _, sub := range subs {
+		if sub.name == args[0] {
+			return sub.run(ctx, args[1:])
+		}
+	}
+
+	usage(os.Stderr)
+	return fmt.Errorf("unknown %s action %q", name, args[0])
+}
+
+// newFlagSet returns the flag set of a command, whose usage prints the
+// description before the flags.
+func newFlagSet(name, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), description)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args into fs, allowing no positional arguments, and
+// resolves the session settings.
+func parseFlags(fs *flag.FlagSet, s *session, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	return s.resolve()
+}
+
+// dsnFromEnv returns the default connection string for commands that talk
+// to the database.
+func dsnFromEnv() string {
+	return os.Getenv("DATABASE_URL")
 }
@@ -209,6 +209,51 @@ func setupTestLedger(
 	return ledgerUUID, accountUUIDs, transactionUUIDs, nil
 }
 
+// addTestAccount creates an account in a test ledger through the api.accounts
+// view and returns its UUID.
+func addTestAccount(ctx context.Context, conn *pgx.Conn, ledgerUUID, name, accountType string) (string, error) {
+	var uuid string
+	err := conn.QueryRow(
+		ctx,
+		"INSERT INTO api.accounts (ledger_uuid, name, type) VALUES ($1, $2, $3) RETURNING uuid",
+		ledgerUUID, name, accountType,
+	).Scan(&uuid)
+	if err != nil {
+		return "", fmt.Errorf("failed to create account %s: %w", name, err)
+	}
+	return uuid, nil
+}
+
+// addTestCategory creates a category in a test ledger through api.add_category
+// and returns its UUID.
+func addTestCategory(ctx context.Context, conn *pgx.Conn, ledgerUUID, name string) (string, error) {
+	var uuid string
+	err := conn.QueryRow(ctx, "SELECT uuid FROM api.add_category($1, $2)", ledgerUUID, name).Scan(&uuid)
+	if err != nil {
+		return "", fmt.Errorf("failed to create category %s: %w", name, err)
+	}
+	return uuid, nil
+}
+
+// findTestCategory returns the UUID of a category the ledger created itself,
+// such as Unassigned or the payment category of a credit card.
+func findTestCategory(ctx context.Context, conn *pgx.Conn, ledgerUUID, name string) (string, error) {
+	var uuid string
+	err := conn.QueryRow(ctx, "SELECT utils.find_category($1, $2)", ledgerUUID, name).Scan(&uuid)
+	if err != nil {
+		return "", fmt.Errorf("failed to find category %s: %w", name, err)
+	}
+	return uuid, nil
+}
+
+// getTestBalance returns the balance of an account or category through
+// api.get_account_balance.
+func getTestBalance(ctx context.Context, conn *pgx.Conn, accountUUID string) (int64, error) {
+	var balance int64
+	err := conn.QueryRow(ctx, "SELECT api.get_account_balance($1)", accountUUID).Scan(&balance)
+	return balance, err
+}
+
 // TestDatabase uses nested subtests to share context between tests
 func TestDatabase(t *testing.T) {
 	is := is_.New(t) // Main 'is' instance for top-level checks
@@ -3633,6 +3678,2663 @@ func TestDatabase(t *testing.T) {
 					)
 				},
 			)
+
+			// Test that exceptions carry stable SQLSTATE codes
+			t.Run(
+				"ErrorCodes", func(t *testing.T) {
+					is := is_.New(t)
+
+					// find the Income account to exercise special account protection
+					var incomeUUID string
+					err := conn.QueryRow(
+						ctx,
+						"SELECT utils.find_category($1, $2)",
+						errorTestLedgerUUID, "Income",
+					).Scan(&incomeUUID)
+					is.NoErr(err) // should find the Income account
+
+					// create a category to trigger a duplicate name
+					_, err = conn.Exec(
+						ctx,
+						"SELECT api.add_category($1, $2)",
+						errorTestLedgerUUID, "Error Codes",
+					)
+					is.NoErr(err) // should create category
+
+					testCases := []struct {
+						name string
+						sql  string
+						args []any
+						code string
+					}{
+						{"LedgerNotFound", "SELECT api.add_category($1, $2)", []any{"missing", "Groceries"}, "PB001"},
+						{"TransactionNotFound", "SELECT api.delete_transaction($1)", []any{"missing"}, "PB004"},
+						{"AmountOutOfRange", "SELECT utils.validate_transaction_data($1, $2)", []any{int64(0), time.Now()}, "PB010"},
+						{"DateOutOfRange", "SELECT utils.validate_transaction_data($1, $2)", []any{int64(1000), time.Now().AddDate(2, 0, 0)}, "PB011"},
+						{"InvalidTransactionType", "SELECT utils.validate_transaction_data($1, $2, $3)", []any{int64(1000), time.Now(), "sideways"}, "PB012"},
+						{"InvalidInput", "SELECT api.add_category($1, $2)", []any{errorTestLedgerUUID, "Bad<Name>"}, "PB013"},
+						{"DuplicateName", "SELECT api.add_category($1, $2)", []any{errorTestLedgerUUID, "Error Codes"}, "23505"},
+						{"SpecialAccountProtected", "DELETE FROM api.accounts WHERE uuid = $1", []any{incomeUUID}, "PB020"},
+					}
+
+					for _, tc := range testCases {
+						t.Run(
+							tc.name, func(t *testing.T) {
+								is := is_.New(t)
+
+								_, err := conn.Exec(ctx, tc.sql, tc.args...)
+								is.True(err != nil) // Should return an error
+
+								var pgErr *pgconn.PgError
+								is.True(errors.As(err, &pgErr)) // Error should be a PgError
+								is.Equal(pgErr.Code, tc.code)   // Should carry the documented SQLSTATE
+							},
+						)
+					}
+				},
+			)
+		},
+	)
+
+	t.Run(
+		"SplitTransactions", func(t *testing.T) {
+			is := is_.New(t)
+
+			ledgerUUID, accounts, _, err := setupTestLedger(ctx, conn, "Split Transactions Test Ledger")
+			is.NoErr(err) // should set up the ledger
+			checkingUUID, groceriesUUID := accounts["Checking"], accounts["Groceries"]
+
+			var householdUUID string
+			err = conn.QueryRow(
+				ctx,
+				"SELECT uuid FROM api.add_category($1, $2)",
+				ledgerUUID, "Household",
+			).Scan(&householdUUID)
+			is.NoErr(err)
+
+			balance := func(is *is_.I, accountUUID string) int64 {
+				var b int64
+				err := conn.QueryRow(ctx, "SELECT api.get_account_balance($1)", accountUUID).Scan(&b)
+				is.NoErr(err)
+				return b
+			}
+			is.Equal(balance(is, checkingUUID), int64(92500)) // setup: 1000.00 income, 75.00 spent
+
+			// a supermarket receipt covering groceries and household goods
+			var splitUUID string
+			err = conn.QueryRow(
+				ctx,
+				"SELECT api.add_split_transaction($1, $2, $3, $4, $5, $6)",
+				ledgerUUID, time.Now(), "Supermarket", "outflow", checkingUUID,
+				fmt.Sprintf(
+					`[{"category_uuid": %q, "amount": 8000}, {"category_uuid": %q, "amount": 4000, "memo": "Cleaning supplies"}]`,
+					groceriesUUID, householdUUID,
+				),
+			).Scan(&splitUUID)
+			is.NoErr(err) // should record the split
+			is.True(splitUUID != "")
+
+			is.Equal(balance(is, checkingUUID), int64(80500))   // one posting of 120.00
+			is.Equal(balance(is, householdUUID), int64(-4000)) // each category is charged its share
+
+			type historyRow struct {
+				Category     string
+				Description  string
+				Type         string
+				Amount       int64
+				Balance      int64
+				UUID         string
+				CategoryUUID *string
+				Split        bool
+			}
+			history := func(is *is_.I, accountUUID string) []historyRow {
+				rows, err := conn.Query(
+					ctx,
+					`SELECT category, description, type, amount, running_balance, uuid, category_uuid, split
+					 FROM api.get_account_transactions_page($1)`,
+					accountUUID,
+				)
+				is.NoErr(err)
+				defer rows.Close()
+
+				var result []historyRow
+				for rows.Next() {
+					var r historyRow
+					err := rows.Scan(&r.Category, &r.Description, &r.Type, &r.Amount, &r.Balance, &r.UUID, &r.CategoryUUID, &r.Split)
+					is.NoErr(err)
+					result = append(result, r)
+				}
+				is.NoErr(rows.Err())
+				return result
+			}
+
+			t.Run(
+				"OneRowOnTheAccount", func(t *testing.T) {
+					is := is_.New(t)
+
+					rows := history(is, checkingUUID)
+					is.Equal(len(rows), 3) // income, spending and the split
+					is.Equal(rows[0].UUID, splitUUID)
+					is.True(rows[0].Split)
+					is.Equal(rows[0].Category, "Groceries, Household")
+					is.Equal(rows[0].CategoryUUID, nil) // several categories
+					is.Equal(rows[0].Description, "Supermarket")
+					is.Equal(rows[0].Type, "outflow")
+					is.Equal(rows[0].Amount, int64(12000))
+					is.Equal(rows[0].Balance, int64(80500))
+
+					rows = history(is, householdUUID)
+					is.Equal(len(rows), 1)
+					is.True(rows[0].Split)
+					is.Equal(rows[0].Description, "Cleaning supplies") // the memo of the leg
+					is.Equal(rows[0].Amount, int64(4000))
+					is.Equal(*rows[0].CategoryUUID, checkingUUID)
+				},
+			)
+
+			var legUUIDs []string
+			t.Run(
+				"GetSplitTransaction", func(t *testing.T) {
+					is := is_.New(t)
+
+					rows, err := conn.Query(
+						ctx,
+						"SELECT amount, leg_uuid, category_uuid, memo, leg_amount FROM api.get_split_transaction($1)",
+						splitUUID,
+					)
+					is.NoErr(err)
+					defer rows.Close()
+
+					var memos []string
+					for rows.Next() {
+						var total, amount int64
+						var legUUID, categoryUUID, memo string
+						is.NoErr(rows.Scan(&total, &legUUID, &categoryUUID, &memo, &amount))
+						is.Equal(total, int64(12000))
+						legUUIDs = append(legUUIDs, legUUID)
+						memos = append(memos, memo)
+					}
+					is.NoErr(rows.Err())
+					is.Equal(memos, []string{"Supermarket", "Cleaning supplies"}) // legs in order, memo defaults to the description
+				},
+			)
+
+			t.Run(
+				"LegsChangeOnlyWithTheSplit", func(t *testing.T) {
+					is := is_.New(t)
+					is.Equal(len(legUUIDs), 2)
+
+					for _, uuid := range []string{splitUUID, legUUIDs[0]} {
+						_, err := conn.Exec(
+							ctx,
+							"SELECT api.correct_transaction($1, 'outflow', $2, $3, 100, 'Leg', $4)",
+							uuid, checkingUUID, groceriesUUID, time.Now(),
+						)
+						var pgErr *pgconn.PgError
+						is.True(errors.As(err, &pgErr)) // a leg cannot be corrected alone
+						is.Equal(pgErr.Code, "PB013")
+					}
+				},
+			)
+
+			var correctionUUID string
+			t.Run(
+				"Correct", func(t *testing.T) {
+					is := is_.New(t)
+
+					err := conn.QueryRow(
+						ctx,
+						"SELECT api.correct_split_transaction($1, $2, $3, $4, $5, $6, $7)",
+						splitUUID, "outflow", checkingUUID, time.Now(), "Supermarket",
+						fmt.Sprintf(
+							`[{"category_uuid": %q, "amount": 9000}, {"category_uuid": %q, "amount": 1000}]`,
+							groceriesUUID, householdUUID,
+						),
+						"Receipt misread",
+					).Scan(&correctionUUID)
+					is.NoErr(err)
+					is.True(correctionUUID != splitUUID)
+
+					is.Equal(balance(is, checkingUUID), int64(82500))
+					is.Equal(balance(is, householdUUID), int64(-1000))
+
+					// every leg is logged with its reversal and the new split
+					var logged int
+					err = conn.QueryRow(
+						ctx,
+						`SELECT count(*)
+						 FROM data.transaction_log l
+						 JOIN data.transactions t ON t.id = l.original_transaction_id
+						 JOIN data.split_transactions s ON s.id = t.split_id
+						 JOIN data.split_transactions c ON c.id = l.correction_split_id
+						 WHERE s.uuid = $1 AND c.uuid = $2 AND l.mutation_type = 'correction'`,
+						splitUUID, correctionUUID,
+					).Scan(&logged)
+					is.NoErr(err)
+					is.Equal(logged, 2)
+
+					rows := history(is, checkingUUID)
+					is.Equal(len(rows), 5) // the original, its reversal and the correction are one row each
+					is.Equal(rows[0].UUID, correctionUUID)
+					is.Equal(rows[0].Amount, int64(10000))
+					is.True(strings.HasPrefix(rows[1].Description, "REVERSAL: "))
+					is.Equal(rows[1].Type, "inflow")
+					is.Equal(rows[1].Amount, int64(12000))
+				},
+			)
+
+			t.Run(
+				"Delete", func(t *testing.T) {
+					is := is_.New(t)
+
+					var reversalUUID string
+					err := conn.QueryRow(ctx, "SELECT api.delete_transaction($1, $2)", correctionUUID, "Returned").Scan(&reversalUUID)
+					is.NoErr(err) // the split uuid deletes every leg
+					is.True(reversalUUID != "")
+
+					is.Equal(balance(is, checkingUUID), int64(92500))
+					is.Equal(balance(is, householdUUID), int64(0))
+
+					var deleted int
+					err = conn.QueryRow(
+						ctx,
+						`SELECT count(*)
+						 FROM data.transaction_log l
+						 JOIN data.transactions t ON t.id = l.reversal_transaction_id
+						 JOIN data.split_transactions s ON s.id = t.split_id
+						 WHERE s.uuid = $1 AND l.mutation_type = 'deletion'`,
+						reversalUUID,
+					).Scan(&deleted)
+					is.NoErr(err)
+					is.Equal(deleted, 2)
+
+					// a split is reversed once: deleting it again, deleting its
+					// reversal or correcting the original it replaced is refused
+					for query, args := range map[string][]any{
+						"SELECT api.delete_transaction($1)":          {correctionUUID},
+						"SELECT api.delete_transaction($1, 'Twice')": {reversalUUID},
+						"SELECT api.correct_split_transaction($1, 'outflow', $2, $3, 'Supermarket', $4)": {
+							splitUUID, checkingUUID, time.Now(),
+							fmt.Sprintf(
+								`[{"category_uuid": %q, "amount": 9000}, {"category_uuid": %q, "amount": 1000}]`,
+								groceriesUUID, householdUUID,
+							),
+						},
+					} {
+						_, err := conn.Exec(ctx, query, args...)
+						var pgErr *pgconn.PgError
+						is.True(errors.As(err, &pgErr)) // Error should be a PgError
+						is.Equal(pgErr.Code, "PB013")
+					}
+					is.Equal(balance(is, checkingUUID), int64(92500))
+					is.Equal(balance(is, householdUUID), int64(0))
+				},
+			)
+
+			t.Run(
+				"Errors", func(t *testing.T) {
+					testCases := []struct {
+						name   string
+						splits string
+						code   string
+					}{
+						{"OneSplit", fmt.Sprintf(`[{"category_uuid": %q, "amount": 100}]`, groceriesUUID), "PB013"},
+						{"NotAnArray", `{"amount": 100}`, "PB013"},
+						{"FractionalAmount", fmt.Sprintf(`[{"category_uuid": %q, "amount": 1.5}, {"amount": 100}]`, groceriesUUID), "PB013"},
+						{"ZeroAmount", fmt.Sprintf(`[{"category_uuid": %q, "amount": 0}, {"amount": 100}]`, groceriesUUID), "PB010"},
+						{"UnknownCategory", `[{"category_uuid": "missing", "amount": 100}, {"amount": 100}]`, "PB003"},
+					}
+
+					for _, tc := range testCases {
+						t.Run(
+							tc.name, func(t *testing.T) {
+								is := is_.New(t)
+
+								_, err := conn.Exec(
+									ctx,
+									"SELECT api.add_split_transaction($1, $2, 'Bad split', 'outflow', $3, $4)",
+									ledgerUUID, time.Now(), checkingUUID, tc.splits,
+								)
+								var pgErr *pgconn.PgError
+								is.True(errors.As(err, &pgErr)) // Error should be a PgError
+								is.Equal(pgErr.Code, tc.code)
+							},
+						)
+					}
+
+					is := is_.New(t)
+					is.Equal(balance(is, checkingUUID), int64(92500)) // failed splits leave nothing behind
+				},
+			)
+		},
+	)
+
+	t.Run(
+		"Schedules", func(t *testing.T) {
+			is := is_.New(t)
+
+			ledgerUUID, accounts, _, err := setupTestLedger(ctx, conn, "Schedules Test Ledger")
+			is.NoErr(err) // should set up the ledger
+			checkingUUID, groceriesUUID := accounts["Checking"], accounts["Groceries"]
+
+			balance := func(is *is_.I, accountUUID string) int64 {
+				var b int64
+				err := conn.QueryRow(ctx, "SELECT api.get_account_balance($1)", accountUUID).Scan(&b)
+				is.NoErr(err)
+				return b
+			}
+			is.Equal(balance(is, checkingUUID), int64(92500)) // setup: 1000.00 income, 75.00 spent
+
+			t.Run(
+				"OccurrenceRules", func(t *testing.T) {
+					testCases := []struct {
+						name       string
+						frequency  string
+						every      int
+						dayOfMonth *int
+						start      string
+						after      string
+						want       string
+					}{
+						{"MonthlyShortMonth", "monthly", 1, ptr(31), "2025-01-31", "2025-01-31", "2025-02-28"},
+						{"MonthlyBackToDay", "monthly", 1, ptr(31), "2025-01-31", "2025-02-28", "2025-03-31"},
+						{"Quarterly", "monthly", 3, ptr(15), "2025-01-15", "2025-01-15", "2025-04-15"},
+						{"MonthlyDayBeforeStart", "monthly", 1, ptr(5), "2025-01-20", "2025-01-19", "2025-02-05"},
+						{"WeeklyFirst", "weekly", 2, nil, "2025-01-03", "2024-12-01", "2025-01-03"},
+						{"Biweekly", "weekly", 2, nil, "2025-01-03", "2025-01-03", "2025-01-17"},
+						{"BiweeklyBetween", "weekly", 2, nil, "2025-01-03", "2025-01-10", "2025-01-17"},
+						{"LastBusinessDaySaturday", "last_business_day", 1, nil, "2025-05-01", "2025-05-15", "2025-05-30"},
+						{"LastBusinessDayMonday", "last_business_day", 1, nil, "2025-05-01", "2025-05-30", "2025-06-30"},
+						{"LastBusinessDaySunday", "last_business_day", 1, nil, "2025-05-01", "2025-07-31", "2025-08-29"},
+					}
+
+					for _, tc := range testCases {
+						t.Run(
+							tc.name, func(t *testing.T) {
+								is := is_.New(t)
+
+								var got string
+								err := conn.QueryRow(
+									ctx,
+									"SELECT utils.schedule_occurrence_after($1, $2, $3, $4::date, $5::date)::text",
+									tc.frequency, tc.every, tc.dayOfMonth, tc.start, tc.after,
+								).Scan(&got)
+								is.NoErr(err)
+								is.Equal(got, tc.want)
+							},
+						)
+					}
+				},
+			)
+
+			// rent on the 1st since two months ago, and a weekly allowance that ended last week
+			var rentUUID, allowanceUUID string
+			err = conn.QueryRow(
+				ctx,
+				`SELECT api.create_schedule(
+					$1, 'Rent', 'outflow', 20000, $2, 'monthly',
+					(date_trunc('month', current_date) - interval '2 months')::date
+				)`,
+				ledgerUUID, checkingUUID,
+			).Scan(&rentUUID)
+			is.NoErr(err) // should create the monthly schedule
+
+			err = conn.QueryRow(
+				ctx,
+				`SELECT api.create_schedule(
+					$1, 'Allowance', 'outflow', 1000, $2, 'weekly', current_date - 14, $3,
+					p_end_date => current_date - 7
+				)`,
+				ledgerUUID, checkingUUID, groceriesUUID,
+			).Scan(&allowanceUUID)
+			is.NoErr(err) // should create the weekly schedule
+
+			run := func(is *is_.I) int {
+				rows, err := conn.Query(ctx, "SELECT schedule_uuid FROM api.run_schedules($1)", ledgerUUID)
+				is.NoErr(err)
+				scheduled, err := pgx.CollectRows(rows, pgx.RowTo[string])
+				is.NoErr(err)
+				return len(scheduled)
+			}
+
+			t.Run(
+				"Run", func(t *testing.T) {
+					is := is_.New(t)
+
+					is.Equal(run(is), 5)                              // three rent payments and two allowances
+					is.Equal(run(is), 0)                              // running again records nothing
+					is.Equal(balance(is, checkingUUID), int64(30500)) // 600.00 rent and 20.00 allowance
+
+					var pending int
+					err := conn.QueryRow(
+						ctx,
+						`SELECT count(*)
+						 FROM data.transactions t
+						 JOIN data.schedules s ON s.id = t.schedule_id
+						 WHERE s.uuid = $1 AND t.status = 'pending'`,
+						rentUUID,
+					).Scan(&pending)
+					is.NoErr(err)
+					is.Equal(pending, 3) // occurrences are recorded as pending
+
+					var nextDate *time.Time
+					err = conn.QueryRow(
+						ctx,
+						"SELECT next_date FROM api.get_schedules($1) WHERE uuid = $2",
+						ledgerUUID, allowanceUUID,
+					).Scan(&nextDate)
+					is.NoErr(err)
+					is.True(nextDate == nil) // the allowance has ended
+				},
+			)
+
+			t.Run(
+				"Upcoming", func(t *testing.T) {
+					is := is_.New(t)
+
+					rows, err := conn.Query(
+						ctx,
+						`SELECT name, date::text, amount
+						 FROM api.get_upcoming_transactions($1, (date_trunc('month', current_date) + interval '2 months')::date)`,
+						ledgerUUID,
+					)
+					is.NoErr(err)
+					defer rows.Close()
+
+					var names []string
+					for rows.Next() {
+						var name, date string
+						var amount int64
+						is.NoErr(rows.Scan(&name, &date, &amount))
+						is.True(strings.HasSuffix(date, "-01")) // rent falls on the 1st
+						names = append(names, name)
+					}
+					is.NoErr(rows.Err())
+					is.Equal(names, []string{"Rent", "Rent"}) // next month and the one after
+				},
+			)
+
+			t.Run(
+				"Delete", func(t *testing.T) {
+					is := is_.New(t)
+
+					_, err := conn.Exec(ctx, "SELECT api.delete_schedule($1)", rentUUID)
+					is.NoErr(err)
+					is.Equal(balance(is, checkingUUID), int64(30500)) // recorded transactions are kept
+					is.Equal(run(is), 0)
+				},
+			)
+
+			t.Run(
+				"Errors", func(t *testing.T) {
+					testCases := []struct {
+						name  string
+						query string
+						code  string
+					}{
+						{"UnknownFrequency", "SELECT api.create_schedule($1, 'Bad', 'outflow', 100, $2, 'daily', current_date)", "PB013"},
+						{"ZeroEvery", "SELECT api.create_schedule($1, 'Bad', 'outflow', 100, $2, 'weekly', current_date, p_every => 0)", "PB013"},
+						{"DayOfWeeklySchedule", "SELECT api.create_schedule($1, 'Bad', 'outflow', 100, $2, 'weekly', current_date, p_day_of_month => 3)", "PB013"},
+						{"EndBeforeStart", "SELECT api.create_schedule($1, 'Bad', 'outflow', 100, $2, 'weekly', current_date, p_end_date => current_date - 1)", "PB011"},
+						{"ZeroAmount", "SELECT api.create_schedule($1, 'Bad', 'outflow', 0, $2, 'weekly', current_date)", "PB010"},
+						{"UnknownCategory", "SELECT api.create_schedule($1, 'Bad', 'outflow', 100, $2, 'weekly', current_date, 'missing')", "PB003"},
+						{"DuplicateName", "SELECT api.create_schedule($1, 'Allowance', 'outflow', 100, $2, 'weekly', current_date)", "23505"},
+					}
+
+					for _, tc := range testCases {
+						t.Run(
+							tc.name, func(t *testing.T) {
+								is := is_.New(t)
+
+								_, err := conn.Exec(ctx, tc.query, ledgerUUID, checkingUUID)
+								var pgErr *pgconn.PgError
+								is.True(errors.As(err, &pgErr)) // Error should be a PgError
+								is.Equal(pgErr.Code, tc.code)
+							},
+						)
+					}
+
+					is := is_.New(t)
+					_, err := conn.Exec(ctx, "SELECT api.delete_schedule('missing')")
+					var pgErr *pgconn.PgError
+					is.True(errors.As(err, &pgErr))
+					is.Equal(pgErr.Code, "PB005") // schedule not found
+				},
+			)
 		},
 	)
+
+	t.Run(
+		"Posting", func(t *testing.T) {
+			is := is_.New(t)
+
+			ledgerUUID, accounts, _, err := setupTestLedger(ctx, conn, "Posting Test Ledger")
+			is.NoErr(err) // should set up the ledger
+			checkingUUID := accounts["Checking"]
+
+			balances := func(is *is_.I) (working, cleared int64) {
+				err := conn.QueryRow(
+					ctx,
+					"SELECT api.get_account_balance($1), api.get_account_balance($1, true)",
+					checkingUUID,
+				).Scan(&working, &cleared)
+				is.NoErr(err)
+				return working, cleared
+			}
+			statusCount := func(is *is_.I, status string) int {
+				var n int
+				err := conn.QueryRow(
+					ctx,
+					"SELECT count(*) FROM api.get_account_transactions_page($1, $2)",
+					checkingUUID, status,
+				).Scan(&n)
+				is.NoErr(err)
+				return n
+			}
+
+			// three months of rent recorded as pending by a schedule
+			_, err = conn.Exec(
+				ctx,
+				`SELECT api.create_schedule(
+					$1, 'Rent', 'outflow', 20000, $2, 'monthly',
+					(date_trunc('month', current_date) - interval '2 months')::date
+				)`,
+				ledgerUUID, checkingUUID,
+			)
+			is.NoErr(err)
+			rows, err := conn.Query(ctx, "SELECT transaction_uuid FROM api.run_schedules($1)", ledgerUUID)
+			is.NoErr(err)
+			rentUUIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+			is.NoErr(err)
+			is.Equal(len(rentUUIDs), 3)
+
+			t.Run(
+				"Balances", func(t *testing.T) {
+					is := is_.New(t)
+
+					working, cleared := balances(is)
+					is.Equal(working, int64(32500)) // pending rent counts towards the working balance
+					is.Equal(cleared, int64(92500)) // but not towards the cleared one
+
+					var current, ledgerCleared int64
+					err := conn.QueryRow(
+						ctx,
+						"SELECT current_balance, cleared_balance FROM api.get_ledger_balances($1) WHERE account_uuid = $2",
+						ledgerUUID, checkingUUID,
+					).Scan(&current, &ledgerCleared)
+					is.NoErr(err)
+					is.Equal(current, working)
+					is.Equal(ledgerCleared, cleared)
+				},
+			)
+
+			t.Run(
+				"StatusFilter", func(t *testing.T) {
+					is := is_.New(t)
+
+					is.Equal(statusCount(is, "pending"), 3)
+					is.Equal(statusCount(is, "posted"), 2) // setup: salary and groceries
+
+					var status string
+					err := conn.QueryRow(
+						ctx,
+						"SELECT status FROM api.get_account_transactions_page($1) WHERE uuid = $2",
+						checkingUUID, rentUUIDs[0],
+					).Scan(&status)
+					is.NoErr(err)
+					is.Equal(status, "pending")
+				},
+			)
+
+			t.Run(
+				"Post", func(t *testing.T) {
+					is := is_.New(t)
+
+					_, err := conn.Exec(ctx, "SELECT api.post_transaction($1)", rentUUIDs[0])
+					is.NoErr(err)
+					working, cleared := balances(is)
+					is.Equal(working, int64(32500)) // posting does not change the working balance
+					is.Equal(cleared, int64(72500))
+					is.Equal(statusCount(is, "pending"), 2)
+
+					var posted int
+					err = conn.QueryRow(ctx, "SELECT api.post_transactions($1)", []string{rentUUIDs[0]}).Scan(&posted)
+					is.NoErr(err)
+					is.Equal(posted, 0) // posted already
+				},
+			)
+
+			t.Run(
+				"DeletePending", func(t *testing.T) {
+					is := is_.New(t)
+
+					_, err := conn.Exec(ctx, "SELECT api.delete_transaction($1)", rentUUIDs[1])
+					is.NoErr(err)
+					working, cleared := balances(is)
+					is.Equal(working, int64(52500))
+					is.Equal(cleared, int64(72500)) // the reversal is pending like the original
+
+					_, err = conn.Exec(ctx, "SELECT api.post_transactions($1)", []string{rentUUIDs[2], rentUUIDs[1]})
+					var pgErr *pgconn.PgError
+					is.True(errors.As(err, &pgErr)) // a deleted transaction cannot be posted
+					is.Equal(pgErr.Code, "PB013")
+					is.Equal(statusCount(is, "pending"), 3) // nothing was posted
+
+					var posted int
+					err = conn.QueryRow(ctx, "SELECT api.post_transactions($1)", []string{rentUUIDs[2]}).Scan(&posted)
+					is.NoErr(err)
+					is.Equal(posted, 1)
+					working, cleared = balances(is)
+					is.Equal(cleared, working)
+				},
+			)
+
+			t.Run(
+				"Errors", func(t *testing.T) {
+					testCases := []struct {
+						name  string
+						query string
+						args  []any
+						code  string
+					}{
+						{"UnknownTransaction", "SELECT api.post_transaction($1)", []any{"missing"}, "PB004"},
+						{"UnknownStatus", "SELECT * FROM api.get_account_transactions_page($1, $2)", []any{checkingUUID, "cleared"}, "PB013"},
+						{"UnknownAccount", "SELECT api.get_account_balance($1, true)", []any{"missing"}, "PB002"},
+					}
+
+					for _, tc := range testCases {
+						t.Run(
+							tc.name, func(t *testing.T) {
+								is := is_.New(t)
+
+								_, err := conn.Exec(ctx, tc.query, tc.args...)
+								var pgErr *pgconn.PgError
+								is.True(errors.As(err, &pgErr)) // Error should be a PgError
+								is.Equal(pgErr.Code, tc.code)
+							},
+						)
+					}
+				},
+			)
+		},
+	)
+
+	t.Run(
+		"Reconciliation", func(t *testing.T) {
+			is := is_.New(t)
+
+			ledgerUUID, accounts, transactions, err := setupTestLedger(ctx, conn, "Reconciliation Test Ledger")
+			is.NoErr(err) // should set up the ledger
+			checkingUUID, groceriesUUID := accounts["Checking"], accounts["Groceries"]
+
+			addTransaction := func(is *is_.I, date, description string, amount int64) string {
+				var uuid string
+				err := conn.QueryRow(
+					ctx,
+					`INSERT INTO api.transactions (ledger_uuid, date, description, type, amount, account_uuid, category_uuid)
+					 VALUES ($1, $2, $3, 'outflow', $4, $5, $6) RETURNING uuid`,
+					ledgerUUID, date, description, amount, checkingUUID, groceriesUUID,
+				).Scan(&uuid)
+				is.NoErr(err)
+				return uuid
+			}
+
+			// a cheque the bank has not cashed yet and coffee after the statement
+			chequeUUID := addTransaction(is, "2023-01-15", "Cheque", 2000)
+			_, err = conn.Exec(ctx, "UPDATE data.transactions SET status = 'pending' WHERE uuid = $1", chequeUUID)
+			is.NoErr(err)
+			coffeeUUID := addTransaction(is, "2023-02-03", "Coffee", 500)
+
+			type preview struct {
+				Cleared      int64
+				Difference   int64
+				Transactions int
+			}
+			previewAt := func(is *is_.I, statementBalance int64) preview {
+				var p preview
+				err := conn.QueryRow(
+					ctx,
+					`SELECT cleared_balance, difference, transactions
+					 FROM api.get_reconciliation_preview($1, $2, '2023-01-31')`,
+					checkingUUID, statementBalance,
+				).Scan(&p.Cleared, &p.Difference, &p.Transactions)
+				is.NoErr(err)
+				return p
+			}
+
+			t.Run(
+				"Preview", func(t *testing.T) {
+					is := is_.New(t)
+
+					// salary and groceries; the cheque is pending and the coffee later
+					is.Equal(previewAt(is, 92000), preview{Cleared: 92500, Difference: -500, Transactions: 2})
+				},
+			)
+
+			var reconciliationUUID string
+			t.Run(
+				"Reconcile", func(t *testing.T) {
+					is := is_.New(t)
+
+					err := conn.QueryRow(
+						ctx,
+						"SELECT api.reconcile_account($1, $2, '2023-01-31')",
+						checkingUUID, 92000,
+					).Scan(&reconciliationUUID)
+					is.NoErr(err)
+
+					var working int64
+					err = conn.QueryRow(ctx, "SELECT api.get_account_balance($1)", checkingUUID).Scan(&working)
+					is.NoErr(err)
+					is.Equal(working, int64(89500)) // 925.00 - 5.00 adjustment - 20.00 cheque - 5.00 coffee
+
+					var adjustmentUUID *string
+					var locked int
+					err = conn.QueryRow(
+						ctx,
+						"SELECT adjustment_uuid, transactions FROM api.get_reconciliations($1) WHERE uuid = $2",
+						checkingUUID, reconciliationUUID,
+					).Scan(&adjustmentUUID, &locked)
+					is.NoErr(err)
+					is.True(adjustmentUUID != nil) // the difference was booked
+					is.Equal(locked, 3)            // salary, groceries and the adjustment
+
+					var category, txType string
+					err = conn.QueryRow(
+						ctx,
+						"SELECT category, type FROM api.get_account_transactions_page($1) WHERE uuid = $2",
+						checkingUUID, *adjustmentUUID,
+					).Scan(&category, &txType)
+					is.NoErr(err)
+					is.Equal(category, "Income") // adjustments go to Income by default
+					is.Equal(txType, "outflow")
+
+					is.Equal(previewAt(is, 92000), preview{Cleared: 92000}) // balanced now
+				},
+			)
+
+			t.Run(
+				"History", func(t *testing.T) {
+					is := is_.New(t)
+
+					for uuid, want := range map[string]bool{
+						transactions["Spend"]: true,
+						chequeUUID:            false,
+						coffeeUUID:            false,
+					} {
+						var reconciled bool
+						err := conn.QueryRow(
+							ctx,
+							"SELECT reconciled FROM api.get_account_transactions_page($1) WHERE uuid = $2",
+							checkingUUID, uuid,
+						).Scan(&reconciled)
+						is.NoErr(err)
+						is.Equal(reconciled, want)
+					}
+				},
+			)
+
+			t.Run(
+				"Locked", func(t *testing.T) {
+					is := is_.New(t)
+					spendUUID := transactions["Spend"]
+
+					for query, args := range map[string][]any{
+						"SELECT api.delete_transaction($1)": {spendUUID},
+						"SELECT api.correct_transaction($1, 'outflow', $2, $3, 8000, 'Grocery shopping', '2023-01-02')": {
+							spendUUID, checkingUUID, groceriesUUID,
+						},
+					} {
+						_, err := conn.Exec(ctx, query, args...)
+						var pgErr *pgconn.PgError
+						is.True(errors.As(err, &pgErr)) // reconciled transactions are locked
+						is.Equal(pgErr.Code, "PB021")
+					}
+
+					_, err := conn.Exec(ctx, "SELECT api.delete_transaction($1)", coffeeUUID)
+					is.NoErr(err) // transactions after the statement are not
+
+					var reversalUUID string
+					err = conn.QueryRow(
+						ctx,
+						"SELECT api.delete_transaction($1, 'Refunded', p_override => true)",
+						spendUUID,
+					).Scan(&reversalUUID)
+					is.NoErr(err) // the override unlocks them
+					is.True(reversalUUID != "")
+				},
+			)
+
+			t.Run(
+				"Errors", func(t *testing.T) {
+					testCases := []struct {
+						name    string
+						account string
+						date    string
+						code    string
+					}{
+						{"BeforeLastReconciliation", checkingUUID, "2023-01-30", "PB011"},
+						{"Category", groceriesUUID, "2023-01-31", "PB013"},
+						{"UnknownAccount", "missing", "2023-01-31", "PB002"},
+					}
+
+					for _, tc := range testCases {
+						t.Run(
+							tc.name, func(t *testing.T) {
+								is := is_.New(t)
+
+								_, err := conn.Exec(ctx, "SELECT api.reconcile_account($1, 0, $2::date)", tc.account, tc.date)
+								var pgErr *pgconn.PgError
+								is.True(errors.As(err, &pgErr)) // Error should be a PgError
+								is.Equal(pgErr.Code, tc.code)
+							},
+						)
+					}
+				},
+			)
+
+			t.Run(
+				"DeletedTransactions", func(t *testing.T) {
+					is := is_.New(t)
+
+					clearedAt := func(is *is_.I, date string) int64 {
+						var cleared int64
+						err := conn.QueryRow(
+							ctx,
+							`SELECT utils.get_account_cleared_balance_at(a.id, $2::date)
+							 FROM data.accounts a WHERE a.uuid = $1`,
+							checkingUUID, date,
+						).Scan(&cleared)
+						is.NoErr(err)
+						return cleared
+					}
+					cleared, later := clearedAt(is, "2023-02-28"), clearedAt(is, "2023-03-31")
+
+					// a gift dated after the statement and a refund in it, both
+					// deleted: the originals and their reversals drop out
+					for date, description := range map[string]string{"2023-03-05": "Gift", "2023-02-10": "Refund"} {
+						_, err := conn.Exec(ctx, "SELECT api.delete_transaction($1)", addTransaction(is, date, description, 700))
+						is.NoErr(err)
+					}
+					is.Equal(clearedAt(is, "2023-02-28"), cleared)
+					is.Equal(clearedAt(is, "2023-03-31"), later)
+
+					var uuid string
+					err := conn.QueryRow(
+						ctx,
+						"SELECT api.reconcile_account($1, $2, '2023-02-28')",
+						checkingUUID, cleared,
+					).Scan(&uuid)
+					is.NoErr(err)
+
+					var adjustmentUUID *string
+					var reconciledBalance int64
+					err = conn.QueryRow(
+						ctx,
+						"SELECT adjustment_uuid, cleared_balance FROM api.get_reconciliations($1) WHERE uuid = $2",
+						checkingUUID, uuid,
+					).Scan(&adjustmentUUID, &reconciledBalance)
+					is.NoErr(err)
+					is.Equal(reconciledBalance, cleared) // the deleted transactions are left out
+					is.True(adjustmentUUID == nil)       // so nothing needed adjusting
+				},
+			)
+		},
+	)
+
+	t.Run(
+		"Goals", func(t *testing.T) {
+			is := is_.New(t)
+
+			ledgerUUID, accounts, _, err := setupTestLedger(ctx, conn, "Goals Test Ledger")
+			is.NoErr(err) // should set up the ledger
+			groceriesUUID := accounts["Groceries"]
+
+			rentUUID, err := addTestCategory(ctx, conn, ledgerUUID, "Rent")
+			is.NoErr(err)
+			vacationUUID, err := addTestCategory(ctx, conn, ledgerUUID, "Vacation")
+			is.NoErr(err)
+
+			type progress struct {
+				Budgeted    int64
+				Needed      int64
+				Underfunded int64
+				OverCap     int64
+			}
+			progressOf := func(is *is_.I, period string) map[string]progress {
+				rows, err := conn.Query(
+					ctx,
+					`SELECT category_name, budgeted, needed, underfunded, over_cap
+					 FROM api.get_goal_progress($1, $2)`,
+					ledgerUUID, period,
+				)
+				is.NoErr(err)
+				defer rows.Close()
+
+				result := make(map[string]progress)
+				for rows.Next() {
+					var name string
+					var p progress
+					is.NoErr(rows.Scan(&name, &p.Budgeted, &p.Needed, &p.Underfunded, &p.OverCap))
+					result[name] = p
+				}
+				is.NoErr(rows.Err())
+				return result
+			}
+
+			t.Run(
+				"Progress", func(t *testing.T) {
+					is := is_.New(t)
+
+					for query, args := range map[string][]any{
+						"SELECT api.set_category_goal($1, 'spending_cap', 5000)":                  {groceriesUUID},
+						"SELECT api.set_category_goal($1, 'monthly_funding', 120000)":             {rentUUID},
+						"SELECT api.set_category_goal($1, 'target_balance', 60000, '2023-06-30')": {vacationUUID},
+					} {
+						_, err := conn.Exec(ctx, query, args...)
+						is.NoErr(err)
+					}
+
+					_, err := conn.Exec(
+						ctx,
+						"SELECT api.assign_to_category($1, '2023-01-10', 'Vacation savings', 4000, $2)",
+						ledgerUUID, vacationUUID,
+					)
+					is.NoErr(err)
+
+					is.Equal(
+						progressOf(is, "202301"), map[string]progress{
+							"Groceries": {Budgeted: 30000, OverCap: 2500}, // spent 75.00 of 50.00
+							"Rent":      {Needed: 120000, Underfunded: 120000},
+							"Vacation":  {Budgeted: 4000, Needed: 10000, Underfunded: 6000}, // 600.00 over 6 months
+						},
+					)
+
+					// the 40.00 saved leaves 560.00 for the 5 months left
+					is.Equal(progressOf(is, "202302")["Vacation"], progress{Needed: 11200, Underfunded: 11200})
+				},
+			)
+
+			t.Run(
+				"Replace", func(t *testing.T) {
+					is := is_.New(t)
+
+					_, err := conn.Exec(ctx, "SELECT api.set_category_goal($1, 'monthly_funding', 100000)", rentUUID)
+					is.NoErr(err)
+
+					var goals int
+					err = conn.QueryRow(
+						ctx,
+						`SELECT count(*) FROM data.category_goals g
+						 JOIN data.accounts a ON a.id = g.category_id WHERE a.uuid = $1`,
+						rentUUID,
+					).Scan(&goals)
+					is.NoErr(err)
+					is.Equal(goals, 1) // a category has one goal
+					is.Equal(progressOf(is, "202301")["Rent"].Needed, int64(100000))
+				},
+			)
+
+			t.Run(
+				"Delete", func(t *testing.T) {
+					is := is_.New(t)
+
+					_, err := conn.Exec(ctx, "SELECT api.delete_category_goal($1)", groceriesUUID)
+					is.NoErr(err)
+
+					_, ok := progressOf(is, "202301")["Groceries"]
+					is.True(!ok) // categories without a goal are not reported
+				},
+			)
+
+			t.Run(
+				"Errors", func(t *testing.T) {
+					testCases := []struct {
+						name  string
+						query string
+						args  []any
+						code  string
+					}{
+						{"NoTargetDate", "SELECT api.set_category_goal($1, 'target_balance', 100)", []any{rentUUID}, "PB011"},
+						{"TargetDateOfCap", "SELECT api.set_category_goal($1, 'spending_cap', 100, '2023-06-30')", []any{rentUUID}, "PB011"},
+						{"ZeroAmount", "SELECT api.set_category_goal($1, 'monthly_funding', 0)", []any{rentUUID}, "PB010"},
+						{"UnknownType", "SELECT api.set_category_goal($1, 'weekly', 100)", []any{rentUUID}, "PB013"},
+						{"Income", "SELECT api.set_category_goal($1, 'monthly_funding', 100)", []any{accounts["Income"]}, "PB020"},
+						{"UnknownCategory", "SELECT api.set_category_goal($1, 'monthly_funding', 100)", []any{"missing"}, "PB003"},
+						{"NoGoal", "SELECT api.delete_category_goal($1)", []any{groceriesUUID}, "PB006"},
+						{"UnknownLedger", "SELECT * FROM api.get_goal_progress($1)", []any{"missing"}, "PB001"},
+					}
+
+					for _, tc := range testCases {
+						t.Run(
+							tc.name, func(t *testing.T) {
+								is := is_.New(t)
+
+								_, err := conn.Exec(ctx, tc.query, tc.args...)
+								var pgErr *pgconn.PgError
+								is.True(errors.As(err, &pgErr)) // Error should be a PgError
+								is.Equal(pgErr.Code, tc.code)
+							},
+						)
+					}
+				},
+			)
+		},
+	)
+
+	t.Run(
+		"MonthClose", func(t *testing.T) {
+			is := is_.New(t)
+
+			ledgerUUID, accounts, _, err := setupTestLedger(ctx, conn, "MonthClose Test Ledger")
+			is.NoErr(err) // should set up the ledger
+			checkingUUID := accounts["Checking"]
+			cardUUID, err := addTestAccount(ctx, conn, ledgerUUID, "Visa", "liability")
+			is.NoErr(err)
+			for _, name := range []string{"Dining", "Rent"} {
+				accounts[name], err = addTestCategory(ctx, conn, ledgerUUID, name)
+				is.NoErr(err)
+			}
+			accounts["Unassigned"], err = findTestCategory(ctx, conn, ledgerUUID, "Unassigned")
+			is.NoErr(err)
+
+			addTransaction := func(is *is_.I, date, txType string, amount int64, accountUUID, category string) {
+				_, err := conn.Exec(
+					ctx,
+					`INSERT INTO api.transactions (ledger_uuid, date, description, type, amount, account_uuid, category_uuid)
+					 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+					ledgerUUID, date, category, txType, amount, accountUUID, accounts[category],
+				)
+				is.NoErr(err)
+			}
+			assign := func(is *is_.I, category string, amount int64) {
+				_, err := conn.Exec(
+					ctx,
+					"SELECT api.assign_to_category($1, '2025-03-01', $2, $3, $4)",
+					ledgerUUID, "Budget: "+category, amount, accounts[category],
+				)
+				is.NoErr(err)
+			}
+			balanceOf := func(is *is_.I, category string) int64 {
+				balance, err := getTestBalance(ctx, conn, accounts[category])
+				is.NoErr(err)
+				return balance
+			}
+
+			addTransaction(is, "2025-03-01", "inflow", 200000, checkingUUID, "Income")
+			assign(is, "Groceries", 7500) // 225.00 are left from the setup
+			assign(is, "Dining", 10000)
+			assign(is, "Rent", 100000)
+			addTransaction(is, "2025-03-08", "outflow", 35000, checkingUUID, "Groceries") // 50.00 overspent in cash
+			addTransaction(is, "2025-03-15", "outflow", 2000, cardUUID, "Groceries")      // and 20.00 on the card
+			addTransaction(is, "2025-03-20", "outflow", 13000, cardUUID, "Dining")        // 30.00 overspent on the card
+			addTransaction(is, "2025-03-31", "outflow", 90000, checkingUUID, "Rent")      // 100.00 left over
+
+			t.Run(
+				"DefaultRules", func(t *testing.T) {
+					is := is_.New(t)
+
+					var leftover, cash, credit string
+					err := conn.QueryRow(
+						ctx,
+						"SELECT leftover, cash_overspending, credit_overspending FROM api.get_rollover_rules($1)",
+						ledgerUUID,
+					).Scan(&leftover, &cash, &credit)
+					is.NoErr(err)
+					is.Equal([]string{leftover, cash, credit}, []string{"keep", "income", "unassigned"})
+				},
+			)
+
+			t.Run(
+				"Close", func(t *testing.T) {
+					is := is_.New(t)
+
+					_, err := conn.Exec(ctx, "SELECT api.set_rollover_rules($1, 'income')", ledgerUUID)
+					is.NoErr(err) // leftovers return to Income, overspending keeps the defaults
+
+					rows, err := conn.Query(
+						ctx,
+						"SELECT category_name, kind, amount, transaction_uuid FROM api.close_month($1, '202503')",
+						ledgerUUID,
+					)
+					is.NoErr(err)
+					defer rows.Close()
+
+					recorded := make(map[string]int64)
+					for rows.Next() {
+						var name, kind, transactionUUID string
+						var amount int64
+						is.NoErr(rows.Scan(&name, &kind, &amount, &transactionUUID))
+						recorded[name+" "+kind] = amount
+					}
+					is.NoErr(rows.Err())
+					is.Equal(
+						recorded, map[string]int64{
+							"Dining credit_overspending":    3000,
+							"Groceries cash_overspending":   5000,
+							"Groceries credit_overspending": 2000,
+							"Rent leftover":                 10000,
+						},
+					)
+
+					// every category starts April at zero
+					for _, category := range []string{"Groceries", "Dining", "Rent"} {
+						is.Equal(balanceOf(is, category), int64(0))
+					}
+					is.Equal(balanceOf(is, "Income"), int64(157500))    // 1525.00 + 100.00 left over - 50.00 cash overspending
+					is.Equal(balanceOf(is, "Unassigned"), int64(-5000)) // credit overspending is unfunded debt
+
+					var date time.Time
+					var closed int
+					err = conn.QueryRow(
+						ctx,
+						`SELECT min(t.date)::date, count(*) FROM data.transactions t
+						 JOIN data.ledgers l ON l.id = t.ledger_id
+						 WHERE l.uuid = $1 AND t.month_close_id IS NOT NULL`,
+						ledgerUUID,
+					).Scan(&date, &closed)
+					is.NoErr(err)
+					is.Equal(closed, 4)
+					is.Equal(date.Format(time.DateOnly), "2025-04-01") // recorded in the next month
+				},
+			)
+
+			t.Run(
+				"Keep", func(t *testing.T) {
+					is := is_.New(t)
+
+					_, err := conn.Exec(ctx, "SELECT api.set_rollover_rules($1, 'keep', 'keep', 'keep')", ledgerUUID)
+					is.NoErr(err)
+					addTransaction(is, "2025-04-10", "outflow", 1000, checkingUUID, "Groceries")
+
+					var recorded int
+					err = conn.QueryRow(ctx, "SELECT count(*) FROM api.close_month($1, '202504')", ledgerUUID).Scan(&recorded)
+					is.NoErr(err)
+					is.Equal(recorded, 0)
+					is.Equal(balanceOf(is, "Groceries"), int64(-1000)) // the overspending rolls over
+
+					var periods []string
+					rows, err := conn.Query(ctx, "SELECT period FROM api.get_closed_months($1)", ledgerUUID)
+					is.NoErr(err)
+					defer rows.Close()
+					for rows.Next() {
+						var period string
+						is.NoErr(rows.Scan(&period))
+						periods = append(periods, period)
+					}
+					is.NoErr(rows.Err())
+					is.Equal(periods, []string{"202504", "202503"}) // newest first
+				},
+			)
+
+			t.Run(
+				"Errors", func(t *testing.T) {
+					testCases := []struct {
+						name  string
+						query string
+						args  []any
+						code  string
+					}{
+						{"AlreadyClosed", "SELECT * FROM api.close_month($1, '202504')", []any{ledgerUUID}, "PB011"},
+						{"BeforeLastClose", "SELECT * FROM api.close_month($1, '202502')", []any{ledgerUUID}, "PB011"},
+						{"NotOver", "SELECT * FROM api.close_month($1, to_char(current_date, 'YYYYMM'))", []any{ledgerUUID}, "PB011"},
+						{"UnknownLedger", "SELECT * FROM api.close_month($1, '202503')", []any{"missing"}, "PB001"},
+						{"InvalidRule", "SELECT api.set_rollover_rules($1, 'unassigned')", []any{ledgerUUID}, "PB013"},
+					}
+
+					for _, tc := range testCases {
+						t.Run(
+							tc.name, func(t *testing.T) {
+								is := is_.New(t)
+
+								_, err := conn.Exec(ctx, tc.query, tc.args...)
+								var pgErr *pgconn.PgError
+								is.True(errors.As(err, &pgErr)) // Error should be a PgError
+								is.Equal(pgErr.Code, tc.code)
+							},
+						)
+					}
+				},
+			)
+		},
+	)
+
+	t.Run(
+		"MoveBetweenCategories", func(t *testing.T) {
+			is := is_.New(t)
+
+			ledgerUUID, categories, _, err := setupTestLedger(ctx, conn, "Move Test Ledger")
+			is.NoErr(err) // should set up the ledger
+			categories["Dining"], err = addTestCategory(ctx, conn, ledgerUUID, "Dining")
+			is.NoErr(err)
+
+			_, err = conn.Exec(
+				ctx,
+				"SELECT api.assign_to_category($1, '2025-06-01', 'Budget: Dining', 10000, $2)",
+				ledgerUUID, categories["Dining"],
+			)
+			is.NoErr(err)
+
+			balanceOf := func(is *is_.I, category string) int64 {
+				balance, err := getTestBalance(ctx, conn, categories[category])
+				is.NoErr(err)
+				return balance
+			}
+
+			t.Run(
+				"Move", func(t *testing.T) {
+					is := is_.New(t)
+
+					var description, fromUUID, toUUID string
+					err := conn.QueryRow(
+						ctx,
+						`SELECT description, account_uuid, category_uuid
+						 FROM api.move_between_categories($1, $2, $3, 5000, '2025-06-10')`,
+						ledgerUUID, categories["Dining"], categories["Groceries"],
+					).Scan(&description, &fromUUID, &toUUID)
+					is.NoErr(err)
+					is.Equal(description, "Move from Dining to Groceries") // the default memo names both categories
+					is.Equal(fromUUID, categories["Dining"])
+					is.Equal(toUUID, categories["Groceries"])
+
+					is.Equal(balanceOf(is, "Dining"), int64(5000))
+					is.Equal(balanceOf(is, "Groceries"), int64(27500))
+					is.Equal(balanceOf(is, "Income"), int64(60000)) // Income is untouched
+
+					// each category shows the move against the other one
+					for _, side := range []struct{ category, other, txType string }{
+						{"Dining", "Groceries", "outflow"},
+						{"Groceries", "Dining", "inflow"},
+					} {
+						var other, txType string
+						var amount int64
+						err := conn.QueryRow(
+							ctx,
+							"SELECT category, type, amount FROM api.get_account_transactions_page($1) LIMIT 1",
+							categories[side.category],
+						).Scan(&other, &txType, &amount)
+						is.NoErr(err)
+						is.Equal([]string{other, txType}, []string{side.other, side.txType})
+						is.Equal(amount, int64(5000))
+					}
+				},
+			)
+
+			t.Run(
+				"Overdraw", func(t *testing.T) {
+					is := is_.New(t)
+
+					_, err := conn.Exec(
+						ctx,
+						"SELECT api.move_between_categories($1, $2, $3, 6000, '2025-06-11', 'Too much')",
+						ledgerUUID, categories["Dining"], categories["Groceries"],
+					)
+					var pgErr *pgconn.PgError
+					is.True(errors.As(err, &pgErr)) // Error should be a PgError
+					is.Equal(pgErr.Code, "PB022")   // Dining only holds 50.00
+					is.Equal(balanceOf(is, "Dining"), int64(5000))
+
+					// the balance counts up to the date of the move only
+					_, err = conn.Exec(
+						ctx,
+						"SELECT api.move_between_categories($1, $2, $3, 1000, '2025-05-31')",
+						ledgerUUID, categories["Dining"], categories["Groceries"],
+					)
+					is.True(errors.As(err, &pgErr))
+					is.Equal(pgErr.Code, "PB022")
+
+					_, err = conn.Exec(
+						ctx,
+						"SELECT api.move_between_categories($1, $2, $3, 6000, '2025-06-11', 'Too much', p_force => true)",
+						ledgerUUID, categories["Dining"], categories["Groceries"],
+					)
+					is.NoErr(err)
+					is.Equal(balanceOf(is, "Dining"), int64(-1000))
+					is.Equal(balanceOf(is, "Groceries"), int64(33500))
+				},
+			)
+
+			t.Run(
+				"Errors", func(t *testing.T) {
+					testCases := []struct {
+						name string
+						args []any
+						code string
+					}{
+						{"SameCategory", []any{ledgerUUID, categories["Groceries"], categories["Groceries"], 100, "2025-06-12"}, "PB013"},
+						{"UnknownCategory", []any{ledgerUUID, categories["Groceries"], "missing", 100, "2025-06-12"}, "PB003"},
+						{"UnknownLedger", []any{"missing", categories["Groceries"], categories["Dining"], 100, "2025-06-12"}, "PB001"},
+						{"ZeroAmount", []any{ledgerUUID, categories["Groceries"], categories["Dining"], 0, "2025-06-12"}, "PB010"},
+						{"FarFuture", []any{ledgerUUID, categories["Groceries"], categories["Dining"], 100, "2099-01-01"}, "PB011"},
+					}
+
+					for _, tc := range testCases {
+						t.Run(
+							tc.name, func(t *testing.T) {
+								is := is_.New(t)
+
+								_, err := conn.Exec(ctx, "SELECT api.move_between_categories($1, $2, $3, $4, $5)", tc.args...)
+								var pgErr *pgconn.PgError
+								is.True(errors.As(err, &pgErr)) // Error should be a PgError
+								is.Equal(pgErr.Code, tc.code)
+							},
+						)
+					}
+				},
+			)
+		},
+	)
+
+	t.Run(
+		"Transfers", func(t *testing.T) {
+			is := is_.New(t)
+
+			ledgerUUID, accounts, _, err := setupTestLedger(ctx, conn, "Transfer Test Ledger")
+			is.NoErr(err) // should set up the ledger
+			for _, account := range []struct{ name, accountType string }{
+				{"Savings", "asset"}, {"Visa", "liability"},
+			} {
+				accounts[account.name], err = addTestAccount(ctx, conn, ledgerUUID, account.name, account.accountType)
+				is.NoErr(err)
+			}
+			for _, name := range []string{"Unassigned", "Visa Payment"} {
+				accounts[name], err = findTestCategory(ctx, conn, ledgerUUID, name)
+				is.NoErr(err) // the card got its payment category
+			}
+
+			balanceOf := func(is *is_.I, account string) int64 {
+				balance, err := getTestBalance(ctx, conn, accounts[account])
+				is.NoErr(err)
+				return balance
+			}
+			paymentsOf := func(is *is_.I, transferUUID string) []int64 {
+				rows, err := conn.Query(
+					ctx,
+					"SELECT amount FROM data.transactions WHERE metadata->>'transfer_uuid' = $1",
+					transferUUID,
+				)
+				is.NoErr(err)
+				defer rows.Close()
+				var amounts []int64
+				for rows.Next() {
+					var amount int64
+					is.NoErr(rows.Scan(&amount))
+					amounts = append(amounts, amount)
+				}
+				is.NoErr(rows.Err())
+				return amounts
+			}
+
+			_, err = conn.Exec(
+				ctx,
+				"SELECT api.add_transaction($1, '2025-07-02', 'Dinner', 'outflow', 20000, $2, null)",
+				ledgerUUID, accounts["Visa"],
+			)
+			is.NoErr(err) // unbudgeted card spending lands in Unassigned
+
+			t.Run(
+				"BetweenBankAccounts", func(t *testing.T) {
+					is := is_.New(t)
+
+					var transferUUID string
+					err := conn.QueryRow(
+						ctx,
+						"SELECT api.add_transfer($1, $2, $3, 30000, '2025-07-03')",
+						ledgerUUID, accounts["Checking"], accounts["Savings"],
+					).Scan(&transferUUID)
+					is.NoErr(err)
+
+					is.Equal(balanceOf(is, "Checking"), int64(62500))
+					is.Equal(balanceOf(is, "Savings"), int64(30000))
+					is.Equal(balanceOf(is, "Income"), int64(70000)) // no category is touched
+
+					var description string
+					err = conn.QueryRow(
+						ctx, "SELECT description FROM data.transactions WHERE uuid = $1", transferUUID,
+					).Scan(&description)
+					is.NoErr(err)
+					is.Equal(description, "Transfer from Checking to Savings")
+				},
+			)
+
+			var paymentUUID string
+			t.Run(
+				"CardPayment", func(t *testing.T) {
+					is := is_.New(t)
+
+					// nothing is set aside yet: the payment only touches the accounts
+					var transferUUID string
+					err := conn.QueryRow(
+						ctx,
+						"SELECT api.add_transfer($1, $2, $3, 5000, '2025-07-04', 'Visa payment')",
+						ledgerUUID, accounts["Checking"], accounts["Visa"],
+					).Scan(&transferUUID)
+					is.NoErr(err)
+					is.Equal(balanceOf(is, "Visa"), int64(15000))
+					is.Equal(balanceOf(is, "Checking"), int64(57500))
+					is.Equal(len(paymentsOf(is, transferUUID)), 0)
+
+					_, err = conn.Exec(
+						ctx,
+						"SELECT api.assign_to_category($1, '2025-07-05', 'Budget: Visa', 8000, $2)",
+						ledgerUUID, accounts["Visa Payment"],
+					)
+					is.NoErr(err)
+
+					err = conn.QueryRow(
+						ctx,
+						"SELECT api.add_transfer($1, $2, $3, 10000, '2025-07-06')",
+						ledgerUUID, accounts["Checking"], accounts["Visa"],
+					).Scan(&transferUUID)
+					is.NoErr(err)
+					is.Equal(balanceOf(is, "Visa"), int64(5000))
+					is.Equal(paymentsOf(is, transferUUID), []int64{8000}) // up to what the category holds
+					is.Equal(balanceOf(is, "Visa Payment"), int64(0))
+					is.Equal(balanceOf(is, "Unassigned"), int64(-12000)) // the dinner is partly funded now
+					paymentUUID = transferUUID
+				},
+			)
+
+			t.Run(
+				"DeleteAfterPayment", func(t *testing.T) {
+					is := is_.New(t)
+
+					_, err := conn.Exec(ctx, "SELECT api.delete_transaction($1, 'Paid twice')", paymentUUID)
+					is.NoErr(err)
+					is.Equal(balanceOf(is, "Visa"), int64(15000))
+					is.Equal(balanceOf(is, "Checking"), int64(57500))
+					is.Equal(balanceOf(is, "Visa Payment"), int64(8000)) // the money set aside is back
+					is.Equal(balanceOf(is, "Unassigned"), int64(-20000))
+
+					// paying again spends it again, and correcting that payment gives it back
+					var transferUUID string
+					err = conn.QueryRow(
+						ctx,
+						"SELECT api.add_transfer($1, $2, $3, 3000, '2025-07-06')",
+						ledgerUUID, accounts["Checking"], accounts["Visa"],
+					).Scan(&transferUUID)
+					is.NoErr(err)
+					is.Equal(paymentsOf(is, transferUUID), []int64{3000})
+					is.Equal(balanceOf(is, "Visa Payment"), int64(5000))
+
+					_, err = conn.Exec(
+						ctx,
+						"SELECT api.correct_transaction($1, 'outflow', $2, $3, 3000, 'Card fee', '2025-07-06')",
+						transferUUID, accounts["Checking"], accounts["Unassigned"],
+					)
+					is.NoErr(err)
+					is.Equal(balanceOf(is, "Visa"), int64(15000))
+					is.Equal(balanceOf(is, "Visa Payment"), int64(8000))
+					is.Equal(balanceOf(is, "Unassigned"), int64(-23000))
+				},
+			)
+
+			t.Run(
+				"Errors", func(t *testing.T) {
+					testCases := []struct {
+						name string
+						args []any
+						code string
+					}{
+						{"Category", []any{ledgerUUID, accounts["Checking"], accounts["Income"], 100}, "PB013"},
+						{"SameAccount", []any{ledgerUUID, accounts["Checking"], accounts["Checking"], 100}, "PB013"},
+						{"UnknownAccount", []any{ledgerUUID, accounts["Checking"], "missing", 100}, "PB002"},
+						{"UnknownLedger", []any{"missing", accounts["Checking"], accounts["Savings"], 100}, "PB001"},
+						{"ZeroAmount", []any{ledgerUUID, accounts["Checking"], accounts["Savings"], 0}, "PB010"},
+					}
+
+					for _, tc := range testCases {
+						t.Run(
+							tc.name, func(t *testing.T) {
+								is := is_.New(t)
+
+								_, err := conn.Exec(ctx, "SELECT api.add_transfer($1, $2, $3, $4, '2025-07-07')", tc.args...)
+								var pgErr *pgconn.PgError
+								is.True(errors.As(err, &pgErr)) // Error should be a PgError
+								is.Equal(pgErr.Code, tc.code)
+							},
+						)
+					}
+				},
+			)
+		},
+	)
+
+	t.Run(
+		"CategoryRules", func(t *testing.T) {
+			is := is_.New(t)
+
+			ledgerUUID, accounts, _, err := setupTestLedger(ctx, conn, "Rule Test Ledger")
+			is.NoErr(err) // should set up the ledger
+			accounts["Visa"], err = addTestAccount(ctx, conn, ledgerUUID, "Visa", "liability")
+			is.NoErr(err)
+			for _, name := range []string{"Coffee", "Dining"} {
+				accounts[name], err = addTestCategory(ctx, conn, ledgerUUID, name)
+				is.NoErr(err)
+			}
+
+			// categoryOf returns the category, description and tags a transaction
+			// was recorded with.
+			categoryOf := func(is *is_.I, transactionUUID string) (string, string, []string) {
+				var category, description string
+				var tags []string
+				err := conn.QueryRow(
+					ctx,
+					`SELECT c.name, t.description,
+					        array(SELECT jsonb_array_elements_text(t.metadata->'tags'))
+					 FROM data.transactions t
+					 JOIN data.accounts c ON c.id IN (t.debit_account_id, t.credit_account_id) AND c.type = 'equity'
+					 WHERE t.uuid = $1`,
+					transactionUUID,
+				).Scan(&category, &description, &tags)
+				is.NoErr(err)
+				return category, description, tags
+			}
+			addTransaction := func(is *is_.I, date, description string, amount int64, account string, category any) string {
+				var uuid string
+				err := conn.QueryRow(
+					ctx,
+					`INSERT INTO api.transactions (ledger_uuid, date, description, type, amount, account_uuid, category_uuid)
+					 VALUES ($1, $2, $3, 'outflow', $4, $5, $6) RETURNING uuid`,
+					ledgerUUID, date, description, amount, accounts[account], category,
+				).Scan(&uuid)
+				is.NoErr(err)
+				return uuid
+			}
+
+			// recorded before any rule exists
+			early := addTransaction(is, "2025-07-01", "STARBUCKS #1234", 450, "Checking", nil)
+
+			var coffeeRule string
+			err = conn.QueryRow(
+				ctx,
+				"SELECT api.add_category_rule($1, 'Coffee', $2, p_description_pattern => 'starbucks|blue bottle', p_max_amount => 2000, p_set_description => 'Coffee', p_tags => array['caffeine'])",
+				ledgerUUID, accounts["Coffee"],
+			).Scan(&coffeeRule)
+			is.NoErr(err)
+			_, err = conn.Exec(
+				ctx,
+				"SELECT api.add_category_rule($1, 'Weekend dining', $2, p_account_uuid => $3, p_weekdays => array[6, 7], p_priority => 1)",
+				ledgerUUID, accounts["Dining"], accounts["Visa"],
+			)
+			is.NoErr(err)
+			_, err = conn.Exec(
+				ctx,
+				"SELECT api.add_category_rule($1, 'Big', p_min_amount => 10000, p_tags => array['review'], p_priority => 2)",
+				ledgerUUID,
+			)
+			is.NoErr(err)
+
+			t.Run(
+				"OnAdd", func(t *testing.T) {
+					testCases := []struct {
+						name, date, description string
+						amount                  int64
+						account                 string
+						category                any
+						want                    []string // category, then description
+						tags                    []string
+					}{
+						{"Description", "2025-07-02", "Blue Bottle SF", 600, "Checking", nil, []string{"Coffee", "Coffee"}, []string{"caffeine"}},
+						{"AboveMaxAmount", "2025-07-02", "Starbucks catering", 5000, "Checking", nil, []string{"Unassigned", "Starbucks catering"}, []string{}},
+						{"Weekday", "2025-07-05", "Taqueria", 3000, "Visa", nil, []string{"Dining", "Taqueria"}, []string{}},
+						{"OtherWeekday", "2025-07-07", "Taqueria", 3000, "Visa", nil, []string{"Unassigned", "Taqueria"}, []string{}},
+						{"OtherAccount", "2025-07-05", "Taqueria", 3000, "Checking", nil, []string{"Unassigned", "Taqueria"}, []string{}},
+						{"TagsOnly", "2025-07-07", "Furniture", 25000, "Checking", nil, []string{"Unassigned", "Furniture"}, []string{"review"}},
+						{"CategoryGiven", "2025-07-02", "Starbucks", 450, "Checking", accounts["Groceries"], []string{"Groceries", "Starbucks"}, []string{}},
+					}
+
+					for _, tc := range testCases {
+						t.Run(
+							tc.name, func(t *testing.T) {
+								is := is_.New(t)
+
+								uuid := addTransaction(is, tc.date, tc.description, tc.amount, tc.account, tc.category)
+								category, description, tags := categoryOf(is, uuid)
+								is.Equal([]string{category, description}, tc.want)
+								is.Equal(tags, tc.tags)
+							},
+						)
+					}
+				},
+			)
+
+			t.Run(
+				"List", func(t *testing.T) {
+					is := is_.New(t)
+
+					rows, err := conn.Query(ctx, "SELECT name FROM api.get_category_rules($1)", ledgerUUID)
+					is.NoErr(err)
+					names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+					is.NoErr(err)
+					is.Equal(names, []string{"Coffee", "Weekend dining", "Big"}) // by priority
+				},
+			)
+
+			t.Run(
+				"Apply", func(t *testing.T) {
+					is := is_.New(t)
+
+					var transactionUUID, correctionUUID, ruleUUID string
+					err := conn.QueryRow(
+						ctx,
+						"SELECT transaction_uuid, correction_uuid, rule_uuid FROM api.apply_category_rules($1) WHERE transaction_uuid = $2",
+						ledgerUUID, early,
+					).Scan(&transactionUUID, &correctionUUID, &ruleUUID)
+					is.NoErr(err)
+					is.Equal(ruleUUID, coffeeRule)
+
+					category, description, tags := categoryOf(is, correctionUUID)
+					is.Equal([]string{category, description}, []string{"Coffee", "Coffee"})
+					is.Equal(tags, []string{"caffeine"})
+
+					var reason string
+					err = conn.QueryRow(
+						ctx,
+						`SELECT l.reason
+						 FROM data.transaction_log l
+						 JOIN data.transactions o ON o.id = l.original_transaction_id
+						 JOIN data.transactions c ON c.id = l.correction_transaction_id
+						 WHERE o.uuid = $1 AND c.uuid = $2`,
+						early, correctionUUID,
+					).Scan(&reason)
+					is.NoErr(err) // the correction is in the transaction log
+					is.Equal(reason, "Category rule: Coffee")
+
+					// transactions already matched are not matched again
+					var applied int
+					err = conn.QueryRow(ctx, "SELECT count(*) FROM api.apply_category_rules($1)", ledgerUUID).Scan(&applied)
+					is.NoErr(err)
+					is.Equal(applied, 0)
+				},
+			)
+
+			t.Run(
+				"EntryPoints", func(t *testing.T) {
+					is := is_.New(t)
+
+					var added, imported string
+					err := conn.QueryRow(
+						ctx,
+						"SELECT api.add_transaction($1, '2025-07-09', 'Blue Bottle', 'outflow', 500, $2)",
+						ledgerUUID, accounts["Checking"],
+					).Scan(&added)
+					is.NoErr(err)
+					err = conn.QueryRow(
+						ctx,
+						"SELECT api.import_transaction($1, '2025-07-09', 'STARBUCKS #77', 'outflow', 450, $2, p_external_id => 'FIT-R1')",
+						ledgerUUID, accounts["Checking"],
+					).Scan(&imported)
+					is.NoErr(err)
+					for _, uuid := range []string{added, imported} {
+						category, description, tags := categoryOf(is, uuid)
+						is.Equal([]string{category, description}, []string{"Coffee", "Coffee"})
+						is.Equal(tags, []string{"caffeine"})
+					}
+
+					// scheduled occurrences keep the category of their schedule, here none
+					_, err = conn.Exec(
+						ctx,
+						"SELECT api.create_schedule($1, 'Starbucks', 'outflow', 450, $2, 'monthly', '2025-07-01', p_end_date => '2025-07-01')",
+						ledgerUUID, accounts["Checking"],
+					)
+					is.NoErr(err)
+					var scheduled string
+					err = conn.QueryRow(ctx, "SELECT transaction_uuid FROM api.run_schedules($1)", ledgerUUID).Scan(&scheduled)
+					is.NoErr(err)
+					category, description, _ := categoryOf(is, scheduled)
+					is.Equal([]string{category, description}, []string{"Unassigned", "Starbucks"})
+				},
+			)
+
+			t.Run(
+				"Delete", func(t *testing.T) {
+					is := is_.New(t)
+
+					_, err := conn.Exec(ctx, "SELECT api.delete_category_rule($1)", coffeeRule)
+					is.NoErr(err)
+
+					uuid := addTransaction(is, "2025-07-08", "Starbucks", 450, "Checking", nil)
+					category, _, _ := categoryOf(is, uuid)
+					is.Equal(category, "Unassigned")
+				},
+			)
+
+			t.Run(
+				"Errors", func(t *testing.T) {
+					testCases := []struct {
+						name  string
+						query string
+						args  []any
+						code  string
+					}{
+						{"NoAction", "SELECT api.add_category_rule($1, 'Idle', p_description_pattern => 'x')", []any{ledgerUUID}, "PB013"},
+						{"BadPattern", "SELECT api.add_category_rule($1, 'Bad', $2, p_description_pattern => '(')", []any{ledgerUUID, accounts["Dining"]}, "PB013"},
+						{"BadWeekday", "SELECT api.add_category_rule($1, 'Bad', $2, p_weekdays => array[0])", []any{ledgerUUID, accounts["Dining"]}, "PB013"},
+						{"BadAmountRange", "SELECT api.add_category_rule($1, 'Bad', $2, p_min_amount => 500, p_max_amount => 100)", []any{ledgerUUID, accounts["Dining"]}, "PB010"},
+						{"AccountAsCategory", "SELECT api.add_category_rule($1, 'Bad', $2)", []any{ledgerUUID, accounts["Checking"]}, "PB003"},
+						{"CategoryAsAccount", "SELECT api.add_category_rule($1, 'Bad', $2, p_account_uuid => $2)", []any{ledgerUUID, accounts["Dining"]}, "PB002"},
+						{"DuplicateName", "SELECT api.add_category_rule($1, 'Big', $2)", []any{ledgerUUID, accounts["Dining"]}, "23505"},
+						{"UnknownLedger", "SELECT api.add_category_rule($1, 'Bad', p_tags => array['x'])", []any{"missing"}, "PB001"},
+						{"UnknownRule", "SELECT api.delete_category_rule($1)", []any{"missing"}, "PB007"},
+						{"ApplyUnknownLedger", "SELECT * FROM api.apply_category_rules($1)", []any{"missing"}, "PB001"},
+					}
+
+					for _, tc := range testCases {
+						t.Run(
+							tc.name, func(t *testing.T) {
+								is := is_.New(t)
+
+								_, err := conn.Exec(ctx, tc.query, tc.args...)
+								var pgErr *pgconn.PgError
+								is.True(errors.As(err, &pgErr)) // Error should be a PgError
+								is.Equal(pgErr.Code, tc.code)
+							},
+						)
+					}
+				},
+			)
+		},
+	)
+
+	t.Run(
+		"Payees", func(t *testing.T) {
+			is := is_.New(t)
+
+			var ledgerUUID, checkingUUID string
+			err := conn.QueryRow(
+				ctx,
+				"insert into api.ledgers (name) values ($1) returning uuid",
+				"Payee Test Ledger",
+			).Scan(&ledgerUUID)
+			is.NoErr(err) // should create ledger without error
+
+			err = conn.QueryRow(
+				ctx,
+				"INSERT INTO api.accounts (ledger_uuid, name, type) VALUES ($1, 'Checking', 'asset') RETURNING uuid",
+				ledgerUUID,
+			).Scan(&checkingUUID)
+			is.NoErr(err)
+
+			categories := make(map[string]string)
+			for _, name := range []string{"Dining", "Groceries", "Household"} {
+				var uuid string
+				err := conn.QueryRow(ctx, "SELECT uuid FROM api.add_category($1, $2)", ledgerUUID, name).Scan(&uuid)
+				is.NoErr(err)
+				categories[name] = uuid
+			}
+
+			type payeeRow struct {
+				UUID         string
+				Category     *string
+				Transactions int64
+				Outflow      int64
+			}
+			payees := func(is *is_.I) ([]string, map[string]payeeRow) {
+				rows, err := conn.Query(
+					ctx,
+					"SELECT name, uuid, default_category_name, transactions, outflow FROM api.get_payees($1)",
+					ledgerUUID,
+				)
+				is.NoErr(err)
+				defer rows.Close()
+				var names []string
+				byName := make(map[string]payeeRow)
+				for rows.Next() {
+					var name string
+					var row payeeRow
+					is.NoErr(rows.Scan(&name, &row.UUID, &row.Category, &row.Transactions, &row.Outflow))
+					names = append(names, name)
+					byName[name] = row
+				}
+				is.NoErr(rows.Err())
+				return names, byName
+			}
+			// recorded returns the category and payee of a transaction as the
+			// account history shows them.
+			recorded := func(is *is_.I, transactionUUID string) (string, *string) {
+				var category string
+				var payee *string
+				err := conn.QueryRow(
+					ctx,
+					"SELECT category, payee FROM api.get_account_transactions_page($1) WHERE uuid = $2",
+					checkingUUID, transactionUUID,
+				).Scan(&category, &payee)
+				is.NoErr(err)
+				return category, payee
+			}
+
+			for _, tx := range []struct {
+				date, description string
+				amount            int64
+				category          any
+			}{
+				{"2025-08-02", "COSTCO WHSE #0123", 10000, categories["Groceries"]},
+				{"2025-08-03", "Costco Whse 0456", 2000, categories["Household"]},
+				{"2025-08-04", "SQ *BLUE BOTTLE #12", 500, nil},
+			} {
+				_, err := conn.Exec(
+					ctx,
+					"SELECT api.add_transaction($1, $2, $3, 'outflow', $4, $5, $6)",
+					ledgerUUID, tx.date, tx.description, tx.amount, checkingUUID, tx.category,
+				)
+				is.NoErr(err)
+			}
+
+			t.Run(
+				"Normalize", func(t *testing.T) {
+					for description, want := range map[string]*string{
+						"SQ *BLUE BOTTLE #0123": ptr("Blue Bottle"),
+						"COSTCO WHSE 0456":      ptr("Costco Whse"),
+						"POS TRADER JOE'S":      ptr("Trader Joe's"),
+						"Post Office":           ptr("Post Office"),
+						"Corner market":         ptr("Corner market"),
+						"#1234":                 nil,
+					} {
+						t.Run(
+							description, func(t *testing.T) {
+								is := is_.New(t)
+
+								var name *string
+								err := conn.QueryRow(ctx, "SELECT utils.normalize_payee_name($1)", description).Scan(&name)
+								is.NoErr(err)
+								is.Equal(name, want)
+							},
+						)
+					}
+				},
+			)
+
+			t.Run(
+				"Backfill", func(t *testing.T) {
+					is := is_.New(t)
+
+					var linked int
+					err := conn.QueryRow(ctx, "SELECT api.backfill_payees($1)", ledgerUUID).Scan(&linked)
+					is.NoErr(err)
+					is.Equal(linked, 3)
+
+					names, byName := payees(is)
+					is.Equal(names, []string{"Blue Bottle", "Costco Whse"})
+					is.Equal(byName["Costco Whse"].Transactions, int64(2))
+					is.Equal(byName["Costco Whse"].Outflow, int64(12000))
+					is.Equal(*byName["Costco Whse"].Category, "Household")   // the latest category
+					is.Equal(byName["Blue Bottle"].Category, (*string)(nil)) // Unassigned is not remembered
+
+					err = conn.QueryRow(ctx, "SELECT api.backfill_payees($1)", ledgerUUID).Scan(&linked)
+					is.NoErr(err)
+					is.Equal(linked, 0) // linked transactions are left alone
+
+					// nothing is left behind for a second call in the same transaction
+					tx, err := conn.Begin(ctx)
+					is.NoErr(err)
+					defer tx.Rollback(ctx)
+					_, err = tx.Exec(ctx, "SELECT api.backfill_payees($1)", ledgerUUID)
+					is.NoErr(err)
+					err = tx.QueryRow(ctx, "SELECT api.backfill_payees($1)", ledgerUUID).Scan(&linked)
+					is.NoErr(err)
+					is.Equal(linked, 0)
+				},
+			)
+
+			var latteUUID string
+			t.Run(
+				"AddTransaction", func(t *testing.T) {
+					is := is_.New(t)
+
+					// the payee is found regardless of case and remembers the category
+					_, err := conn.Exec(
+						ctx,
+						"SELECT api.add_transaction($1, '2025-08-05', 'Cold brew', 'outflow', 600, $2, $3, 'blue bottle')",
+						ledgerUUID, checkingUUID, categories["Dining"],
+					)
+					is.NoErr(err)
+
+					err = conn.QueryRow(
+						ctx,
+						"SELECT api.add_transaction($1, '2025-08-06', 'Latte', 'outflow', 450, $2, p_payee => 'Blue Bottle')",
+						ledgerUUID, checkingUUID,
+					).Scan(&latteUUID)
+					is.NoErr(err)
+					category, payee := recorded(is, latteUUID)
+					is.Equal(category, "Dining")
+					is.Equal(*payee, "Blue Bottle")
+
+					var marketUUID string
+					err = conn.QueryRow(
+						ctx,
+						"SELECT api.add_transaction($1, '2025-08-06', 'Apples', 'outflow', 300, $2, p_payee => '  Farmers   Market ')",
+						ledgerUUID, checkingUUID,
+					).Scan(&marketUUID)
+					is.NoErr(err)
+					category, payee = recorded(is, marketUUID)
+					is.Equal(category, "Unassigned") // a new payee has no default category
+					is.Equal(*payee, "Farmers Market")
+				},
+			)
+
+			t.Run(
+				"CorrectTransaction", func(t *testing.T) {
+					is := is_.New(t)
+
+					var correctionUUID string
+					err := conn.QueryRow(
+						ctx,
+						"SELECT api.correct_transaction($1, 'outflow', $2, null, 450, 'Latte', '2025-08-06', p_payee => 'Costco Whse')",
+						latteUUID, checkingUUID,
+					).Scan(&correctionUUID)
+					is.NoErr(err)
+					category, payee := recorded(is, correctionUUID)
+					is.Equal(category, "Household") // the default category of the new payee
+					is.Equal(*payee, "Costco Whse")
+
+					err = conn.QueryRow(
+						ctx,
+						"SELECT api.correct_transaction($1, 'outflow', $2, $3, 450, 'Latte', '2025-08-06')",
+						correctionUUID, checkingUUID, categories["Groceries"],
+					).Scan(&correctionUUID)
+					is.NoErr(err)
+					category, payee = recorded(is, correctionUUID)
+					is.Equal(category, "Groceries")
+					is.Equal(*payee, "Costco Whse") // kept from the original
+
+					// corrected transactions count through their corrections only
+					_, byName := payees(is)
+					is.Equal(byName["Blue Bottle"].Transactions, int64(2))
+					is.Equal(byName["Blue Bottle"].Outflow, int64(1100))
+					is.Equal(byName["Costco Whse"].Transactions, int64(3))
+					is.Equal(*byName["Costco Whse"].Category, "Household")
+				},
+			)
+
+			t.Run(
+				"RenameAndMerge", func(t *testing.T) {
+					is := is_.New(t)
+
+					var costcoUUID string
+					err := conn.QueryRow(
+						ctx, "SELECT api.create_payee($1, 'Costco', $2)", ledgerUUID, categories["Groceries"],
+					).Scan(&costcoUUID)
+					is.NoErr(err)
+
+					_, byName := payees(is)
+					_, err = conn.Exec(ctx, "SELECT api.rename_payee($1, 'costco whse')", costcoUUID)
+					var pgErr *pgconn.PgError
+					is.True(errors.As(err, &pgErr))
+					is.Equal(pgErr.Code, "23505") // names are unique regardless of case
+
+					// the merge moves the reversals along with the transactions
+					var moved int
+					err = conn.QueryRow(
+						ctx, "SELECT api.merge_payees($1, $2)", byName["Costco Whse"].UUID, costcoUUID,
+					).Scan(&moved)
+					is.NoErr(err)
+					is.Equal(moved, 5)
+
+					_, err = conn.Exec(ctx, "SELECT api.rename_payee($1, 'Costco Wholesale')", costcoUUID)
+					is.NoErr(err)
+					_, err = conn.Exec(ctx, "SELECT api.set_payee_category($1, null)", byName["Blue Bottle"].UUID)
+					is.NoErr(err)
+
+					names, byName := payees(is)
+					is.Equal(names, []string{"Blue Bottle", "Costco Wholesale", "Farmers Market"})
+					is.Equal(byName["Costco Wholesale"].Transactions, int64(3))
+					is.Equal(*byName["Costco Wholesale"].Category, "Groceries") // the target keeps its own
+					is.Equal(byName["Blue Bottle"].Category, (*string)(nil))
+				},
+			)
+
+			t.Run(
+				"Errors", func(t *testing.T) {
+					is := is_.New(t)
+
+					var payeeUUID string
+					err := conn.QueryRow(ctx, "SELECT uuid FROM api.get_payees($1) LIMIT 1", ledgerUUID).Scan(&payeeUUID)
+					is.NoErr(err)
+
+					testCases := []struct {
+						name  string
+						query string
+						args  []any
+						code  string
+					}{
+						{"EmptyName", "SELECT api.create_payee($1, '  ')", []any{ledgerUUID}, "PB013"},
+						{"DuplicateName", "SELECT api.create_payee($1, 'BLUE BOTTLE')", []any{ledgerUUID}, "23505"},
+						{"UnknownCategory", "SELECT api.create_payee($1, 'Bakery', $2)", []any{ledgerUUID, checkingUUID}, "PB003"},
+						{"UnknownLedger", "SELECT api.create_payee($1, 'Bakery')", []any{"missing"}, "PB001"},
+						{"UnknownPayee", "SELECT api.rename_payee($1, 'Bakery')", []any{"missing"}, "PB008"},
+						{"MergeUnknownPayee", "SELECT api.merge_payees($1, $2)", []any{payeeUUID, "missing"}, "PB008"},
+						{"MergeIntoItself", "SELECT api.merge_payees($1, $1)", []any{payeeUUID}, "PB013"},
+						{"BackfillUnknownLedger", "SELECT api.backfill_payees($1)", []any{"missing"}, "PB001"},
+					}
+
+					for _, tc := range testCases {
+						t.Run(
+							tc.name, func(t *testing.T) {
+								is := is_.New(t)
+
+								_, err := conn.Exec(ctx, tc.query, tc.args...)
+								var pgErr *pgconn.PgError
+								is.True(errors.As(err, &pgErr)) // Error should be a PgError
+								is.Equal(pgErr.Code, tc.code)
+							},
+						)
+					}
+				},
+			)
+		},
+	)
+
+	t.Run(
+		"Tags", func(t *testing.T) {
+			is := is_.New(t)
+
+			var ledgerUUID, checkingUUID string
+			err := conn.QueryRow(
+				ctx,
+				"insert into api.ledgers (name) values ($1) returning uuid",
+				"Tag Test Ledger",
+			).Scan(&ledgerUUID)
+			is.NoErr(err) // should create ledger without error
+
+			err = conn.QueryRow(
+				ctx,
+				"INSERT INTO api.accounts (ledger_uuid, name, type) VALUES ($1, 'Checking', 'asset') RETURNING uuid",
+				ledgerUUID,
+			).Scan(&checkingUUID)
+			is.NoErr(err)
+
+			categories := make(map[string]string)
+			for _, name := range []string{"Dining", "Groceries", "Travel"} {
+				var uuid string
+				err := conn.QueryRow(ctx, "SELECT uuid FROM api.add_category($1, $2)", ledgerUUID, name).Scan(&uuid)
+				is.NoErr(err)
+				categories[name] = uuid
+			}
+
+			add := func(is *is_.I, date, description, txType string, amount int64, category string) string {
+				var uuid string
+				err := conn.QueryRow(
+					ctx,
+					"SELECT api.add_transaction($1, $2, $3, $4, $5, $6, $7)",
+					ledgerUUID, date, description, txType, amount, checkingUUID, categories[category],
+				).Scan(&uuid)
+				is.NoErr(err)
+				return uuid
+			}
+			hotelUUID := add(is, "2025-07-10", "Hotel", "outflow", 5000, "Travel")
+			dinnerUUID := add(is, "2025-08-03", "Dinner", "outflow", 3000, "Dining")
+			snacksUUID := add(is, "2025-08-05", "Snacks", "outflow", 1200, "Groceries")
+			refundUUID := add(is, "2025-08-09", "Hotel refund", "inflow", 1000, "Travel")
+
+			var splitUUID string
+			err = conn.QueryRow(
+				ctx,
+				"SELECT api.add_split_transaction($1, '2025-08-12', 'Market', 'outflow', $2, $3)",
+				ledgerUUID, checkingUUID,
+				fmt.Sprintf(
+					`[{"category_uuid": %q, "amount": 2000}, {"category_uuid": %q, "amount": 1500}]`,
+					categories["Dining"], categories["Groceries"],
+				),
+			).Scan(&splitUUID)
+			is.NoErr(err)
+
+			tag := func(is *is_.I, transactionUUID string, tags ...string) []string {
+				var current []string
+				err := conn.QueryRow(ctx, "SELECT api.tag_transaction($1, $2)", transactionUUID, tags).Scan(&current)
+				is.NoErr(err)
+				return current
+			}
+			// tagged returns the uuids and tags of the account history carrying
+			// every tag given.
+			tagged := func(is *is_.I, tags ...string) map[string][]string {
+				rows, err := conn.Query(
+					ctx,
+					"SELECT uuid, tags FROM api.get_account_transactions_page($1, p_tags => $2)",
+					checkingUUID, tags,
+				)
+				is.NoErr(err)
+				defer rows.Close()
+				byUUID := make(map[string][]string)
+				for rows.Next() {
+					var uuid string
+					var tags []string
+					is.NoErr(rows.Scan(&uuid, &tags))
+					byUUID[uuid] = tags
+				}
+				is.NoErr(rows.Err())
+				return byUUID
+			}
+
+			t.Run(
+				"TagAndUntag", func(t *testing.T) {
+					is := is_.New(t)
+
+					is.Equal(tag(is, hotelUUID, " Vacation-2026", "reimbursable", "vacation-2026"), []string{"reimbursable", "vacation-2026"})
+					is.Equal(tag(is, hotelUUID, "tax"), []string{"reimbursable", "tax", "vacation-2026"}) // tags add up
+
+					var current []string
+					err := conn.QueryRow(ctx, "SELECT api.untag_transaction($1, array['TAX'])", hotelUUID).Scan(&current)
+					is.NoErr(err)
+					is.Equal(current, []string{"reimbursable", "vacation-2026"})
+
+					is.Equal(tag(is, snacksUUID, "party"), []string{"party"})
+					err = conn.QueryRow(ctx, "SELECT api.untag_transaction($1)", snacksUUID).Scan(&current)
+					is.NoErr(err)
+					is.Equal(len(current), 0) // every tag is removed
+
+					var metadata *string
+					err = conn.QueryRow(ctx, "SELECT metadata::text FROM data.transactions WHERE uuid = $1", snacksUUID).Scan(&metadata)
+					is.NoErr(err)
+					is.Equal(metadata, (*string)(nil)) // without tags the key is dropped
+
+					is.Equal(tag(is, splitUUID, "vacation-2026"), []string{"vacation-2026"})
+					var legs int
+					err = conn.QueryRow(
+						ctx,
+						`SELECT count(*) FROM data.transactions t JOIN data.split_transactions s ON s.id = t.split_id
+						 WHERE s.uuid = $1 AND t.metadata -> 'tags' = '["vacation-2026"]'`,
+						splitUUID,
+					).Scan(&legs)
+					is.NoErr(err)
+					is.Equal(legs, 2) // every leg of the split is tagged
+				},
+			)
+
+			t.Run(
+				"Filter", func(t *testing.T) {
+					is := is_.New(t)
+
+					tag(is, dinnerUUID, "vacation-2026")
+					tag(is, refundUUID, "vacation-2026")
+
+					history := tagged(is)
+					is.Equal(len(history), 5) // no filter
+					is.Equal(len(history[snacksUUID]), 0)
+
+					history = tagged(is, "Vacation-2026")
+					is.Equal(len(history), 4)
+					is.Equal(history[splitUUID], []string{"vacation-2026"}) // the split is one row
+
+					history = tagged(is, "vacation-2026", "reimbursable")
+					is.Equal(len(history), 1) // every tag must match
+					is.Equal(history[hotelUUID], []string{"reimbursable", "vacation-2026"})
+				},
+			)
+
+			t.Run(
+				"Corrections", func(t *testing.T) {
+					is := is_.New(t)
+
+					var correctionUUID string
+					err := conn.QueryRow(
+						ctx,
+						"SELECT api.correct_transaction($1, 'outflow', $2, $3, 3500, 'Dinner', '2025-08-03')",
+						dinnerUUID, checkingUUID, categories["Dining"],
+					).Scan(&correctionUUID)
+					is.NoErr(err)
+
+					var splitCorrectionUUID string
+					err = conn.QueryRow(
+						ctx,
+						"SELECT api.correct_split_transaction($1, 'outflow', $2, '2025-08-12', 'Market', $3)",
+						splitUUID, checkingUUID,
+						fmt.Sprintf(
+							`[{"category_uuid": %q, "amount": 2500}, {"category_uuid": %q, "amount": 1500}]`,
+							categories["Dining"], categories["Groceries"],
+						),
+					).Scan(&splitCorrectionUUID)
+					is.NoErr(err)
+
+					history := tagged(is, "vacation-2026")
+					is.Equal(len(history), 6) // the history keeps the originals
+
+					is.Equal(history[correctionUUID], []string{"vacation-2026"})      // the correction keeps the tags
+					is.Equal(history[splitCorrectionUUID], []string{"vacation-2026"}) // and so does a corrected split
+				},
+			)
+
+			t.Run(
+				"Spending", func(t *testing.T) {
+					is := is_.New(t)
+
+					type spendingRow struct {
+						tag, period, category string
+						spent, transactions   int64
+					}
+					spending := func(is *is_.I, query string, args ...any) []spendingRow {
+						rows, err := conn.Query(ctx, query, args...)
+						is.NoErr(err)
+						defer rows.Close()
+						var report []spendingRow
+						for rows.Next() {
+							var row spendingRow
+							is.NoErr(rows.Scan(&row.tag, &row.period, &row.category, &row.spent, &row.transactions))
+							report = append(report, row)
+						}
+						is.NoErr(rows.Err())
+						return report
+					}
+
+					report := spending(
+						is,
+						"SELECT tag, period, category_name, spent, transactions FROM api.get_tag_spending($1, array['vacation-2026'])",
+						ledgerUUID,
+					)
+					is.Equal(
+						report, []spendingRow{
+							{"vacation-2026", "202507", "Travel", 5000, 1},
+							{"vacation-2026", "202508", "Dining", 6000, 2}, // the corrections replace the originals
+							{"vacation-2026", "202508", "Groceries", 1500, 1},
+							{"vacation-2026", "202508", "Travel", -1000, 1}, // refunds count against spending
+						},
+					)
+
+					report = spending(
+						is,
+						"SELECT tag, period, category_name, spent, transactions FROM api.get_tag_spending($1, p_start_period => '202507', p_end_period => '202507')",
+						ledgerUUID,
+					)
+					is.Equal(
+						report, []spendingRow{
+							{"reimbursable", "202507", "Travel", 5000, 1},
+							{"vacation-2026", "202507", "Travel", 5000, 1},
+						},
+					)
+				},
+			)
+
+			t.Run(
+				"Errors", func(t *testing.T) {
+					testCases := []struct {
+						name  string
+						query string
+						args  []any
+						code  string
+					}{
+						{"NoTags", "SELECT api.tag_transaction($1, '{}')", []any{hotelUUID}, "PB013"},
+						{"EmptyTag", "SELECT api.tag_transaction($1, array[' '])", []any{hotelUUID}, "PB013"},
+						{"TagWithSpace", "SELECT api.tag_transaction($1, array['road trip'])", []any{hotelUUID}, "PB013"},
+						{"TagWithComma", "SELECT api.tag_transaction($1, array['a,b'])", []any{hotelUUID}, "PB013"},
+						{"UnknownTransaction", "SELECT api.tag_transaction($1, array['trip'])", []any{"missing"}, "PB004"},
+						{"CorrectedTransaction", "SELECT api.tag_transaction($1, array['trip'])", []any{dinnerUUID}, "PB013"},
+						{"CorrectedSplit", "SELECT api.untag_transaction($1)", []any{splitUUID}, "PB013"},
+						{"FilterWithSpace", "SELECT * FROM api.get_account_transactions_page($1, null, array['road trip'])", []any{checkingUUID}, "PB013"},
+						{"SpendingUnknownLedger", "SELECT * FROM api.get_tag_spending($1)", []any{"missing"}, "PB001"},
+						{"SpendingInvalidPeriod", "SELECT * FROM api.get_tag_spending($1, p_start_period => '2025-08')", []any{ledgerUUID}, "P0001"},
+					}
+
+					for _, tc := range testCases {
+						t.Run(
+							tc.name, func(t *testing.T) {
+								is := is_.New(t)
+
+								_, err := conn.Exec(ctx, tc.query, tc.args...)
+								var pgErr *pgconn.PgError
+								is.True(errors.As(err, &pgErr)) // Error should be a PgError
+								is.Equal(pgErr.Code, tc.code)
+							},
+						)
+					}
+				},
+			)
+		},
+	)
+
+	t.Run(
+		"Search", func(t *testing.T) {
+			is := is_.New(t)
+
+			var ledgerUUID, checkingUUID string
+			err := conn.QueryRow(
+				ctx,
+				"insert into api.ledgers (name) values ($1) returning uuid",
+				"Search Test Ledger",
+			).Scan(&ledgerUUID)
+			is.NoErr(err) // should create ledger without error
+
+			err = conn.QueryRow(
+				ctx,
+				"INSERT INTO api.accounts (ledger_uuid, name, type) VALUES ($1, 'Checking', 'asset') RETURNING uuid",
+				ledgerUUID,
+			).Scan(&checkingUUID)
+			is.NoErr(err)
+
+			categories := make(map[string]string)
+			for _, name := range []string{"Dining", "Groceries"} {
+				var uuid string
+				err := conn.QueryRow(ctx, "SELECT uuid FROM api.add_category($1, $2)", ledgerUUID, name).Scan(&uuid)
+				is.NoErr(err)
+				categories[name] = uuid
+			}
+
+			uuids := make(map[string]string)
+			for _, tx := range []struct {
+				date, description, txType string
+				amount                    int64
+				category, payee           any
+			}{
+				{"2025-06-01", "COSTCO WHSE #0123", "outflow", 10000, categories["Groceries"], nil},
+				{"2025-06-02", "Latte", "outflow", 550, categories["Dining"], "Cafe Luna"},
+				{"2025-06-03", "Costco gas", "outflow", 4000, categories["Groceries"], nil},
+				{"2025-06-04", "Paycheck", "inflow", 200000, nil, nil},
+				{"2025-06-05", "Dinner 50% off", "outflow", 3000, categories["Dining"], nil},
+			} {
+				var uuid string
+				err := conn.QueryRow(
+					ctx,
+					"SELECT api.add_transaction($1, $2, $3, $4, $5, $6, $7, $8)",
+					ledgerUUID, tx.date, tx.description, tx.txType, tx.amount, checkingUUID, tx.category, tx.payee,
+				).Scan(&uuid)
+				is.NoErr(err)
+				uuids[tx.description] = uuid
+			}
+
+			// search returns the descriptions and uuids found, newest first.
+			search := func(is *is_.I, filters string, args ...any) ([]string, []string) {
+				rows, err := conn.Query(
+					ctx,
+					"SELECT description, uuid FROM api.search_transactions($1"+filters+")",
+					append([]any{ledgerUUID}, args...)...,
+				)
+				is.NoErr(err)
+				defer rows.Close()
+				var descriptions, found []string
+				for rows.Next() {
+					var description, uuid string
+					is.NoErr(rows.Scan(&description, &uuid))
+					descriptions = append(descriptions, description)
+					found = append(found, uuid)
+				}
+				is.NoErr(rows.Err())
+				return descriptions, found
+			}
+
+			t.Run(
+				"Query", func(t *testing.T) {
+					for query, want := range map[string][]string{
+						"costco":    {"Costco gas", "COSTCO WHSE #0123"},
+						"cost whse": {"COSTCO WHSE #0123"}, // every word as a prefix
+						"WHSE #01":  {"COSTCO WHSE #0123"}, // a fragment
+						"50%":       {"Dinner 50% off"},    // wildcards match themselves
+						"cafe":      {"Latte"},             // the payee name
+						"tea":       nil,
+					} {
+						t.Run(
+							query, func(t *testing.T) {
+								is := is_.New(t)
+
+								descriptions, _ := search(is, ", p_query => $2", query)
+								is.Equal(descriptions, want)
+							},
+						)
+					}
+				},
+			)
+
+			t.Run(
+				"Filters", func(t *testing.T) {
+					is := is_.New(t)
+
+					descriptions, _ := search(is, ", p_min_amount => $2, p_max_amount => $3", 3000, 10000)
+					is.Equal(descriptions, []string{"Dinner 50% off", "Costco gas", "COSTCO WHSE #0123"}) // inclusive
+
+					descriptions, _ = search(is, ", p_start_date => $2, p_end_date => $3", "2025-06-02", "2025-06-03")
+					is.Equal(descriptions, []string{"Costco gas", "Latte"})
+
+					descriptions, _ = search(is, ", p_category_uuid => $2", categories["Dining"])
+					is.Equal(descriptions, []string{"Dinner 50% off", "Latte"})
+
+					descriptions, _ = search(is, ", p_query => 'costco', p_account_uuid => $2", checkingUUID)
+					is.Equal(len(descriptions), 2)
+
+					descriptions, _ = search(is, ", p_status => 'pending'")
+					is.Equal(len(descriptions), 0)
+
+					_, err := conn.Exec(ctx, "SELECT api.tag_transaction($1, array['bulk'])", uuids["COSTCO WHSE #0123"])
+					is.NoErr(err)
+					descriptions, _ = search(is, ", p_metadata_keys => array['tags']")
+					is.Equal(descriptions, []string{"COSTCO WHSE #0123"})
+				},
+			)
+
+			t.Run(
+				"Pagination", func(t *testing.T) {
+					is := is_.New(t)
+
+					var pages [][]string
+					var after any
+					for {
+						descriptions, found := search(is, ", p_after => $2, p_limit => 2", after)
+						if len(found) == 0 {
+							break
+						}
+						pages = append(pages, descriptions)
+						after = found[len(found)-1]
+					}
+					is.Equal(
+						pages, [][]string{
+							{"Dinner 50% off", "Paycheck"},
+							{"Costco gas", "Latte"},
+							{"COSTCO WHSE #0123"},
+						},
+					)
+				},
+			)
+
+			t.Run(
+				"Corrections", func(t *testing.T) {
+					is := is_.New(t)
+
+					_, err := conn.Exec(
+						ctx,
+						"SELECT api.correct_transaction($1, 'outflow', $2, $3, 4200, 'Costco fuel', '2025-06-03')",
+						uuids["Costco gas"], checkingUUID, categories["Groceries"],
+					)
+					is.NoErr(err)
+
+					descriptions, _ := search(is, ", p_query => 'costco'")
+					is.Equal(descriptions, []string{"Costco fuel", "COSTCO WHSE #0123"}) // the original and its reversal are history
+				},
+			)
+
+			t.Run(
+				"Errors", func(t *testing.T) {
+					testCases := []struct {
+						name  string
+						query string
+						args  []any
+						code  string
+					}{
+						{"UnknownLedger", "SELECT * FROM api.search_transactions($1)", []any{"missing"}, "PB001"},
+						{"InvalidStatus", "SELECT * FROM api.search_transactions($1, p_status => 'cleared')", []any{ledgerUUID}, "PB013"},
+						{"InvalidLimit", "SELECT * FROM api.search_transactions($1, p_limit => 0)", []any{ledgerUUID}, "PB013"},
+						{"AmountRange", "SELECT * FROM api.search_transactions($1, p_min_amount => 10, p_max_amount => 5)", []any{ledgerUUID}, "PB010"},
+						{"DateRange", "SELECT * FROM api.search_transactions($1, p_start_date => '2025-06-02', p_end_date => '2025-06-01')", []any{ledgerUUID}, "PB011"},
+						{"UnknownAccount", "SELECT * FROM api.search_transactions($1, p_account_uuid => $2)", []any{ledgerUUID, categories["Dining"]}, "PB002"},
+						{"UnknownCategory", "SELECT * FROM api.search_transactions($1, p_category_uuid => $2)", []any{ledgerUUID, checkingUUID}, "PB003"},
+						{"UnknownCursor", "SELECT * FROM api.search_transactions($1, p_after => 'missing')", []any{ledgerUUID}, "PB004"},
+					}
+
+					for _, tc := range testCases {
+						t.Run(
+							tc.name, func(t *testing.T) {
+								is := is_.New(t)
+
+								_, err := conn.Exec(ctx, tc.query, tc.args...)
+								var pgErr *pgconn.PgError
+								is.True(errors.As(err, &pgErr)) // Error should be a PgError
+								is.Equal(pgErr.Code, tc.code)
+							},
+						)
+					}
+				},
+			)
+		},
+	)
+
+	t.Run(
+		"AccountHistoryPages", func(t *testing.T) {
+			is := is_.New(t)
+
+			var ledgerUUID, checkingUUID, groceriesUUID string
+			err := conn.QueryRow(
+				ctx,
+				"insert into api.ledgers (name) values ($1) returning uuid",
+				"History Pages Test Ledger",
+			).Scan(&ledgerUUID)
+			is.NoErr(err) // should create ledger without error
+
+			err = conn.QueryRow(
+				ctx,
+				"INSERT INTO api.accounts (ledger_uuid, name, type) VALUES ($1, 'Checking', 'asset') RETURNING uuid",
+				ledgerUUID,
+			).Scan(&checkingUUID)
+			is.NoErr(err)
+
+			err = conn.QueryRow(ctx, "SELECT uuid FROM api.add_category($1, 'Groceries')", ledgerUUID).Scan(&groceriesUUID)
+			is.NoErr(err)
+
+			// two transactions share a date, so the keyset goes past the date
+			for _, tx := range []struct {
+				date, description, txType string
+				amount                    int64
+			}{
+				{"2025-09-01", "Paycheck", "inflow", 100000},
+				{"2025-09-02", "Bakery", "outflow", 800},
+				{"2025-09-02", "Butcher", "outflow", 2400},
+				{"2025-09-04", "Market", "outflow", 3100},
+			} {
+				_, err := conn.Exec(
+					ctx,
+					"SELECT api.add_transaction($1, $2, $3, $4, $5, $6, $7)",
+					ledgerUUID, tx.date, tx.description, tx.txType, tx.amount, checkingUUID, groceriesUUID,
+				)
+				is.NoErr(err)
+			}
+			_, err = conn.Exec(
+				ctx,
+				"SELECT api.add_split_transaction($1, '2025-09-05', 'Supermarket', 'outflow', $2, $3)",
+				ledgerUUID, checkingUUID,
+				fmt.Sprintf(
+					`[{"category_uuid": %q, "amount": 1000}, {"category_uuid": %q, "amount": 500}]`,
+					groceriesUUID, groceriesUUID,
+				),
+			)
+			is.NoErr(err)
+
+			type row struct {
+				description, uuid string
+				balance           int64
+				total             *int64
+			}
+			// history returns the rows of api.get_account_transactions_page called
+			// with the named arguments given.
+			history := func(is *is_.I, arguments string, args ...any) []row {
+				rows, err := conn.Query(
+					ctx,
+					"SELECT description, uuid, running_balance, total_count FROM api.get_account_transactions_page($1"+arguments+")",
+					append([]any{checkingUUID}, args...)...,
+				)
+				is.NoErr(err)
+				defer rows.Close()
+				var found []row
+				for rows.Next() {
+					var r row
+					is.NoErr(rows.Scan(&r.description, &r.uuid, &r.balance, &r.total))
+					found = append(found, r)
+				}
+				is.NoErr(rows.Err())
+				return found
+			}
+			all := history(is, "")
+			is.Equal(len(all), 5)
+			is.Equal(all[0].total, (*int64)(nil)) // counted only on request
+
+			t.Run(
+				"After", func(t *testing.T) {
+					is := is_.New(t)
+
+					var pages [][]row
+					var after any
+					for {
+						page := history(is, ", p_after => $2, p_limit => 2", after)
+						if len(page) == 0 {
+							break
+						}
+						pages = append(pages, page)
+						after = page[len(page)-1].uuid
+					}
+					is.Equal(len(pages), 3)
+					is.Equal(pages, [][]row{all[0:2], all[2:4], all[4:5]}) // running balances match the whole history
+					is.Equal(pages[0][0].description, "Supermarket")       // a split is one row
+
+					// the legs of a split fill a single row of the page
+					is.Equal(history(is, ", p_limit => 1"), all[0:1])
+					is.Equal(history(is, ", p_after => $2, p_limit => 1", all[0].uuid), all[1:2])
+				},
+			)
+
+			t.Run(
+				"Before", func(t *testing.T) {
+					is := is_.New(t)
+
+					page := history(is, ", p_before => $2, p_limit => 2", all[4].uuid)
+					is.Equal(page, all[2:4]) // the rows next to the cursor, newest first
+
+					page = history(is, ", p_before => $2", all[1].uuid)
+					is.Equal(page, all[0:1])
+
+					page = history(is, ", p_after => $2, p_before => $3", all[1].uuid, all[4].uuid)
+					is.Equal(page, all[2:4])
+				},
+			)
+
+			t.Run(
+				"Total", func(t *testing.T) {
+					is := is_.New(t)
+
+					page := history(is, ", p_after => $2, p_limit => 1, p_with_total => true", all[0].uuid)
+					is.Equal(len(page), 1)
+					is.Equal(*page[0].total, int64(5))
+
+					page = history(is, ", p_status => 'pending', p_with_total => true")
+					is.Equal(len(page), 0)
+				},
+			)
+
+			t.Run(
+				"Errors", func(t *testing.T) {
+					testCases := []struct {
+						name  string
+						query string
+						args  []any
+						code  string
+					}{
+						{"UnknownCursor", "SELECT * FROM api.get_account_transactions_page($1, p_after => 'missing')", []any{checkingUUID}, "PB004"},
+						{"ZeroLimit", "SELECT * FROM api.get_account_transactions_page($1, p_limit => 0)", []any{checkingUUID}, "PB013"},
+						{"LimitTooLarge", "SELECT * FROM api.get_account_transactions_page($1, p_limit => 1001)", []any{checkingUUID}, "PB013"},
+					}
+
+					for _, tc := range testCases {
+						t.Run(
+							tc.name, func(t *testing.T) {
+								is := is_.New(t)
+
+								_, err := conn.Exec(ctx, tc.query, tc.args...)
+								var pgErr *pgconn.PgError
+								is.True(errors.As(err, &pgErr)) // Error should be a PgError
+								is.Equal(pgErr.Code, tc.code)
+							},
+						)
+					}
+				},
+			)
+		},
+	)
+}
+
+// ptr returns a pointer to v.
+func ptr[T any](v T) *T {
+	return &v
 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/j0lvera/pgbudget/migrations"
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" database/sql driver
+	"github.com/pressly/goose/v3"
+)
+
+const migrateUsage = `Usage: pgbudget migrate [flags] <up|down|status|redo|version>
+
+  up       apply all pending migrations
+  down     roll back the most recent migration
+  status   list every migration and when it was applied
+  redo     roll back the most recent migration and apply it again
+  version  print the current schema version
+
+Flags:
+`
+
+func runMigrate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dsn := fs.String("dsn", dsnFromEnv(), "PostgreSQL connection string (default $DATABASE_URL)")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), migrateUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *dsn == "" {
+		return errors.New("missing connection string: set -dsn or DATABASE_URL")
+	}
+
+	db, err := sql.Open("pgx", *dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	provider, err := migrations.NewProvider(db)
+	if err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "up":
+		results, err := provider.Up(ctx)
+		printResults(results)
+		if err != nil {
+			return fmt.Errorf("failed to migrate up: %w", err)
+		}
+		if len(results) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		result, err := provider.Down(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to migrate down: %w", err)
+		}
+		printResults([]*goose.MigrationResult{result})
+	case "redo":
+		down, err := provider.Down(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to roll back migration: %w", err)
+		}
+		up, err := provider.UpByOne(ctx)
+		printResults([]*goose.MigrationResult{down, up})
+		if err != nil {
+			return fmt.Errorf("failed to reapply migration: %w", err)
+		}
+	case "status":
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get migration status: %w", err)
+		}
+		printStatus(statuses)
+	case "version":
+		version, err := provider.GetDBVersion(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get schema version: %w", err)
+		}
+		fmt.Println(version)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown migrate command %q", fs.Arg(0))
+	}
+
+	return nil
+}
+
+func printResults(results []*goose.MigrationResult) {
+	for _, r := range results {
+		if r != nil && r.Source != nil {
+			fmt.Println(r)
+		}
+	}
+}
+
+func printStatus(statuses []*goose.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "APPLIED AT\tMIGRATION")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.State == goose.StateApplied {
+			appliedAt = s.AppliedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", appliedAt, filepath.Base(s.Source.Path))
+	}
+	w.Flush()
+}
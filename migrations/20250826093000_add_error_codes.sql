@@ -0,0 +1,842 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- attach stable sqlstate codes to the exceptions raised by the api
+-- messages stay exactly the same; clients can now branch on the code instead of the text
+-- codes use the custom class 'PB' (pgbudget):
+--   PB001 ledger not found
+--   PB002 account not found
+--   PB003 category not found
+--   PB004 transaction not found
+--   PB010 amount out of range
+--   PB011 date out of range
+--   PB012 invalid transaction type
+--   PB013 invalid input (names, descriptions)
+--   PB020 special account protected
+-- duplicate names keep the standard unique_violation (23505) code
+
+-- validate transaction amounts, dates, and types with error codes
+create or replace function utils.validate_transaction_data(
+    p_amount bigint,
+    p_date timestamptz,
+    p_type text default null
+) returns void as $$
+begin
+    -- validate amount is positive
+    if p_amount <= 0 then
+        raise exception 'Transaction amount must be positive. Received: $%.%',
+            p_amount / 100, lpad((p_amount % 100)::text, 2, '0')
+            using errcode = 'PB010';
+    end if;
+
+    -- validate amount is reasonable (less than $1 million)
+    if p_amount > 100000000 then -- $1,000,000.00 in cents
+        raise exception 'Transaction amount exceeds maximum limit of $1,000,000.00. Received: $%.%',
+            p_amount / 100, lpad((p_amount % 100)::text, 2, '0')
+            using errcode = 'PB010';
+    end if;
+
+    -- validate date is not too far in the future (more than 1 year)
+    if p_date > current_timestamp + interval '1 year' then
+        raise exception 'Transaction date cannot be more than 1 year in the future. Received: %',
+            p_date::date
+            using errcode = 'PB011';
+    end if;
+
+    -- validate date is not too far in the past (more than 10 years)
+    if p_date < current_timestamp - interval '10 years' then
+        raise exception 'Transaction date cannot be more than 10 years in the past. Received: %',
+            p_date::date
+            using errcode = 'PB011';
+    end if;
+
+    -- validate transaction type if provided
+    if p_type is not null and p_type not in ('inflow', 'outflow') then
+        raise exception 'Invalid transaction type: "%". Must be either "inflow" or "outflow".', p_type
+            using errcode = 'PB012';
+    end if;
+end;
+$$ language plpgsql immutable;
+
+-- validate common input parameters like names, descriptions with error codes
+create or replace function utils.validate_input_data(
+    p_name text default null,
+    p_description text default null,
+    p_field_name text default 'field'
+) returns text as $$
+declare
+    v_cleaned_name text;
+begin
+    -- validate and clean name if provided
+    if p_name is not null then
+        -- trim whitespace
+        v_cleaned_name := trim(p_name);
+
+        -- check if empty after trimming
+        if v_cleaned_name = '' then
+            raise exception '% name cannot be empty or contain only whitespace.', initcap(p_field_name)
+                using errcode = 'PB013';
+        end if;
+
+        -- check length constraints
+        if char_length(v_cleaned_name) > 255 then
+            raise exception '% name cannot exceed 255 characters. Current length: %',
+                initcap(p_field_name), char_length(v_cleaned_name)
+                using errcode = 'PB013';
+        end if;
+
+        -- check for invalid characters (basic validation)
+        if v_cleaned_name ~ '[<>"\\/]' then
+            raise exception '% name contains invalid characters. Please avoid: < > " \ /',
+                initcap(p_field_name)
+                using errcode = 'PB013';
+        end if;
+
+        return v_cleaned_name;
+    end if;
+
+    -- validate description length if provided
+    if p_description is not null and char_length(p_description) > 1000 then
+        raise exception 'Description cannot exceed 1000 characters. Current length: %',
+            char_length(p_description)
+            using errcode = 'PB013';
+    end if;
+
+    return p_name;
+end;
+$$ language plpgsql immutable;
+
+-- create a category account with error codes
+create or replace function utils.add_category(
+    p_ledger_uuid text,
+    p_name text,
+    p_user_data text = utils.get_user()
+) returns data.accounts as
+$$
+declare
+    v_ledger_id int;
+    v_account_record data.accounts;
+    v_cleaned_name text;
+begin
+    -- validate and clean input data
+    v_cleaned_name := utils.validate_input_data(p_name, null, 'category');
+
+    -- find the ledger ID for the specified UUID and user
+    select l.id
+      into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    -- raise exception if ledger not found for the user
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    -- create the category account (equity type, liability_like behavior)
+    begin
+        insert into data.accounts (ledger_id, name, type, internal_type, user_data)
+        values (v_ledger_id, v_cleaned_name, 'equity', 'liability_like', p_user_data)
+        returning * into v_account_record;
+    exception
+        when unique_violation then
+            raise exception using
+                message = utils.handle_constraint_violation('accounts_name_ledger_unique', 'accounts', v_cleaned_name),
+                errcode = 'unique_violation';
+        when foreign_key_violation then
+            raise exception 'Invalid ledger reference. Please verify the ledger exists.'
+                using errcode = 'PB001';
+    end;
+
+    return v_account_record;
+end;
+$$ language plpgsql security definer;
+
+-- create multiple categories at once with error codes
+create or replace function utils.add_categories(
+    p_ledger_uuid text,
+    p_names text[],
+    p_user_data text = utils.get_user()
+) returns setof data.accounts as
+$$
+declare
+    v_ledger_id int;
+    v_name text;
+    v_account_record data.accounts;
+begin
+    -- find the ledger ID for the specified UUID and user
+    select l.id
+      into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    -- raise exception if ledger not found for the user
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    -- process each category name
+    foreach v_name in array p_names
+    loop
+        -- skip empty names after trimming
+        v_name := trim(v_name);
+        if v_name = '' then
+            continue;
+        end if;
+
+        -- create the category account
+        begin
+            insert into data.accounts (ledger_id, name, type, internal_type, user_data)
+            values (v_ledger_id, v_name, 'equity', 'liability_like', p_user_data)
+            returning * into v_account_record;
+
+            return next v_account_record;
+        exception
+            when unique_violation then
+                raise exception 'Category with name "%" already exists in this ledger', v_name
+                    using errcode = 'unique_violation';
+        end;
+    end loop;
+
+    return;
+end;
+$$ language plpgsql security definer;
+
+-- find a category by name in a ledger with error codes
+create or replace function utils.find_category(
+    p_ledger_uuid text,
+    p_category_name text,
+    p_user_data text = utils.get_user()
+) returns text as
+$$
+declare
+    v_ledger_id int;
+    v_category_uuid text;
+begin
+    -- find the ledger ID for the specified UUID and user
+    select l.id
+      into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    -- raise exception if ledger not found for the user
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    -- find the category account UUID for this ledger, user, and name
+    select a.uuid
+      into v_category_uuid
+      from data.accounts a
+     where a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data
+       and a.name = p_category_name
+       and a.type = 'equity';
+
+    -- return the found UUID (will be null if not found)
+    return v_category_uuid;
+end;
+$$ language plpgsql stable security definer;
+
+-- add a transaction with error codes
+create or replace function utils.add_transaction(
+    p_ledger_uuid text,
+    p_date timestamptz,
+    p_description text,
+    p_type text,
+    p_amount bigint,
+    p_account_uuid text,
+    p_category_uuid text = null,
+    p_user_data text = utils.get_user()
+) returns int as
+$$
+declare
+    v_ledger_id             int;
+    v_account_id            int;
+    v_account_internal_type text;
+    v_category_id           int;
+    v_transaction_id        int;
+    v_debit_account_id      int;
+    v_credit_account_id     int;
+    v_cleaned_description   text;
+begin
+    -- validate transaction data
+    perform utils.validate_transaction_data(p_amount, p_date, p_type);
+
+    -- validate and clean description
+    v_cleaned_description := coalesce(trim(p_description), '');
+    if char_length(v_cleaned_description) > 500 then
+        raise exception 'Transaction description cannot exceed 500 characters. Current length: %',
+            char_length(v_cleaned_description)
+            using errcode = 'PB013';
+    end if;
+
+    -- find the ledger_id from uuid and validate ownership
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    -- find the account_id and internal_type in one query
+    select a.id, a.internal_type
+      into v_account_id, v_account_internal_type
+      from data.accounts a
+     where a.uuid = p_account_uuid
+       and a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found in ledger % for current user',
+                       p_account_uuid, p_ledger_uuid
+            using errcode = 'PB002';
+    end if;
+
+    -- handle category lookup
+    if p_category_uuid is null then
+        -- find the "Unassigned" category directly
+        select a.id into v_category_id
+          from data.accounts a
+         where a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.name = 'Unassigned'
+           and a.type = 'equity';
+
+        if v_category_id is null then
+            raise exception 'Default "Unassigned" category not found in ledger %. This indicates a system error.',
+                p_ledger_uuid
+                using errcode = 'PB003';
+        end if;
+    else
+        -- find the category by UUID
+        select a.id into v_category_id
+          from data.accounts a
+         where a.uuid = p_category_uuid
+           and a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.type = 'equity';
+
+        if v_category_id is null then
+            raise exception 'Category with UUID % not found in ledger % for current user',
+                           p_category_uuid, p_ledger_uuid
+                using errcode = 'PB003';
+        end if;
+    end if;
+
+    -- validate account type and transaction type combination
+    if (v_account_internal_type = 'asset_like' and p_type = 'outflow') or
+       (v_account_internal_type = 'liability_like' and p_type = 'inflow') then
+        -- debit category, credit account
+        v_debit_account_id := v_category_id;
+        v_credit_account_id := v_account_id;
+    elsif (v_account_internal_type = 'asset_like' and p_type = 'inflow') or
+          (v_account_internal_type = 'liability_like' and p_type = 'outflow') then
+        -- debit account, credit category
+        v_debit_account_id := v_account_id;
+        v_credit_account_id := v_category_id;
+    else
+        raise exception 'Invalid combination: account type "%" with transaction type "%". Please verify your account and transaction types.',
+            v_account_internal_type, p_type
+            using errcode = 'PB012';
+    end if;
+
+    -- create the transaction
+    begin
+        insert into data.transactions (
+            ledger_id, description, date, amount,
+            debit_account_id, credit_account_id, user_data
+        )
+        values (
+            v_ledger_id, v_cleaned_description, p_date, p_amount,
+            v_debit_account_id, v_credit_account_id, p_user_data
+        )
+        returning id into v_transaction_id;
+    exception
+        when unique_violation then
+            raise exception using
+                message = utils.handle_constraint_violation('transactions_uuid_unique', 'transactions'),
+                errcode = 'unique_violation';
+        when foreign_key_violation then
+            raise exception 'Invalid account reference in transaction. Please verify all accounts exist.'
+                using errcode = 'PB002';
+        when check_violation then
+            raise exception 'Transaction violates business rules. Please check amount and account constraints.'
+                using errcode = 'PB010';
+    end;
+
+    return v_transaction_id;
+end;
+$$ language plpgsql security definer;
+
+-- public api function to add a transaction with error codes
+create or replace function api.add_transaction(
+    p_ledger_uuid text,
+    p_date date,
+    p_description text,
+    p_type text, -- 'inflow' or 'outflow'
+    p_amount bigint,
+    p_account_uuid text, -- the bank account or credit card
+    p_category_uuid text default null -- the category, optional
+) returns text as $$
+declare
+    v_transaction_id int;
+    v_transaction_uuid text;
+begin
+    -- validate transaction type
+    if p_type not in ('inflow', 'outflow') then
+        raise exception 'Invalid transaction type: %. Must be "inflow" or "outflow"', p_type
+            using errcode = 'PB012';
+    end if;
+
+    -- call the utils function
+    select utils.add_transaction(
+        p_ledger_uuid,
+        p_date::timestamptz,
+        p_description,
+        p_type,
+        p_amount,
+        p_account_uuid,
+        p_category_uuid
+    ) into v_transaction_id;
+
+    -- get the uuid of the created transaction
+    select uuid into v_transaction_uuid
+    from data.transactions
+    where id = v_transaction_id;
+
+    return v_transaction_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- assign money from Income to a category with error codes
+create or replace function utils.assign_to_category(
+    p_ledger_uuid text,
+    p_date timestamptz,
+    p_description text,
+    p_amount bigint,
+    p_category_uuid text,
+    p_user_data text = utils.get_user()
+) returns table(r_uuid text, r_description text, r_amount bigint, r_date timestamptz, r_metadata jsonb, r_ledger_uuid text, r_transaction_type text, r_account_uuid text, r_category_uuid text) as
+$$
+declare
+    v_ledger_id          int;
+    v_income_account_id  int;
+    v_income_account_uuid text;
+    v_category_account_id int;
+    v_transaction_uuid text;
+    v_metadata jsonb;
+    v_transaction_record data.transactions;
+    v_cleaned_description text;
+begin
+    -- validate assignment amount and date
+    perform utils.validate_transaction_data(p_amount, p_date);
+
+    -- validate and clean description
+    v_cleaned_description := coalesce(trim(p_description), '');
+    if char_length(v_cleaned_description) > 500 then
+        raise exception 'Assignment description cannot exceed 500 characters. Current length: %',
+            char_length(v_cleaned_description)
+            using errcode = 'PB013';
+    end if;
+
+    -- find the ledger ID for the specified UUID and user
+    select l.id into v_ledger_id from data.ledgers l
+    where l.uuid = p_ledger_uuid and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    -- find the Income account ID and UUID for this ledger
+    select a.id, a.uuid into v_income_account_id, v_income_account_uuid
+    from data.accounts a
+    where a.ledger_id = v_ledger_id
+      and a.user_data = p_user_data
+      and a.name = 'Income'
+      and a.type = 'equity';
+
+    if v_income_account_id is null then
+        raise exception 'Income account not found for ledger %. This indicates a system error.', p_ledger_uuid
+            using errcode = 'PB003';
+    end if;
+
+    -- find the target category account ID
+    select a.id into v_category_account_id from data.accounts a
+    where a.uuid = p_category_uuid
+      and a.ledger_id = v_ledger_id
+      and a.user_data = p_user_data
+      and a.type = 'equity';
+
+    if v_category_account_id is null then
+        raise exception 'Category with UUID % not found in ledger % for current user',
+            p_category_uuid, p_ledger_uuid
+            using errcode = 'PB003';
+    end if;
+
+    -- create the assignment transaction (debit Income, credit Category)
+    begin
+        insert into data.transactions (ledger_id, description, date, amount, debit_account_id, credit_account_id, user_data)
+        values (v_ledger_id, v_cleaned_description, p_date, p_amount, v_income_account_id, v_category_account_id, p_user_data)
+        returning * into v_transaction_record;
+    exception
+        when unique_violation then
+            raise exception using
+                message = utils.handle_constraint_violation('transactions_uuid_unique', 'transactions'),
+                errcode = 'unique_violation';
+        when foreign_key_violation then
+            raise exception 'Invalid account reference in assignment. Please verify all accounts exist.'
+                using errcode = 'PB002';
+    end;
+
+    -- extract values for return
+    v_transaction_uuid := v_transaction_record.uuid;
+    v_metadata := v_transaction_record.metadata;
+
+    -- return the transaction details in the expected format
+    return query select
+        v_transaction_uuid,
+        v_cleaned_description,
+        p_amount,
+        p_date,
+        v_metadata,
+        p_ledger_uuid,
+        null::text, -- transaction_type is null for budget assignments
+        v_income_account_uuid,
+        p_category_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- correct a transaction with error codes
+create or replace function utils.correct_transaction(
+    p_original_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_category_uuid text,
+    p_new_amount bigint,
+    p_new_description text,
+    p_new_date date,
+    p_reason text default 'Transaction correction'
+) returns int as $$
+declare
+    v_original_tx data.transactions;
+    v_ledger_uuid text;
+    v_account_id bigint;
+    v_category_id bigint;
+    v_reversal_id bigint;
+    v_correction_id bigint;
+    v_debit_account_id bigint;
+    v_credit_account_id bigint;
+begin
+    -- get original transaction
+    select t.* into v_original_tx
+    from data.transactions t
+    where t.uuid = p_original_uuid
+      and t.user_data = utils.get_user();
+
+    if v_original_tx.id is null then
+        raise exception 'Transaction not found: %', p_original_uuid
+            using errcode = 'PB004';
+    end if;
+
+    -- get ledger uuid
+    select l.uuid into v_ledger_uuid
+    from data.ledgers l
+    where l.id = v_original_tx.ledger_id;
+
+    -- resolve account id from uuid
+    select id into v_account_id
+    from data.accounts
+    where uuid = p_new_account_uuid and user_data = utils.get_user();
+
+    if v_account_id is null then
+        raise exception 'Account not found: %', p_new_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    -- handle category lookup (default to Unassigned if null)
+    if p_new_category_uuid is null then
+        declare
+            v_unassigned_uuid text;
+        begin
+            select utils.find_category(v_ledger_uuid, 'Unassigned') into v_unassigned_uuid;
+
+            if v_unassigned_uuid is null then
+                raise exception 'Could not find "Unassigned" category in ledger for current user'
+                    using errcode = 'PB003';
+            end if;
+
+            -- convert UUID to ID
+            select id into v_category_id
+            from data.accounts
+            where uuid = v_unassigned_uuid and user_data = utils.get_user();
+        end;
+    else
+        -- find the specified category
+        select id into v_category_id
+        from data.accounts
+        where uuid = p_new_category_uuid and user_data = utils.get_user();
+
+        if v_category_id is null then
+            raise exception 'Category not found: %', p_new_category_uuid
+                using errcode = 'PB003';
+        end if;
+    end if;
+
+    -- determine debit/credit based on transaction type (budgeting logic)
+    case p_new_type
+        when 'outflow' then
+            -- money leaves account, goes to category
+            v_debit_account_id := v_category_id;
+            v_credit_account_id := v_account_id;
+        when 'inflow' then
+            -- money enters account, comes from category
+            v_debit_account_id := v_account_id;
+            v_credit_account_id := v_category_id;
+        else
+            raise exception 'Invalid transaction type: %. Must be "inflow" or "outflow"', p_new_type
+                using errcode = 'PB012';
+    end case;
+
+    -- create reversal transaction (opposite of original)
+    insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, user_data)
+    values (
+        v_original_tx.amount,
+        'REVERSAL: ' || v_original_tx.description,
+        v_original_tx.date,
+        v_original_tx.credit_account_id,  -- swap accounts to reverse
+        v_original_tx.debit_account_id,
+        v_original_tx.ledger_id,
+        utils.get_user()
+    ) returning id into v_reversal_id;
+
+    -- create corrected transaction with new values
+    insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, user_data)
+    values (
+        p_new_amount,
+        p_new_description,
+        p_new_date,
+        v_debit_account_id,
+        v_credit_account_id,
+        v_original_tx.ledger_id,
+        utils.get_user()
+    ) returning id into v_correction_id;
+
+    -- record the correction in transaction log
+    insert into data.transaction_log (original_transaction_id, reversal_transaction_id, correction_transaction_id, mutation_type, reason)
+    values (
+        v_original_tx.id,
+        v_reversal_id,
+        v_correction_id,
+        'correction',
+        p_reason
+    );
+
+    return v_correction_id;
+end;
+$$ language plpgsql security definer;
+
+-- delete a transaction with error codes
+create or replace function utils.delete_transaction(
+    p_original_uuid text,
+    p_reason text default 'Transaction deleted'
+) returns int as $$
+declare
+    v_original_tx data.transactions;
+    v_reversal_id bigint;
+begin
+    -- get original transaction
+    select * into v_original_tx
+    from data.transactions
+    where uuid = p_original_uuid
+      and user_data = utils.get_user();
+
+    if v_original_tx.id is null then
+        raise exception 'Transaction not found: %', p_original_uuid
+            using errcode = 'PB004';
+    end if;
+
+    -- create reversal transaction to cancel original
+    insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, user_data)
+    values (
+        v_original_tx.amount,
+        'DELETED: ' || v_original_tx.description,
+        v_original_tx.date,
+        v_original_tx.credit_account_id,  -- swap accounts to reverse
+        v_original_tx.debit_account_id,
+        v_original_tx.ledger_id,
+        utils.get_user()
+    ) returning id into v_reversal_id;
+
+    -- record the deletion in transaction log
+    insert into data.transaction_log (original_transaction_id, reversal_transaction_id, mutation_type, reason)
+    values (
+        v_original_tx.id,
+        v_reversal_id,
+        'deletion',
+        p_reason
+    );
+
+    return v_reversal_id;
+end;
+$$ language plpgsql security definer;
+
+-- prevent deletion of special accounts with error codes (acts on data.accounts)
+create or replace function utils.prevent_special_account_deletion()
+    returns trigger as
+$$
+begin
+    raise exception 'Cannot delete special account: %', OLD.name
+        using errcode = 'PB020';
+    return null;
+end;
+$$ language plpgsql;
+
+-- handle inserts into the api.accounts view with error codes
+create or replace function utils.accounts_insert_single_fn() returns trigger as
+$$
+declare
+    v_ledger_id   bigint;
+    v_user_data   text := utils.get_user();
+begin
+    -- get the ledger_id based on the provided ledger_uuid
+    select l.id
+      into v_ledger_id
+      from data.ledgers l
+     where l.uuid = NEW.ledger_uuid
+       and l.user_data = v_user_data;
+
+    -- raise exception if the ledger is not found for the current user
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user %', NEW.ledger_uuid, v_user_data
+            using errcode = 'PB001';
+    end if;
+
+    -- insert the account into the base data.accounts table
+       insert into data.accounts (name, type, description, metadata, ledger_id)
+       values (NEW.name,
+               NEW.type,
+               NEW.description,
+               NEW.metadata,
+               v_ledger_id)
+    returning uuid, user_data into
+        new.uuid, new.user_data;
+
+    return new;
+end;
+$$ language plpgsql security definer;
+
+-- handle deletes on the api.accounts view with error codes
+create or replace function utils.accounts_delete_single_fn()
+returns trigger as
+$$
+declare
+    v_account_id int;
+    v_user_data text := utils.get_user();
+    v_is_special boolean;
+begin
+    -- check if this is a special account that shouldn't be deleted
+    select (a.name in ('Income', 'Off-budget', 'Unassigned') and a.type = 'equity') into v_is_special
+      from data.accounts a
+     where a.uuid = OLD.uuid and a.user_data = v_user_data;
+
+    if v_is_special then
+        raise exception 'Cannot delete special account: %', OLD.name
+            using errcode = 'PB020';
+    end if;
+
+    -- get the internal ID of the account to delete
+    select a.id into v_account_id
+      from data.accounts a
+     where a.uuid = OLD.uuid and a.user_data = v_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user to delete', OLD.uuid
+            using errcode = 'PB002';
+    end if;
+
+    -- delete the account
+    delete from data.accounts where id = v_account_id;
+
+    return OLD;
+end;
+$$ language plpgsql volatile security definer;
+
+-- get account balance from snapshots with error codes
+create or replace function utils.get_account_balance_from_snapshots(
+    p_account_uuid text
+) returns bigint as $$
+declare
+    v_account_id bigint;
+begin
+    -- get account id
+    select id into v_account_id
+    from data.accounts
+    where uuid = p_account_uuid and user_data = utils.get_user();
+
+    if v_account_id is null then
+        raise exception 'account not found or does not belong to the specified ledger: %', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return utils.get_account_current_balance(v_account_id);
+end;
+$$ language plpgsql security definer;
+
+-- get all current balances for a ledger with error codes
+create or replace function utils.get_ledger_current_balances(
+    p_ledger_uuid text
+) returns table(
+    account_uuid text,
+    account_name text,
+    account_type text,
+    current_balance bigint
+) as $$
+declare
+    v_ledger_id bigint;
+begin
+    -- get ledger id
+    select id into v_ledger_id
+    from data.ledgers
+    where uuid = p_ledger_uuid and user_data = utils.get_user();
+
+    if v_ledger_id is null then
+        raise exception 'Ledger not found: %', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    -- return current balance for each account in the ledger
+    return query
+    select
+        a.uuid::text,
+        a.name,
+        a.type,
+        coalesce(utils.get_account_current_balance(a.id), 0)
+    from data.accounts a
+    where a.ledger_id = v_ledger_id
+      and a.user_data = utils.get_user()
+    order by a.type, a.name;
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- restoring the previous definitions only removes the sqlstate codes; messages are unchanged
+-- note: this would require restoring the exact previous implementations
+-- for now, we'll just indicate that a rollback would be needed
+
+select 'Error codes rollback - would need to restore previous function implementations';
+
+-- +goose StatementEnd
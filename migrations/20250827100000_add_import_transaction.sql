@@ -0,0 +1,141 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- index for finding imported transactions by the bank's transaction id (ofx fitid)
+create index idx_transactions_fitid on data.transactions ((metadata ->> 'fitid'))
+    where metadata ? 'fitid';
+
+-- add a transaction from a bank statement, storing its external id in metadata
+-- returns null without inserting when the account already has a transaction with
+-- that external id, so the same statement can be imported any number of times
+create or replace function utils.import_transaction(
+    p_ledger_uuid text,
+    p_date timestamptz,
+    p_description text,
+    p_type text,
+    p_amount bigint,
+    p_account_uuid text,
+    p_category_uuid text = null,
+    p_external_id text = null,
+    p_metadata jsonb = null,
+    p_user_data text = utils.get_user()
+) returns int as
+$$
+declare
+    v_transaction_id int;
+    v_metadata       jsonb;
+begin
+    -- validate metadata shape
+    if p_metadata is not null and jsonb_typeof(p_metadata) != 'object' then
+        raise exception 'Transaction metadata must be a JSON object'
+            using errcode = 'PB013';
+    end if;
+
+    if p_external_id is not null then
+        -- serialize imports into the same account so concurrent runs cannot both insert
+        perform pg_advisory_xact_lock(hashtextextended('pgbudget.import:' || p_account_uuid, 0));
+
+        -- deleted transactions count too, otherwise re-importing would bring them back
+        select t.id
+          into v_transaction_id
+          from data.transactions t
+               join data.accounts a on a.id in (t.debit_account_id, t.credit_account_id)
+         where a.uuid = p_account_uuid
+           and a.user_data = p_user_data
+           and t.user_data = p_user_data
+           and t.metadata ->> 'fitid' = p_external_id
+         limit 1;
+
+        if v_transaction_id is not null then
+            return null;
+        end if;
+    end if;
+
+    -- record the transaction through the regular path so all validation applies
+    v_transaction_id := utils.add_transaction(
+        p_ledger_uuid,
+        p_date,
+        p_description,
+        p_type,
+        p_amount,
+        p_account_uuid,
+        p_category_uuid,
+        p_user_data
+    );
+
+    -- attach the external id and any extra statement fields
+    v_metadata := coalesce(p_metadata, '{}'::jsonb);
+    if p_external_id is not null then
+        v_metadata := v_metadata || jsonb_build_object('fitid', p_external_id);
+    end if;
+
+    if v_metadata != '{}'::jsonb then
+        update data.transactions
+           set metadata = coalesce(metadata, '{}'::jsonb) || v_metadata
+         where id = v_transaction_id;
+    end if;
+
+    return v_transaction_id;
+end;
+$$ language plpgsql security definer;
+
+-- public api function to import a statement line
+-- returns the uuid of the new transaction, or null when it was already imported
+create or replace function api.import_transaction(
+    p_ledger_uuid text,
+    p_date date,
+    p_description text,
+    p_type text, -- 'inflow' or 'outflow'
+    p_amount bigint,
+    p_account_uuid text, -- the bank account or credit card
+    p_category_uuid text default null, -- the category, optional
+    p_external_id text default null, -- the bank's transaction id (ofx fitid)
+    p_metadata jsonb default null -- extra statement fields, merged into metadata
+) returns text as $$
+declare
+    v_transaction_id int;
+    v_transaction_uuid text;
+begin
+    -- validate transaction type
+    if p_type not in ('inflow', 'outflow') then
+        raise exception 'Invalid transaction type: %. Must be "inflow" or "outflow"', p_type
+            using errcode = 'PB012';
+    end if;
+
+    -- call the utils function
+    select utils.import_transaction(
+        p_ledger_uuid,
+        p_date::timestamptz,
+        p_description,
+        p_type,
+        p_amount,
+        p_account_uuid,
+        p_category_uuid,
+        nullif(trim(p_external_id), ''),
+        p_metadata
+    ) into v_transaction_id;
+
+    -- already imported
+    if v_transaction_id is null then
+        return null;
+    end if;
+
+    -- get the uuid of the created transaction
+    select uuid into v_transaction_uuid
+    from data.transactions
+    where id = v_transaction_id;
+
+    return v_transaction_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop function if exists api.import_transaction(text, date, text, text, bigint, text, text, text, jsonb);
+drop function if exists utils.import_transaction(text, timestamptz, text, text, bigint, text, text, text, jsonb, text);
+drop index if exists data.idx_transactions_fitid;
+
+-- +goose StatementEnd
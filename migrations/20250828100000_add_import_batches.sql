@@ -0,0 +1,287 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- one row per statement import, so re-runs can recognize what was already seen
+create table data.import_batches
+(
+    id          bigint generated always as identity primary key,
+    uuid        text        not null default utils.nanoid(8),
+    created_at  timestamptz not null default current_timestamp,
+
+    source      text,                               -- file name or format the lines came from
+    policy      text        not null default 'skip', -- how likely duplicates were handled
+    imported    int         not null default 0,
+    skipped     int         not null default 0,
+    merged      int         not null default 0,
+    forced      int         not null default 0,
+
+    ledger_id   bigint      not null references data.ledgers (id) on delete cascade,
+    account_id  bigint      not null references data.accounts (id) on delete cascade,
+    user_data   text        not null default utils.get_user(),
+
+    constraint import_batches_uuid_unique unique (uuid),
+    constraint import_batches_policy_check check (policy in ('skip', 'merge', 'force')),
+    constraint import_batches_user_data_length_check check (char_length(user_data) < 255)
+);
+
+-- one row per statement line of a batch, keyed by the line's fingerprint
+create table data.import_batch_items
+(
+    id             bigint generated always as identity primary key,
+    batch_id       bigint not null references data.import_batches (id) on delete cascade,
+    line           int,
+    fingerprint    text   not null,
+    action         text   not null,
+    transaction_id bigint references data.transactions (id),
+    user_data      text   not null default utils.get_user(),
+
+    constraint import_batch_items_action_check check (action in ('imported', 'skipped', 'merged', 'forced'))
+);
+
+-- index for looking up fingerprints seen in earlier batches
+create index idx_import_batch_items_fingerprint on data.import_batch_items (fingerprint);
+
+-- enable RLS
+alter table data.import_batches
+    enable row level security;
+
+create policy import_batches_policy on data.import_batches
+    using (user_data = utils.get_user())
+    with check (user_data = utils.get_user());
+
+alter table data.import_batch_items
+    enable row level security;
+
+create policy import_batch_items_policy on data.import_batch_items
+    using (user_data = utils.get_user())
+    with check (user_data = utils.get_user());
+
+-- public view of import batches
+create or replace view api.import_batches with (security_invoker = true) as
+select b.uuid,
+       b.created_at,
+       b.source,
+       b.policy,
+       b.imported,
+       b.skipped,
+       b.merged,
+       b.forced,
+       l.uuid as ledger_uuid,
+       a.uuid as account_uuid,
+       b.user_data
+  from data.import_batches b
+       join data.ledgers l on l.id = b.ledger_id
+       join data.accounts a on a.id = b.account_id;
+
+-- store a finished import batch and its lines
+-- p_items is a json array of {"line", "fingerprint", "action", "transaction_uuid"}
+create or replace function utils.add_import_batch(
+    p_account_uuid text,
+    p_source text,
+    p_policy text,
+    p_items jsonb,
+    p_user_data text = utils.get_user()
+) returns text as
+$$
+declare
+    v_account_id bigint;
+    v_ledger_id  bigint;
+    v_batch_id   bigint;
+    v_batch_uuid text;
+begin
+    -- validate policy and items
+    if p_policy not in ('skip', 'merge', 'force') then
+        raise exception 'Invalid duplicate policy: %. Must be "skip", "merge" or "force"', p_policy
+            using errcode = 'PB013';
+    end if;
+
+    if jsonb_typeof(coalesce(p_items, '[]'::jsonb)) != 'array' then
+        raise exception 'Import batch items must be a JSON array'
+            using errcode = 'PB013';
+    end if;
+
+    -- find the account and its ledger
+    select a.id, a.ledger_id
+      into v_account_id, v_ledger_id
+      from data.accounts a
+     where a.uuid = p_account_uuid
+       and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    -- create the batch
+    insert into data.import_batches (source, policy, ledger_id, account_id, user_data)
+    values (p_source, p_policy, v_ledger_id, v_account_id, p_user_data)
+    returning id, uuid into v_batch_id, v_batch_uuid;
+
+    -- store its lines, resolving transaction uuids to ids
+    insert into data.import_batch_items (batch_id, line, fingerprint, action, transaction_id, user_data)
+    select v_batch_id,
+           (i ->> 'line')::int,
+           i ->> 'fingerprint',
+           i ->> 'action',
+           t.id,
+           p_user_data
+      from jsonb_array_elements(coalesce(p_items, '[]'::jsonb)) i
+           left join data.transactions t
+                  on t.uuid = i ->> 'transaction_uuid'
+                 and t.user_data = p_user_data;
+
+    -- keep the counters in step with the items
+    update data.import_batches b
+       set imported = c.imported,
+           skipped  = c.skipped,
+           merged   = c.merged,
+           forced   = c.forced
+      from (
+          select count(*) filter (where action = 'imported') as imported,
+                 count(*) filter (where action = 'skipped')  as skipped,
+                 count(*) filter (where action = 'merged')   as merged,
+                 count(*) filter (where action = 'forced')   as forced
+            from data.import_batch_items
+           where batch_id = v_batch_id
+      ) c
+     where b.id = v_batch_id;
+
+    return v_batch_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- public api function to store an import batch
+create or replace function api.add_import_batch(
+    p_account_uuid text,
+    p_source text,
+    p_policy text,
+    p_items jsonb
+) returns text as
+$$
+begin
+    return utils.add_import_batch(p_account_uuid, p_source, p_policy, p_items);
+end;
+$$ language plpgsql security definer;
+
+-- find the fingerprints an account has already seen in earlier batches
+create or replace function api.find_import_fingerprints(
+    p_account_uuid text,
+    p_fingerprints text[]
+) returns table (
+    fingerprint text,
+    action text,
+    transaction_uuid text,
+    batch_uuid text
+) as
+$$
+begin
+    return query
+    select distinct on (i.fingerprint)
+           i.fingerprint,
+           i.action,
+           t.uuid,
+           b.uuid
+      from data.import_batch_items i
+           join data.import_batches b on b.id = i.batch_id
+           join data.accounts a on a.id = b.account_id
+           left join data.transactions t on t.id = i.transaction_id
+     where a.uuid = p_account_uuid
+       and a.user_data = utils.get_user()
+       and i.fingerprint = any (p_fingerprints)
+     order by i.fingerprint, b.created_at desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- list the transactions of an account in a date range, as import duplicate candidates
+create or replace function api.find_import_candidates(
+    p_account_uuid text,
+    p_start_date date,
+    p_end_date date
+) returns table (
+    uuid text,
+    date date,
+    type text,
+    amount bigint,
+    description text,
+    metadata jsonb
+) as
+$$
+declare
+    v_account_id    bigint;
+    v_internal_type text;
+begin
+    select a.id, a.internal_type
+      into v_account_id, v_internal_type
+      from data.accounts a
+     where a.uuid = p_account_uuid
+       and a.user_data = utils.get_user();
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return query
+    select t.uuid,
+           t.date,
+           -- same type rules as utils.get_account_transactions
+           case
+               when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                    (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+               then 'inflow'
+               else 'outflow'
+           end,
+           t.amount,
+           coalesce(t.description, ''),
+           t.metadata
+      from data.transactions t
+     where (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+       and t.deleted_at is null
+       and t.date between p_start_date and p_end_date
+     order by t.date, t.id;
+end;
+$$ language plpgsql stable security definer;
+
+-- merge statement fields into an existing transaction's metadata
+-- used when an imported line is recognized as a transaction entered by hand
+create or replace function api.merge_transaction_metadata(
+    p_transaction_uuid text,
+    p_metadata jsonb
+) returns void as
+$$
+begin
+    if p_metadata is null or jsonb_typeof(p_metadata) != 'object' then
+        raise exception 'Transaction metadata must be a JSON object'
+            using errcode = 'PB013';
+    end if;
+
+    update data.transactions t
+       set metadata = coalesce(t.metadata, '{}'::jsonb) || p_metadata
+     where t.uuid = p_transaction_uuid
+       and t.user_data = utils.get_user()
+       and t.deleted_at is null;
+
+    if not found then
+        raise exception 'Transaction not found: %', p_transaction_uuid
+            using errcode = 'PB004';
+    end if;
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop function if exists api.merge_transaction_metadata(text, jsonb);
+drop function if exists api.find_import_candidates(text, date, date);
+drop function if exists api.find_import_fingerprints(text, text[]);
+drop function if exists api.add_import_batch(text, text, text, jsonb);
+drop function if exists utils.add_import_batch(text, text, text, jsonb, text);
+drop view if exists api.import_batches;
+drop policy if exists import_batch_items_policy on data.import_batch_items;
+drop policy if exists import_batches_policy on data.import_batches;
+drop table if exists data.import_batch_items;
+drop table if exists data.import_batches;
+
+-- +goose StatementEnd
@@ -0,0 +1,181 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the return type changes, so the functions are dropped and recreated.
+-- 20250916100000 gives api.get_account_transactions its six columns back
+drop function if exists api.get_account_transactions(text);
+drop function if exists utils.get_account_transactions(text, text);
+
+-- account history with running balances, now with the transaction uuid and
+-- the uuid of the other side, so that clients can correct a row they list
+create or replace function utils.get_account_transactions(
+    p_account_uuid text,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text
+) as $$
+declare
+    v_account_id bigint;
+    v_internal_type text;
+begin
+    -- resolve the account uuid to its internal id and validate ownership
+    select a.id, a.internal_type
+    into v_account_id, v_internal_type
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return query
+    select
+        t.date,
+        -- the other account's name as category
+        o.name as category,
+        t.description,
+        -- determine transaction type based on account's internal type
+        case
+            when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                 (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+            then 'inflow'
+            else 'outflow'
+        end as type,
+        t.amount,
+        -- running balance from the balance snapshot of this transaction
+        coalesce(bs.balance, 0) as running_balance,
+        t.uuid,
+        o.uuid as category_uuid
+    from
+        data.transactions t
+        join data.accounts o on o.id = case
+            when t.debit_account_id = v_account_id then t.credit_account_id
+            else t.debit_account_id
+        end
+        left join data.balance_snapshots bs on (
+            bs.transaction_id = t.id
+            and bs.account_id = v_account_id
+            and bs.user_data = p_user_data
+        )
+    where
+        (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+        and t.deleted_at is null
+    order by
+        t.date desc,
+        t.created_at desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- public api function, passes through to the utils function
+create or replace function api.get_account_transactions(
+    p_account_uuid text
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text
+) as $$
+begin
+    return query
+    select * from utils.get_account_transactions(p_account_uuid);
+end;
+$$ language plpgsql stable security invoker;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop function if exists api.get_account_transactions(text);
+drop function if exists utils.get_account_transactions(text, text);
+
+-- restore the previous definitions, without the uuid columns
+create or replace function utils.get_account_transactions(
+    p_account_uuid text,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint
+) as $$
+declare
+    v_account_id bigint;
+    v_internal_type text;
+begin
+    select a.id, a.internal_type
+    into v_account_id, v_internal_type
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid;
+    end if;
+
+    return query
+    select
+        t.date,
+        case
+            when t.debit_account_id = v_account_id then
+                (select name from data.accounts where id = t.credit_account_id)
+            else
+                (select name from data.accounts where id = t.debit_account_id)
+        end as category,
+        t.description,
+        case
+            when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                 (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+            then 'inflow'
+            else 'outflow'
+        end as type,
+        t.amount,
+        coalesce(bs.balance, 0) as running_balance
+    from
+        data.transactions t
+        left join data.balance_snapshots bs on (
+            bs.transaction_id = t.id
+            and bs.account_id = v_account_id
+            and bs.user_data = p_user_data
+        )
+    where
+        (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+        and t.deleted_at is null
+    order by
+        t.date desc,
+        t.created_at desc;
+end;
+$$ language plpgsql stable security definer;
+
+create or replace function api.get_account_transactions(
+    p_account_uuid text
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint
+) as $$
+begin
+    return query
+    select * from utils.get_account_transactions(p_account_uuid);
+end;
+$$ language plpgsql stable security invoker;
+
+-- +goose StatementEnd
@@ -0,0 +1,794 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- a split transaction is one posting on a bank account or credit card shared
+-- by several categories. every category leg is an ordinary transaction between
+-- the account and the category, grouped under its split through split_id
+create table data.split_transactions
+(
+    id          bigint generated always as identity primary key,
+    uuid        text        not null default utils.nanoid(8),
+    created_at  timestamptz not null default current_timestamp,
+
+    date        date        not null,
+    description text,
+    type        text        not null, -- 'inflow' or 'outflow', seen from the account
+
+    account_id  bigint      not null references data.accounts (id),
+    ledger_id   bigint      not null references data.ledgers (id) on delete cascade,
+    user_data   text        not null default utils.get_user(),
+
+    constraint split_transactions_uuid_unique unique (uuid),
+    constraint split_transactions_type_check check (type in ('inflow', 'outflow')),
+    constraint split_transactions_user_data_length_check check (char_length(user_data) < 255)
+);
+
+-- enable RLS
+alter table data.split_transactions
+    enable row level security;
+
+create policy split_transactions_policy on data.split_transactions
+    using (user_data = utils.get_user())
+    with check (user_data = utils.get_user());
+
+-- the legs point to their split
+alter table data.transactions
+    add column split_id bigint references data.split_transactions (id);
+
+create index idx_transactions_split_id on data.transactions (split_id) where split_id is not null;
+
+-- a corrected split is replaced by a new split rather than by one transaction
+alter table data.transaction_log
+    add column correction_split_id bigint references data.split_transactions (id);
+
+-- record a split transaction; p_splits is a json array of at least two
+-- {"category_uuid", "amount", "memo"} objects. category_uuid defaults to
+-- Unassigned and memo to the description of the split
+create or replace function utils.add_split_transaction(
+    p_ledger_uuid text,
+    p_date timestamptz,
+    p_description text,
+    p_type text,
+    p_account_uuid text,
+    p_splits jsonb,
+    p_user_data text = utils.get_user()
+) returns bigint as
+$$
+declare
+    v_ledger_id           bigint;
+    v_account_id          bigint;
+    v_split_id            bigint;
+    v_transaction_id      bigint;
+    v_leg                 jsonb;
+    v_total               bigint := 0;
+    v_cleaned_description text;
+begin
+    if p_splits is null or jsonb_typeof(p_splits) <> 'array' or jsonb_array_length(p_splits) < 2 then
+        raise exception 'A split transaction needs at least two splits'
+            using errcode = 'PB013';
+    end if;
+
+    -- every leg needs a whole amount in cents
+    for v_leg in select * from jsonb_array_elements(p_splits)
+    loop
+        if jsonb_typeof(v_leg) <> 'object' or coalesce(v_leg ->> 'amount', '') !~ '^-?[0-9]+$' then
+            raise exception 'Each split needs an amount in cents. Received: %', v_leg
+                using errcode = 'PB013';
+        end if;
+        v_total := v_total + (v_leg ->> 'amount')::bigint;
+    end loop;
+
+    -- the total is held to the same limits as a single transaction
+    if p_type is null then
+        raise exception 'Invalid transaction type: %. Must be "inflow" or "outflow"', p_type
+            using errcode = 'PB012';
+    end if;
+    perform utils.validate_transaction_data(v_total, p_date, p_type);
+
+    -- validate and clean description
+    v_cleaned_description := coalesce(trim(p_description), '');
+    if char_length(v_cleaned_description) > 500 then
+        raise exception 'Transaction description cannot exceed 500 characters. Current length: %',
+            char_length(v_cleaned_description)
+            using errcode = 'PB013';
+    end if;
+
+    -- find the ledger_id from uuid and validate ownership
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    select a.id into v_account_id
+      from data.accounts a
+     where a.uuid = p_account_uuid
+       and a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found in ledger % for current user',
+                       p_account_uuid, p_ledger_uuid
+            using errcode = 'PB002';
+    end if;
+
+    insert into data.split_transactions (date, description, type, account_id, ledger_id, user_data)
+    values (p_date::date, v_cleaned_description, p_type, v_account_id, v_ledger_id, p_user_data)
+    returning id into v_split_id;
+
+    -- each leg goes through utils.add_transaction for its validation and
+    -- debit/credit rules, then joins the split
+    for v_leg in select * from jsonb_array_elements(p_splits)
+    loop
+        v_transaction_id := utils.add_transaction(
+            p_ledger_uuid,
+            p_date,
+            coalesce(nullif(trim(v_leg ->> 'memo'), ''), v_cleaned_description),
+            p_type,
+            (v_leg ->> 'amount')::bigint,
+            p_account_uuid,
+            nullif(v_leg ->> 'category_uuid', ''),
+            p_user_data
+        );
+
+        update data.transactions
+           set split_id = v_split_id
+         where id = v_transaction_id;
+    end loop;
+
+    return v_split_id;
+end;
+$$ language plpgsql security definer;
+
+-- reverse every leg of a split under a new split and log each reversal
+create or replace function utils.reverse_split_transaction(
+    p_split_id bigint,
+    p_description_prefix text,
+    p_mutation_type text,
+    p_reason text
+) returns bigint as $$
+declare
+    v_split data.split_transactions;
+    v_leg data.transactions;
+    v_reversal_split_id bigint;
+    v_reversal_id bigint;
+begin
+    select * into v_split
+    from data.split_transactions
+    where id = p_split_id
+      and user_data = utils.get_user();
+
+    insert into data.split_transactions (date, description, type, account_id, ledger_id, user_data)
+    values (
+        v_split.date,
+        p_description_prefix || v_split.description,
+        case v_split.type when 'inflow' then 'outflow' else 'inflow' end,
+        v_split.account_id,
+        v_split.ledger_id,
+        utils.get_user()
+    ) returning id into v_reversal_split_id;
+
+    for v_leg in
+        select * from data.transactions
+        where split_id = p_split_id
+          and user_data = utils.get_user()
+        order by id
+    loop
+        insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, split_id, user_data)
+        values (
+            v_leg.amount,
+            p_description_prefix || v_leg.description,
+            v_leg.date,
+            v_leg.credit_account_id,  -- swap accounts to reverse
+            v_leg.debit_account_id,
+            v_leg.ledger_id,
+            v_reversal_split_id,
+            utils.get_user()
+        ) returning id into v_reversal_id;
+
+        insert into data.transaction_log (original_transaction_id, reversal_transaction_id, mutation_type, reason)
+        values (v_leg.id, v_reversal_id, p_mutation_type, p_reason);
+    end loop;
+
+    return v_reversal_split_id;
+end;
+$$ language plpgsql security definer;
+
+-- delete a split transaction: all of its legs are reversed together
+create or replace function utils.delete_split_transaction(
+    p_split_uuid text,
+    p_reason text default 'Transaction deleted'
+) returns bigint as $$
+declare
+    v_split_id bigint;
+begin
+    select id into v_split_id
+    from data.split_transactions
+    where uuid = p_split_uuid
+      and user_data = utils.get_user();
+
+    if v_split_id is null then
+        raise exception 'Transaction not found: %', p_split_uuid
+            using errcode = 'PB004';
+    end if;
+
+    return utils.reverse_split_transaction(v_split_id, 'DELETED: ', 'deletion', p_reason);
+end;
+$$ language plpgsql security definer;
+
+-- correct a split transaction: all of its legs are reversed and a new split
+-- is recorded with the new values, linked from the transaction log
+create or replace function utils.correct_split_transaction(
+    p_split_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_date date,
+    p_new_description text,
+    p_new_splits jsonb,
+    p_reason text default 'Transaction correction'
+) returns bigint as $$
+declare
+    v_split data.split_transactions;
+    v_ledger_uuid text;
+    v_reversal_split_id bigint;
+    v_correction_split_id bigint;
+begin
+    select * into v_split
+    from data.split_transactions
+    where uuid = p_split_uuid
+      and user_data = utils.get_user();
+
+    if v_split.id is null then
+        raise exception 'Transaction not found: %', p_split_uuid
+            using errcode = 'PB004';
+    end if;
+
+    select l.uuid into v_ledger_uuid
+    from data.ledgers l
+    where l.id = v_split.ledger_id;
+
+    v_reversal_split_id := utils.reverse_split_transaction(v_split.id, 'REVERSAL: ', 'correction', p_reason);
+
+    v_correction_split_id := utils.add_split_transaction(
+        v_ledger_uuid,
+        p_new_date::timestamptz,
+        p_new_description,
+        p_new_type,
+        p_new_account_uuid,
+        p_new_splits
+    );
+
+    -- link the logged reversals to the new split
+    update data.transaction_log l
+       set correction_split_id = v_correction_split_id
+      from data.transactions r
+     where r.split_id = v_reversal_split_id
+       and l.reversal_transaction_id = r.id;
+
+    return v_correction_split_id;
+end;
+$$ language plpgsql security definer;
+
+-- the legs of a split transaction, each with the fields of the split
+create or replace function utils.get_split_transaction(
+    p_split_uuid text,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    uuid text,
+    date date,
+    description text,
+    type text,
+    account_uuid text,
+    amount bigint,
+    leg_uuid text,
+    category_uuid text,
+    category text,
+    memo text,
+    leg_amount bigint
+) as $$
+begin
+    if not exists (
+        select 1 from data.split_transactions s
+        where s.uuid = p_split_uuid and s.user_data = p_user_data
+    ) then
+        raise exception 'Transaction not found: %', p_split_uuid
+            using errcode = 'PB004';
+    end if;
+
+    return query
+    select
+        s.uuid,
+        s.date,
+        s.description,
+        s.type,
+        a.uuid as account_uuid,
+        (sum(t.amount) over ())::bigint as amount,
+        t.uuid as leg_uuid,
+        c.uuid as category_uuid,
+        c.name as category,
+        t.description as memo,
+        t.amount as leg_amount
+    from
+        data.split_transactions s
+        join data.accounts a on a.id = s.account_id
+        join data.transactions t on t.split_id = s.id
+        -- the category is the side of the leg that is not the account
+        join data.accounts c on c.id = case
+            when t.debit_account_id = s.account_id then t.credit_account_id
+            else t.debit_account_id
+        end
+    where
+        s.uuid = p_split_uuid
+        and s.user_data = p_user_data
+    order by
+        t.id;
+end;
+$$ language plpgsql stable security definer;
+
+-- the return type changes, so the functions are dropped and recreated
+drop function if exists api.get_account_transactions(text);
+drop function if exists utils.get_account_transactions(text, text);
+
+-- account history with running balances. the legs of a split are shown as
+-- one row carrying the uuid of the split; on the bank side its category lists
+-- every category of the split and category_uuid is null
+create or replace function utils.get_account_transactions(
+    p_account_uuid text,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean
+) as $$
+declare
+    v_account_id bigint;
+    v_internal_type text;
+begin
+    -- resolve the account uuid to its internal id and validate ownership
+    select a.id, a.internal_type
+    into v_account_id, v_internal_type
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return query
+    with legs as (
+        select
+            t.id,
+            t.date,
+            t.created_at,
+            t.description,
+            t.amount,
+            t.uuid,
+            t.split_id,
+            o.name as other_name,
+            o.uuid as other_uuid,
+            -- determine transaction type based on account's internal type
+            case
+                when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                     (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+                then 'inflow'
+                else 'outflow'
+            end as type
+        from
+            data.transactions t
+            join data.accounts o on o.id = case
+                when t.debit_account_id = v_account_id then t.credit_account_id
+                else t.debit_account_id
+            end
+        where
+            (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+            and t.deleted_at is null
+    )
+    select
+        min(l.date) as date,
+        string_agg(distinct l.other_name, ', ' order by l.other_name) as category,
+        -- a single leg keeps its memo, several legs show the split
+        case when count(*) = 1 then min(l.description) else s.description end as description,
+        min(l.type) as type,
+        sum(l.amount)::bigint as amount,
+        -- running balance from the balance snapshot of the last leg
+        coalesce(
+            (
+                select bs.balance
+                from data.balance_snapshots bs
+                where bs.transaction_id = max(l.id)
+                  and bs.account_id = v_account_id
+                  and bs.user_data = p_user_data
+            ), 0
+        ) as running_balance,
+        coalesce(s.uuid, min(l.uuid)) as uuid,
+        case when count(distinct l.other_uuid) = 1 then min(l.other_uuid) end as category_uuid,
+        s.id is not null as split
+    from
+        legs l
+        left join data.split_transactions s on s.id = l.split_id
+    group by
+        s.id,
+        case when s.id is null then l.id end
+    order by
+        min(l.date) desc,
+        max(l.created_at) desc,
+        max(l.id) desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- public api function, passes through to the utils function
+create or replace function api.get_account_transactions(
+    p_account_uuid text
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean
+) as $$
+begin
+    return query
+    select * from utils.get_account_transactions(p_account_uuid);
+end;
+$$ language plpgsql stable security invoker;
+
+-- public api function to record a split transaction
+create or replace function api.add_split_transaction(
+    p_ledger_uuid text,
+    p_date date,
+    p_description text,
+    p_type text, -- 'inflow' or 'outflow'
+    p_account_uuid text, -- the bank account or credit card
+    p_splits jsonb -- [{"category_uuid": "...", "amount": 8000, "memo": "..."}, ...]
+) returns text as $$
+declare
+    v_split_id bigint;
+    v_split_uuid text;
+begin
+    select utils.add_split_transaction(
+        p_ledger_uuid,
+        p_date::timestamptz,
+        p_description,
+        p_type,
+        p_account_uuid,
+        p_splits
+    ) into v_split_id;
+
+    select uuid into v_split_uuid
+    from data.split_transactions
+    where id = v_split_id;
+
+    return v_split_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- public api function to correct a split transaction
+create or replace function api.correct_split_transaction(
+    p_split_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_date date,
+    p_new_description text,
+    p_new_splits jsonb,
+    p_reason text default 'Transaction correction'
+) returns text as $$
+declare
+    v_split_id bigint;
+    v_split_uuid text;
+begin
+    select utils.correct_split_transaction(
+        p_split_uuid,
+        p_new_type,
+        p_new_account_uuid,
+        p_new_date,
+        p_new_description,
+        p_new_splits,
+        p_reason
+    ) into v_split_id;
+
+    select uuid into v_split_uuid
+    from data.split_transactions
+    where id = v_split_id;
+
+    return v_split_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- public api function listing the legs of a split transaction
+create or replace function api.get_split_transaction(
+    p_split_uuid text
+) returns table (
+    uuid text,
+    date date,
+    description text,
+    type text,
+    account_uuid text,
+    amount bigint,
+    leg_uuid text,
+    category_uuid text,
+    category text,
+    memo text,
+    leg_amount bigint
+) as $$
+begin
+    return query
+    select * from utils.get_split_transaction(p_split_uuid);
+end;
+$$ language plpgsql stable security invoker;
+
+-- a leg only changes together with its split: correcting it alone would
+-- take it out of the split
+create or replace function api.correct_transaction(
+    p_original_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_category_uuid text,
+    p_new_amount bigint,
+    p_new_description text,
+    p_new_date date,
+    p_reason text default 'Transaction correction'
+) returns text as $$
+declare
+    v_split_uuid text;
+    v_correction_id int;
+    v_correction_uuid text;
+begin
+    -- find the split the uuid names, or the split of the leg it names
+    select s.uuid into v_split_uuid
+    from data.split_transactions s
+    where s.user_data = utils.get_user()
+      and (
+          s.uuid = p_original_uuid
+          or s.id = (select t.split_id from data.transactions t where t.uuid = p_original_uuid)
+      );
+
+    if v_split_uuid is not null then
+        raise exception 'Transaction % is part of split transaction %. Use api.correct_split_transaction to change its splits.',
+            p_original_uuid, v_split_uuid
+            using errcode = 'PB013';
+    end if;
+
+    -- call utils function to do all the work
+    select utils.correct_transaction(
+        p_original_uuid,
+        p_new_type,
+        p_new_account_uuid,
+        p_new_category_uuid,
+        p_new_amount,
+        p_new_description,
+        p_new_date,
+        p_reason
+    ) into v_correction_id;
+
+    -- get the uuid of the corrected transaction
+    select uuid into v_correction_uuid
+    from data.transactions
+    where id = v_correction_id;
+
+    return v_correction_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- deleting a split, or one of its legs, deletes all of its legs
+create or replace function api.delete_transaction(
+    p_original_uuid text,
+    p_reason text default 'Transaction deleted'
+) returns text as $$
+declare
+    v_split_uuid text;
+    v_reversal_id bigint;
+    v_reversal_uuid text;
+begin
+    -- find the split the uuid names, or the split of the leg it names
+    select s.uuid into v_split_uuid
+    from data.split_transactions s
+    where s.user_data = utils.get_user()
+      and (
+          s.uuid = p_original_uuid
+          or s.id = (select t.split_id from data.transactions t where t.uuid = p_original_uuid)
+      );
+
+    if v_split_uuid is not null then
+        select utils.delete_split_transaction(v_split_uuid, p_reason) into v_reversal_id;
+
+        select uuid into v_reversal_uuid
+        from data.split_transactions
+        where id = v_reversal_id;
+
+        return v_reversal_uuid;
+    end if;
+
+    -- call utils function to do all the work
+    select utils.delete_transaction(
+        p_original_uuid,
+        p_reason
+    ) into v_reversal_id;
+
+    -- get the uuid of the reversal transaction
+    select uuid into v_reversal_uuid
+    from data.transactions
+    where id = v_reversal_id;
+
+    return v_reversal_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop function if exists api.get_split_transaction(text);
+drop function if exists api.correct_split_transaction(text, text, text, date, text, jsonb, text);
+drop function if exists api.add_split_transaction(text, date, text, text, text, jsonb);
+drop function if exists utils.get_split_transaction(text, text);
+drop function if exists utils.correct_split_transaction(text, text, text, date, text, jsonb, text);
+drop function if exists utils.delete_split_transaction(text, text);
+drop function if exists utils.reverse_split_transaction(bigint, text, text, text);
+drop function if exists utils.add_split_transaction(text, timestamptz, text, text, text, jsonb, text);
+
+-- restore the wrappers without split handling
+create or replace function api.correct_transaction(
+    p_original_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_category_uuid text,
+    p_new_amount bigint,
+    p_new_description text,
+    p_new_date date,
+    p_reason text default 'Transaction correction'
+) returns text as $$
+declare
+    v_correction_id int;
+    v_correction_uuid text;
+begin
+    select utils.correct_transaction(
+        p_original_uuid,
+        p_new_type,
+        p_new_account_uuid,
+        p_new_category_uuid,
+        p_new_amount,
+        p_new_description,
+        p_new_date,
+        p_reason
+    ) into v_correction_id;
+
+    select uuid into v_correction_uuid
+    from data.transactions
+    where id = v_correction_id;
+
+    return v_correction_uuid;
+end;
+$$ language plpgsql security definer;
+
+create or replace function api.delete_transaction(
+    p_original_uuid text,
+    p_reason text default 'Transaction deleted'
+) returns text as $$
+declare
+    v_reversal_id int;
+    v_reversal_uuid text;
+begin
+    select utils.delete_transaction(
+        p_original_uuid,
+        p_reason
+    ) into v_reversal_id;
+
+    select uuid into v_reversal_uuid
+    from data.transactions
+    where id = v_reversal_id;
+
+    return v_reversal_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- restore the account history without the split column
+drop function if exists api.get_account_transactions(text);
+drop function if exists utils.get_account_transactions(text, text);
+
+create or replace function utils.get_account_transactions(
+    p_account_uuid text,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text
+) as $$
+declare
+    v_account_id bigint;
+    v_internal_type text;
+begin
+    select a.id, a.internal_type
+    into v_account_id, v_internal_type
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return query
+    select
+        t.date,
+        o.name as category,
+        t.description,
+        case
+            when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                 (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+            then 'inflow'
+            else 'outflow'
+        end as type,
+        t.amount,
+        coalesce(bs.balance, 0) as running_balance,
+        t.uuid,
+        o.uuid as category_uuid
+    from
+        data.transactions t
+        join data.accounts o on o.id = case
+            when t.debit_account_id = v_account_id then t.credit_account_id
+            else t.debit_account_id
+        end
+        left join data.balance_snapshots bs on (
+            bs.transaction_id = t.id
+            and bs.account_id = v_account_id
+            and bs.user_data = p_user_data
+        )
+    where
+        (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+        and t.deleted_at is null
+    order by
+        t.date desc,
+        t.created_at desc;
+end;
+$$ language plpgsql stable security definer;
+
+create or replace function api.get_account_transactions(
+    p_account_uuid text
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text
+) as $$
+begin
+    return query
+    select * from utils.get_account_transactions(p_account_uuid);
+end;
+$$ language plpgsql stable security invoker;
+
+drop index if exists data.idx_transactions_split_id;
+alter table data.transaction_log drop column if exists correction_split_id;
+alter table data.transactions drop column if exists split_id;
+
+drop policy if exists split_transactions_policy on data.split_transactions;
+drop table if exists data.split_transactions;
+
+-- +goose StatementEnd
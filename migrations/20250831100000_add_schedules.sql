@@ -0,0 +1,599 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- a schedule is a recurring transaction, such as rent on the 1st or a
+-- biweekly paycheck. due occurrences are recorded as pending transactions by
+-- utils.run_schedules; next_date is the first occurrence not recorded yet and
+-- is null once the schedule has ended
+--   monthly           on day_of_month every `every` months; days past the end
+--                     of a short month fall on its last day
+--   weekly            every `every` weeks from start_date
+--   last_business_day on the last weekday of the month every `every` months
+create table data.schedules
+(
+    id           bigint generated always as identity primary key,
+    uuid         text        not null default utils.nanoid(8),
+    created_at   timestamptz not null default current_timestamp,
+    updated_at   timestamptz not null default current_timestamp,
+
+    name         text        not null,
+    description  text,
+    type         text        not null, -- 'inflow' or 'outflow', seen from the account
+    amount       bigint      not null,
+
+    frequency    text        not null,
+    every        int         not null default 1,
+    day_of_month int,
+    start_date   date        not null,
+    end_date     date,
+    next_date    date,
+
+    account_id   bigint      not null references data.accounts (id),
+    category_id  bigint references data.accounts (id), -- null records to Unassigned
+    ledger_id    bigint      not null references data.ledgers (id) on delete cascade,
+    user_data    text        not null default utils.get_user(),
+
+    constraint schedules_uuid_unique unique (uuid),
+    constraint schedules_name_unique unique (ledger_id, name),
+    constraint schedules_type_check check (type in ('inflow', 'outflow')),
+    constraint schedules_amount_positive check (amount > 0),
+    constraint schedules_frequency_check check (frequency in ('monthly', 'weekly', 'last_business_day')),
+    constraint schedules_every_positive check (every > 0),
+    constraint schedules_day_of_month_check check (
+        (frequency = 'monthly' and day_of_month between 1 and 31)
+        or (frequency <> 'monthly' and day_of_month is null)
+    ),
+    constraint schedules_end_date_check check (end_date >= start_date),
+    constraint schedules_user_data_length_check check (char_length(user_data) < 255)
+);
+
+-- enable RLS
+alter table data.schedules
+    enable row level security;
+
+create policy schedules_policy on data.schedules
+    using (user_data = utils.get_user())
+    with check (user_data = utils.get_user());
+
+-- transactions recorded by a schedule point to it. the unique index keeps a
+-- run from recording the same occurrence twice
+alter table data.transactions
+    add column schedule_id bigint references data.schedules (id) on delete set null;
+
+create unique index idx_transactions_schedule_occurrence on data.transactions (schedule_id, date)
+    where schedule_id is not null;
+
+-- the first occurrence of a recurrence that is on or after p_start_date and
+-- strictly after p_after
+create or replace function utils.schedule_occurrence_after(
+    p_frequency text,
+    p_every int,
+    p_day_of_month int,
+    p_start_date date,
+    p_after date
+) returns date as $$
+declare
+    v_month date;
+    v_last_day date;
+    v_step int := 0;
+    v_date date;
+begin
+    if p_frequency = 'weekly' then
+        if p_after < p_start_date then
+            return p_start_date;
+        end if;
+        return p_start_date + ((p_after - p_start_date) / (7 * p_every) + 1) * 7 * p_every;
+    end if;
+
+    -- monthly rules count months from the month of the start date, skipping
+    -- the intervals that end before the month of p_after
+    if p_after >= p_start_date then
+        v_step := ((extract(year from p_after) - extract(year from p_start_date)) * 12
+                   + extract(month from p_after) - extract(month from p_start_date))::int / p_every;
+    end if;
+
+    loop
+        v_month := (date_trunc('month', p_start_date) + make_interval(months => v_step * p_every))::date;
+        v_last_day := (v_month + interval '1 month - 1 day')::date;
+
+        v_date := case p_frequency
+            when 'monthly' then least(v_month + p_day_of_month - 1, v_last_day)
+            -- step back from saturday and sunday
+            else v_last_day - greatest(extract(isodow from v_last_day)::int - 5, 0)
+        end;
+
+        exit when v_date >= p_start_date and v_date > p_after;
+        v_step := v_step + 1;
+    end loop;
+
+    return v_date;
+end;
+$$ language plpgsql immutable;
+
+-- create a schedule and return its id. day_of_month defaults to the day of
+-- the start date for monthly schedules
+create or replace function utils.create_schedule(
+    p_ledger_uuid text,
+    p_name text,
+    p_type text,
+    p_amount bigint,
+    p_account_uuid text,
+    p_frequency text,
+    p_start_date date,
+    p_category_uuid text = null,
+    p_every int = 1,
+    p_day_of_month int = null,
+    p_end_date date = null,
+    p_description text = null,
+    p_user_data text = utils.get_user()
+) returns bigint as
+$$
+declare
+    v_ledger_id    bigint;
+    v_account_id   bigint;
+    v_category_id  bigint;
+    v_schedule_id  bigint;
+    v_name         text;
+    v_day_of_month int;
+begin
+    v_name := utils.validate_input_data(p_name, null, 'schedule');
+
+    -- occurrences are held to the same limits as a single transaction
+    if p_type is null then
+        raise exception 'Invalid transaction type: %. Must be "inflow" or "outflow"', p_type
+            using errcode = 'PB012';
+    end if;
+    perform utils.validate_transaction_data(p_amount, p_start_date, p_type);
+
+    if p_frequency is null or p_frequency not in ('monthly', 'weekly', 'last_business_day') then
+        raise exception 'Invalid frequency: %. Must be "monthly", "weekly" or "last_business_day"', p_frequency
+            using errcode = 'PB013';
+    end if;
+
+    if p_every is null or p_every < 1 then
+        raise exception 'A schedule must repeat at least every 1 period. Received: %', p_every
+            using errcode = 'PB013';
+    end if;
+
+    if p_frequency = 'monthly' then
+        v_day_of_month := coalesce(p_day_of_month, extract(day from p_start_date)::int);
+        if v_day_of_month not between 1 and 31 then
+            raise exception 'Day of month must be between 1 and 31. Received: %', v_day_of_month
+                using errcode = 'PB013';
+        end if;
+    elsif p_day_of_month is not null then
+        raise exception 'Day of month only applies to monthly schedules'
+            using errcode = 'PB013';
+    end if;
+
+    if p_end_date < p_start_date then
+        raise exception 'Schedule end date % is before its start date %', p_end_date, p_start_date
+            using errcode = 'PB011';
+    end if;
+
+    if char_length(p_description) > 500 then
+        raise exception 'Transaction description cannot exceed 500 characters. Current length: %',
+            char_length(p_description)
+            using errcode = 'PB013';
+    end if;
+
+    -- find the ledger_id from uuid and validate ownership
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    select a.id into v_account_id
+      from data.accounts a
+     where a.uuid = p_account_uuid
+       and a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found in ledger % for current user',
+                       p_account_uuid, p_ledger_uuid
+            using errcode = 'PB002';
+    end if;
+
+    if p_category_uuid is not null then
+        select a.id into v_category_id
+          from data.accounts a
+         where a.uuid = p_category_uuid
+           and a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.type = 'equity';
+
+        if v_category_id is null then
+            raise exception 'Category with UUID % not found in ledger % for current user',
+                           p_category_uuid, p_ledger_uuid
+                using errcode = 'PB003';
+        end if;
+    end if;
+
+    insert into data.schedules (
+        name, description, type, amount, frequency, every, day_of_month,
+        start_date, end_date, next_date, account_id, category_id, ledger_id, user_data
+    )
+    values (
+        v_name, nullif(trim(p_description), ''), p_type, p_amount, p_frequency, p_every, v_day_of_month,
+        p_start_date, p_end_date,
+        utils.schedule_occurrence_after(p_frequency, p_every, v_day_of_month, p_start_date, p_start_date - 1),
+        v_account_id, v_category_id, v_ledger_id, p_user_data
+    )
+    returning id into v_schedule_id;
+
+    return v_schedule_id;
+end;
+$$ language plpgsql security definer;
+
+-- delete a schedule. transactions it already recorded are kept
+create or replace function utils.delete_schedule(
+    p_schedule_uuid text,
+    p_user_data text = utils.get_user()
+) returns void as
+$$
+begin
+    delete from data.schedules s
+     where s.uuid = p_schedule_uuid
+       and s.user_data = p_user_data;
+
+    if not found then
+        raise exception 'Schedule not found: %', p_schedule_uuid
+            using errcode = 'PB005';
+    end if;
+end;
+$$ language plpgsql security definer;
+
+-- the schedules of a ledger
+create or replace function utils.get_schedules(
+    p_ledger_uuid text,
+    p_user_data text = utils.get_user()
+)
+returns table (
+    uuid text,
+    name text,
+    description text,
+    type text,
+    amount bigint,
+    account_uuid text,
+    category_uuid text,
+    frequency text,
+    every int,
+    day_of_month int,
+    start_date date,
+    end_date date,
+    next_date date
+) as $$
+begin
+    if not exists (
+        select 1 from data.ledgers l
+        where l.uuid = p_ledger_uuid and l.user_data = p_user_data
+    ) then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    return query
+    select
+        s.uuid,
+        s.name,
+        s.description,
+        s.type,
+        s.amount,
+        a.uuid as account_uuid,
+        c.uuid as category_uuid,
+        s.frequency,
+        s.every,
+        s.day_of_month,
+        s.start_date,
+        s.end_date,
+        s.next_date
+    from
+        data.schedules s
+        join data.ledgers l on l.id = s.ledger_id
+        join data.accounts a on a.id = s.account_id
+        left join data.accounts c on c.id = s.category_id
+    where
+        l.uuid = p_ledger_uuid
+        and s.user_data = p_user_data
+    order by
+        s.name;
+end;
+$$ language plpgsql stable security definer;
+
+-- the occurrences of the schedules of a ledger that are not recorded yet, up
+-- to and including p_through
+create or replace function utils.get_upcoming_transactions(
+    p_ledger_uuid text,
+    p_through date,
+    p_user_data text = utils.get_user()
+)
+returns table (
+    schedule_uuid text,
+    name text,
+    date date,
+    description text,
+    type text,
+    amount bigint,
+    account_uuid text,
+    category_uuid text
+) as $$
+begin
+    if not exists (
+        select 1 from data.ledgers l
+        where l.uuid = p_ledger_uuid and l.user_data = p_user_data
+    ) then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    return query
+    with recursive occurrences (schedule_id, occurs_on) as (
+        select s.id, s.next_date
+        from
+            data.schedules s
+            join data.ledgers l on l.id = s.ledger_id
+        where
+            l.uuid = p_ledger_uuid
+            and s.user_data = p_user_data
+            and s.next_date <= p_through
+            and (s.end_date is null or s.next_date <= s.end_date)
+
+        union all
+
+        select o.schedule_id, n.occurs_on
+        from
+            occurrences o
+            join data.schedules s on s.id = o.schedule_id
+            cross join lateral (
+                select utils.schedule_occurrence_after(
+                    s.frequency, s.every, s.day_of_month, s.start_date, o.occurs_on
+                ) as occurs_on
+            ) n
+        where
+            n.occurs_on <= p_through
+            and (s.end_date is null or n.occurs_on <= s.end_date)
+    )
+    select
+        s.uuid as schedule_uuid,
+        s.name,
+        o.occurs_on as date,
+        coalesce(s.description, s.name) as description,
+        s.type,
+        s.amount,
+        a.uuid as account_uuid,
+        c.uuid as category_uuid
+    from
+        occurrences o
+        join data.schedules s on s.id = o.schedule_id
+        join data.accounts a on a.id = s.account_id
+        left join data.accounts c on c.id = s.category_id
+    order by
+        o.occurs_on,
+        s.name;
+end;
+$$ language plpgsql stable security definer;
+
+-- record every occurrence due up to and including p_through as a pending
+-- transaction and advance the schedules. runs of the same user wait for each
+-- other on the schedule rows, so running again records nothing new
+create or replace function utils.run_schedules(
+    p_ledger_uuid text,
+    p_through date,
+    p_user_data text = utils.get_user()
+)
+returns table (
+    schedule_uuid text,
+    transaction_uuid text,
+    date date
+) as $$
+declare
+    v_schedule data.schedules;
+    v_ledger_uuid text;
+    v_account_uuid text;
+    v_category_uuid text;
+    v_next_date date;
+    v_transaction_id bigint;
+begin
+    for v_schedule in
+        select s.*
+        from
+            data.schedules s
+            join data.ledgers l on l.id = s.ledger_id
+        where
+            s.user_data = p_user_data
+            and (p_ledger_uuid is null or l.uuid = p_ledger_uuid)
+            and s.next_date <= p_through
+        order by
+            s.id
+        for update of s
+    loop
+        select l.uuid into v_ledger_uuid from data.ledgers l where l.id = v_schedule.ledger_id;
+        select a.uuid into v_account_uuid from data.accounts a where a.id = v_schedule.account_id;
+        select a.uuid into v_category_uuid from data.accounts a where a.id = v_schedule.category_id;
+
+        v_next_date := v_schedule.next_date;
+        while v_next_date <= p_through
+              and (v_schedule.end_date is null or v_next_date <= v_schedule.end_date)
+        loop
+            -- record through the regular path so all validation applies
+            v_transaction_id := utils.add_transaction(
+                v_ledger_uuid,
+                v_next_date::timestamptz,
+                coalesce(v_schedule.description, v_schedule.name),
+                v_schedule.type,
+                v_schedule.amount,
+                v_account_uuid,
+                v_category_uuid,
+                p_user_data
+            );
+
+            update data.transactions t
+               set status = 'pending',
+                   schedule_id = v_schedule.id
+             where t.id = v_transaction_id;
+
+            return query
+            select v_schedule.uuid, t.uuid, v_next_date
+            from data.transactions t
+            where t.id = v_transaction_id;
+
+            v_next_date := utils.schedule_occurrence_after(
+                v_schedule.frequency, v_schedule.every, v_schedule.day_of_month,
+                v_schedule.start_date, v_next_date
+            );
+        end loop;
+
+        -- a schedule past its end date has nothing left to record
+        if v_schedule.end_date is not null and v_next_date > v_schedule.end_date then
+            v_next_date := null;
+        end if;
+
+        update data.schedules s
+           set next_date = v_next_date,
+               updated_at = current_timestamp
+         where s.id = v_schedule.id;
+    end loop;
+end;
+$$ language plpgsql security definer;
+
+-- public api function to create a schedule, returns its uuid
+create or replace function api.create_schedule(
+    p_ledger_uuid text,
+    p_name text,
+    p_type text, -- 'inflow' or 'outflow'
+    p_amount bigint,
+    p_account_uuid text, -- the bank account or credit card
+    p_frequency text, -- 'monthly', 'weekly' or 'last_business_day'
+    p_start_date date,
+    p_category_uuid text default null, -- the category, optional
+    p_every int default 1, -- repeat every n months or weeks
+    p_day_of_month int default null, -- monthly only, defaults to the day of p_start_date
+    p_end_date date default null,
+    p_description text default null -- defaults to the name
+) returns text as $$
+declare
+    v_schedule_id bigint;
+    v_schedule_uuid text;
+begin
+    select utils.create_schedule(
+        p_ledger_uuid,
+        p_name,
+        p_type,
+        p_amount,
+        p_account_uuid,
+        p_frequency,
+        p_start_date,
+        p_category_uuid,
+        p_every,
+        p_day_of_month,
+        p_end_date,
+        p_description
+    ) into v_schedule_id;
+
+    select s.uuid into v_schedule_uuid
+    from data.schedules s
+    where s.id = v_schedule_id;
+
+    return v_schedule_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- public api function to delete a schedule
+create or replace function api.delete_schedule(
+    p_schedule_uuid text
+) returns void as $$
+begin
+    perform utils.delete_schedule(p_schedule_uuid);
+end;
+$$ language plpgsql security definer;
+
+-- public api function listing the schedules of a ledger
+create or replace function api.get_schedules(
+    p_ledger_uuid text
+) returns table (
+    uuid text,
+    name text,
+    description text,
+    type text,
+    amount bigint,
+    account_uuid text,
+    category_uuid text,
+    frequency text,
+    every int,
+    day_of_month int,
+    start_date date,
+    end_date date,
+    next_date date
+) as $$
+begin
+    return query
+    select * from utils.get_schedules(p_ledger_uuid);
+end;
+$$ language plpgsql stable security invoker;
+
+-- public api function listing the occurrences not recorded yet
+create or replace function api.get_upcoming_transactions(
+    p_ledger_uuid text,
+    p_through date default current_date + 30
+) returns table (
+    schedule_uuid text,
+    name text,
+    date date,
+    description text,
+    type text,
+    amount bigint,
+    account_uuid text,
+    category_uuid text
+) as $$
+begin
+    return query
+    select * from utils.get_upcoming_transactions(p_ledger_uuid, p_through);
+end;
+$$ language plpgsql stable security invoker;
+
+-- public api function recording the due occurrences as pending transactions.
+-- with a null ledger every ledger of the user is run
+create or replace function api.run_schedules(
+    p_ledger_uuid text default null,
+    p_through date default current_date
+) returns table (
+    schedule_uuid text,
+    transaction_uuid text,
+    date date
+) as $$
+begin
+    return query
+    select * from utils.run_schedules(p_ledger_uuid, p_through);
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop function if exists api.run_schedules(text, date);
+drop function if exists api.get_upcoming_transactions(text, date);
+drop function if exists api.get_schedules(text);
+drop function if exists api.delete_schedule(text);
+drop function if exists api.create_schedule(text, text, text, bigint, text, text, date, text, int, int, date, text);
+drop function if exists utils.run_schedules(text, date, text);
+drop function if exists utils.get_upcoming_transactions(text, date, text);
+drop function if exists utils.get_schedules(text, text);
+drop function if exists utils.delete_schedule(text, text);
+drop function if exists utils.create_schedule(text, text, text, bigint, text, text, date, text, int, int, date, text, text);
+drop function if exists utils.schedule_occurrence_after(text, int, int, date, date);
+
+drop index if exists data.idx_transactions_schedule_occurrence;
+alter table data.transactions drop column if exists schedule_id;
+
+drop policy if exists schedules_policy on data.schedules;
+drop table if exists data.schedules;
+
+-- +goose StatementEnd
@@ -0,0 +1,554 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- a transaction is 'pending' until the bank clears it and 'posted' after.
+-- balance snapshots keep counting every transaction, giving the working
+-- balance; the cleared balance leaves the pending ones out. pending
+-- transactions are few, so they are looked up through partial indexes
+create index idx_transactions_pending_debit on data.transactions (debit_account_id)
+    where status = 'pending';
+create index idx_transactions_pending_credit on data.transactions (credit_account_id)
+    where status = 'pending';
+
+-- reversals and corrections take the status of the transaction they replace,
+-- so correcting or deleting a pending transaction leaves the cleared balance
+-- alone
+create or replace function utils.transaction_log_status_fn() returns trigger as $$
+begin
+    update data.transactions t
+       set status = o.status
+      from data.transactions o
+     where o.id = new.original_transaction_id
+       and (
+           t.id in (new.reversal_transaction_id, new.correction_transaction_id)
+           or t.split_id = new.correction_split_id
+       );
+
+    return new;
+end;
+$$ language plpgsql security definer;
+
+create trigger transaction_log_status_tg
+    after insert or update of correction_transaction_id, correction_split_id on data.transaction_log
+    for each row
+    execute function utils.transaction_log_status_fn();
+
+-- the change pending transactions make to the balance of an account, using
+-- the same debit/credit rules as utils.create_balance_snapshots
+create or replace function utils.get_account_pending_balance(
+    p_account_id bigint
+) returns bigint as $$
+    select coalesce(
+        sum(
+            case
+                when (a.internal_type = 'asset_like') = (t.debit_account_id = a.id) then t.amount
+                else -t.amount
+            end
+        ), 0
+    )::bigint
+    from data.accounts a
+         join data.transactions t on a.id in (t.debit_account_id, t.credit_account_id)
+    where a.id = p_account_id
+      and t.status = 'pending'
+      and t.user_data = utils.get_user();
+$$ language sql stable security definer;
+
+-- the balance of an account counting posted transactions only
+create or replace function utils.get_account_cleared_balance(
+    p_account_uuid text
+) returns bigint as $$
+declare
+    v_account_id bigint;
+begin
+    select id into v_account_id
+    from data.accounts
+    where uuid = p_account_uuid and user_data = utils.get_user();
+
+    if v_account_id is null then
+        raise exception 'account not found or does not belong to the specified ledger: %', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return coalesce(utils.get_account_current_balance(v_account_id), 0)
+        - utils.get_account_pending_balance(v_account_id);
+end;
+$$ language plpgsql stable security definer;
+
+-- mark pending transactions as posted. a split transaction uuid posts all of
+-- its splits. transactions that are posted already are skipped; corrected or
+-- deleted ones are refused, since posting them alone would leave their
+-- reversal pending. returns the number of transactions posted
+create or replace function utils.post_transactions(
+    p_transaction_uuids text[],
+    p_user_data text = utils.get_user()
+) returns int as $$
+declare
+    v_uuid text;
+    v_ids bigint[] := '{}';
+    v_found bigint[];
+    v_posted int;
+begin
+    foreach v_uuid in array coalesce(p_transaction_uuids, '{}')
+    loop
+        select array_agg(t.id) into v_found
+        from data.transactions t
+             left join data.split_transactions s on s.id = t.split_id
+        where (t.uuid = v_uuid or s.uuid = v_uuid)
+          and t.user_data = p_user_data;
+
+        if v_found is null then
+            raise exception 'Transaction not found: %', v_uuid
+                using errcode = 'PB004';
+        end if;
+
+        if exists (
+            select 1 from data.transaction_log l
+            where l.original_transaction_id = any(v_found)
+               or l.reversal_transaction_id = any(v_found)
+        ) then
+            raise exception 'Transaction % was corrected or deleted and cannot be posted', v_uuid
+                using errcode = 'PB013';
+        end if;
+
+        v_ids := v_ids || v_found;
+    end loop;
+
+    update data.transactions t
+       set status = 'posted'
+     where t.id = any(v_ids)
+       and t.status = 'pending';
+
+    get diagnostics v_posted = row_count;
+    return v_posted;
+end;
+$$ language plpgsql security definer;
+
+-- public api function to post one transaction
+create or replace function api.post_transaction(
+    p_transaction_uuid text
+) returns void as $$
+begin
+    perform utils.post_transactions(array[p_transaction_uuid]);
+end;
+$$ language plpgsql security definer;
+
+-- public api function to post several transactions, all or none.
+-- returns the number of transactions that were pending
+create or replace function api.post_transactions(
+    p_transaction_uuids text[]
+) returns int as $$
+begin
+    return utils.post_transactions(p_transaction_uuids);
+end;
+$$ language plpgsql security definer;
+
+-- the working balance counts every transaction, the cleared balance only
+-- posted ones
+drop function if exists api.get_account_balance(text);
+
+create or replace function api.get_account_balance(
+    p_account_uuid text,
+    p_cleared boolean default false
+) returns bigint as $$
+begin
+    if p_cleared then
+        return utils.get_account_cleared_balance(p_account_uuid);
+    end if;
+    return utils.get_account_balance_from_snapshots(p_account_uuid);
+end;
+$$ language plpgsql security definer;
+
+-- the return type changes, so the functions are dropped and recreated
+drop function if exists api.get_ledger_balances(text);
+drop function if exists utils.get_ledger_current_balances(text);
+
+-- current_balance is the working balance
+create or replace function utils.get_ledger_current_balances(
+    p_ledger_uuid text
+) returns table(
+    account_uuid text,
+    account_name text,
+    account_type text,
+    current_balance bigint,
+    cleared_balance bigint
+) as $$
+declare
+    v_ledger_id bigint;
+begin
+    -- get ledger id
+    select id into v_ledger_id
+    from data.ledgers
+    where uuid = p_ledger_uuid and user_data = utils.get_user();
+
+    if v_ledger_id is null then
+        raise exception 'Ledger not found: %', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    -- return the working and cleared balance of each account in the ledger
+    return query
+    select
+        a.uuid::text,
+        a.name,
+        a.type,
+        b.balance,
+        b.balance - utils.get_account_pending_balance(a.id)
+    from data.accounts a
+         cross join lateral (
+             select coalesce(utils.get_account_current_balance(a.id), 0) as balance
+         ) b
+    where a.ledger_id = v_ledger_id
+      and a.user_data = utils.get_user()
+    order by a.type, a.name;
+end;
+$$ language plpgsql security definer;
+
+create or replace function api.get_ledger_balances(
+    p_ledger_uuid text
+) returns table(
+    account_uuid text,
+    account_name text,
+    account_type text,
+    current_balance bigint,
+    cleared_balance bigint
+) as $$
+begin
+    return query
+    select
+        u.account_uuid,
+        u.account_name,
+        u.account_type,
+        u.current_balance,
+        u.cleared_balance
+    from utils.get_ledger_current_balances(p_ledger_uuid) u;
+end;
+$$ language plpgsql security definer;
+
+-- the account history gains a status column and filter
+drop function if exists api.get_account_transactions(text);
+drop function if exists utils.get_account_transactions(text, text);
+
+-- account history with running balances. the legs of a split are shown as
+-- one row carrying the uuid of the split; on the bank side its category lists
+-- every category of the split and category_uuid is null. p_status keeps only
+-- 'pending' or 'posted' transactions
+create or replace function utils.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text
+) as $$
+declare
+    v_account_id bigint;
+    v_internal_type text;
+begin
+    if p_status is not null and p_status not in ('pending', 'posted') then
+        raise exception 'Invalid transaction status: %. Must be "pending" or "posted"', p_status
+            using errcode = 'PB013';
+    end if;
+
+    -- resolve the account uuid to its internal id and validate ownership
+    select a.id, a.internal_type
+    into v_account_id, v_internal_type
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return query
+    with legs as (
+        select
+            t.id,
+            t.date,
+            t.created_at,
+            t.description,
+            t.amount,
+            t.uuid,
+            t.split_id,
+            t.status,
+            o.name as other_name,
+            o.uuid as other_uuid,
+            -- determine transaction type based on account's internal type
+            case
+                when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                     (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+                then 'inflow'
+                else 'outflow'
+            end as type
+        from
+            data.transactions t
+            join data.accounts o on o.id = case
+                when t.debit_account_id = v_account_id then t.credit_account_id
+                else t.debit_account_id
+            end
+        where
+            (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+            and t.deleted_at is null
+            and (p_status is null or t.status = p_status)
+    )
+    select
+        min(l.date) as date,
+        string_agg(distinct l.other_name, ', ' order by l.other_name) as category,
+        -- a single leg keeps its memo, several legs show the split
+        case when count(*) = 1 then min(l.description) else s.description end as description,
+        min(l.type) as type,
+        sum(l.amount)::bigint as amount,
+        -- running balance from the balance snapshot of the last leg
+        coalesce(
+            (
+                select bs.balance
+                from data.balance_snapshots bs
+                where bs.transaction_id = max(l.id)
+                  and bs.account_id = v_account_id
+                  and bs.user_data = p_user_data
+            ), 0
+        ) as running_balance,
+        coalesce(s.uuid, min(l.uuid)) as uuid,
+        case when count(distinct l.other_uuid) = 1 then min(l.other_uuid) end as category_uuid,
+        s.id is not null as split,
+        -- a split is pending while any of its splits is
+        case when bool_or(l.status = 'pending') then 'pending' else 'posted' end as status
+    from
+        legs l
+        left join data.split_transactions s on s.id = l.split_id
+    group by
+        s.id,
+        case when s.id is null then l.id end
+    order by
+        min(l.date) desc,
+        max(l.created_at) desc,
+        max(l.id) desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- public api function, passes through to the utils function
+create or replace function api.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null -- 'pending' or 'posted'; all when null
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text
+) as $$
+begin
+    return query
+    select * from utils.get_account_transactions(p_account_uuid, p_status);
+end;
+$$ language plpgsql stable security invoker;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- restore the account history without the status column
+drop function if exists api.get_account_transactions(text, text);
+drop function if exists utils.get_account_transactions(text, text, text);
+
+create or replace function utils.get_account_transactions(
+    p_account_uuid text,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean
+) as $$
+declare
+    v_account_id bigint;
+    v_internal_type text;
+begin
+    select a.id, a.internal_type
+    into v_account_id, v_internal_type
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return query
+    with legs as (
+        select
+            t.id,
+            t.date,
+            t.created_at,
+            t.description,
+            t.amount,
+            t.uuid,
+            t.split_id,
+            o.name as other_name,
+            o.uuid as other_uuid,
+            case
+                when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                     (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+                then 'inflow'
+                else 'outflow'
+            end as type
+        from
+            data.transactions t
+            join data.accounts o on o.id = case
+                when t.debit_account_id = v_account_id then t.credit_account_id
+                else t.debit_account_id
+            end
+        where
+            (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+            and t.deleted_at is null
+    )
+    select
+        min(l.date) as date,
+        string_agg(distinct l.other_name, ', ' order by l.other_name) as category,
+        case when count(*) = 1 then min(l.description) else s.description end as description,
+        min(l.type) as type,
+        sum(l.amount)::bigint as amount,
+        coalesce(
+            (
+                select bs.balance
+                from data.balance_snapshots bs
+                where bs.transaction_id = max(l.id)
+                  and bs.account_id = v_account_id
+                  and bs.user_data = p_user_data
+            ), 0
+        ) as running_balance,
+        coalesce(s.uuid, min(l.uuid)) as uuid,
+        case when count(distinct l.other_uuid) = 1 then min(l.other_uuid) end as category_uuid,
+        s.id is not null as split
+    from
+        legs l
+        left join data.split_transactions s on s.id = l.split_id
+    group by
+        s.id,
+        case when s.id is null then l.id end
+    order by
+        min(l.date) desc,
+        max(l.created_at) desc,
+        max(l.id) desc;
+end;
+$$ language plpgsql stable security definer;
+
+create or replace function api.get_account_transactions(
+    p_account_uuid text
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean
+) as $$
+begin
+    return query
+    select * from utils.get_account_transactions(p_account_uuid);
+end;
+$$ language plpgsql stable security invoker;
+
+-- restore the ledger balances without the cleared balance
+drop function if exists api.get_ledger_balances(text);
+drop function if exists utils.get_ledger_current_balances(text);
+
+create or replace function utils.get_ledger_current_balances(
+    p_ledger_uuid text
+) returns table(
+    account_uuid text,
+    account_name text,
+    account_type text,
+    current_balance bigint
+) as $$
+declare
+    v_ledger_id bigint;
+begin
+    select id into v_ledger_id
+    from data.ledgers
+    where uuid = p_ledger_uuid and user_data = utils.get_user();
+
+    if v_ledger_id is null then
+        raise exception 'Ledger not found: %', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    return query
+    select
+        a.uuid::text,
+        a.name,
+        a.type,
+        coalesce(utils.get_account_current_balance(a.id), 0)
+    from data.accounts a
+    where a.ledger_id = v_ledger_id
+      and a.user_data = utils.get_user()
+    order by a.type, a.name;
+end;
+$$ language plpgsql security definer;
+
+create or replace function api.get_ledger_balances(
+    p_ledger_uuid text
+) returns table(
+    account_uuid text,
+    account_name text,
+    account_type text,
+    current_balance bigint
+) as $$
+begin
+    return query
+    select
+        u.account_uuid,
+        u.account_name,
+        u.account_type,
+        u.current_balance
+    from utils.get_ledger_current_balances(p_ledger_uuid) u;
+end;
+$$ language plpgsql security definer;
+
+drop function if exists api.get_account_balance(text, boolean);
+
+create or replace function api.get_account_balance(
+    p_account_uuid text
+) returns bigint as $$
+begin
+    return utils.get_account_balance_from_snapshots(p_account_uuid);
+end;
+$$ language plpgsql security definer;
+
+drop function if exists api.post_transactions(text[]);
+drop function if exists api.post_transaction(text);
+drop function if exists utils.post_transactions(text[], text);
+drop function if exists utils.get_account_cleared_balance(text);
+drop function if exists utils.get_account_pending_balance(bigint);
+
+drop trigger if exists transaction_log_status_tg on data.transaction_log;
+drop function if exists utils.transaction_log_status_fn();
+
+drop index if exists data.idx_transactions_pending_credit;
+drop index if exists data.idx_transactions_pending_debit;
+
+-- +goose StatementEnd
@@ -0,0 +1,934 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- a reconciliation checks the cleared balance of a bank account or credit
+-- card against the ending balance of a statement. a difference is booked as
+-- an adjustment transaction, and the posted transactions up to the statement
+-- date are locked: api.correct_transaction and api.delete_transaction refuse
+-- to change them unless overridden
+create table data.reconciliations
+(
+    id                        bigint generated always as identity primary key,
+    uuid                      text        not null default utils.nanoid(8),
+    created_at                timestamptz not null default current_timestamp,
+
+    statement_date            date        not null,
+    statement_balance         bigint      not null,
+    cleared_balance           bigint      not null, -- before the adjustment
+
+    -- null when the cleared balance matched the statement
+    adjustment_transaction_id bigint references data.transactions (id) on delete set null,
+    account_id                bigint      not null references data.accounts (id),
+    ledger_id                 bigint      not null references data.ledgers (id) on delete cascade,
+    user_data                 text        not null default utils.get_user(),
+
+    constraint reconciliations_uuid_unique unique (uuid),
+    constraint reconciliations_user_data_length_check check (char_length(user_data) < 255)
+);
+
+-- enable RLS
+alter table data.reconciliations
+    enable row level security;
+
+create policy reconciliations_policy on data.reconciliations
+    using (user_data = utils.get_user())
+    with check (user_data = utils.get_user());
+
+create index idx_reconciliations_account_id on data.reconciliations (account_id, statement_date);
+
+-- reconciled transactions point to the reconciliation that locked them
+alter table data.transactions
+    add column reconciliation_id bigint references data.reconciliations (id) on delete set null;
+
+create index idx_transactions_reconciliation_id on data.transactions (reconciliation_id)
+    where reconciliation_id is not null;
+
+-- the cleared balance of an account as a statement ending on p_date shows it:
+-- the current balance without pending transactions and without the ones
+-- dated after the statement
+create or replace function utils.get_account_cleared_balance_at(
+    p_account_id bigint,
+    p_date date
+) returns bigint as $$
+    select coalesce(utils.get_account_current_balance(p_account_id), 0) - coalesce(
+        sum(
+            case
+                when (a.internal_type = 'asset_like') = (t.debit_account_id = a.id) then t.amount
+                else -t.amount
+            end
+        ), 0
+    )::bigint
+    from data.accounts a
+         join data.transactions t on a.id in (t.debit_account_id, t.credit_account_id)
+    where a.id = p_account_id
+      and (t.status = 'pending' or t.date > p_date)
+      and t.user_data = utils.get_user();
+$$ language sql stable security definer;
+
+-- find a bank account or credit card that can be reconciled against a
+-- statement ending on p_statement_date
+create or replace function utils.get_reconcilable_account(
+    p_account_uuid text,
+    p_statement_date date,
+    p_user_data text = utils.get_user()
+) returns data.accounts as $$
+declare
+    v_account data.accounts;
+    v_last_date date;
+begin
+    select * into v_account
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account.id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    if v_account.type not in ('asset', 'liability') then
+        raise exception 'Only bank accounts and credit cards can be reconciled. % is of type %',
+            v_account.name, v_account.type
+            using errcode = 'PB013';
+    end if;
+
+    if p_statement_date is null then
+        raise exception 'Statement date is required'
+            using errcode = 'PB011';
+    end if;
+
+    select max(r.statement_date) into v_last_date
+    from data.reconciliations r
+    where r.account_id = v_account.id;
+
+    if p_statement_date < v_last_date then
+        raise exception 'Statement date % is before the last reconciliation of % on %',
+            p_statement_date, v_account.name, v_last_date
+            using errcode = 'PB011';
+    end if;
+
+    return v_account;
+end;
+$$ language plpgsql stable security definer;
+
+-- what reconciling an account would find, without changing anything:
+-- the cleared balance, its difference to the statement and the number of
+-- transactions that would be locked
+create or replace function utils.get_reconciliation_preview(
+    p_account_uuid text,
+    p_statement_balance bigint,
+    p_statement_date date default current_date,
+    p_user_data text = utils.get_user()
+)
+returns table (
+    statement_date date,
+    statement_balance bigint,
+    cleared_balance bigint,
+    difference bigint,
+    transactions int
+) as $$
+declare
+    v_account data.accounts;
+    v_cleared bigint;
+begin
+    v_account := utils.get_reconcilable_account(p_account_uuid, p_statement_date, p_user_data);
+    v_cleared := utils.get_account_cleared_balance_at(v_account.id, p_statement_date);
+
+    return query
+    select
+        p_statement_date,
+        p_statement_balance,
+        v_cleared,
+        p_statement_balance - v_cleared,
+        (
+            select count(*)::int
+            from data.transactions t
+            where v_account.id in (t.debit_account_id, t.credit_account_id)
+              and t.status = 'posted'
+              and t.reconciliation_id is null
+              and t.date <= p_statement_date
+        );
+end;
+$$ language plpgsql stable security definer;
+
+-- reconcile an account against a statement. a difference between the
+-- statement and the cleared balance is recorded as a posted adjustment
+-- against p_category_uuid (Income by default), then every posted transaction
+-- up to the statement date is locked. returns the id of the reconciliation
+create or replace function utils.reconcile_account(
+    p_account_uuid text,
+    p_statement_balance bigint,
+    p_statement_date date default current_date,
+    p_category_uuid text default null,
+    p_user_data text = utils.get_user()
+) returns bigint as $$
+declare
+    v_account data.accounts;
+    v_ledger_uuid text;
+    v_cleared bigint;
+    v_difference bigint;
+    v_adjustment_id bigint;
+    v_reconciliation_id bigint;
+begin
+    -- reconciliations of one account wait for each other
+    perform 1
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data
+    for update;
+
+    v_account := utils.get_reconcilable_account(p_account_uuid, p_statement_date, p_user_data);
+    v_cleared := utils.get_account_cleared_balance_at(v_account.id, p_statement_date);
+    v_difference := p_statement_balance - v_cleared;
+
+    if v_difference <> 0 then
+        select l.uuid into v_ledger_uuid
+        from data.ledgers l
+        where l.id = v_account.ledger_id;
+
+        -- a positive difference raises the balance: an inflow on a bank
+        -- account, a charge on a credit card
+        v_adjustment_id := utils.add_transaction(
+            v_ledger_uuid,
+            p_statement_date,
+            'Reconciliation adjustment',
+            case when (v_difference > 0) = (v_account.internal_type = 'asset_like') then 'inflow' else 'outflow' end,
+            abs(v_difference),
+            p_account_uuid,
+            coalesce(p_category_uuid, utils.find_category(v_ledger_uuid, 'Income', p_user_data)),
+            p_user_data
+        );
+    end if;
+
+    insert into data.reconciliations (
+        statement_date, statement_balance, cleared_balance, adjustment_transaction_id,
+        account_id, ledger_id, user_data
+    )
+    values (
+        p_statement_date, p_statement_balance, v_cleared, v_adjustment_id,
+        v_account.id, v_account.ledger_id, p_user_data
+    )
+    returning id into v_reconciliation_id;
+
+    -- lock the posted transactions of the statement, the adjustment included
+    update data.transactions t
+       set reconciliation_id = v_reconciliation_id
+     where v_account.id in (t.debit_account_id, t.credit_account_id)
+       and t.status = 'posted'
+       and t.reconciliation_id is null
+       and t.date <= p_statement_date
+       and t.user_data = p_user_data;
+
+    return v_reconciliation_id;
+end;
+$$ language plpgsql security definer;
+
+-- the reconciliations of an account, latest first
+create or replace function utils.get_reconciliations(
+    p_account_uuid text,
+    p_user_data text = utils.get_user()
+)
+returns table (
+    uuid text,
+    statement_date date,
+    statement_balance bigint,
+    cleared_balance bigint,
+    adjustment_uuid text,
+    transactions int,
+    created_at timestamptz
+) as $$
+declare
+    v_account_id bigint;
+begin
+    select a.id into v_account_id
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return query
+    select
+        r.uuid,
+        r.statement_date,
+        r.statement_balance,
+        r.cleared_balance,
+        adj.uuid as adjustment_uuid,
+        (select count(*)::int from data.transactions t where t.reconciliation_id = r.id) as transactions,
+        r.created_at
+    from
+        data.reconciliations r
+        left join data.transactions adj on adj.id = r.adjustment_transaction_id
+    where r.account_id = v_account_id
+      and r.user_data = p_user_data
+    order by r.statement_date desc, r.id desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- refuse to change a reconciled transaction. p_transaction_uuid names a
+-- transaction or a split transaction, which is reconciled when any of its
+-- splits is
+create or replace function utils.assert_not_reconciled(
+    p_transaction_uuid text
+) returns void as $$
+declare
+    v_statement_date date;
+begin
+    select r.statement_date into v_statement_date
+    from data.transactions t
+         join data.reconciliations r on r.id = t.reconciliation_id
+    where t.user_data = utils.get_user()
+      and (
+          t.uuid = p_transaction_uuid
+          or t.split_id in (
+              select s.id from data.split_transactions s where s.uuid = p_transaction_uuid
+              union
+              select l.split_id from data.transactions l where l.uuid = p_transaction_uuid
+          )
+      )
+    limit 1;
+
+    if v_statement_date is not null then
+        raise exception 'Transaction % is reconciled with the statement of %. Pass p_override => true to change it anyway.',
+            p_transaction_uuid, v_statement_date
+            using errcode = 'PB021';
+    end if;
+end;
+$$ language plpgsql stable security definer;
+
+-- public api function to compare an account with a statement
+create or replace function api.get_reconciliation_preview(
+    p_account_uuid text,
+    p_statement_balance bigint,
+    p_statement_date date default current_date
+) returns table (
+    statement_date date,
+    statement_balance bigint,
+    cleared_balance bigint,
+    difference bigint,
+    transactions int
+) as $$
+begin
+    return query
+    select * from utils.get_reconciliation_preview(p_account_uuid, p_statement_balance, p_statement_date);
+end;
+$$ language plpgsql stable security invoker;
+
+-- public api function to reconcile an account, returns the reconciliation uuid
+create or replace function api.reconcile_account(
+    p_account_uuid text,
+    p_statement_balance bigint,
+    p_statement_date date default current_date,
+    p_category_uuid text default null -- adjustment category, Income when null
+) returns text as $$
+declare
+    v_reconciliation_id bigint;
+    v_reconciliation_uuid text;
+begin
+    v_reconciliation_id := utils.reconcile_account(
+        p_account_uuid, p_statement_balance, p_statement_date, p_category_uuid
+    );
+
+    select r.uuid into v_reconciliation_uuid
+    from data.reconciliations r
+    where r.id = v_reconciliation_id;
+
+    return v_reconciliation_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- public api function listing the reconciliations of an account
+create or replace function api.get_reconciliations(
+    p_account_uuid text
+) returns table (
+    uuid text,
+    statement_date date,
+    statement_balance bigint,
+    cleared_balance bigint,
+    adjustment_uuid text,
+    transactions int,
+    created_at timestamptz
+) as $$
+begin
+    return query
+    select * from utils.get_reconciliations(p_account_uuid);
+end;
+$$ language plpgsql stable security invoker;
+
+-- correcting and deleting refuse reconciled transactions unless p_override
+-- is set; the signatures change, so the wrappers are dropped and recreated
+drop function if exists api.correct_transaction(text, text, text, text, bigint, text, date, text);
+drop function if exists api.delete_transaction(text, text);
+drop function if exists api.correct_split_transaction(text, text, text, date, text, jsonb, text);
+
+-- a leg only changes together with its split: correcting it alone would
+-- take it out of the split
+create or replace function api.correct_transaction(
+    p_original_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_category_uuid text,
+    p_new_amount bigint,
+    p_new_description text,
+    p_new_date date,
+    p_reason text default 'Transaction correction',
+    p_override boolean default false -- change reconciled transactions too
+) returns text as $$
+declare
+    v_split_uuid text;
+    v_correction_id int;
+    v_correction_uuid text;
+begin
+    -- reconciled transactions are locked
+    if not p_override then
+        perform utils.assert_not_reconciled(p_original_uuid);
+    end if;
+
+    -- find the split the uuid names, or the split of the leg it names
+    select s.uuid into v_split_uuid
+    from data.split_transactions s
+    where s.user_data = utils.get_user()
+      and (
+          s.uuid = p_original_uuid
+          or s.id = (select t.split_id from data.transactions t where t.uuid = p_original_uuid)
+      );
+
+    if v_split_uuid is not null then
+        raise exception 'Transaction % is part of split transaction %. Use api.correct_split_transaction to change its splits.',
+            p_original_uuid, v_split_uuid
+            using errcode = 'PB013';
+    end if;
+
+    -- call utils function to do all the work
+    select utils.correct_transaction(
+        p_original_uuid,
+        p_new_type,
+        p_new_account_uuid,
+        p_new_category_uuid,
+        p_new_amount,
+        p_new_description,
+        p_new_date,
+        p_reason
+    ) into v_correction_id;
+
+    -- get the uuid of the corrected transaction
+    select uuid into v_correction_uuid
+    from data.transactions
+    where id = v_correction_id;
+
+    return v_correction_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- deleting a split, or one of its legs, deletes all of its legs
+create or replace function api.delete_transaction(
+    p_original_uuid text,
+    p_reason text default 'Transaction deleted',
+    p_override boolean default false -- change reconciled transactions too
+) returns text as $$
+declare
+    v_split_uuid text;
+    v_reversal_id bigint;
+    v_reversal_uuid text;
+begin
+    -- reconciled transactions are locked
+    if not p_override then
+        perform utils.assert_not_reconciled(p_original_uuid);
+    end if;
+
+    -- find the split the uuid names, or the split of the leg it names
+    select s.uuid into v_split_uuid
+    from data.split_transactions s
+    where s.user_data = utils.get_user()
+      and (
+          s.uuid = p_original_uuid
+          or s.id = (select t.split_id from data.transactions t where t.uuid = p_original_uuid)
+      );
+
+    if v_split_uuid is not null then
+        select utils.delete_split_transaction(v_split_uuid, p_reason) into v_reversal_id;
+
+        select uuid into v_reversal_uuid
+        from data.split_transactions
+        where id = v_reversal_id;
+
+        return v_reversal_uuid;
+    end if;
+
+    -- call utils function to do all the work
+    select utils.delete_transaction(
+        p_original_uuid,
+        p_reason
+    ) into v_reversal_id;
+
+    -- get the uuid of the reversal transaction
+    select uuid into v_reversal_uuid
+    from data.transactions
+    where id = v_reversal_id;
+
+    return v_reversal_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- public api function to correct a split transaction
+create or replace function api.correct_split_transaction(
+    p_split_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_date date,
+    p_new_description text,
+    p_new_splits jsonb,
+    p_reason text default 'Transaction correction',
+    p_override boolean default false -- change reconciled transactions too
+) returns text as $$
+declare
+    v_split_id bigint;
+    v_split_uuid text;
+begin
+    -- reconciled transactions are locked
+    if not p_override then
+        perform utils.assert_not_reconciled(p_split_uuid);
+    end if;
+
+    select utils.correct_split_transaction(
+        p_split_uuid,
+        p_new_type,
+        p_new_account_uuid,
+        p_new_date,
+        p_new_description,
+        p_new_splits,
+        p_reason
+    ) into v_split_id;
+
+    select uuid into v_split_uuid
+    from data.split_transactions
+    where id = v_split_id;
+
+    return v_split_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- the account history gains a reconciled column
+drop function if exists api.get_account_transactions(text, text);
+drop function if exists utils.get_account_transactions(text, text, text);
+
+-- account history with running balances. the legs of a split are shown as
+-- one row carrying the uuid of the split; on the bank side its category lists
+-- every category of the split and category_uuid is null. p_status keeps only
+-- 'pending' or 'posted' transactions; reconciled marks the transactions locked
+-- by a reconciliation
+create or replace function utils.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean
+) as $$
+declare
+    v_account_id bigint;
+    v_internal_type text;
+begin
+    if p_status is not null and p_status not in ('pending', 'posted') then
+        raise exception 'Invalid transaction status: %. Must be "pending" or "posted"', p_status
+            using errcode = 'PB013';
+    end if;
+
+    -- resolve the account uuid to its internal id and validate ownership
+    select a.id, a.internal_type
+    into v_account_id, v_internal_type
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return query
+    with legs as (
+        select
+            t.id,
+            t.date,
+            t.created_at,
+            t.description,
+            t.amount,
+            t.uuid,
+            t.split_id,
+            t.status,
+            t.reconciliation_id,
+            o.name as other_name,
+            o.uuid as other_uuid,
+            -- determine transaction type based on account's internal type
+            case
+                when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                     (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+                then 'inflow'
+                else 'outflow'
+            end as type
+        from
+            data.transactions t
+            join data.accounts o on o.id = case
+                when t.debit_account_id = v_account_id then t.credit_account_id
+                else t.debit_account_id
+            end
+        where
+            (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+            and t.deleted_at is null
+            and (p_status is null or t.status = p_status)
+    )
+    select
+        min(l.date) as date,
+        string_agg(distinct l.other_name, ', ' order by l.other_name) as category,
+        -- a single leg keeps its memo, several legs show the split
+        case when count(*) = 1 then min(l.description) else s.description end as description,
+        min(l.type) as type,
+        sum(l.amount)::bigint as amount,
+        -- running balance from the balance snapshot of the last leg
+        coalesce(
+            (
+                select bs.balance
+                from data.balance_snapshots bs
+                where bs.transaction_id = max(l.id)
+                  and bs.account_id = v_account_id
+                  and bs.user_data = p_user_data
+            ), 0
+        ) as running_balance,
+        coalesce(s.uuid, min(l.uuid)) as uuid,
+        case when count(distinct l.other_uuid) = 1 then min(l.other_uuid) end as category_uuid,
+        s.id is not null as split,
+        -- a split is pending while any of its splits is
+        case when bool_or(l.status = 'pending') then 'pending' else 'posted' end as status,
+        bool_or(l.reconciliation_id is not null) as reconciled
+    from
+        legs l
+        left join data.split_transactions s on s.id = l.split_id
+    group by
+        s.id,
+        case when s.id is null then l.id end
+    order by
+        min(l.date) desc,
+        max(l.created_at) desc,
+        max(l.id) desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- public api function, passes through to the utils function
+create or replace function api.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null -- 'pending' or 'posted'; all when null
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean
+) as $$
+begin
+    return query
+    select * from utils.get_account_transactions(p_account_uuid, p_status);
+end;
+$$ language plpgsql stable security invoker;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- restore the account history without the reconciled column
+drop function if exists api.get_account_transactions(text, text);
+drop function if exists utils.get_account_transactions(text, text, text);
+
+-- account history with running balances. the legs of a split are shown as
+-- one row carrying the uuid of the split; on the bank side its category lists
+-- every category of the split and category_uuid is null. p_status keeps only
+-- 'pending' or 'posted' transactions
+create or replace function utils.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text
+) as $$
+declare
+    v_account_id bigint;
+    v_internal_type text;
+begin
+    if p_status is not null and p_status not in ('pending', 'posted') then
+        raise exception 'Invalid transaction status: %. Must be "pending" or "posted"', p_status
+            using errcode = 'PB013';
+    end if;
+
+    -- resolve the account uuid to its internal id and validate ownership
+    select a.id, a.internal_type
+    into v_account_id, v_internal_type
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return query
+    with legs as (
+        select
+            t.id,
+            t.date,
+            t.created_at,
+            t.description,
+            t.amount,
+            t.uuid,
+            t.split_id,
+            t.status,
+            o.name as other_name,
+            o.uuid as other_uuid,
+            -- determine transaction type based on account's internal type
+            case
+                when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                     (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+                then 'inflow'
+                else 'outflow'
+            end as type
+        from
+            data.transactions t
+            join data.accounts o on o.id = case
+                when t.debit_account_id = v_account_id then t.credit_account_id
+                else t.debit_account_id
+            end
+        where
+            (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+            and t.deleted_at is null
+            and (p_status is null or t.status = p_status)
+    )
+    select
+        min(l.date) as date,
+        string_agg(distinct l.other_name, ', ' order by l.other_name) as category,
+        -- a single leg keeps its memo, several legs show the split
+        case when count(*) = 1 then min(l.description) else s.description end as description,
+        min(l.type) as type,
+        sum(l.amount)::bigint as amount,
+        -- running balance from the balance snapshot of the last leg
+        coalesce(
+            (
+                select bs.balance
+                from data.balance_snapshots bs
+                where bs.transaction_id = max(l.id)
+                  and bs.account_id = v_account_id
+                  and bs.user_data = p_user_data
+            ), 0
+        ) as running_balance,
+        coalesce(s.uuid, min(l.uuid)) as uuid,
+        case when count(distinct l.other_uuid) = 1 then min(l.other_uuid) end as category_uuid,
+        s.id is not null as split,
+        -- a split is pending while any of its splits is
+        case when bool_or(l.status = 'pending') then 'pending' else 'posted' end as status
+    from
+        legs l
+        left join data.split_transactions s on s.id = l.split_id
+    group by
+        s.id,
+        case when s.id is null then l.id end
+    order by
+        min(l.date) desc,
+        max(l.created_at) desc,
+        max(l.id) desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- public api function, passes through to the utils function
+create or replace function api.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null -- 'pending' or 'posted'; all when null
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text
+) as $$
+begin
+    return query
+    select * from utils.get_account_transactions(p_account_uuid, p_status);
+end;
+$$ language plpgsql stable security invoker;
+
+-- restore the wrappers without the override
+drop function if exists api.correct_transaction(text, text, text, text, bigint, text, date, text, boolean);
+drop function if exists api.delete_transaction(text, text, boolean);
+drop function if exists api.correct_split_transaction(text, text, text, date, text, jsonb, text, boolean);
+
+-- a leg only changes together with its split: correcting it alone would
+-- take it out of the split
+create or replace function api.correct_transaction(
+    p_original_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_category_uuid text,
+    p_new_amount bigint,
+    p_new_description text,
+    p_new_date date,
+    p_reason text default 'Transaction correction'
+) returns text as $$
+declare
+    v_split_uuid text;
+    v_correction_id int;
+    v_correction_uuid text;
+begin
+    -- find the split the uuid names, or the split of the leg it names
+    select s.uuid into v_split_uuid
+    from data.split_transactions s
+    where s.user_data = utils.get_user()
+      and (
+          s.uuid = p_original_uuid
+          or s.id = (select t.split_id from data.transactions t where t.uuid = p_original_uuid)
+      );
+
+    if v_split_uuid is not null then
+        raise exception 'Transaction % is part of split transaction %. Use api.correct_split_transaction to change its splits.',
+            p_original_uuid, v_split_uuid
+            using errcode = 'PB013';
+    end if;
+
+    -- call utils function to do all the work
+    select utils.correct_transaction(
+        p_original_uuid,
+        p_new_type,
+        p_new_account_uuid,
+        p_new_category_uuid,
+        p_new_amount,
+        p_new_description,
+        p_new_date,
+        p_reason
+    ) into v_correction_id;
+
+    -- get the uuid of the corrected transaction
+    select uuid into v_correction_uuid
+    from data.transactions
+    where id = v_correction_id;
+
+    return v_correction_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- deleting a split, or one of its legs, deletes all of its legs
+create or replace function api.delete_transaction(
+    p_original_uuid text,
+    p_reason text default 'Transaction deleted'
+) returns text as $$
+declare
+    v_split_uuid text;
+    v_reversal_id bigint;
+    v_reversal_uuid text;
+begin
+    -- find the split the uuid names, or the split of the leg it names
+    select s.uuid into v_split_uuid
+    from data.split_transactions s
+    where s.user_data = utils.get_user()
+      and (
+          s.uuid = p_original_uuid
+          or s.id = (select t.split_id from data.transactions t where t.uuid = p_original_uuid)
+      );
+
+    if v_split_uuid is not null then
+        select utils.delete_split_transaction(v_split_uuid, p_reason) into v_reversal_id;
+
+        select uuid into v_reversal_uuid
+        from data.split_transactions
+        where id = v_reversal_id;
+
+        return v_reversal_uuid;
+    end if;
+
+    -- call utils function to do all the work
+    select utils.delete_transaction(
+        p_original_uuid,
+        p_reason
+    ) into v_reversal_id;
+
+    -- get the uuid of the reversal transaction
+    select uuid into v_reversal_uuid
+    from data.transactions
+    where id = v_reversal_id;
+
+    return v_reversal_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- public api function to correct a split transaction
+create or replace function api.correct_split_transaction(
+    p_split_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_date date,
+    p_new_description text,
+    p_new_splits jsonb,
+    p_reason text default 'Transaction correction'
+) returns text as $$
+declare
+    v_split_id bigint;
+    v_split_uuid text;
+begin
+    select utils.correct_split_transaction(
+        p_split_uuid,
+        p_new_type,
+        p_new_account_uuid,
+        p_new_date,
+        p_new_description,
+        p_new_splits,
+        p_reason
+    ) into v_split_id;
+
+    select uuid into v_split_uuid
+    from data.split_transactions
+    where id = v_split_id;
+
+    return v_split_uuid;
+end;
+$$ language plpgsql security definer;
+
+drop function if exists api.get_reconciliations(text);
+drop function if exists api.reconcile_account(text, bigint, date, text);
+drop function if exists api.get_reconciliation_preview(text, bigint, date);
+drop function if exists utils.assert_not_reconciled(text);
+drop function if exists utils.get_reconciliations(text, text);
+drop function if exists utils.reconcile_account(text, bigint, date, text, text);
+drop function if exists utils.get_reconciliation_preview(text, bigint, date, text);
+drop function if exists utils.get_reconcilable_account(text, date, text);
+drop function if exists utils.get_account_cleared_balance_at(bigint, date);
+
+alter table data.transactions drop column if exists reconciliation_id;
+drop table if exists data.reconciliations;
+
+-- +goose StatementEnd
@@ -0,0 +1,289 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- a goal tells what a budget category should have. a category has at most
+-- one goal:
+--   monthly_funding  assign amount every month
+--   target_balance   reach a balance of amount by target_date, spreading what
+--                    is missing evenly over the months left
+--   spending_cap     spend at most amount a month
+create table data.category_goals
+(
+    id          bigint generated always as identity primary key,
+    created_at  timestamptz not null default current_timestamp,
+    updated_at  timestamptz not null default current_timestamp,
+
+    type        text        not null,
+    amount      bigint      not null,
+    target_date date,
+
+    category_id bigint      not null references data.accounts (id) on delete cascade,
+    ledger_id   bigint      not null references data.ledgers (id) on delete cascade,
+    user_data   text        not null default utils.get_user(),
+
+    constraint category_goals_category_unique unique (category_id),
+    constraint category_goals_type_check check (type in ('monthly_funding', 'target_balance', 'spending_cap')),
+    constraint category_goals_amount_positive check (amount > 0),
+    constraint category_goals_target_date_check check ((type = 'target_balance') = (target_date is not null)),
+    constraint category_goals_user_data_length_check check (char_length(user_data) < 255)
+);
+
+-- enable RLS
+alter table data.category_goals
+    enable row level security;
+
+create policy category_goals_policy on data.category_goals
+    using (user_data = utils.get_user())
+    with check (user_data = utils.get_user());
+
+create index idx_category_goals_ledger_id on data.category_goals (ledger_id);
+
+-- set the goal of a category, replacing the one it had
+create or replace function utils.set_category_goal(
+    p_category_uuid text,
+    p_type text,
+    p_amount bigint,
+    p_target_date date = null,
+    p_user_data text = utils.get_user()
+) returns void as
+$$
+declare
+    v_category data.accounts;
+begin
+    if p_type is null or p_type not in ('monthly_funding', 'target_balance', 'spending_cap') then
+        raise exception 'Invalid goal type: %. Must be "monthly_funding", "target_balance" or "spending_cap"', p_type
+            using errcode = 'PB013';
+    end if;
+
+    if p_amount is null or p_amount <= 0 then
+        raise exception 'Goal amount must be positive. Received: %', p_amount
+            using errcode = 'PB010';
+    end if;
+
+    if p_type = 'target_balance' and p_target_date is null then
+        raise exception 'A target balance goal needs a target date'
+            using errcode = 'PB011';
+    elsif p_type <> 'target_balance' and p_target_date is not null then
+        raise exception 'Only target balance goals have a target date'
+            using errcode = 'PB011';
+    end if;
+
+    select a.* into v_category
+      from data.accounts a
+     where a.uuid = p_category_uuid
+       and a.user_data = p_user_data
+       and a.type = 'equity';
+
+    if v_category.id is null then
+        raise exception 'Category with UUID % not found for current user', p_category_uuid
+            using errcode = 'PB003';
+    end if;
+
+    if v_category.name in ('Income', 'Off-budget', 'Unassigned') then
+        raise exception 'The % account cannot have a goal', v_category.name
+            using errcode = 'PB020';
+    end if;
+
+    insert into data.category_goals (type, amount, target_date, category_id, ledger_id, user_data)
+    values (p_type, p_amount, p_target_date, v_category.id, v_category.ledger_id, p_user_data)
+    on conflict (category_id) do update
+        set type        = excluded.type,
+            amount      = excluded.amount,
+            target_date = excluded.target_date,
+            updated_at  = current_timestamp;
+end;
+$$ language plpgsql security definer;
+
+-- remove the goal of a category
+create or replace function utils.delete_category_goal(
+    p_category_uuid text,
+    p_user_data text = utils.get_user()
+) returns void as
+$$
+begin
+    delete from data.category_goals g
+     using data.accounts a
+     where a.id = g.category_id
+       and a.uuid = p_category_uuid
+       and g.user_data = p_user_data;
+
+    if not found then
+        raise exception 'Goal not found for category %', p_category_uuid
+            using errcode = 'PB006';
+    end if;
+end;
+$$ language plpgsql security definer;
+
+-- integer division rounding up, for non-negative numbers
+create or replace function utils.ceil_div(
+    p_dividend bigint,
+    p_divisor bigint
+) returns bigint as $$
+    select (p_dividend + p_divisor - 1) / p_divisor;
+$$ language sql immutable;
+
+-- the progress of every goal of a ledger in a month. needed is what the goal
+-- asks to budget in the month and underfunded what is still missing of it;
+-- over_cap is how much a spending cap is exceeded. spending caps need no
+-- funding
+create or replace function utils.get_goal_progress(
+    p_ledger_uuid text,
+    p_period text = to_char(current_date, 'YYYYMM'),
+    p_user_data text = utils.get_user()
+)
+returns table (
+    category_uuid text,
+    category_name text,
+    goal_type text,
+    goal_amount bigint,
+    target_date date,
+    budgeted bigint,
+    activity bigint,
+    balance bigint,
+    needed bigint,
+    underfunded bigint,
+    over_cap bigint
+) as $$
+declare
+    v_ledger_id bigint;
+    v_start_date date;
+    v_end_date date;
+begin
+    if p_period is null or p_period !~ '^\d{6}$' then
+        raise exception 'Invalid period format. Use YYYYMM (e.g., 202508)';
+    end if;
+    v_start_date := (p_period || '01')::date;
+    v_end_date := (v_start_date + interval '1 month - 1 day')::date;
+
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    return query
+    with in_month as (
+        select bs.account_uuid, bs.budgeted::bigint as budgeted, bs.activity::bigint as activity
+        from utils.get_budget_status(p_ledger_uuid, p_user_data, v_start_date, v_end_date) bs
+    ),
+    before_month as (
+        select bs.account_uuid, bs.balance::bigint as balance
+        from utils.get_budget_status(p_ledger_uuid, p_user_data, null, v_start_date - 1) bs
+    ),
+    progress as (
+        select
+            a.uuid as category_uuid,
+            a.name as category_name,
+            g.type as goal_type,
+            g.amount as goal_amount,
+            g.target_date,
+            coalesce(m.budgeted, 0) as budgeted,
+            coalesce(m.activity, 0) as activity,
+            coalesce(b.balance, 0) + coalesce(m.budgeted, 0) + coalesce(m.activity, 0) as balance,
+            case g.type
+                when 'monthly_funding' then g.amount
+                -- what is missing at the start of the month, spread over the
+                -- months left including this one; all of it once the date passed
+                when 'target_balance' then
+                    utils.ceil_div(
+                        greatest(g.amount - coalesce(b.balance, 0), 0),
+                        greatest(
+                            (extract(year from g.target_date) * 12 + extract(month from g.target_date))::int
+                                - (extract(year from v_start_date) * 12 + extract(month from v_start_date))::int + 1,
+                            1
+                        )
+                    )
+                else 0
+            end as needed
+        from
+            data.category_goals g
+            join data.accounts a on a.id = g.category_id
+            left join in_month m on m.account_uuid = a.uuid
+            left join before_month b on b.account_uuid = a.uuid
+        where
+            g.ledger_id = v_ledger_id
+            and g.user_data = p_user_data
+    )
+    select
+        p.category_uuid,
+        p.category_name,
+        p.goal_type,
+        p.goal_amount,
+        p.target_date,
+        p.budgeted,
+        p.activity,
+        p.balance,
+        p.needed,
+        greatest(p.needed - p.budgeted, 0) as underfunded,
+        case when p.goal_type = 'spending_cap' then greatest(-p.activity - p.goal_amount, 0) else 0 end as over_cap
+    from
+        progress p
+    order by
+        p.category_name;
+end;
+$$ language plpgsql stable security definer;
+
+-- public api function to set the goal of a category
+create or replace function api.set_category_goal(
+    p_category_uuid text,
+    p_type text, -- 'monthly_funding', 'target_balance' or 'spending_cap'
+    p_amount bigint,
+    p_target_date date default null -- target_balance only
+) returns void as $$
+begin
+    perform utils.set_category_goal(p_category_uuid, p_type, p_amount, p_target_date);
+end;
+$$ language plpgsql security definer;
+
+-- public api function to remove the goal of a category
+create or replace function api.delete_category_goal(
+    p_category_uuid text
+) returns void as $$
+begin
+    perform utils.delete_category_goal(p_category_uuid);
+end;
+$$ language plpgsql security definer;
+
+-- public api function reporting the progress of the goals of a ledger in a
+-- month, YYYYMM, the current one by default
+create or replace function api.get_goal_progress(
+    p_ledger_uuid text,
+    p_period text default to_char(current_date, 'YYYYMM')
+) returns table (
+    category_uuid text,
+    category_name text,
+    goal_type text,
+    goal_amount bigint,
+    target_date date,
+    budgeted bigint,
+    activity bigint,
+    balance bigint,
+    needed bigint,
+    underfunded bigint,
+    over_cap bigint
+) as $$
+begin
+    return query
+    select * from utils.get_goal_progress(p_ledger_uuid, p_period);
+end;
+$$ language plpgsql stable security invoker;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop function if exists api.get_goal_progress(text, text);
+drop function if exists api.delete_category_goal(text);
+drop function if exists api.set_category_goal(text, text, bigint, date);
+drop function if exists utils.ceil_div(bigint, bigint);
+drop function if exists utils.get_goal_progress(text, text, text);
+drop function if exists utils.delete_category_goal(text, text);
+drop function if exists utils.set_category_goal(text, text, bigint, date, text);
+drop table if exists data.category_goals;
+
+-- +goose StatementEnd
@@ -0,0 +1,429 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- what closing a month does with the balances of the budget categories.
+-- leftover is what happens to a positive balance:
+--   keep        it rolls over into the next month
+--   income      it returns to Income, to be budgeted again
+-- an overspent category is covered according to how the money was spent.
+-- cash_overspending is the part spent from bank accounts and
+-- credit_overspending the part spent on credit cards:
+--   income      Income covers it, leaving less to budget next month
+--   unassigned  Unassigned covers it, tracking it as unfunded debt
+--   keep        the negative balance rolls over into the next month
+create table data.rollover_rules
+(
+    id                  bigint generated always as identity primary key,
+    created_at          timestamptz not null default current_timestamp,
+    updated_at          timestamptz not null default current_timestamp,
+
+    leftover            text        not null default 'keep',
+    cash_overspending   text        not null default 'income',
+    credit_overspending text        not null default 'unassigned',
+
+    ledger_id           bigint      not null references data.ledgers (id) on delete cascade,
+    user_data           text        not null default utils.get_user(),
+
+    constraint rollover_rules_ledger_unique unique (ledger_id),
+    constraint rollover_rules_leftover_check check (leftover in ('keep', 'income')),
+    constraint rollover_rules_cash_overspending_check check (cash_overspending in ('income', 'unassigned', 'keep')),
+    constraint rollover_rules_credit_overspending_check check (credit_overspending in ('income', 'unassigned', 'keep')),
+    constraint rollover_rules_user_data_length_check check (char_length(user_data) < 255)
+);
+
+-- enable RLS
+alter table data.rollover_rules
+    enable row level security;
+
+create policy rollover_rules_policy on data.rollover_rules
+    using (user_data = utils.get_user())
+    with check (user_data = utils.get_user());
+
+-- a closed month of a ledger. month is its first day
+create table data.month_closes
+(
+    id         bigint generated always as identity primary key,
+    uuid       text        not null default utils.nanoid(8),
+    created_at timestamptz not null default current_timestamp,
+
+    month      date        not null,
+
+    ledger_id  bigint      not null references data.ledgers (id) on delete cascade,
+    user_data  text        not null default utils.get_user(),
+
+    constraint month_closes_uuid_unique unique (uuid),
+    constraint month_closes_month_unique unique (ledger_id, month),
+    constraint month_closes_month_check check (extract(day from month) = 1),
+    constraint month_closes_user_data_length_check check (char_length(user_data) < 255)
+);
+
+-- enable RLS
+alter table data.month_closes
+    enable row level security;
+
+create policy month_closes_policy on data.month_closes
+    using (user_data = utils.get_user())
+    with check (user_data = utils.get_user());
+
+-- the transactions recorded by closing a month point to it
+alter table data.transactions
+    add column month_close_id bigint references data.month_closes (id) on delete set null;
+
+create index idx_transactions_month_close_id on data.transactions (month_close_id)
+    where month_close_id is not null;
+
+-- the rollover rules of a ledger; the defaults until they are set
+create or replace function utils.get_rollover_rules(
+    p_ledger_uuid text,
+    p_user_data text = utils.get_user()
+)
+returns table (
+    leftover text,
+    cash_overspending text,
+    credit_overspending text
+) as $$
+declare
+    v_ledger_id bigint;
+begin
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    return query
+    select
+        coalesce(r.leftover, 'keep'),
+        coalesce(r.cash_overspending, 'income'),
+        coalesce(r.credit_overspending, 'unassigned')
+    from
+        (select 1) as defaults
+        left join data.rollover_rules r on r.ledger_id = v_ledger_id and r.user_data = p_user_data;
+end;
+$$ language plpgsql stable security definer;
+
+-- set the rollover rules of a ledger
+create or replace function utils.set_rollover_rules(
+    p_ledger_uuid text,
+    p_leftover text,
+    p_cash_overspending text,
+    p_credit_overspending text,
+    p_user_data text = utils.get_user()
+) returns void as
+$$
+declare
+    v_ledger_id bigint;
+begin
+    if p_leftover is null or p_leftover not in ('keep', 'income') then
+        raise exception 'Invalid leftover rule: %. Must be "keep" or "income"', p_leftover
+            using errcode = 'PB013';
+    end if;
+
+    if p_cash_overspending is null or p_cash_overspending not in ('income', 'unassigned', 'keep') then
+        raise exception 'Invalid cash overspending rule: %. Must be "income", "unassigned" or "keep"', p_cash_overspending
+            using errcode = 'PB013';
+    end if;
+
+    if p_credit_overspending is null or p_credit_overspending not in ('income', 'unassigned', 'keep') then
+        raise exception 'Invalid credit overspending rule: %. Must be "income", "unassigned" or "keep"', p_credit_overspending
+            using errcode = 'PB013';
+    end if;
+
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    insert into data.rollover_rules (leftover, cash_overspending, credit_overspending, ledger_id, user_data)
+    values (p_leftover, p_cash_overspending, p_credit_overspending, v_ledger_id, p_user_data)
+    on conflict (ledger_id) do update
+        set leftover            = excluded.leftover,
+            cash_overspending   = excluded.cash_overspending,
+            credit_overspending = excluded.credit_overspending,
+            updated_at          = current_timestamp;
+end;
+$$ language plpgsql security definer;
+
+-- close a month of a ledger: apply the rollover rules to the balance every
+-- budget category has at the end of the month. every change is recorded as
+-- a transaction between the category and Income or Unassigned, dated the
+-- first day of the next month and pointing to the close. months close once,
+-- in order, and only once they are over
+create or replace function utils.close_month(
+    p_ledger_uuid text,
+    p_period text,
+    p_user_data text = utils.get_user()
+)
+returns table (
+    category_uuid text,
+    category_name text,
+    kind text,
+    amount bigint,
+    transaction_uuid text
+) as $$
+declare
+    v_ledger_id bigint;
+    v_start_date date;
+    v_next_date date;
+    v_last_month date;
+    v_rules record;
+    v_income_id bigint;
+    v_unassigned_id bigint;
+    v_close_id bigint;
+    v_label text;
+    v_category record;
+    v_credit bigint;
+    v_change record;
+    v_transaction_uuid text;
+begin
+    if p_period is null or p_period !~ '^\d{6}$' then
+        raise exception 'Invalid period format. Use YYYYMM (e.g., 202508)';
+    end if;
+    v_start_date := (p_period || '01')::date;
+    v_next_date := (v_start_date + interval '1 month')::date;
+    v_label := to_char(v_start_date, 'YYYY-MM');
+
+    -- closes of the same ledger wait for each other
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data
+       for update;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    if v_next_date > current_date then
+        raise exception 'Month % is not over yet', v_label
+            using errcode = 'PB011';
+    end if;
+
+    select max(mc.month) into v_last_month
+      from data.month_closes mc
+     where mc.ledger_id = v_ledger_id;
+
+    if v_last_month = v_start_date then
+        raise exception 'Month % is already closed', v_label
+            using errcode = 'PB011';
+    elsif v_last_month > v_start_date then
+        raise exception 'Month % is before the last closed month %', v_label, to_char(v_last_month, 'YYYY-MM')
+            using errcode = 'PB011';
+    end if;
+
+    select * into v_rules from utils.get_rollover_rules(p_ledger_uuid, p_user_data);
+
+    select a.id into v_income_id
+      from data.accounts a
+     where a.ledger_id = v_ledger_id and a.type = 'equity' and a.name = 'Income';
+
+    select a.id into v_unassigned_id
+      from data.accounts a
+     where a.ledger_id = v_ledger_id and a.type = 'equity' and a.name = 'Unassigned';
+
+    insert into data.month_closes (month, ledger_id, user_data)
+    values (v_start_date, v_ledger_id, p_user_data)
+    returning id into v_close_id;
+
+    for v_category in
+        select
+            c.id,
+            c.uuid,
+            c.name,
+            -- categories are credit-normal: money comes in on the credit side
+            coalesce(sum(case when t.credit_account_id = c.id then t.amount else -t.amount end), 0) as balance,
+            -- the month's net spending on credit cards
+            coalesce(sum(
+                case
+                    when o.type <> 'liability' or t.date < v_start_date then 0
+                    when t.debit_account_id = c.id then t.amount
+                    else -t.amount
+                end
+            ), 0) as credit_spending
+        from
+            data.accounts c
+            left join data.transactions t
+                on (t.debit_account_id = c.id or t.credit_account_id = c.id)
+                and t.deleted_at is null
+                and t.date < v_next_date
+            left join data.accounts o
+                on o.id = case when t.debit_account_id = c.id then t.credit_account_id else t.debit_account_id end
+        where
+            c.ledger_id = v_ledger_id
+            and c.type = 'equity'
+            and c.name not in ('Income', 'Off-budget', 'Unassigned')
+        group by
+            c.id, c.uuid, c.name
+        having
+            coalesce(sum(case when t.credit_account_id = c.id then t.amount else -t.amount end), 0) <> 0
+        order by
+            c.name
+    loop
+        -- overspending is credit overspending up to what was spent on credit
+        -- cards this month; the rest was spent from bank accounts
+        v_credit := least(greatest(-v_category.balance, 0), greatest(v_category.credit_spending, 0));
+
+        for v_change in
+            select 'leftover' as kind, v_category.balance as amount, v_rules.leftover as rule
+             where v_category.balance > 0
+            union all
+            select 'cash_overspending', -v_category.balance - v_credit, v_rules.cash_overspending
+             where -v_category.balance - v_credit > 0
+            union all
+            select 'credit_overspending', v_credit, v_rules.credit_overspending
+             where v_credit > 0
+        loop
+            continue when v_change.rule = 'keep';
+
+            insert into data.transactions (
+                ledger_id, description, date, amount, debit_account_id, credit_account_id, month_close_id, user_data
+            )
+            values (
+                v_ledger_id,
+                'Month close ' || v_label || ': ' || replace(v_change.kind, '_', ' '),
+                v_next_date,
+                v_change.amount,
+                -- leftovers leave the category, overspending is covered
+                case
+                    when v_change.kind = 'leftover' then v_category.id
+                    when v_change.rule = 'income' then v_income_id
+                    else v_unassigned_id
+                end,
+                case when v_change.kind = 'leftover' then v_income_id else v_category.id end,
+                v_close_id,
+                p_user_data
+            )
+            returning data.transactions.uuid into v_transaction_uuid;
+
+            return query
+            select v_category.uuid, v_category.name, v_change.kind::text, v_change.amount::bigint, v_transaction_uuid;
+        end loop;
+    end loop;
+end;
+$$ language plpgsql security definer;
+
+-- the closed months of a ledger, newest first
+create or replace function utils.get_closed_months(
+    p_ledger_uuid text,
+    p_user_data text = utils.get_user()
+)
+returns table (
+    period text,
+    closed_at timestamptz,
+    transactions int
+) as $$
+begin
+    if not exists (
+        select 1 from data.ledgers l
+        where l.uuid = p_ledger_uuid and l.user_data = p_user_data
+    ) then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    return query
+    select
+        to_char(mc.month, 'YYYYMM') as period,
+        mc.created_at as closed_at,
+        (select count(*)::int from data.transactions t where t.month_close_id = mc.id) as transactions
+    from
+        data.month_closes mc
+        join data.ledgers l on l.id = mc.ledger_id
+    where
+        l.uuid = p_ledger_uuid
+        and mc.user_data = p_user_data
+    order by
+        mc.month desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- public api function returning the rollover rules of a ledger
+create or replace function api.get_rollover_rules(
+    p_ledger_uuid text
+) returns table (
+    leftover text,
+    cash_overspending text,
+    credit_overspending text
+) as $$
+begin
+    return query
+    select * from utils.get_rollover_rules(p_ledger_uuid);
+end;
+$$ language plpgsql stable security invoker;
+
+-- public api function to set the rollover rules of a ledger
+create or replace function api.set_rollover_rules(
+    p_ledger_uuid text,
+    p_leftover text default 'keep', -- 'keep' or 'income'
+    p_cash_overspending text default 'income', -- 'income', 'unassigned' or 'keep'
+    p_credit_overspending text default 'unassigned' -- 'income', 'unassigned' or 'keep'
+) returns void as $$
+begin
+    perform utils.set_rollover_rules(p_ledger_uuid, p_leftover, p_cash_overspending, p_credit_overspending);
+end;
+$$ language plpgsql security definer;
+
+-- public api function closing a month, YYYYMM, and returning the
+-- transactions it recorded
+create or replace function api.close_month(
+    p_ledger_uuid text,
+    p_period text
+) returns table (
+    category_uuid text,
+    category_name text,
+    kind text,
+    amount bigint,
+    transaction_uuid text
+) as $$
+begin
+    return query
+    select * from utils.close_month(p_ledger_uuid, p_period);
+end;
+$$ language plpgsql security definer;
+
+-- public api function listing the closed months of a ledger
+create or replace function api.get_closed_months(
+    p_ledger_uuid text
+) returns table (
+    period text,
+    closed_at timestamptz,
+    transactions int
+) as $$
+begin
+    return query
+    select * from utils.get_closed_months(p_ledger_uuid);
+end;
+$$ language plpgsql stable security invoker;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop function if exists api.get_closed_months(text);
+drop function if exists api.close_month(text, text);
+drop function if exists api.set_rollover_rules(text, text, text, text);
+drop function if exists api.get_rollover_rules(text);
+drop function if exists utils.get_closed_months(text, text);
+drop function if exists utils.close_month(text, text, text);
+drop function if exists utils.set_rollover_rules(text, text, text, text, text);
+drop function if exists utils.get_rollover_rules(text, text);
+
+drop index if exists data.idx_transactions_month_close_id;
+alter table data.transactions
+    drop column if exists month_close_id;
+
+drop table if exists data.month_closes;
+drop table if exists data.rollover_rules;
+
+-- +goose StatementEnd
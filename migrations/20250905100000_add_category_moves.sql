@@ -0,0 +1,145 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- move money from one budget category to another: debit the source, credit
+-- the destination. the source cannot be overdrawn on the date of the move
+-- unless p_force is set
+create or replace function utils.move_between_categories(
+    p_ledger_uuid text,
+    p_from_category_uuid text,
+    p_to_category_uuid text,
+    p_amount bigint,
+    p_date timestamptz,
+    p_memo text default null,
+    p_force boolean default false,
+    p_user_data text = utils.get_user()
+) returns table(r_uuid text, r_description text, r_amount bigint, r_date date, r_metadata jsonb, r_ledger_uuid text, r_transaction_type text, r_account_uuid text, r_category_uuid text) as
+$$
+declare
+    v_ledger_id      bigint;
+    v_from           data.accounts;
+    v_to             data.accounts;
+    v_balance        bigint;
+    v_description    text;
+    v_transaction    data.transactions;
+begin
+    -- validate move amount and date
+    perform utils.validate_transaction_data(p_amount, p_date);
+
+    v_description := coalesce(trim(p_memo), '');
+    if char_length(v_description) > 500 then
+        raise exception 'Move memo cannot exceed 500 characters. Current length: %',
+            char_length(v_description)
+            using errcode = 'PB013';
+    end if;
+
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    -- lock the source so concurrent moves cannot overdraw it together
+    select a.* into v_from
+      from data.accounts a
+     where a.uuid = p_from_category_uuid
+       and a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data
+       and a.type = 'equity'
+       for update;
+
+    if v_from.id is null then
+        raise exception 'Category with UUID % not found in ledger % for current user',
+            p_from_category_uuid, p_ledger_uuid
+            using errcode = 'PB003';
+    end if;
+
+    select a.* into v_to
+      from data.accounts a
+     where a.uuid = p_to_category_uuid
+       and a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data
+       and a.type = 'equity';
+
+    if v_to.id is null then
+        raise exception 'Category with UUID % not found in ledger % for current user',
+            p_to_category_uuid, p_ledger_uuid
+            using errcode = 'PB003';
+    end if;
+
+    if v_from.id = v_to.id then
+        raise exception 'Cannot move money from category % to itself', v_from.name
+            using errcode = 'PB013';
+    end if;
+
+    -- categories are credit-normal: credits add to the balance
+    select coalesce(sum(case when t.credit_account_id = v_from.id then t.amount else -t.amount end), 0)
+      into v_balance
+      from data.transactions t
+     where t.ledger_id = v_ledger_id
+       and (t.debit_account_id = v_from.id or t.credit_account_id = v_from.id)
+       and t.deleted_at is null
+       and t.date <= p_date;
+
+    if v_balance < p_amount and not coalesce(p_force, false) then
+        raise exception 'Category % holds $% on %, less than the $% to move. Pass p_force => true to overdraw it.',
+            v_from.name,
+            to_char(v_balance / 100.0, 'FM999999990.00'),
+            p_date::date,
+            to_char(p_amount / 100.0, 'FM999999990.00')
+            using errcode = 'PB022';
+    end if;
+
+    if v_description = '' then
+        v_description := format('Move from %s to %s', v_from.name, v_to.name);
+    end if;
+
+    insert into data.transactions (ledger_id, description, date, amount, debit_account_id, credit_account_id, user_data)
+    values (v_ledger_id, v_description, p_date, p_amount, v_from.id, v_to.id, p_user_data)
+    returning * into v_transaction;
+
+    return query select
+        v_transaction.uuid,
+        v_transaction.description,
+        v_transaction.amount,
+        v_transaction.date,
+        v_transaction.metadata,
+        p_ledger_uuid,
+        null::text, -- moves have no transaction type, like assignments
+        v_from.uuid,
+        v_to.uuid;
+end;
+$$ language plpgsql security definer;
+
+-- public api function to move money between categories. the source is in
+-- account_uuid and the destination in category_uuid of the returned row
+create or replace function api.move_between_categories(
+    p_ledger_uuid text,
+    p_from_category_uuid text,
+    p_to_category_uuid text,
+    p_amount bigint,
+    p_date timestamptz,
+    p_memo text default null, -- "Move from <source> to <destination>" when empty
+    p_force boolean default false -- allow overdrawing the source
+) returns setof api.transactions as $$
+begin
+    return query
+    select * from utils.move_between_categories(
+        p_ledger_uuid, p_from_category_uuid, p_to_category_uuid, p_amount, p_date, p_memo, p_force
+    );
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop function if exists api.move_between_categories(text, text, text, bigint, timestamptz, text, boolean);
+drop function if exists utils.move_between_categories(text, text, text, bigint, timestamptz, text, boolean, text);
+
+-- +goose StatementEnd
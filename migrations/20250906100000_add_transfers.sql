@@ -0,0 +1,537 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- every credit card has a budget category holding the money set aside to
+-- pay it. payments to the card take the money out of it
+alter table data.accounts
+    add column payment_category_id bigint references data.accounts (id) on delete set null;
+
+-- give a credit card its payment category, "<card> Payment", reusing a
+-- category that already has the name
+create or replace function utils.create_payment_category_fn()
+    returns trigger as
+$$
+declare
+    v_name text := left(new.name, 247) || ' Payment';
+    v_category_id bigint;
+begin
+    select a.id into v_category_id
+      from data.accounts a
+     where a.ledger_id = new.ledger_id
+       and a.user_data = new.user_data
+       and a.name = v_name
+       and a.type = 'equity';
+
+    if v_category_id is null then
+        insert into data.accounts (ledger_id, user_data, name, type, internal_type)
+        values (new.ledger_id, new.user_data, v_name, 'equity', 'liability_like')
+        returning id into v_category_id;
+    end if;
+
+    update data.accounts
+       set payment_category_id = v_category_id
+     where id = new.id;
+
+    return new;
+end;
+$$ language plpgsql;
+
+create trigger accounts_create_payment_category_tg
+    after insert
+    on data.accounts
+    for each row
+    when (new.type = 'liability')
+execute function utils.create_payment_category_fn();
+
+comment on trigger accounts_create_payment_category_tg on data.accounts is 'After inserting a credit card (liability), creates its payment category.';
+
+-- credit cards created before get their payment category too
+do $$
+declare
+    v_card data.accounts;
+    v_name text;
+    v_category_id bigint;
+begin
+    for v_card in
+        select a.* from data.accounts a where a.type = 'liability' and a.payment_category_id is null
+    loop
+        v_name := left(v_card.name, 247) || ' Payment';
+        v_category_id := null;
+
+        select a.id into v_category_id
+          from data.accounts a
+         where a.ledger_id = v_card.ledger_id
+           and a.user_data = v_card.user_data
+           and a.name = v_name
+           and a.type = 'equity';
+
+        if v_category_id is null then
+            insert into data.accounts (ledger_id, user_data, name, type, internal_type)
+            values (v_card.ledger_id, v_card.user_data, v_name, 'equity', 'liability_like')
+            returning id into v_category_id;
+        end if;
+
+        update data.accounts set payment_category_id = v_category_id where id = v_card.id;
+    end loop;
+end;
+$$;
+
+-- record money moving between two bank or credit card accounts: credit the
+-- source, debit the destination. budget categories are not touched, except
+-- for payments from a bank account to a credit card: they also move the
+-- amount, up to what it holds, from the payment category of the card to
+-- Unassigned, where credit overspending is tracked
+create or replace function utils.add_transfer(
+    p_ledger_uuid text,
+    p_from_account_uuid text,
+    p_to_account_uuid text,
+    p_amount bigint,
+    p_date date,
+    p_memo text default null,
+    p_user_data text = utils.get_user()
+) returns text as
+$$
+declare
+    v_ledger_id     bigint;
+    v_from          data.accounts;
+    v_to            data.accounts;
+    v_description   text;
+    v_transfer      data.transactions;
+    v_unassigned_id bigint;
+    v_available     bigint;
+begin
+    perform utils.validate_transaction_data(p_amount, p_date);
+
+    v_description := coalesce(trim(p_memo), '');
+    if char_length(v_description) > 500 then
+        raise exception 'Transfer memo cannot exceed 500 characters. Current length: %',
+            char_length(v_description)
+            using errcode = 'PB013';
+    end if;
+
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    select a.* into v_from
+      from data.accounts a
+     where a.uuid = p_from_account_uuid
+       and a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data;
+
+    if v_from.id is null then
+        raise exception 'Account with UUID % not found in ledger % for current user', p_from_account_uuid, p_ledger_uuid
+            using errcode = 'PB002';
+    end if;
+
+    select a.* into v_to
+      from data.accounts a
+     where a.uuid = p_to_account_uuid
+       and a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data;
+
+    if v_to.id is null then
+        raise exception 'Account with UUID % not found in ledger % for current user', p_to_account_uuid, p_ledger_uuid
+            using errcode = 'PB002';
+    end if;
+
+    if v_from.type = 'equity' or v_to.type = 'equity' then
+        raise exception 'Transfers are between bank and credit card accounts; use api.move_between_categories for categories'
+            using errcode = 'PB013';
+    end if;
+
+    if v_from.id = v_to.id then
+        raise exception 'Cannot transfer money from account % to itself', v_from.name
+            using errcode = 'PB013';
+    end if;
+
+    if v_description = '' then
+        v_description := format('Transfer from %s to %s', v_from.name, v_to.name);
+    end if;
+
+    insert into data.transactions (ledger_id, description, date, amount, debit_account_id, credit_account_id, user_data)
+    values (v_ledger_id, v_description, p_date, p_amount, v_to.id, v_from.id, p_user_data)
+    returning * into v_transfer;
+
+    -- a card payment spends what was set aside in the payment category
+    if v_from.type = 'asset' and v_to.type = 'liability' and v_to.payment_category_id is not null then
+        select coalesce(sum(case when t.credit_account_id = v_to.payment_category_id then t.amount else -t.amount end), 0)
+          into v_available
+          from data.transactions t
+         where t.ledger_id = v_ledger_id
+           and (t.debit_account_id = v_to.payment_category_id or t.credit_account_id = v_to.payment_category_id)
+           and t.deleted_at is null;
+
+        if least(v_available, p_amount) > 0 then
+            select a.id into v_unassigned_id
+              from data.accounts a
+             where a.ledger_id = v_ledger_id and a.type = 'equity' and a.name = 'Unassigned';
+
+            insert into data.transactions (
+                ledger_id, description, date, amount, debit_account_id, credit_account_id, metadata, user_data
+            )
+            values (
+                v_ledger_id,
+                format('Payment to %s', v_to.name),
+                p_date,
+                least(v_available, p_amount),
+                v_to.payment_category_id,
+                v_unassigned_id,
+                jsonb_build_object('transfer_uuid', v_transfer.uuid),
+                p_user_data
+            );
+        end if;
+    end if;
+
+    return v_transfer.uuid;
+end;
+$$ language plpgsql security definer;
+
+-- public api function to transfer money between bank and credit card
+-- accounts, returning the uuid of the transfer
+create or replace function api.add_transfer(
+    p_ledger_uuid text,
+    p_from_account_uuid text,
+    p_to_account_uuid text,
+    p_amount bigint,
+    p_date date,
+    p_memo text default null -- "Transfer from <source> to <destination>" when empty
+) returns text as $$
+begin
+    return utils.add_transfer(p_ledger_uuid, p_from_account_uuid, p_to_account_uuid, p_amount, p_date, p_memo);
+end;
+$$ language plpgsql security definer;
+
+-- payment categories always roll over: their money waits for the payment
+create or replace function utils.close_month(
+    p_ledger_uuid text,
+    p_period text,
+    p_user_data text = utils.get_user()
+)
+returns table (
+    category_uuid text,
+    category_name text,
+    kind text,
+    amount bigint,
+    transaction_uuid text
+) as $$
+declare
+    v_ledger_id bigint;
+    v_start_date date;
+    v_next_date date;
+    v_last_month date;
+    v_rules record;
+    v_income_id bigint;
+    v_unassigned_id bigint;
+    v_close_id bigint;
+    v_label text;
+    v_category record;
+    v_credit bigint;
+    v_change record;
+    v_transaction_uuid text;
+begin
+    if p_period is null or p_period !~ '^\d{6}$' then
+        raise exception 'Invalid period format. Use YYYYMM (e.g., 202508)';
+    end if;
+    v_start_date := (p_period || '01')::date;
+    v_next_date := (v_start_date + interval '1 month')::date;
+    v_label := to_char(v_start_date, 'YYYY-MM');
+
+    -- closes of the same ledger wait for each other
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data
+       for update;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    if v_next_date > current_date then
+        raise exception 'Month % is not over yet', v_label
+            using errcode = 'PB011';
+    end if;
+
+    select max(mc.month) into v_last_month
+      from data.month_closes mc
+     where mc.ledger_id = v_ledger_id;
+
+    if v_last_month = v_start_date then
+        raise exception 'Month % is already closed', v_label
+            using errcode = 'PB011';
+    elsif v_last_month > v_start_date then
+        raise exception 'Month % is before the last closed month %', v_label, to_char(v_last_month, 'YYYY-MM')
+            using errcode = 'PB011';
+    end if;
+
+    select * into v_rules from utils.get_rollover_rules(p_ledger_uuid, p_user_data);
+
+    select a.id into v_income_id
+      from data.accounts a
+     where a.ledger_id = v_ledger_id and a.type = 'equity' and a.name = 'Income';
+
+    select a.id into v_unassigned_id
+      from data.accounts a
+     where a.ledger_id = v_ledger_id and a.type = 'equity' and a.name = 'Unassigned';
+
+    insert into data.month_closes (month, ledger_id, user_data)
+    values (v_start_date, v_ledger_id, p_user_data)
+    returning id into v_close_id;
+
+    for v_category in
+        select
+            c.id,
+            c.uuid,
+            c.name,
+            -- categories are credit-normal: money comes in on the credit side
+            coalesce(sum(case when t.credit_account_id = c.id then t.amount else -t.amount end), 0) as balance,
+            -- the month's net spending on credit cards
+            coalesce(sum(
+                case
+                    when o.type <> 'liability' or t.date < v_start_date then 0
+                    when t.debit_account_id = c.id then t.amount
+                    else -t.amount
+                end
+            ), 0) as credit_spending
+        from
+            data.accounts c
+            left join data.transactions t
+                on (t.debit_account_id = c.id or t.credit_account_id = c.id)
+                and t.deleted_at is null
+                and t.date < v_next_date
+            left join data.accounts o
+                on o.id = case when t.debit_account_id = c.id then t.credit_account_id else t.debit_account_id end
+        where
+            c.ledger_id = v_ledger_id
+            and c.type = 'equity'
+            and c.name not in ('Income', 'Off-budget', 'Unassigned')
+            and not exists (select 1 from data.accounts card where card.payment_category_id = c.id)
+        group by
+            c.id, c.uuid, c.name
+        having
+            coalesce(sum(case when t.credit_account_id = c.id then t.amount else -t.amount end), 0) <> 0
+        order by
+            c.name
+    loop
+        -- overspending is credit overspending up to what was spent on credit
+        -- cards this month; the rest was spent from bank accounts
+        v_credit := least(greatest(-v_category.balance, 0), greatest(v_category.credit_spending, 0));
+
+        for v_change in
+            select 'leftover' as kind, v_category.balance as amount, v_rules.leftover as rule
+             where v_category.balance > 0
+            union all
+            select 'cash_overspending', -v_category.balance - v_credit, v_rules.cash_overspending
+             where -v_category.balance - v_credit > 0
+            union all
+            select 'credit_overspending', v_credit, v_rules.credit_overspending
+             where v_credit > 0
+        loop
+            continue when v_change.rule = 'keep';
+
+            insert into data.transactions (
+                ledger_id, description, date, amount, debit_account_id, credit_account_id, month_close_id, user_data
+            )
+            values (
+                v_ledger_id,
+                'Month close ' || v_label || ': ' || replace(v_change.kind, '_', ' '),
+                v_next_date,
+                v_change.amount,
+                -- leftovers leave the category, overspending is covered
+                case
+                    when v_change.kind = 'leftover' then v_category.id
+                    when v_change.rule = 'income' then v_income_id
+                    else v_unassigned_id
+                end,
+                case when v_change.kind = 'leftover' then v_income_id else v_category.id end,
+                v_close_id,
+                p_user_data
+            )
+            returning data.transactions.uuid into v_transaction_uuid;
+
+            return query
+            select v_category.uuid, v_category.name, v_change.kind::text, v_change.amount::bigint, v_transaction_uuid;
+        end loop;
+    end loop;
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- restore close_month without the payment categories
+create or replace function utils.close_month(
+    p_ledger_uuid text,
+    p_period text,
+    p_user_data text = utils.get_user()
+)
+returns table (
+    category_uuid text,
+    category_name text,
+    kind text,
+    amount bigint,
+    transaction_uuid text
+) as $$
+declare
+    v_ledger_id bigint;
+    v_start_date date;
+    v_next_date date;
+    v_last_month date;
+    v_rules record;
+    v_income_id bigint;
+    v_unassigned_id bigint;
+    v_close_id bigint;
+    v_label text;
+    v_category record;
+    v_credit bigint;
+    v_change record;
+    v_transaction_uuid text;
+begin
+    if p_period is null or p_period !~ '^\d{6}$' then
+        raise exception 'Invalid period format. Use YYYYMM (e.g., 202508)';
+    end if;
+    v_start_date := (p_period || '01')::date;
+    v_next_date := (v_start_date + interval '1 month')::date;
+    v_label := to_char(v_start_date, 'YYYY-MM');
+
+    -- closes of the same ledger wait for each other
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data
+       for update;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    if v_next_date > current_date then
+        raise exception 'Month % is not over yet', v_label
+            using errcode = 'PB011';
+    end if;
+
+    select max(mc.month) into v_last_month
+      from data.month_closes mc
+     where mc.ledger_id = v_ledger_id;
+
+    if v_last_month = v_start_date then
+        raise exception 'Month % is already closed', v_label
+            using errcode = 'PB011';
+    elsif v_last_month > v_start_date then
+        raise exception 'Month % is before the last closed month %', v_label, to_char(v_last_month, 'YYYY-MM')
+            using errcode = 'PB011';
+    end if;
+
+    select * into v_rules from utils.get_rollover_rules(p_ledger_uuid, p_user_data);
+
+    select a.id into v_income_id
+      from data.accounts a
+     where a.ledger_id = v_ledger_id and a.type = 'equity' and a.name = 'Income';
+
+    select a.id into v_unassigned_id
+      from data.accounts a
+     where a.ledger_id = v_ledger_id and a.type = 'equity' and a.name = 'Unassigned';
+
+    insert into data.month_closes (month, ledger_id, user_data)
+    values (v_start_date, v_ledger_id, p_user_data)
+    returning id into v_close_id;
+
+    for v_category in
+        select
+            c.id,
+            c.uuid,
+            c.name,
+            -- categories are credit-normal: money comes in on the credit side
+            coalesce(sum(case when t.credit_account_id = c.id then t.amount else -t.amount end), 0) as balance,
+            -- the month's net spending on credit cards
+            coalesce(sum(
+                case
+                    when o.type <> 'liability' or t.date < v_start_date then 0
+                    when t.debit_account_id = c.id then t.amount
+                    else -t.amount
+                end
+            ), 0) as credit_spending
+        from
+            data.accounts c
+            left join data.transactions t
+                on (t.debit_account_id = c.id or t.credit_account_id = c.id)
+                and t.deleted_at is null
+                and t.date < v_next_date
+            left join data.accounts o
+                on o.id = case when t.debit_account_id = c.id then t.credit_account_id else t.debit_account_id end
+        where
+            c.ledger_id = v_ledger_id
+            and c.type = 'equity'
+            and c.name not in ('Income', 'Off-budget', 'Unassigned')
+        group by
+            c.id, c.uuid, c.name
+        having
+            coalesce(sum(case when t.credit_account_id = c.id then t.amount else -t.amount end), 0) <> 0
+        order by
+            c.name
+    loop
+        -- overspending is credit overspending up to what was spent on credit
+        -- cards this month; the rest was spent from bank accounts
+        v_credit := least(greatest(-v_category.balance, 0), greatest(v_category.credit_spending, 0));
+
+        for v_change in
+            select 'leftover' as kind, v_category.balance as amount, v_rules.leftover as rule
+             where v_category.balance > 0
+            union all
+            select 'cash_overspending', -v_category.balance - v_credit, v_rules.cash_overspending
+             where -v_category.balance - v_credit > 0
+            union all
+            select 'credit_overspending', v_credit, v_rules.credit_overspending
+             where v_credit > 0
+        loop
+            continue when v_change.rule = 'keep';
+
+            insert into data.transactions (
+                ledger_id, description, date, amount, debit_account_id, credit_account_id, month_close_id, user_data
+            )
+            values (
+                v_ledger_id,
+                'Month close ' || v_label || ': ' || replace(v_change.kind, '_', ' '),
+                v_next_date,
+                v_change.amount,
+                -- leftovers leave the category, overspending is covered
+                case
+                    when v_change.kind = 'leftover' then v_category.id
+                    when v_change.rule = 'income' then v_income_id
+                    else v_unassigned_id
+                end,
+                case when v_change.kind = 'leftover' then v_income_id else v_category.id end,
+                v_close_id,
+                p_user_data
+            )
+            returning data.transactions.uuid into v_transaction_uuid;
+
+            return query
+            select v_category.uuid, v_category.name, v_change.kind::text, v_change.amount::bigint, v_transaction_uuid;
+        end loop;
+    end loop;
+end;
+$$ language plpgsql security definer;
+
+drop function if exists api.add_transfer(text, text, text, bigint, date, text);
+drop function if exists utils.add_transfer(text, text, text, bigint, date, text, text);
+
+drop trigger if exists accounts_create_payment_category_tg on data.accounts;
+drop function if exists utils.create_payment_category_fn();
+
+-- the payment categories stay behind as plain categories
+alter table data.accounts
+    drop column if exists payment_category_id;
+
+-- +goose StatementEnd
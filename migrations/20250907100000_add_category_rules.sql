@@ -0,0 +1,756 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- a rule categorizes the transactions recorded without a category. a rule
+-- matches when every condition it has holds:
+--   description_pattern  case-insensitive regular expression on the description
+--   min_amount           the amount is at least this many cents
+--   max_amount           the amount is at most this many cents
+--   account_id           the transaction goes through this bank account or card
+--   weekdays             the date falls on one of these ISO weekdays, 1 (Monday) to 7
+-- the first matching rule, by priority then age, assigns its category,
+-- replaces the description with set_description and adds its tags to the
+-- metadata
+create table data.category_rules
+(
+    id                  bigint generated always as identity primary key,
+    uuid                text        not null default utils.nanoid(8),
+    created_at          timestamptz not null default current_timestamp,
+    updated_at          timestamptz not null default current_timestamp,
+
+    name                text        not null,
+    priority            int         not null default 0,
+
+    description_pattern text,
+    min_amount          bigint,
+    max_amount          bigint,
+    account_id          bigint references data.accounts (id) on delete cascade,
+    weekdays            int[],
+
+    category_id         bigint references data.accounts (id) on delete cascade,
+    set_description     text,
+    tags                text[],
+
+    ledger_id           bigint      not null references data.ledgers (id) on delete cascade,
+    user_data           text        not null default utils.get_user(),
+
+    constraint category_rules_uuid_unique unique (uuid),
+    constraint category_rules_name_ledger_unique unique (name, ledger_id),
+    constraint category_rules_amount_range_check check (min_amount is null or max_amount is null or min_amount <= max_amount),
+    constraint category_rules_weekdays_check check (weekdays is null or weekdays <@ array[1, 2, 3, 4, 5, 6, 7]),
+    constraint category_rules_action_check check (
+        category_id is not null or set_description is not null or cardinality(tags) > 0
+    ),
+    constraint category_rules_user_data_length_check check (char_length(user_data) < 255)
+);
+
+-- enable RLS
+alter table data.category_rules
+    enable row level security;
+
+create policy category_rules_policy on data.category_rules
+    using (user_data = utils.get_user())
+    with check (user_data = utils.get_user());
+
+create index idx_category_rules_ledger_id on data.category_rules (ledger_id);
+
+-- add a rule to a ledger, returning its uuid
+create or replace function utils.add_category_rule(
+    p_ledger_uuid text,
+    p_name text,
+    p_category_uuid text = null,
+    p_description_pattern text = null,
+    p_min_amount bigint = null,
+    p_max_amount bigint = null,
+    p_account_uuid text = null,
+    p_weekdays int[] = null,
+    p_set_description text = null,
+    p_tags text[] = null,
+    p_priority int = 0,
+    p_user_data text = utils.get_user()
+) returns text as
+$$
+declare
+    v_ledger_id   bigint;
+    v_category_id bigint;
+    v_account_id  bigint;
+    v_name        text;
+    v_rule_uuid   text;
+begin
+    v_name := utils.validate_input_data(p_name, null, 'rule');
+
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    if p_category_uuid is null and nullif(trim(p_set_description), '') is null and coalesce(cardinality(p_tags), 0) = 0 then
+        raise exception 'Rule % does nothing: give it a category, a description or tags', v_name
+            using errcode = 'PB013';
+    end if;
+
+    if p_description_pattern is not null then
+        begin
+            perform '' ~* p_description_pattern;
+        exception
+            when invalid_regular_expression then
+                raise exception 'Invalid description pattern: %', p_description_pattern
+                    using errcode = 'PB013';
+        end;
+    end if;
+
+    if p_min_amount < 0 or p_max_amount < 0 or p_min_amount > p_max_amount then
+        raise exception 'Invalid amount range: % to %', p_min_amount, p_max_amount
+            using errcode = 'PB010';
+    end if;
+
+    if not coalesce(p_weekdays <@ array[1, 2, 3, 4, 5, 6, 7], true) then
+        raise exception 'Invalid weekdays: %. Use 1 (Monday) to 7 (Sunday)', p_weekdays
+            using errcode = 'PB013';
+    end if;
+
+    if p_category_uuid is not null then
+        select a.id into v_category_id
+          from data.accounts a
+         where a.uuid = p_category_uuid
+           and a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.type = 'equity';
+
+        if v_category_id is null then
+            raise exception 'Category with UUID % not found in ledger % for current user', p_category_uuid, p_ledger_uuid
+                using errcode = 'PB003';
+        end if;
+    end if;
+
+    if p_account_uuid is not null then
+        select a.id into v_account_id
+          from data.accounts a
+         where a.uuid = p_account_uuid
+           and a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.type <> 'equity';
+
+        if v_account_id is null then
+            raise exception 'Account with UUID % not found in ledger % for current user', p_account_uuid, p_ledger_uuid
+                using errcode = 'PB002';
+        end if;
+    end if;
+
+    begin
+        insert into data.category_rules (
+            name, priority, description_pattern, min_amount, max_amount, account_id, weekdays,
+            category_id, set_description, tags, ledger_id, user_data
+        )
+        values (
+            v_name, coalesce(p_priority, 0), p_description_pattern, p_min_amount, p_max_amount, v_account_id,
+            p_weekdays, v_category_id, nullif(trim(p_set_description), ''), nullif(p_tags, '{}'),
+            v_ledger_id, p_user_data
+        )
+        returning uuid into v_rule_uuid;
+    exception
+        when unique_violation then
+            raise exception 'A rule named % already exists in this ledger', v_name
+                using errcode = 'unique_violation';
+    end;
+
+    return v_rule_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- remove a rule
+create or replace function utils.delete_category_rule(
+    p_rule_uuid text,
+    p_user_data text = utils.get_user()
+) returns void as
+$$
+begin
+    delete from data.category_rules r
+     where r.uuid = p_rule_uuid
+       and r.user_data = p_user_data;
+
+    if not found then
+        raise exception 'Rule with UUID % not found for current user', p_rule_uuid
+            using errcode = 'PB007';
+    end if;
+end;
+$$ language plpgsql security definer;
+
+-- the first rule of a ledger matching a transaction, if any
+create or replace function utils.match_category_rule(
+    p_ledger_id bigint,
+    p_account_id bigint,
+    p_description text,
+    p_amount bigint,
+    p_date date,
+    p_user_data text = utils.get_user()
+) returns data.category_rules as
+$$
+    select r.*
+      from data.category_rules r
+     where r.ledger_id = p_ledger_id
+       and r.user_data = p_user_data
+       and (r.description_pattern is null or coalesce(p_description, '') ~* r.description_pattern)
+       and (r.min_amount is null or p_amount >= r.min_amount)
+       and (r.max_amount is null or p_amount <= r.max_amount)
+       and (r.account_id is null or r.account_id = p_account_id)
+       and (r.weekdays is null or extract(isodow from p_date)::int = any (r.weekdays))
+     order by r.priority, r.id
+     limit 1;
+$$ language sql stable security definer;
+
+-- the metadata a rule adds to the transactions it categorizes
+create or replace function utils.category_rule_metadata(
+    p_rule data.category_rules
+) returns jsonb as
+$$
+    select jsonb_build_object('rule_uuid', (p_rule).uuid)
+        || case when (p_rule).tags is null then '{}'::jsonb else jsonb_build_object('tags', to_jsonb((p_rule).tags)) end;
+$$ language sql immutable;
+
+-- the rules of a ledger, in the order they are tried
+create or replace function utils.get_category_rules(
+    p_ledger_uuid text,
+    p_user_data text = utils.get_user()
+)
+returns table (
+    uuid text,
+    name text,
+    priority int,
+    description_pattern text,
+    min_amount bigint,
+    max_amount bigint,
+    account_uuid text,
+    weekdays int[],
+    category_uuid text,
+    category_name text,
+    set_description text,
+    tags text[]
+) as $$
+declare
+    v_ledger_id bigint;
+begin
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    return query
+    select
+        r.uuid,
+        r.name,
+        r.priority,
+        r.description_pattern,
+        r.min_amount,
+        r.max_amount,
+        a.uuid as account_uuid,
+        r.weekdays,
+        c.uuid as category_uuid,
+        c.name as category_name,
+        r.set_description,
+        r.tags
+    from
+        data.category_rules r
+        left join data.accounts a on a.id = r.account_id
+        left join data.accounts c on c.id = r.category_id
+    where
+        r.ledger_id = v_ledger_id
+        and r.user_data = p_user_data
+    order by
+        r.priority,
+        r.id;
+end;
+$$ language plpgsql stable security definer;
+
+-- run the rules over the Unassigned transactions of a ledger. every match is
+-- corrected through api.correct_transaction, so the transaction log keeps
+-- the original; the correction carries the metadata of the original and of
+-- the rule. split, pending, reconciled and month close transactions are
+-- left alone
+create or replace function utils.apply_category_rules(
+    p_ledger_uuid text,
+    p_user_data text = utils.get_user()
+)
+returns table (
+    transaction_uuid text,
+    correction_uuid text,
+    rule_uuid text,
+    category_uuid text
+) as $$
+declare
+    v_ledger_id     bigint;
+    v_unassigned    data.accounts;
+    v_transaction   record;
+    v_rule          data.category_rules;
+    v_category_uuid text;
+    v_correction    text;
+begin
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    select a.* into v_unassigned
+      from data.accounts a
+     where a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data
+       and a.name = 'Unassigned'
+       and a.type = 'equity';
+
+    for v_transaction in
+        select
+            t.*,
+            o.id as account_id,
+            o.uuid as account_uuid,
+            -- the type as the account sees it, like api.get_account_transactions
+            case
+                when (o.internal_type = 'asset_like' and t.debit_account_id = o.id) or
+                     (o.internal_type = 'liability_like' and t.credit_account_id = o.id)
+                then 'inflow'
+                else 'outflow'
+            end as type
+        from
+            data.transactions t
+            join data.accounts o on o.id = case
+                when t.debit_account_id = v_unassigned.id then t.credit_account_id
+                else t.debit_account_id
+            end
+        where
+            t.ledger_id = v_ledger_id
+            and t.user_data = p_user_data
+            and v_unassigned.id in (t.debit_account_id, t.credit_account_id)
+            and o.type in ('asset', 'liability')
+            and t.deleted_at is null
+            and t.status = 'posted'
+            and t.split_id is null
+            and t.reconciliation_id is null
+            and t.month_close_id is null
+            -- a rule without a category leaves its matches Unassigned
+            and not coalesce(t.metadata ? 'rule_uuid', false)
+            -- corrected or deleted transactions and their reversals are history
+            and not exists (
+                select 1
+                from data.transaction_log tl
+                where t.id in (tl.original_transaction_id, tl.reversal_transaction_id)
+            )
+        order by
+            t.date,
+            t.id
+    loop
+        v_rule := utils.match_category_rule(
+            v_ledger_id, v_transaction.account_id, v_transaction.description, v_transaction.amount,
+            v_transaction.date, p_user_data
+        );
+        continue when v_rule.id is null;
+
+        select a.uuid into v_category_uuid from data.accounts a where a.id = v_rule.category_id;
+
+        v_correction := api.correct_transaction(
+            v_transaction.uuid,
+            v_transaction.type,
+            v_transaction.account_uuid,
+            v_category_uuid,
+            v_transaction.amount,
+            coalesce(v_rule.set_description, v_transaction.description),
+            v_transaction.date,
+            'Category rule: ' || v_rule.name
+        );
+
+        update data.transactions t
+           set metadata = coalesce(v_transaction.metadata, '{}'::jsonb) || utils.category_rule_metadata(v_rule)
+         where t.uuid = v_correction;
+
+        return query select v_transaction.uuid, v_correction, v_rule.uuid, coalesce(v_category_uuid, v_unassigned.uuid);
+    end loop;
+end;
+$$ language plpgsql security definer;
+
+-- record a transaction. without a category, the first matching rule of
+-- the ledger may choose one, rewrite the description and add tags
+create or replace function utils.add_transaction(
+    p_ledger_uuid text,
+    p_date timestamptz,
+    p_description text,
+    p_type text,
+    p_amount bigint,
+    p_account_uuid text,
+    p_category_uuid text = null,
+    p_user_data text = utils.get_user()
+) returns int as
+$$
+declare
+    v_ledger_id             int;
+    v_account_id            int;
+    v_account_internal_type text;
+    v_category_id           int;
+    v_transaction_id        int;
+    v_debit_account_id      int;
+    v_credit_account_id     int;
+    v_cleaned_description   text;
+    v_rule                  data.category_rules;
+    v_metadata              jsonb;
+begin
+    -- validate transaction data
+    perform utils.validate_transaction_data(p_amount, p_date, p_type);
+
+    -- validate and clean description
+    v_cleaned_description := coalesce(trim(p_description), '');
+    if char_length(v_cleaned_description) > 500 then
+        raise exception 'Transaction description cannot exceed 500 characters. Current length: %',
+            char_length(v_cleaned_description)
+            using errcode = 'PB013';
+    end if;
+
+    -- find the ledger_id from uuid and validate ownership
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    -- find the account_id and internal_type in one query
+    select a.id, a.internal_type
+      into v_account_id, v_account_internal_type
+      from data.accounts a
+     where a.uuid = p_account_uuid
+       and a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found in ledger % for current user',
+                       p_account_uuid, p_ledger_uuid
+            using errcode = 'PB002';
+    end if;
+
+    -- transactions without a category go through the rules of the ledger
+    if p_category_uuid is null then
+        v_rule := utils.match_category_rule(
+            v_ledger_id, v_account_id, v_cleaned_description, p_amount, p_date::date, p_user_data
+        );
+        if v_rule.id is not null then
+            v_category_id := v_rule.category_id;
+            v_cleaned_description := coalesce(v_rule.set_description, v_cleaned_description);
+            v_metadata := utils.category_rule_metadata(v_rule);
+        end if;
+    end if;
+
+    -- handle category lookup
+    if v_category_id is not null then
+        -- a rule chose the category
+        null;
+    elsif p_category_uuid is null then
+        -- find the "Unassigned" category directly
+        select a.id into v_category_id
+          from data.accounts a
+         where a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.name = 'Unassigned'
+           and a.type = 'equity';
+
+        if v_category_id is null then
+            raise exception 'Default "Unassigned" category not found in ledger %. This indicates a system error.',
+                p_ledger_uuid
+                using errcode = 'PB003';
+        end if;
+    else
+        -- find the category by UUID
+        select a.id into v_category_id
+          from data.accounts a
+         where a.uuid = p_category_uuid
+           and a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.type = 'equity';
+
+        if v_category_id is null then
+            raise exception 'Category with UUID % not found in ledger % for current user',
+                           p_category_uuid, p_ledger_uuid
+                using errcode = 'PB003';
+        end if;
+    end if;
+
+    -- validate account type and transaction type combination
+    if (v_account_internal_type = 'asset_like' and p_type = 'outflow') or
+       (v_account_internal_type = 'liability_like' and p_type = 'inflow') then
+        -- debit category, credit account
+        v_debit_account_id := v_category_id;
+        v_credit_account_id := v_account_id;
+    elsif (v_account_internal_type = 'asset_like' and p_type = 'inflow') or
+          (v_account_internal_type = 'liability_like' and p_type = 'outflow') then
+        -- debit account, credit category
+        v_debit_account_id := v_account_id;
+        v_credit_account_id := v_category_id;
+    else
+        raise exception 'Invalid combination: account type "%" with transaction type "%". Please verify your account and transaction types.',
+            v_account_internal_type, p_type
+            using errcode = 'PB012';
+    end if;
+
+    -- create the transaction
+    begin
+        insert into data.transactions (
+            ledger_id, description, date, amount,
+            debit_account_id, credit_account_id, metadata, user_data
+        )
+        values (
+            v_ledger_id, v_cleaned_description, p_date, p_amount,
+            v_debit_account_id, v_credit_account_id, v_metadata, p_user_data
+        )
+        returning id into v_transaction_id;
+    exception
+        when unique_violation then
+            raise exception using
+                message = utils.handle_constraint_violation('transactions_uuid_unique', 'transactions'),
+                errcode = 'unique_violation';
+        when foreign_key_violation then
+            raise exception 'Invalid account reference in transaction. Please verify all accounts exist.'
+                using errcode = 'PB002';
+        when check_violation then
+            raise exception 'Transaction violates business rules. Please check amount and account constraints.'
+                using errcode = 'PB010';
+    end;
+
+    return v_transaction_id;
+end;
+$$ language plpgsql security definer;
+
+-- public api function to add a rule to a ledger, returning its uuid
+create or replace function api.add_category_rule(
+    p_ledger_uuid text,
+    p_name text,
+    p_category_uuid text default null, -- the category to assign
+    p_description_pattern text default null, -- case-insensitive regular expression
+    p_min_amount bigint default null,
+    p_max_amount bigint default null,
+    p_account_uuid text default null, -- the bank account or credit card
+    p_weekdays int[] default null, -- ISO weekdays, 1 (Monday) to 7 (Sunday)
+    p_set_description text default null, -- the description to record instead
+    p_tags text[] default null, -- added to metadata
+    p_priority int default 0 -- lower priorities are tried first
+) returns text as $$
+begin
+    return utils.add_category_rule(
+        p_ledger_uuid, p_name, p_category_uuid, p_description_pattern, p_min_amount, p_max_amount,
+        p_account_uuid, p_weekdays, p_set_description, p_tags, p_priority
+    );
+end;
+$$ language plpgsql security definer;
+
+-- public api function to remove a rule
+create or replace function api.delete_category_rule(
+    p_rule_uuid text
+) returns void as $$
+begin
+    perform utils.delete_category_rule(p_rule_uuid);
+end;
+$$ language plpgsql security definer;
+
+-- public api function listing the rules of a ledger in the order they are tried
+create or replace function api.get_category_rules(
+    p_ledger_uuid text
+) returns table (
+    uuid text,
+    name text,
+    priority int,
+    description_pattern text,
+    min_amount bigint,
+    max_amount bigint,
+    account_uuid text,
+    weekdays int[],
+    category_uuid text,
+    category_name text,
+    set_description text,
+    tags text[]
+) as $$
+begin
+    return query
+    select * from utils.get_category_rules(p_ledger_uuid);
+end;
+$$ language plpgsql stable security invoker;
+
+-- public api function running the rules over the Unassigned transactions of
+-- a ledger, returning every correction made
+create or replace function api.apply_category_rules(
+    p_ledger_uuid text
+) returns table (
+    transaction_uuid text,
+    correction_uuid text,
+    rule_uuid text,
+    category_uuid text
+) as $$
+begin
+    return query
+    select * from utils.apply_category_rules(p_ledger_uuid);
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- restore add_transaction without the rules
+create or replace function utils.add_transaction(
+    p_ledger_uuid text,
+    p_date timestamptz,
+    p_description text,
+    p_type text,
+    p_amount bigint,
+    p_account_uuid text,
+    p_category_uuid text = null,
+    p_user_data text = utils.get_user()
+) returns int as
+$$
+declare
+    v_ledger_id             int;
+    v_account_id            int;
+    v_account_internal_type text;
+    v_category_id           int;
+    v_transaction_id        int;
+    v_debit_account_id      int;
+    v_credit_account_id     int;
+    v_cleaned_description   text;
+begin
+    -- validate transaction data
+    perform utils.validate_transaction_data(p_amount, p_date, p_type);
+
+    -- validate and clean description
+    v_cleaned_description := coalesce(trim(p_description), '');
+    if char_length(v_cleaned_description) > 500 then
+        raise exception 'Transaction description cannot exceed 500 characters. Current length: %',
+            char_length(v_cleaned_description)
+            using errcode = 'PB013';
+    end if;
+
+    -- find the ledger_id from uuid and validate ownership
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    -- find the account_id and internal_type in one query
+    select a.id, a.internal_type
+      into v_account_id, v_account_internal_type
+      from data.accounts a
+     where a.uuid = p_account_uuid
+       and a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found in ledger % for current user',
+                       p_account_uuid, p_ledger_uuid
+            using errcode = 'PB002';
+    end if;
+
+    -- handle category lookup
+    if p_category_uuid is null then
+        -- find the "Unassigned" category directly
+        select a.id into v_category_id
+          from data.accounts a
+         where a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.name = 'Unassigned'
+           and a.type = 'equity';
+
+        if v_category_id is null then
+            raise exception 'Default "Unassigned" category not found in ledger %. This indicates a system error.',
+                p_ledger_uuid
+                using errcode = 'PB003';
+        end if;
+    else
+        -- find the category by UUID
+        select a.id into v_category_id
+          from data.accounts a
+         where a.uuid = p_category_uuid
+           and a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.type = 'equity';
+
+        if v_category_id is null then
+            raise exception 'Category with UUID % not found in ledger % for current user',
+                           p_category_uuid, p_ledger_uuid
+                using errcode = 'PB003';
+        end if;
+    end if;
+
+    -- validate account type and transaction type combination
+    if (v_account_internal_type = 'asset_like' and p_type = 'outflow') or
+       (v_account_internal_type = 'liability_like' and p_type = 'inflow') then
+        -- debit category, credit account
+        v_debit_account_id := v_category_id;
+        v_credit_account_id := v_account_id;
+    elsif (v_account_internal_type = 'asset_like' and p_type = 'inflow') or
+          (v_account_internal_type = 'liability_like' and p_type = 'outflow') then
+        -- debit account, credit category
+        v_debit_account_id := v_account_id;
+        v_credit_account_id := v_category_id;
+    else
+        raise exception 'Invalid combination: account type "%" with transaction type "%". Please verify your account and transaction types.',
+            v_account_internal_type, p_type
+            using errcode = 'PB012';
+    end if;
+
+    -- create the transaction
+    begin
+        insert into data.transactions (
+            ledger_id, description, date, amount,
+            debit_account_id, credit_account_id, user_data
+        )
+        values (
+            v_ledger_id, v_cleaned_description, p_date, p_amount,
+            v_debit_account_id, v_credit_account_id, p_user_data
+        )
+        returning id into v_transaction_id;
+    exception
+        when unique_violation then
+            raise exception using
+                message = utils.handle_constraint_violation('transactions_uuid_unique', 'transactions'),
+                errcode = 'unique_violation';
+        when foreign_key_violation then
+            raise exception 'Invalid account reference in transaction. Please verify all accounts exist.'
+                using errcode = 'PB002';
+        when check_violation then
+            raise exception 'Transaction violates business rules. Please check amount and account constraints.'
+                using errcode = 'PB010';
+    end;
+
+    return v_transaction_id;
+end;
+$$ language plpgsql security definer;
+
+drop function if exists api.apply_category_rules(text);
+drop function if exists api.get_category_rules(text);
+drop function if exists api.delete_category_rule(text);
+drop function if exists api.add_category_rule(text, text, text, text, bigint, bigint, text, int[], text, text[], int);
+drop function if exists utils.apply_category_rules(text, text);
+drop function if exists utils.get_category_rules(text, text);
+drop function if exists utils.category_rule_metadata(data.category_rules);
+drop function if exists utils.match_category_rule(bigint, bigint, text, bigint, date, text);
+drop function if exists utils.delete_category_rule(text, text);
+drop function if exists utils.add_category_rule(text, text, text, text, bigint, bigint, text, int[], text, text[], int, text);
+drop table if exists data.category_rules;
+
+-- +goose StatementEnd
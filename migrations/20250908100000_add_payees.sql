@@ -0,0 +1,1137 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- a payee is who a transaction was paid to or received from. names are
+-- unique per ledger regardless of case; default_category_id is the category
+-- the payee was last recorded with, used when a transaction names the payee
+-- but no category
+create table data.payees
+(
+    id                  bigint generated always as identity primary key,
+    uuid                text        not null default utils.nanoid(8),
+    created_at          timestamptz not null default current_timestamp,
+    updated_at          timestamptz not null default current_timestamp,
+
+    name                text        not null,
+    default_category_id bigint references data.accounts (id) on delete set null,
+
+    ledger_id           bigint      not null references data.ledgers (id) on delete cascade,
+    user_data           text        not null default utils.get_user(),
+
+    constraint payees_uuid_unique unique (uuid),
+    constraint payees_name_length_check check (char_length(name) <= 255),
+    constraint payees_user_data_length_check check (char_length(user_data) < 255)
+);
+
+create unique index payees_name_ledger_unique on data.payees (ledger_id, lower(name));
+
+-- enable RLS
+alter table data.payees
+    enable row level security;
+
+create policy payees_policy on data.payees
+    using (user_data = utils.get_user())
+    with check (user_data = utils.get_user());
+
+-- the payee of a transaction, if any
+alter table data.transactions
+    add column payee_id bigint references data.payees (id) on delete set null;
+
+create index idx_transactions_payee_id on data.transactions (payee_id)
+    where payee_id is not null;
+
+-- turn a bank description into a payee name: card processor prefixes, store
+-- numbers and trailing reference numbers are dropped and all-caps names are
+-- capitalized, so "SQ *BLUE BOTTLE #0123" becomes "Blue Bottle". null when
+-- nothing is left
+create or replace function utils.normalize_payee_name(
+    p_description text
+) returns text as
+$$
+declare
+    v_name text;
+begin
+    v_name := regexp_replace(
+        coalesce(p_description, ''),
+        '^\s*((pos|checkcard|debit card purchase)\M|sq \*|tst\*|paypal \*)\s*', '', 'i'
+    );
+    -- characters payee names cannot hold
+    v_name := regexp_replace(v_name, '[<>"\\/*]', ' ', 'g');
+    v_name := regexp_replace(v_name, '\s*#\s*\d.*$', '');
+    v_name := regexp_replace(v_name, '(\s+[\d-]{3,})+\s*$', '');
+    v_name := regexp_replace(trim(v_name), '\s+', ' ', 'g');
+
+    if v_name = upper(v_name) then
+        v_name := regexp_replace(initcap(v_name), '''S\M', '''s', 'g');
+    end if;
+
+    return nullif(left(v_name, 255), '');
+end;
+$$ language plpgsql immutable;
+
+-- the payee of a ledger with the given name, regardless of case; it is
+-- created when there is none
+create or replace function utils.find_or_create_payee(
+    p_ledger_id bigint,
+    p_name text,
+    p_user_data text = utils.get_user()
+) returns data.payees as
+$$
+declare
+    v_name  text;
+    v_payee data.payees;
+begin
+    v_name := regexp_replace(utils.validate_input_data(p_name, null, 'payee'), '\s+', ' ', 'g');
+
+    insert into data.payees (name, ledger_id, user_data)
+    values (v_name, p_ledger_id, p_user_data)
+    on conflict (ledger_id, lower(name)) do nothing;
+
+    select p.* into v_payee
+      from data.payees p
+     where p.ledger_id = p_ledger_id
+       and p.user_data = p_user_data
+       and lower(p.name) = lower(v_name);
+
+    return v_payee;
+end;
+$$ language plpgsql security definer;
+
+-- a payee of the current user, locked for update
+create or replace function utils.get_payee(
+    p_payee_uuid text,
+    p_user_data text = utils.get_user()
+) returns data.payees as
+$$
+declare
+    v_payee data.payees;
+begin
+    select p.* into v_payee
+      from data.payees p
+     where p.uuid = p_payee_uuid
+       and p.user_data = p_user_data
+       for update;
+
+    if v_payee.id is null then
+        raise exception 'Payee with UUID % not found for current user', p_payee_uuid
+            using errcode = 'PB008';
+    end if;
+
+    return v_payee;
+end;
+$$ language plpgsql security definer;
+
+-- the id of a category of the ledger, or null when p_category_uuid is null
+create or replace function utils.get_payee_category_id(
+    p_ledger_id bigint,
+    p_category_uuid text,
+    p_user_data text = utils.get_user()
+) returns bigint as
+$$
+declare
+    v_category_id bigint;
+begin
+    if p_category_uuid is null then
+        return null;
+    end if;
+
+    select a.id into v_category_id
+      from data.accounts a
+     where a.uuid = p_category_uuid
+       and a.ledger_id = p_ledger_id
+       and a.user_data = p_user_data
+       and a.type = 'equity';
+
+    if v_category_id is null then
+        raise exception 'Category with UUID % not found for current user', p_category_uuid
+            using errcode = 'PB003';
+    end if;
+
+    return v_category_id;
+end;
+$$ language plpgsql stable security definer;
+
+-- add a payee to a ledger, returning its uuid
+create or replace function utils.create_payee(
+    p_ledger_uuid text,
+    p_name text,
+    p_default_category_uuid text = null,
+    p_user_data text = utils.get_user()
+) returns text as
+$$
+declare
+    v_ledger_id   bigint;
+    v_category_id bigint;
+    v_name        text;
+    v_payee_uuid  text;
+begin
+    v_name := regexp_replace(utils.validate_input_data(p_name, null, 'payee'), '\s+', ' ', 'g');
+
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    v_category_id := utils.get_payee_category_id(v_ledger_id, p_default_category_uuid, p_user_data);
+
+    begin
+        insert into data.payees (name, default_category_id, ledger_id, user_data)
+        values (v_name, v_category_id, v_ledger_id, p_user_data)
+        returning uuid into v_payee_uuid;
+    exception
+        when unique_violation then
+            raise exception 'A payee named % already exists in this ledger', v_name
+                using errcode = 'unique_violation';
+    end;
+
+    return v_payee_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- rename a payee. to combine two payees, merge them instead
+create or replace function utils.rename_payee(
+    p_payee_uuid text,
+    p_name text,
+    p_user_data text = utils.get_user()
+) returns void as
+$$
+declare
+    v_payee data.payees;
+    v_name  text;
+begin
+    v_name := regexp_replace(utils.validate_input_data(p_name, null, 'payee'), '\s+', ' ', 'g');
+    v_payee := utils.get_payee(p_payee_uuid, p_user_data);
+
+    begin
+        update data.payees p
+           set name = v_name,
+               updated_at = current_timestamp
+         where p.id = v_payee.id;
+    exception
+        when unique_violation then
+            raise exception 'A payee named % already exists in this ledger. Merge the payees instead.', v_name
+                using errcode = 'unique_violation';
+    end;
+end;
+$$ language plpgsql security definer;
+
+-- set or, with a null category, clear the default category of a payee
+create or replace function utils.set_payee_category(
+    p_payee_uuid text,
+    p_category_uuid text,
+    p_user_data text = utils.get_user()
+) returns void as
+$$
+declare
+    v_payee data.payees;
+begin
+    v_payee := utils.get_payee(p_payee_uuid, p_user_data);
+
+    update data.payees p
+       set default_category_id = utils.get_payee_category_id(v_payee.ledger_id, p_category_uuid, p_user_data),
+           updated_at = current_timestamp
+     where p.id = v_payee.id;
+end;
+$$ language plpgsql security definer;
+
+-- merge a payee into another of the same ledger: its transactions move to
+-- the target, which keeps its default category or takes the source's, and
+-- the source is deleted. returns the number of transactions moved
+create or replace function utils.merge_payees(
+    p_source_uuid text,
+    p_target_uuid text,
+    p_user_data text = utils.get_user()
+) returns int as
+$$
+declare
+    v_source data.payees;
+    v_target data.payees;
+    v_moved  int;
+begin
+    v_source := utils.get_payee(p_source_uuid, p_user_data);
+    v_target := utils.get_payee(p_target_uuid, p_user_data);
+
+    if v_source.ledger_id <> v_target.ledger_id then
+        raise exception 'Payee with UUID % not found in the ledger of payee %', p_target_uuid, v_source.name
+            using errcode = 'PB008';
+    end if;
+
+    if v_source.id = v_target.id then
+        raise exception 'Cannot merge payee % into itself', v_source.name
+            using errcode = 'PB013';
+    end if;
+
+    update data.transactions t
+       set payee_id = v_target.id
+     where t.payee_id = v_source.id;
+    get diagnostics v_moved = row_count;
+
+    update data.payees p
+       set default_category_id = coalesce(p.default_category_id, v_source.default_category_id),
+           updated_at = current_timestamp
+     where p.id = v_target.id;
+
+    delete from data.payees p where p.id = v_source.id;
+
+    return v_moved;
+end;
+$$ language plpgsql security definer;
+
+-- the payees of a ledger by name, with the totals of their transactions.
+-- outflow and inflow are seen from the bank account or credit card side;
+-- corrected and deleted transactions count through their corrections only
+create or replace function utils.get_payees(
+    p_ledger_uuid text,
+    p_user_data text = utils.get_user()
+)
+returns table (
+    uuid text,
+    name text,
+    default_category_uuid text,
+    default_category_name text,
+    transactions bigint,
+    outflow bigint,
+    inflow bigint,
+    last_date date
+) as $$
+declare
+    v_ledger_id bigint;
+begin
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    return query
+    with live as (
+        select
+            t.payee_id,
+            t.amount,
+            t.date,
+            case
+                when (o.internal_type = 'asset_like' and t.debit_account_id = o.id) or
+                     (o.internal_type = 'liability_like' and t.credit_account_id = o.id)
+                then 'inflow'
+                else 'outflow'
+            end as type
+        from
+            data.transactions t
+            join data.accounts o on o.id in (t.debit_account_id, t.credit_account_id)
+                                and o.type in ('asset', 'liability')
+        where
+            t.ledger_id = v_ledger_id
+            and t.user_data = p_user_data
+            and t.payee_id is not null
+            and t.deleted_at is null
+            and not exists (
+                select 1
+                from data.transaction_log tl
+                where t.id in (tl.original_transaction_id, tl.reversal_transaction_id)
+            )
+    )
+    select
+        p.uuid,
+        p.name,
+        c.uuid as default_category_uuid,
+        c.name as default_category_name,
+        count(l.payee_id) as transactions,
+        coalesce(sum(l.amount) filter (where l.type = 'outflow'), 0)::bigint as outflow,
+        coalesce(sum(l.amount) filter (where l.type = 'inflow'), 0)::bigint as inflow,
+        max(l.date) as last_date
+    from
+        data.payees p
+        left join data.accounts c on c.id = p.default_category_id
+        left join live l on l.payee_id = p.id
+    where
+        p.ledger_id = v_ledger_id
+        and p.user_data = p_user_data
+    group by
+        p.id,
+        c.id
+    order by
+        lower(p.name);
+end;
+$$ language plpgsql stable security definer;
+
+-- give the transactions of a ledger recorded without a payee the payee their
+-- normalized description names, creating the payees missing. transfers,
+-- assignments, split legs, reversals and reconciliation adjustments are left
+-- alone. payees without a default category take the latest category other
+-- than Unassigned they were recorded with. returns the number of
+-- transactions linked
+create or replace function utils.backfill_payees(
+    p_ledger_id bigint,
+    p_user_data text = utils.get_user()
+) returns int as
+$$
+declare
+    v_linked int;
+begin
+    create temporary table payee_backfill as
+    select
+        t.id,
+        t.date,
+        c.id as category_id,
+        c.name as category_name,
+        utils.normalize_payee_name(t.description) as payee_name
+    from
+        data.transactions t
+        join data.accounts o on o.id in (t.debit_account_id, t.credit_account_id)
+                            and o.type in ('asset', 'liability')
+        join data.accounts c on c.id in (t.debit_account_id, t.credit_account_id)
+                            and c.type = 'equity'
+    where
+        t.ledger_id = p_ledger_id
+        and t.user_data = p_user_data
+        and t.payee_id is null
+        and t.split_id is null
+        and not exists (
+            select 1 from data.transaction_log tl where tl.reversal_transaction_id = t.id
+        )
+        and not exists (
+            select 1 from data.reconciliations r where r.adjustment_transaction_id = t.id
+        );
+
+    delete from payee_backfill b where b.payee_name is null;
+
+    insert into data.payees (name, ledger_id, user_data)
+    select distinct on (lower(b.payee_name)) b.payee_name, p_ledger_id, p_user_data
+      from payee_backfill b
+     order by lower(b.payee_name), b.payee_name
+    on conflict (ledger_id, lower(name)) do nothing;
+
+    update data.transactions t
+       set payee_id = p.id
+      from payee_backfill b
+           join data.payees p on p.ledger_id = p_ledger_id
+                             and p.user_data = p_user_data
+                             and lower(p.name) = lower(b.payee_name)
+     where t.id = b.id;
+    get diagnostics v_linked = row_count;
+
+    update data.payees p
+       set default_category_id = (
+               select b.category_id
+                 from payee_backfill b
+                where lower(b.payee_name) = lower(p.name)
+                  and b.category_name <> 'Unassigned'
+                order by b.date desc, b.id desc
+                limit 1
+           )
+     where p.ledger_id = p_ledger_id
+       and p.user_data = p_user_data
+       and p.default_category_id is null;
+
+    drop table payee_backfill;
+
+    return v_linked;
+end;
+$$ language plpgsql security definer;
+
+-- link the transactions recorded so far to payees
+do
+$$
+declare
+    v_ledger data.ledgers;
+begin
+    for v_ledger in select l.* from data.ledgers l
+    loop
+        perform utils.backfill_payees(v_ledger.id, v_ledger.user_data);
+    end loop;
+end;
+$$;
+
+-- api.add_transaction and api.correct_transaction gain a payee; the
+-- signatures change, so the functions are dropped and recreated
+drop function if exists api.add_transaction(text, date, text, text, bigint, text, text);
+drop function if exists api.correct_transaction(text, text, text, text, bigint, text, date, text, boolean);
+
+-- public api function to add a transaction. p_payee names the payee, which
+-- is created when the ledger has none by that name. without a category the
+-- payee's default category is used; with one, the payee remembers it
+create or replace function api.add_transaction(
+    p_ledger_uuid text,
+    p_date date,
+    p_description text,
+    p_type text, -- 'inflow' or 'outflow'
+    p_amount bigint,
+    p_account_uuid text, -- the bank account or credit card
+    p_category_uuid text default null, -- the category, optional
+    p_payee text default null -- the payee name, optional
+) returns text as $$
+declare
+    v_ledger_id        bigint;
+    v_payee            data.payees;
+    v_category_uuid    text;
+    v_transaction_id   int;
+    v_transaction_uuid text;
+begin
+    -- validate transaction type
+    if p_type not in ('inflow', 'outflow') then
+        raise exception 'Invalid transaction type: %. Must be "inflow" or "outflow"', p_type
+            using errcode = 'PB012';
+    end if;
+
+    v_category_uuid := p_category_uuid;
+
+    if p_payee is not null then
+        select l.id into v_ledger_id
+          from data.ledgers l
+         where l.uuid = p_ledger_uuid
+           and l.user_data = utils.get_user();
+
+        if v_ledger_id is null then
+            raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+                using errcode = 'PB001';
+        end if;
+
+        v_payee := utils.find_or_create_payee(v_ledger_id, p_payee);
+
+        if v_category_uuid is null then
+            select a.uuid into v_category_uuid
+              from data.accounts a
+             where a.id = v_payee.default_category_id;
+        end if;
+    end if;
+
+    -- call the utils function
+    select utils.add_transaction(
+        p_ledger_uuid,
+        p_date::timestamptz,
+        p_description,
+        p_type,
+        p_amount,
+        p_account_uuid,
+        v_category_uuid
+    ) into v_transaction_id;
+
+    if v_payee.id is not null then
+        update data.transactions t
+           set payee_id = v_payee.id
+         where t.id = v_transaction_id;
+
+        -- the payee remembers the category it was given
+        if p_category_uuid is not null then
+            update data.payees p
+               set default_category_id = utils.get_payee_category_id(v_ledger_id, p_category_uuid),
+                   updated_at = current_timestamp
+             where p.id = v_payee.id;
+        end if;
+    end if;
+
+    -- get the uuid of the created transaction
+    select uuid into v_transaction_uuid
+    from data.transactions
+    where id = v_transaction_id;
+
+    return v_transaction_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- a leg only changes together with its split: correcting it alone would
+-- take it out of the split. the correction keeps the payee of the original
+-- unless p_payee names another, whose default category is used when no
+-- category is given
+create or replace function api.correct_transaction(
+    p_original_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_category_uuid text,
+    p_new_amount bigint,
+    p_new_description text,
+    p_new_date date,
+    p_reason text default 'Transaction correction',
+    p_override boolean default false, -- change reconciled transactions too
+    p_payee text default null -- the payee name, optional
+) returns text as $$
+declare
+    v_split_uuid text;
+    v_original data.transactions;
+    v_payee data.payees;
+    v_category_uuid text;
+    v_correction_id int;
+    v_correction_uuid text;
+begin
+    -- reconciled transactions are locked
+    if not p_override then
+        perform utils.assert_not_reconciled(p_original_uuid);
+    end if;
+
+    -- find the split the uuid names, or the split of the leg it names
+    select s.uuid into v_split_uuid
+    from data.split_transactions s
+    where s.user_data = utils.get_user()
+      and (
+          s.uuid = p_original_uuid
+          or s.id = (select t.split_id from data.transactions t where t.uuid = p_original_uuid)
+      );
+
+    if v_split_uuid is not null then
+        raise exception 'Transaction % is part of split transaction %. Use api.correct_split_transaction to change its splits.',
+            p_original_uuid, v_split_uuid
+            using errcode = 'PB013';
+    end if;
+
+    select t.* into v_original
+    from data.transactions t
+    where t.uuid = p_original_uuid
+      and t.user_data = utils.get_user();
+
+    v_category_uuid := p_new_category_uuid;
+
+    if p_payee is not null and v_original.id is not null then
+        v_payee := utils.find_or_create_payee(v_original.ledger_id, p_payee);
+
+        if v_category_uuid is null then
+            select a.uuid into v_category_uuid
+            from data.accounts a
+            where a.id = v_payee.default_category_id;
+        end if;
+    end if;
+
+    -- call utils function to do all the work
+    select utils.correct_transaction(
+        p_original_uuid,
+        p_new_type,
+        p_new_account_uuid,
+        v_category_uuid,
+        p_new_amount,
+        p_new_description,
+        p_new_date,
+        p_reason
+    ) into v_correction_id;
+
+    -- the reversal keeps the payee of the original
+    if coalesce(v_payee.id, v_original.payee_id) is not null then
+        update data.transactions t
+        set payee_id = case when t.id = v_correction_id then coalesce(v_payee.id, v_original.payee_id) else v_original.payee_id end
+        where t.id = v_correction_id
+           or t.id = (
+               select tl.reversal_transaction_id
+               from data.transaction_log tl
+               where tl.correction_transaction_id = v_correction_id
+           );
+    end if;
+
+    -- the payee remembers the category it was given
+    if v_payee.id is not null and p_new_category_uuid is not null then
+        update data.payees p
+        set default_category_id = utils.get_payee_category_id(v_original.ledger_id, p_new_category_uuid),
+            updated_at = current_timestamp
+        where p.id = v_payee.id;
+    end if;
+
+    -- get the uuid of the corrected transaction
+    select uuid into v_correction_uuid
+    from data.transactions
+    where id = v_correction_id;
+
+    return v_correction_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- the account history gains a payee column
+drop function if exists api.get_account_transactions(text, text);
+drop function if exists utils.get_account_transactions(text, text, text);
+
+-- account history with running balances. the legs of a split are shown as
+-- one row carrying the uuid of the split; on the bank side its category lists
+-- every category of the split and category_uuid is null. p_status keeps only
+-- 'pending' or 'posted' transactions; reconciled marks the transactions locked
+-- by a reconciliation and payee names the payee of the transaction
+create or replace function utils.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean,
+    payee text
+) as $$
+declare
+    v_account_id bigint;
+    v_internal_type text;
+begin
+    if p_status is not null and p_status not in ('pending', 'posted') then
+        raise exception 'Invalid transaction status: %. Must be "pending" or "posted"', p_status
+            using errcode = 'PB013';
+    end if;
+
+    -- resolve the account uuid to its internal id and validate ownership
+    select a.id, a.internal_type
+    into v_account_id, v_internal_type
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return query
+    with legs as (
+        select
+            t.id,
+            t.date,
+            t.created_at,
+            t.description,
+            t.amount,
+            t.uuid,
+            t.split_id,
+            t.status,
+            t.reconciliation_id,
+            o.name as other_name,
+            p.name as payee_name,
+            o.uuid as other_uuid,
+            -- determine transaction type based on account's internal type
+            case
+                when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                     (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+                then 'inflow'
+                else 'outflow'
+            end as type
+        from
+            data.transactions t
+            join data.accounts o on o.id = case
+                when t.debit_account_id = v_account_id then t.credit_account_id
+                else t.debit_account_id
+            end
+            left join data.payees p on p.id = t.payee_id
+        where
+            (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+            and t.deleted_at is null
+            and (p_status is null or t.status = p_status)
+    )
+    select
+        min(l.date) as date,
+        string_agg(distinct l.other_name, ', ' order by l.other_name) as category,
+        -- a single leg keeps its memo, several legs show the split
+        case when count(*) = 1 then min(l.description) else s.description end as description,
+        min(l.type) as type,
+        sum(l.amount)::bigint as amount,
+        -- running balance from the balance snapshot of the last leg
+        coalesce(
+            (
+                select bs.balance
+                from data.balance_snapshots bs
+                where bs.transaction_id = max(l.id)
+                  and bs.account_id = v_account_id
+                  and bs.user_data = p_user_data
+            ), 0
+        ) as running_balance,
+        coalesce(s.uuid, min(l.uuid)) as uuid,
+        case when count(distinct l.other_uuid) = 1 then min(l.other_uuid) end as category_uuid,
+        s.id is not null as split,
+        -- a split is pending while any of its splits is
+        case when bool_or(l.status = 'pending') then 'pending' else 'posted' end as status,
+        bool_or(l.reconciliation_id is not null) as reconciled,
+        min(l.payee_name) as payee
+    from
+        legs l
+        left join data.split_transactions s on s.id = l.split_id
+    group by
+        s.id,
+        case when s.id is null then l.id end
+    order by
+        min(l.date) desc,
+        max(l.created_at) desc,
+        max(l.id) desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- public api function, passes through to the utils function
+create or replace function api.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null -- 'pending' or 'posted'; all when null
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean,
+    payee text
+) as $$
+begin
+    return query
+    select * from utils.get_account_transactions(p_account_uuid, p_status);
+end;
+$$ language plpgsql stable security invoker;
+
+-- public api function to add a payee to a ledger, returning its uuid
+create or replace function api.create_payee(
+    p_ledger_uuid text,
+    p_name text,
+    p_default_category_uuid text default null -- used when a transaction names no category
+) returns text as $$
+begin
+    return utils.create_payee(p_ledger_uuid, p_name, p_default_category_uuid);
+end;
+$$ language plpgsql security definer;
+
+-- public api function to rename a payee
+create or replace function api.rename_payee(
+    p_payee_uuid text,
+    p_name text
+) returns void as $$
+begin
+    perform utils.rename_payee(p_payee_uuid, p_name);
+end;
+$$ language plpgsql security definer;
+
+-- public api function to set or clear the default category of a payee
+create or replace function api.set_payee_category(
+    p_payee_uuid text,
+    p_category_uuid text -- null clears it
+) returns void as $$
+begin
+    perform utils.set_payee_category(p_payee_uuid, p_category_uuid);
+end;
+$$ language plpgsql security definer;
+
+-- public api function to merge a payee into another, returning the number
+-- of transactions moved
+create or replace function api.merge_payees(
+    p_source_uuid text, -- deleted
+    p_target_uuid text -- kept
+) returns int as $$
+begin
+    return utils.merge_payees(p_source_uuid, p_target_uuid);
+end;
+$$ language plpgsql security definer;
+
+-- public api function listing the payees of a ledger with their totals
+create or replace function api.get_payees(
+    p_ledger_uuid text
+) returns table (
+    uuid text,
+    name text,
+    default_category_uuid text,
+    default_category_name text,
+    transactions bigint,
+    outflow bigint,
+    inflow bigint,
+    last_date date
+) as $$
+begin
+    return query
+    select * from utils.get_payees(p_ledger_uuid);
+end;
+$$ language plpgsql stable security invoker;
+
+-- public api function linking the transactions of a ledger recorded without
+-- a payee to the payee their description names, returning how many were
+-- linked
+create or replace function api.backfill_payees(
+    p_ledger_uuid text
+) returns int as $$
+declare
+    v_ledger_id bigint;
+begin
+    select l.id into v_ledger_id
+    from data.ledgers l
+    where l.uuid = p_ledger_uuid
+      and l.user_data = utils.get_user();
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    return utils.backfill_payees(v_ledger_id);
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop function if exists api.backfill_payees(text);
+drop function if exists api.get_payees(text);
+drop function if exists api.merge_payees(text, text);
+drop function if exists api.set_payee_category(text, text);
+drop function if exists api.rename_payee(text, text);
+drop function if exists api.create_payee(text, text, text);
+
+-- restore the account history without the payee column
+drop function if exists api.get_account_transactions(text, text);
+drop function if exists utils.get_account_transactions(text, text, text);
+
+-- account history with running balances. the legs of a split are shown as
+-- one row carrying the uuid of the split; on the bank side its category lists
+-- every category of the split and category_uuid is null. p_status keeps only
+-- 'pending' or 'posted' transactions; reconciled marks the transactions locked
+-- by a reconciliation
+create or replace function utils.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean
+) as $$
+declare
+    v_account_id bigint;
+    v_internal_type text;
+begin
+    if p_status is not null and p_status not in ('pending', 'posted') then
+        raise exception 'Invalid transaction status: %. Must be "pending" or "posted"', p_status
+            using errcode = 'PB013';
+    end if;
+
+    -- resolve the account uuid to its internal id and validate ownership
+    select a.id, a.internal_type
+    into v_account_id, v_internal_type
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return query
+    with legs as (
+        select
+            t.id,
+            t.date,
+            t.created_at,
+            t.description,
+            t.amount,
+            t.uuid,
+            t.split_id,
+            t.status,
+            t.reconciliation_id,
+            o.name as other_name,
+            o.uuid as other_uuid,
+            -- determine transaction type based on account's internal type
+            case
+                when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                     (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+                then 'inflow'
+                else 'outflow'
+            end as type
+        from
+            data.transactions t
+            join data.accounts o on o.id = case
+                when t.debit_account_id = v_account_id then t.credit_account_id
+                else t.debit_account_id
+            end
+        where
+            (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+            and t.deleted_at is null
+            and (p_status is null or t.status = p_status)
+    )
+    select
+        min(l.date) as date,
+        string_agg(distinct l.other_name, ', ' order by l.other_name) as category,
+        -- a single leg keeps its memo, several legs show the split
+        case when count(*) = 1 then min(l.description) else s.description end as description,
+        min(l.type) as type,
+        sum(l.amount)::bigint as amount,
+        -- running balance from the balance snapshot of the last leg
+        coalesce(
+            (
+                select bs.balance
+                from data.balance_snapshots bs
+                where bs.transaction_id = max(l.id)
+                  and bs.account_id = v_account_id
+                  and bs.user_data = p_user_data
+            ), 0
+        ) as running_balance,
+        coalesce(s.uuid, min(l.uuid)) as uuid,
+        case when count(distinct l.other_uuid) = 1 then min(l.other_uuid) end as category_uuid,
+        s.id is not null as split,
+        -- a split is pending while any of its splits is
+        case when bool_or(l.status = 'pending') then 'pending' else 'posted' end as status,
+        bool_or(l.reconciliation_id is not null) as reconciled
+    from
+        legs l
+        left join data.split_transactions s on s.id = l.split_id
+    group by
+        s.id,
+        case when s.id is null then l.id end
+    order by
+        min(l.date) desc,
+        max(l.created_at) desc,
+        max(l.id) desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- public api function, passes through to the utils function
+create or replace function api.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null -- 'pending' or 'posted'; all when null
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean
+) as $$
+begin
+    return query
+    select * from utils.get_account_transactions(p_account_uuid, p_status);
+end;
+$$ language plpgsql stable security invoker;
+
+-- restore api.add_transaction and api.correct_transaction without a payee
+drop function if exists api.add_transaction(text, date, text, text, bigint, text, text, text);
+drop function if exists api.correct_transaction(text, text, text, text, bigint, text, date, text, boolean, text);
+
+-- public api function to add a transaction with error codes
+create or replace function api.add_transaction(
+    p_ledger_uuid text,
+    p_date date,
+    p_description text,
+    p_type text, -- 'inflow' or 'outflow'
+    p_amount bigint,
+    p_account_uuid text, -- the bank account or credit card
+    p_category_uuid text default null -- the category, optional
+) returns text as $$
+declare
+    v_transaction_id int;
+    v_transaction_uuid text;
+begin
+    -- validate transaction type
+    if p_type not in ('inflow', 'outflow') then
+        raise exception 'Invalid transaction type: %. Must be "inflow" or "outflow"', p_type
+            using errcode = 'PB012';
+    end if;
+
+    -- call the utils function
+    select utils.add_transaction(
+        p_ledger_uuid,
+        p_date::timestamptz,
+        p_description,
+        p_type,
+        p_amount,
+        p_account_uuid,
+        p_category_uuid
+    ) into v_transaction_id;
+
+    -- get the uuid of the created transaction
+    select uuid into v_transaction_uuid
+    from data.transactions
+    where id = v_transaction_id;
+
+    return v_transaction_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- a leg only changes together with its split: correcting it alone would
+-- take it out of the split
+create or replace function api.correct_transaction(
+    p_original_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_category_uuid text,
+    p_new_amount bigint,
+    p_new_description text,
+    p_new_date date,
+    p_reason text default 'Transaction correction',
+    p_override boolean default false -- change reconciled transactions too
+) returns text as $$
+declare
+    v_split_uuid text;
+    v_correction_id int;
+    v_correction_uuid text;
+begin
+    -- reconciled transactions are locked
+    if not p_override then
+        perform utils.assert_not_reconciled(p_original_uuid);
+    end if;
+
+    -- find the split the uuid names, or the split of the leg it names
+    select s.uuid into v_split_uuid
+    from data.split_transactions s
+    where s.user_data = utils.get_user()
+      and (
+          s.uuid = p_original_uuid
+          or s.id = (select t.split_id from data.transactions t where t.uuid = p_original_uuid)
+      );
+
+    if v_split_uuid is not null then
+        raise exception 'Transaction % is part of split transaction %. Use api.correct_split_transaction to change its splits.',
+            p_original_uuid, v_split_uuid
+            using errcode = 'PB013';
+    end if;
+
+    -- call utils function to do all the work
+    select utils.correct_transaction(
+        p_original_uuid,
+        p_new_type,
+        p_new_account_uuid,
+        p_new_category_uuid,
+        p_new_amount,
+        p_new_description,
+        p_new_date,
+        p_reason
+    ) into v_correction_id;
+
+    -- get the uuid of the corrected transaction
+    select uuid into v_correction_uuid
+    from data.transactions
+    where id = v_correction_id;
+
+    return v_correction_uuid;
+end;
+$$ language plpgsql security definer;
+
+drop function if exists utils.backfill_payees(bigint, text);
+drop function if exists utils.get_payees(text, text);
+drop function if exists utils.merge_payees(text, text, text);
+drop function if exists utils.set_payee_category(text, text, text);
+drop function if exists utils.rename_payee(text, text, text);
+drop function if exists utils.create_payee(text, text, text, text);
+drop function if exists utils.get_payee_category_id(bigint, text, text);
+drop function if exists utils.get_payee(text, text);
+drop function if exists utils.find_or_create_payee(bigint, text, text);
+drop function if exists utils.normalize_payee_name(text);
+
+alter table data.transactions drop column if exists payee_id;
+drop table if exists data.payees;
+
+-- +goose StatementEnd
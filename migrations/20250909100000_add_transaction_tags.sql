@@ -0,0 +1,946 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- tags label transactions independently of their category. they live in
+-- the metadata of the transaction as a json array, where category rules
+-- already put theirs; the legs of a split carry the tags of the split
+create index idx_transactions_tags on data.transactions using gin ((metadata -> 'tags'))
+    where metadata ? 'tags';
+
+-- validate tags and turn them into their stored form: trimmed, lowercase,
+-- sorted and without duplicates. an empty array when p_tags is null
+create or replace function utils.normalize_tags(
+    p_tags text[]
+) returns text[] as
+$$
+declare
+    v_tag  text;
+    v_tags text[] := '{}';
+begin
+    foreach v_tag in array coalesce(p_tags, '{}')
+    loop
+        v_tag := lower(trim(coalesce(v_tag, '')));
+
+        if v_tag = '' then
+            raise exception 'Tags cannot be empty'
+                using errcode = 'PB013';
+        end if;
+        if char_length(v_tag) > 50 then
+            raise exception 'Tag % cannot exceed 50 characters. Current length: %', v_tag, char_length(v_tag)
+                using errcode = 'PB013';
+        end if;
+        if v_tag ~ '[\s,]' then
+            raise exception 'Tag "%" cannot contain spaces or commas', v_tag
+                using errcode = 'PB013';
+        end if;
+
+        v_tags := v_tags || v_tag;
+    end loop;
+
+    return array(select distinct t from unnest(v_tags) t order by t);
+end;
+$$ language plpgsql immutable;
+
+-- the tags of one or more transactions from a json array of their tag
+-- arrays, e.g. jsonb_agg(metadata -> 'tags') over the legs of a split:
+-- lowercase, sorted and without duplicates
+create or replace function utils.tag_union(
+    p_tag_lists jsonb
+) returns text[] as
+$$
+    select coalesce(array_agg(distinct lower(e.tag) order by lower(e.tag)), '{}')
+      from jsonb_array_elements(coalesce(p_tag_lists, '[]'::jsonb)) l(tags)
+           cross join lateral jsonb_array_elements_text(
+               case when jsonb_typeof(l.tags) = 'array' then l.tags else '[]'::jsonb end
+           ) e(tag);
+$$ language sql immutable;
+
+-- the ids of the transactions a uuid tags: the transaction itself, or every
+-- leg of a split. corrected and deleted transactions and their reversals are
+-- history and cannot be tagged
+create or replace function utils.get_tag_target_ids(
+    p_transaction_uuid text,
+    p_user_data text = utils.get_user()
+) returns bigint[] as
+$$
+declare
+    v_ids bigint[];
+begin
+    select array_agg(t.id) into v_ids
+      from data.transactions t
+     where t.user_data = p_user_data
+       and t.deleted_at is null
+       and (
+           t.uuid = p_transaction_uuid
+           or t.split_id = (
+               select s.id
+                 from data.split_transactions s
+                where s.uuid = p_transaction_uuid
+                  and s.user_data = p_user_data
+           )
+       );
+
+    if v_ids is null then
+        raise exception 'Transaction not found: %', p_transaction_uuid
+            using errcode = 'PB004';
+    end if;
+
+    if exists (
+        select 1
+          from data.transaction_log tl
+         where tl.original_transaction_id = any(v_ids)
+            or tl.reversal_transaction_id = any(v_ids)
+    ) then
+        raise exception 'Transaction % was corrected or deleted, or reverses one. Tag its correction instead.',
+            p_transaction_uuid
+            using errcode = 'PB013';
+    end if;
+
+    return v_ids;
+end;
+$$ language plpgsql stable security definer;
+
+-- add tags to a transaction or to every leg of a split, keeping the tags it
+-- has. returns the tags it carries now
+create or replace function utils.tag_transaction(
+    p_transaction_uuid text,
+    p_tags text[],
+    p_user_data text = utils.get_user()
+) returns text[] as
+$$
+declare
+    v_ids  bigint[];
+    v_tags text[];
+begin
+    v_tags := utils.normalize_tags(p_tags);
+    if cardinality(v_tags) = 0 then
+        raise exception 'No tags given'
+            using errcode = 'PB013';
+    end if;
+
+    v_ids := utils.get_tag_target_ids(p_transaction_uuid, p_user_data);
+
+    update data.transactions t
+       set metadata = coalesce(t.metadata, '{}'::jsonb) || jsonb_build_object(
+               'tags', to_jsonb(utils.tag_union(jsonb_build_array(t.metadata -> 'tags', to_jsonb(v_tags))))
+           )
+     where t.id = any(v_ids);
+
+    return (
+        select utils.tag_union(jsonb_agg(t.metadata -> 'tags'))
+          from data.transactions t
+         where t.id = any(v_ids)
+    );
+end;
+$$ language plpgsql security definer;
+
+-- remove tags from a transaction or from every leg of a split; every tag
+-- when p_tags is null. returns the tags it carries now
+create or replace function utils.untag_transaction(
+    p_transaction_uuid text,
+    p_tags text[] = null,
+    p_user_data text = utils.get_user()
+) returns text[] as
+$$
+declare
+    v_ids  bigint[];
+    v_tags text[];
+begin
+    v_tags := utils.normalize_tags(p_tags);
+    v_ids := utils.get_tag_target_ids(p_transaction_uuid, p_user_data);
+
+    update data.transactions t
+       set metadata = nullif(
+               case
+                   when p_tags is null then t.metadata - 'tags'
+                   else t.metadata || jsonb_build_object(
+                       'tags', to_jsonb(array(
+                           select k.tag
+                             from unnest(utils.tag_union(jsonb_build_array(t.metadata -> 'tags'))) k(tag)
+                            where k.tag <> all(v_tags)
+                       ))
+                   )
+               end,
+               '{}'::jsonb
+           )
+     where t.id = any(v_ids)
+       and t.metadata ? 'tags';
+
+    -- a transaction left without tags drops the key
+    update data.transactions t
+       set metadata = nullif(t.metadata - 'tags', '{}'::jsonb)
+     where t.id = any(v_ids)
+       and t.metadata -> 'tags' = '[]'::jsonb;
+
+    return (
+        select utils.tag_union(jsonb_agg(t.metadata -> 'tags'))
+          from data.transactions t
+         where t.id = any(v_ids)
+    );
+end;
+$$ language plpgsql security definer;
+
+-- spending per tag, month and category: what bank accounts and credit cards
+-- paid out of each category, less what came back into it, for the
+-- transactions carrying the tag. p_tags keeps only the tags given and the
+-- periods, as YYYYMM, bound the months reported
+create or replace function utils.get_tag_spending(
+    p_ledger_uuid text,
+    p_tags text[] = null,
+    p_start_period text = null,
+    p_end_period text = null,
+    p_user_data text = utils.get_user()
+) returns table (
+    tag text,
+    period text,
+    category_uuid text,
+    category_name text,
+    spent bigint,
+    transactions bigint
+) as
+$$
+declare
+    v_ledger_id  bigint;
+    v_tags       text[];
+    v_start_date date;
+    v_end_date   date;
+begin
+    if p_start_period is not null then
+        if p_start_period !~ '^\d{6}$' then
+            raise exception 'Invalid period format. Use YYYYMM (e.g., 202508)';
+        end if;
+        v_start_date := (p_start_period || '01')::date;
+    end if;
+    if p_end_period is not null then
+        if p_end_period !~ '^\d{6}$' then
+            raise exception 'Invalid period format. Use YYYYMM (e.g., 202508)';
+        end if;
+        v_end_date := ((p_end_period || '01')::date + interval '1 month - 1 day')::date;
+    end if;
+
+    if p_tags is not null then
+        v_tags := utils.normalize_tags(p_tags);
+    end if;
+
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    return query
+    with tagged as (
+        select
+            e.tag,
+            t.date,
+            c.uuid as category_uuid,
+            c.name as category_name,
+            -- outflows debit the category
+            case when t.debit_account_id = c.id then t.amount else -t.amount end as spent
+        from
+            data.transactions t
+            join data.accounts o on o.id in (t.debit_account_id, t.credit_account_id)
+                                and o.type in ('asset', 'liability')
+            join data.accounts c on c.id in (t.debit_account_id, t.credit_account_id)
+                                and c.type = 'equity'
+            cross join lateral unnest(utils.tag_union(jsonb_build_array(t.metadata -> 'tags'))) e(tag)
+        where
+            t.ledger_id = v_ledger_id
+            and t.user_data = p_user_data
+            and t.metadata ? 'tags'
+            and t.deleted_at is null
+            and (v_start_date is null or t.date >= v_start_date)
+            and (v_end_date is null or t.date <= v_end_date)
+            and not exists (
+                select 1
+                from data.transaction_log tl
+                where t.id in (tl.original_transaction_id, tl.reversal_transaction_id)
+            )
+    )
+    select
+        tg.tag,
+        to_char(tg.date, 'YYYYMM') as period,
+        tg.category_uuid,
+        tg.category_name,
+        sum(tg.spent)::bigint as spent,
+        count(*) as transactions
+    from
+        tagged tg
+    where
+        v_tags is null or tg.tag = any(v_tags)
+    group by
+        tg.tag,
+        to_char(tg.date, 'YYYYMM'),
+        tg.category_uuid,
+        tg.category_name
+    order by
+        tg.tag,
+        to_char(tg.date, 'YYYYMM'),
+        tg.category_name;
+end;
+$$ language plpgsql stable security definer;
+
+-- a leg only changes together with its split: correcting it alone would
+-- take it out of the split. the correction keeps the payee of the original
+-- unless p_payee names another, whose default category is used when no
+-- category is given. the correction keeps the tags of the original
+create or replace function api.correct_transaction(
+    p_original_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_category_uuid text,
+    p_new_amount bigint,
+    p_new_description text,
+    p_new_date date,
+    p_reason text default 'Transaction correction',
+    p_override boolean default false, -- change reconciled transactions too
+    p_payee text default null -- the payee name, optional
+) returns text as $$
+declare
+    v_split_uuid text;
+    v_original data.transactions;
+    v_payee data.payees;
+    v_category_uuid text;
+    v_correction_id int;
+    v_correction_uuid text;
+begin
+    -- reconciled transactions are locked
+    if not p_override then
+        perform utils.assert_not_reconciled(p_original_uuid);
+    end if;
+
+    -- find the split the uuid names, or the split of the leg it names
+    select s.uuid into v_split_uuid
+    from data.split_transactions s
+    where s.user_data = utils.get_user()
+      and (
+          s.uuid = p_original_uuid
+          or s.id = (select t.split_id from data.transactions t where t.uuid = p_original_uuid)
+      );
+
+    if v_split_uuid is not null then
+        raise exception 'Transaction % is part of split transaction %. Use api.correct_split_transaction to change its splits.',
+            p_original_uuid, v_split_uuid
+            using errcode = 'PB013';
+    end if;
+
+    select t.* into v_original
+    from data.transactions t
+    where t.uuid = p_original_uuid
+      and t.user_data = utils.get_user();
+
+    v_category_uuid := p_new_category_uuid;
+
+    if p_payee is not null and v_original.id is not null then
+        v_payee := utils.find_or_create_payee(v_original.ledger_id, p_payee);
+
+        if v_category_uuid is null then
+            select a.uuid into v_category_uuid
+            from data.accounts a
+            where a.id = v_payee.default_category_id;
+        end if;
+    end if;
+
+    -- call utils function to do all the work
+    select utils.correct_transaction(
+        p_original_uuid,
+        p_new_type,
+        p_new_account_uuid,
+        v_category_uuid,
+        p_new_amount,
+        p_new_description,
+        p_new_date,
+        p_reason
+    ) into v_correction_id;
+
+    -- the reversal keeps the payee of the original
+    if coalesce(v_payee.id, v_original.payee_id) is not null then
+        update data.transactions t
+        set payee_id = case when t.id = v_correction_id then coalesce(v_payee.id, v_original.payee_id) else v_original.payee_id end
+        where t.id = v_correction_id
+           or t.id = (
+               select tl.reversal_transaction_id
+               from data.transaction_log tl
+               where tl.correction_transaction_id = v_correction_id
+           );
+    end if;
+
+    -- the correction keeps the tags of the original
+    if v_original.metadata ? 'tags' then
+        update data.transactions t
+        set metadata = coalesce(t.metadata, '{}'::jsonb) || jsonb_build_object('tags', v_original.metadata -> 'tags')
+        where t.id = v_correction_id;
+    end if;
+
+    -- the payee remembers the category it was given
+    if v_payee.id is not null and p_new_category_uuid is not null then
+        update data.payees p
+        set default_category_id = utils.get_payee_category_id(v_original.ledger_id, p_new_category_uuid),
+            updated_at = current_timestamp
+        where p.id = v_payee.id;
+    end if;
+
+    -- get the uuid of the corrected transaction
+    select uuid into v_correction_uuid
+    from data.transactions
+    where id = v_correction_id;
+
+    return v_correction_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- public api function to correct a split transaction. the legs of the
+-- correction carry the tags of the split
+create or replace function api.correct_split_transaction(
+    p_split_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_date date,
+    p_new_description text,
+    p_new_splits jsonb,
+    p_reason text default 'Transaction correction',
+    p_override boolean default false -- change reconciled transactions too
+) returns text as $$
+declare
+    v_split_id bigint;
+    v_split_uuid text;
+    v_tags text[];
+begin
+    -- reconciled transactions are locked
+    if not p_override then
+        perform utils.assert_not_reconciled(p_split_uuid);
+    end if;
+
+    select utils.tag_union(jsonb_agg(t.metadata -> 'tags')) into v_tags
+    from data.transactions t
+    join data.split_transactions s on s.id = t.split_id
+    where s.uuid = p_split_uuid
+      and s.user_data = utils.get_user();
+
+    select utils.correct_split_transaction(
+        p_split_uuid,
+        p_new_type,
+        p_new_account_uuid,
+        p_new_date,
+        p_new_description,
+        p_new_splits,
+        p_reason
+    ) into v_split_id;
+
+    if cardinality(v_tags) > 0 then
+        update data.transactions t
+        set metadata = coalesce(t.metadata, '{}'::jsonb) || jsonb_build_object('tags', to_jsonb(v_tags))
+        where t.split_id = v_split_id;
+    end if;
+
+    select uuid into v_split_uuid
+    from data.split_transactions
+    where id = v_split_id;
+
+    return v_split_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- the account history gains a tags column and filter
+drop function if exists api.get_account_transactions(text, text);
+drop function if exists utils.get_account_transactions(text, text, text);
+
+-- account history with running balances. the legs of a split are shown as
+-- one row carrying the uuid of the split; on the bank side its category lists
+-- every category of the split and category_uuid is null. p_status keeps only
+-- 'pending' or 'posted' transactions; reconciled marks the transactions locked
+-- by a reconciliation and payee names the payee of the transaction. tags
+-- lists the tags of the transaction, or of every leg of a split, and p_tags
+-- keeps only the transactions carrying all of the tags given
+create or replace function utils.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null,
+    p_tags text[] default null,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean,
+    payee text,
+    tags text[]
+) as $$
+declare
+    v_account_id bigint;
+    v_internal_type text;
+    v_tags text[];
+begin
+    if p_status is not null and p_status not in ('pending', 'posted') then
+        raise exception 'Invalid transaction status: %. Must be "pending" or "posted"', p_status
+            using errcode = 'PB013';
+    end if;
+
+    v_tags := utils.normalize_tags(p_tags);
+
+    -- resolve the account uuid to its internal id and validate ownership
+    select a.id, a.internal_type
+    into v_account_id, v_internal_type
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return query
+    with legs as (
+        select
+            t.id,
+            t.date,
+            t.created_at,
+            t.description,
+            t.amount,
+            t.uuid,
+            t.split_id,
+            t.status,
+            t.reconciliation_id,
+            o.name as other_name,
+            p.name as payee_name,
+            t.metadata -> 'tags' as tag_list,
+            o.uuid as other_uuid,
+            -- determine transaction type based on account's internal type
+            case
+                when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                     (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+                then 'inflow'
+                else 'outflow'
+            end as type
+        from
+            data.transactions t
+            join data.accounts o on o.id = case
+                when t.debit_account_id = v_account_id then t.credit_account_id
+                else t.debit_account_id
+            end
+            left join data.payees p on p.id = t.payee_id
+        where
+            (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+            and t.deleted_at is null
+            and (p_status is null or t.status = p_status)
+    )
+    select
+        min(l.date) as date,
+        string_agg(distinct l.other_name, ', ' order by l.other_name) as category,
+        -- a single leg keeps its memo, several legs show the split
+        case when count(*) = 1 then min(l.description) else s.description end as description,
+        min(l.type) as type,
+        sum(l.amount)::bigint as amount,
+        -- running balance from the balance snapshot of the last leg
+        coalesce(
+            (
+                select bs.balance
+                from data.balance_snapshots bs
+                where bs.transaction_id = max(l.id)
+                  and bs.account_id = v_account_id
+                  and bs.user_data = p_user_data
+            ), 0
+        ) as running_balance,
+        coalesce(s.uuid, min(l.uuid)) as uuid,
+        case when count(distinct l.other_uuid) = 1 then min(l.other_uuid) end as category_uuid,
+        s.id is not null as split,
+        -- a split is pending while any of its splits is
+        case when bool_or(l.status = 'pending') then 'pending' else 'posted' end as status,
+        bool_or(l.reconciliation_id is not null) as reconciled,
+        min(l.payee_name) as payee,
+        utils.tag_union(jsonb_agg(l.tag_list)) as tags
+    from
+        legs l
+        left join data.split_transactions s on s.id = l.split_id
+    group by
+        s.id,
+        case when s.id is null then l.id end
+    having
+        utils.tag_union(jsonb_agg(l.tag_list)) @> v_tags
+    order by
+        min(l.date) desc,
+        max(l.created_at) desc,
+        max(l.id) desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- public api function, passes through to the utils function
+create or replace function api.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null, -- 'pending' or 'posted'; all when null
+    p_tags text[] default null -- only transactions carrying all of these tags
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean,
+    payee text,
+    tags text[]
+) as $$
+begin
+    return query
+    select * from utils.get_account_transactions(p_account_uuid, p_status, p_tags);
+end;
+$$ language plpgsql stable security invoker;
+
+-- public api function to tag a transaction, or every leg of a split,
+-- returning its tags
+create or replace function api.tag_transaction(
+    p_transaction_uuid text,
+    p_tags text[]
+) returns text[] as $$
+begin
+    return utils.tag_transaction(p_transaction_uuid, p_tags);
+end;
+$$ language plpgsql security definer;
+
+-- public api function to remove tags from a transaction, returning the tags
+-- left
+create or replace function api.untag_transaction(
+    p_transaction_uuid text,
+    p_tags text[] default null -- every tag when null
+) returns text[] as $$
+begin
+    return utils.untag_transaction(p_transaction_uuid, p_tags);
+end;
+$$ language plpgsql security definer;
+
+-- public api function reporting spending per tag, month and category
+create or replace function api.get_tag_spending(
+    p_ledger_uuid text,
+    p_tags text[] default null, -- every tag when null
+    p_start_period text default null, -- YYYYMM
+    p_end_period text default null -- YYYYMM
+) returns table (
+    tag text,
+    period text,
+    category_uuid text,
+    category_name text,
+    spent bigint,
+    transactions bigint
+) as $$
+begin
+    return query
+    select * from utils.get_tag_spending(p_ledger_uuid, p_tags, p_start_period, p_end_period);
+end;
+$$ language plpgsql stable security invoker;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop function if exists api.get_tag_spending(text, text[], text, text);
+drop function if exists api.untag_transaction(text, text[]);
+drop function if exists api.tag_transaction(text, text[]);
+
+drop function if exists api.get_account_transactions(text, text, text[]);
+drop function if exists utils.get_account_transactions(text, text, text[], text);
+
+-- account history with running balances. the legs of a split are shown as
+-- one row carrying the uuid of the split; on the bank side its category lists
+-- every category of the split and category_uuid is null. p_status keeps only
+-- 'pending' or 'posted' transactions; reconciled marks the transactions locked
+-- by a reconciliation and payee names the payee of the transaction
+create or replace function utils.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean,
+    payee text
+) as $$
+declare
+    v_account_id bigint;
+    v_internal_type text;
+begin
+    if p_status is not null and p_status not in ('pending', 'posted') then
+        raise exception 'Invalid transaction status: %. Must be "pending" or "posted"', p_status
+            using errcode = 'PB013';
+    end if;
+
+    -- resolve the account uuid to its internal id and validate ownership
+    select a.id, a.internal_type
+    into v_account_id, v_internal_type
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return query
+    with legs as (
+        select
+            t.id,
+            t.date,
+            t.created_at,
+            t.description,
+            t.amount,
+            t.uuid,
+            t.split_id,
+            t.status,
+            t.reconciliation_id,
+            o.name as other_name,
+            p.name as payee_name,
+            o.uuid as other_uuid,
+            -- determine transaction type based on account's internal type
+            case
+                when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                     (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+                then 'inflow'
+                else 'outflow'
+            end as type
+        from
+            data.transactions t
+            join data.accounts o on o.id = case
+                when t.debit_account_id = v_account_id then t.credit_account_id
+                else t.debit_account_id
+            end
+            left join data.payees p on p.id = t.payee_id
+        where
+            (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+            and t.deleted_at is null
+            and (p_status is null or t.status = p_status)
+    )
+    select
+        min(l.date) as date,
+        string_agg(distinct l.other_name, ', ' order by l.other_name) as category,
+        -- a single leg keeps its memo, several legs show the split
+        case when count(*) = 1 then min(l.description) else s.description end as description,
+        min(l.type) as type,
+        sum(l.amount)::bigint as amount,
+        -- running balance from the balance snapshot of the last leg
+        coalesce(
+            (
+                select bs.balance
+                from data.balance_snapshots bs
+                where bs.transaction_id = max(l.id)
+                  and bs.account_id = v_account_id
+                  and bs.user_data = p_user_data
+            ), 0
+        ) as running_balance,
+        coalesce(s.uuid, min(l.uuid)) as uuid,
+        case when count(distinct l.other_uuid) = 1 then min(l.other_uuid) end as category_uuid,
+        s.id is not null as split,
+        -- a split is pending while any of its splits is
+        case when bool_or(l.status = 'pending') then 'pending' else 'posted' end as status,
+        bool_or(l.reconciliation_id is not null) as reconciled,
+        min(l.payee_name) as payee
+    from
+        legs l
+        left join data.split_transactions s on s.id = l.split_id
+    group by
+        s.id,
+        case when s.id is null then l.id end
+    order by
+        min(l.date) desc,
+        max(l.created_at) desc,
+        max(l.id) desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- public api function, passes through to the utils function
+create or replace function api.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null -- 'pending' or 'posted'; all when null
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean,
+    payee text
+) as $$
+begin
+    return query
+    select * from utils.get_account_transactions(p_account_uuid, p_status);
+end;
+$$ language plpgsql stable security invoker;
+
+-- a leg only changes together with its split: correcting it alone would
+-- take it out of the split. the correction keeps the payee of the original
+-- unless p_payee names another, whose default category is used when no
+-- category is given
+create or replace function api.correct_transaction(
+    p_original_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_category_uuid text,
+    p_new_amount bigint,
+    p_new_description text,
+    p_new_date date,
+    p_reason text default 'Transaction correction',
+    p_override boolean default false, -- change reconciled transactions too
+    p_payee text default null -- the payee name, optional
+) returns text as $$
+declare
+    v_split_uuid text;
+    v_original data.transactions;
+    v_payee data.payees;
+    v_category_uuid text;
+    v_correction_id int;
+    v_correction_uuid text;
+begin
+    -- reconciled transactions are locked
+    if not p_override then
+        perform utils.assert_not_reconciled(p_original_uuid);
+    end if;
+
+    -- find the split the uuid names, or the split of the leg it names
+    select s.uuid into v_split_uuid
+    from data.split_transactions s
+    where s.user_data = utils.get_user()
+      and (
+          s.uuid = p_original_uuid
+          or s.id = (select t.split_id from data.transactions t where t.uuid = p_original_uuid)
+      );
+
+    if v_split_uuid is not null then
+        raise exception 'Transaction % is part of split transaction %. Use api.correct_split_transaction to change its splits.',
+            p_original_uuid, v_split_uuid
+            using errcode = 'PB013';
+    end if;
+
+    select t.* into v_original
+    from data.transactions t
+    where t.uuid = p_original_uuid
+      and t.user_data = utils.get_user();
+
+    v_category_uuid := p_new_category_uuid;
+
+    if p_payee is not null and v_original.id is not null then
+        v_payee := utils.find_or_create_payee(v_original.ledger_id, p_payee);
+
+        if v_category_uuid is null then
+            select a.uuid into v_category_uuid
+            from data.accounts a
+            where a.id = v_payee.default_category_id;
+        end if;
+    end if;
+
+    -- call utils function to do all the work
+    select utils.correct_transaction(
+        p_original_uuid,
+        p_new_type,
+        p_new_account_uuid,
+        v_category_uuid,
+        p_new_amount,
+        p_new_description,
+        p_new_date,
+        p_reason
+    ) into v_correction_id;
+
+    -- the reversal keeps the payee of the original
+    if coalesce(v_payee.id, v_original.payee_id) is not null then
+        update data.transactions t
+        set payee_id = case when t.id = v_correction_id then coalesce(v_payee.id, v_original.payee_id) else v_original.payee_id end
+        where t.id = v_correction_id
+           or t.id = (
+               select tl.reversal_transaction_id
+               from data.transaction_log tl
+               where tl.correction_transaction_id = v_correction_id
+           );
+    end if;
+
+    -- the payee remembers the category it was given
+    if v_payee.id is not null and p_new_category_uuid is not null then
+        update data.payees p
+        set default_category_id = utils.get_payee_category_id(v_original.ledger_id, p_new_category_uuid),
+            updated_at = current_timestamp
+        where p.id = v_payee.id;
+    end if;
+
+    -- get the uuid of the corrected transaction
+    select uuid into v_correction_uuid
+    from data.transactions
+    where id = v_correction_id;
+
+    return v_correction_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- public api function to correct a split transaction
+create or replace function api.correct_split_transaction(
+    p_split_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_date date,
+    p_new_description text,
+    p_new_splits jsonb,
+    p_reason text default 'Transaction correction',
+    p_override boolean default false -- change reconciled transactions too
+) returns text as $$
+declare
+    v_split_id bigint;
+    v_split_uuid text;
+begin
+    -- reconciled transactions are locked
+    if not p_override then
+        perform utils.assert_not_reconciled(p_split_uuid);
+    end if;
+
+    select utils.correct_split_transaction(
+        p_split_uuid,
+        p_new_type,
+        p_new_account_uuid,
+        p_new_date,
+        p_new_description,
+        p_new_splits,
+        p_reason
+    ) into v_split_id;
+
+    select uuid into v_split_uuid
+    from data.split_transactions
+    where id = v_split_id;
+
+    return v_split_uuid;
+end;
+$$ language plpgsql security definer;
+
+drop function if exists utils.get_tag_spending(text, text[], text, text, text);
+drop function if exists utils.untag_transaction(text, text[], text);
+drop function if exists utils.tag_transaction(text, text[], text);
+drop function if exists utils.get_tag_target_ids(text, text);
+drop function if exists utils.tag_union(jsonb);
+drop function if exists utils.normalize_tags(text[]);
+
+drop index if exists data.idx_transactions_tags;
+
+-- +goose StatementEnd
@@ -0,0 +1,287 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create extension if not exists pg_trgm;
+
+-- whole words and word prefixes of descriptions are found through their
+-- text search vector; other fragments, such as store numbers, through
+-- trigrams. 'simple' keeps the words as written, whatever the language
+create index idx_transactions_description_search on data.transactions
+    using gin (to_tsvector('simple', coalesce(description, '')));
+
+create index idx_transactions_description_trgm on data.transactions
+    using gin (description gin_trgm_ops);
+
+-- turn a search query into a text search query matching every word of it
+-- as a prefix, so "cost whse" finds "COSTCO WHSE #0123". null when the query
+-- has no words
+create or replace function utils.search_query(
+    p_query text
+) returns tsquery as
+$$
+    select to_tsquery('simple', string_agg(w.word || ':*', ' & '))
+      from regexp_split_to_table(lower(coalesce(p_query, '')), '[^[:alnum:]]+') w(word)
+     where w.word <> '';
+$$ language sql immutable;
+
+-- the transactions of a ledger matching a query and filters, newest first.
+-- the query matches descriptions by words, word prefixes or any fragment,
+-- and payee names by words. every filter is optional: amounts are in cents
+-- and inclusive, p_account_uuid matches either side of a transaction and
+-- p_metadata_keys keeps the transactions whose metadata has every key, e.g.
+-- fitid for imported ones. pages hold p_limit transactions; p_after is the
+-- uuid of the last transaction of the previous page. corrected and deleted
+-- transactions and their reversals are history and are left out
+create or replace function utils.search_transactions(
+    p_ledger_uuid text,
+    p_query text = null,
+    p_min_amount bigint = null,
+    p_max_amount bigint = null,
+    p_start_date date = null,
+    p_end_date date = null,
+    p_account_uuid text = null,
+    p_category_uuid text = null,
+    p_status text = null,
+    p_metadata_keys text[] = null,
+    p_after text = null,
+    p_limit int = 50,
+    p_user_data text = utils.get_user()
+) returns table (
+    uuid text,
+    date date,
+    description text,
+    type text,
+    amount bigint,
+    account_uuid text,
+    account_name text,
+    category_uuid text,
+    category_name text,
+    payee text,
+    status text,
+    split_uuid text,
+    tags text[],
+    metadata jsonb
+) as
+$$
+declare
+    v_ledger_id   bigint;
+    v_account_id  bigint;
+    v_category_id bigint;
+    v_query       tsquery;
+    v_pattern     text;
+    v_after       data.transactions;
+begin
+    if p_status is not null and p_status not in ('pending', 'posted') then
+        raise exception 'Invalid transaction status: %. Must be "pending" or "posted"', p_status
+            using errcode = 'PB013';
+    end if;
+
+    if p_limit is null or p_limit < 1 or p_limit > 1000 then
+        raise exception 'Search limit must be between 1 and 1000, got %', p_limit
+            using errcode = 'PB013';
+    end if;
+
+    if p_min_amount is not null and p_max_amount is not null and p_min_amount > p_max_amount then
+        raise exception 'Minimum amount % exceeds maximum amount %', p_min_amount, p_max_amount
+            using errcode = 'PB010';
+    end if;
+
+    if p_start_date is not null and p_end_date is not null and p_start_date > p_end_date then
+        raise exception 'Start date % is after end date %', p_start_date, p_end_date
+            using errcode = 'PB011';
+    end if;
+
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    if p_account_uuid is not null then
+        select a.id into v_account_id
+          from data.accounts a
+         where a.uuid = p_account_uuid
+           and a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.type in ('asset', 'liability');
+
+        if v_account_id is null then
+            raise exception 'Account with UUID % not found in ledger % for current user',
+                p_account_uuid, p_ledger_uuid
+                using errcode = 'PB002';
+        end if;
+    end if;
+
+    if p_category_uuid is not null then
+        select a.id into v_category_id
+          from data.accounts a
+         where a.uuid = p_category_uuid
+           and a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.type = 'equity';
+
+        if v_category_id is null then
+            raise exception 'Category with UUID % not found in ledger % for current user',
+                p_category_uuid, p_ledger_uuid
+                using errcode = 'PB003';
+        end if;
+    end if;
+
+    if p_after is not null then
+        select t.* into v_after
+          from data.transactions t
+         where t.uuid = p_after
+           and t.ledger_id = v_ledger_id
+           and t.user_data = p_user_data;
+
+        if v_after.id is null then
+            raise exception 'Transaction not found: %', p_after
+                using errcode = 'PB004';
+        end if;
+    end if;
+
+    if nullif(trim(p_query), '') is not null then
+        v_query := utils.search_query(p_query);
+        -- like wildcards in the query match themselves
+        v_pattern := '%' || replace(replace(replace(trim(p_query), '\', '\\'), '%', '\%'), '_', '\_') || '%';
+    end if;
+
+    return query
+    with matches as (
+        select
+            t.*,
+            -- the bank account or credit card of the transaction; the source
+            -- of a transfer and the category credited by an assignment
+            case
+                when d.type in ('asset', 'liability') and c.type = 'equity' then d.id
+                else c.id
+            end as side_id
+        from
+            data.transactions t
+            join data.accounts d on d.id = t.debit_account_id
+            join data.accounts c on c.id = t.credit_account_id
+            left join data.payees p on p.id = t.payee_id
+        where
+            t.ledger_id = v_ledger_id
+            and t.user_data = p_user_data
+            and t.deleted_at is null
+            and (
+                v_pattern is null
+                or to_tsvector('simple', coalesce(t.description, '')) @@ v_query
+                or t.description ilike v_pattern
+                or to_tsvector('simple', coalesce(p.name, '')) @@ v_query
+            )
+            and (p_min_amount is null or t.amount >= p_min_amount)
+            and (p_max_amount is null or t.amount <= p_max_amount)
+            and (p_start_date is null or t.date >= p_start_date)
+            and (p_end_date is null or t.date <= p_end_date)
+            and (v_account_id is null or v_account_id in (t.debit_account_id, t.credit_account_id))
+            and (v_category_id is null or v_category_id in (t.debit_account_id, t.credit_account_id))
+            and (p_status is null or t.status = p_status)
+            and (p_metadata_keys is null or coalesce(t.metadata ?& p_metadata_keys, false))
+            and (v_after.id is null or (t.date, t.id) < (v_after.date, v_after.id))
+            and not exists (
+                select 1
+                from data.transaction_log tl
+                where t.id in (tl.original_transaction_id, tl.reversal_transaction_id)
+            )
+        order by
+            t.date desc,
+            t.id desc
+        limit p_limit
+    )
+    select
+        m.uuid,
+        m.date,
+        m.description,
+        -- seen from the account, as in the account history
+        case
+            when (a.internal_type = 'asset_like' and m.debit_account_id = a.id) or
+                 (a.internal_type = 'liability_like' and m.credit_account_id = a.id)
+            then 'inflow'
+            else 'outflow'
+        end as type,
+        m.amount,
+        a.uuid as account_uuid,
+        a.name as account_name,
+        o.uuid as category_uuid,
+        o.name as category_name,
+        p.name as payee,
+        m.status,
+        s.uuid as split_uuid,
+        utils.tag_union(jsonb_build_array(m.metadata -> 'tags')) as tags,
+        m.metadata
+    from
+        matches m
+        join data.accounts a on a.id = m.side_id
+        join data.accounts o on o.id = case
+            when m.debit_account_id = m.side_id then m.credit_account_id
+            else m.debit_account_id
+        end
+        left join data.payees p on p.id = m.payee_id
+        left join data.split_transactions s on s.id = m.split_id
+    order by
+        m.date desc,
+        m.id desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- public api function to search the transactions of a ledger, passes
+-- through to the utils function
+create or replace function api.search_transactions(
+    p_ledger_uuid text,
+    p_query text default null, -- words, word prefixes or a fragment of the description or payee
+    p_min_amount bigint default null, -- in cents
+    p_max_amount bigint default null, -- in cents
+    p_start_date date default null,
+    p_end_date date default null,
+    p_account_uuid text default null, -- either side of the transaction
+    p_category_uuid text default null,
+    p_status text default null, -- 'pending' or 'posted'; all when null
+    p_metadata_keys text[] default null, -- keys the metadata must all have
+    p_after text default null, -- uuid of the last transaction of the previous page
+    p_limit int default 50
+) returns table (
+    uuid text,
+    date date,
+    description text,
+    type text,
+    amount bigint,
+    account_uuid text,
+    account_name text,
+    category_uuid text,
+    category_name text,
+    payee text,
+    status text,
+    split_uuid text,
+    tags text[],
+    metadata jsonb
+) as $$
+begin
+    return query
+    select * from utils.search_transactions(
+        p_ledger_uuid, p_query, p_min_amount, p_max_amount, p_start_date, p_end_date,
+        p_account_uuid, p_category_uuid, p_status, p_metadata_keys, p_after, p_limit
+    );
+end;
+$$ language plpgsql stable security invoker;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop function if exists api.search_transactions(text, text, bigint, bigint, date, date, text, text, text, text[], text, int);
+drop function if exists utils.search_transactions(text, text, bigint, bigint, date, date, text, text, text, text[], text, int, text);
+drop function if exists utils.search_query(text);
+
+drop index if exists data.idx_transactions_description_trgm;
+drop index if exists data.idx_transactions_description_search;
+
+drop extension if exists pg_trgm;
+
+-- +goose StatementEnd
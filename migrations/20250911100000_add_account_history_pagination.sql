@@ -0,0 +1,456 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the account history gains keyset pagination and a total count
+drop function if exists api.get_account_transactions(text, text, text[]);
+drop function if exists utils.get_account_transactions(text, text, text[], text);
+
+-- the position of a transaction, or of a split, in the history of an
+-- account: the keys the history is sorted by. raises PB004 when the account
+-- has no such transaction, e.g. once it is deleted
+create or replace function utils.get_account_history_key(
+    p_account_id bigint,
+    p_uuid text,
+    p_user_data text,
+    out r_date date,
+    out r_created_at timestamptz,
+    out r_id bigint
+) as
+$$
+begin
+    select min(t.date), max(t.created_at), max(t.id)
+      into r_date, r_created_at, r_id
+      from data.transactions t
+           left join data.split_transactions s on s.id = t.split_id
+     where (t.uuid = p_uuid or s.uuid = p_uuid)
+       and (t.debit_account_id = p_account_id or t.credit_account_id = p_account_id)
+       and t.user_data = p_user_data
+       and t.deleted_at is null;
+
+    if r_id is null then
+        raise exception 'Transaction not found: %', p_uuid
+            using errcode = 'PB004';
+    end if;
+end;
+$$ language plpgsql stable security definer;
+
+-- account history with running balances. the legs of a split are shown as
+-- one row carrying the uuid of the split; on the bank side its category lists
+-- every category of the split and category_uuid is null. p_status keeps only
+-- 'pending' or 'posted' transactions; reconciled marks the transactions locked
+-- by a reconciliation and payee names the payee of the transaction. tags
+-- lists the tags of the transaction, or of every leg of a split, and p_tags
+-- keeps only the transactions carrying all of the tags given.
+-- the history is paged by keyset: p_after keeps the transactions older than
+-- the one with that uuid, p_before the newer ones, and p_limit keeps at most
+-- that many rows next to the cursor, all when null. running balances come
+-- from the balance snapshots, so every page carries them. total_count counts
+-- the rows matching p_status and p_tags over every page when p_with_total is
+-- set, and is null otherwise
+create or replace function utils.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null,
+    p_tags text[] default null,
+    p_after text default null,
+    p_before text default null,
+    p_limit int default null,
+    p_with_total boolean default false,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean,
+    payee text,
+    tags text[],
+    total_count bigint
+) as $$
+declare
+    v_account_id bigint;
+    v_internal_type text;
+    v_tags text[];
+    v_after_date date;
+    v_after_created_at timestamptz;
+    v_after_id bigint;
+    v_before_date date;
+    v_before_created_at timestamptz;
+    v_before_id bigint;
+    v_total bigint;
+begin
+    if p_status is not null and p_status not in ('pending', 'posted') then
+        raise exception 'Invalid transaction status: %. Must be "pending" or "posted"', p_status
+            using errcode = 'PB013';
+    end if;
+
+    if p_limit is not null and (p_limit < 1 or p_limit > 1000) then
+        raise exception 'Page size must be between 1 and 1000, got %', p_limit
+            using errcode = 'PB013';
+    end if;
+
+    v_tags := utils.normalize_tags(p_tags);
+
+    -- resolve the account uuid to its internal id and validate ownership
+    select a.id, a.internal_type
+    into v_account_id, v_internal_type
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    if p_after is not null then
+        select k.r_date, k.r_created_at, k.r_id
+          into v_after_date, v_after_created_at, v_after_id
+          from utils.get_account_history_key(v_account_id, p_after, p_user_data) k;
+    end if;
+    if p_before is not null then
+        select k.r_date, k.r_created_at, k.r_id
+          into v_before_date, v_before_created_at, v_before_id
+          from utils.get_account_history_key(v_account_id, p_before, p_user_data) k;
+    end if;
+
+    if coalesce(p_with_total, false) then
+        select count(*) into v_total
+          from (
+              select 1
+                from data.transactions t
+               where (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+                 and t.deleted_at is null
+                 and (p_status is null or t.status = p_status)
+               group by
+                   t.split_id,
+                   case when t.split_id is null then t.id end
+              having
+                   utils.tag_union(jsonb_agg(t.metadata -> 'tags')) @> v_tags
+          ) h;
+    end if;
+
+    return query
+    with legs as (
+        select
+            t.id,
+            t.date,
+            t.created_at,
+            t.description,
+            t.amount,
+            t.uuid,
+            t.split_id,
+            t.status,
+            t.reconciliation_id,
+            o.name as other_name,
+            p.name as payee_name,
+            t.metadata -> 'tags' as tag_list,
+            o.uuid as other_uuid,
+            -- determine transaction type based on account's internal type
+            case
+                when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                     (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+                then 'inflow'
+                else 'outflow'
+            end as type
+        from
+            data.transactions t
+            join data.accounts o on o.id = case
+                when t.debit_account_id = v_account_id then t.credit_account_id
+                else t.debit_account_id
+            end
+            left join data.payees p on p.id = t.payee_id
+        where
+            (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+            and t.deleted_at is null
+            and (p_status is null or t.status = p_status)
+            -- narrow the scan to the dates of the page; the keys are
+            -- compared exactly once the legs are grouped
+            and (p_after is null or t.date <= v_after_date)
+            and (p_before is null or t.date >= v_before_date)
+    ),
+    history as (
+        select
+            min(l.date) as date,
+            string_agg(distinct l.other_name, ', ' order by l.other_name) as category,
+            -- a single leg keeps its memo, several legs show the split
+            case when count(*) = 1 then min(l.description) else s.description end as description,
+            min(l.type) as type,
+            sum(l.amount)::bigint as amount,
+            -- running balance from the balance snapshot of the last leg
+            coalesce(
+                (
+                    select bs.balance
+                    from data.balance_snapshots bs
+                    where bs.transaction_id = max(l.id)
+                      and bs.account_id = v_account_id
+                      and bs.user_data = p_user_data
+                ), 0
+            ) as running_balance,
+            coalesce(s.uuid, min(l.uuid)) as uuid,
+            case when count(distinct l.other_uuid) = 1 then min(l.other_uuid) end as category_uuid,
+            s.id is not null as split,
+            -- a split is pending while any of its splits is
+            case when bool_or(l.status = 'pending') then 'pending' else 'posted' end as status,
+            bool_or(l.reconciliation_id is not null) as reconciled,
+            min(l.payee_name) as payee,
+            utils.tag_union(jsonb_agg(l.tag_list)) as tags,
+            -- the sort keys, also compared to the cursors
+            min(l.date) as sort_date,
+            max(l.created_at) as sort_created_at,
+            max(l.id) as sort_id
+        from
+            legs l
+            left join data.split_transactions s on s.id = l.split_id
+        group by
+            s.id,
+            case when s.id is null then l.id end
+        having
+            utils.tag_union(jsonb_agg(l.tag_list)) @> v_tags
+            and (
+                p_after is null
+                or (min(l.date), max(l.created_at), max(l.id)) < (v_after_date, v_after_created_at, v_after_id)
+            )
+            and (
+                p_before is null
+                or (min(l.date), max(l.created_at), max(l.id)) > (v_before_date, v_before_created_at, v_before_id)
+            )
+    ),
+    page as (
+        select *
+        from history h
+        order by
+            -- paging back from p_before keeps the rows next to it, the
+            -- oldest of the newer ones
+            case when p_before is not null and p_after is null then h.sort_date end,
+            case when p_before is not null and p_after is null then h.sort_created_at end,
+            case when p_before is not null and p_after is null then h.sort_id end,
+            h.sort_date desc,
+            h.sort_created_at desc,
+            h.sort_id desc
+        limit p_limit
+    )
+    select
+        pg.date,
+        pg.category,
+        pg.description,
+        pg.type,
+        pg.amount,
+        pg.running_balance,
+        pg.uuid,
+        pg.category_uuid,
+        pg.split,
+        pg.status,
+        pg.reconciled,
+        pg.payee,
+        pg.tags,
+        v_total
+    from
+        page pg
+    order by
+        pg.sort_date desc,
+        pg.sort_created_at desc,
+        pg.sort_id desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- public api function, passes through to the utils function
+create or replace function api.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null, -- 'pending' or 'posted'; all when null
+    p_tags text[] default null, -- only transactions carrying all of these tags
+    p_after text default null, -- uuid of a transaction: only older ones
+    p_before text default null, -- uuid of a transaction: only newer ones
+    p_limit int default null, -- page size; all rows when null
+    p_with_total boolean default false -- fill total_count
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean,
+    payee text,
+    tags text[],
+    total_count bigint
+) as $$
+begin
+    return query
+    select * from utils.get_account_transactions(
+        p_account_uuid, p_status, p_tags, p_after, p_before, p_limit, p_with_total
+    );
+end;
+$$ language plpgsql stable security invoker;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop function if exists api.get_account_transactions(text, text, text[], text, text, int, boolean);
+drop function if exists utils.get_account_transactions(text, text, text[], text, text, int, boolean, text);
+drop function if exists utils.get_account_history_key(bigint, text, text);
+
+-- account history with running balances. the legs of a split are shown as
+-- one row carrying the uuid of the split; on the bank side its category lists
+-- every category of the split and category_uuid is null. p_status keeps only
+-- 'pending' or 'posted' transactions; reconciled marks the transactions locked
+-- by a reconciliation and payee names the payee of the transaction. tags
+-- lists the tags of the transaction, or of every leg of a split, and p_tags
+-- keeps only the transactions carrying all of the tags given
+create or replace function utils.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null,
+    p_tags text[] default null,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean,
+    payee text,
+    tags text[]
+) as $$
+declare
+    v_account_id bigint;
+    v_internal_type text;
+    v_tags text[];
+begin
+    if p_status is not null and p_status not in ('pending', 'posted') then
+        raise exception 'Invalid transaction status: %. Must be "pending" or "posted"', p_status
+            using errcode = 'PB013';
+    end if;
+
+    v_tags := utils.normalize_tags(p_tags);
+
+    -- resolve the account uuid to its internal id and validate ownership
+    select a.id, a.internal_type
+    into v_account_id, v_internal_type
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return query
+    with legs as (
+        select
+            t.id,
+            t.date,
+            t.created_at,
+            t.description,
+            t.amount,
+            t.uuid,
+            t.split_id,
+            t.status,
+            t.reconciliation_id,
+            o.name as other_name,
+            p.name as payee_name,
+            t.metadata -> 'tags' as tag_list,
+            o.uuid as other_uuid,
+            -- determine transaction type based on account's internal type
+            case
+                when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                     (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+                then 'inflow'
+                else 'outflow'
+            end as type
+        from
+            data.transactions t
+            join data.accounts o on o.id = case
+                when t.debit_account_id = v_account_id then t.credit_account_id
+                else t.debit_account_id
+            end
+            left join data.payees p on p.id = t.payee_id
+        where
+            (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+            and t.deleted_at is null
+            and (p_status is null or t.status = p_status)
+    )
+    select
+        min(l.date) as date,
+        string_agg(distinct l.other_name, ', ' order by l.other_name) as category,
+        -- a single leg keeps its memo, several legs show the split
+        case when count(*) = 1 then min(l.description) else s.description end as description,
+        min(l.type) as type,
+        sum(l.amount)::bigint as amount,
+        -- running balance from the balance snapshot of the last leg
+        coalesce(
+            (
+                select bs.balance
+                from data.balance_snapshots bs
+                where bs.transaction_id = max(l.id)
+                  and bs.account_id = v_account_id
+                  and bs.user_data = p_user_data
+            ), 0
+        ) as running_balance,
+        coalesce(s.uuid, min(l.uuid)) as uuid,
+        case when count(distinct l.other_uuid) = 1 then min(l.other_uuid) end as category_uuid,
+        s.id is not null as split,
+        -- a split is pending while any of its splits is
+        case when bool_or(l.status = 'pending') then 'pending' else 'posted' end as status,
+        bool_or(l.reconciliation_id is not null) as reconciled,
+        min(l.payee_name) as payee,
+        utils.tag_union(jsonb_agg(l.tag_list)) as tags
+    from
+        legs l
+        left join data.split_transactions s on s.id = l.split_id
+    group by
+        s.id,
+        case when s.id is null then l.id end
+    having
+        utils.tag_union(jsonb_agg(l.tag_list)) @> v_tags
+    order by
+        min(l.date) desc,
+        max(l.created_at) desc,
+        max(l.id) desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- public api function, passes through to the utils function
+create or replace function api.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null, -- 'pending' or 'posted'; all when null
+    p_tags text[] default null -- only transactions carrying all of these tags
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean,
+    payee text,
+    tags text[]
+) as $$
+begin
+    return query
+    select * from utils.get_account_transactions(p_account_uuid, p_status, p_tags);
+end;
+$$ language plpgsql stable security invoker;
+
+-- +goose StatementEnd
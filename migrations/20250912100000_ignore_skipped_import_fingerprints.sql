@@ -0,0 +1,71 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- find the fingerprints an account has already seen in earlier batches.
+-- only lines that were recorded count: a line skipped as a likely duplicate
+-- is matched afresh on every import, so a narrower date window or the force
+-- policy can still record it
+create or replace function api.find_import_fingerprints(
+    p_account_uuid text,
+    p_fingerprints text[]
+) returns table (
+    fingerprint text,
+    action text,
+    transaction_uuid text,
+    batch_uuid text
+) as
+$$
+begin
+    return query
+    select distinct on (i.fingerprint)
+           i.fingerprint,
+           i.action,
+           t.uuid,
+           b.uuid
+      from data.import_batch_items i
+           join data.import_batches b on b.id = i.batch_id
+           join data.accounts a on a.id = b.account_id
+           left join data.transactions t on t.id = i.transaction_id
+     where a.uuid = p_account_uuid
+       and a.user_data = utils.get_user()
+       and i.fingerprint = any (p_fingerprints)
+       and i.action <> 'skipped'
+     order by i.fingerprint, b.created_at desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- find the fingerprints an account has already seen in earlier batches
+create or replace function api.find_import_fingerprints(
+    p_account_uuid text,
+    p_fingerprints text[]
+) returns table (
+    fingerprint text,
+    action text,
+    transaction_uuid text,
+    batch_uuid text
+) as
+$$
+begin
+    return query
+    select distinct on (i.fingerprint)
+           i.fingerprint,
+           i.action,
+           t.uuid,
+           b.uuid
+      from data.import_batch_items i
+           join data.import_batches b on b.id = i.batch_id
+           join data.accounts a on a.id = b.account_id
+           left join data.transactions t on t.id = i.transaction_id
+     where a.uuid = p_account_uuid
+       and a.user_data = utils.get_user()
+       and i.fingerprint = any (p_fingerprints)
+     order by i.fingerprint, b.created_at desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- +goose StatementEnd
@@ -0,0 +1,777 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the first rule of a ledger matching a transaction about to be recorded
+-- without a category. null when no rule matches, or when the ledger or the
+-- account is unknown: recording the transaction then raises the error
+create or replace function utils.find_category_rule(
+    p_ledger_uuid text,
+    p_account_uuid text,
+    p_description text,
+    p_amount bigint,
+    p_date date,
+    p_user_data text = utils.get_user()
+) returns data.category_rules as
+$$
+    select utils.match_category_rule(
+               l.id, a.id, coalesce(trim(p_description), ''), p_amount, p_date, p_user_data
+           )
+      from data.ledgers l
+           join data.accounts a on a.ledger_id = l.id
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data
+       and a.uuid = p_account_uuid
+       and a.user_data = p_user_data;
+$$ language sql stable security definer;
+
+-- the uuid of the category a rule assigns, null for rules that only add
+-- tags or rewrite the description
+create or replace function utils.category_rule_category_uuid(
+    p_rule data.category_rules
+) returns text as
+$$
+    select a.uuid
+      from data.accounts a
+     where a.id = (p_rule).category_id;
+$$ language sql stable security definer;
+
+-- record a transaction. category rules are applied by the entry points
+-- users record through, not here: split legs, scheduled occurrences and
+-- reconciliation adjustments go into the category they are given
+create or replace function utils.add_transaction(
+    p_ledger_uuid text,
+    p_date timestamptz,
+    p_description text,
+    p_type text,
+    p_amount bigint,
+    p_account_uuid text,
+    p_category_uuid text = null,
+    p_user_data text = utils.get_user()
+) returns int as
+$$
+declare
+    v_ledger_id             int;
+    v_account_id            int;
+    v_account_internal_type text;
+    v_category_id           int;
+    v_transaction_id        int;
+    v_debit_account_id      int;
+    v_credit_account_id     int;
+    v_cleaned_description   text;
+begin
+    -- validate transaction data
+    perform utils.validate_transaction_data(p_amount, p_date, p_type);
+
+    -- validate and clean description
+    v_cleaned_description := coalesce(trim(p_description), '');
+    if char_length(v_cleaned_description) > 500 then
+        raise exception 'Transaction description cannot exceed 500 characters. Current length: %',
+            char_length(v_cleaned_description)
+            using errcode = 'PB013';
+    end if;
+
+    -- find the ledger_id from uuid and validate ownership
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    -- find the account_id and internal_type in one query
+    select a.id, a.internal_type
+      into v_account_id, v_account_internal_type
+      from data.accounts a
+     where a.uuid = p_account_uuid
+       and a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found in ledger % for current user',
+                       p_account_uuid, p_ledger_uuid
+            using errcode = 'PB002';
+    end if;
+
+    -- handle category lookup
+    if p_category_uuid is null then
+        -- find the "Unassigned" category directly
+        select a.id into v_category_id
+          from data.accounts a
+         where a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.name = 'Unassigned'
+           and a.type = 'equity';
+
+        if v_category_id is null then
+            raise exception 'Default "Unassigned" category not found in ledger %. This indicates a system error.',
+                p_ledger_uuid
+                using errcode = 'PB003';
+        end if;
+    else
+        -- find the category by UUID
+        select a.id into v_category_id
+          from data.accounts a
+         where a.uuid = p_category_uuid
+           and a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.type = 'equity';
+
+        if v_category_id is null then
+            raise exception 'Category with UUID % not found in ledger % for current user',
+                           p_category_uuid, p_ledger_uuid
+                using errcode = 'PB003';
+        end if;
+    end if;
+
+    -- validate account type and transaction type combination
+    if (v_account_internal_type = 'asset_like' and p_type = 'outflow') or
+       (v_account_internal_type = 'liability_like' and p_type = 'inflow') then
+        -- debit category, credit account
+        v_debit_account_id := v_category_id;
+        v_credit_account_id := v_account_id;
+    elsif (v_account_internal_type = 'asset_like' and p_type = 'inflow') or
+          (v_account_internal_type = 'liability_like' and p_type = 'outflow') then
+        -- debit account, credit category
+        v_debit_account_id := v_account_id;
+        v_credit_account_id := v_category_id;
+    else
+        raise exception 'Invalid combination: account type "%" with transaction type "%". Please verify your account and transaction types.',
+            v_account_internal_type, p_type
+            using errcode = 'PB012';
+    end if;
+
+    -- create the transaction
+    begin
+        insert into data.transactions (
+            ledger_id, description, date, amount,
+            debit_account_id, credit_account_id, user_data
+        )
+        values (
+            v_ledger_id, v_cleaned_description, p_date, p_amount,
+            v_debit_account_id, v_credit_account_id, p_user_data
+        )
+        returning id into v_transaction_id;
+    exception
+        when unique_violation then
+            raise exception using
+                message = utils.handle_constraint_violation('transactions_uuid_unique', 'transactions'),
+                errcode = 'unique_violation';
+        when foreign_key_violation then
+            raise exception 'Invalid account reference in transaction. Please verify all accounts exist.'
+                using errcode = 'PB002';
+        when check_violation then
+            raise exception 'Transaction violates business rules. Please check amount and account constraints.'
+                using errcode = 'PB010';
+    end;
+
+    return v_transaction_id;
+end;
+$$ language plpgsql security definer;
+
+-- public api function to add a transaction. p_payee names the payee, which
+-- is created when the ledger has none by that name. without a category the
+-- payee's default category is used; with one, the payee remembers it.
+-- still without a category, the first matching rule of the ledger may
+-- choose one, rewrite the description and add tags
+create or replace function api.add_transaction(
+    p_ledger_uuid text,
+    p_date date,
+    p_description text,
+    p_type text, -- 'inflow' or 'outflow'
+    p_amount bigint,
+    p_account_uuid text, -- the bank account or credit card
+    p_category_uuid text default null, -- the category, optional
+    p_payee text default null -- the payee name, optional
+) returns text as $$
+declare
+    v_ledger_id        bigint;
+    v_payee            data.payees;
+    v_category_uuid    text;
+    v_description      text;
+    v_rule             data.category_rules;
+    v_transaction_id   int;
+    v_transaction_uuid text;
+begin
+    -- validate transaction type
+    if p_type not in ('inflow', 'outflow') then
+        raise exception 'Invalid transaction type: %. Must be "inflow" or "outflow"', p_type
+            using errcode = 'PB012';
+    end if;
+
+    v_category_uuid := p_category_uuid;
+
+    if p_payee is not null then
+        select l.id into v_ledger_id
+          from data.ledgers l
+         where l.uuid = p_ledger_uuid
+           and l.user_data = utils.get_user();
+
+        if v_ledger_id is null then
+            raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+                using errcode = 'PB001';
+        end if;
+
+        v_payee := utils.find_or_create_payee(v_ledger_id, p_payee);
+
+        if v_category_uuid is null then
+            select a.uuid into v_category_uuid
+              from data.accounts a
+             where a.id = v_payee.default_category_id;
+        end if;
+    end if;
+
+    v_description := p_description;
+
+    -- transactions without a category go through the rules of the ledger
+    if v_category_uuid is null then
+        v_rule := utils.find_category_rule(p_ledger_uuid, p_account_uuid, p_description, p_amount, p_date);
+        if v_rule.id is not null then
+            v_category_uuid := utils.category_rule_category_uuid(v_rule);
+            v_description := coalesce(v_rule.set_description, p_description);
+        end if;
+    end if;
+
+    -- call the utils function
+    select utils.add_transaction(
+        p_ledger_uuid,
+        p_date::timestamptz,
+        v_description,
+        p_type,
+        p_amount,
+        p_account_uuid,
+        v_category_uuid
+    ) into v_transaction_id;
+
+    if v_rule.id is not null then
+        update data.transactions t
+           set metadata = coalesce(t.metadata, '{}'::jsonb) || utils.category_rule_metadata(v_rule)
+         where t.id = v_transaction_id;
+    end if;
+
+    if v_payee.id is not null then
+        update data.transactions t
+           set payee_id = v_payee.id
+         where t.id = v_transaction_id;
+
+        -- the payee remembers the category it was given
+        if p_category_uuid is not null then
+            update data.payees p
+               set default_category_id = utils.get_payee_category_id(v_ledger_id, p_category_uuid),
+                   updated_at = current_timestamp
+             where p.id = v_payee.id;
+        end if;
+    end if;
+
+    -- get the uuid of the created transaction
+    select uuid into v_transaction_uuid
+    from data.transactions
+    where id = v_transaction_id;
+
+    return v_transaction_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- add a transaction from a bank statement, storing its external id in metadata
+-- returns null without inserting when the account already has a transaction with
+-- that external id, so the same statement can be imported any number of times.
+-- lines without a category go through the category rules of the ledger
+create or replace function utils.import_transaction(
+    p_ledger_uuid text,
+    p_date timestamptz,
+    p_description text,
+    p_type text,
+    p_amount bigint,
+    p_account_uuid text,
+    p_category_uuid text = null,
+    p_external_id text = null,
+    p_metadata jsonb = null,
+    p_user_data text = utils.get_user()
+) returns int as
+$$
+declare
+    v_transaction_id int;
+    v_metadata       jsonb;
+    v_category_uuid  text;
+    v_description    text;
+    v_rule           data.category_rules;
+begin
+    -- validate metadata shape
+    if p_metadata is not null and jsonb_typeof(p_metadata) != 'object' then
+        raise exception 'Transaction metadata must be a JSON object'
+            using errcode = 'PB013';
+    end if;
+
+    if p_external_id is not null then
+        -- serialize imports into the same account so concurrent runs cannot both insert
+        perform pg_advisory_xact_lock(hashtextextended('pgbudget.import:' || p_account_uuid, 0));
+
+        -- deleted transactions count too, otherwise re-importing would bring them back
+        select t.id
+          into v_transaction_id
+          from data.transactions t
+               join data.accounts a on a.id in (t.debit_account_id, t.credit_account_id)
+         where a.uuid = p_account_uuid
+           and a.user_data = p_user_data
+           and t.user_data = p_user_data
+           and t.metadata ->> 'fitid' = p_external_id
+         limit 1;
+
+        if v_transaction_id is not null then
+            return null;
+        end if;
+    end if;
+
+    v_category_uuid := p_category_uuid;
+    v_description := p_description;
+    if v_category_uuid is null then
+        v_rule := utils.find_category_rule(
+            p_ledger_uuid, p_account_uuid, p_description, p_amount, p_date::date, p_user_data
+        );
+        if v_rule.id is not null then
+            v_category_uuid := utils.category_rule_category_uuid(v_rule);
+            v_description := coalesce(v_rule.set_description, p_description);
+        end if;
+    end if;
+
+    -- record the transaction through the regular path so all validation applies
+    v_transaction_id := utils.add_transaction(
+        p_ledger_uuid,
+        p_date,
+        v_description,
+        p_type,
+        p_amount,
+        p_account_uuid,
+        v_category_uuid,
+        p_user_data
+    );
+
+    -- attach the metadata of the rule, the external id and any extra
+    -- statement fields
+    v_metadata := coalesce(p_metadata, '{}'::jsonb);
+    if v_rule.id is not null then
+        v_metadata := utils.category_rule_metadata(v_rule) || v_metadata;
+    end if;
+    if p_external_id is not null then
+        v_metadata := v_metadata || jsonb_build_object('fitid', p_external_id);
+    end if;
+
+    if v_metadata != '{}'::jsonb then
+        update data.transactions
+           set metadata = coalesce(metadata, '{}'::jsonb) || v_metadata
+         where id = v_transaction_id;
+    end if;
+
+    return v_transaction_id;
+end;
+$$ language plpgsql security definer;
+
+-- inserts into api.transactions record the transaction like
+-- api.add_transaction: without a category, the first matching rule of the
+-- ledger may choose one, rewrite the description and add tags
+create or replace function utils.simple_transactions_insert_fn()
+returns trigger as
+$$
+declare
+    v_transaction_id int;
+    v_user_data text := utils.get_user();
+    v_category_uuid text;
+    v_description text;
+    v_rule data.category_rules;
+begin
+    -- use our enhanced utils.add_transaction function for all validation and business logic
+    -- this ensures consistent validation whether transactions are created via API functions or view inserts
+    v_category_uuid := NEW.category_uuid;
+    v_description := NEW.description;
+    if v_category_uuid is null then
+        v_rule := utils.find_category_rule(
+            NEW.ledger_uuid, NEW.account_uuid, NEW.description, NEW.amount, NEW.date::date, v_user_data
+        );
+        if v_rule.id is not null then
+            v_category_uuid := utils.category_rule_category_uuid(v_rule);
+            v_description := coalesce(v_rule.set_description, NEW.description);
+        end if;
+    end if;
+
+    select utils.add_transaction(
+        NEW.ledger_uuid,
+        NEW.date::timestamptz,
+        v_description,
+        NEW.type,
+        NEW.amount,
+        NEW.account_uuid,
+        v_category_uuid,
+        v_user_data
+    ) into v_transaction_id;
+
+    if v_rule.id is not null then
+        update data.transactions t
+           set metadata = coalesce(t.metadata, '{}'::jsonb) || utils.category_rule_metadata(v_rule)
+         where t.id = v_transaction_id;
+    end if;
+    
+    -- populate NEW record with the created transaction data for backward compatibility
+    -- get the transaction details from the created record
+    select t.uuid, t.description, t.amount, t.date, t.metadata
+      into NEW.uuid, NEW.description, NEW.amount, NEW.date, NEW.metadata
+      from data.transactions t
+     where t.id = v_transaction_id;
+    
+    -- NEW.ledger_uuid, NEW.account_uuid, NEW.category_uuid, NEW.type are already set from input
+    
+    return NEW;
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- record a transaction. without a category, the first matching rule of
+-- the ledger may choose one, rewrite the description and add tags
+create or replace function utils.add_transaction(
+    p_ledger_uuid text,
+    p_date timestamptz,
+    p_description text,
+    p_type text,
+    p_amount bigint,
+    p_account_uuid text,
+    p_category_uuid text = null,
+    p_user_data text = utils.get_user()
+) returns int as
+$$
+declare
+    v_ledger_id             int;
+    v_account_id            int;
+    v_account_internal_type text;
+    v_category_id           int;
+    v_transaction_id        int;
+    v_debit_account_id      int;
+    v_credit_account_id     int;
+    v_cleaned_description   text;
+    v_rule                  data.category_rules;
+    v_metadata              jsonb;
+begin
+    -- validate transaction data
+    perform utils.validate_transaction_data(p_amount, p_date, p_type);
+
+    -- validate and clean description
+    v_cleaned_description := coalesce(trim(p_description), '');
+    if char_length(v_cleaned_description) > 500 then
+        raise exception 'Transaction description cannot exceed 500 characters. Current length: %',
+            char_length(v_cleaned_description)
+            using errcode = 'PB013';
+    end if;
+
+    -- find the ledger_id from uuid and validate ownership
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    -- find the account_id and internal_type in one query
+    select a.id, a.internal_type
+      into v_account_id, v_account_internal_type
+      from data.accounts a
+     where a.uuid = p_account_uuid
+       and a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found in ledger % for current user',
+                       p_account_uuid, p_ledger_uuid
+            using errcode = 'PB002';
+    end if;
+
+    -- transactions without a category go through the rules of the ledger
+    if p_category_uuid is null then
+        v_rule := utils.match_category_rule(
+            v_ledger_id, v_account_id, v_cleaned_description, p_amount, p_date::date, p_user_data
+        );
+        if v_rule.id is not null then
+            v_category_id := v_rule.category_id;
+            v_cleaned_description := coalesce(v_rule.set_description, v_cleaned_description);
+            v_metadata := utils.category_rule_metadata(v_rule);
+        end if;
+    end if;
+
+    -- handle category lookup
+    if v_category_id is not null then
+        -- a rule chose the category
+        null;
+    elsif p_category_uuid is null then
+        -- find the "Unassigned" category directly
+        select a.id into v_category_id
+          from data.accounts a
+         where a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.name = 'Unassigned'
+           and a.type = 'equity';
+
+        if v_category_id is null then
+            raise exception 'Default "Unassigned" category not found in ledger %. This indicates a system error.',
+                p_ledger_uuid
+                using errcode = 'PB003';
+        end if;
+    else
+        -- find the category by UUID
+        select a.id into v_category_id
+          from data.accounts a
+         where a.uuid = p_category_uuid
+           and a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.type = 'equity';
+
+        if v_category_id is null then
+            raise exception 'Category with UUID % not found in ledger % for current user',
+                           p_category_uuid, p_ledger_uuid
+                using errcode = 'PB003';
+        end if;
+    end if;
+
+    -- validate account type and transaction type combination
+    if (v_account_internal_type = 'asset_like' and p_type = 'outflow') or
+       (v_account_internal_type = 'liability_like' and p_type = 'inflow') then
+        -- debit category, credit account
+        v_debit_account_id := v_category_id;
+        v_credit_account_id := v_account_id;
+    elsif (v_account_internal_type = 'asset_like' and p_type = 'inflow') or
+          (v_account_internal_type = 'liability_like' and p_type = 'outflow') then
+        -- debit account, credit category
+        v_debit_account_id := v_account_id;
+        v_credit_account_id := v_category_id;
+    else
+        raise exception 'Invalid combination: account type "%" with transaction type "%". Please verify your account and transaction types.',
+            v_account_internal_type, p_type
+            using errcode = 'PB012';
+    end if;
+
+    -- create the transaction
+    begin
+        insert into data.transactions (
+            ledger_id, description, date, amount,
+            debit_account_id, credit_account_id, metadata, user_data
+        )
+        values (
+            v_ledger_id, v_cleaned_description, p_date, p_amount,
+            v_debit_account_id, v_credit_account_id, v_metadata, p_user_data
+        )
+        returning id into v_transaction_id;
+    exception
+        when unique_violation then
+            raise exception using
+                message = utils.handle_constraint_violation('transactions_uuid_unique', 'transactions'),
+                errcode = 'unique_violation';
+        when foreign_key_violation then
+            raise exception 'Invalid account reference in transaction. Please verify all accounts exist.'
+                using errcode = 'PB002';
+        when check_violation then
+            raise exception 'Transaction violates business rules. Please check amount and account constraints.'
+                using errcode = 'PB010';
+    end;
+
+    return v_transaction_id;
+end;
+$$ language plpgsql security definer;
+
+-- public api function to add a transaction. p_payee names the payee, which
+-- is created when the ledger has none by that name. without a category the
+-- payee's default category is used; with one, the payee remembers it
+create or replace function api.add_transaction(
+    p_ledger_uuid text,
+    p_date date,
+    p_description text,
+    p_type text, -- 'inflow' or 'outflow'
+    p_amount bigint,
+    p_account_uuid text, -- the bank account or credit card
+    p_category_uuid text default null, -- the category, optional
+    p_payee text default null -- the payee name, optional
+) returns text as $$
+declare
+    v_ledger_id        bigint;
+    v_payee            data.payees;
+    v_category_uuid    text;
+    v_transaction_id   int;
+    v_transaction_uuid text;
+begin
+    -- validate transaction type
+    if p_type not in ('inflow', 'outflow') then
+        raise exception 'Invalid transaction type: %. Must be "inflow" or "outflow"', p_type
+            using errcode = 'PB012';
+    end if;
+
+    v_category_uuid := p_category_uuid;
+
+    if p_payee is not null then
+        select l.id into v_ledger_id
+          from data.ledgers l
+         where l.uuid = p_ledger_uuid
+           and l.user_data = utils.get_user();
+
+        if v_ledger_id is null then
+            raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+                using errcode = 'PB001';
+        end if;
+
+        v_payee := utils.find_or_create_payee(v_ledger_id, p_payee);
+
+        if v_category_uuid is null then
+            select a.uuid into v_category_uuid
+              from data.accounts a
+             where a.id = v_payee.default_category_id;
+        end if;
+    end if;
+
+    -- call the utils function
+    select utils.add_transaction(
+        p_ledger_uuid,
+        p_date::timestamptz,
+        p_description,
+        p_type,
+        p_amount,
+        p_account_uuid,
+        v_category_uuid
+    ) into v_transaction_id;
+
+    if v_payee.id is not null then
+        update data.transactions t
+           set payee_id = v_payee.id
+         where t.id = v_transaction_id;
+
+        -- the payee remembers the category it was given
+        if p_category_uuid is not null then
+            update data.payees p
+               set default_category_id = utils.get_payee_category_id(v_ledger_id, p_category_uuid),
+                   updated_at = current_timestamp
+             where p.id = v_payee.id;
+        end if;
+    end if;
+
+    -- get the uuid of the created transaction
+    select uuid into v_transaction_uuid
+    from data.transactions
+    where id = v_transaction_id;
+
+    return v_transaction_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- add a transaction from a bank statement, storing its external id in metadata
+-- returns null without inserting when the account already has a transaction with
+-- that external id, so the same statement can be imported any number of times
+create or replace function utils.import_transaction(
+    p_ledger_uuid text,
+    p_date timestamptz,
+    p_description text,
+    p_type text,
+    p_amount bigint,
+    p_account_uuid text,
+    p_category_uuid text = null,
+    p_external_id text = null,
+    p_metadata jsonb = null,
+    p_user_data text = utils.get_user()
+) returns int as
+$$
+declare
+    v_transaction_id int;
+    v_metadata       jsonb;
+begin
+    -- validate metadata shape
+    if p_metadata is not null and jsonb_typeof(p_metadata) != 'object' then
+        raise exception 'Transaction metadata must be a JSON object'
+            using errcode = 'PB013';
+    end if;
+
+    if p_external_id is not null then
+        -- serialize imports into the same account so concurrent runs cannot both insert
+        perform pg_advisory_xact_lock(hashtextextended('pgbudget.import:' || p_account_uuid, 0));
+
+        -- deleted transactions count too, otherwise re-importing would bring them back
+        select t.id
+          into v_transaction_id
+          from data.transactions t
+               join data.accounts a on a.id in (t.debit_account_id, t.credit_account_id)
+         where a.uuid = p_account_uuid
+           and a.user_data = p_user_data
+           and t.user_data = p_user_data
+           and t.metadata ->> 'fitid' = p_external_id
+         limit 1;
+
+        if v_transaction_id is not null then
+            return null;
+        end if;
+    end if;
+
+    -- record the transaction through the regular path so all validation applies
+    v_transaction_id := utils.add_transaction(
+        p_ledger_uuid,
+        p_date,
+        p_description,
+        p_type,
+        p_amount,
+        p_account_uuid,
+        p_category_uuid,
+        p_user_data
+    );
+
+    -- attach the external id and any extra statement fields
+    v_metadata := coalesce(p_metadata, '{}'::jsonb);
+    if p_external_id is not null then
+        v_metadata := v_metadata || jsonb_build_object('fitid', p_external_id);
+    end if;
+
+    if v_metadata != '{}'::jsonb then
+        update data.transactions
+           set metadata = coalesce(metadata, '{}'::jsonb) || v_metadata
+         where id = v_transaction_id;
+    end if;
+
+    return v_transaction_id;
+end;
+$$ language plpgsql security definer;
+
+create or replace function utils.simple_transactions_insert_fn()
+returns trigger as
+$$
+declare
+    v_transaction_id int;
+    v_user_data text := utils.get_user();
+begin
+    -- use our enhanced utils.add_transaction function for all validation and business logic
+    -- this ensures consistent validation whether transactions are created via API functions or view inserts
+    select utils.add_transaction(
+        NEW.ledger_uuid,
+        NEW.date::timestamptz,
+        NEW.description,
+        NEW.type,
+        NEW.amount,
+        NEW.account_uuid,
+        NEW.category_uuid,
+        v_user_data
+    ) into v_transaction_id;
+    
+    -- populate NEW record with the created transaction data for backward compatibility
+    -- get the transaction details from the created record
+    select t.uuid, t.description, t.amount, t.date, t.metadata
+      into NEW.uuid, NEW.description, NEW.amount, NEW.date, NEW.metadata
+      from data.transactions t
+     where t.id = v_transaction_id;
+    
+    -- NEW.ledger_uuid, NEW.account_uuid, NEW.category_uuid, NEW.type are already set from input
+    
+    return NEW;
+end;
+$$ language plpgsql security definer;
+
+drop function if exists utils.category_rule_category_uuid(data.category_rules);
+drop function if exists utils.find_category_rule(text, text, text, bigint, date, text);
+
+-- +goose StatementEnd
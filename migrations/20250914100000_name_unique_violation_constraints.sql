@@ -0,0 +1,568 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- duplicate names re-raised with a friendlier message keep the name of the
+-- constraint they break, so clients can tell them from other unique
+-- violations
+
+-- create a category account with error codes
+create or replace function utils.add_category(
+    p_ledger_uuid text,
+    p_name text,
+    p_user_data text = utils.get_user()
+) returns data.accounts as
+$$
+declare
+    v_ledger_id int;
+    v_account_record data.accounts;
+    v_cleaned_name text;
+begin
+    -- validate and clean input data
+    v_cleaned_name := utils.validate_input_data(p_name, null, 'category');
+
+    -- find the ledger ID for the specified UUID and user
+    select l.id
+      into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    -- raise exception if ledger not found for the user
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    -- create the category account (equity type, liability_like behavior)
+    begin
+        insert into data.accounts (ledger_id, name, type, internal_type, user_data)
+        values (v_ledger_id, v_cleaned_name, 'equity', 'liability_like', p_user_data)
+        returning * into v_account_record;
+    exception
+        when unique_violation then
+            raise exception using
+                message = utils.handle_constraint_violation('accounts_name_ledger_unique', 'accounts', v_cleaned_name),
+                errcode = 'unique_violation',
+                constraint = 'accounts_name_ledger_unique';
+        when foreign_key_violation then
+            raise exception 'Invalid ledger reference. Please verify the ledger exists.'
+                using errcode = 'PB001';
+    end;
+
+    return v_account_record;
+end;
+$$ language plpgsql security definer;
+
+-- create multiple categories at once with error codes
+create or replace function utils.add_categories(
+    p_ledger_uuid text,
+    p_names text[],
+    p_user_data text = utils.get_user()
+) returns setof data.accounts as
+$$
+declare
+    v_ledger_id int;
+    v_name text;
+    v_account_record data.accounts;
+begin
+    -- find the ledger ID for the specified UUID and user
+    select l.id
+      into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    -- raise exception if ledger not found for the user
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    -- process each category name
+    foreach v_name in array p_names
+    loop
+        -- skip empty names after trimming
+        v_name := trim(v_name);
+        if v_name = '' then
+            continue;
+        end if;
+
+        -- create the category account
+        begin
+            insert into data.accounts (ledger_id, name, type, internal_type, user_data)
+            values (v_ledger_id, v_name, 'equity', 'liability_like', p_user_data)
+            returning * into v_account_record;
+
+            return next v_account_record;
+        exception
+            when unique_violation then
+                raise exception 'Category with name "%" already exists in this ledger', v_name
+                    using errcode = 'unique_violation', constraint = 'accounts_name_ledger_unique';
+        end;
+    end loop;
+
+    return;
+end;
+$$ language plpgsql security definer;
+
+-- add a rule to a ledger, returning its uuid
+create or replace function utils.add_category_rule(
+    p_ledger_uuid text,
+    p_name text,
+    p_category_uuid text = null,
+    p_description_pattern text = null,
+    p_min_amount bigint = null,
+    p_max_amount bigint = null,
+    p_account_uuid text = null,
+    p_weekdays int[] = null,
+    p_set_description text = null,
+    p_tags text[] = null,
+    p_priority int = 0,
+    p_user_data text = utils.get_user()
+) returns text as
+$$
+declare
+    v_ledger_id   bigint;
+    v_category_id bigint;
+    v_account_id  bigint;
+    v_name        text;
+    v_rule_uuid   text;
+begin
+    v_name := utils.validate_input_data(p_name, null, 'rule');
+
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    if p_category_uuid is null and nullif(trim(p_set_description), '') is null and coalesce(cardinality(p_tags), 0) = 0 then
+        raise exception 'Rule % does nothing: give it a category, a description or tags', v_name
+            using errcode = 'PB013';
+    end if;
+
+    if p_description_pattern is not null then
+        begin
+            perform '' ~* p_description_pattern;
+        exception
+            when invalid_regular_expression then
+                raise exception 'Invalid description pattern: %', p_description_pattern
+                    using errcode = 'PB013';
+        end;
+    end if;
+
+    if p_min_amount < 0 or p_max_amount < 0 or p_min_amount > p_max_amount then
+        raise exception 'Invalid amount range: % to %', p_min_amount, p_max_amount
+            using errcode = 'PB010';
+    end if;
+
+    if not coalesce(p_weekdays <@ array[1, 2, 3, 4, 5, 6, 7], true) then
+        raise exception 'Invalid weekdays: %. Use 1 (Monday) to 7 (Sunday)', p_weekdays
+            using errcode = 'PB013';
+    end if;
+
+    if p_category_uuid is not null then
+        select a.id into v_category_id
+          from data.accounts a
+         where a.uuid = p_category_uuid
+           and a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.type = 'equity';
+
+        if v_category_id is null then
+            raise exception 'Category with UUID % not found in ledger % for current user', p_category_uuid, p_ledger_uuid
+                using errcode = 'PB003';
+        end if;
+    end if;
+
+    if p_account_uuid is not null then
+        select a.id into v_account_id
+          from data.accounts a
+         where a.uuid = p_account_uuid
+           and a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.type <> 'equity';
+
+        if v_account_id is null then
+            raise exception 'Account with UUID % not found in ledger % for current user', p_account_uuid, p_ledger_uuid
+                using errcode = 'PB002';
+        end if;
+    end if;
+
+    begin
+        insert into data.category_rules (
+            name, priority, description_pattern, min_amount, max_amount, account_id, weekdays,
+            category_id, set_description, tags, ledger_id, user_data
+        )
+        values (
+            v_name, coalesce(p_priority, 0), p_description_pattern, p_min_amount, p_max_amount, v_account_id,
+            p_weekdays, v_category_id, nullif(trim(p_set_description), ''), nullif(p_tags, '{}'),
+            v_ledger_id, p_user_data
+        )
+        returning uuid into v_rule_uuid;
+    exception
+        when unique_violation then
+            raise exception 'A rule named % already exists in this ledger', v_name
+                using errcode = 'unique_violation', constraint = 'category_rules_name_ledger_unique';
+    end;
+
+    return v_rule_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- add a payee to a ledger, returning its uuid
+create or replace function utils.create_payee(
+    p_ledger_uuid text,
+    p_name text,
+    p_default_category_uuid text = null,
+    p_user_data text = utils.get_user()
+) returns text as
+$$
+declare
+    v_ledger_id   bigint;
+    v_category_id bigint;
+    v_name        text;
+    v_payee_uuid  text;
+begin
+    v_name := regexp_replace(utils.validate_input_data(p_name, null, 'payee'), '\s+', ' ', 'g');
+
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    v_category_id := utils.get_payee_category_id(v_ledger_id, p_default_category_uuid, p_user_data);
+
+    begin
+        insert into data.payees (name, default_category_id, ledger_id, user_data)
+        values (v_name, v_category_id, v_ledger_id, p_user_data)
+        returning uuid into v_payee_uuid;
+    exception
+        when unique_violation then
+            raise exception 'A payee named % already exists in this ledger', v_name
+                using errcode = 'unique_violation', constraint = 'payees_name_ledger_unique';
+    end;
+
+    return v_payee_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- rename a payee. to combine two payees, merge them instead
+create or replace function utils.rename_payee(
+    p_payee_uuid text,
+    p_name text,
+    p_user_data text = utils.get_user()
+) returns void as
+$$
+declare
+    v_payee data.payees;
+    v_name  text;
+begin
+    v_name := regexp_replace(utils.validate_input_data(p_name, null, 'payee'), '\s+', ' ', 'g');
+    v_payee := utils.get_payee(p_payee_uuid, p_user_data);
+
+    begin
+        update data.payees p
+           set name = v_name,
+               updated_at = current_timestamp
+         where p.id = v_payee.id;
+    exception
+        when unique_violation then
+            raise exception 'A payee named % already exists in this ledger. Merge the payees instead.', v_name
+                using errcode = 'unique_violation', constraint = 'payees_name_ledger_unique';
+    end;
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- create a category account with error codes
+create or replace function utils.add_category(
+    p_ledger_uuid text,
+    p_name text,
+    p_user_data text = utils.get_user()
+) returns data.accounts as
+$$
+declare
+    v_ledger_id int;
+    v_account_record data.accounts;
+    v_cleaned_name text;
+begin
+    -- validate and clean input data
+    v_cleaned_name := utils.validate_input_data(p_name, null, 'category');
+
+    -- find the ledger ID for the specified UUID and user
+    select l.id
+      into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    -- raise exception if ledger not found for the user
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    -- create the category account (equity type, liability_like behavior)
+    begin
+        insert into data.accounts (ledger_id, name, type, internal_type, user_data)
+        values (v_ledger_id, v_cleaned_name, 'equity', 'liability_like', p_user_data)
+        returning * into v_account_record;
+    exception
+        when unique_violation then
+            raise exception using
+                message = utils.handle_constraint_violation('accounts_name_ledger_unique', 'accounts', v_cleaned_name),
+                errcode = 'unique_violation';
+        when foreign_key_violation then
+            raise exception 'Invalid ledger reference. Please verify the ledger exists.'
+                using errcode = 'PB001';
+    end;
+
+    return v_account_record;
+end;
+$$ language plpgsql security definer;
+
+-- create multiple categories at once with error codes
+create or replace function utils.add_categories(
+    p_ledger_uuid text,
+    p_names text[],
+    p_user_data text = utils.get_user()
+) returns setof data.accounts as
+$$
+declare
+    v_ledger_id int;
+    v_name text;
+    v_account_record data.accounts;
+begin
+    -- find the ledger ID for the specified UUID and user
+    select l.id
+      into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    -- raise exception if ledger not found for the user
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    -- process each category name
+    foreach v_name in array p_names
+    loop
+        -- skip empty names after trimming
+        v_name := trim(v_name);
+        if v_name = '' then
+            continue;
+        end if;
+
+        -- create the category account
+        begin
+            insert into data.accounts (ledger_id, name, type, internal_type, user_data)
+            values (v_ledger_id, v_name, 'equity', 'liability_like', p_user_data)
+            returning * into v_account_record;
+
+            return next v_account_record;
+        exception
+            when unique_violation then
+                raise exception 'Category with name "%" already exists in this ledger', v_name
+                    using errcode = 'unique_violation';
+        end;
+    end loop;
+
+    return;
+end;
+$$ language plpgsql security definer;
+
+-- add a rule to a ledger, returning its uuid
+create or replace function utils.add_category_rule(
+    p_ledger_uuid text,
+    p_name text,
+    p_category_uuid text = null,
+    p_description_pattern text = null,
+    p_min_amount bigint = null,
+    p_max_amount bigint = null,
+    p_account_uuid text = null,
+    p_weekdays int[] = null,
+    p_set_description text = null,
+    p_tags text[] = null,
+    p_priority int = 0,
+    p_user_data text = utils.get_user()
+) returns text as
+$$
+declare
+    v_ledger_id   bigint;
+    v_category_id bigint;
+    v_account_id  bigint;
+    v_name        text;
+    v_rule_uuid   text;
+begin
+    v_name := utils.validate_input_data(p_name, null, 'rule');
+
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    if p_category_uuid is null and nullif(trim(p_set_description), '') is null and coalesce(cardinality(p_tags), 0) = 0 then
+        raise exception 'Rule % does nothing: give it a category, a description or tags', v_name
+            using errcode = 'PB013';
+    end if;
+
+    if p_description_pattern is not null then
+        begin
+            perform '' ~* p_description_pattern;
+        exception
+            when invalid_regular_expression then
+                raise exception 'Invalid description pattern: %', p_description_pattern
+                    using errcode = 'PB013';
+        end;
+    end if;
+
+    if p_min_amount < 0 or p_max_amount < 0 or p_min_amount > p_max_amount then
+        raise exception 'Invalid amount range: % to %', p_min_amount, p_max_amount
+            using errcode = 'PB010';
+    end if;
+
+    if not coalesce(p_weekdays <@ array[1, 2, 3, 4, 5, 6, 7], true) then
+        raise exception 'Invalid weekdays: %. Use 1 (Monday) to 7 (Sunday)', p_weekdays
+            using errcode = 'PB013';
+    end if;
+
+    if p_category_uuid is not null then
+        select a.id into v_category_id
+          from data.accounts a
+         where a.uuid = p_category_uuid
+           and a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.type = 'equity';
+
+        if v_category_id is null then
+            raise exception 'Category with UUID % not found in ledger % for current user', p_category_uuid, p_ledger_uuid
+                using errcode = 'PB003';
+        end if;
+    end if;
+
+    if p_account_uuid is not null then
+        select a.id into v_account_id
+          from data.accounts a
+         where a.uuid = p_account_uuid
+           and a.ledger_id = v_ledger_id
+           and a.user_data = p_user_data
+           and a.type <> 'equity';
+
+        if v_account_id is null then
+            raise exception 'Account with UUID % not found in ledger % for current user', p_account_uuid, p_ledger_uuid
+                using errcode = 'PB002';
+        end if;
+    end if;
+
+    begin
+        insert into data.category_rules (
+            name, priority, description_pattern, min_amount, max_amount, account_id, weekdays,
+            category_id, set_description, tags, ledger_id, user_data
+        )
+        values (
+            v_name, coalesce(p_priority, 0), p_description_pattern, p_min_amount, p_max_amount, v_account_id,
+            p_weekdays, v_category_id, nullif(trim(p_set_description), ''), nullif(p_tags, '{}'),
+            v_ledger_id, p_user_data
+        )
+        returning uuid into v_rule_uuid;
+    exception
+        when unique_violation then
+            raise exception 'A rule named % already exists in this ledger', v_name
+                using errcode = 'unique_violation';
+    end;
+
+    return v_rule_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- add a payee to a ledger, returning its uuid
+create or replace function utils.create_payee(
+    p_ledger_uuid text,
+    p_name text,
+    p_default_category_uuid text = null,
+    p_user_data text = utils.get_user()
+) returns text as
+$$
+declare
+    v_ledger_id   bigint;
+    v_category_id bigint;
+    v_name        text;
+    v_payee_uuid  text;
+begin
+    v_name := regexp_replace(utils.validate_input_data(p_name, null, 'payee'), '\s+', ' ', 'g');
+
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    v_category_id := utils.get_payee_category_id(v_ledger_id, p_default_category_uuid, p_user_data);
+
+    begin
+        insert into data.payees (name, default_category_id, ledger_id, user_data)
+        values (v_name, v_category_id, v_ledger_id, p_user_data)
+        returning uuid into v_payee_uuid;
+    exception
+        when unique_violation then
+            raise exception 'A payee named % already exists in this ledger', v_name
+                using errcode = 'unique_violation';
+    end;
+
+    return v_payee_uuid;
+end;
+$$ language plpgsql security definer;
+
+-- rename a payee. to combine two payees, merge them instead
+create or replace function utils.rename_payee(
+    p_payee_uuid text,
+    p_name text,
+    p_user_data text = utils.get_user()
+) returns void as
+$$
+declare
+    v_payee data.payees;
+    v_name  text;
+begin
+    v_name := regexp_replace(utils.validate_input_data(p_name, null, 'payee'), '\s+', ' ', 'g');
+    v_payee := utils.get_payee(p_payee_uuid, p_user_data);
+
+    begin
+        update data.payees p
+           set name = v_name,
+               updated_at = current_timestamp
+         where p.id = v_payee.id;
+    exception
+        when unique_violation then
+            raise exception 'A payee named % already exists in this ledger. Merge the payees instead.', v_name
+                using errcode = 'unique_violation';
+    end;
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
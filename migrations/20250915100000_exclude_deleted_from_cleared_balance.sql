@@ -0,0 +1,298 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the cleared balance takes the user of its caller
+drop function if exists utils.get_account_cleared_balance_at(bigint, date);
+
+-- the cleared balance of an account as a statement ending on p_date shows it:
+-- the latest balance snapshot without pending transactions and without the
+-- ones dated after the statement. posted is what counts as cleared: there is
+-- no separate flag, api.post_transactions marks pending transactions cleared.
+-- deleted and corrected transactions and their reversals cancel out in the
+-- snapshot, so they are left out of what is taken off it
+create function utils.get_account_cleared_balance_at(
+    p_account_id bigint,
+    p_date date,
+    p_user_data text = utils.get_user()
+) returns bigint as $$
+    select coalesce(
+        (
+            select s.balance
+            from data.balance_snapshots s
+            where s.account_id = p_account_id
+              and s.user_data = p_user_data
+            order by s.transaction_id desc
+            limit 1
+        ), 0
+    ) - coalesce(
+        sum(
+            case
+                when (a.internal_type = 'asset_like') = (t.debit_account_id = a.id) then t.amount
+                else -t.amount
+            end
+        ), 0
+    )::bigint
+    from data.accounts a
+         join data.transactions t on a.id in (t.debit_account_id, t.credit_account_id)
+    where a.id = p_account_id
+      and (t.status = 'pending' or t.date > p_date)
+      and t.user_data = p_user_data
+      and not exists (
+          select 1
+          from data.transaction_log tl
+          where t.id in (tl.original_transaction_id, tl.reversal_transaction_id)
+      );
+$$ language sql stable security definer;
+
+-- what reconciling an account would find, without changing anything:
+-- the cleared balance, its difference to the statement and the number of
+-- transactions that would be locked
+create or replace function utils.get_reconciliation_preview(
+    p_account_uuid text,
+    p_statement_balance bigint,
+    p_statement_date date default current_date,
+    p_user_data text = utils.get_user()
+)
+returns table (
+    statement_date date,
+    statement_balance bigint,
+    cleared_balance bigint,
+    difference bigint,
+    transactions int
+) as $$
+declare
+    v_account data.accounts;
+    v_cleared bigint;
+begin
+    v_account := utils.get_reconcilable_account(p_account_uuid, p_statement_date, p_user_data);
+    v_cleared := utils.get_account_cleared_balance_at(v_account.id, p_statement_date, p_user_data);
+
+    return query
+    select
+        p_statement_date,
+        p_statement_balance,
+        v_cleared,
+        p_statement_balance - v_cleared,
+        (
+            select count(*)::int
+            from data.transactions t
+            where v_account.id in (t.debit_account_id, t.credit_account_id)
+              and t.status = 'posted'
+              and t.reconciliation_id is null
+              and t.date <= p_statement_date
+        );
+end;
+$$ language plpgsql stable security definer;
+
+-- reconcile an account against a statement. a difference between the
+-- statement and the cleared balance is recorded as a posted adjustment
+-- against p_category_uuid (Income by default), then every posted transaction
+-- up to the statement date is locked. returns the id of the reconciliation
+create or replace function utils.reconcile_account(
+    p_account_uuid text,
+    p_statement_balance bigint,
+    p_statement_date date default current_date,
+    p_category_uuid text default null,
+    p_user_data text = utils.get_user()
+) returns bigint as $$
+declare
+    v_account data.accounts;
+    v_ledger_uuid text;
+    v_cleared bigint;
+    v_difference bigint;
+    v_adjustment_id bigint;
+    v_reconciliation_id bigint;
+begin
+    -- reconciliations of one account wait for each other
+    perform 1
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data
+    for update;
+
+    v_account := utils.get_reconcilable_account(p_account_uuid, p_statement_date, p_user_data);
+    v_cleared := utils.get_account_cleared_balance_at(v_account.id, p_statement_date, p_user_data);
+    v_difference := p_statement_balance - v_cleared;
+
+    if v_difference <> 0 then
+        select l.uuid into v_ledger_uuid
+        from data.ledgers l
+        where l.id = v_account.ledger_id;
+
+        -- a positive difference raises the balance: an inflow on a bank
+        -- account, a charge on a credit card
+        v_adjustment_id := utils.add_transaction(
+            v_ledger_uuid,
+            p_statement_date,
+            'Reconciliation adjustment',
+            case when (v_difference > 0) = (v_account.internal_type = 'asset_like') then 'inflow' else 'outflow' end,
+            abs(v_difference),
+            p_account_uuid,
+            coalesce(p_category_uuid, utils.find_category(v_ledger_uuid, 'Income', p_user_data)),
+            p_user_data
+        );
+    end if;
+
+    insert into data.reconciliations (
+        statement_date, statement_balance, cleared_balance, adjustment_transaction_id,
+        account_id, ledger_id, user_data
+    )
+    values (
+        p_statement_date, p_statement_balance, v_cleared, v_adjustment_id,
+        v_account.id, v_account.ledger_id, p_user_data
+    )
+    returning id into v_reconciliation_id;
+
+    -- lock the posted transactions of the statement, the adjustment included
+    update data.transactions t
+       set reconciliation_id = v_reconciliation_id
+     where v_account.id in (t.debit_account_id, t.credit_account_id)
+       and t.status = 'posted'
+       and t.reconciliation_id is null
+       and t.date <= p_statement_date
+       and t.user_data = p_user_data;
+
+    return v_reconciliation_id;
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop function if exists utils.get_account_cleared_balance_at(bigint, date, text);
+
+-- the cleared balance of an account as a statement ending on p_date shows it:
+-- the current balance without pending transactions and without the ones
+-- dated after the statement
+create function utils.get_account_cleared_balance_at(
+    p_account_id bigint,
+    p_date date
+) returns bigint as $$
+    select coalesce(utils.get_account_current_balance(p_account_id), 0) - coalesce(
+        sum(
+            case
+                when (a.internal_type = 'asset_like') = (t.debit_account_id = a.id) then t.amount
+                else -t.amount
+            end
+        ), 0
+    )::bigint
+    from data.accounts a
+         join data.transactions t on a.id in (t.debit_account_id, t.credit_account_id)
+    where a.id = p_account_id
+      and (t.status = 'pending' or t.date > p_date)
+      and t.user_data = utils.get_user();
+$$ language sql stable security definer;
+
+-- what reconciling an account would find, without changing anything:
+-- the cleared balance, its difference to the statement and the number of
+-- transactions that would be locked
+create or replace function utils.get_reconciliation_preview(
+    p_account_uuid text,
+    p_statement_balance bigint,
+    p_statement_date date default current_date,
+    p_user_data text = utils.get_user()
+)
+returns table (
+    statement_date date,
+    statement_balance bigint,
+    cleared_balance bigint,
+    difference bigint,
+    transactions int
+) as $$
+declare
+    v_account data.accounts;
+    v_cleared bigint;
+begin
+    v_account := utils.get_reconcilable_account(p_account_uuid, p_statement_date, p_user_data);
+    v_cleared := utils.get_account_cleared_balance_at(v_account.id, p_statement_date);
+
+    return query
+    select
+        p_statement_date,
+        p_statement_balance,
+        v_cleared,
+        p_statement_balance - v_cleared,
+        (
+            select count(*)::int
+            from data.transactions t
+            where v_account.id in (t.debit_account_id, t.credit_account_id)
+              and t.status = 'posted'
+              and t.reconciliation_id is null
+              and t.date <= p_statement_date
+        );
+end;
+$$ language plpgsql stable security definer;
+
+-- reconcile an account against a statement. a difference between the
+-- statement and the cleared balance is recorded as a posted adjustment
+-- against p_category_uuid (Income by default), then every posted transaction
+-- up to the statement date is locked. returns the id of the reconciliation
+create or replace function utils.reconcile_account(
+    p_account_uuid text,
+    p_statement_balance bigint,
+    p_statement_date date default current_date,
+    p_category_uuid text default null,
+    p_user_data text = utils.get_user()
+) returns bigint as $$
+declare
+    v_account data.accounts;
+    v_ledger_uuid text;
+    v_cleared bigint;
+    v_difference bigint;
+    v_adjustment_id bigint;
+    v_reconciliation_id bigint;
+begin
+    -- reconciliations of one account wait for each other
+    perform 1
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data
+    for update;
+
+    v_account := utils.get_reconcilable_account(p_account_uuid, p_statement_date, p_user_data);
+    v_cleared := utils.get_account_cleared_balance_at(v_account.id, p_statement_date);
+    v_difference := p_statement_balance - v_cleared;
+
+    if v_difference <> 0 then
+        select l.uuid into v_ledger_uuid
+        from data.ledgers l
+        where l.id = v_account.ledger_id;
+
+        -- a positive difference raises the balance: an inflow on a bank
+        -- account, a charge on a credit card
+        v_adjustment_id := utils.add_transaction(
+            v_ledger_uuid,
+            p_statement_date,
+            'Reconciliation adjustment',
+            case when (v_difference > 0) = (v_account.internal_type = 'asset_like') then 'inflow' else 'outflow' end,
+            abs(v_difference),
+            p_account_uuid,
+            coalesce(p_category_uuid, utils.find_category(v_ledger_uuid, 'Income', p_user_data)),
+            p_user_data
+        );
+    end if;
+
+    insert into data.reconciliations (
+        statement_date, statement_balance, cleared_balance, adjustment_transaction_id,
+        account_id, ledger_id, user_data
+    )
+    values (
+        p_statement_date, p_statement_balance, v_cleared, v_adjustment_id,
+        v_account.id, v_account.ledger_id, p_user_data
+    )
+    returning id into v_reconciliation_id;
+
+    -- lock the posted transactions of the statement, the adjustment included
+    update data.transactions t
+       set reconciliation_id = v_reconciliation_id
+     where v_account.id in (t.debit_account_id, t.credit_account_id)
+       and t.status = 'posted'
+       and t.reconciliation_id is null
+       and t.date <= p_statement_date
+       and t.user_data = p_user_data;
+
+    return v_reconciliation_id;
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
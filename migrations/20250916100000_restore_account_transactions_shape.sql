@@ -0,0 +1,103 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- api.get_account_transactions keeps the six columns it always returned, so
+-- callers reading them by position keep working. the uuids, status, payee,
+-- tags and paging added since move to api.get_account_transactions_page
+drop function if exists api.get_account_transactions(text, text, text[], text, text, int, boolean);
+
+-- account history with running balances, newest first. a split shows as one
+-- row
+create function api.get_account_transactions(
+    p_account_uuid text
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint
+) as $$
+begin
+    return query
+    select h.date, h.category, h.description, h.type, h.amount, h.running_balance
+    from utils.get_account_transactions(p_account_uuid) h;
+end;
+$$ language plpgsql stable security invoker;
+
+-- the account history with every column, filtered and paged. passes through
+-- to the utils function
+create function api.get_account_transactions_page(
+    p_account_uuid text,
+    p_status text default null, -- 'pending' or 'posted'; all when null
+    p_tags text[] default null, -- only transactions carrying all of these tags
+    p_after text default null, -- uuid of a transaction: only older ones
+    p_before text default null, -- uuid of a transaction: only newer ones
+    p_limit int default null, -- page size; all rows when null
+    p_with_total boolean default false -- fill total_count
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean,
+    payee text,
+    tags text[],
+    total_count bigint
+) as $$
+begin
+    return query
+    select * from utils.get_account_transactions(
+        p_account_uuid, p_status, p_tags, p_after, p_before, p_limit, p_with_total
+    );
+end;
+$$ language plpgsql stable security invoker;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+drop function if exists api.get_account_transactions_page(text, text, text[], text, text, int, boolean);
+drop function if exists api.get_account_transactions(text);
+
+-- public api function, passes through to the utils function
+create or replace function api.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null, -- 'pending' or 'posted'; all when null
+    p_tags text[] default null, -- only transactions carrying all of these tags
+    p_after text default null, -- uuid of a transaction: only older ones
+    p_before text default null, -- uuid of a transaction: only newer ones
+    p_limit int default null, -- page size; all rows when null
+    p_with_total boolean default false -- fill total_count
+) returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean,
+    payee text,
+    tags text[],
+    total_count bigint
+) as $$
+begin
+    return query
+    select * from utils.get_account_transactions(
+        p_account_uuid, p_status, p_tags, p_after, p_before, p_limit, p_with_total
+    );
+end;
+$$ language plpgsql stable security invoker;
+
+-- +goose StatementEnd
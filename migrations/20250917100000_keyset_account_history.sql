@@ -0,0 +1,603 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the history of an account is read newest first from either side of its
+-- transactions; these indexes give every page its rows without a sort
+create index idx_transactions_debit_history
+    on data.transactions (debit_account_id, date desc, created_at desc, id desc);
+create index idx_transactions_credit_history
+    on data.transactions (credit_account_id, date desc, created_at desc, id desc);
+
+-- whether a leg heads its row in the history of an account: a transaction is
+-- its own row, a split is headed by its last leg on the account. legs of
+-- another status than p_status are not part of the history, and a row must
+-- carry every tag of p_tags
+create or replace function utils.is_account_history_head(
+    p_id bigint,
+    p_split_id bigint,
+    p_metadata jsonb,
+    p_account_id bigint,
+    p_status text,
+    p_tags text[]
+) returns boolean as
+$$
+    select case
+        when p_split_id is null then
+            cardinality(p_tags) = 0
+            or utils.tag_union(jsonb_build_array(p_metadata -> 'tags')) @> p_tags
+        else (
+            select max(s.id) = p_id
+                   and (cardinality(p_tags) = 0 or utils.tag_union(jsonb_agg(s.metadata -> 'tags')) @> p_tags)
+              from data.transactions s
+             where s.split_id = p_split_id
+               and (s.debit_account_id = p_account_id or s.credit_account_id = p_account_id)
+               and s.deleted_at is null
+               and (p_status is null or s.status = p_status)
+        )
+    end;
+$$ language sql stable;
+
+-- the position of a transaction, or of a split, in the history of an
+-- account: the keys of the leg heading its row. raises PB004 when the
+-- account has no such transaction, e.g. once it is deleted
+create or replace function utils.get_account_history_key(
+    p_account_id bigint,
+    p_uuid text,
+    p_user_data text,
+    out r_date date,
+    out r_created_at timestamptz,
+    out r_id bigint
+) as
+$$
+begin
+    select t.date, t.created_at, t.id
+      into r_date, r_created_at, r_id
+      from data.transactions t
+           left join data.split_transactions s on s.id = t.split_id
+     where (t.uuid = p_uuid or s.uuid = p_uuid)
+       and (t.debit_account_id = p_account_id or t.credit_account_id = p_account_id)
+       and t.user_data = p_user_data
+       and t.deleted_at is null
+     order by t.id desc
+     limit 1;
+
+    if r_id is null then
+        raise exception 'Transaction not found: %', p_uuid
+            using errcode = 'PB004';
+    end if;
+end;
+$$ language plpgsql stable security definer;
+
+-- account history with running balances. the legs of a split are shown as
+-- one row carrying the uuid of the split; on the bank side its category lists
+-- every category of the split and category_uuid is null. p_status keeps only
+-- 'pending' or 'posted' transactions; reconciled marks the transactions locked
+-- by a reconciliation and payee names the payee of the transaction. tags
+-- lists the tags of the transaction, or of every leg of a split, and p_tags
+-- keeps only the transactions carrying all of the tags given.
+-- the history is paged by keyset: p_after keeps the transactions older than
+-- the one with that uuid, p_before the newer ones, and p_limit keeps at most
+-- that many rows next to the cursor, all when null. the legs heading the rows
+-- of the page are read from the history indexes up to the limit, and only
+-- their rows are grouped and given the running balance of their snapshot.
+-- total_count counts the rows matching p_status and p_tags over every page
+-- when p_with_total is set, and is null otherwise; it reads the whole history
+-- of the account, so ask for it once rather than on every page
+create or replace function utils.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null,
+    p_tags text[] default null,
+    p_after text default null,
+    p_before text default null,
+    p_limit int default null,
+    p_with_total boolean default false,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean,
+    payee text,
+    tags text[],
+    total_count bigint
+) as $$
+declare
+    v_account_id bigint;
+    v_internal_type text;
+    v_tags text[];
+    -- without a cursor the keys bound nothing
+    v_after_date date := 'infinity';
+    v_after_created_at timestamptz := 'infinity';
+    v_after_id bigint := 9223372036854775807;
+    v_before_date date := '-infinity';
+    v_before_created_at timestamptz := '-infinity';
+    v_before_id bigint := 0;
+    v_heads bigint[];
+    v_split_ids bigint[];
+    v_total bigint;
+begin
+    if p_status is not null and p_status not in ('pending', 'posted') then
+        raise exception 'Invalid transaction status: %. Must be "pending" or "posted"', p_status
+            using errcode = 'PB013';
+    end if;
+
+    if p_limit is not null and (p_limit < 1 or p_limit > 1000) then
+        raise exception 'Page size must be between 1 and 1000, got %', p_limit
+            using errcode = 'PB013';
+    end if;
+
+    v_tags := utils.normalize_tags(p_tags);
+
+    -- resolve the account uuid to its internal id and validate ownership
+    select a.id, a.internal_type
+    into v_account_id, v_internal_type
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    if p_after is not null then
+        select k.r_date, k.r_created_at, k.r_id
+          into v_after_date, v_after_created_at, v_after_id
+          from utils.get_account_history_key(v_account_id, p_after, p_user_data) k;
+    end if;
+    if p_before is not null then
+        select k.r_date, k.r_created_at, k.r_id
+          into v_before_date, v_before_created_at, v_before_id
+          from utils.get_account_history_key(v_account_id, p_before, p_user_data) k;
+    end if;
+
+    -- the legs heading the rows of the page, newest first from each side of
+    -- the account. paging back from p_before alone walks the indexes the
+    -- other way, keeping the rows next to it
+    if p_before is not null and p_after is null then
+        select array_agg(h.head_id) into v_heads
+          from (
+              (
+                  select t.id as head_id, t.date as head_date, t.created_at as head_created_at
+                    from data.transactions t
+                   where t.debit_account_id = v_account_id
+                     and (t.date, t.created_at, t.id) > (v_before_date, v_before_created_at, v_before_id)
+                     and t.deleted_at is null
+                     and (p_status is null or t.status = p_status)
+                     and utils.is_account_history_head(t.id, t.split_id, t.metadata, v_account_id, p_status, v_tags)
+                   order by t.date, t.created_at, t.id
+                   limit p_limit
+              )
+              union all
+              (
+                  select t.id, t.date, t.created_at
+                    from data.transactions t
+                   where t.credit_account_id = v_account_id
+                     and t.debit_account_id <> v_account_id
+                     and (t.date, t.created_at, t.id) > (v_before_date, v_before_created_at, v_before_id)
+                     and t.deleted_at is null
+                     and (p_status is null or t.status = p_status)
+                     and utils.is_account_history_head(t.id, t.split_id, t.metadata, v_account_id, p_status, v_tags)
+                   order by t.date, t.created_at, t.id
+                   limit p_limit
+              )
+              order by head_date, head_created_at, head_id
+              limit p_limit
+          ) h;
+    else
+        select array_agg(h.head_id) into v_heads
+          from (
+              (
+                  select t.id as head_id, t.date as head_date, t.created_at as head_created_at
+                    from data.transactions t
+                   where t.debit_account_id = v_account_id
+                     and (t.date, t.created_at, t.id) < (v_after_date, v_after_created_at, v_after_id)
+                     and (t.date, t.created_at, t.id) > (v_before_date, v_before_created_at, v_before_id)
+                     and t.deleted_at is null
+                     and (p_status is null or t.status = p_status)
+                     and utils.is_account_history_head(t.id, t.split_id, t.metadata, v_account_id, p_status, v_tags)
+                   order by t.date desc, t.created_at desc, t.id desc
+                   limit p_limit
+              )
+              union all
+              (
+                  select t.id, t.date, t.created_at
+                    from data.transactions t
+                   where t.credit_account_id = v_account_id
+                     and t.debit_account_id <> v_account_id
+                     and (t.date, t.created_at, t.id) < (v_after_date, v_after_created_at, v_after_id)
+                     and (t.date, t.created_at, t.id) > (v_before_date, v_before_created_at, v_before_id)
+                     and t.deleted_at is null
+                     and (p_status is null or t.status = p_status)
+                     and utils.is_account_history_head(t.id, t.split_id, t.metadata, v_account_id, p_status, v_tags)
+                   order by t.date desc, t.created_at desc, t.id desc
+                   limit p_limit
+              )
+              order by head_date desc, head_created_at desc, head_id desc
+              limit p_limit
+          ) h;
+    end if;
+
+    select array_agg(distinct t.split_id) into v_split_ids
+      from data.transactions t
+     where t.id = any (v_heads)
+       and t.split_id is not null;
+
+    if coalesce(p_with_total, false) then
+        select count(*) into v_total
+          from data.transactions t
+         where (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+           and t.deleted_at is null
+           and (p_status is null or t.status = p_status)
+           and utils.is_account_history_head(t.id, t.split_id, t.metadata, v_account_id, p_status, v_tags);
+    end if;
+
+    return query
+    with legs as (
+        select
+            t.id,
+            t.date,
+            t.created_at,
+            t.description,
+            t.amount,
+            t.uuid,
+            t.split_id,
+            t.status,
+            t.reconciliation_id,
+            o.name as other_name,
+            p.name as payee_name,
+            t.metadata -> 'tags' as tag_list,
+            o.uuid as other_uuid,
+            -- determine transaction type based on account's internal type
+            case
+                when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                     (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+                then 'inflow'
+                else 'outflow'
+            end as type
+        from
+            data.transactions t
+            join data.accounts o on o.id = case
+                when t.debit_account_id = v_account_id then t.credit_account_id
+                else t.debit_account_id
+            end
+            left join data.payees p on p.id = t.payee_id
+        where
+            -- the heads of the page and the other legs of its splits
+            (t.id = any (v_heads) or t.split_id = any (v_split_ids))
+            and (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+            and t.deleted_at is null
+            and (p_status is null or t.status = p_status)
+    ),
+    page as (
+        select
+            min(l.date) as date,
+            string_agg(distinct l.other_name, ', ' order by l.other_name) as category,
+            -- a single leg keeps its memo, several legs show the split
+            case when count(*) = 1 then min(l.description) else s.description end as description,
+            min(l.type) as type,
+            sum(l.amount)::bigint as amount,
+            -- running balance from the balance snapshot of the head leg
+            coalesce(
+                (
+                    select bs.balance
+                    from data.balance_snapshots bs
+                    where bs.transaction_id = max(l.id)
+                      and bs.account_id = v_account_id
+                      and bs.user_data = p_user_data
+                ), 0
+            ) as running_balance,
+            coalesce(s.uuid, min(l.uuid)) as uuid,
+            case when count(distinct l.other_uuid) = 1 then min(l.other_uuid) end as category_uuid,
+            s.id is not null as split,
+            -- a split is pending while any of its splits is
+            case when bool_or(l.status = 'pending') then 'pending' else 'posted' end as status,
+            bool_or(l.reconciliation_id is not null) as reconciled,
+            min(l.payee_name) as payee,
+            utils.tag_union(jsonb_agg(l.tag_list)) as tags,
+            -- the keys of the head leg
+            max(l.date) as sort_date,
+            max(l.created_at) as sort_created_at,
+            max(l.id) as sort_id
+        from
+            legs l
+            left join data.split_transactions s on s.id = l.split_id
+        group by
+            s.id,
+            case when s.id is null then l.id end
+    )
+    select
+        pg.date,
+        pg.category,
+        pg.description,
+        pg.type,
+        pg.amount,
+        pg.running_balance,
+        pg.uuid,
+        pg.category_uuid,
+        pg.split,
+        pg.status,
+        pg.reconciled,
+        pg.payee,
+        pg.tags,
+        v_total
+    from
+        page pg
+    order by
+        pg.sort_date desc,
+        pg.sort_created_at desc,
+        pg.sort_id desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- the position of a transaction, or of a split, in the history of an
+-- account: the keys the history is sorted by. raises PB004 when the account
+-- has no such transaction, e.g. once it is deleted
+create or replace function utils.get_account_history_key(
+    p_account_id bigint,
+    p_uuid text,
+    p_user_data text,
+    out r_date date,
+    out r_created_at timestamptz,
+    out r_id bigint
+) as
+$$
+begin
+    select min(t.date), max(t.created_at), max(t.id)
+      into r_date, r_created_at, r_id
+      from data.transactions t
+           left join data.split_transactions s on s.id = t.split_id
+     where (t.uuid = p_uuid or s.uuid = p_uuid)
+       and (t.debit_account_id = p_account_id or t.credit_account_id = p_account_id)
+       and t.user_data = p_user_data
+       and t.deleted_at is null;
+
+    if r_id is null then
+        raise exception 'Transaction not found: %', p_uuid
+            using errcode = 'PB004';
+    end if;
+end;
+$$ language plpgsql stable security definer;
+
+-- account history with running balances. the legs of a split are shown as
+-- one row carrying the uuid of the split; on the bank side its category lists
+-- every category of the split and category_uuid is null. p_status keeps only
+-- 'pending' or 'posted' transactions; reconciled marks the transactions locked
+-- by a reconciliation and payee names the payee of the transaction. tags
+-- lists the tags of the transaction, or of every leg of a split, and p_tags
+-- keeps only the transactions carrying all of the tags given.
+-- the history is paged by keyset: p_after keeps the transactions older than
+-- the one with that uuid, p_before the newer ones, and p_limit keeps at most
+-- that many rows next to the cursor, all when null. running balances come
+-- from the balance snapshots, so every page carries them. total_count counts
+-- the rows matching p_status and p_tags over every page when p_with_total is
+-- set, and is null otherwise
+create or replace function utils.get_account_transactions(
+    p_account_uuid text,
+    p_status text default null,
+    p_tags text[] default null,
+    p_after text default null,
+    p_before text default null,
+    p_limit int default null,
+    p_with_total boolean default false,
+    p_user_data text default utils.get_user()
+)
+returns table (
+    date date,
+    category text,
+    description text,
+    type text,
+    amount bigint,
+    running_balance bigint,
+    uuid text,
+    category_uuid text,
+    split boolean,
+    status text,
+    reconciled boolean,
+    payee text,
+    tags text[],
+    total_count bigint
+) as $$
+declare
+    v_account_id bigint;
+    v_internal_type text;
+    v_tags text[];
+    v_after_date date;
+    v_after_created_at timestamptz;
+    v_after_id bigint;
+    v_before_date date;
+    v_before_created_at timestamptz;
+    v_before_id bigint;
+    v_total bigint;
+begin
+    if p_status is not null and p_status not in ('pending', 'posted') then
+        raise exception 'Invalid transaction status: %. Must be "pending" or "posted"', p_status
+            using errcode = 'PB013';
+    end if;
+
+    if p_limit is not null and (p_limit < 1 or p_limit > 1000) then
+        raise exception 'Page size must be between 1 and 1000, got %', p_limit
+            using errcode = 'PB013';
+    end if;
+
+    v_tags := utils.normalize_tags(p_tags);
+
+    -- resolve the account uuid to its internal id and validate ownership
+    select a.id, a.internal_type
+    into v_account_id, v_internal_type
+    from data.accounts a
+    where a.uuid = p_account_uuid and a.user_data = p_user_data;
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    if p_after is not null then
+        select k.r_date, k.r_created_at, k.r_id
+          into v_after_date, v_after_created_at, v_after_id
+          from utils.get_account_history_key(v_account_id, p_after, p_user_data) k;
+    end if;
+    if p_before is not null then
+        select k.r_date, k.r_created_at, k.r_id
+          into v_before_date, v_before_created_at, v_before_id
+          from utils.get_account_history_key(v_account_id, p_before, p_user_data) k;
+    end if;
+
+    if coalesce(p_with_total, false) then
+        select count(*) into v_total
+          from (
+              select 1
+                from data.transactions t
+               where (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+                 and t.deleted_at is null
+                 and (p_status is null or t.status = p_status)
+               group by
+                   t.split_id,
+                   case when t.split_id is null then t.id end
+              having
+                   utils.tag_union(jsonb_agg(t.metadata -> 'tags')) @> v_tags
+          ) h;
+    end if;
+
+    return query
+    with legs as (
+        select
+            t.id,
+            t.date,
+            t.created_at,
+            t.description,
+            t.amount,
+            t.uuid,
+            t.split_id,
+            t.status,
+            t.reconciliation_id,
+            o.name as other_name,
+            p.name as payee_name,
+            t.metadata -> 'tags' as tag_list,
+            o.uuid as other_uuid,
+            -- determine transaction type based on account's internal type
+            case
+                when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                     (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+                then 'inflow'
+                else 'outflow'
+            end as type
+        from
+            data.transactions t
+            join data.accounts o on o.id = case
+                when t.debit_account_id = v_account_id then t.credit_account_id
+                else t.debit_account_id
+            end
+            left join data.payees p on p.id = t.payee_id
+        where
+            (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+            and t.deleted_at is null
+            and (p_status is null or t.status = p_status)
+            -- narrow the scan to the dates of the page; the keys are
+            -- compared exactly once the legs are grouped
+            and (p_after is null or t.date <= v_after_date)
+            and (p_before is null or t.date >= v_before_date)
+    ),
+    history as (
+        select
+            min(l.date) as date,
+            string_agg(distinct l.other_name, ', ' order by l.other_name) as category,
+            -- a single leg keeps its memo, several legs show the split
+            case when count(*) = 1 then min(l.description) else s.description end as description,
+            min(l.type) as type,
+            sum(l.amount)::bigint as amount,
+            -- running balance from the balance snapshot of the last leg
+            coalesce(
+                (
+                    select bs.balance
+                    from data.balance_snapshots bs
+                    where bs.transaction_id = max(l.id)
+                      and bs.account_id = v_account_id
+                      and bs.user_data = p_user_data
+                ), 0
+            ) as running_balance,
+            coalesce(s.uuid, min(l.uuid)) as uuid,
+            case when count(distinct l.other_uuid) = 1 then min(l.other_uuid) end as category_uuid,
+            s.id is not null as split,
+            -- a split is pending while any of its splits is
+            case when bool_or(l.status = 'pending') then 'pending' else 'posted' end as status,
+            bool_or(l.reconciliation_id is not null) as reconciled,
+            min(l.payee_name) as payee,
+            utils.tag_union(jsonb_agg(l.tag_list)) as tags,
+            -- the sort keys, also compared to the cursors
+            min(l.date) as sort_date,
+            max(l.created_at) as sort_created_at,
+            max(l.id) as sort_id
+        from
+            legs l
+            left join data.split_transactions s on s.id = l.split_id
+        group by
+            s.id,
+            case when s.id is null then l.id end
+        having
+            utils.tag_union(jsonb_agg(l.tag_list)) @> v_tags
+            and (
+                p_after is null
+                or (min(l.date), max(l.created_at), max(l.id)) < (v_after_date, v_after_created_at, v_after_id)
+            )
+            and (
+                p_before is null
+                or (min(l.date), max(l.created_at), max(l.id)) > (v_before_date, v_before_created_at, v_before_id)
+            )
+    ),
+    page as (
+        select *
+        from history h
+        order by
+            -- paging back from p_before keeps the rows next to it, the
+            -- oldest of the newer ones
+            case when p_before is not null and p_after is null then h.sort_date end,
+            case when p_before is not null and p_after is null then h.sort_created_at end,
+            case when p_before is not null and p_after is null then h.sort_id end,
+            h.sort_date desc,
+            h.sort_created_at desc,
+            h.sort_id desc
+        limit p_limit
+    )
+    select
+        pg.date,
+        pg.category,
+        pg.description,
+        pg.type,
+        pg.amount,
+        pg.running_balance,
+        pg.uuid,
+        pg.category_uuid,
+        pg.split,
+        pg.status,
+        pg.reconciled,
+        pg.payee,
+        pg.tags,
+        v_total
+    from
+        page pg
+    order by
+        pg.sort_date desc,
+        pg.sort_created_at desc,
+        pg.sort_id desc;
+end;
+$$ language plpgsql stable security definer;
+
+drop function if exists utils.is_account_history_head(bigint, bigint, jsonb, bigint, text, text[]);
+drop index if exists data.idx_transactions_credit_history;
+drop index if exists data.idx_transactions_debit_history;
+
+-- +goose StatementEnd
@@ -0,0 +1,506 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- refuse to change a transaction that was corrected or deleted already, or
+-- that reverses one: reversing it again would count it twice.
+-- p_transaction_uuid names a transaction or a split transaction, which is
+-- reversed when any of its splits is
+create or replace function utils.assert_not_reversed(
+    p_transaction_uuid text
+) returns void as $$
+begin
+    if exists (
+        select 1
+        from data.transactions t
+             join data.transaction_log l on t.id in (l.original_transaction_id, l.reversal_transaction_id)
+        where t.user_data = utils.get_user()
+          and (
+              t.uuid = p_transaction_uuid
+              or t.split_id in (
+                  select s.id from data.split_transactions s where s.uuid = p_transaction_uuid
+                  union
+                  select x.split_id from data.transactions x where x.uuid = p_transaction_uuid
+              )
+          )
+    ) then
+        raise exception 'Transaction % was corrected or deleted, or reverses one, and cannot be changed again',
+            p_transaction_uuid
+            using errcode = 'PB013';
+    end if;
+end;
+$$ language plpgsql stable security definer;
+
+-- reverse every leg of a split under a new split and log each reversal.
+-- a split that was corrected or deleted, or that reverses one, is refused
+create or replace function utils.reverse_split_transaction(
+    p_split_id bigint,
+    p_description_prefix text,
+    p_mutation_type text,
+    p_reason text
+) returns bigint as $$
+declare
+    v_split data.split_transactions;
+    v_leg data.transactions;
+    v_reversal_split_id bigint;
+    v_reversal_id bigint;
+begin
+    select * into v_split
+    from data.split_transactions
+    where id = p_split_id
+      and user_data = utils.get_user();
+
+    -- a split is reversed once
+    perform utils.assert_not_reversed(v_split.uuid);
+
+    insert into data.split_transactions (date, description, type, account_id, ledger_id, user_data)
+    values (
+        v_split.date,
+        p_description_prefix || v_split.description,
+        case v_split.type when 'inflow' then 'outflow' else 'inflow' end,
+        v_split.account_id,
+        v_split.ledger_id,
+        utils.get_user()
+    ) returning id into v_reversal_split_id;
+
+    for v_leg in
+        select * from data.transactions
+        where split_id = p_split_id
+          and user_data = utils.get_user()
+        order by id
+    loop
+        insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, split_id, user_data)
+        values (
+            v_leg.amount,
+            p_description_prefix || v_leg.description,
+            v_leg.date,
+            v_leg.credit_account_id,  -- swap accounts to reverse
+            v_leg.debit_account_id,
+            v_leg.ledger_id,
+            v_reversal_split_id,
+            utils.get_user()
+        ) returning id into v_reversal_id;
+
+        insert into data.transaction_log (original_transaction_id, reversal_transaction_id, mutation_type, reason)
+        values (v_leg.id, v_reversal_id, p_mutation_type, p_reason);
+    end loop;
+
+    return v_reversal_split_id;
+end;
+$$ language plpgsql security definer;
+
+-- correct a transaction with error codes, once
+create or replace function utils.correct_transaction(
+    p_original_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_category_uuid text,
+    p_new_amount bigint,
+    p_new_description text,
+    p_new_date date,
+    p_reason text default 'Transaction correction'
+) returns int as $$
+declare
+    v_original_tx data.transactions;
+    v_ledger_uuid text;
+    v_account_id bigint;
+    v_category_id bigint;
+    v_reversal_id bigint;
+    v_correction_id bigint;
+    v_debit_account_id bigint;
+    v_credit_account_id bigint;
+begin
+    -- get original transaction
+    select t.* into v_original_tx
+    from data.transactions t
+    where t.uuid = p_original_uuid
+      and t.user_data = utils.get_user();
+
+    if v_original_tx.id is null then
+        raise exception 'Transaction not found: %', p_original_uuid
+            using errcode = 'PB004';
+    end if;
+
+    -- a transaction is reversed once
+    perform utils.assert_not_reversed(p_original_uuid);
+
+    -- get ledger uuid
+    select l.uuid into v_ledger_uuid
+    from data.ledgers l
+    where l.id = v_original_tx.ledger_id;
+
+    -- resolve account id from uuid
+    select id into v_account_id
+    from data.accounts
+    where uuid = p_new_account_uuid and user_data = utils.get_user();
+
+    if v_account_id is null then
+        raise exception 'Account not found: %', p_new_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    -- handle category lookup (default to Unassigned if null)
+    if p_new_category_uuid is null then
+        declare
+            v_unassigned_uuid text;
+        begin
+            select utils.find_category(v_ledger_uuid, 'Unassigned') into v_unassigned_uuid;
+
+            if v_unassigned_uuid is null then
+                raise exception 'Could not find "Unassigned" category in ledger for current user'
+                    using errcode = 'PB003';
+            end if;
+
+            -- convert UUID to ID
+            select id into v_category_id
+            from data.accounts
+            where uuid = v_unassigned_uuid and user_data = utils.get_user();
+        end;
+    else
+        -- find the specified category
+        select id into v_category_id
+        from data.accounts
+        where uuid = p_new_category_uuid and user_data = utils.get_user();
+
+        if v_category_id is null then
+            raise exception 'Category not found: %', p_new_category_uuid
+                using errcode = 'PB003';
+        end if;
+    end if;
+
+    -- determine debit/credit based on transaction type (budgeting logic)
+    case p_new_type
+        when 'outflow' then
+            -- money leaves account, goes to category
+            v_debit_account_id := v_category_id;
+            v_credit_account_id := v_account_id;
+        when 'inflow' then
+            -- money enters account, comes from category
+            v_debit_account_id := v_account_id;
+            v_credit_account_id := v_category_id;
+        else
+            raise exception 'Invalid transaction type: %. Must be "inflow" or "outflow"', p_new_type
+                using errcode = 'PB012';
+    end case;
+
+    -- create reversal transaction (opposite of original)
+    insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, user_data)
+    values (
+        v_original_tx.amount,
+        'REVERSAL: ' || v_original_tx.description,
+        v_original_tx.date,
+        v_original_tx.credit_account_id,  -- swap accounts to reverse
+        v_original_tx.debit_account_id,
+        v_original_tx.ledger_id,
+        utils.get_user()
+    ) returning id into v_reversal_id;
+
+    -- create corrected transaction with new values
+    insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, user_data)
+    values (
+        p_new_amount,
+        p_new_description,
+        p_new_date,
+        v_debit_account_id,
+        v_credit_account_id,
+        v_original_tx.ledger_id,
+        utils.get_user()
+    ) returning id into v_correction_id;
+
+    -- record the correction in transaction log
+    insert into data.transaction_log (original_transaction_id, reversal_transaction_id, correction_transaction_id, mutation_type, reason)
+    values (
+        v_original_tx.id,
+        v_reversal_id,
+        v_correction_id,
+        'correction',
+        p_reason
+    );
+
+    return v_correction_id;
+end;
+$$ language plpgsql security definer;
+
+-- delete a transaction with error codes, once
+create or replace function utils.delete_transaction(
+    p_original_uuid text,
+    p_reason text default 'Transaction deleted'
+) returns int as $$
+declare
+    v_original_tx data.transactions;
+    v_reversal_id bigint;
+begin
+    -- get original transaction
+    select * into v_original_tx
+    from data.transactions
+    where uuid = p_original_uuid
+      and user_data = utils.get_user();
+
+    if v_original_tx.id is null then
+        raise exception 'Transaction not found: %', p_original_uuid
+            using errcode = 'PB004';
+    end if;
+
+    -- a transaction is reversed once
+    perform utils.assert_not_reversed(p_original_uuid);
+
+    -- create reversal transaction to cancel original
+    insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, user_data)
+    values (
+        v_original_tx.amount,
+        'DELETED: ' || v_original_tx.description,
+        v_original_tx.date,
+        v_original_tx.credit_account_id,  -- swap accounts to reverse
+        v_original_tx.debit_account_id,
+        v_original_tx.ledger_id,
+        utils.get_user()
+    ) returning id into v_reversal_id;
+
+    -- record the deletion in transaction log
+    insert into data.transaction_log (original_transaction_id, reversal_transaction_id, mutation_type, reason)
+    values (
+        v_original_tx.id,
+        v_reversal_id,
+        'deletion',
+        p_reason
+    );
+
+    return v_reversal_id;
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- reverse every leg of a split under a new split and log each reversal
+create or replace function utils.reverse_split_transaction(
+    p_split_id bigint,
+    p_description_prefix text,
+    p_mutation_type text,
+    p_reason text
+) returns bigint as $$
+declare
+    v_split data.split_transactions;
+    v_leg data.transactions;
+    v_reversal_split_id bigint;
+    v_reversal_id bigint;
+begin
+    select * into v_split
+    from data.split_transactions
+    where id = p_split_id
+      and user_data = utils.get_user();
+
+    insert into data.split_transactions (date, description, type, account_id, ledger_id, user_data)
+    values (
+        v_split.date,
+        p_description_prefix || v_split.description,
+        case v_split.type when 'inflow' then 'outflow' else 'inflow' end,
+        v_split.account_id,
+        v_split.ledger_id,
+        utils.get_user()
+    ) returning id into v_reversal_split_id;
+
+    for v_leg in
+        select * from data.transactions
+        where split_id = p_split_id
+          and user_data = utils.get_user()
+        order by id
+    loop
+        insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, split_id, user_data)
+        values (
+            v_leg.amount,
+            p_description_prefix || v_leg.description,
+            v_leg.date,
+            v_leg.credit_account_id,  -- swap accounts to reverse
+            v_leg.debit_account_id,
+            v_leg.ledger_id,
+            v_reversal_split_id,
+            utils.get_user()
+        ) returning id into v_reversal_id;
+
+        insert into data.transaction_log (original_transaction_id, reversal_transaction_id, mutation_type, reason)
+        values (v_leg.id, v_reversal_id, p_mutation_type, p_reason);
+    end loop;
+
+    return v_reversal_split_id;
+end;
+$$ language plpgsql security definer;
+
+-- correct a transaction with error codes
+create or replace function utils.correct_transaction(
+    p_original_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_category_uuid text,
+    p_new_amount bigint,
+    p_new_description text,
+    p_new_date date,
+    p_reason text default 'Transaction correction'
+) returns int as $$
+declare
+    v_original_tx data.transactions;
+    v_ledger_uuid text;
+    v_account_id bigint;
+    v_category_id bigint;
+    v_reversal_id bigint;
+    v_correction_id bigint;
+    v_debit_account_id bigint;
+    v_credit_account_id bigint;
+begin
+    -- get original transaction
+    select t.* into v_original_tx
+    from data.transactions t
+    where t.uuid = p_original_uuid
+      and t.user_data = utils.get_user();
+
+    if v_original_tx.id is null then
+        raise exception 'Transaction not found: %', p_original_uuid
+            using errcode = 'PB004';
+    end if;
+
+    -- get ledger uuid
+    select l.uuid into v_ledger_uuid
+    from data.ledgers l
+    where l.id = v_original_tx.ledger_id;
+
+    -- resolve account id from uuid
+    select id into v_account_id
+    from data.accounts
+    where uuid = p_new_account_uuid and user_data = utils.get_user();
+
+    if v_account_id is null then
+        raise exception 'Account not found: %', p_new_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    -- handle category lookup (default to Unassigned if null)
+    if p_new_category_uuid is null then
+        declare
+            v_unassigned_uuid text;
+        begin
+            select utils.find_category(v_ledger_uuid, 'Unassigned') into v_unassigned_uuid;
+
+            if v_unassigned_uuid is null then
+                raise exception 'Could not find "Unassigned" category in ledger for current user'
+                    using errcode = 'PB003';
+            end if;
+
+            -- convert UUID to ID
+            select id into v_category_id
+            from data.accounts
+            where uuid = v_unassigned_uuid and user_data = utils.get_user();
+        end;
+    else
+        -- find the specified category
+        select id into v_category_id
+        from data.accounts
+        where uuid = p_new_category_uuid and user_data = utils.get_user();
+
+        if v_category_id is null then
+            raise exception 'Category not found: %', p_new_category_uuid
+                using errcode = 'PB003';
+        end if;
+    end if;
+
+    -- determine debit/credit based on transaction type (budgeting logic)
+    case p_new_type
+        when 'outflow' then
+            -- money leaves account, goes to category
+            v_debit_account_id := v_category_id;
+            v_credit_account_id := v_account_id;
+        when 'inflow' then
+            -- money enters account, comes from category
+            v_debit_account_id := v_account_id;
+            v_credit_account_id := v_category_id;
+        else
+            raise exception 'Invalid transaction type: %. Must be "inflow" or "outflow"', p_new_type
+                using errcode = 'PB012';
+    end case;
+
+    -- create reversal transaction (opposite of original)
+    insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, user_data)
+    values (
+        v_original_tx.amount,
+        'REVERSAL: ' || v_original_tx.description,
+        v_original_tx.date,
+        v_original_tx.credit_account_id,  -- swap accounts to reverse
+        v_original_tx.debit_account_id,
+        v_original_tx.ledger_id,
+        utils.get_user()
+    ) returning id into v_reversal_id;
+
+    -- create corrected transaction with new values
+    insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, user_data)
+    values (
+        p_new_amount,
+        p_new_description,
+        p_new_date,
+        v_debit_account_id,
+        v_credit_account_id,
+        v_original_tx.ledger_id,
+        utils.get_user()
+    ) returning id into v_correction_id;
+
+    -- record the correction in transaction log
+    insert into data.transaction_log (original_transaction_id, reversal_transaction_id, correction_transaction_id, mutation_type, reason)
+    values (
+        v_original_tx.id,
+        v_reversal_id,
+        v_correction_id,
+        'correction',
+        p_reason
+    );
+
+    return v_correction_id;
+end;
+$$ language plpgsql security definer;
+
+-- delete a transaction with error codes
+create or replace function utils.delete_transaction(
+    p_original_uuid text,
+    p_reason text default 'Transaction deleted'
+) returns int as $$
+declare
+    v_original_tx data.transactions;
+    v_reversal_id bigint;
+begin
+    -- get original transaction
+    select * into v_original_tx
+    from data.transactions
+    where uuid = p_original_uuid
+      and user_data = utils.get_user();
+
+    if v_original_tx.id is null then
+        raise exception 'Transaction not found: %', p_original_uuid
+            using errcode = 'PB004';
+    end if;
+
+    -- create reversal transaction to cancel original
+    insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, user_data)
+    values (
+        v_original_tx.amount,
+        'DELETED: ' || v_original_tx.description,
+        v_original_tx.date,
+        v_original_tx.credit_account_id,  -- swap accounts to reverse
+        v_original_tx.debit_account_id,
+        v_original_tx.ledger_id,
+        utils.get_user()
+    ) returning id into v_reversal_id;
+
+    -- record the deletion in transaction log
+    insert into data.transaction_log (original_transaction_id, reversal_transaction_id, mutation_type, reason)
+    values (
+        v_original_tx.id,
+        v_reversal_id,
+        'deletion',
+        p_reason
+    );
+
+    return v_reversal_id;
+end;
+$$ language plpgsql security definer;
+
+drop function if exists utils.assert_not_reversed(text);
+
+-- +goose StatementEnd
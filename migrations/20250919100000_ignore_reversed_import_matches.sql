@@ -0,0 +1,189 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- list the transactions of an account in a date range, as import duplicate candidates
+create or replace function api.find_import_candidates(
+    p_account_uuid text,
+    p_start_date date,
+    p_end_date date
+) returns table (
+    uuid text,
+    date date,
+    type text,
+    amount bigint,
+    description text,
+    metadata jsonb
+) as
+$$
+declare
+    v_account_id    bigint;
+    v_internal_type text;
+begin
+    select a.id, a.internal_type
+      into v_account_id, v_internal_type
+      from data.accounts a
+     where a.uuid = p_account_uuid
+       and a.user_data = utils.get_user();
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return query
+    select t.uuid,
+           t.date,
+           -- same type rules as utils.get_account_transactions
+           case
+               when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                    (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+               then 'inflow'
+               else 'outflow'
+           end,
+           t.amount,
+           coalesce(t.description, ''),
+           t.metadata
+      from data.transactions t
+     where (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+       and t.deleted_at is null
+       and t.date between p_start_date and p_end_date
+       -- deleted and corrected transactions and their reversals no longer
+       -- stand for a bank line; a correction does
+       and not exists (
+           select 1
+           from data.transaction_log tl
+           where t.id in (tl.original_transaction_id, tl.reversal_transaction_id)
+       )
+     order by t.date, t.id;
+end;
+$$ language plpgsql stable security definer;
+
+-- find the fingerprints an account has already seen in earlier batches.
+-- only lines that were recorded count: a line skipped as a likely duplicate
+-- is matched afresh on every import, so a narrower date window or the force
+-- policy can still record it
+create or replace function api.find_import_fingerprints(
+    p_account_uuid text,
+    p_fingerprints text[]
+) returns table (
+    fingerprint text,
+    action text,
+    transaction_uuid text,
+    batch_uuid text
+) as
+$$
+begin
+    return query
+    select distinct on (i.fingerprint)
+           i.fingerprint,
+           i.action,
+           t.uuid,
+           b.uuid
+      from data.import_batch_items i
+           join data.import_batches b on b.id = i.batch_id
+           join data.accounts a on a.id = b.account_id
+           left join data.transactions t on t.id = i.transaction_id
+     where a.uuid = p_account_uuid
+       and a.user_data = utils.get_user()
+       and i.fingerprint = any (p_fingerprints)
+       and i.action <> 'skipped'
+       -- a line whose transaction was deleted or corrected since is matched
+       -- afresh, against the correction if there is one
+       and not exists (
+           select 1
+           from data.transaction_log tl
+           where tl.original_transaction_id = i.transaction_id
+       )
+     order by i.fingerprint, b.created_at desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- list the transactions of an account in a date range, as import duplicate candidates
+create or replace function api.find_import_candidates(
+    p_account_uuid text,
+    p_start_date date,
+    p_end_date date
+) returns table (
+    uuid text,
+    date date,
+    type text,
+    amount bigint,
+    description text,
+    metadata jsonb
+) as
+$$
+declare
+    v_account_id    bigint;
+    v_internal_type text;
+begin
+    select a.id, a.internal_type
+      into v_account_id, v_internal_type
+      from data.accounts a
+     where a.uuid = p_account_uuid
+       and a.user_data = utils.get_user();
+
+    if v_account_id is null then
+        raise exception 'Account with UUID % not found for current user', p_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    return query
+    select t.uuid,
+           t.date,
+           -- same type rules as utils.get_account_transactions
+           case
+               when (v_internal_type = 'asset_like' and t.debit_account_id = v_account_id) or
+                    (v_internal_type = 'liability_like' and t.credit_account_id = v_account_id)
+               then 'inflow'
+               else 'outflow'
+           end,
+           t.amount,
+           coalesce(t.description, ''),
+           t.metadata
+      from data.transactions t
+     where (t.debit_account_id = v_account_id or t.credit_account_id = v_account_id)
+       and t.deleted_at is null
+       and t.date between p_start_date and p_end_date
+     order by t.date, t.id;
+end;
+$$ language plpgsql stable security definer;
+
+-- find the fingerprints an account has already seen in earlier batches.
+-- only lines that were recorded count: a line skipped as a likely duplicate
+-- is matched afresh on every import, so a narrower date window or the force
+-- policy can still record it
+create or replace function api.find_import_fingerprints(
+    p_account_uuid text,
+    p_fingerprints text[]
+) returns table (
+    fingerprint text,
+    action text,
+    transaction_uuid text,
+    batch_uuid text
+) as
+$$
+begin
+    return query
+    select distinct on (i.fingerprint)
+           i.fingerprint,
+           i.action,
+           t.uuid,
+           b.uuid
+      from data.import_batch_items i
+           join data.import_batches b on b.id = i.batch_id
+           join data.accounts a on a.id = b.account_id
+           left join data.transactions t on t.id = i.transaction_id
+     where a.uuid = p_account_uuid
+       and a.user_data = utils.get_user()
+       and i.fingerprint = any (p_fingerprints)
+       and i.action <> 'skipped'
+     order by i.fingerprint, b.created_at desc;
+end;
+$$ language plpgsql stable security definer;
+
+-- +goose StatementEnd
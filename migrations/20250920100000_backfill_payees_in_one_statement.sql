@@ -0,0 +1,174 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- give the transactions of a ledger recorded without a payee the payee their
+-- normalized description names, creating the payees missing. transfers,
+-- assignments, split legs, reversals and reconciliation adjustments are left
+-- alone. payees without a default category take the latest category other
+-- than Unassigned they were recorded with. returns the number of
+-- transactions linked. a single statement rather than a temporary table,
+-- which a second call in the same session found already there
+create or replace function utils.backfill_payees(
+    p_ledger_id bigint,
+    p_user_data text = utils.get_user()
+) returns int as
+$$
+declare
+    v_linked int;
+begin
+    with candidate as (
+        select
+            t.id,
+            t.date,
+            c.id as category_id,
+            c.name as category_name,
+            utils.normalize_payee_name(t.description) as payee_name
+        from
+            data.transactions t
+            join data.accounts o on o.id in (t.debit_account_id, t.credit_account_id)
+                                and o.type in ('asset', 'liability')
+            join data.accounts c on c.id in (t.debit_account_id, t.credit_account_id)
+                                and c.type = 'equity'
+        where
+            t.ledger_id = p_ledger_id
+            and t.user_data = p_user_data
+            and t.payee_id is null
+            and t.split_id is null
+            and utils.normalize_payee_name(t.description) is not null
+            and not exists (
+                select 1 from data.transaction_log tl where tl.reversal_transaction_id = t.id
+            )
+            and not exists (
+                select 1 from data.reconciliations r where r.adjustment_transaction_id = t.id
+            )
+    ),
+    latest_category as (
+        select distinct on (lower(b.payee_name)) lower(b.payee_name) as payee_key, b.category_id
+          from candidate b
+         where b.category_name <> 'Unassigned'
+         order by lower(b.payee_name), b.date desc, b.id desc
+    ),
+    inserted as (
+        insert into data.payees (name, ledger_id, user_data, default_category_id)
+        select n.payee_name, p_ledger_id, p_user_data, lc.category_id
+          from (
+                   select distinct on (lower(b.payee_name)) b.payee_name
+                     from candidate b
+                    order by lower(b.payee_name), b.payee_name
+               ) n
+               left join latest_category lc on lc.payee_key = lower(n.payee_name)
+        on conflict (ledger_id, lower(name)) do nothing
+        returning id, name
+    ),
+    defaulted as (
+        update data.payees p
+           set default_category_id = lc.category_id
+          from latest_category lc
+         where p.ledger_id = p_ledger_id
+           and p.user_data = p_user_data
+           and p.default_category_id is null
+           and lower(p.name) = lc.payee_key
+    ),
+    payee as (
+        select i.id, i.name from inserted i
+        union all
+        select p.id, p.name
+          from data.payees p
+         where p.ledger_id = p_ledger_id
+           and p.user_data = p_user_data
+    ),
+    linked as (
+        update data.transactions t
+           set payee_id = p.id
+          from candidate b
+               join payee p on lower(p.name) = lower(b.payee_name)
+         where t.id = b.id
+        returning t.id
+    )
+    select count(*) into v_linked from linked;
+
+    return v_linked;
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- give the transactions of a ledger recorded without a payee the payee their
+-- normalized description names, creating the payees missing. transfers,
+-- assignments, split legs, reversals and reconciliation adjustments are left
+-- alone. payees without a default category take the latest category other
+-- than Unassigned they were recorded with. returns the number of
+-- transactions linked
+create or replace function utils.backfill_payees(
+    p_ledger_id bigint,
+    p_user_data text = utils.get_user()
+) returns int as
+$$
+declare
+    v_linked int;
+begin
+    create temporary table payee_backfill as
+    select
+        t.id,
+        t.date,
+        c.id as category_id,
+        c.name as category_name,
+        utils.normalize_payee_name(t.description) as payee_name
+    from
+        data.transactions t
+        join data.accounts o on o.id in (t.debit_account_id, t.credit_account_id)
+                            and o.type in ('asset', 'liability')
+        join data.accounts c on c.id in (t.debit_account_id, t.credit_account_id)
+                            and c.type = 'equity'
+    where
+        t.ledger_id = p_ledger_id
+        and t.user_data = p_user_data
+        and t.payee_id is null
+        and t.split_id is null
+        and not exists (
+            select 1 from data.transaction_log tl where tl.reversal_transaction_id = t.id
+        )
+        and not exists (
+            select 1 from data.reconciliations r where r.adjustment_transaction_id = t.id
+        );
+
+    delete from payee_backfill b where b.payee_name is null;
+
+    insert into data.payees (name, ledger_id, user_data)
+    select distinct on (lower(b.payee_name)) b.payee_name, p_ledger_id, p_user_data
+      from payee_backfill b
+     order by lower(b.payee_name), b.payee_name
+    on conflict (ledger_id, lower(name)) do nothing;
+
+    update data.transactions t
+       set payee_id = p.id
+      from payee_backfill b
+           join data.payees p on p.ledger_id = p_ledger_id
+                             and p.user_data = p_user_data
+                             and lower(p.name) = lower(b.payee_name)
+     where t.id = b.id;
+    get diagnostics v_linked = row_count;
+
+    update data.payees p
+       set default_category_id = (
+               select b.category_id
+                 from payee_backfill b
+                where lower(b.payee_name) = lower(p.name)
+                  and b.category_name <> 'Unassigned'
+                order by b.date desc, b.id desc
+                limit 1
+           )
+     where p.ledger_id = p_ledger_id
+       and p.user_data = p_user_data
+       and p.default_category_id is null;
+
+    drop table payee_backfill;
+
+    return v_linked;
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
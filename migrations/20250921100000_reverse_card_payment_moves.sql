@@ -0,0 +1,653 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- reverse the move out of a card's payment category that a card payment
+-- made, once the payment is corrected or deleted: the money set aside for the
+-- card is back in its payment category
+create or replace function utils.reverse_card_payment_move(
+    p_transfer_id bigint,
+    p_description_prefix text,
+    p_mutation_type text,
+    p_reason text
+) returns void as $$
+declare
+    v_move data.transactions;
+    v_reversal_id bigint;
+begin
+    for v_move in
+        select m.*
+        from data.transactions t
+             join data.transactions m on m.ledger_id = t.ledger_id
+                                     and m.metadata->>'transfer_uuid' = t.uuid
+        where t.id = p_transfer_id
+          and m.user_data = utils.get_user()
+          and not exists (
+              select 1 from data.transaction_log l where l.original_transaction_id = m.id
+          )
+        order by m.id
+    loop
+        insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, user_data)
+        values (
+            v_move.amount,
+            p_description_prefix || v_move.description,
+            v_move.date,
+            v_move.credit_account_id,  -- swap accounts to reverse
+            v_move.debit_account_id,
+            v_move.ledger_id,
+            utils.get_user()
+        ) returning id into v_reversal_id;
+
+        insert into data.transaction_log (original_transaction_id, reversal_transaction_id, mutation_type, reason)
+        values (v_move.id, v_reversal_id, p_mutation_type, p_reason);
+    end loop;
+end;
+$$ language plpgsql security definer;
+
+-- correct a transaction with error codes, once. correcting a card payment
+-- also reverses its payment category move
+create or replace function utils.correct_transaction(
+    p_original_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_category_uuid text,
+    p_new_amount bigint,
+    p_new_description text,
+    p_new_date date,
+    p_reason text default 'Transaction correction'
+) returns int as $$
+declare
+    v_original_tx data.transactions;
+    v_ledger_uuid text;
+    v_account_id bigint;
+    v_category_id bigint;
+    v_reversal_id bigint;
+    v_correction_id bigint;
+    v_debit_account_id bigint;
+    v_credit_account_id bigint;
+begin
+    -- get original transaction
+    select t.* into v_original_tx
+    from data.transactions t
+    where t.uuid = p_original_uuid
+      and t.user_data = utils.get_user();
+
+    if v_original_tx.id is null then
+        raise exception 'Transaction not found: %', p_original_uuid
+            using errcode = 'PB004';
+    end if;
+
+    -- a transaction is reversed once
+    perform utils.assert_not_reversed(p_original_uuid);
+
+    -- get ledger uuid
+    select l.uuid into v_ledger_uuid
+    from data.ledgers l
+    where l.id = v_original_tx.ledger_id;
+
+    -- resolve account id from uuid
+    select id into v_account_id
+    from data.accounts
+    where uuid = p_new_account_uuid and user_data = utils.get_user();
+
+    if v_account_id is null then
+        raise exception 'Account not found: %', p_new_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    -- handle category lookup (default to Unassigned if null)
+    if p_new_category_uuid is null then
+        declare
+            v_unassigned_uuid text;
+        begin
+            select utils.find_category(v_ledger_uuid, 'Unassigned') into v_unassigned_uuid;
+
+            if v_unassigned_uuid is null then
+                raise exception 'Could not find "Unassigned" category in ledger for current user'
+                    using errcode = 'PB003';
+            end if;
+
+            -- convert UUID to ID
+            select id into v_category_id
+            from data.accounts
+            where uuid = v_unassigned_uuid and user_data = utils.get_user();
+        end;
+    else
+        -- find the specified category
+        select id into v_category_id
+        from data.accounts
+        where uuid = p_new_category_uuid and user_data = utils.get_user();
+
+        if v_category_id is null then
+            raise exception 'Category not found: %', p_new_category_uuid
+                using errcode = 'PB003';
+        end if;
+    end if;
+
+    -- determine debit/credit based on transaction type (budgeting logic)
+    case p_new_type
+        when 'outflow' then
+            -- money leaves account, goes to category
+            v_debit_account_id := v_category_id;
+            v_credit_account_id := v_account_id;
+        when 'inflow' then
+            -- money enters account, comes from category
+            v_debit_account_id := v_account_id;
+            v_credit_account_id := v_category_id;
+        else
+            raise exception 'Invalid transaction type: %. Must be "inflow" or "outflow"', p_new_type
+                using errcode = 'PB012';
+    end case;
+
+    -- create reversal transaction (opposite of original)
+    insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, user_data)
+    values (
+        v_original_tx.amount,
+        'REVERSAL: ' || v_original_tx.description,
+        v_original_tx.date,
+        v_original_tx.credit_account_id,  -- swap accounts to reverse
+        v_original_tx.debit_account_id,
+        v_original_tx.ledger_id,
+        utils.get_user()
+    ) returning id into v_reversal_id;
+
+    -- create corrected transaction with new values
+    insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, user_data)
+    values (
+        p_new_amount,
+        p_new_description,
+        p_new_date,
+        v_debit_account_id,
+        v_credit_account_id,
+        v_original_tx.ledger_id,
+        utils.get_user()
+    ) returning id into v_correction_id;
+
+    -- record the correction in transaction log
+    insert into data.transaction_log (original_transaction_id, reversal_transaction_id, correction_transaction_id, mutation_type, reason)
+    values (
+        v_original_tx.id,
+        v_reversal_id,
+        v_correction_id,
+        'correction',
+        p_reason
+    );
+
+    -- a corrected card payment gives back what it took from the payment category
+    perform utils.reverse_card_payment_move(v_original_tx.id, 'REVERSAL: ', 'correction', p_reason);
+
+    return v_correction_id;
+end;
+$$ language plpgsql security definer;
+
+-- delete a transaction with error codes, once. deleting a card payment
+-- also reverses its payment category move
+create or replace function utils.delete_transaction(
+    p_original_uuid text,
+    p_reason text default 'Transaction deleted'
+) returns int as $$
+declare
+    v_original_tx data.transactions;
+    v_reversal_id bigint;
+begin
+    -- get original transaction
+    select * into v_original_tx
+    from data.transactions
+    where uuid = p_original_uuid
+      and user_data = utils.get_user();
+
+    if v_original_tx.id is null then
+        raise exception 'Transaction not found: %', p_original_uuid
+            using errcode = 'PB004';
+    end if;
+
+    -- a transaction is reversed once
+    perform utils.assert_not_reversed(p_original_uuid);
+
+    -- create reversal transaction to cancel original
+    insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, user_data)
+    values (
+        v_original_tx.amount,
+        'DELETED: ' || v_original_tx.description,
+        v_original_tx.date,
+        v_original_tx.credit_account_id,  -- swap accounts to reverse
+        v_original_tx.debit_account_id,
+        v_original_tx.ledger_id,
+        utils.get_user()
+    ) returning id into v_reversal_id;
+
+    -- record the deletion in transaction log
+    insert into data.transaction_log (original_transaction_id, reversal_transaction_id, mutation_type, reason)
+    values (
+        v_original_tx.id,
+        v_reversal_id,
+        'deletion',
+        p_reason
+    );
+
+    -- a deleted card payment gives back what it took from the payment category
+    perform utils.reverse_card_payment_move(v_original_tx.id, 'DELETED: ', 'deletion', p_reason);
+
+    return v_reversal_id;
+end;
+$$ language plpgsql security definer;
+
+-- record money moving between two bank or credit card accounts. the money
+-- a card payment category holds is summed from all its transactions, which
+-- includes the reversals of corrected and deleted ones
+create or replace function utils.add_transfer(
+    p_ledger_uuid text,
+    p_from_account_uuid text,
+    p_to_account_uuid text,
+    p_amount bigint,
+    p_date date,
+    p_memo text default null,
+    p_user_data text = utils.get_user()
+) returns text as
+$$
+declare
+    v_ledger_id     bigint;
+    v_from          data.accounts;
+    v_to            data.accounts;
+    v_description   text;
+    v_transfer      data.transactions;
+    v_unassigned_id bigint;
+    v_available     bigint;
+begin
+    perform utils.validate_transaction_data(p_amount, p_date);
+
+    v_description := coalesce(trim(p_memo), '');
+    if char_length(v_description) > 500 then
+        raise exception 'Transfer memo cannot exceed 500 characters. Current length: %',
+            char_length(v_description)
+            using errcode = 'PB013';
+    end if;
+
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    select a.* into v_from
+      from data.accounts a
+     where a.uuid = p_from_account_uuid
+       and a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data;
+
+    if v_from.id is null then
+        raise exception 'Account with UUID % not found in ledger % for current user', p_from_account_uuid, p_ledger_uuid
+            using errcode = 'PB002';
+    end if;
+
+    select a.* into v_to
+      from data.accounts a
+     where a.uuid = p_to_account_uuid
+       and a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data;
+
+    if v_to.id is null then
+        raise exception 'Account with UUID % not found in ledger % for current user', p_to_account_uuid, p_ledger_uuid
+            using errcode = 'PB002';
+    end if;
+
+    if v_from.type = 'equity' or v_to.type = 'equity' then
+        raise exception 'Transfers are between bank and credit card accounts; use api.move_between_categories for categories'
+            using errcode = 'PB013';
+    end if;
+
+    if v_from.id = v_to.id then
+        raise exception 'Cannot transfer money from account % to itself', v_from.name
+            using errcode = 'PB013';
+    end if;
+
+    if v_description = '' then
+        v_description := format('Transfer from %s to %s', v_from.name, v_to.name);
+    end if;
+
+    insert into data.transactions (ledger_id, description, date, amount, debit_account_id, credit_account_id, user_data)
+    values (v_ledger_id, v_description, p_date, p_amount, v_to.id, v_from.id, p_user_data)
+    returning * into v_transfer;
+
+    -- a card payment spends what was set aside in the payment category.
+    -- reversals are summed too: they net out what was corrected or deleted
+    if v_from.type = 'asset' and v_to.type = 'liability' and v_to.payment_category_id is not null then
+        select coalesce(sum(case when t.credit_account_id = v_to.payment_category_id then t.amount else -t.amount end), 0)
+          into v_available
+          from data.transactions t
+         where t.ledger_id = v_ledger_id
+           and (t.debit_account_id = v_to.payment_category_id or t.credit_account_id = v_to.payment_category_id);
+
+        if least(v_available, p_amount) > 0 then
+            select a.id into v_unassigned_id
+              from data.accounts a
+             where a.ledger_id = v_ledger_id and a.type = 'equity' and a.name = 'Unassigned';
+
+            insert into data.transactions (
+                ledger_id, description, date, amount, debit_account_id, credit_account_id, metadata, user_data
+            )
+            values (
+                v_ledger_id,
+                format('Payment to %s', v_to.name),
+                p_date,
+                least(v_available, p_amount),
+                v_to.payment_category_id,
+                v_unassigned_id,
+                jsonb_build_object('transfer_uuid', v_transfer.uuid),
+                p_user_data
+            );
+        end if;
+    end if;
+
+    return v_transfer.uuid;
+end;
+$$ language plpgsql security definer;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- record money moving between two bank or credit card accounts: credit the
+-- source, debit the destination. budget categories are not touched, except
+-- for payments from a bank account to a credit card: they also move the
+-- amount, up to what it holds, from the payment category of the card to
+-- Unassigned, where credit overspending is tracked
+create or replace function utils.add_transfer(
+    p_ledger_uuid text,
+    p_from_account_uuid text,
+    p_to_account_uuid text,
+    p_amount bigint,
+    p_date date,
+    p_memo text default null,
+    p_user_data text = utils.get_user()
+) returns text as
+$$
+declare
+    v_ledger_id     bigint;
+    v_from          data.accounts;
+    v_to            data.accounts;
+    v_description   text;
+    v_transfer      data.transactions;
+    v_unassigned_id bigint;
+    v_available     bigint;
+begin
+    perform utils.validate_transaction_data(p_amount, p_date);
+
+    v_description := coalesce(trim(p_memo), '');
+    if char_length(v_description) > 500 then
+        raise exception 'Transfer memo cannot exceed 500 characters. Current length: %',
+            char_length(v_description)
+            using errcode = 'PB013';
+    end if;
+
+    select l.id into v_ledger_id
+      from data.ledgers l
+     where l.uuid = p_ledger_uuid
+       and l.user_data = p_user_data;
+
+    if v_ledger_id is null then
+        raise exception 'Ledger with UUID % not found for current user', p_ledger_uuid
+            using errcode = 'PB001';
+    end if;
+
+    select a.* into v_from
+      from data.accounts a
+     where a.uuid = p_from_account_uuid
+       and a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data;
+
+    if v_from.id is null then
+        raise exception 'Account with UUID % not found in ledger % for current user', p_from_account_uuid, p_ledger_uuid
+            using errcode = 'PB002';
+    end if;
+
+    select a.* into v_to
+      from data.accounts a
+     where a.uuid = p_to_account_uuid
+       and a.ledger_id = v_ledger_id
+       and a.user_data = p_user_data;
+
+    if v_to.id is null then
+        raise exception 'Account with UUID % not found in ledger % for current user', p_to_account_uuid, p_ledger_uuid
+            using errcode = 'PB002';
+    end if;
+
+    if v_from.type = 'equity' or v_to.type = 'equity' then
+        raise exception 'Transfers are between bank and credit card accounts; use api.move_between_categories for categories'
+            using errcode = 'PB013';
+    end if;
+
+    if v_from.id = v_to.id then
+        raise exception 'Cannot transfer money from account % to itself', v_from.name
+            using errcode = 'PB013';
+    end if;
+
+    if v_description = '' then
+        v_description := format('Transfer from %s to %s', v_from.name, v_to.name);
+    end if;
+
+    insert into data.transactions (ledger_id, description, date, amount, debit_account_id, credit_account_id, user_data)
+    values (v_ledger_id, v_description, p_date, p_amount, v_to.id, v_from.id, p_user_data)
+    returning * into v_transfer;
+
+    -- a card payment spends what was set aside in the payment category
+    if v_from.type = 'asset' and v_to.type = 'liability' and v_to.payment_category_id is not null then
+        select coalesce(sum(case when t.credit_account_id = v_to.payment_category_id then t.amount else -t.amount end), 0)
+          into v_available
+          from data.transactions t
+         where t.ledger_id = v_ledger_id
+           and (t.debit_account_id = v_to.payment_category_id or t.credit_account_id = v_to.payment_category_id)
+           and t.deleted_at is null;
+
+        if least(v_available, p_amount) > 0 then
+            select a.id into v_unassigned_id
+              from data.accounts a
+             where a.ledger_id = v_ledger_id and a.type = 'equity' and a.name = 'Unassigned';
+
+            insert into data.transactions (
+                ledger_id, description, date, amount, debit_account_id, credit_account_id, metadata, user_data
+            )
+            values (
+                v_ledger_id,
+                format('Payment to %s', v_to.name),
+                p_date,
+                least(v_available, p_amount),
+                v_to.payment_category_id,
+                v_unassigned_id,
+                jsonb_build_object('transfer_uuid', v_transfer.uuid),
+                p_user_data
+            );
+        end if;
+    end if;
+
+    return v_transfer.uuid;
+end;
+$$ language plpgsql security definer;
+
+-- correct a transaction with error codes, once
+create or replace function utils.correct_transaction(
+    p_original_uuid text,
+    p_new_type text,
+    p_new_account_uuid text,
+    p_new_category_uuid text,
+    p_new_amount bigint,
+    p_new_description text,
+    p_new_date date,
+    p_reason text default 'Transaction correction'
+) returns int as $$
+declare
+    v_original_tx data.transactions;
+    v_ledger_uuid text;
+    v_account_id bigint;
+    v_category_id bigint;
+    v_reversal_id bigint;
+    v_correction_id bigint;
+    v_debit_account_id bigint;
+    v_credit_account_id bigint;
+begin
+    -- get original transaction
+    select t.* into v_original_tx
+    from data.transactions t
+    where t.uuid = p_original_uuid
+      and t.user_data = utils.get_user();
+
+    if v_original_tx.id is null then
+        raise exception 'Transaction not found: %', p_original_uuid
+            using errcode = 'PB004';
+    end if;
+
+    -- a transaction is reversed once
+    perform utils.assert_not_reversed(p_original_uuid);
+
+    -- get ledger uuid
+    select l.uuid into v_ledger_uuid
+    from data.ledgers l
+    where l.id = v_original_tx.ledger_id;
+
+    -- resolve account id from uuid
+    select id into v_account_id
+    from data.accounts
+    where uuid = p_new_account_uuid and user_data = utils.get_user();
+
+    if v_account_id is null then
+        raise exception 'Account not found: %', p_new_account_uuid
+            using errcode = 'PB002';
+    end if;
+
+    -- handle category lookup (default to Unassigned if null)
+    if p_new_category_uuid is null then
+        declare
+            v_unassigned_uuid text;
+        begin
+            select utils.find_category(v_ledger_uuid, 'Unassigned') into v_unassigned_uuid;
+
+            if v_unassigned_uuid is null then
+                raise exception 'Could not find "Unassigned" category in ledger for current user'
+                    using errcode = 'PB003';
+            end if;
+
+            -- convert UUID to ID
+            select id into v_category_id
+            from data.accounts
+            where uuid = v_unassigned_uuid and user_data = utils.get_user();
+        end;
+    else
+        -- find the specified category
+        select id into v_category_id
+        from data.accounts
+        where uuid = p_new_category_uuid and user_data = utils.get_user();
+
+        if v_category_id is null then
+            raise exception 'Category not found: %', p_new_category_uuid
+                using errcode = 'PB003';
+        end if;
+    end if;
+
+    -- determine debit/credit based on transaction type (budgeting logic)
+    case p_new_type
+        when 'outflow' then
+            -- money leaves account, goes to category
+            v_debit_account_id := v_category_id;
+            v_credit_account_id := v_account_id;
+        when 'inflow' then
+            -- money enters account, comes from category
+            v_debit_account_id := v_account_id;
+            v_credit_account_id := v_category_id;
+        else
+            raise exception 'Invalid transaction type: %. Must be "inflow" or "outflow"', p_new_type
+                using errcode = 'PB012';
+    end case;
+
+    -- create reversal transaction (opposite of original)
+    insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, user_data)
+    values (
+        v_original_tx.amount,
+        'REVERSAL: ' || v_original_tx.description,
+        v_original_tx.date,
+        v_original_tx.credit_account_id,  -- swap accounts to reverse
+        v_original_tx.debit_account_id,
+        v_original_tx.ledger_id,
+        utils.get_user()
+    ) returning id into v_reversal_id;
+
+    -- create corrected transaction with new values
+    insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, user_data)
+    values (
+        p_new_amount,
+        p_new_description,
+        p_new_date,
+        v_debit_account_id,
+        v_credit_account_id,
+        v_original_tx.ledger_id,
+        utils.get_user()
+    ) returning id into v_correction_id;
+
+    -- record the correction in transaction log
+    insert into data.transaction_log (original_transaction_id, reversal_transaction_id, correction_transaction_id, mutation_type, reason)
+    values (
+        v_original_tx.id,
+        v_reversal_id,
+        v_correction_id,
+        'correction',
+        p_reason
+    );
+
+    return v_correction_id;
+end;
+$$ language plpgsql security definer;
+
+-- delete a transaction with error codes, once
+create or replace function utils.delete_transaction(
+    p_original_uuid text,
+    p_reason text default 'Transaction deleted'
+) returns int as $$
+declare
+    v_original_tx data.transactions;
+    v_reversal_id bigint;
+begin
+    -- get original transaction
+    select * into v_original_tx
+    from data.transactions
+    where uuid = p_original_uuid
+      and user_data = utils.get_user();
+
+    if v_original_tx.id is null then
+        raise exception 'Transaction not found: %', p_original_uuid
+            using errcode = 'PB004';
+    end if;
+
+    -- a transaction is reversed once
+    perform utils.assert_not_reversed(p_original_uuid);
+
+    -- create reversal transaction to cancel original
+    insert into data.transactions (amount, description, date, debit_account_id, credit_account_id, ledger_id, user_data)
+    values (
+        v_original_tx.amount,
+        'DELETED: ' || v_original_tx.description,
+        v_original_tx.date,
+        v_original_tx.credit_account_id,  -- swap accounts to reverse
+        v_original_tx.debit_account_id,
+        v_original_tx.ledger_id,
+        utils.get_user()
+    ) returning id into v_reversal_id;
+
+    -- record the deletion in transaction log
+    insert into data.transaction_log (original_transaction_id, reversal_transaction_id, mutation_type, reason)
+    values (
+        v_original_tx.id,
+        v_reversal_id,
+        'deletion',
+        p_reason
+    );
+
+    return v_reversal_id;
+end;
+$$ language plpgsql security definer;
+
+drop function if exists utils.reverse_card_payment_move(bigint, text, text, text);
+
+-- +goose StatementEnd
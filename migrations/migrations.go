@@ -0,0 +1,52 @@
+// Package migrations embeds the pgbudget SQL migrations so that they can be
+// applied from a compiled binary, without a source checkout or the goose CLI.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// FS holds every goose migration of the repository.
+//
+//go:embed *.sql
+var FS embed.FS
+
+// NewProvider returns a goose provider for the embedded migrations. A
+// Postgres advisory lock guards every run, so several application instances
+// can migrate the same database on startup.
+func NewProvider(db *sql.DB, opts ...goose.ProviderOption) (*goose.Provider, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration lock: %w", err)
+	}
+
+	opts = append([]goose.ProviderOption{goose.WithSessionLocker(locker)}, opts...)
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, FS, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration provider: %w", err)
+	}
+
+	return provider, nil
+}
+
+// Apply brings the database schema up to date by running every pending
+// migration. It is a no-op when the schema is current.
+func Apply(ctx context.Context, db *sql.DB) error {
+	provider, err := NewProvider(db)
+	if err != nil {
+		return err
+	}
+
+	if _, err := provider.Up(ctx); err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+
+	return nil
+}
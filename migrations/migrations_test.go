@@ -0,0 +1,58 @@
+package migrations_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/j0lvera/pgbudget/migrations"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	is_ "github.com/matryer/is"
+)
+
+// TestEmbeddedMigrations makes sure every migration on disk is compiled into
+// the binary and recognised by goose, without needing a database.
+func TestEmbeddedMigrations(t *testing.T) {
+	is := is_.New(t)
+
+	onDisk, err := filepath.Glob("*.sql")
+	is.NoErr(err)
+	is.True(len(onDisk) > 0) // should find migrations next to the package
+
+	t.Run(
+		"FS", func(t *testing.T) {
+			is := is_.New(t)
+
+			for _, name := range onDisk {
+				want, err := os.ReadFile(name)
+				is.NoErr(err)
+
+				got, err := migrations.FS.ReadFile(name)
+				is.NoErr(err)                       // migration should be embedded
+				is.Equal(string(got), string(want)) // embedded copy should match the file
+			}
+		},
+	)
+
+	t.Run(
+		"Provider", func(t *testing.T) {
+			is := is_.New(t)
+
+			// sql.Open does not connect, so no database is needed to list sources
+			db, err := sql.Open("pgx", "postgres://localhost/pgbudget")
+			is.NoErr(err)
+			t.Cleanup(func() { db.Close() })
+
+			provider, err := migrations.NewProvider(db)
+			is.NoErr(err)
+
+			sources := provider.ListSources()
+			is.Equal(len(sources), len(onDisk)) // every file should be a goose migration
+
+			for i := 1; i < len(sources); i++ {
+				is.True(sources[i-1].Version < sources[i].Version) // versions should be ascending
+			}
+		},
+	)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+)
+
+const monthCloseUsage = `Usage: pgbudget month close -period <YYYYMM> [flags]
+
+Closes a month: the rollover rules of the ledger are applied to the balance
+every category has at the end of the month. Each change is recorded as a
+transaction between the category and Income or Unassigned on the first day
+of the next month. Months close once, in order, and only once they are over.
+
+Flags:
+`
+
+const monthRulesUsage = `Usage: pgbudget month rules [flags]
+
+Shows the rollover rules of a ledger, after changing the ones given:
+
+  -leftover  keep: positive balances roll over (default)
+             income: they return to Income
+  -cash      what covers money overspent from bank accounts
+  -credit    what covers money overspent on credit cards
+             income: Income, leaving less to budget next month
+             unassigned: Unassigned, tracking it as unfunded debt
+             keep: the negative balance rolls over
+
+Cash overspending is covered by Income and credit overspending by
+Unassigned until the rules are changed.
+
+Flags:
+`
+
+const monthListUsage = `Usage: pgbudget month list [flags]
+
+Lists the closed months of a ledger, newest first.
+
+Flags:
+`
+
+func runMonth(ctx context.Context, args []string) error {
+	return runSubcommand(
+		ctx, "month", []subcommand{
+			{"close", "apply the rollover rules to a month", runMonthClose},
+			{"rules", "show or change the rollover rules", runMonthRules},
+			{"list", "list the closed months", runMonthList},
+		}, args,
+	)
+}
+
+func runMonthClose(ctx context.Context, args []string) error {
+	fs := newFlagSet("month close", monthCloseUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	period := fs.String("period", "", "month as YYYYMM")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	if *period == "" {
+		return errors.New("missing -period")
+	}
+	if _, err := time.Parse("200601", *period); err != nil {
+		return fmt.Errorf("invalid -period %q: use YYYYMM", *period)
+	}
+
+	var recorded []client.MonthCloseTransaction
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		recorded, err = c.CloseMonth(ctx, s.ledger, *period)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	t := table{
+		header: []string{"category", "kind", "amount", "transaction"},
+		footer: []string{strconv.Itoa(len(recorded)) + " transactions recorded"},
+	}
+	for _, r := range recorded {
+		t.rows = append(t.rows, []string{r.CategoryName, r.Kind, client.FormatAmount(r.Amount), r.TransactionUUID})
+	}
+	return s.print(recorded, t)
+}
+
+func runMonthRules(ctx context.Context, args []string) error {
+	fs := newFlagSet("month rules", monthRulesUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	leftover := fs.String("leftover", "", "keep or income")
+	cash := fs.String("cash", "", "income, unassigned or keep")
+	credit := fs.String("credit", "", "income, unassigned or keep")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+
+	var rules *client.RolloverRules
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		rules, err = c.GetRolloverRules(ctx, s.ledger)
+		if err != nil || (*leftover == "" && *cash == "" && *credit == "") {
+			return err
+		}
+
+		if *leftover != "" {
+			rules.Leftover = client.RolloverRule(*leftover)
+		}
+		if *cash != "" {
+			rules.CashOverspending = client.RolloverRule(*cash)
+		}
+		if *credit != "" {
+			rules.CreditOverspending = client.RolloverRule(*credit)
+		}
+		return c.SetRolloverRules(ctx, s.ledger, *rules)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(
+		rules, table{
+			header: []string{"leftover", "cash overspending", "credit overspending"},
+			rows: [][]string{{
+				string(rules.Leftover), string(rules.CashOverspending), string(rules.CreditOverspending),
+			}},
+		},
+	)
+}
+
+func runMonthList(ctx context.Context, args []string) error {
+	fs := newFlagSet("month list", monthListUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+
+	var months []client.ClosedMonth
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		months, err = c.GetClosedMonths(ctx, s.ledger)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	t := table{header: []string{"period", "closed", "transactions"}}
+	for _, m := range months {
+		t.rows = append(t.rows, []string{m.Period, m.ClosedAt.Format(time.DateTime), strconv.Itoa(m.Transactions)})
+	}
+	return s.print(months, t)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// outputFormat is how the budgeting commands print their results.
+type outputFormat string
+
+const (
+	// formatTable aligns columns for reading in a terminal.
+	formatTable outputFormat = "table"
+	// formatJSON encodes the client structs, with amounts in cents.
+	formatJSON outputFormat = "json"
+	// formatCSV writes the table with a header row.
+	formatCSV outputFormat = "csv"
+)
+
+func parseFormat(name string) (outputFormat, error) {
+	switch f := outputFormat(name); f {
+	case formatTable, formatJSON, formatCSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q: use table, json or csv", name)
+}
+
+// table is the tabular form of a command's result. footer lines follow
+// the table in table format only.
+type table struct {
+	header []string
+	rows   [][]string
+	footer []string
+}
+
+// write prints v as JSON, or t as an aligned table or CSV.
+func write(w io.Writer, f outputFormat, v any, t table) error {
+	switch f {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(t.header); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(t.rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := make([]string, len(t.header))
+	for i, h := range t.header {
+		header[i] = strings.ToUpper(h)
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range t.rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(t.footer) > 0 {
+		fmt.Fprintln(w)
+		for _, line := range t.footer {
+			fmt.Fprintln(w, line)
+		}
+	}
+	return nil
+}
+
+// parseDate reads a YYYY-MM-DD date; empty means today.
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), nil
+	}
+	d, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD", value)
+	}
+	return d, nil
+}
+
+// deref returns the string s points to, or "".
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	is_ "github.com/matryer/is"
+)
+
+func TestWrite(t *testing.T) {
+	rows := []struct {
+		Name   string `json:"name"`
+		Amount int64  `json:"amount"`
+	}{{"Groceries", 4210}, {"Rent, flat", -100000}}
+	tbl := table{
+		header: []string{"name", "amount"},
+		rows:   [][]string{{"Groceries", "42.10"}, {"Rent, flat", "-1000.00"}},
+		footer: []string{"Total: -957.90"},
+	}
+
+	t.Run(
+		"Table", func(t *testing.T) {
+			is := is_.New(t)
+
+			var out strings.Builder
+			is.NoErr(write(&out, formatTable, rows, tbl))
+			is.Equal(
+				out.String(),
+				"NAME        AMOUNT\n"+
+					"Groceries   42.10\n"+
+					"Rent, flat  -1000.00\n"+
+					"\nTotal: -957.90\n",
+			)
+		},
+	)
+
+	t.Run(
+		"CSV", func(t *testing.T) {
+			is := is_.New(t)
+
+			var out strings.Builder
+			is.NoErr(write(&out, formatCSV, rows, tbl))
+			is.Equal(out.String(), "name,amount\nGroceries,42.10\n\"Rent, flat\",-1000.00\n")
+		},
+	)
+
+	t.Run(
+		"JSON", func(t *testing.T) {
+			is := is_.New(t)
+
+			var out strings.Builder
+			is.NoErr(write(&out, formatJSON, rows, tbl))
+			is.True(strings.Contains(out.String(), `"amount": -100000`)) // cents, not the table text
+		},
+	)
+}
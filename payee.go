@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+)
+
+const payeeAddUsage = `Usage: pgbudget payee add -name <name> [flags]
+
+Adds a payee to a ledger. Transactions that name the payee without a
+category are recorded in its -category.
+
+Flags:
+`
+
+const payeeListUsage = `Usage: pgbudget payee list [flags]
+
+Lists the payees of a ledger with the number of their transactions, what
+was paid to them and received from them, and their last transaction date.
+
+Flags:
+`
+
+const payeeRenameUsage = `Usage: pgbudget payee rename -payee <uuid> -name <name> [flags]
+
+Renames a payee. To combine two payees, merge them.
+
+Flags:
+`
+
+const payeeCategoryUsage = `Usage: pgbudget payee category -payee <uuid> [-category <name|uuid>] [flags]
+
+Sets the default category of a payee, or clears it without -category.
+
+Flags:
+`
+
+const payeeMergeUsage = `Usage: pgbudget payee merge -payee <uuid> -into <uuid> [flags]
+
+Moves the transactions of a payee to another and deletes it. The payee kept
+takes the default category of the other when it has none.
+
+Flags:
+`
+
+const payeeBackfillUsage = `Usage: pgbudget payee backfill [flags]
+
+Links the transactions recorded without a payee, such as imported ones, to
+the payee their description names once card prefixes, store numbers and
+reference numbers are dropped: "SQ *BLUE BOTTLE #0123" becomes Blue
+Bottle. Missing payees are created.
+
+Flags:
+`
+
+func runPayee(ctx context.Context, args []string) error {
+	return runSubcommand(
+		ctx, "payee", []subcommand{
+			{"add", "add a payee", runPayeeAdd},
+			{"list", "list the payees of a ledger with their totals", runPayeeList},
+			{"rename", "rename a payee", runPayeeRename},
+			{"category", "set the default category of a payee", runPayeeCategory},
+			{"merge", "merge a payee into another", runPayeeMerge},
+			{"backfill", "link transactions to payees from their descriptions", runPayeeBackfill},
+		}, args,
+	)
+}
+
+// payeeResult is printed by payee add.
+type payeeResult struct {
+	UUID string `json:"uuid"`
+}
+
+// countResult is printed by payee merge and payee backfill.
+type countResult struct {
+	Transactions int `json:"transactions"`
+}
+
+func runPayeeAdd(ctx context.Context, args []string) error {
+	fs := newFlagSet("payee add", payeeAddUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	name := fs.String("name", "", "name of the payee, e.g. Costco")
+	category := fs.String("category", "", "default category name or UUID")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("missing -name")
+	}
+
+	var result payeeResult
+	err := s.with(ctx, func(c *client.Client) error {
+		categoryUUID, err := resolveCategory(ctx, c, s.ledger, *category)
+		if err != nil {
+			return err
+		}
+		result.UUID, err = c.CreatePayee(
+			ctx, client.CreatePayeeParams{LedgerUUID: s.ledger, Name: *name, DefaultCategoryUUID: categoryUUID},
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(result, table{header: []string{"uuid"}, rows: [][]string{{result.UUID}}})
+}
+
+func runPayeeList(ctx context.Context, args []string) error {
+	fs := newFlagSet("payee list", payeeListUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+
+	var payees []client.Payee
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		payees, err = c.GetPayees(ctx, s.ledger)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	t := table{header: []string{"uuid", "name", "category", "transactions", "outflow", "inflow", "last"}}
+	for _, p := range payees {
+		last := ""
+		if p.LastDate != nil {
+			last = p.LastDate.Format(time.DateOnly)
+		}
+		t.rows = append(
+			t.rows, []string{
+				p.UUID, p.Name, deref(p.DefaultCategoryName), strconv.Itoa(p.Transactions),
+				client.FormatAmount(p.Outflow), client.FormatAmount(p.Inflow), last,
+			},
+		)
+	}
+	return s.print(payees, t)
+}
+
+func runPayeeRename(ctx context.Context, args []string) error {
+	fs := newFlagSet("payee rename", payeeRenameUsage)
+	s := registerSessionFlags(fs)
+	payee := fs.String("payee", "", "UUID of the payee to rename")
+	name := fs.String("name", "", "new name")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	switch {
+	case *payee == "":
+		return errors.New("missing -payee")
+	case *name == "":
+		return errors.New("missing -name")
+	}
+
+	return s.with(ctx, func(c *client.Client) error {
+		return c.RenamePayee(ctx, *payee, *name)
+	})
+}
+
+func runPayeeCategory(ctx context.Context, args []string) error {
+	fs := newFlagSet("payee category", payeeCategoryUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	payee := fs.String("payee", "", "payee UUID")
+	category := fs.String("category", "", "category name or UUID (default none)")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if *payee == "" {
+		return errors.New("missing -payee")
+	}
+	if *category != "" {
+		// category names are looked up in the ledger
+		if err := s.requireLedger(); err != nil {
+			return err
+		}
+	}
+
+	return s.with(ctx, func(c *client.Client) error {
+		categoryUUID, err := resolveCategory(ctx, c, s.ledger, *category)
+		if err != nil {
+			return err
+		}
+		return c.SetPayeeCategory(ctx, *payee, categoryUUID)
+	})
+}
+
+func runPayeeMerge(ctx context.Context, args []string) error {
+	fs := newFlagSet("payee merge", payeeMergeUsage)
+	s := registerSessionFlags(fs)
+	s.registerFormatFlag(fs)
+	payee := fs.String("payee", "", "UUID of the payee to merge and delete")
+	into := fs.String("into", "", "UUID of the payee to keep")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	switch {
+	case *payee == "":
+		return errors.New("missing -payee")
+	case *into == "":
+		return errors.New("missing -into")
+	}
+
+	var result countResult
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		result.Transactions, err = c.MergePayees(ctx, *payee, *into)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(
+		result, table{header: []string{"transactions moved"}, rows: [][]string{{strconv.Itoa(result.Transactions)}}},
+	)
+}
+
+func runPayeeBackfill(ctx context.Context, args []string) error {
+	fs := newFlagSet("payee backfill", payeeBackfillUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+
+	var result countResult
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		result.Transactions, err = c.BackfillPayees(ctx, s.ledger)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(
+		result, table{header: []string{"transactions linked"}, rows: [][]string{{strconv.Itoa(result.Transactions)}}},
+	)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/j0lvera/pgbudget/client"
+)
+
+const ruleAddUsage = `Usage: pgbudget rule add -name <name> [flags]
+
+Adds a rule that categorizes transactions recorded without a category. A
+rule matches a transaction when all of its conditions hold:
+
+  -match     a case-insensitive regular expression on the description
+  -min/-max  the amount, inclusive
+  -account   the bank account or credit card
+  -weekdays  the weekday of the date, 1 (Monday) to 7 (Sunday)
+
+and does what its actions say: -category assigns the category,
+-description replaces the description and -tags adds tags to the metadata.
+Rules are tried by -priority, lowest first, and only the first match
+applies.
+
+Flags:
+`
+
+const ruleListUsage = `Usage: pgbudget rule list [flags]
+
+Lists the rules of a ledger in the order they are tried.
+
+Flags:
+`
+
+const ruleDeleteUsage = `Usage: pgbudget rule delete -rule <uuid> [flags]
+
+Deletes a rule. The transactions it categorized are kept.
+
+Flags:
+`
+
+const ruleApplyUsage = `Usage: pgbudget rule apply [flags]
+
+Runs the rules of a ledger over the transactions recorded in Unassigned
+before the rules existed. Every match is corrected, so the original
+transaction stays in the transaction log.
+
+Flags:
+`
+
+func runRule(ctx context.Context, args []string) error {
+	return runSubcommand(
+		ctx, "rule", []subcommand{
+			{"add", "add a categorization rule", runRuleAdd},
+			{"list", "list the rules of a ledger", runRuleList},
+			{"delete", "delete a rule", runRuleDelete},
+			{"apply", "categorize Unassigned transactions", runRuleApply},
+		}, args,
+	)
+}
+
+// ruleResult is printed by rule add.
+type ruleResult struct {
+	UUID string `json:"uuid"`
+}
+
+func runRuleAdd(ctx context.Context, args []string) error {
+	fs := newFlagSet("rule add", ruleAddUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	name := fs.String("name", "", "name of the rule, e.g. Coffee")
+	priority := fs.Int("priority", 0, "lower priorities are tried first")
+	match := fs.String("match", "", "regular expression on the description")
+	minAmount := fs.String("min", "", "smallest amount matched, e.g. 5.00")
+	maxAmount := fs.String("max", "", "largest amount matched, e.g. 20.00")
+	account := fs.String("account", "", "UUID of the bank account or credit card matched")
+	weekdays := fs.String("weekdays", "", "comma-separated weekdays matched, e.g. 6,7")
+	category := fs.String("category", "", "category name or UUID to assign")
+	description := fs.String("description", "", "description to record instead")
+	tags := fs.String("tags", "", "comma-separated tags to add")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("missing -name")
+	}
+
+	params := client.AddCategoryRuleParams{
+		LedgerUUID:         s.ledger,
+		Name:               *name,
+		Priority:           *priority,
+		DescriptionPattern: *match,
+		AccountUUID:        *account,
+		SetDescription:     *description,
+	}
+	var err error
+	if params.MinAmount, err = parseAmountBound("-min", *minAmount); err != nil {
+		return err
+	}
+	if params.MaxAmount, err = parseAmountBound("-max", *maxAmount); err != nil {
+		return err
+	}
+	for _, day := range splitList(*weekdays) {
+		n, err := strconv.Atoi(day)
+		if err != nil {
+			return fmt.Errorf("invalid -weekdays %q: use numbers from 1 to 7", *weekdays)
+		}
+		params.Weekdays = append(params.Weekdays, n)
+	}
+	params.Tags = splitList(*tags)
+
+	var result ruleResult
+	err = s.with(ctx, func(c *client.Client) (err error) {
+		if params.CategoryUUID, err = resolveCategory(ctx, c, s.ledger, *category); err != nil {
+			return err
+		}
+		result.UUID, err = c.AddCategoryRule(ctx, params)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(result, table{header: []string{"uuid"}, rows: [][]string{{result.UUID}}})
+}
+
+func runRuleList(ctx context.Context, args []string) error {
+	fs := newFlagSet("rule list", ruleListUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+
+	var rules []client.CategoryRule
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		rules, err = c.GetCategoryRules(ctx, s.ledger)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	t := table{header: []string{"uuid", "name", "priority", "match", "amount", "weekdays", "category", "description", "tags"}}
+	for _, r := range rules {
+		t.rows = append(
+			t.rows, []string{
+				r.UUID, r.Name, strconv.Itoa(r.Priority), deref(r.DescriptionPattern), describeAmountRange(r),
+				joinInts(r.Weekdays), deref(r.CategoryName), deref(r.SetDescription), strings.Join(r.Tags, ","),
+			},
+		)
+	}
+	return s.print(rules, t)
+}
+
+func runRuleDelete(ctx context.Context, args []string) error {
+	fs := newFlagSet("rule delete", ruleDeleteUsage)
+	s := registerSessionFlags(fs)
+	rule := fs.String("rule", "", "UUID of the rule to delete")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if *rule == "" {
+		return errors.New("missing -rule")
+	}
+
+	return s.with(ctx, func(c *client.Client) error {
+		return c.DeleteCategoryRule(ctx, *rule)
+	})
+}
+
+func runRuleApply(ctx context.Context, args []string) error {
+	fs := newFlagSet("rule apply", ruleApplyUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+
+	var applied []client.RuleApplication
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		applied, err = c.ApplyCategoryRules(ctx, s.ledger)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	t := table{
+		header: []string{"transaction", "correction", "rule", "category"},
+		footer: []string{strconv.Itoa(len(applied)) + " transactions categorized"},
+	}
+	for _, a := range applied {
+		t.rows = append(t.rows, []string{a.TransactionUUID, a.CorrectionUUID, a.RuleUUID, a.CategoryUUID})
+	}
+	return s.print(applied, t)
+}
+
+// describeAmountRange words the amount condition of a rule for rule list,
+// e.g. "5.00..20.00".
+func describeAmountRange(r client.CategoryRule) string {
+	if r.MinAmount == nil && r.MaxAmount == nil {
+		return ""
+	}
+	var lo, hi string
+	if r.MinAmount != nil {
+		lo = client.FormatAmount(*r.MinAmount)
+	}
+	if r.MaxAmount != nil {
+		hi = client.FormatAmount(*r.MaxAmount)
+	}
+	return lo + ".." + hi
+}
+
+// parseAmountBound reads the optional amount of a -min or -max flag.
+func parseAmountBound(flag, value string) (*int64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	cents, err := client.ParseAmount(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", flag, err)
+	}
+	return &cents, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// joinInts joins numbers with commas.
+func joinInts(values []int) string {
+	items := make([]string, len(values))
+	for i, v := range values {
+		items[i] = strconv.Itoa(v)
+	}
+	return strings.Join(items, ",")
+}
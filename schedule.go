@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+	"github.com/j0lvera/pgbudget/scheduler"
+	"github.com/rs/zerolog"
+)
+
+const scheduleAddUsage = `Usage: pgbudget schedule add -name <name> -account <uuid> -amount <amount> [flags]
+
+Creates a recurring transaction, such as rent on the 1st or a biweekly
+paycheck. -frequency is one of:
+
+  monthly            on -day of the month (default the day of -date); days
+                     past the end of a short month fall on its last day
+  weekly             on the weekday of -date
+  last_business_day  on the last weekday of the month
+
+-date is the first day an occurrence may fall on and -every repeats every n
+months or weeks. Occurrences are recorded as pending transactions by
+pgbudget scheduler run.
+
+Flags:
+`
+
+const scheduleListUsage = `Usage: pgbudget schedule list [flags]
+
+Lists the schedules of a ledger with the date of their next occurrence.
+
+Flags:
+`
+
+const scheduleDeleteUsage = `Usage: pgbudget schedule delete -schedule <uuid> [flags]
+
+Deletes a schedule. The transactions it already recorded are kept.
+
+Flags:
+`
+
+const scheduleUpcomingUsage = `Usage: pgbudget schedule upcoming [flags]
+
+Lists the occurrences of the schedules of a ledger that are not recorded
+yet, in date order.
+
+Flags:
+`
+
+const schedulerRunUsage = `Usage: pgbudget scheduler run [flags]
+
+Records every occurrence of the schedules that is due as a pending
+transaction. Each occurrence is recorded once, so running again, or from
+several places at a time, is safe. Without -ledger every ledger of the user
+is run.
+
+With -interval the command keeps running and records due occurrences at
+that interval until interrupted, logging every run to standard error.
+
+Flags:
+`
+
+func runSchedule(ctx context.Context, args []string) error {
+	return runSubcommand(
+		ctx, "schedule", []subcommand{
+			{"add", "create a recurring transaction", runScheduleAdd},
+			{"list", "list the schedules of a ledger", runScheduleList},
+			{"delete", "delete a schedule", runScheduleDelete},
+			{"upcoming", "list occurrences not recorded yet", runScheduleUpcoming},
+		}, args,
+	)
+}
+
+func runScheduler(ctx context.Context, args []string) error {
+	return runSubcommand(
+		ctx, "scheduler", []subcommand{
+			{"run", "record due recurring transactions as pending", runSchedulerRun},
+		}, args,
+	)
+}
+
+// scheduleResult is printed by schedule add.
+type scheduleResult struct {
+	UUID string `json:"uuid"`
+}
+
+func runScheduleAdd(ctx context.Context, args []string) error {
+	fs := newFlagSet("schedule add", scheduleAddUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	name := fs.String("name", "", "name of the schedule, e.g. Rent")
+	f := registerTxFlags(fs)
+	frequency := fs.String("frequency", string(client.FrequencyMonthly), "monthly, weekly or last_business_day")
+	every := fs.Int("every", 1, "repeat every n months or weeks")
+	day := fs.Int("day", 0, "day of the month of a monthly schedule (default the day of -date)")
+	end := fs.String("end", "", "last date as YYYY-MM-DD (default none)")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("missing -name")
+	}
+	amount, startDate, err := f.parse()
+	if err != nil {
+		return err
+	}
+	var endDate time.Time
+	if *end != "" {
+		if endDate, err = parseDate(*end); err != nil {
+			return err
+		}
+	}
+
+	var result scheduleResult
+	err = s.with(ctx, func(c *client.Client) error {
+		categoryUUID, err := resolveCategory(ctx, c, s.ledger, *f.category)
+		if err != nil {
+			return err
+		}
+		result.UUID, err = c.CreateSchedule(
+			ctx, client.CreateScheduleParams{
+				LedgerUUID:   s.ledger,
+				Name:         *name,
+				Description:  *f.description,
+				Type:         client.TransactionType(*f.txType),
+				Amount:       amount,
+				AccountUUID:  *f.account,
+				CategoryUUID: categoryUUID,
+				Frequency:    client.Frequency(*frequency),
+				Every:        *every,
+				DayOfMonth:   *day,
+				StartDate:    startDate,
+				EndDate:      endDate,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(result, table{header: []string{"uuid"}, rows: [][]string{{result.UUID}}})
+}
+
+func runScheduleList(ctx context.Context, args []string) error {
+	fs := newFlagSet("schedule list", scheduleListUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+
+	var schedules []client.Schedule
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		schedules, err = c.GetSchedules(ctx, s.ledger)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	t := table{header: []string{"uuid", "name", "type", "amount", "repeats", "next"}}
+	for _, sc := range schedules {
+		next := "ended"
+		if sc.NextDate != nil {
+			next = sc.NextDate.Format(time.DateOnly)
+		}
+		t.rows = append(
+			t.rows, []string{
+				sc.UUID, sc.Name, string(sc.Type), client.FormatAmount(sc.Amount), describeRecurrence(sc), next,
+			},
+		)
+	}
+	return s.print(schedules, t)
+}
+
+// describeRecurrence words the recurrence of a schedule for schedule list,
+// e.g. "every 2 weeks".
+func describeRecurrence(sc client.Schedule) string {
+	unit := "month"
+	if sc.Frequency == client.FrequencyWeekly {
+		unit = "week"
+	}
+	every := "every " + unit
+	if sc.Every > 1 {
+		every = "every " + strconv.Itoa(sc.Every) + " " + unit + "s"
+	}
+
+	switch {
+	case sc.Frequency == client.FrequencyLastBusinessDay:
+		return every + ", last business day"
+	case sc.DayOfMonth != nil:
+		return every + ", day " + strconv.Itoa(*sc.DayOfMonth)
+	default:
+		return every
+	}
+}
+
+func runScheduleDelete(ctx context.Context, args []string) error {
+	fs := newFlagSet("schedule delete", scheduleDeleteUsage)
+	s := registerSessionFlags(fs)
+	schedule := fs.String("schedule", "", "UUID of the schedule to delete")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if *schedule == "" {
+		return errors.New("missing -schedule")
+	}
+
+	return s.with(ctx, func(c *client.Client) error {
+		return c.DeleteSchedule(ctx, *schedule)
+	})
+}
+
+func runScheduleUpcoming(ctx context.Context, args []string) error {
+	fs := newFlagSet("schedule upcoming", scheduleUpcomingUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	through := fs.String("through", "", "last date as YYYY-MM-DD (default 30 days from today)")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	day, err := parseDate(*through)
+	if err != nil {
+		return err
+	}
+	if *through == "" {
+		day = day.AddDate(0, 0, 30)
+	}
+
+	var upcoming []client.UpcomingTransaction
+	err = s.with(ctx, func(c *client.Client) (err error) {
+		upcoming, err = c.GetUpcomingTransactions(ctx, s.ledger, day)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	t := table{header: []string{"date", "schedule", "description", "type", "amount"}}
+	for _, u := range upcoming {
+		t.rows = append(
+			t.rows, []string{
+				u.Date.Format(time.DateOnly), u.Name, u.Description, string(u.Type), client.FormatAmount(u.Amount),
+			},
+		)
+	}
+	return s.print(upcoming, t)
+}
+
+func runSchedulerRun(ctx context.Context, args []string) error {
+	fs := newFlagSet("scheduler run", schedulerRunUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	interval := fs.Duration("interval", 0, "keep running and record due occurrences at this interval, e.g. 1h")
+	lead := fs.Duration("lead", 0, "also record occurrences due within this time from now, e.g. 72h")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if *interval < 0 || *lead < 0 {
+		return errors.New("-interval and -lead must not be negative")
+	}
+
+	opts := scheduler.Options{
+		LedgerUUID: s.ledger,
+		Lead:       *lead,
+		// every run connects anew, so a dropped connection is retried by the next one
+		Do: s.with,
+	}
+
+	if *interval == 0 {
+		recorded, err := scheduler.RunOnce(ctx, opts)
+		if err != nil {
+			return err
+		}
+		t := table{
+			header: []string{"date", "schedule", "transaction"},
+			footer: []string{fmt.Sprintf("%d pending transactions recorded", len(recorded))},
+		}
+		for _, r := range recorded {
+			t.rows = append(t.rows, []string{r.Date.Format(time.DateOnly), r.ScheduleUUID, r.TransactionUUID})
+		}
+		return s.print(recorded, t)
+	}
+
+	log := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	opts.Interval = *interval
+	opts.OnRun = func(recorded []client.ScheduledTransaction, err error) {
+		if err != nil {
+			log.Error().Err(err).Msg("scheduler run failed")
+			return
+		}
+		log.Info().Int("recorded", len(recorded)).Msg("scheduler run")
+	}
+	log.Info().Dur("interval", *interval).Msg("scheduler started")
+
+	return scheduler.Run(ctx, opts)
+}
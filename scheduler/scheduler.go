@@ -0,0 +1,81 @@
+// Package scheduler records the due occurrences of recurring schedules as
+// pending transactions, once or on a ticker.
+//
+// The recurrence rules and the bookkeeping of what was recorded live in the
+// database (api.run_schedules), so any number of runs, from the command line
+// or from a long-running process, record each occurrence exactly once.
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+)
+
+// Options configures Run and RunOnce.
+type Options struct {
+	// LedgerUUID limits runs to one ledger; every ledger of the user is run
+	// when it is empty.
+	LedgerUUID string
+	// Interval is the time between runs. Run runs once when it is zero.
+	Interval time.Duration
+	// Lead records occurrences this far ahead of today, e.g. to see next
+	// week's rent as pending already.
+	Lead time.Duration
+	// Do runs fn in a database transaction scoped to the user, typically
+	// through client.WithUser. Every run is one call.
+	Do func(ctx context.Context, fn func(c *client.Client) error) error
+	// Now returns the current time; it defaults to time.Now.
+	Now func() time.Time
+	// OnRun, when set, is called after every run with the transactions it
+	// recorded or its error.
+	OnRun func(recorded []client.ScheduledTransaction, err error)
+}
+
+// RunOnce records every occurrence due up to today, plus Lead, and returns
+// the pending transactions it created.
+func RunOnce(ctx context.Context, opts Options) ([]client.ScheduledTransaction, error) {
+	now := time.Now
+	if opts.Now != nil {
+		now = opts.Now
+	}
+	through := now().Add(opts.Lead)
+
+	var recorded []client.ScheduledTransaction
+	err := opts.Do(ctx, func(c *client.Client) (err error) {
+		recorded, err = c.RunSchedules(ctx, opts.LedgerUUID, through)
+		return err
+	})
+	if opts.OnRun != nil {
+		opts.OnRun(recorded, err)
+	}
+
+	return recorded, err
+}
+
+// Run calls RunOnce right away and then every Interval until ctx is done.
+// A failed run does not stop the ticker, since the next one picks up what
+// it missed; it is reported to OnRun. With a zero Interval Run returns the
+// error of its only run.
+func Run(ctx context.Context, opts Options) error {
+	if opts.Interval <= 0 {
+		_, err := RunOnce(ctx, opts)
+		return err
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		_, _ = RunOnce(ctx, opts)
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+	"github.com/j0lvera/pgbudget/scheduler"
+	is_ "github.com/matryer/is"
+)
+
+var errUnavailable = errors.New("database unavailable")
+
+// failingDo stands in for client.WithUser when the database is down.
+func failingDo(context.Context, func(c *client.Client) error) error {
+	return errUnavailable
+}
+
+func TestRun(t *testing.T) {
+	t.Run(
+		"Once", func(t *testing.T) {
+			is := is_.New(t)
+
+			var runs int
+			err := scheduler.Run(
+				context.Background(), scheduler.Options{
+					Do:    failingDo,
+					OnRun: func(_ []client.ScheduledTransaction, err error) { runs++ },
+				},
+			)
+			is.True(errors.Is(err, errUnavailable)) // a single run reports its error
+			is.Equal(runs, 1)
+		},
+	)
+
+	t.Run(
+		"Ticker", func(t *testing.T) {
+			is := is_.New(t)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var runs int
+			err := scheduler.Run(
+				ctx, scheduler.Options{
+					Interval: time.Millisecond,
+					Do:       failingDo,
+					OnRun: func(_ []client.ScheduledTransaction, err error) {
+						is.True(errors.Is(err, errUnavailable))
+						runs++
+						if runs == 3 {
+							cancel()
+						}
+					},
+				},
+			)
+			is.NoErr(err)     // failed runs do not stop the ticker
+			is.Equal(runs, 3) // stops once ctx is done
+		},
+	)
+}
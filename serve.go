@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/j0lvera/pgbudget/auth"
+	"github.com/j0lvera/pgbudget/client"
+	"github.com/j0lvera/pgbudget/server"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+const serveUsage = `Usage: pgbudget serve [flags]
+
+Serves the REST API. Every request runs in its own transaction with
+app.current_user_id set to the authenticated user. At least one of
+-jwt-secret, -jwt-public-key, -api-keys or -user-header must be given;
+requests without a valid identity are refused.
+
+Flags:
+`
+
+func runServe(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	dsn := fs.String("dsn", dsnFromEnv(), "PostgreSQL connection string (default $DATABASE_URL)")
+	addr := fs.String("addr", ":8080", "address to listen on")
+	jwtSecret := fs.String("jwt-secret", os.Getenv("PGBUDGET_JWT_SECRET"), "HS256 secret (default $PGBUDGET_JWT_SECRET)")
+	jwtPublicKey := fs.String("jwt-public-key", "", "PEM file with the RS256 public key")
+	jwtClaim := fs.String("jwt-claim", auth.DefaultClaim, "JWT claim holding the user ID")
+	jwtIssuer := fs.String("jwt-issuer", "", "required JWT issuer")
+	jwtAudience := fs.String("jwt-audience", "", "required JWT audience")
+	jwtAllowNoExp := fs.Bool("jwt-allow-no-exp", false, "accept JWTs without an exp claim")
+	apiKeys := fs.String("api-keys", "", `file with one "<key> <user id>" pair per line`)
+	userHeader := fs.String("user-header", "", "trust the user ID in this header (only behind an authenticating proxy)")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), serveUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *dsn == "" {
+		return errors.New("missing connection string: set -dsn or DATABASE_URL")
+	}
+
+	authenticator, err := newAuthenticator(authFlags{
+		jwtSecret:     *jwtSecret,
+		jwtPublicKey:  *jwtPublicKey,
+		jwtClaim:      *jwtClaim,
+		jwtIssuer:     *jwtIssuer,
+		jwtAudience:   *jwtAudience,
+		jwtAllowNoExp: *jwtAllowNoExp,
+		apiKeys:       *apiKeys,
+		userHeader:    *userHeader,
+	})
+	if err != nil {
+		return err
+	}
+
+	log := zerolog.New(os.Stderr).With().Timestamp().Logger()
+
+	poolConfig, err := pgxpool.ParseConfig(*dsn)
+	if err != nil {
+		return fmt.Errorf("failed to parse connection string: %w", err)
+	}
+	client.ConfigurePool(poolConfig)
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create connection pool: %w", err)
+	}
+	defer pool.Close()
+
+	srv := server.New(
+		pool,
+		server.WithLogger(log),
+		server.WithIdentity(server.Authenticate(authenticator)),
+	)
+
+	return srv.ListenAndServe(ctx, *addr)
+}
+
+type authFlags struct {
+	jwtSecret     string
+	jwtPublicKey  string
+	jwtClaim      string
+	jwtIssuer     string
+	jwtAudience   string
+	jwtAllowNoExp bool
+	apiKeys       string
+	userHeader    string
+}
+
+// newAuthenticator chains the authentication methods enabled by the flags:
+// JWT first, then API keys, then the trusted header.
+func newAuthenticator(f authFlags) (auth.Authenticator, error) {
+	var chain auth.Chain
+
+	if f.jwtSecret != "" || f.jwtPublicKey != "" {
+		cfg := auth.JWTConfig{
+			Claim:              f.jwtClaim,
+			Issuer:             f.jwtIssuer,
+			Audience:           f.jwtAudience,
+			Leeway:             time.Minute,
+			AllowMissingExpiry: f.jwtAllowNoExp,
+		}
+		if f.jwtSecret != "" {
+			cfg.Secret = []byte(f.jwtSecret)
+		}
+		if f.jwtPublicKey != "" {
+			data, err := os.ReadFile(f.jwtPublicKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read public key: %w", err)
+			}
+			if cfg.PublicKey, err = auth.ParseRSAPublicKey(data); err != nil {
+				return nil, err
+			}
+		}
+
+		jwt, err := auth.NewJWT(cfg)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, jwt)
+	}
+
+	if f.apiKeys != "" {
+		file, err := os.Open(f.apiKeys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open api keys: %w", err)
+		}
+		defer file.Close()
+
+		keys, err := auth.LoadAPIKeys(file)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, keys)
+	}
+
+	if f.userHeader != "" {
+		chain = append(chain, auth.Header(f.userHeader))
+	}
+
+	if len(chain) == 0 {
+		return nil, errors.New("no authentication configured: set -jwt-secret, -jwt-public-key, -api-keys or -user-header")
+	}
+
+	return chain, nil
+}
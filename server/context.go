@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/j0lvera/pgbudget/auth"
+)
+
+type contextKey struct{}
+
+// ContextWithUserID returns a copy of ctx carrying the authenticated user ID.
+// Identity middleware must call it for every request it accepts.
+func ContextWithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, userID)
+}
+
+// UserIDFromContext returns the user ID stored by ContextWithUserID.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(contextKey{}).(string)
+	return userID, ok && userID != ""
+}
+
+// Authenticate returns identity middleware backed by a. Requests that a
+// cannot authenticate are refused with 401 before reaching any handler, so
+// no query ever runs without a user.
+func Authenticate(a auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, err := a.Authenticate(r)
+			if err != nil || userID == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="pgbudget"`)
+				writeError(w, errUnauthenticated)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ContextWithUserID(r.Context(), userID)))
+		})
+	}
+}
+
+// HeaderIdentity trusts the user ID sent in the given header. It is meant for
+// deployments behind a gateway that authenticates users and sets the header
+// itself; requests without the header are rejected.
+func HeaderIdentity(header string) func(http.Handler) http.Handler {
+	return Authenticate(auth.Header(header))
+}
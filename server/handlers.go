@@ -0,0 +1,1517 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+)
+
+// routes registers every endpoint. Paths name resources by their UUID; the
+// database decides whether the current user may see them.
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /ledgers", s.handleListLedgers)
+	s.mux.HandleFunc("POST /ledgers", s.handleCreateLedger)
+	s.mux.HandleFunc("GET /ledgers/{ledger}", s.handleGetLedger)
+
+	s.mux.HandleFunc("GET /ledgers/{ledger}/accounts", s.handleListAccounts)
+	s.mux.HandleFunc("POST /ledgers/{ledger}/accounts", s.handleCreateAccount)
+	s.mux.HandleFunc("GET /accounts/{account}", s.handleGetAccount)
+
+	s.mux.HandleFunc("GET /ledgers/{ledger}/categories", s.handleListCategories)
+	s.mux.HandleFunc("POST /ledgers/{ledger}/categories", s.handleAddCategories)
+
+	s.mux.HandleFunc("GET /ledgers/{ledger}/transactions", s.handleSearchTransactions)
+	s.mux.HandleFunc("POST /ledgers/{ledger}/transactions", s.handleAddTransaction)
+	s.mux.HandleFunc("POST /ledgers/{ledger}/transfers", s.handleAddTransfer)
+	s.mux.HandleFunc("POST /ledgers/{ledger}/assignments", s.handleAssignToCategory)
+	s.mux.HandleFunc("POST /ledgers/{ledger}/moves", s.handleMoveBetweenCategories)
+	s.mux.HandleFunc("POST /transactions/{transaction}/corrections", s.handleCorrectTransaction)
+	s.mux.HandleFunc("DELETE /transactions/{transaction}", s.handleDeleteTransaction)
+	s.mux.HandleFunc("POST /transactions/{transaction}/post", s.handlePostTransaction)
+	s.mux.HandleFunc("POST /transactions/post", s.handlePostTransactions)
+	s.mux.HandleFunc("POST /ledgers/{ledger}/split-transactions", s.handleAddSplitTransaction)
+	s.mux.HandleFunc("GET /split-transactions/{split}", s.handleGetSplitTransaction)
+	s.mux.HandleFunc("POST /split-transactions/{split}/corrections", s.handleCorrectSplitTransaction)
+
+	s.mux.HandleFunc("GET /ledgers/{ledger}/schedules", s.handleListSchedules)
+	s.mux.HandleFunc("POST /ledgers/{ledger}/schedules", s.handleCreateSchedule)
+	s.mux.HandleFunc("DELETE /schedules/{schedule}", s.handleDeleteSchedule)
+	s.mux.HandleFunc("GET /ledgers/{ledger}/upcoming-transactions", s.handleUpcomingTransactions)
+	s.mux.HandleFunc("POST /ledgers/{ledger}/schedules/run", s.handleRunSchedules)
+
+	s.mux.HandleFunc("GET /ledgers/{ledger}/budget-status", s.handleBudgetStatus)
+	s.mux.HandleFunc("GET /ledgers/{ledger}/budget-totals", s.handleBudgetTotals)
+	s.mux.HandleFunc("GET /ledgers/{ledger}/balances", s.handleLedgerBalances)
+	s.mux.HandleFunc("POST /ledgers/{ledger}/balances/rebuild", s.handleRebuildBalances)
+
+	s.mux.HandleFunc("GET /accounts/{account}/transactions", s.handleAccountTransactions)
+	s.mux.HandleFunc("GET /accounts/{account}/balance", s.handleAccountBalance)
+	s.mux.HandleFunc("GET /accounts/{account}/balance-history", s.handleBalanceHistory)
+
+	s.mux.HandleFunc("GET /accounts/{account}/reconciliations", s.handleListReconciliations)
+	s.mux.HandleFunc("POST /accounts/{account}/reconciliations", s.handleReconcileAccount)
+	s.mux.HandleFunc("POST /accounts/{account}/reconciliations/preview", s.handlePreviewReconciliation)
+
+	s.mux.HandleFunc("PUT /categories/{category}/goal", s.handleSetCategoryGoal)
+	s.mux.HandleFunc("DELETE /categories/{category}/goal", s.handleDeleteCategoryGoal)
+	s.mux.HandleFunc("GET /ledgers/{ledger}/goal-progress", s.handleGoalProgress)
+	s.mux.HandleFunc("POST /ledgers/{ledger}/goals/assign", s.handleAutoAssign)
+
+	s.mux.HandleFunc("GET /ledgers/{ledger}/rollover-rules", s.handleGetRolloverRules)
+	s.mux.HandleFunc("PUT /ledgers/{ledger}/rollover-rules", s.handleSetRolloverRules)
+	s.mux.HandleFunc("GET /ledgers/{ledger}/closed-months", s.handleClosedMonths)
+	s.mux.HandleFunc("POST /ledgers/{ledger}/months/{period}/close", s.handleCloseMonth)
+
+	s.mux.HandleFunc("GET /ledgers/{ledger}/rules", s.handleListCategoryRules)
+	s.mux.HandleFunc("POST /ledgers/{ledger}/rules", s.handleAddCategoryRule)
+	s.mux.HandleFunc("DELETE /rules/{rule}", s.handleDeleteCategoryRule)
+	s.mux.HandleFunc("POST /ledgers/{ledger}/rules/apply", s.handleApplyCategoryRules)
+
+	s.mux.HandleFunc("GET /ledgers/{ledger}/payees", s.handleListPayees)
+	s.mux.HandleFunc("POST /ledgers/{ledger}/payees", s.handleCreatePayee)
+	s.mux.HandleFunc("PUT /payees/{payee}", s.handleUpdatePayee)
+	s.mux.HandleFunc("POST /payees/{payee}/merge", s.handleMergePayees)
+	s.mux.HandleFunc("POST /ledgers/{ledger}/payees/backfill", s.handleBackfillPayees)
+
+	s.mux.HandleFunc("POST /transactions/{transaction}/tags", s.handleTagTransaction)
+	s.mux.HandleFunc("DELETE /transactions/{transaction}/tags", s.handleUntagTransaction)
+	s.mux.HandleFunc("GET /ledgers/{ledger}/tag-spending", s.handleTagSpending)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if err := s.pool.Ping(r.Context()); err != nil {
+		s.log.Error().Err(err).Msg("health check failed")
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// uuidResponse is returned by endpoints that create a transaction.
+type uuidResponse struct {
+	UUID string `json:"uuid"`
+}
+
+// ledgers
+
+type createLedgerRequest struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Metadata    json.RawMessage `json:"metadata"`
+}
+
+func (s *Server) handleListLedgers(w http.ResponseWriter, r *http.Request) {
+	var ledgers []client.Ledger
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		ledgers, err = c.ListLedgers(r.Context())
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ledgers)
+}
+
+func (s *Server) handleCreateLedger(w http.ResponseWriter, r *http.Request) {
+	var req createLedgerRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var ledger *client.Ledger
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		ledger, err = c.CreateLedger(r.Context(), client.CreateLedgerParams(req))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, ledger)
+}
+
+func (s *Server) handleGetLedger(w http.ResponseWriter, r *http.Request) {
+	var ledger *client.Ledger
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		ledger, err = c.GetLedger(r.Context(), r.PathValue("ledger"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ledger)
+}
+
+// accounts
+
+type createAccountRequest struct {
+	Name        string             `json:"name"`
+	Type        client.AccountType `json:"type"`
+	Description string             `json:"description"`
+	Metadata    json.RawMessage    `json:"metadata"`
+}
+
+func (s *Server) handleListAccounts(w http.ResponseWriter, r *http.Request) {
+	var accounts []client.Account
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		accounts, err = c.ListAccounts(r.Context(), r.PathValue("ledger"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, accounts)
+}
+
+func (s *Server) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
+	var req createAccountRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var account *client.Account
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		account, err = c.CreateAccount(
+			r.Context(), client.CreateAccountParams{
+				LedgerUUID:  r.PathValue("ledger"),
+				Name:        req.Name,
+				Type:        req.Type,
+				Description: req.Description,
+				Metadata:    req.Metadata,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, account)
+}
+
+func (s *Server) handleGetAccount(w http.ResponseWriter, r *http.Request) {
+	var account *client.Account
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		account, err = c.GetAccount(r.Context(), r.PathValue("account"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, account)
+}
+
+// categories
+
+// addCategoriesRequest accepts either a single name or a list of names.
+type addCategoriesRequest struct {
+	Name  string   `json:"name"`
+	Names []string `json:"names"`
+}
+
+func (s *Server) handleListCategories(w http.ResponseWriter, r *http.Request) {
+	var categories []client.Account
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		categories, err = c.ListCategories(r.Context(), r.PathValue("ledger"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, categories)
+}
+
+func (s *Server) handleAddCategories(w http.ResponseWriter, r *http.Request) {
+	var req addCategoriesRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	if (req.Name == "") == (len(req.Names) == 0) {
+		s.fail(w, r, badRequestf("set exactly one of name or names"))
+		return
+	}
+
+	ledgerUUID := r.PathValue("ledger")
+
+	if req.Name != "" {
+		var category *client.Account
+		err := s.withClient(r, func(c *client.Client) (err error) {
+			category, err = c.AddCategory(r.Context(), ledgerUUID, req.Name)
+			return err
+		})
+		if err != nil {
+			s.fail(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, category)
+		return
+	}
+
+	var categories []client.Account
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		categories, err = c.AddCategories(r.Context(), ledgerUUID, req.Names)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, categories)
+}
+
+// transactions
+
+type addTransactionRequest struct {
+	Date         string                 `json:"date"`
+	Description  string                 `json:"description"`
+	Type         client.TransactionType `json:"type"`
+	Amount       int64                  `json:"amount"`
+	AccountUUID  string                 `json:"account_uuid"`
+	CategoryUUID string                 `json:"category_uuid"`
+	Payee        string                 `json:"payee"`
+}
+
+type transferRequest struct {
+	Date            string `json:"date"`
+	Memo            string `json:"memo"`
+	Amount          int64  `json:"amount"`
+	FromAccountUUID string `json:"from_account_uuid"`
+	ToAccountUUID   string `json:"to_account_uuid"`
+}
+
+type assignRequest struct {
+	Date         string `json:"date"`
+	Description  string `json:"description"`
+	Amount       int64  `json:"amount"`
+	CategoryUUID string `json:"category_uuid"`
+}
+
+type moveRequest struct {
+	Date             string `json:"date"`
+	Memo             string `json:"memo"`
+	Amount           int64  `json:"amount"`
+	FromCategoryUUID string `json:"from_category_uuid"`
+	ToCategoryUUID   string `json:"to_category_uuid"`
+	Force            bool   `json:"force"`
+}
+
+type correctTransactionRequest struct {
+	Date         string                 `json:"date"`
+	Description  string                 `json:"description"`
+	Type         client.TransactionType `json:"type"`
+	Amount       int64                  `json:"amount"`
+	AccountUUID  string                 `json:"account_uuid"`
+	CategoryUUID string                 `json:"category_uuid"`
+	Payee        string                 `json:"payee"`
+	Reason       string                 `json:"reason"`
+	// Override allows correcting a reconciled transaction.
+	Override bool `json:"override"`
+}
+
+func (s *Server) handleAddTransaction(w http.ResponseWriter, r *http.Request) {
+	var req addTransactionRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	date, err := parseDate(req.Date)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var transactionUUID string
+	err = s.withClient(r, func(c *client.Client) (err error) {
+		transactionUUID, err = c.AddTransaction(
+			r.Context(), client.AddTransactionParams{
+				LedgerUUID:   r.PathValue("ledger"),
+				Date:         date,
+				Description:  req.Description,
+				Type:         req.Type,
+				Amount:       req.Amount,
+				AccountUUID:  req.AccountUUID,
+				CategoryUUID: req.CategoryUUID,
+				Payee:        req.Payee,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, uuidResponse{UUID: transactionUUID})
+}
+
+func (s *Server) handleAddTransfer(w http.ResponseWriter, r *http.Request) {
+	var req transferRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	date, err := parseDate(req.Date)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var transferUUID string
+	err = s.withClient(r, func(c *client.Client) (err error) {
+		transferUUID, err = c.AddTransfer(
+			r.Context(), client.AddTransferParams{
+				LedgerUUID:      r.PathValue("ledger"),
+				FromAccountUUID: req.FromAccountUUID,
+				ToAccountUUID:   req.ToAccountUUID,
+				Amount:          req.Amount,
+				Date:            date,
+				Memo:            req.Memo,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, uuidResponse{UUID: transferUUID})
+}
+
+func (s *Server) handleAssignToCategory(w http.ResponseWriter, r *http.Request) {
+	var req assignRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	date, err := parseDate(req.Date)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var transaction *client.Transaction
+	err = s.withClient(r, func(c *client.Client) (err error) {
+		transaction, err = c.AssignToCategory(
+			r.Context(), client.AssignToCategoryParams{
+				LedgerUUID:   r.PathValue("ledger"),
+				Date:         date,
+				Description:  req.Description,
+				Amount:       req.Amount,
+				CategoryUUID: req.CategoryUUID,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, transaction)
+}
+
+func (s *Server) handleMoveBetweenCategories(w http.ResponseWriter, r *http.Request) {
+	var req moveRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	date, err := parseDate(req.Date)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var transaction *client.Transaction
+	err = s.withClient(r, func(c *client.Client) (err error) {
+		transaction, err = c.MoveBetweenCategories(
+			r.Context(), client.MoveBetweenCategoriesParams{
+				LedgerUUID:       r.PathValue("ledger"),
+				FromCategoryUUID: req.FromCategoryUUID,
+				ToCategoryUUID:   req.ToCategoryUUID,
+				Amount:           req.Amount,
+				Date:             date,
+				Memo:             req.Memo,
+				Force:            req.Force,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, transaction)
+}
+
+func (s *Server) handleCorrectTransaction(w http.ResponseWriter, r *http.Request) {
+	var req correctTransactionRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	date, err := parseDate(req.Date)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var correctionUUID string
+	err = s.withClient(r, func(c *client.Client) (err error) {
+		correctionUUID, err = c.CorrectTransaction(
+			r.Context(), client.CorrectTransactionParams{
+				TransactionUUID: r.PathValue("transaction"),
+				Type:            req.Type,
+				AccountUUID:     req.AccountUUID,
+				CategoryUUID:    req.CategoryUUID,
+				Amount:          req.Amount,
+				Description:     req.Description,
+				Date:            date,
+				Reason:          req.Reason,
+				Override:        req.Override,
+				Payee:           req.Payee,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, uuidResponse{UUID: correctionUUID})
+}
+
+func (s *Server) handleDeleteTransaction(w http.ResponseWriter, r *http.Request) {
+	reason := strings.TrimSpace(r.URL.Query().Get("reason"))
+	override, err := queryBool(r, "override")
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var reversalUUID string
+	err = s.withClient(r, func(c *client.Client) (err error) {
+		if override {
+			reversalUUID, err = c.DeleteReconciledTransaction(r.Context(), r.PathValue("transaction"), reason)
+		} else {
+			reversalUUID, err = c.DeleteTransaction(r.Context(), r.PathValue("transaction"), reason)
+		}
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, uuidResponse{UUID: reversalUUID})
+}
+
+func (s *Server) handlePostTransaction(w http.ResponseWriter, r *http.Request) {
+	err := s.withClient(r, func(c *client.Client) error {
+		return c.PostTransaction(r.Context(), r.PathValue("transaction"))
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type postTransactionsRequest struct {
+	TransactionUUIDs []string `json:"transaction_uuids"`
+}
+
+// postTransactionsResponse is the body of POST /transactions/post.
+type postTransactionsResponse struct {
+	Posted int `json:"posted"`
+}
+
+func (s *Server) handlePostTransactions(w http.ResponseWriter, r *http.Request) {
+	var req postTransactionsRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var posted int
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		posted, err = c.PostTransactions(r.Context(), req.TransactionUUIDs)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, postTransactionsResponse{Posted: posted})
+}
+
+// split transactions; they are deleted through DELETE /transactions/{uuid}
+
+type splitTransactionRequest struct {
+	Date        string                 `json:"date"`
+	Description string                 `json:"description"`
+	Type        client.TransactionType `json:"type"`
+	AccountUUID string                 `json:"account_uuid"`
+	Splits      []client.Split         `json:"splits"`
+	// Reason and Override are only used by corrections.
+	Reason   string `json:"reason"`
+	Override bool   `json:"override"`
+}
+
+func (s *Server) handleAddSplitTransaction(w http.ResponseWriter, r *http.Request) {
+	var req splitTransactionRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	date, err := parseDate(req.Date)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var splitUUID string
+	err = s.withClient(r, func(c *client.Client) (err error) {
+		splitUUID, err = c.AddSplitTransaction(
+			r.Context(), client.AddSplitTransactionParams{
+				LedgerUUID:  r.PathValue("ledger"),
+				Date:        date,
+				Description: req.Description,
+				Type:        req.Type,
+				AccountUUID: req.AccountUUID,
+				Splits:      req.Splits,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, uuidResponse{UUID: splitUUID})
+}
+
+func (s *Server) handleGetSplitTransaction(w http.ResponseWriter, r *http.Request) {
+	var split *client.SplitTransaction
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		split, err = c.GetSplitTransaction(r.Context(), r.PathValue("split"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, split)
+}
+
+func (s *Server) handleCorrectSplitTransaction(w http.ResponseWriter, r *http.Request) {
+	var req splitTransactionRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	date, err := parseDate(req.Date)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var splitUUID string
+	err = s.withClient(r, func(c *client.Client) (err error) {
+		splitUUID, err = c.CorrectSplitTransaction(
+			r.Context(), client.CorrectSplitTransactionParams{
+				SplitUUID:   r.PathValue("split"),
+				Type:        req.Type,
+				AccountUUID: req.AccountUUID,
+				Date:        date,
+				Description: req.Description,
+				Splits:      req.Splits,
+				Reason:      req.Reason,
+				Override:    req.Override,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, uuidResponse{UUID: splitUUID})
+}
+
+// schedules
+
+type createScheduleRequest struct {
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description"`
+	Type         client.TransactionType `json:"type"`
+	Amount       int64                  `json:"amount"`
+	AccountUUID  string                 `json:"account_uuid"`
+	CategoryUUID string                 `json:"category_uuid"`
+	Frequency    client.Frequency       `json:"frequency"`
+	Every        int                    `json:"every"`
+	DayOfMonth   int                    `json:"day_of_month"`
+	StartDate    string                 `json:"start_date"`
+	EndDate      string                 `json:"end_date"`
+}
+
+func (s *Server) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	var schedules []client.Schedule
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		schedules, err = c.GetSchedules(r.Context(), r.PathValue("ledger"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, schedules)
+}
+
+func (s *Server) handleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var req createScheduleRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	startDate, err := parseDate(req.StartDate)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	var endDate time.Time
+	if req.EndDate != "" {
+		if endDate, err = parseDate(req.EndDate); err != nil {
+			s.fail(w, r, err)
+			return
+		}
+	}
+
+	var scheduleUUID string
+	err = s.withClient(r, func(c *client.Client) (err error) {
+		scheduleUUID, err = c.CreateSchedule(
+			r.Context(), client.CreateScheduleParams{
+				LedgerUUID:   r.PathValue("ledger"),
+				Name:         req.Name,
+				Description:  req.Description,
+				Type:         req.Type,
+				Amount:       req.Amount,
+				AccountUUID:  req.AccountUUID,
+				CategoryUUID: req.CategoryUUID,
+				Frequency:    req.Frequency,
+				Every:        req.Every,
+				DayOfMonth:   req.DayOfMonth,
+				StartDate:    startDate,
+				EndDate:      endDate,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, uuidResponse{UUID: scheduleUUID})
+}
+
+func (s *Server) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	err := s.withClient(r, func(c *client.Client) error {
+		return c.DeleteSchedule(r.Context(), r.PathValue("schedule"))
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleUpcomingTransactions(w http.ResponseWriter, r *http.Request) {
+	through := time.Now().AddDate(0, 0, 30)
+	if value := r.URL.Query().Get("through"); value != "" {
+		var err error
+		if through, err = parseDate(value); err != nil {
+			s.fail(w, r, err)
+			return
+		}
+	}
+
+	var upcoming []client.UpcomingTransaction
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		upcoming, err = c.GetUpcomingTransactions(r.Context(), r.PathValue("ledger"), through)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, upcoming)
+}
+
+func (s *Server) handleRunSchedules(w http.ResponseWriter, r *http.Request) {
+	through, err := parseDate(r.URL.Query().Get("through"))
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var recorded []client.ScheduledTransaction
+	err = s.withClient(r, func(c *client.Client) (err error) {
+		recorded, err = c.RunSchedules(r.Context(), r.PathValue("ledger"), through)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, recorded)
+}
+
+// reports
+
+func (s *Server) handleBudgetStatus(w http.ResponseWriter, r *http.Request) {
+	var status []client.BudgetStatus
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		status, err = c.GetBudgetStatus(r.Context(), r.PathValue("ledger"), r.URL.Query().Get("period"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) handleBudgetTotals(w http.ResponseWriter, r *http.Request) {
+	var totals *client.BudgetTotals
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		totals, err = c.GetBudgetTotals(r.Context(), r.PathValue("ledger"), r.URL.Query().Get("period"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, totals)
+}
+
+func (s *Server) handleLedgerBalances(w http.ResponseWriter, r *http.Request) {
+	var balances []client.LedgerBalance
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		balances, err = c.GetLedgerBalances(r.Context(), r.PathValue("ledger"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, balances)
+}
+
+func (s *Server) handleRebuildBalances(w http.ResponseWriter, r *http.Request) {
+	err := s.withClient(r, func(c *client.Client) error {
+		return c.RebuildLedgerBalanceSnapshots(r.Context(), r.PathValue("ledger"))
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAccountTransactions returns the history as an array, so pages keep
+// the shape of the whole history; the total is sent in X-Total-Count.
+func (s *Server) handleAccountTransactions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := client.AccountTransactionsParams{
+		AccountUUID: r.PathValue("account"),
+		Status:      client.TransactionStatus(query.Get("status")),
+		Tags:        query["tag"],
+		After:       query.Get("after"),
+		Before:      query.Get("before"),
+	}
+
+	var err error
+	if params.Limit, err = queryInt(r, "limit", 0); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	if params.WithTotal, err = queryBool(r, "total"); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var page *client.AccountTransactionsPage
+	err = s.withClient(r, func(c *client.Client) (err error) {
+		page, err = c.GetAccountTransactionsPage(r.Context(), params)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	if page.Total != nil {
+		w.Header().Set("X-Total-Count", strconv.FormatInt(*page.Total, 10))
+	}
+	writeJSON(w, http.StatusOK, page.Transactions)
+}
+
+// balanceResponse is the body of GET /accounts/{account}/balance.
+type balanceResponse struct {
+	AccountUUID string `json:"account_uuid"`
+	// Balance is the working balance, counting pending transactions.
+	Balance        int64 `json:"balance"`
+	ClearedBalance int64 `json:"cleared_balance"`
+}
+
+func (s *Server) handleAccountBalance(w http.ResponseWriter, r *http.Request) {
+	accountUUID := r.PathValue("account")
+
+	resp := balanceResponse{AccountUUID: accountUUID}
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		if resp.Balance, err = c.GetAccountBalance(r.Context(), accountUUID); err != nil {
+			return err
+		}
+		resp.ClearedBalance, err = c.GetAccountClearedBalance(r.Context(), accountUUID)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleBalanceHistory(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", 50)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var history []client.BalanceHistoryEntry
+	err = s.withClient(r, func(c *client.Client) (err error) {
+		history, err = c.GetAccountBalanceHistory(r.Context(), r.PathValue("account"), limit)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, history)
+}
+
+// reconciliations
+
+type reconcileRequest struct {
+	StatementBalance int64  `json:"statement_balance"`
+	StatementDate    string `json:"statement_date"`
+	// CategoryUUID receives the adjustment; Income when empty.
+	CategoryUUID string `json:"category_uuid"`
+}
+
+// params decodes the request body into the arguments of a reconciliation.
+func (req reconcileRequest) params(r *http.Request) (client.ReconcileParams, error) {
+	date, err := parseDate(req.StatementDate)
+	if err != nil {
+		return client.ReconcileParams{}, err
+	}
+	return client.ReconcileParams{
+		AccountUUID:            r.PathValue("account"),
+		StatementBalance:       req.StatementBalance,
+		StatementDate:          date,
+		AdjustmentCategoryUUID: req.CategoryUUID,
+	}, nil
+}
+
+func (s *Server) handleListReconciliations(w http.ResponseWriter, r *http.Request) {
+	var reconciliations []client.Reconciliation
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		reconciliations, err = c.GetReconciliations(r.Context(), r.PathValue("account"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reconciliations)
+}
+
+func (s *Server) handleReconcileAccount(w http.ResponseWriter, r *http.Request) {
+	var req reconcileRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	params, err := req.params(r)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var reconciliationUUID string
+	err = s.withClient(r, func(c *client.Client) (err error) {
+		reconciliationUUID, err = c.ReconcileAccount(r.Context(), params)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, uuidResponse{UUID: reconciliationUUID})
+}
+
+func (s *Server) handlePreviewReconciliation(w http.ResponseWriter, r *http.Request) {
+	var req reconcileRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	params, err := req.params(r)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var preview *client.ReconciliationPreview
+	err = s.withClient(r, func(c *client.Client) (err error) {
+		preview, err = c.PreviewReconciliation(r.Context(), params)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, preview)
+}
+
+// goals
+
+type setGoalRequest struct {
+	Type       client.GoalType `json:"type"`
+	Amount     int64           `json:"amount"`
+	TargetDate string          `json:"target_date"`
+}
+
+func (s *Server) handleSetCategoryGoal(w http.ResponseWriter, r *http.Request) {
+	var req setGoalRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	var targetDate time.Time
+	if req.TargetDate != "" {
+		var err error
+		if targetDate, err = parseDate(req.TargetDate); err != nil {
+			s.fail(w, r, err)
+			return
+		}
+	}
+
+	err := s.withClient(r, func(c *client.Client) error {
+		return c.SetCategoryGoal(
+			r.Context(), client.SetCategoryGoalParams{
+				CategoryUUID: r.PathValue("category"),
+				Type:         req.Type,
+				Amount:       req.Amount,
+				TargetDate:   targetDate,
+			},
+		)
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDeleteCategoryGoal(w http.ResponseWriter, r *http.Request) {
+	err := s.withClient(r, func(c *client.Client) error {
+		return c.DeleteCategoryGoal(r.Context(), r.PathValue("category"))
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleGoalProgress(w http.ResponseWriter, r *http.Request) {
+	var progress []client.GoalProgress
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		progress, err = c.GetGoalProgress(r.Context(), r.PathValue("ledger"), r.URL.Query().Get("period"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, progress)
+}
+
+func (s *Server) handleAutoAssign(w http.ResponseWriter, r *http.Request) {
+	var date time.Time
+	if value := r.URL.Query().Get("date"); value != "" {
+		var err error
+		if date, err = parseDate(value); err != nil {
+			s.fail(w, r, err)
+			return
+		}
+	}
+
+	var assignments []client.Transaction
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		assignments, err = c.AutoAssign(
+			r.Context(), client.AutoAssignParams{
+				LedgerUUID: r.PathValue("ledger"),
+				Period:     r.URL.Query().Get("period"),
+				Date:       date,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, assignments)
+}
+
+// month close
+
+func (s *Server) handleGetRolloverRules(w http.ResponseWriter, r *http.Request) {
+	var rules *client.RolloverRules
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		rules, err = c.GetRolloverRules(r.Context(), r.PathValue("ledger"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rules)
+}
+
+func (s *Server) handleSetRolloverRules(w http.ResponseWriter, r *http.Request) {
+	var req client.RolloverRules
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	err := s.withClient(r, func(c *client.Client) error {
+		return c.SetRolloverRules(r.Context(), r.PathValue("ledger"), req)
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleClosedMonths(w http.ResponseWriter, r *http.Request) {
+	var months []client.ClosedMonth
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		months, err = c.GetClosedMonths(r.Context(), r.PathValue("ledger"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, months)
+}
+
+func (s *Server) handleCloseMonth(w http.ResponseWriter, r *http.Request) {
+	var recorded []client.MonthCloseTransaction
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		recorded, err = c.CloseMonth(r.Context(), r.PathValue("ledger"), r.PathValue("period"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, recorded)
+}
+
+// category rules
+
+type addCategoryRuleRequest struct {
+	Name               string   `json:"name"`
+	Priority           int      `json:"priority"`
+	DescriptionPattern string   `json:"description_pattern"`
+	MinAmount          *int64   `json:"min_amount"`
+	MaxAmount          *int64   `json:"max_amount"`
+	AccountUUID        string   `json:"account_uuid"`
+	Weekdays           []int    `json:"weekdays"`
+	CategoryUUID       string   `json:"category_uuid"`
+	SetDescription     string   `json:"set_description"`
+	Tags               []string `json:"tags"`
+}
+
+func (s *Server) handleListCategoryRules(w http.ResponseWriter, r *http.Request) {
+	var rules []client.CategoryRule
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		rules, err = c.GetCategoryRules(r.Context(), r.PathValue("ledger"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rules)
+}
+
+func (s *Server) handleAddCategoryRule(w http.ResponseWriter, r *http.Request) {
+	var req addCategoryRuleRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var ruleUUID string
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		ruleUUID, err = c.AddCategoryRule(
+			r.Context(), client.AddCategoryRuleParams{
+				LedgerUUID:         r.PathValue("ledger"),
+				Name:               req.Name,
+				Priority:           req.Priority,
+				DescriptionPattern: req.DescriptionPattern,
+				MinAmount:          req.MinAmount,
+				MaxAmount:          req.MaxAmount,
+				AccountUUID:        req.AccountUUID,
+				Weekdays:           req.Weekdays,
+				CategoryUUID:       req.CategoryUUID,
+				SetDescription:     req.SetDescription,
+				Tags:               req.Tags,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, uuidResponse{UUID: ruleUUID})
+}
+
+func (s *Server) handleDeleteCategoryRule(w http.ResponseWriter, r *http.Request) {
+	err := s.withClient(r, func(c *client.Client) error {
+		return c.DeleteCategoryRule(r.Context(), r.PathValue("rule"))
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleApplyCategoryRules(w http.ResponseWriter, r *http.Request) {
+	var applied []client.RuleApplication
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		applied, err = c.ApplyCategoryRules(r.Context(), r.PathValue("ledger"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, applied)
+}
+
+// payees
+
+type payeeRequest struct {
+	Name                string `json:"name"`
+	DefaultCategoryUUID string `json:"default_category_uuid"`
+}
+
+type mergePayeesRequest struct {
+	TargetUUID string `json:"target_uuid"`
+}
+
+// countResponse is the body of the payee merge and backfill routes.
+type countResponse struct {
+	Transactions int `json:"transactions"`
+}
+
+func (s *Server) handleListPayees(w http.ResponseWriter, r *http.Request) {
+	var payees []client.Payee
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		payees, err = c.GetPayees(r.Context(), r.PathValue("ledger"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, payees)
+}
+
+func (s *Server) handleCreatePayee(w http.ResponseWriter, r *http.Request) {
+	var req payeeRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var payeeUUID string
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		payeeUUID, err = c.CreatePayee(
+			r.Context(), client.CreatePayeeParams{
+				LedgerUUID:          r.PathValue("ledger"),
+				Name:                req.Name,
+				DefaultCategoryUUID: req.DefaultCategoryUUID,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, uuidResponse{UUID: payeeUUID})
+}
+
+// handleUpdatePayee replaces the name and the default category of a payee;
+// an empty default_category_uuid clears it.
+func (s *Server) handleUpdatePayee(w http.ResponseWriter, r *http.Request) {
+	var req payeeRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	err := s.withClient(r, func(c *client.Client) error {
+		if err := c.RenamePayee(r.Context(), r.PathValue("payee"), req.Name); err != nil {
+			return err
+		}
+		return c.SetPayeeCategory(r.Context(), r.PathValue("payee"), req.DefaultCategoryUUID)
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleMergePayees(w http.ResponseWriter, r *http.Request) {
+	var req mergePayeesRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var moved int
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		moved, err = c.MergePayees(r.Context(), r.PathValue("payee"), req.TargetUUID)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, countResponse{Transactions: moved})
+}
+
+func (s *Server) handleBackfillPayees(w http.ResponseWriter, r *http.Request) {
+	var linked int
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		linked, err = c.BackfillPayees(r.Context(), r.PathValue("ledger"))
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, countResponse{Transactions: linked})
+}
+
+// tags
+
+type tagRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// tagsResponse is the body of the /transactions/{transaction}/tags
+// endpoints: the tags the transaction carries after the change.
+type tagsResponse struct {
+	Tags []string `json:"tags"`
+}
+
+func (s *Server) handleTagTransaction(w http.ResponseWriter, r *http.Request) {
+	var req tagRequest
+	if err := decode(r, &req); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var tags []string
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		tags, err = c.TagTransaction(r.Context(), r.PathValue("transaction"), req.Tags...)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tagsResponse{Tags: tags})
+}
+
+// handleUntagTransaction removes the tags named by the tag query parameters,
+// or every tag when there are none.
+func (s *Server) handleUntagTransaction(w http.ResponseWriter, r *http.Request) {
+	var tags []string
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		tags, err = c.UntagTransaction(r.Context(), r.PathValue("transaction"), r.URL.Query()["tag"]...)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tagsResponse{Tags: tags})
+}
+
+func (s *Server) handleTagSpending(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var spending []client.TagSpending
+	err := s.withClient(r, func(c *client.Client) (err error) {
+		spending, err = c.GetTagSpending(
+			r.Context(), client.TagSpendingParams{
+				LedgerUUID:  r.PathValue("ledger"),
+				Tags:        query["tag"],
+				StartPeriod: query.Get("from"),
+				EndPeriod:   query.Get("to"),
+			},
+		)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, spending)
+}
+
+// search
+
+// handleSearchTransactions searches the transactions of a ledger. Every
+// query parameter is optional: q, min_amount and max_amount in cents, from
+// and to as dates, account, category, status, key (repeated) for metadata
+// keys, after for the cursor and limit.
+func (s *Server) handleSearchTransactions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := client.SearchParams{
+		LedgerUUID:   r.PathValue("ledger"),
+		Query:        query.Get("q"),
+		AccountUUID:  query.Get("account"),
+		CategoryUUID: query.Get("category"),
+		Status:       client.TransactionStatus(query.Get("status")),
+		MetadataKeys: query["key"],
+		After:        query.Get("after"),
+	}
+
+	var err error
+	if params.MinAmount, err = queryAmount(r, "min_amount"); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	if params.MaxAmount, err = queryAmount(r, "max_amount"); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	for _, bound := range []struct {
+		name string
+		date *time.Time
+	}{{"from", &params.StartDate}, {"to", &params.EndDate}} {
+		if value := query.Get(bound.name); value != "" {
+			if *bound.date, err = parseDate(value); err != nil {
+				s.fail(w, r, err)
+				return
+			}
+		}
+	}
+	if params.Limit, err = queryInt(r, "limit", 50); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	var page *client.SearchPage
+	err = s.withClient(r, func(c *client.Client) (err error) {
+		page, err = c.Search(r.Context(), params)
+		return err
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+)
+
+var errUnauthenticated = errors.New("authentication required")
+
+// errorResponse is the JSON body of every failed request.
+type errorResponse struct {
+	Error string `json:"error"`
+	// Code is the SQLSTATE raised by the database, when there is one.
+	Code string `json:"code,omitempty"`
+}
+
+// badRequest marks an error caused by a malformed request.
+type badRequest struct {
+	err error
+}
+
+func (e badRequest) Error() string { return e.err.Error() }
+func (e badRequest) Unwrap() error { return e.err }
+
+func badRequestf(format string, args ...any) error {
+	return badRequest{err: fmt.Errorf(format, args...)}
+}
+
+// statusByKind maps the client error taxonomy to HTTP status codes.
+var statusByKind = []struct {
+	kind   error
+	status int
+}{
+	{client.ErrLedgerNotFound, http.StatusNotFound},
+	{client.ErrAccountNotFound, http.StatusNotFound},
+	{client.ErrCategoryNotFound, http.StatusNotFound},
+	{client.ErrTransactionNotFound, http.StatusNotFound},
+	{client.ErrScheduleNotFound, http.StatusNotFound},
+	{client.ErrGoalNotFound, http.StatusNotFound},
+	{client.ErrRuleNotFound, http.StatusNotFound},
+	{client.ErrPayeeNotFound, http.StatusNotFound},
+	{client.ErrDuplicateName, http.StatusConflict},
+	{client.ErrSpecialAccountProtected, http.StatusForbidden},
+	{client.ErrTransactionReconciled, http.StatusConflict},
+	{client.ErrInsufficientFunds, http.StatusConflict},
+	{client.ErrAmountOutOfRange, http.StatusUnprocessableEntity},
+	{client.ErrDateOutOfRange, http.StatusUnprocessableEntity},
+	{client.ErrInvalidTransactionType, http.StatusUnprocessableEntity},
+	{client.ErrInvalidInput, http.StatusUnprocessableEntity},
+	{client.ErrInvalidPeriod, http.StatusBadRequest},
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes the JSON error body for err.
+func writeError(w http.ResponseWriter, err error) {
+	status, body := errorStatus(err)
+	writeJSON(w, status, body)
+}
+
+// fail writes the error response for err and logs it when it is unexpected.
+func (s *Server) fail(w http.ResponseWriter, r *http.Request, err error) {
+	status, body := errorStatus(err)
+	if status == http.StatusInternalServerError {
+		s.log.Error().Err(err).Str("method", r.Method).Str("path", r.URL.Path).Msg("request failed")
+	}
+	writeJSON(w, status, body)
+}
+
+// errorStatus translates err into a status code and a JSON error body.
+// Errors raised by the database keep their message, since the api schema
+// words them for end users; anything else is reported as a bare 500.
+func errorStatus(err error) (int, errorResponse) {
+	var clientErr *client.Error
+	var br badRequest
+	switch {
+	case errors.Is(err, errUnauthenticated):
+		return http.StatusUnauthorized, errorResponse{Error: err.Error()}
+	case errors.As(err, &br):
+		return http.StatusBadRequest, errorResponse{Error: br.Error()}
+	case errors.As(err, &clientErr) && clientErr.Kind != nil:
+		status := http.StatusUnprocessableEntity
+		for _, m := range statusByKind {
+			if errors.Is(clientErr.Kind, m.kind) {
+				status = m.status
+				break
+			}
+		}
+		body := errorResponse{Error: clientErr.Message, Code: clientErr.Code}
+		if body.Error == "" {
+			body.Error = clientErr.Kind.Error()
+		}
+		return status, body
+	case errors.As(err, &clientErr) && isUserError(clientErr.Code):
+		return http.StatusUnprocessableEntity, errorResponse{Error: clientErr.Message, Code: clientErr.Code}
+	}
+
+	return http.StatusInternalServerError, errorResponse{Error: "internal server error"}
+}
+
+// isUserError reports whether a SQLSTATE describes bad input rather than a
+// server fault: data exceptions (22), integrity violations (23) and
+// exceptions raised by the api functions (P0).
+func isUserError(code string) bool {
+	if len(code) < 2 {
+		return false
+	}
+	switch code[:2] {
+	case "22", "23", "P0":
+		return true
+	}
+	return false
+}
+
+// decode reads a JSON request body into v, rejecting unknown fields.
+func decode(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return badRequestf("invalid request body: %v", err)
+	}
+	return nil
+}
+
+// parseDate accepts either a calendar date (2006-01-02) or an RFC 3339
+// timestamp. An empty value means now.
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Now(), nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, badRequestf("invalid date %q: use YYYY-MM-DD or RFC 3339", value)
+	}
+	return t, nil
+}
+
+// queryInt returns the integer query parameter name, or def when it is absent.
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, badRequestf("invalid %s %q", name, value)
+	}
+	return n, nil
+}
+
+// queryAmount returns the amount in cents of the query parameter name, or
+// nil when it is absent.
+func queryAmount(r *http.Request, name string) (*int64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	cents, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || cents < 0 {
+		return nil, badRequestf("invalid %s %q", name, value)
+	}
+	return &cents, nil
+}
+
+// queryBool reads an optional boolean query parameter, false when absent.
+func queryBool(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, badRequestf("invalid %s %q", name, value)
+	}
+	return b, nil
+}
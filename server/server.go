@@ -0,0 +1,147 @@
+// Package server exposes the pgbudget api schema as a JSON REST service.
+//
+// Every request runs inside its own database transaction with
+// `app.current_user_id` set transaction-locally to the authenticated user, so
+// the row level security policies on the data schema apply exactly as they
+// would through PostgREST, and the setting never outlives the request.
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// Server serves the REST API on top of a connection pool.
+type Server struct {
+	pool *pgxpool.Pool
+	log  zerolog.Logger
+	mux  *http.ServeMux
+	// identify resolves the user of a request. It wraps every route except
+	// the health check.
+	identify func(http.Handler) http.Handler
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithLogger sets the logger used for request and error logs.
+func WithLogger(log zerolog.Logger) Option {
+	return func(s *Server) {
+		s.log = log
+	}
+}
+
+// WithIdentity installs the middleware that authenticates requests and
+// stores the user ID in the request context with ContextWithUserID.
+func WithIdentity(mw func(http.Handler) http.Handler) Option {
+	return func(s *Server) {
+		s.identify = mw
+	}
+}
+
+// New creates a Server that runs its queries through pool.
+func New(pool *pgxpool.Pool, opts ...Option) *Server {
+	s := &Server{
+		pool:     pool,
+		log:      zerolog.Nop(),
+		mux:      http.NewServeMux(),
+		identify: func(next http.Handler) http.Handler { return next },
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.routes()
+
+	return s
+}
+
+// Handler returns the root http.Handler of the server. The health check is
+// served without authentication; every other route goes through the
+// identity middleware.
+func (s *Server) Handler() http.Handler {
+	root := http.NewServeMux()
+	root.HandleFunc("GET /healthz", s.handleHealth)
+	root.Handle("/", s.identify(s.mux))
+
+	return s.logRequests(root)
+}
+
+// ListenAndServe serves the API on addr until ctx is cancelled, then shuts
+// down gracefully.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+
+	s.log.Info().Str("addr", addr).Msg("listening")
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down: %w", err)
+	}
+
+	return nil
+}
+
+// withClient runs fn inside a transaction scoped to the user of the request
+// through client.WithUser.
+func (s *Server) withClient(r *http.Request, fn func(c *client.Client) error) error {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		return errUnauthenticated
+	}
+
+	return client.WithUser(r.Context(), s.pool, userID, func(tx pgx.Tx) error {
+		return fn(client.New(tx))
+	})
+}
+
+// logRequests logs one line per request with its status and duration.
+func (s *Server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		s.log.Info().
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Int("status", rec.status).
+			Dur("duration", time.Since(start)).
+			Msg("request")
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
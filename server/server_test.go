@@ -0,0 +1,930 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/pgbudget/auth"
+	"github.com/j0lvera/pgbudget/client"
+	"github.com/j0lvera/pgbudget/server"
+	"github.com/j0lvera/pgbudget/testutils/pgcontainer"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	is_ "github.com/matryer/is"
+	"github.com/rs/zerolog"
+)
+
+var (
+	testDSN string
+	log     zerolog.Logger
+)
+
+// apiRole is the role the server connects as in tests. The migrations run as
+// the table owner, which bypasses row level security, so the server needs a
+// role of its own for RLS to apply.
+const apiRole = "pgbudget_api"
+
+func TestMain(m *testing.M) {
+	// Setup logging
+	log = zerolog.New(os.Stdout).With().Timestamp().Logger()
+
+	// Create a context with timeout for setup
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	// Configure and start the PostgreSQL container
+	cfg := pgcontainer.NewConfig()
+	cfg.WithLogger(&log).WithMigrationsPath("migrations") // Path relative to project root
+
+	pgContainer := pgcontainer.NewPgContainer(cfg)
+	output, err := pgContainer.Start(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to start PostgreSQL container")
+	}
+
+	if err := createAPIRole(ctx, output.DSN()); err != nil {
+		log.Fatal().Err(err).Msg("Failed to create API role")
+	}
+
+	testDSN = output.DSN()
+
+	os.Exit(m.Run())
+}
+
+// createAPIRole creates a login role with the grants the server needs.
+func createAPIRole(ctx context.Context, dsn string) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(
+		ctx, `
+		create role `+apiRole+` login password '`+apiRole+`';
+		grant usage on schema api, data, utils to `+apiRole+`;
+		grant select, insert, update, delete on all tables in schema api, data to `+apiRole+`;
+		grant usage on all sequences in schema data to `+apiRole+`;
+		grant execute on all functions in schema api, utils to `+apiRole+`;
+	`,
+	)
+	return err
+}
+
+// newAPIPool connects to the test database as apiRole.
+func newAPIPool(ctx context.Context) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(testDSN)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ConnConfig.User = apiRole
+	cfg.ConnConfig.Password = apiRole
+	client.ConfigurePool(cfg)
+
+	return pgxpool.NewWithConfig(ctx, cfg)
+}
+
+// apiClient issues JSON requests against the test server as a given user.
+type apiClient struct {
+	t      *testing.T
+	url    string
+	userID string
+}
+
+// do sends body as JSON and decodes the response into out when it is not nil.
+func (c apiClient) do(method, path string, body, out any) int {
+	c.t.Helper()
+
+	_, status := c.doHeader(method, path, body, out)
+	return status
+}
+
+// doHeader is do returning the response headers too.
+func (c apiClient) doHeader(method, path string, body, out any) (http.Header, int) {
+	c.t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			c.t.Fatalf("encode request: %v", err)
+		}
+	}
+
+	req, err := http.NewRequest(method, c.url+path, &buf)
+	if err != nil {
+		c.t.Fatalf("new request: %v", err)
+	}
+	if c.userID != "" {
+		req.Header.Set("X-User-ID", c.userID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			c.t.Fatalf("decode %s %s: %v", method, path, err)
+		}
+	}
+
+	return resp.Header, resp.StatusCode
+}
+
+// TestServer drives the REST API end to end for two users and checks that
+// neither can see the other's data.
+func TestServer(t *testing.T) {
+	is := is_.New(t)
+	ctx := context.Background()
+
+	pool, err := newAPIPool(ctx)
+	is.NoErr(err) // should connect to database without error
+	t.Cleanup(pool.Close)
+
+	srv := server.New(pool, server.WithLogger(log), server.WithIdentity(server.HeaderIdentity("X-User-ID")))
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+
+	alice := apiClient{t: t, url: ts.URL, userID: "alice"}
+	bob := apiClient{t: t, url: ts.URL, userID: "bob"}
+	anonymous := apiClient{t: t, url: ts.URL}
+
+	var ledger client.Ledger
+	status := alice.do(http.MethodPost, "/ledgers", map[string]any{"name": "Alice Budget"}, &ledger)
+	is.Equal(status, http.StatusCreated)
+	is.Equal(ledger.UserData, "alice") // ledger should belong to the request user
+
+	var (
+		checking  client.Account
+		groceries client.Account
+		income    client.Account
+	)
+
+	t.Run(
+		"Health", func(t *testing.T) {
+			is := is_.New(t)
+
+			var body map[string]string
+			status := anonymous.do(http.MethodGet, "/healthz", nil, &body)
+			is.Equal(status, http.StatusOK) // health check needs no identity
+			is.Equal(body["status"], "ok")
+		},
+	)
+
+	t.Run(
+		"Setup", func(t *testing.T) {
+			is := is_.New(t)
+
+			status := alice.do(
+				http.MethodPost, "/ledgers/"+ledger.UUID+"/accounts",
+				map[string]any{"name": "Checking", "type": "asset"}, &checking,
+			)
+			is.Equal(status, http.StatusCreated)
+
+			status = alice.do(
+				http.MethodPost, "/ledgers/"+ledger.UUID+"/categories",
+				map[string]any{"name": "Groceries"}, &groceries,
+			)
+			is.Equal(status, http.StatusCreated)
+
+			var categories []client.Account
+			status = alice.do(http.MethodGet, "/ledgers/"+ledger.UUID+"/categories", nil, &categories)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(categories), 4) // 3 special accounts + Groceries
+			for _, c := range categories {
+				if c.Name == client.IncomeCategory {
+					income = c
+				}
+			}
+			is.True(income.UUID != "")
+		},
+	)
+
+	t.Run(
+		"Transactions", func(t *testing.T) {
+			is := is_.New(t)
+			today := time.Now().Format(time.DateOnly)
+
+			var created struct{ UUID string }
+			status := alice.do(
+				http.MethodPost, "/ledgers/"+ledger.UUID+"/transactions",
+				map[string]any{
+					"date": today, "description": "Paycheck", "type": "inflow", "amount": 100000,
+					"account_uuid": checking.UUID, "category_uuid": income.UUID,
+				}, &created,
+			)
+			is.Equal(status, http.StatusCreated)
+			is.True(created.UUID != "")
+
+			status = alice.do(
+				http.MethodPost, "/ledgers/"+ledger.UUID+"/assignments",
+				map[string]any{"date": today, "description": "Budget", "amount": 30000, "category_uuid": groceries.UUID},
+				nil,
+			)
+			is.Equal(status, http.StatusCreated)
+
+			status = alice.do(
+				http.MethodPost, "/ledgers/"+ledger.UUID+"/transactions",
+				map[string]any{
+					"date": today, "description": "Groceries", "type": "outflow", "amount": 4500,
+					"account_uuid": checking.UUID, "category_uuid": groceries.UUID,
+				}, &created,
+			)
+			is.Equal(status, http.StatusCreated)
+
+			var balance struct{ Balance int64 }
+			status = alice.do(http.MethodGet, "/accounts/"+checking.UUID+"/balance", nil, &balance)
+			is.Equal(status, http.StatusOK)
+			is.Equal(balance.Balance, int64(95500))
+
+			var history []client.AccountTransaction
+			status = alice.do(http.MethodGet, "/accounts/"+checking.UUID+"/transactions", nil, &history)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(history), 2)
+
+			var reversal struct{ UUID string }
+			status = alice.do(http.MethodDelete, "/transactions/"+created.UUID+"?reason=duplicate", nil, &reversal)
+			is.Equal(status, http.StatusOK)
+			is.True(reversal.UUID != "")
+
+			status = alice.do(http.MethodGet, "/accounts/"+checking.UUID+"/balance", nil, &balance)
+			is.Equal(status, http.StatusOK)
+			is.Equal(balance.Balance, int64(100000))
+		},
+	)
+
+	t.Run(
+		"Reports", func(t *testing.T) {
+			is := is_.New(t)
+
+			var budget []client.BudgetStatus
+			status := alice.do(http.MethodGet, "/ledgers/"+ledger.UUID+"/budget-status", nil, &budget)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(budget), 1)
+			is.Equal(budget[0].Budgeted, int64(30000))
+
+			var totals client.BudgetTotals
+			period := time.Now().Format("200601")
+			status = alice.do(http.MethodGet, "/ledgers/"+ledger.UUID+"/budget-totals?period="+period, nil, &totals)
+			is.Equal(status, http.StatusOK)
+			is.Equal(totals.LeftToBudget, int64(70000))
+
+			var balances []client.LedgerBalance
+			status = alice.do(http.MethodGet, "/ledgers/"+ledger.UUID+"/balances", nil, &balances)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(balances), 5)
+		},
+	)
+
+	t.Run(
+		"SplitTransactions", func(t *testing.T) {
+			is := is_.New(t)
+
+			var household client.Account
+			status := alice.do(
+				http.MethodPost, "/ledgers/"+ledger.UUID+"/categories",
+				map[string]any{"name": "Household"}, &household,
+			)
+			is.Equal(status, http.StatusCreated)
+
+			var created struct{ UUID string }
+			status = alice.do(
+				http.MethodPost, "/ledgers/"+ledger.UUID+"/split-transactions",
+				map[string]any{
+					"description": "Supermarket", "type": "outflow", "account_uuid": checking.UUID,
+					"splits": []map[string]any{
+						{"category_uuid": groceries.UUID, "amount": 8000},
+						{"category_uuid": household.UUID, "amount": 4000, "memo": "Soap"},
+					},
+				}, &created,
+			)
+			is.Equal(status, http.StatusCreated)
+
+			var split client.SplitTransaction
+			status = alice.do(http.MethodGet, "/split-transactions/"+created.UUID, nil, &split)
+			is.Equal(status, http.StatusOK)
+			is.Equal(split.Amount, int64(12000))
+			is.Equal(len(split.Splits), 2)
+
+			status = alice.do(
+				http.MethodPost, "/split-transactions/"+created.UUID+"/corrections",
+				map[string]any{
+					"description": "Supermarket", "type": "outflow", "account_uuid": checking.UUID,
+					"splits": []map[string]any{
+						{"category_uuid": groceries.UUID, "amount": 7000},
+						{"category_uuid": household.UUID, "amount": 3000},
+					},
+					"reason": "typo",
+				}, &created,
+			)
+			is.Equal(status, http.StatusCreated)
+
+			var balance struct{ Balance int64 }
+			status = alice.do(http.MethodGet, "/accounts/"+checking.UUID+"/balance", nil, &balance)
+			is.Equal(status, http.StatusOK)
+			is.Equal(balance.Balance, int64(90000))
+
+			status = alice.do(http.MethodDelete, "/transactions/"+created.UUID, nil, nil)
+			is.Equal(status, http.StatusOK) // deleting the split removes every split
+
+			status = alice.do(http.MethodGet, "/accounts/"+checking.UUID+"/balance", nil, &balance)
+			is.Equal(status, http.StatusOK)
+			is.Equal(balance.Balance, int64(100000))
+
+			status = bob.do(http.MethodGet, "/split-transactions/"+created.UUID, nil, nil)
+			is.Equal(status, http.StatusNotFound)
+		},
+	)
+
+	t.Run(
+		"Schedules", func(t *testing.T) {
+			is := is_.New(t)
+			lastWeek := time.Now().AddDate(0, 0, -7).Format(time.DateOnly)
+
+			var created struct{ UUID string }
+			status := alice.do(
+				http.MethodPost, "/ledgers/"+ledger.UUID+"/schedules",
+				map[string]any{
+					"name": "Allowance", "type": "outflow", "amount": 1000, "account_uuid": checking.UUID,
+					"category_uuid": groceries.UUID, "frequency": "weekly", "start_date": lastWeek,
+				}, &created,
+			)
+			is.Equal(status, http.StatusCreated)
+
+			var schedules []client.Schedule
+			status = alice.do(http.MethodGet, "/ledgers/"+ledger.UUID+"/schedules", nil, &schedules)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(schedules), 1)
+
+			var recorded []client.ScheduledTransaction
+			status = alice.do(http.MethodPost, "/ledgers/"+ledger.UUID+"/schedules/run", nil, &recorded)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(recorded), 2) // last week and today
+
+			var upcoming []client.UpcomingTransaction
+			status = alice.do(http.MethodGet, "/ledgers/"+ledger.UUID+"/upcoming-transactions", nil, &upcoming)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(upcoming), 4) // the next 30 days
+
+			status = bob.do(http.MethodDelete, "/schedules/"+created.UUID, nil, nil)
+			is.Equal(status, http.StatusNotFound)
+
+			status = alice.do(http.MethodDelete, "/schedules/"+created.UUID, nil, nil)
+			is.Equal(status, http.StatusNoContent)
+		},
+	)
+
+	t.Run(
+		"Posting", func(t *testing.T) {
+			is := is_.New(t)
+			path := "/accounts/" + checking.UUID
+
+			var pending []client.AccountTransaction
+			status := alice.do(http.MethodGet, path+"/transactions?status=pending", nil, &pending)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(pending), 2) // the allowance recorded by the schedule
+
+			status = bob.do(http.MethodPost, "/transactions/"+pending[0].UUID+"/post", nil, nil)
+			is.Equal(status, http.StatusNotFound)
+
+			status = alice.do(http.MethodPost, "/transactions/"+pending[0].UUID+"/post", nil, nil)
+			is.Equal(status, http.StatusNoContent)
+
+			var posted struct{ Posted int }
+			status = alice.do(
+				http.MethodPost, "/transactions/post",
+				map[string]any{"transaction_uuids": []string{pending[0].UUID, pending[1].UUID}}, &posted,
+			)
+			is.Equal(status, http.StatusOK)
+			is.Equal(posted.Posted, 1)
+
+			var balance struct {
+				Balance        int64
+				ClearedBalance int64 `json:"cleared_balance"`
+			}
+			status = alice.do(http.MethodGet, path+"/balance", nil, &balance)
+			is.Equal(status, http.StatusOK)
+			is.Equal(balance.ClearedBalance, balance.Balance)
+
+			status = alice.do(http.MethodGet, path+"/transactions?status=cleared", nil, nil)
+			is.Equal(status, http.StatusUnprocessableEntity)
+		},
+	)
+
+	t.Run(
+		"Reconciliation", func(t *testing.T) {
+			is := is_.New(t)
+			path := "/accounts/" + checking.UUID + "/reconciliations"
+			statement := map[string]any{"statement_balance": 1000}
+
+			var preview client.ReconciliationPreview
+			status := alice.do(http.MethodPost, path+"/preview", statement, &preview)
+			is.Equal(status, http.StatusOK)
+			is.Equal(preview.StatementBalance, int64(1000))
+
+			status = bob.do(http.MethodPost, path, statement, nil)
+			is.Equal(status, http.StatusNotFound)
+
+			var created struct{ UUID string }
+			status = alice.do(http.MethodPost, path, statement, &created)
+			is.Equal(status, http.StatusCreated)
+
+			var reconciliations []client.Reconciliation
+			status = alice.do(http.MethodGet, path, nil, &reconciliations)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(reconciliations), 1)
+			is.Equal(reconciliations[0].UUID, created.UUID)
+
+			var history []client.AccountTransaction
+			status = alice.do(http.MethodGet, "/accounts/"+checking.UUID+"/transactions", nil, &history)
+			is.Equal(status, http.StatusOK)
+			is.True(history[0].Reconciled)
+
+			status = alice.do(http.MethodDelete, "/transactions/"+history[0].UUID, nil, nil)
+			is.Equal(status, http.StatusConflict) // reconciled transactions are locked
+
+			status = alice.do(http.MethodDelete, "/transactions/"+history[0].UUID+"?override=true", nil, nil)
+			is.Equal(status, http.StatusOK)
+		},
+	)
+
+	t.Run(
+		"Goals", func(t *testing.T) {
+			is := is_.New(t)
+			path := "/categories/" + groceries.UUID + "/goal"
+
+			status := bob.do(http.MethodPut, path, map[string]any{"type": "monthly_funding", "amount": 100}, nil)
+			is.Equal(status, http.StatusNotFound)
+
+			status = alice.do(http.MethodPut, path, map[string]any{"type": "monthly_funding", "amount": 100}, nil)
+			is.Equal(status, http.StatusNoContent)
+
+			var progress []client.GoalProgress
+			status = alice.do(http.MethodGet, "/ledgers/"+ledger.UUID+"/goal-progress", nil, &progress)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(progress), 1)
+			is.Equal(progress[0].Needed, int64(100))
+
+			var assignments []client.Transaction
+			status = alice.do(http.MethodPost, "/ledgers/"+ledger.UUID+"/goals/assign", nil, &assignments)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(assignments), 0) // 300.00 are budgeted this month already
+
+			status = alice.do(http.MethodPut, path, map[string]any{"type": "target_balance", "amount": 100}, nil)
+			is.Equal(status, http.StatusUnprocessableEntity) // target balances need a date
+
+			status = alice.do(http.MethodDelete, path, nil, nil)
+			is.Equal(status, http.StatusNoContent)
+			status = alice.do(http.MethodDelete, path, nil, nil)
+			is.Equal(status, http.StatusNotFound)
+		},
+	)
+
+	t.Run(
+		"MoveBetweenCategories", func(t *testing.T) {
+			is := is_.New(t)
+			path := "/ledgers/" + ledger.UUID + "/moves"
+
+			var balance struct{ Balance int64 }
+			status := alice.do(http.MethodGet, "/accounts/"+groceries.UUID+"/balance", nil, &balance)
+			is.Equal(status, http.StatusOK)
+
+			move := map[string]any{
+				"from_category_uuid": groceries.UUID, "to_category_uuid": income.UUID, "amount": balance.Balance + 100,
+			}
+			status = bob.do(http.MethodPost, path, move, nil)
+			is.Equal(status, http.StatusNotFound)
+
+			status = alice.do(http.MethodPost, path, move, nil)
+			is.Equal(status, http.StatusConflict) // Groceries cannot be overdrawn
+
+			move["force"] = true
+			var transaction client.Transaction
+			status = alice.do(http.MethodPost, path, move, &transaction)
+			is.Equal(status, http.StatusCreated)
+			is.Equal(*transaction.AccountUUID, groceries.UUID)
+			is.Equal(transaction.Description, "Move from Groceries to Income")
+
+			status = alice.do(http.MethodGet, "/accounts/"+groceries.UUID+"/balance", nil, &balance)
+			is.Equal(status, http.StatusOK)
+			is.Equal(balance.Balance, int64(-100))
+		},
+	)
+
+	t.Run(
+		"Transfers", func(t *testing.T) {
+			is := is_.New(t)
+			path := "/ledgers/" + ledger.UUID + "/transfers"
+
+			var card client.Account
+			status := alice.do(
+				http.MethodPost, "/ledgers/"+ledger.UUID+"/accounts",
+				map[string]any{"name": "Visa", "type": "liability"}, &card,
+			)
+			is.Equal(status, http.StatusCreated)
+
+			var categories []client.Account
+			status = alice.do(http.MethodGet, "/ledgers/"+ledger.UUID+"/categories", nil, &categories)
+			is.Equal(status, http.StatusOK)
+			found := false
+			for _, c := range categories {
+				found = found || c.Name == "Visa Payment"
+			}
+			is.True(found) // every card gets a payment category
+
+			payment := map[string]any{"from_account_uuid": checking.UUID, "to_account_uuid": card.UUID, "amount": 100}
+			status = bob.do(http.MethodPost, path, payment, nil)
+			is.Equal(status, http.StatusNotFound)
+
+			var created map[string]string
+			status = alice.do(http.MethodPost, path, payment, &created)
+			is.Equal(status, http.StatusCreated)
+			is.True(created["uuid"] != "")
+
+			payment["to_account_uuid"] = income.UUID
+			status = alice.do(http.MethodPost, path, payment, nil)
+			is.Equal(status, http.StatusUnprocessableEntity)
+		},
+	)
+
+	t.Run(
+		"CategoryRules", func(t *testing.T) {
+			is := is_.New(t)
+			path := "/ledgers/" + ledger.UUID + "/rules"
+
+			var bakery struct{ UUID string }
+			status := alice.do(
+				http.MethodPost, "/ledgers/"+ledger.UUID+"/transactions",
+				map[string]any{
+					"date": time.Now().Format(time.DateOnly), "description": "Corner Bakery", "type": "outflow",
+					"amount": 800, "account_uuid": checking.UUID,
+				}, &bakery,
+			)
+			is.Equal(status, http.StatusCreated)
+
+			rule := map[string]any{
+				"name": "Bakery", "description_pattern": "bakery", "category_uuid": groceries.UUID, "tags": []string{"bread"},
+			}
+			status = bob.do(http.MethodPost, path, rule, nil)
+			is.Equal(status, http.StatusNotFound)
+
+			var created map[string]string
+			status = alice.do(http.MethodPost, path, rule, &created)
+			is.Equal(status, http.StatusCreated)
+
+			var rules []client.CategoryRule
+			status = alice.do(http.MethodGet, path, nil, &rules)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(rules), 1)
+			is.Equal(rules[0].UUID, created["uuid"])
+
+			var applied []client.RuleApplication
+			status = alice.do(http.MethodPost, path+"/apply", nil, &applied)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(applied), 1)
+			is.Equal(applied[0].TransactionUUID, bakery.UUID)
+			is.Equal(applied[0].CategoryUUID, groceries.UUID)
+
+			status = alice.do(http.MethodDelete, "/rules/"+created["uuid"], nil, nil)
+			is.Equal(status, http.StatusNoContent)
+			status = alice.do(http.MethodDelete, "/rules/"+created["uuid"], nil, nil)
+			is.Equal(status, http.StatusNotFound)
+
+			status = alice.do(http.MethodPost, path, map[string]any{"name": "Idle"}, nil)
+			is.Equal(status, http.StatusUnprocessableEntity) // a rule needs an action
+		},
+	)
+
+	t.Run(
+		"Payees", func(t *testing.T) {
+			is := is_.New(t)
+			path := "/ledgers/" + ledger.UUID + "/payees"
+
+			status := bob.do(http.MethodPost, path, map[string]any{"name": "Costco"}, nil)
+			is.Equal(status, http.StatusNotFound)
+
+			var costco map[string]string
+			status = alice.do(
+				http.MethodPost, path, map[string]any{"name": "Costco", "default_category_uuid": groceries.UUID}, &costco,
+			)
+			is.Equal(status, http.StatusCreated)
+			status = alice.do(http.MethodPost, path, map[string]any{"name": "COSTCO"}, nil)
+			is.Equal(status, http.StatusConflict)
+
+			var created struct{ UUID string }
+			status = alice.do(
+				http.MethodPost, "/ledgers/"+ledger.UUID+"/transactions",
+				map[string]any{
+					"date": time.Now().Format(time.DateOnly), "description": "Bulk rice", "type": "outflow",
+					"amount": 2500, "account_uuid": checking.UUID, "payee": "costco",
+				}, &created,
+			)
+			is.Equal(status, http.StatusCreated)
+
+			var history []client.AccountTransaction
+			status = alice.do(http.MethodGet, "/accounts/"+checking.UUID+"/transactions", nil, &history)
+			is.Equal(status, http.StatusOK)
+			for _, tx := range history {
+				if tx.UUID == created.UUID {
+					is.Equal(tx.CategoryUUID, groceries.UUID) // the default category of the payee
+					is.Equal(*tx.Payee, "Costco")
+				}
+			}
+
+			var count map[string]int
+			status = alice.do(http.MethodPost, path+"/backfill", nil, &count)
+			is.Equal(status, http.StatusOK)
+			is.True(count["transactions"] > 0)
+
+			var wholesale map[string]string
+			status = alice.do(http.MethodPost, path, map[string]any{"name": "Wholesale"}, &wholesale)
+			is.Equal(status, http.StatusCreated)
+			status = alice.do(http.MethodPost, "/payees/"+costco["uuid"]+"/merge", map[string]any{"target_uuid": wholesale["uuid"]}, &count)
+			is.Equal(status, http.StatusOK)
+			is.Equal(count["transactions"], 1)
+
+			status = alice.do(http.MethodPut, "/payees/"+wholesale["uuid"], map[string]any{"name": "Costco Wholesale"}, nil)
+			is.Equal(status, http.StatusNoContent)
+			status = alice.do(http.MethodPut, "/payees/"+costco["uuid"], map[string]any{"name": "Costco"}, nil)
+			is.Equal(status, http.StatusNotFound) // merged payees are deleted
+
+			var payees []client.Payee
+			status = alice.do(http.MethodGet, path, nil, &payees)
+			is.Equal(status, http.StatusOK)
+			found := false
+			for _, p := range payees {
+				if p.UUID == wholesale["uuid"] {
+					found = true
+					is.Equal(p.Name, "Costco Wholesale")
+					is.Equal(p.Transactions, 1)
+					is.Equal(*p.DefaultCategoryName, "Groceries") // taken from the merged payee
+				}
+			}
+			is.True(found)
+		},
+	)
+
+	t.Run(
+		"Tags", func(t *testing.T) {
+			is := is_.New(t)
+
+			var created struct{ UUID string }
+			status := alice.do(
+				http.MethodPost, "/ledgers/"+ledger.UUID+"/transactions",
+				map[string]any{
+					"date": time.Now().Format(time.DateOnly), "description": "Museum tickets", "type": "outflow",
+					"amount": 3600, "account_uuid": checking.UUID, "category_uuid": groceries.UUID,
+				}, &created,
+			)
+			is.Equal(status, http.StatusCreated)
+			path := "/transactions/" + created.UUID + "/tags"
+
+			status = bob.do(http.MethodPost, path, map[string]any{"tags": []string{"museum"}}, nil)
+			is.Equal(status, http.StatusNotFound)
+
+			var tagged struct{ Tags []string }
+			status = alice.do(http.MethodPost, path, map[string]any{"tags": []string{"Vacation-2026", "museum"}}, &tagged)
+			is.Equal(status, http.StatusOK)
+			is.Equal(tagged.Tags, []string{"museum", "vacation-2026"})
+			status = alice.do(http.MethodPost, path, map[string]any{"tags": []string{"two words"}}, nil)
+			is.Equal(status, http.StatusUnprocessableEntity)
+
+			var history []client.AccountTransaction
+			status = alice.do(
+				http.MethodGet, "/accounts/"+checking.UUID+"/transactions?tag=museum&tag=vacation-2026", nil, &history,
+			)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(history), 1)
+			is.Equal(history[0].UUID, created.UUID)
+
+			var spending []client.TagSpending
+			period := time.Now().Format("200601")
+			status = alice.do(
+				http.MethodGet, "/ledgers/"+ledger.UUID+"/tag-spending?tag=museum&from="+period+"&to="+period, nil, &spending,
+			)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(spending), 1)
+			is.Equal(spending[0].Spent, int64(3600))
+
+			status = alice.do(http.MethodDelete, path+"?tag=museum", nil, &tagged)
+			is.Equal(status, http.StatusOK)
+			is.Equal(tagged.Tags, []string{"vacation-2026"})
+			status = alice.do(http.MethodDelete, path, nil, &tagged)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(tagged.Tags), 0) // without tag parameters every tag goes
+		},
+	)
+
+	t.Run(
+		"Search", func(t *testing.T) {
+			is := is_.New(t)
+
+			for _, amount := range []int{1800, 5400} {
+				status := alice.do(
+					http.MethodPost, "/ledgers/"+ledger.UUID+"/transactions",
+					map[string]any{
+						"date": time.Now().Format(time.DateOnly), "description": "Quillon Books #7", "type": "outflow",
+						"amount": amount, "account_uuid": checking.UUID, "category_uuid": groceries.UUID,
+					}, nil,
+				)
+				is.Equal(status, http.StatusCreated)
+			}
+			path := "/ledgers/" + ledger.UUID + "/transactions"
+
+			status := bob.do(http.MethodGet, path+"?q=quillon", nil, nil)
+			is.Equal(status, http.StatusNotFound)
+
+			var page client.SearchPage
+			status = alice.do(http.MethodGet, path+"?q=quill&min_amount=2000&account="+checking.UUID, nil, &page)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(page.Transactions), 1)
+			is.Equal(page.Transactions[0].Amount, int64(5400))
+
+			status = alice.do(http.MethodGet, path+"?q=books+%237&limit=1", nil, &page)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(page.Transactions), 1)
+			is.True(page.NextCursor != "")
+			status = alice.do(http.MethodGet, path+"?q=books+%237&limit=1&after="+page.NextCursor, nil, &page)
+			is.Equal(status, http.StatusOK)
+			is.Equal(page.Transactions[0].Amount, int64(1800))
+
+			status = alice.do(http.MethodGet, path+"?min_amount=ten", nil, nil)
+			is.Equal(status, http.StatusBadRequest)
+			status = alice.do(http.MethodGet, path+"?status=cleared", nil, nil)
+			is.Equal(status, http.StatusUnprocessableEntity)
+		},
+	)
+
+	t.Run(
+		"AccountTransactionsPage", func(t *testing.T) {
+			is := is_.New(t)
+			path := "/accounts/" + checking.UUID + "/transactions"
+
+			var whole []client.AccountTransaction
+			status := alice.do(http.MethodGet, path, nil, &whole)
+			is.Equal(status, http.StatusOK)
+			is.True(len(whole) > 2)
+
+			var page []client.AccountTransaction
+			header, status := alice.doHeader(http.MethodGet, path+"?limit=2&total=true", nil, &page)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(page), 2)
+			is.Equal(page[0].UUID, whole[0].UUID)
+			is.Equal(header.Get("X-Total-Count"), strconv.Itoa(len(whole)))
+
+			status = alice.do(http.MethodGet, path+"?limit=2&after="+page[1].UUID, nil, &page)
+			is.Equal(status, http.StatusOK)
+			is.Equal(page[0].UUID, whole[2].UUID)
+
+			status = alice.do(http.MethodGet, path+"?before="+whole[1].UUID, nil, &page)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(page), 1)
+			is.Equal(page[0].UUID, whole[0].UUID)
+
+			status = alice.do(http.MethodGet, path+"?after=missing", nil, nil)
+			is.Equal(status, http.StatusNotFound)
+			status = alice.do(http.MethodGet, path+"?limit=many", nil, nil)
+			is.Equal(status, http.StatusBadRequest)
+		},
+	)
+
+	t.Run(
+		"MonthClose", func(t *testing.T) {
+			is := is_.New(t)
+			path := "/ledgers/" + ledger.UUID
+
+			var rules client.RolloverRules
+			status := alice.do(http.MethodGet, path+"/rollover-rules", nil, &rules)
+			is.Equal(status, http.StatusOK)
+			is.Equal(rules.CreditOverspending, client.RolloverUnassigned)
+
+			rules.CreditOverspending = client.RolloverKeep
+			status = alice.do(http.MethodPut, path+"/rollover-rules", rules, nil)
+			is.Equal(status, http.StatusNoContent)
+
+			now := time.Now()
+			lastMonth := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.UTC).Format("200601")
+			status = bob.do(http.MethodPost, path+"/months/"+lastMonth+"/close", nil, nil)
+			is.Equal(status, http.StatusNotFound)
+
+			var recorded []client.MonthCloseTransaction
+			status = alice.do(http.MethodPost, path+"/months/"+lastMonth+"/close", nil, &recorded)
+			is.Equal(status, http.StatusOK)
+
+			status = alice.do(http.MethodPost, path+"/months/"+lastMonth+"/close", nil, nil)
+			is.Equal(status, http.StatusUnprocessableEntity) // months close once
+
+			var months []client.ClosedMonth
+			status = alice.do(http.MethodGet, path+"/closed-months", nil, &months)
+			is.Equal(status, http.StatusOK)
+			is.Equal(len(months), 1)
+			is.Equal(months[0].Transactions, len(recorded))
+		},
+	)
+
+	t.Run(
+		"Isolation", func(t *testing.T) {
+			is := is_.New(t)
+
+			var ledgers []client.Ledger
+			status := bob.do(http.MethodGet, "/ledgers", nil, &ledgers)
+			is.Equal(status, http.StatusOK)
+			for _, l := range ledgers {
+				is.True(l.UUID != ledger.UUID) // bob should not see alice's ledger
+			}
+
+			status = bob.do(http.MethodGet, "/ledgers/"+ledger.UUID, nil, nil)
+			is.Equal(status, http.StatusNotFound)
+
+			status = bob.do(
+				http.MethodPost, "/ledgers/"+ledger.UUID+"/categories",
+				map[string]any{"name": "Sneaky"}, nil,
+			)
+			is.Equal(status, http.StatusNotFound) // bob cannot write into alice's ledger
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			is := is_.New(t)
+
+			status := anonymous.do(http.MethodGet, "/ledgers", nil, nil)
+			is.Equal(status, http.StatusUnauthorized)
+
+			var body struct {
+				Error string
+				Code  string
+			}
+			status = alice.do(
+				http.MethodPost, "/ledgers/"+ledger.UUID+"/transactions",
+				map[string]any{"type": "outflow", "amount": 0, "account_uuid": checking.UUID}, &body,
+			)
+			is.Equal(status, http.StatusUnprocessableEntity)
+			is.Equal(body.Code, client.CodeAmountOutOfRange)
+
+			status = alice.do(
+				http.MethodPost, "/ledgers/"+ledger.UUID+"/categories",
+				map[string]any{"name": "Groceries"}, &body,
+			)
+			is.Equal(status, http.StatusConflict)
+
+			status = alice.do(http.MethodPost, "/ledgers", map[string]any{"bogus": true}, nil)
+			is.Equal(status, http.StatusBadRequest) // unknown fields are rejected
+
+			status = alice.do(http.MethodGet, "/ledgers/"+ledger.UUID+"/budget-status?period=2025-08", nil, nil)
+			is.Equal(status, http.StatusBadRequest)
+		},
+	)
+
+	t.Run(
+		"Authentication", func(t *testing.T) {
+			is := is_.New(t)
+
+			keys, err := auth.NewAPIKeys(map[string]string{"alice-key": "alice"})
+			is.NoErr(err)
+
+			srv := server.New(pool, server.WithIdentity(server.Authenticate(keys)))
+			ts := httptest.NewServer(srv.Handler())
+			t.Cleanup(ts.Close)
+
+			get := func(key string) *http.Response {
+				req, err := http.NewRequest(http.MethodGet, ts.URL+"/ledgers", nil)
+				is.NoErr(err)
+				if key != "" {
+					req.Header.Set(auth.APIKeyHeader, key)
+				}
+				// the trusted header is not enabled on this server and must be ignored
+				req.Header.Set("X-User-ID", "alice")
+
+				resp, err := http.DefaultClient.Do(req)
+				is.NoErr(err)
+				t.Cleanup(func() { resp.Body.Close() })
+				return resp
+			}
+
+			resp := get("")
+			is.Equal(resp.StatusCode, http.StatusUnauthorized) // no key, no identity
+			is.True(resp.Header.Get("WWW-Authenticate") != "")
+
+			resp = get("wrong-key")
+			is.Equal(resp.StatusCode, http.StatusUnauthorized)
+
+			resp = get("alice-key")
+			is.Equal(resp.StatusCode, http.StatusOK)
+
+			var ledgers []client.Ledger
+			is.NoErr(json.NewDecoder(resp.Body).Decode(&ledgers))
+			is.Equal(len(ledgers), 1) // only alice's ledger
+			is.Equal(ledgers[0].UUID, ledger.UUID)
+		},
+	)
+}
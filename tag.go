@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+)
+
+const tagAddUsage = `Usage: pgbudget tag add -tx <uuid> [flags] <tag>...
+
+Tags a transaction, keeping the tags it has, and shows the tags it carries
+now. Tags are stored in lowercase and cannot hold spaces or commas, e.g.
+vacation-2026 or reimbursable. The UUID of a split tags all of its splits.
+
+Flags:
+`
+
+const tagRemoveUsage = `Usage: pgbudget tag remove -tx <uuid> [flags] [<tag>...]
+
+Removes tags from a transaction, or every tag when none is given, and shows
+the tags left.
+
+Flags:
+`
+
+const tagSpendingUsage = `Usage: pgbudget tag spending [flags]
+
+Shows what the transactions carrying each tag spent per month and category:
+what was paid out of the category less what came back into it. -tags keeps
+only some tags and -from and -to bound the months.
+
+Flags:
+`
+
+func runTag(ctx context.Context, args []string) error {
+	return runSubcommand(
+		ctx, "tag", []subcommand{
+			{"add", "tag a transaction", runTagAdd},
+			{"remove", "remove tags from a transaction", runTagRemove},
+			{"spending", "show spending per tag, month and category", runTagSpending},
+		}, args,
+	)
+}
+
+// tagsResult is printed by tag add and tag remove.
+type tagsResult struct {
+	Tags []string `json:"tags"`
+}
+
+func runTagAdd(ctx context.Context, args []string) error {
+	fs := newFlagSet("tag add", tagAddUsage)
+	s := registerSessionFlags(fs)
+	s.registerFormatFlag(fs)
+	transaction := fs.String("tx", "", "transaction or split UUID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if err := s.resolve(); err != nil {
+		return err
+	}
+	if *transaction == "" {
+		return errors.New("missing -tx")
+	}
+
+	var result tagsResult
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		result.Tags, err = c.TagTransaction(ctx, *transaction, fs.Args()...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(result, table{header: []string{"tags"}, rows: [][]string{{strings.Join(result.Tags, ",")}}})
+}
+
+func runTagRemove(ctx context.Context, args []string) error {
+	fs := newFlagSet("tag remove", tagRemoveUsage)
+	s := registerSessionFlags(fs)
+	s.registerFormatFlag(fs)
+	transaction := fs.String("tx", "", "transaction or split UUID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := s.resolve(); err != nil {
+		return err
+	}
+	if *transaction == "" {
+		return errors.New("missing -tx")
+	}
+
+	var result tagsResult
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		result.Tags, err = c.UntagTransaction(ctx, *transaction, fs.Args()...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(result, table{header: []string{"tags"}, rows: [][]string{{strings.Join(result.Tags, ",")}}})
+}
+
+func runTagSpending(ctx context.Context, args []string) error {
+	fs := newFlagSet("tag spending", tagSpendingUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	tags := fs.String("tags", "", "comma-separated tags to report (default all)")
+	from := fs.String("from", "", "first month as YYYYMM")
+	to := fs.String("to", "", "last month as YYYYMM")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	for _, period := range []struct{ flag, value string }{{"from", *from}, {"to", *to}} {
+		if period.value == "" {
+			continue
+		}
+		if _, err := time.Parse("200601", period.value); err != nil {
+			return fmt.Errorf("invalid -%s %q: use YYYYMM", period.flag, period.value)
+		}
+	}
+
+	var spending []client.TagSpending
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		spending, err = c.GetTagSpending(
+			ctx, client.TagSpendingParams{
+				LedgerUUID:  s.ledger,
+				Tags:        splitList(*tags),
+				StartPeriod: *from,
+				EndPeriod:   *to,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	t := table{header: []string{"tag", "period", "category", "spent", "transactions"}}
+	for _, r := range spending {
+		t.rows = append(
+			t.rows, []string{
+				r.Tag, r.Period, r.CategoryName, client.FormatAmount(r.Spent), strconv.Itoa(r.Transactions),
+			},
+		)
+	}
+	return s.print(spending, t)
+}
@@ -0,0 +1,93 @@
+package tui
+
+import "unicode/utf8"
+
+// keyCode names the keys the screens react to besides printable runes.
+type keyCode int
+
+const (
+	keyRune keyCode = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyEnter
+	keyTab
+	keyBacktab
+	keyBackspace
+	keyEsc
+	keyCtrlC
+	keyUnknown
+)
+
+// key is one keystroke; r is set for keyRune.
+type key struct {
+	code keyCode
+	r    rune
+}
+
+// escapes maps the ANSI sequences of xterm compatible terminals, in both
+// normal and application cursor mode, to keys.
+var escapes = map[string]keyCode{
+	"\x1b[A": keyUp, "\x1bOA": keyUp,
+	"\x1b[B": keyDown, "\x1bOB": keyDown,
+	"\x1b[C": keyRight, "\x1bOC": keyRight,
+	"\x1b[D": keyLeft, "\x1bOD": keyLeft,
+	"\x1b[Z": keyBacktab,
+}
+
+// parseKeys splits what one read from a raw mode terminal returned into
+// keys. An ESC byte that starts no escape sequence is the Esc key; unknown
+// sequences are reported as keyUnknown and skipped whole.
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b:
+			n := escapeLen(b)
+			code, ok := escapes[string(b[:n])]
+			switch {
+			case n == 1:
+				code = keyEsc
+			case !ok:
+				code = keyUnknown
+			}
+			keys = append(keys, key{code: code})
+			b = b[n:]
+		case c == '\r' || c == '\n':
+			keys = append(keys, key{code: keyEnter})
+			b = b[1:]
+		case c == '\t':
+			keys = append(keys, key{code: keyTab})
+			b = b[1:]
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, key{code: keyBackspace})
+			b = b[1:]
+		case c == 0x03:
+			keys = append(keys, key{code: keyCtrlC})
+			b = b[1:]
+		case c < 0x20:
+			keys = append(keys, key{code: keyUnknown})
+			b = b[1:]
+		default:
+			r, n := utf8.DecodeRune(b)
+			keys = append(keys, key{code: keyRune, r: r})
+			b = b[n:]
+		}
+	}
+	return keys
+}
+
+// escapeLen returns the length of the escape sequence at the start of b:
+// ESC followed by "[" or "O", parameters, and a final byte.
+func escapeLen(b []byte) int {
+	if len(b) < 2 || (b[1] != '[' && b[1] != 'O') {
+		return 1
+	}
+	for i := 2; i < len(b); i++ {
+		if b[i] >= 0x40 && b[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(b)
+}
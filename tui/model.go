@@ -0,0 +1,380 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+)
+
+// screen is what the model shows.
+type screen int
+
+const (
+	// screenBudget is the month grid of categories.
+	screenBudget screen = iota
+	// screenTransactions lists the transactions of one category in the month.
+	screenTransactions
+)
+
+// action is work the model asks Run to do against the database. Key
+// handling never talks to the database itself, so it can be tested alone.
+type action interface{}
+
+type (
+	quitAction   struct{}
+	reloadAction struct{}
+	openAction   struct{ category client.BudgetStatus }
+	assignAction struct {
+		params   client.AssignToCategoryParams
+		category string
+	}
+	addAction     struct{ params client.AddTransactionParams }
+	correctAction struct{ params client.CorrectTransactionParams }
+)
+
+// model is the state of the budgeting screens.
+type model struct {
+	ledger   client.Ledger
+	period   time.Time // first day of the month shown
+	today    time.Time
+	accounts []client.Account // asset and liability accounts, for entry forms
+
+	status []client.BudgetStatus
+	totals client.BudgetTotals
+	cursor int
+
+	screen       screen
+	category     client.BudgetStatus
+	transactions []client.AccountTransaction // of category, dated in period
+	txCursor     int
+
+	form    *form
+	message string
+}
+
+func newModel(ledger client.Ledger, period, today time.Time) *model {
+	return &model{
+		ledger: ledger,
+		period: time.Date(period.Year(), period.Month(), 1, 0, 0, 0, 0, time.UTC),
+		today:  today,
+	}
+}
+
+// periodString returns the period in the YYYYMM form of api.get_budget_status.
+func (m *model) periodString() string {
+	return m.period.Format("200601")
+}
+
+// setBudget replaces the month grid, keeping the cursor in range.
+func (m *model) setBudget(status []client.BudgetStatus, totals client.BudgetTotals) {
+	m.status, m.totals = status, totals
+	m.cursor = clamp(m.cursor, len(status))
+}
+
+// setTransactions shows the transactions of category dated in the period.
+func (m *model) setTransactions(category client.BudgetStatus, rows []client.AccountTransaction) {
+	m.screen, m.category = screenTransactions, category
+	m.transactions = m.transactions[:0]
+	for _, row := range rows {
+		if row.Date.Year() == m.period.Year() && row.Date.Month() == m.period.Month() {
+			m.transactions = append(m.transactions, row)
+		}
+	}
+	m.txCursor = clamp(m.txCursor, len(m.transactions))
+}
+
+// handleKey applies k and returns the action Run has to carry out, or nil.
+func (m *model) handleKey(k key) action {
+	if k.code == keyCtrlC {
+		return quitAction{}
+	}
+	if m.form != nil {
+		return m.handleFormKey(k)
+	}
+	m.message = ""
+
+	switch {
+	case k.code == keyRune && k.r == 'q':
+		return quitAction{}
+	case k.code == keyRune && k.r == 'r':
+		return reloadAction{}
+	case k.code == keyLeft || k.code == keyRune && (k.r == 'h' || k.r == '['):
+		m.period = m.period.AddDate(0, -1, 0)
+		return reloadAction{}
+	case k.code == keyRight || k.code == keyRune && (k.r == 'l' || k.r == ']'):
+		m.period = m.period.AddDate(0, 1, 0)
+		return reloadAction{}
+	case k.code == keyRune && k.r == 't':
+		m.period = time.Date(m.today.Year(), m.today.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return reloadAction{}
+	}
+
+	if m.screen == screenTransactions {
+		return m.handleTransactionsKey(k)
+	}
+	return m.handleBudgetKey(k)
+}
+
+func (m *model) handleBudgetKey(k key) action {
+	switch {
+	case k.code == keyUp || k.code == keyRune && k.r == 'k':
+		m.cursor = clamp(m.cursor-1, len(m.status))
+	case k.code == keyDown || k.code == keyRune && k.r == 'j':
+		m.cursor = clamp(m.cursor+1, len(m.status))
+	case len(m.status) == 0:
+		m.message = "No categories yet: add some with pgbudget category add"
+	case k.code == keyEnter:
+		return openAction{category: m.status[m.cursor]}
+	case k.code == keyRune && k.r == 'a':
+		m.form = m.assignForm(m.status[m.cursor])
+	case k.code == keyRune && k.r == 'n':
+		m.form = m.transactionForm(m.status[m.cursor], nil)
+	}
+	return nil
+}
+
+func (m *model) handleTransactionsKey(k key) action {
+	switch {
+	case k.code == keyEsc || k.code == keyBackspace:
+		m.screen = screenBudget
+	case k.code == keyUp || k.code == keyRune && k.r == 'k':
+		m.txCursor = clamp(m.txCursor-1, len(m.transactions))
+	case k.code == keyDown || k.code == keyRune && k.r == 'j':
+		m.txCursor = clamp(m.txCursor+1, len(m.transactions))
+	case k.code == keyRune && k.r == 'n':
+		m.form = m.transactionForm(m.category, nil)
+	case k.code == keyEnter || k.code == keyRune && k.r == 'c':
+		if len(m.transactions) == 0 {
+			return nil
+		}
+		row := m.transactions[m.txCursor]
+		if row.Split {
+			m.message = "Split transactions are corrected with pgbudget tx split -tx " + row.UUID
+			return nil
+		}
+		if m.account(row.CategoryUUID) == nil {
+			m.message = "Only transactions on a bank account or credit card can be corrected here"
+			return nil
+		}
+		m.form = m.transactionForm(m.category, &row)
+	}
+	return nil
+}
+
+func (m *model) handleFormKey(k key) action {
+	f := m.form
+	switch k.code {
+	case keyEsc:
+		m.form, m.message = nil, ""
+	case keyTab, keyDown:
+		f.focus = (f.focus + 1) % len(f.fields)
+	case keyBacktab, keyUp:
+		f.focus = (f.focus + len(f.fields) - 1) % len(f.fields)
+	case keyBackspace:
+		v := []rune(f.fields[f.focus].value)
+		if len(v) > 0 {
+			f.fields[f.focus].value = string(v[:len(v)-1])
+		}
+	case keyRune:
+		f.fields[f.focus].value += string(k.r)
+	case keyEnter:
+		if f.focus < len(f.fields)-1 {
+			f.focus++
+			return nil
+		}
+		act, err := f.submit(f.values())
+		if err != nil {
+			m.message = err.Error()
+			return nil
+		}
+		m.form, m.message = nil, ""
+		return act
+	}
+	return nil
+}
+
+// assignForm asks how much Income to assign to category.
+func (m *model) assignForm(category client.BudgetStatus) *form {
+	return &form{
+		title:  "Assign to " + category.CategoryName,
+		fields: []field{{label: "Amount"}, {label: "Description", value: "Budget assignment"}},
+		submit: func(values []string) (action, error) {
+			amount, err := client.ParseAmount(values[0])
+			if err != nil {
+				return nil, err
+			}
+			return assignAction{
+				params: client.AssignToCategoryParams{
+					LedgerUUID:   m.ledger.UUID,
+					Date:         m.entryDate(),
+					Description:  values[1],
+					Amount:       amount,
+					CategoryUUID: category.CategoryUUID,
+				},
+				category: category.CategoryName,
+			}, nil
+		},
+	}
+}
+
+// transactionForm enters a new transaction in category, or corrects row.
+func (m *model) transactionForm(category client.BudgetStatus, row *client.AccountTransaction) *form {
+	f := &form{
+		title: "New transaction in " + category.CategoryName,
+		fields: []field{
+			{label: "Account"},
+			{label: "Type", value: string(client.Outflow)},
+			{label: "Amount"},
+			{label: "Description"},
+			{label: "Date", value: m.entryDate().Format(time.DateOnly)},
+		},
+	}
+	if len(m.accounts) > 0 {
+		f.fields[0].value = m.accounts[0].Name
+	}
+
+	if row != nil {
+		account := m.account(row.CategoryUUID)
+		f.title = "Correct transaction in " + category.CategoryName
+		f.fields[0].value = account.Name
+		f.fields[1].value = accountType(account.Type, row.Type)
+		f.fields[2].value = client.FormatAmount(row.Amount)
+		f.fields[3].value = row.Description
+		f.fields[4].value = row.Date.Format(time.DateOnly)
+	}
+
+	f.submit = func(values []string) (action, error) {
+		account, err := m.findAccount(values[0])
+		if err != nil {
+			return nil, err
+		}
+		txType := client.TransactionType(strings.ToLower(strings.TrimSpace(values[1])))
+		if txType != client.Inflow && txType != client.Outflow {
+			return nil, fmt.Errorf("invalid type %q: use inflow or outflow", values[1])
+		}
+		amount, err := client.ParseAmount(values[2])
+		if err != nil {
+			return nil, err
+		}
+		date, err := time.Parse(time.DateOnly, strings.TrimSpace(values[4]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q: use YYYY-MM-DD", values[4])
+		}
+
+		if row != nil {
+			return correctAction{
+				params: client.CorrectTransactionParams{
+					TransactionUUID: row.UUID,
+					Type:            txType,
+					AccountUUID:     account.UUID,
+					CategoryUUID:    category.CategoryUUID,
+					Amount:          amount,
+					Description:     values[3],
+					Date:            date,
+				},
+			}, nil
+		}
+		return addAction{
+			params: client.AddTransactionParams{
+				LedgerUUID:   m.ledger.UUID,
+				Date:         date,
+				Description:  values[3],
+				Type:         txType,
+				Amount:       amount,
+				AccountUUID:  account.UUID,
+				CategoryUUID: category.CategoryUUID,
+			},
+		}, nil
+	}
+	return f
+}
+
+// entryDate is the default date of new entries: today when it falls in the
+// period, else the first day of the period.
+func (m *model) entryDate() time.Time {
+	if m.today.Year() == m.period.Year() && m.today.Month() == m.period.Month() {
+		return time.Date(m.today.Year(), m.today.Month(), m.today.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return m.period
+}
+
+// account returns the asset or liability account with the given UUID.
+func (m *model) account(uuid string) *client.Account {
+	for i := range m.accounts {
+		if m.accounts[i].UUID == uuid {
+			return &m.accounts[i]
+		}
+	}
+	return nil
+}
+
+// findAccount returns the account whose name is, or uniquely starts with,
+// name, ignoring case.
+func (m *model) findAccount(name string) (*client.Account, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	var found []*client.Account
+	for i := range m.accounts {
+		n := strings.ToLower(m.accounts[i].Name)
+		if n == name {
+			return &m.accounts[i], nil
+		}
+		if name != "" && strings.HasPrefix(n, name) {
+			found = append(found, &m.accounts[i])
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no account named %q", name)
+	case 1:
+		return found[0], nil
+	}
+	return nil, fmt.Errorf("%q matches %d accounts", name, len(found))
+}
+
+// accountType converts the type of a row seen from a category into the type
+// of the same transaction seen from its account. Category rows follow the
+// liability rules, so they agree with asset accounts and are reversed for
+// credit cards.
+func accountType(t client.AccountType, categoryRowType string) string {
+	if t != client.AccountTypeLiability {
+		return categoryRowType
+	}
+	if categoryRowType == string(client.Inflow) {
+		return string(client.Outflow)
+	}
+	return string(client.Inflow)
+}
+
+// form is a small input dialog at the bottom of the screen.
+type form struct {
+	title  string
+	fields []field
+	focus  int
+	submit func(values []string) (action, error)
+}
+
+type field struct {
+	label string
+	value string
+}
+
+func (f *form) values() []string {
+	values := make([]string, len(f.fields))
+	for i, fld := range f.fields {
+		values[i] = fld.value
+	}
+	return values
+}
+
+// clamp keeps a cursor within a list of n items.
+func clamp(cursor, n int) int {
+	switch {
+	case n == 0 || cursor < 0:
+		return 0
+	case cursor >= n:
+		return n - 1
+	}
+	return cursor
+}
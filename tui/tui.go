@@ -0,0 +1,225 @@
+// Package tui is a full-screen terminal interface for monthly budgeting. It
+// draws with plain ANSI escape sequences, so it works in any terminal and
+// needs nothing but the database.
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+	"golang.org/x/term"
+)
+
+// Options configures Run.
+type Options struct {
+	LedgerUUID string
+	// Period is any day of the month shown first.
+	Period time.Time
+	// Do runs fn in a database transaction scoped to the user, typically
+	// through client.WithUser. Every action of the screen is one call.
+	Do func(ctx context.Context, fn func(c *client.Client) error) error
+	// In and Out are the terminal; they default to standard input and output.
+	In  *os.File
+	Out io.Writer
+}
+
+// ErrNotTerminal is returned when standard input is not a terminal.
+var ErrNotTerminal = errors.New("not a terminal")
+
+// Run shows the budget of a ledger until the user quits or ctx is done.
+//
+// The budget screen lists api.get_budget_status for the month with the
+// totals of api.get_budget_totals. Keys move between categories and months,
+// assign money with api.assign_to_category, and open a category to list its
+// transactions of the month from api.get_account_transactions_page, where they
+// can be entered and corrected.
+func Run(ctx context.Context, opts Options) error {
+	if opts.In == nil {
+		opts.In = os.Stdin
+	}
+	if opts.Out == nil {
+		opts.Out = os.Stdout
+	}
+	fd := int(opts.In.Fd())
+	if !term.IsTerminal(fd) {
+		return ErrNotTerminal
+	}
+
+	var m *model
+	err := opts.Do(ctx, func(c *client.Client) error {
+		ledger, err := c.GetLedger(ctx, opts.LedgerUUID)
+		if err != nil {
+			return err
+		}
+		m = newModel(*ledger, opts.Period, time.Now())
+		if err := loadAccounts(ctx, c, m); err != nil {
+			return err
+		}
+		return loadBudget(ctx, c, m)
+	})
+	if err != nil {
+		return err
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to set up terminal: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	// alternate screen, hidden cursor; restored on the way out
+	fmt.Fprint(opts.Out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(opts.Out, "\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan []byte)
+	go func() {
+		for {
+			buf := make([]byte, 64)
+			n, err := opts.In.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- buf[:n]
+		}
+	}()
+
+	for {
+		width, height, err := term.GetSize(fd)
+		if err != nil || width <= 0 || height <= 0 {
+			width, height = 80, 24
+		}
+		draw(opts.Out, m.view(width, height))
+
+		var input []byte
+		select {
+		case <-ctx.Done():
+			return nil
+		case b, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			input = b
+		}
+
+		for _, k := range parseKeys(input) {
+			act := m.handleKey(k)
+			if _, ok := act.(quitAction); ok {
+				return nil
+			}
+			if act != nil {
+				if err := opts.Do(ctx, func(c *client.Client) error { return perform(ctx, c, m, act) }); err != nil {
+					m.message = "Error: " + err.Error()
+				}
+			}
+		}
+	}
+}
+
+// perform carries out an action of the model and reloads what it changed.
+func perform(ctx context.Context, c *client.Client, m *model, act action) error {
+	switch a := act.(type) {
+	case reloadAction:
+		if err := loadBudget(ctx, c, m); err != nil {
+			return err
+		}
+		if m.screen == screenTransactions {
+			return loadTransactions(ctx, c, m, m.category.CategoryUUID)
+		}
+		return nil
+
+	case openAction:
+		m.txCursor = 0
+		return loadTransactions(ctx, c, m, a.category.CategoryUUID)
+
+	case assignAction:
+		if _, err := c.AssignToCategory(ctx, a.params); err != nil {
+			return err
+		}
+		m.message = fmt.Sprintf("Assigned %s to %s", client.FormatAmount(a.params.Amount), a.category)
+
+	case addAction:
+		if _, err := c.AddTransaction(ctx, a.params); err != nil {
+			return err
+		}
+		m.message = "Transaction added"
+
+	case correctAction:
+		if _, err := c.CorrectTransaction(ctx, a.params); err != nil {
+			return err
+		}
+		m.message = "Transaction corrected"
+	}
+
+	return perform(ctx, c, m, reloadAction{})
+}
+
+// loadAccounts keeps the asset and liability accounts of the ledger for the
+// entry forms.
+func loadAccounts(ctx context.Context, c *client.Client, m *model) error {
+	accounts, err := c.ListAccounts(ctx, m.ledger.UUID)
+	if err != nil {
+		return err
+	}
+	m.accounts = m.accounts[:0]
+	for _, a := range accounts {
+		if a.Type == client.AccountTypeAsset || a.Type == client.AccountTypeLiability {
+			m.accounts = append(m.accounts, a)
+		}
+	}
+	return nil
+}
+
+// loadBudget reads the month grid and totals of the model's period.
+func loadBudget(ctx context.Context, c *client.Client, m *model) error {
+	status, err := c.GetBudgetStatus(ctx, m.ledger.UUID, m.periodString())
+	if err != nil {
+		return err
+	}
+	totals, err := c.GetBudgetTotals(ctx, m.ledger.UUID, m.periodString())
+	if err != nil {
+		return err
+	}
+	m.setBudget(status, *totals)
+	return nil
+}
+
+// loadTransactions opens the category with the given UUID, using its row of
+// the freshly loaded month grid.
+func loadTransactions(ctx context.Context, c *client.Client, m *model, categoryUUID string) error {
+	rows, err := c.GetAccountTransactions(ctx, categoryUUID)
+	if err != nil {
+		return err
+	}
+
+	category := m.category
+	for _, s := range m.status {
+		if s.CategoryUUID == categoryUUID {
+			category = s
+		}
+	}
+	m.setTransactions(category, rows)
+	return nil
+}
+
+// draw repaints the screen. Raw mode does not translate newlines, so every
+// line is positioned and cleared explicitly.
+func draw(w io.Writer, lines []string) {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+	io.WriteString(w, b.String())
+}
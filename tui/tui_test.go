@@ -0,0 +1,179 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+	is_ "github.com/matryer/is"
+)
+
+func TestParseKeys(t *testing.T) {
+	is := is_.New(t)
+
+	keys := parseKeys([]byte("a\x1b[A\x1bOB\r\x7f\x1b\x03é\x1b[1;5C"))
+	codes := make([]keyCode, len(keys))
+	for i, k := range keys {
+		codes[i] = k.code
+	}
+	is.Equal(codes, []keyCode{keyRune, keyUp, keyDown, keyEnter, keyBackspace, keyEsc, keyCtrlC, keyRune, keyUnknown})
+	is.Equal(keys[0].r, 'a')
+	is.Equal(keys[7].r, 'é')
+}
+
+// newTestModel is a ledger with a checking account, a credit card and two
+// categories, looking at March 2025.
+func newTestModel() *model {
+	m := newModel(
+		client.Ledger{UUID: "ledger", Name: "Home"},
+		time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC),
+	)
+	m.accounts = []client.Account{
+		{UUID: "checking", Name: "Checking", Type: client.AccountTypeAsset},
+		{UUID: "visa", Name: "Visa", Type: client.AccountTypeLiability},
+	}
+	m.setBudget(
+		[]client.BudgetStatus{
+			{CategoryUUID: "groceries", CategoryName: "Groceries", Budgeted: 20000, Activity: -4500, Balance: 15500},
+			{CategoryUUID: "rent", CategoryName: "Rent", Budgeted: 100000, Balance: 100000},
+		},
+		client.BudgetTotals{Income: 150000, Budgeted: 120000, LeftToBudget: 30000},
+	)
+	return m
+}
+
+// typeKeys feeds s to the model one rune at a time.
+func typeKeys(m *model, s string) {
+	for _, r := range s {
+		m.handleKey(key{code: keyRune, r: r})
+	}
+}
+
+func TestModel(t *testing.T) {
+	t.Run(
+		"Navigation", func(t *testing.T) {
+			is := is_.New(t)
+			m := newTestModel()
+
+			is.Equal(m.periodString(), "202503")
+			m.handleKey(key{code: keyDown})
+			m.handleKey(key{code: keyDown})
+			is.Equal(m.cursor, 1) // stops at the last category
+
+			_, ok := m.handleKey(key{code: keyLeft}).(reloadAction)
+			is.True(ok)
+			is.Equal(m.periodString(), "202502")
+
+			act, ok := m.handleKey(key{code: keyEnter}).(openAction)
+			is.True(ok)
+			is.Equal(act.category.CategoryUUID, "rent")
+
+			_, ok = m.handleKey(key{code: keyRune, r: 'q'}).(quitAction)
+			is.True(ok)
+		},
+	)
+
+	t.Run(
+		"Assign", func(t *testing.T) {
+			is := is_.New(t)
+			m := newTestModel()
+
+			m.handleKey(key{code: keyRune, r: 'a'})
+			is.True(m.form != nil)
+			typeKeys(m, "12x")
+			m.handleKey(key{code: keyBackspace})
+			typeKeys(m, ".5")
+			m.handleKey(key{code: keyEnter}) // to the description
+			act, ok := m.handleKey(key{code: keyEnter}).(assignAction)
+			is.True(ok)
+			is.Equal(act.params.Amount, int64(1250))
+			is.Equal(act.params.CategoryUUID, "groceries")
+			is.Equal(act.params.Date, time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC))
+			is.True(m.form == nil)
+		},
+	)
+
+	t.Run(
+		"InvalidForm", func(t *testing.T) {
+			is := is_.New(t)
+			m := newTestModel()
+
+			m.handleKey(key{code: keyRune, r: 'n'})
+			m.form.focus = len(m.form.fields) - 1
+			is.Equal(m.handleKey(key{code: keyEnter}), nil) // no amount
+			is.True(m.form != nil)
+			is.True(strings.Contains(m.message, "invalid amount"))
+
+			m.handleKey(key{code: keyEsc})
+			is.True(m.form == nil)
+		},
+	)
+
+	t.Run(
+		"AddTransaction", func(t *testing.T) {
+			is := is_.New(t)
+			m := newTestModel()
+			m.period = time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+
+			m.handleKey(key{code: keyRune, r: 'n'})
+			m.form.fields[0].value = "vi" // unique prefix of Visa
+			m.form.fields[1].value = "inflow"
+			m.form.fields[2].value = "45"
+			m.form.focus = len(m.form.fields) - 1
+			act, ok := m.handleKey(key{code: keyEnter}).(addAction)
+			is.True(ok)
+			is.Equal(act.params.AccountUUID, "visa")
+			is.Equal(act.params.Type, client.Inflow)
+			is.Equal(act.params.Amount, int64(4500))
+			is.Equal(act.params.Date, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)) // today is not in February
+		},
+	)
+
+	t.Run(
+		"CorrectTransaction", func(t *testing.T) {
+			is := is_.New(t)
+			m := newTestModel()
+
+			m.setTransactions(
+				m.status[0], []client.AccountTransaction{
+					// a credit card charge, seen from the category
+					{UUID: "tx-1", Date: time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), Category: "Visa", CategoryUUID: "visa", Type: "outflow", Amount: 4500},
+					{UUID: "tx-0", Date: time.Date(2025, 2, 27, 0, 0, 0, 0, time.UTC), Category: "Checking", CategoryUUID: "checking", Type: "outflow", Amount: 100},
+				},
+			)
+			is.Equal(len(m.transactions), 1) // only March
+
+			m.handleKey(key{code: keyRune, r: 'c'})
+			is.Equal(m.form.fields[1].value, "inflow") // a charge is an inflow to the card
+			m.form.fields[2].value = "40.00"
+			m.form.focus = len(m.form.fields) - 1
+			act, ok := m.handleKey(key{code: keyEnter}).(correctAction)
+			is.True(ok)
+			is.Equal(act.params.TransactionUUID, "tx-1")
+			is.Equal(act.params.AccountUUID, "visa")
+			is.Equal(act.params.Type, client.Inflow)
+			is.Equal(act.params.CategoryUUID, "groceries")
+			is.Equal(act.params.Amount, int64(4000))
+
+			m.handleKey(key{code: keyEsc})
+			is.Equal(m.screen, screenBudget)
+		},
+	)
+
+	t.Run(
+		"View", func(t *testing.T) {
+			is := is_.New(t)
+			m := newTestModel()
+
+			lines := m.view(80, 12)
+			is.Equal(len(lines), 12)
+			screen := strings.Join(lines, "\n")
+			is.True(strings.Contains(screen, "Home  March 2025"))
+			is.True(strings.Contains(screen, "Left to budget 300.00"))
+			is.True(strings.Contains(screen, reverse+"  Groceries"))
+			is.True(strings.Contains(screen, "155.00"))
+		},
+	)
+}
@@ -0,0 +1,165 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/j0lvera/pgbudget/client"
+)
+
+// ANSI attributes used by the view.
+const (
+	reverse = "\x1b[7m"
+	bold    = "\x1b[1m"
+	dim     = "\x1b[2m"
+	reset   = "\x1b[0m"
+)
+
+// amountWidth is the width of the amount columns.
+const amountWidth = 12
+
+// view renders the model as exactly height lines of at most width columns.
+func (m *model) view(width, height int) []string {
+	var header, body []string
+	var selected int
+
+	title := fmt.Sprintf("%s  %s", m.ledger.Name, m.period.Format("January 2006"))
+	if m.screen == screenTransactions {
+		title += "  " + m.category.CategoryName
+	}
+	header = append(header, bold+pad(title, width)+reset)
+
+	switch m.screen {
+	case screenBudget:
+		header = append(
+			header,
+			pad(
+				fmt.Sprintf(
+					"Income %s   Budgeted %s   Left to budget %s",
+					client.FormatAmount(m.totals.Income), client.FormatAmount(m.totals.Budgeted),
+					client.FormatAmount(m.totals.LeftToBudget),
+				), width,
+			),
+			"",
+			dim+row(width, "CATEGORY", "BUDGETED", "ACTIVITY", "BALANCE")+reset,
+		)
+		for _, s := range m.status {
+			body = append(
+				body, row(
+					width, s.CategoryName, client.FormatAmount(s.Budgeted),
+					client.FormatAmount(s.Activity), client.FormatAmount(s.Balance),
+				),
+			)
+		}
+		selected = m.cursor
+
+	case screenTransactions:
+		header = append(
+			header,
+			pad(
+				fmt.Sprintf(
+					"Budgeted %s   Activity %s   Balance %s",
+					client.FormatAmount(m.category.Budgeted), client.FormatAmount(m.category.Activity),
+					client.FormatAmount(m.category.Balance),
+				), width,
+			),
+			"",
+			dim+row(width, "DATE        ACCOUNT / DESCRIPTION", "", "AMOUNT", "BALANCE")+reset,
+		)
+		for _, t := range m.transactions {
+			amount := t.Amount
+			if t.Type == string(client.Outflow) {
+				amount = -amount
+			}
+			body = append(
+				body, row(
+					width, t.Date.Format(time.DateOnly)+"  "+t.Category+" / "+t.Description, "",
+					client.FormatAmount(amount), client.FormatAmount(t.RunningBalance),
+				),
+			)
+		}
+		selected = m.txCursor
+	}
+
+	footer := m.footer(width)
+
+	// the body scrolls to keep the selected row visible
+	rows := height - len(header) - len(footer)
+	if rows < 1 {
+		rows = 1
+	}
+	start := 0
+	if selected >= rows {
+		start = selected - rows + 1
+	}
+
+	lines := append([]string{}, header...)
+	for i := start; i < start+rows; i++ {
+		switch {
+		case i >= len(body):
+			if i == 0 {
+				lines = append(lines, dim+"  (nothing this month)"+reset)
+			} else {
+				lines = append(lines, "")
+			}
+		case i == selected && m.form == nil:
+			lines = append(lines, reverse+body[i]+reset)
+		default:
+			lines = append(lines, body[i])
+		}
+	}
+	lines = append(lines, footer...)
+
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return lines
+}
+
+// footer returns the open form, or the message and key help.
+func (m *model) footer(width int) []string {
+	if m.form != nil {
+		lines := []string{"", bold + pad(m.form.title, width) + reset}
+		for i, f := range m.form.fields {
+			line := fmt.Sprintf("  %-12s %s", f.label+":", f.value)
+			if i == m.form.focus {
+				line = reverse + pad(line+"_", width) + reset
+			}
+			lines = append(lines, line)
+		}
+		return append(lines, pad(m.message, width), dim+pad("enter next/save  tab move  esc cancel", width)+reset)
+	}
+
+	help := "↑↓ select  ←→ month  t today  enter open  a assign  n new  r reload  q quit"
+	if m.screen == screenTransactions {
+		help = "↑↓ select  ←→ month  c correct  n new  esc back  r reload  q quit"
+	}
+	return []string{"", pad(m.message, width), dim + pad(help, width) + reset}
+}
+
+// row lays out a name column and three right aligned amount columns.
+func row(width int, name, a, b, c string) string {
+	nameWidth := width - 3*(amountWidth+1) - 2
+	if nameWidth < 10 {
+		nameWidth = 10
+	}
+	return fmt.Sprintf(
+		"  %s %*s %*s %*s",
+		pad(name, nameWidth), amountWidth, a, amountWidth, b, amountWidth, c,
+	)
+}
+
+// pad cuts s to width runes, or fills it with spaces up to width.
+func pad(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		r := []rune(s)
+		if width <= 1 {
+			return string(r[:width])
+		}
+		return string(r[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-n)
+}
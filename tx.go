@@ -0,0 +1,695 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/j0lvera/pgbudget/client"
+)
+
+const txAddUsage = `Usage: pgbudget tx add -account <uuid> -amount <amount> [flags]
+
+Records a transaction on a bank account or credit card. Outflows spend
+from the category, inflows add to it; income is recorded as an inflow to
+the Income category. Amounts are positive decimals such as 12.34.
+
+-payee names who was paid; it is created the first time. Without -category
+the payee's default category is used, and with one the payee remembers it.
+
+Flags:
+`
+
+const txTransferUsage = `Usage: pgbudget tx transfer -from <uuid> -to <uuid> -amount <amount> [flags]
+
+Moves money between two bank accounts or credit cards, such as a transfer
+to savings or a credit card payment, without touching budget categories.
+Paying a credit card from a bank account also spends the money set aside in
+the "<card> Payment" category of the card.
+
+Flags:
+`
+
+const txListUsage = `Usage: pgbudget tx list -account <uuid> [flags]
+
+Lists the transactions of an account, newest first, with the running
+balance after each one. -status pending lists the transactions the bank
+has not cleared yet and -tags the ones carrying every tag given. -limit
+pages the history: the cursors of the neighbouring pages are printed, pass
+them as -after or -before.
+
+Flags:
+`
+
+const txCorrectUsage = `Usage: pgbudget tx correct -tx <uuid> -account <uuid> -amount <amount> [flags]
+
+Replaces a transaction: the original is reversed and a corrected copy is
+recorded, both kept in the transaction log. Every field of the corrected
+transaction must be given, except -payee: the payee is kept unless another
+is named. Reconciled transactions are only corrected with -override.
+
+Flags:
+`
+
+const txDeleteUsage = `Usage: pgbudget tx delete -tx <uuid> [flags]
+
+Cancels a transaction with a reversing entry kept in the transaction log.
+Reconciled transactions are only deleted with -override.
+
+Flags:
+`
+
+const txSplitUsage = `Usage: pgbudget tx split -account <uuid> -split <category>=<amount>[:<memo>] -split ... [flags]
+
+Records one transaction on a bank account or credit card shared by several
+categories, such as a receipt covering groceries and household goods. Give
+-split once per category; the amounts add up to the transaction.
+
+With -tx, an existing split transaction is corrected instead: all of its
+splits are reversed and the given ones recorded, every step kept in the
+transaction log. Every field must be given again.
+
+Flags:
+`
+
+const txPostUsage = `Usage: pgbudget tx post [flags] <uuid>...
+
+Marks pending transactions, such as the ones recorded by pgbudget scheduler
+run, as cleared by the bank, all or none. A split transaction is posted with
+all of its splits. Transactions that are posted already are left alone.
+
+Flags:
+`
+
+const txShowUsage = `Usage: pgbudget tx show -tx <uuid> [flags]
+
+Shows the splits of a split transaction. tx list prints a split transaction
+as one row carrying its UUID.
+
+Flags:
+`
+
+const txSearchUsage = `Usage: pgbudget tx search [flags] [<query>...]
+
+Finds the transactions of a ledger, newest first. The query matches
+descriptions by words, word prefixes or any fragment, and payee names by
+words; the flags narrow the search. Corrected and deleted transactions are
+left out. When a page is full, the cursor of the next one is printed: pass
+it as -after.
+
+Flags:
+`
+
+// txResult is printed by the commands that record a transaction.
+type txResult struct {
+	UUID string `json:"uuid"`
+}
+
+func runTx(ctx context.Context, args []string) error {
+	return runSubcommand(
+		ctx, "tx", []subcommand{
+			{"add", "record a transaction", runTxAdd},
+			{"transfer", "move money between accounts", runTxTransfer},
+			{"list", "list the transactions of an account", runTxList},
+			{"search", "search the transactions of a ledger", runTxSearch},
+			{"correct", "correct a transaction", runTxCorrect},
+			{"delete", "delete a transaction", runTxDelete},
+			{"post", "mark pending transactions as cleared", runTxPost},
+			{"split", "record or correct a transaction split across categories", runTxSplit},
+			{"show", "show the splits of a split transaction", runTxShow},
+		}, args,
+	)
+}
+
+// txFlags are the fields of a transaction shared by tx add and tx correct.
+type txFlags struct {
+	account     *string
+	category    *string
+	txType      *string
+	amount      *string
+	date        *string
+	description *string
+}
+
+func registerTxFlags(fs *flag.FlagSet) txFlags {
+	return txFlags{
+		account:     fs.String("account", "", "UUID of the bank account or credit card"),
+		category:    fs.String("category", "", "category name or UUID (default Unassigned)"),
+		txType:      fs.String("type", string(client.Outflow), "inflow or outflow"),
+		amount:      fs.String("amount", "", "positive amount, e.g. 12.34"),
+		date:        fs.String("date", "", "date as YYYY-MM-DD (default today)"),
+		description: fs.String("description", "", "description"),
+	}
+}
+
+// parse validates the flags and returns the amount in cents and the date.
+func (f txFlags) parse() (int64, time.Time, error) {
+	switch {
+	case *f.account == "":
+		return 0, time.Time{}, errors.New("missing -account")
+	case *f.amount == "":
+		return 0, time.Time{}, errors.New("missing -amount")
+	case *f.txType != string(client.Inflow) && *f.txType != string(client.Outflow):
+		return 0, time.Time{}, fmt.Errorf("invalid -type %q: use inflow or outflow", *f.txType)
+	}
+
+	amount, err := client.ParseAmount(*f.amount)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	date, err := parseDate(*f.date)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return amount, date, nil
+}
+
+func runTxAdd(ctx context.Context, args []string) error {
+	fs := newFlagSet("tx add", txAddUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	f := registerTxFlags(fs)
+	payee := fs.String("payee", "", "payee name")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	amount, date, err := f.parse()
+	if err != nil {
+		return err
+	}
+
+	var result txResult
+	err = s.with(ctx, func(c *client.Client) error {
+		categoryUUID, err := resolveCategory(ctx, c, s.ledger, *f.category)
+		if err != nil {
+			return err
+		}
+		result.UUID, err = c.AddTransaction(
+			ctx, client.AddTransactionParams{
+				LedgerUUID:   s.ledger,
+				Date:         date,
+				Description:  *f.description,
+				Type:         client.TransactionType(*f.txType),
+				Amount:       amount,
+				AccountUUID:  *f.account,
+				CategoryUUID: categoryUUID,
+				Payee:        *payee,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(result, table{header: []string{"uuid"}, rows: [][]string{{result.UUID}}})
+}
+
+func runTxTransfer(ctx context.Context, args []string) error {
+	fs := newFlagSet("tx transfer", txTransferUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	from := fs.String("from", "", "UUID of the account the money leaves")
+	to := fs.String("to", "", "UUID of the account the money enters")
+	amount := fs.String("amount", "", "positive amount, e.g. 12.34")
+	date := fs.String("date", "", "date as YYYY-MM-DD (default today)")
+	memo := fs.String("memo", "", "description (default \"Transfer from <from> to <to>\")")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	switch {
+	case *from == "":
+		return errors.New("missing -from")
+	case *to == "":
+		return errors.New("missing -to")
+	case *amount == "":
+		return errors.New("missing -amount")
+	}
+	cents, err := client.ParseAmount(*amount)
+	if err != nil {
+		return err
+	}
+	day, err := parseDate(*date)
+	if err != nil {
+		return err
+	}
+
+	var result txResult
+	err = s.with(ctx, func(c *client.Client) (err error) {
+		result.UUID, err = c.AddTransfer(
+			ctx, client.AddTransferParams{
+				LedgerUUID:      s.ledger,
+				FromAccountUUID: *from,
+				ToAccountUUID:   *to,
+				Amount:          cents,
+				Date:            day,
+				Memo:            *memo,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(result, table{header: []string{"uuid"}, rows: [][]string{{result.UUID}}})
+}
+
+func runTxList(ctx context.Context, args []string) error {
+	fs := newFlagSet("tx list", txListUsage)
+	s := registerSessionFlags(fs)
+	s.registerFormatFlag(fs)
+	account := fs.String("account", "", "account UUID")
+	limit := fs.Int("limit", 0, "show at most this many transactions (0 for all)")
+	after := fs.String("after", "", "show the transactions older than this transaction UUID")
+	before := fs.String("before", "", "show the transactions newer than this transaction UUID")
+	total := fs.Bool("total", false, "count the transactions of every page")
+	status := fs.String("status", "", "pending or posted (default both)")
+	tags := fs.String("tags", "", "comma-separated tags the transactions must carry")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	switch {
+	case *account == "":
+		return errors.New("missing -account")
+	case *limit < 0:
+		return errors.New("-limit must not be negative")
+	case *status != "" && *status != string(client.StatusPending) && *status != string(client.StatusPosted):
+		return fmt.Errorf("invalid -status %q: use pending or posted", *status)
+	}
+
+	var page *client.AccountTransactionsPage
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		page, err = c.GetAccountTransactionsPage(
+			ctx, client.AccountTransactionsParams{
+				AccountUUID: *account,
+				Status:      client.TransactionStatus(*status),
+				Tags:        splitList(*tags),
+				After:       *after,
+				Before:      *before,
+				Limit:       *limit,
+				WithTotal:   *total,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	rows := page.Transactions
+
+	t := table{header: []string{"date", "category", "payee", "description", "type", "amount", "balance", "status", "tags"}}
+	for _, r := range rows {
+		t.rows = append(
+			t.rows, []string{
+				r.Date.Format(time.DateOnly), r.Category, deref(r.Payee), r.Description, r.Type,
+				client.FormatAmount(r.Amount), client.FormatAmount(r.RunningBalance), string(r.Status),
+				strings.Join(r.Tags, ","),
+			},
+		)
+	}
+	// the transactions past a cursor are a page away; a full page may be
+	// followed by more
+	full := *limit > 0 && len(rows) == *limit
+	if len(rows) > 0 && (*after != "" || (*before != "" && full)) {
+		t.footer = append(t.footer, "newer page: -before "+rows[0].UUID)
+	}
+	if len(rows) > 0 && (*before != "" || full) {
+		t.footer = append(t.footer, "older page: -after "+rows[len(rows)-1].UUID)
+	}
+	if page.Total != nil {
+		t.footer = append(t.footer, strconv.FormatInt(*page.Total, 10)+" transactions in all")
+	}
+	return s.print(rows, t)
+}
+
+func runTxCorrect(ctx context.Context, args []string) error {
+	fs := newFlagSet("tx correct", txCorrectUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	transaction := fs.String("tx", "", "UUID of the transaction to correct")
+	reason := fs.String("reason", "", "reason stored in the transaction log")
+	override := fs.Bool("override", false, "correct the transaction even if it is reconciled")
+	f := registerTxFlags(fs)
+	payee := fs.String("payee", "", "payee name (default the payee of the transaction)")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if *transaction == "" {
+		return errors.New("missing -tx")
+	}
+	if *f.category != "" {
+		// category names are looked up in the ledger
+		if err := s.requireLedger(); err != nil {
+			return err
+		}
+	}
+	amount, date, err := f.parse()
+	if err != nil {
+		return err
+	}
+
+	var result txResult
+	err = s.with(ctx, func(c *client.Client) error {
+		categoryUUID, err := resolveCategory(ctx, c, s.ledger, *f.category)
+		if err != nil {
+			return err
+		}
+		result.UUID, err = c.CorrectTransaction(
+			ctx, client.CorrectTransactionParams{
+				TransactionUUID: *transaction,
+				Type:            client.TransactionType(*f.txType),
+				AccountUUID:     *f.account,
+				CategoryUUID:    categoryUUID,
+				Amount:          amount,
+				Description:     *f.description,
+				Date:            date,
+				Reason:          *reason,
+				Override:        *override,
+				Payee:           *payee,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(result, table{header: []string{"uuid"}, rows: [][]string{{result.UUID}}})
+}
+
+func runTxDelete(ctx context.Context, args []string) error {
+	fs := newFlagSet("tx delete", txDeleteUsage)
+	s := registerSessionFlags(fs)
+	s.registerFormatFlag(fs)
+	transaction := fs.String("tx", "", "UUID of the transaction to delete")
+	reason := fs.String("reason", "", "reason stored in the transaction log")
+	override := fs.Bool("override", false, "delete the transaction even if it is reconciled")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if *transaction == "" {
+		return errors.New("missing -tx")
+	}
+
+	var result txResult
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		if *override {
+			result.UUID, err = c.DeleteReconciledTransaction(ctx, *transaction, *reason)
+		} else {
+			result.UUID, err = c.DeleteTransaction(ctx, *transaction, *reason)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(result, table{header: []string{"uuid"}, rows: [][]string{{result.UUID}}})
+}
+
+// postResult is printed by tx post.
+type postResult struct {
+	Posted int `json:"posted"`
+}
+
+func runTxPost(ctx context.Context, args []string) error {
+	fs := newFlagSet("tx post", txPostUsage)
+	s := registerSessionFlags(fs)
+	s.registerFormatFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if err := s.resolve(); err != nil {
+		return err
+	}
+
+	var result postResult
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		result.Posted, err = c.PostTransactions(ctx, fs.Args())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(result, table{header: []string{"posted"}, rows: [][]string{{strconv.Itoa(result.Posted)}}})
+}
+
+// splitArg is one -split flag of tx split.
+type splitArg struct {
+	category string
+	amount   int64
+	memo     string
+}
+
+// splitArgs collects the repeated -split flags.
+type splitArgs []splitArg
+
+func (a *splitArgs) String() string { return "" }
+
+func (a *splitArgs) Set(value string) error {
+	arg, err := parseSplit(value)
+	if err != nil {
+		return err
+	}
+	*a = append(*a, arg)
+	return nil
+}
+
+// parseSplit reads a split given as category=amount[:memo]. An empty
+// category stands for Unassigned.
+func parseSplit(value string) (splitArg, error) {
+	category, rest, ok := strings.Cut(value, "=")
+	if !ok {
+		return splitArg{}, fmt.Errorf("invalid split %q: use category=amount[:memo]", value)
+	}
+	amount, memo, _ := strings.Cut(rest, ":")
+
+	cents, err := client.ParseAmount(amount)
+	if err != nil {
+		return splitArg{}, err
+	}
+	return splitArg{category: strings.TrimSpace(category), amount: cents, memo: strings.TrimSpace(memo)}, nil
+}
+
+func runTxSplit(ctx context.Context, args []string) error {
+	fs := newFlagSet("tx split", txSplitUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	transaction := fs.String("tx", "", "UUID of a split transaction to correct")
+	reason := fs.String("reason", "", "reason stored in the transaction log, with -tx")
+	override := fs.Bool("override", false, "correct the split transaction even if it is reconciled, with -tx")
+	account := fs.String("account", "", "UUID of the bank account or credit card")
+	txType := fs.String("type", string(client.Outflow), "inflow or outflow")
+	dateFlag := fs.String("date", "", "date as YYYY-MM-DD (default today)")
+	description := fs.String("description", "", "description")
+	var splits splitArgs
+	fs.Var(&splits, "split", "category name or UUID, amount and optional memo as category=amount[:memo]; repeat per category")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+
+	switch {
+	case *account == "":
+		return errors.New("missing -account")
+	case len(splits) < 2:
+		return errors.New("give -split at least twice")
+	case *txType != string(client.Inflow) && *txType != string(client.Outflow):
+		return fmt.Errorf("invalid -type %q: use inflow or outflow", *txType)
+	}
+	// a correction only needs the ledger to look up category names
+	if *transaction == "" || slices.ContainsFunc(splits, func(a splitArg) bool { return a.category != "" }) {
+		if err := s.requireLedger(); err != nil {
+			return err
+		}
+	}
+	date, err := parseDate(*dateFlag)
+	if err != nil {
+		return err
+	}
+
+	var result txResult
+	err = s.with(ctx, func(c *client.Client) error {
+		params := make([]client.Split, len(splits))
+		for i, a := range splits {
+			categoryUUID, err := resolveCategory(ctx, c, s.ledger, a.category)
+			if err != nil {
+				return err
+			}
+			params[i] = client.Split{CategoryUUID: categoryUUID, Amount: a.amount, Memo: a.memo}
+		}
+
+		if *transaction != "" {
+			result.UUID, err = c.CorrectSplitTransaction(
+				ctx, client.CorrectSplitTransactionParams{
+					SplitUUID:   *transaction,
+					Type:        client.TransactionType(*txType),
+					AccountUUID: *account,
+					Date:        date,
+					Description: *description,
+					Splits:      params,
+					Reason:      *reason,
+					Override:    *override,
+				},
+			)
+			return err
+		}
+		result.UUID, err = c.AddSplitTransaction(
+			ctx, client.AddSplitTransactionParams{
+				LedgerUUID:  s.ledger,
+				Date:        date,
+				Description: *description,
+				Type:        client.TransactionType(*txType),
+				AccountUUID: *account,
+				Splits:      params,
+			},
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.print(result, table{header: []string{"uuid"}, rows: [][]string{{result.UUID}}})
+}
+
+func runTxShow(ctx context.Context, args []string) error {
+	fs := newFlagSet("tx show", txShowUsage)
+	s := registerSessionFlags(fs)
+	s.registerFormatFlag(fs)
+	transaction := fs.String("tx", "", "UUID of the split transaction")
+	if err := parseFlags(fs, s, args); err != nil {
+		return err
+	}
+	if *transaction == "" {
+		return errors.New("missing -tx")
+	}
+
+	var split *client.SplitTransaction
+	err := s.with(ctx, func(c *client.Client) (err error) {
+		split, err = c.GetSplitTransaction(ctx, *transaction)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	t := table{
+		header: []string{"uuid", "category", "memo", "amount"},
+		footer: []string{
+			fmt.Sprintf(
+				"%s  %s  %s %s", split.Date.Format(time.DateOnly), split.Description, split.Type,
+				client.FormatAmount(split.Amount),
+			),
+		},
+	}
+	for _, sp := range split.Splits {
+		t.rows = append(t.rows, []string{sp.UUID, sp.Category, sp.Memo, client.FormatAmount(sp.Amount)})
+	}
+	return s.print(split, t)
+}
+
+func runTxSearch(ctx context.Context, args []string) error {
+	fs := newFlagSet("tx search", txSearchUsage)
+	s := registerSessionFlags(fs)
+	s.registerLedgerFlag(fs)
+	s.registerFormatFlag(fs)
+	minAmount := fs.String("min", "", "minimum amount, e.g. 10.00")
+	maxAmount := fs.String("max", "", "maximum amount")
+	from := fs.String("from", "", "first date as YYYY-MM-DD")
+	to := fs.String("to", "", "last date as YYYY-MM-DD")
+	account := fs.String("account", "", "account UUID")
+	category := fs.String("category", "", "category name or UUID")
+	status := fs.String("status", "", "pending or posted (default both)")
+	keys := fs.String("keys", "", "comma-separated metadata keys the transactions must have, e.g. fitid")
+	after := fs.String("after", "", "cursor printed with the previous page")
+	limit := fs.Int("limit", 50, "transactions per page")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := s.resolve(); err != nil {
+		return err
+	}
+	if err := s.requireLedger(); err != nil {
+		return err
+	}
+	switch {
+	case *limit < 1:
+		return errors.New("-limit must be positive")
+	case *status != "" && *status != string(client.StatusPending) && *status != string(client.StatusPosted):
+		return fmt.Errorf("invalid -status %q: use pending or posted", *status)
+	}
+
+	params := client.SearchParams{
+		LedgerUUID:   s.ledger,
+		Query:        strings.Join(fs.Args(), " "),
+		AccountUUID:  *account,
+		Status:       client.TransactionStatus(*status),
+		MetadataKeys: splitList(*keys),
+		After:        *after,
+		Limit:        *limit,
+	}
+	var err error
+	if params.MinAmount, err = parseAmountBound("-min", *minAmount); err != nil {
+		return err
+	}
+	if params.MaxAmount, err = parseAmountBound("-max", *maxAmount); err != nil {
+		return err
+	}
+	if *from != "" {
+		if params.StartDate, err = parseDate(*from); err != nil {
+			return err
+		}
+	}
+	if *to != "" {
+		if params.EndDate, err = parseDate(*to); err != nil {
+			return err
+		}
+	}
+
+	var page *client.SearchPage
+	err = s.with(ctx, func(c *client.Client) (err error) {
+		if params.CategoryUUID, err = resolveCategory(ctx, c, s.ledger, *category); err != nil {
+			return err
+		}
+		page, err = c.Search(ctx, params)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	t := table{header: []string{"uuid", "date", "account", "category", "payee", "description", "type", "amount", "status"}}
+	for _, r := range page.Transactions {
+		t.rows = append(
+			t.rows, []string{
+				r.UUID, r.Date.Format(time.DateOnly), r.AccountName, r.CategoryName, deref(r.Payee), r.Description,
+				r.Type, client.FormatAmount(r.Amount), string(r.Status),
+			},
+		)
+	}
+	if page.NextCursor != "" {
+		t.footer = []string{"next page: -after " + page.NextCursor}
+	}
+	return s.print(page, t)
+}